@@ -0,0 +1,45 @@
+// Command backfill-actors fills the actor name and role of events written before
+// they were recorded. New events are described on write; run this once after
+// deploying, and again whenever it reports unresolved actors that were since synced.
+//
+//	backfill-actors -dry-run
+//	backfill-actors
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"passport-booking/database"
+	"passport-booking/logger"
+	"passport-booking/services/attribution"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report how many rows would be filled without writing")
+	flag.Parse()
+
+	db, err := database.InitDB()
+	if err != nil {
+		logger.Error("Failed to connect to the database", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	results := make([]*attribution.BackfillResult, 0, len(attribution.Columns))
+	for _, col := range attribution.Columns {
+		result, err := attribution.Backfill(db, col, *dryRun)
+		results = append(results, result)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to backfill %s.%s", col.Table, col.Ref), err)
+			failed = true
+		}
+	}
+
+	out, _ := json.MarshalIndent(results, "", "  ")
+	fmt.Println(string(out))
+	if failed {
+		os.Exit(1)
+	}
+}
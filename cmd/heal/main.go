@@ -0,0 +1,72 @@
+// Command heal runs the auto-heal fixes from the runbook against the database:
+//
+//	heal -command recompute-status -booking 42,43
+//	heal -command regenerate-status-events -all -apply
+//	heal -command relink-bags -all -apply
+//
+// Without -apply it only prints what it would change.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"passport-booking/database"
+	"passport-booking/logger"
+	"passport-booking/services/auto_heal"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	command := flag.String("command", "", "recompute-status, regenerate-status-events or relink-bags")
+	bookings := flag.String("booking", "", "comma-separated booking IDs")
+	all := flag.Bool("all", false, "scan every booking for the problem")
+	apply := flag.Bool("apply", false, "write the fixes instead of only reporting them")
+	force := flag.Bool("force", false, "recompute-status: also move delivered or returned bookings and make moves the workflow does not allow")
+	limit := flag.Int("limit", 0, "most fixes to make in one run (default AUTO_HEAL_LIMIT or 500)")
+	flag.Parse()
+
+	cmd := auto_heal.Command(*command)
+	if !cmd.IsValid() {
+		fmt.Fprintln(os.Stderr, "heal: -command must be recompute-status, regenerate-status-events or relink-bags")
+		os.Exit(2)
+	}
+
+	var ids []uint
+	for _, part := range strings.Split(*bookings, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "heal: invalid booking ID %q\n", part)
+			os.Exit(2)
+		}
+		ids = append(ids, uint(id))
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		logger.Error("Failed to connect to the database", err)
+		os.Exit(1)
+	}
+
+	result, err := auto_heal.Run(db, cmd, auto_heal.Options{
+		BookingIDs: ids,
+		All:        *all,
+		Apply:      *apply,
+		Force:      *force,
+		Limit:      *limit,
+		Actor:      auto_heal.CLIActor,
+	})
+	if result != nil {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(out))
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Auto-heal %s failed", cmd), err)
+		os.Exit(1)
+	}
+}
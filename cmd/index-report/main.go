@@ -0,0 +1,51 @@
+// Command index-report lists the indexes Postgres has not scanned since its statistics
+// were last reset. Run it against production after a representative period of traffic
+// to verify the delivery flow indexes are used, and before dropping one that is not.
+// It exits with status 1 when an unused index is found and -fail is set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"passport-booking/database"
+	"passport-booking/logger"
+)
+
+func main() {
+	fail := flag.Bool("fail", false, "exit with status 1 when an unused index is found")
+	flag.Parse()
+
+	db, err := database.InitDB()
+	if err != nil {
+		logger.Error("Failed to connect to the database", err)
+		os.Exit(1)
+	}
+
+	resetAt, err := database.StatsResetAt(db)
+	if err != nil {
+		logger.Error("Failed to read when index statistics were reset", err)
+	}
+	if resetAt == "" {
+		resetAt = "never"
+	}
+
+	unused, err := database.UnusedIndexes(db)
+	if err != nil {
+		logger.Error("Failed to read index usage", err)
+		os.Exit(1)
+	}
+
+	logger.Info(fmt.Sprintf("Index statistics last reset: %s", resetAt))
+	if len(unused) == 0 {
+		logger.Success("Every index has been scanned")
+		return
+	}
+	for _, idx := range unused {
+		logger.Warning(fmt.Sprintf("Unused index %s on %s (%d kB)", idx.Index, idx.Table, idx.SizeBytes/1024))
+	}
+	logger.Warning(fmt.Sprintf("%d unused indexes", len(unused)))
+	if *fail {
+		os.Exit(1)
+	}
+}
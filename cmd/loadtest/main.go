@@ -0,0 +1,36 @@
+// Command loadtest prepares and supports the load-test scenarios in loadtest/.
+//
+//	loadtest seed -username <account>   create fixtures and write loadtest/fixtures.json
+//	loadtest clean                      delete every fixture row seed created
+//	loadtest dms-stub                   serve a fake DMS so runs do not touch the real one
+//
+// Fixture rows are recognisable by the LT prefix of their IDs and barcodes, so clean
+// never touches real data.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: loadtest <seed|clean|dms-stub> [flags]")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "seed":
+		seed(args)
+	case "clean":
+		clean(args)
+	case "dms-stub":
+		dmsStub(args)
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"passport-booking/database"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	shipmentModel "passport-booking/models/shipment"
+	"passport-booking/models/slip_parser"
+	"passport-booking/models/user"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// fixturePrefix marks every row seed creates
+	fixturePrefix = "LT"
+	// fixtureOffice is the delivery branch of seeded bookings and the office the
+	// seeded transport line serves
+	fixtureOffice = "LT-OFFICE"
+	fixtureLine   = "LT-LINE"
+)
+
+// Fixtures is written to loadtest/fixtures.json for the k6 scenarios
+type Fixtures struct {
+	RunID      string   `json:"run_id"`
+	RequestIDs []string `json:"request_ids"` // successful slip parses for booking create
+	BagIDs     []string `json:"bag_ids"`     // bags of bagged bookings for bag receive
+	LineID     string   `json:"line_id"`
+	Barcodes   []string `json:"barcodes"` // booked items for tracking reads
+}
+
+func seed(args []string) {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	username := fs.String("username", "", "existing account the seeded bookings belong to (the load-test token's user)")
+	slips := fs.Int("slips", 2000, "successful slip parses to create for booking create (at most 9999)")
+	bags := fs.Int("bags", 20, "bags to create for bag receive")
+	items := fs.Int("items", 500, "bookings per bag")
+	out := fs.String("out", "loadtest/fixtures.json", "where to write the fixture IDs")
+	fs.Parse(args)
+
+	if *username == "" {
+		fmt.Fprintln(os.Stderr, "seed: -username is required")
+		os.Exit(2)
+	}
+	if *slips > 9999 || *items > 9999 || *bags > 999 {
+		fmt.Fprintln(os.Stderr, "seed: at most 9999 slips, 999 bags and 9999 items per bag")
+		os.Exit(2)
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		logger.Error("Failed to connect to the database", err)
+		os.Exit(1)
+	}
+
+	var owner user.User
+	if err := db.Where("username = ?", *username).First(&owner).Error; err != nil {
+		logger.Error(fmt.Sprintf("Load-test account %s not found; log in with it once first", *username), err)
+		os.Exit(1)
+	}
+
+	// The run id keeps fixture IDs unique across seeds without a clean in between
+	runID := fmt.Sprintf("%06d", time.Now().Unix()%1000000)
+	fixtures := Fixtures{RunID: runID, LineID: fixtureLine}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := seedLine(tx); err != nil {
+			return err
+		}
+		requestIDs, err := seedSlips(tx, runID, *slips)
+		if err != nil {
+			return err
+		}
+		fixtures.RequestIDs = requestIDs
+		fixtures.BagIDs, fixtures.Barcodes, err = seedBags(tx, runID, owner, *bags, *items)
+		return err
+	})
+	if err != nil {
+		logger.Error("Failed to seed load-test fixtures", err)
+		os.Exit(1)
+	}
+
+	raw, _ := json.MarshalIndent(fixtures, "", "  ")
+	if err := os.WriteFile(*out, raw, 0644); err != nil {
+		logger.Error(fmt.Sprintf("Failed to write %s", *out), err)
+		os.Exit(1)
+	}
+	logger.Success(fmt.Sprintf("Seeded %d slips, %d bags of %d items (run %s) into %s",
+		len(fixtures.RequestIDs), len(fixtures.BagIDs), *items, runID, *out))
+}
+
+// seedLine registers the transport line bag receive checks against
+func seedLine(tx *gorm.DB) error {
+	var line bookingModel.TransportLine
+	err := tx.Where("code = ?", fixtureLine).
+		Attrs(bookingModel.TransportLine{Name: "Load test line", OriginOffice: fixtureOffice, Active: true}).
+		FirstOrCreate(&line).Error
+	if err != nil {
+		return err
+	}
+	office := bookingModel.TransportLineOffice{LineID: line.ID, OfficeCode: fixtureOffice}
+	return tx.Where(office).FirstOrCreate(&office).Error
+}
+
+// seedSlips creates successful slip parses whose application IDs pass validation
+func seedSlips(tx *gorm.DB, runID string, count int) ([]string, error) {
+	requestIDs := make([]string, 0, count)
+	rows := make([]slip_parser.SlipParserRequest, 0, count)
+	for i := 0; i < count; i++ {
+		requestID := fmt.Sprintf("%s%s%016d", fixturePrefix, runID, i)
+		requestIDs = append(requestIDs, requestID)
+		rows = append(rows, slip_parser.SlipParserRequest{
+			RequestID:        requestID,
+			OriginalFileName: "loadtest.pdf",
+			SavedFileName:    "loadtest.pdf",
+			FilePath:         "loadtest.pdf",
+			MimeType:         "application/pdf",
+			Status:           "success",
+			// OID plus 10 digits, the shape of a valid e-passport application ID
+			AppOrOrderID: fmt.Sprintf("OID%s%04d", runID, i),
+			Name:         "Load Test Applicant",
+			FatherName:   "Load Test Father",
+			MotherName:   "Load Test Mother",
+			Phone:        fmt.Sprintf("+88017%08d", i),
+			Address:      "Load test address",
+		})
+	}
+	return requestIDs, tx.CreateInBatches(rows, 500).Error
+}
+
+// seedBags creates booked bookings already added to bags, each with the status
+// history tracking reads return
+func seedBags(tx *gorm.DB, runID string, owner user.User, bags, items int) ([]string, []string, error) {
+	var bagIDs, barcodes []string
+	office := fixtureOffice
+	now := time.Now()
+	for b := 0; b < bags; b++ {
+		bagID := fmt.Sprintf("%s-BAG-%s-%03d", fixturePrefix, runID, b)
+		bagIDs = append(bagIDs, bagID)
+
+		rows := make([]bookingModel.Booking, 0, items)
+		for i := 0; i < items; i++ {
+			barcode := fmt.Sprintf("%s%s%03d%04d", fixturePrefix, runID, b, i)
+			bag := bagID
+			rows = append(rows, bookingModel.Booking{
+				UserID:             owner.ID,
+				AppOrOrderID:       barcode,
+				CurrentBagID:       &bag,
+				Barcode:            &barcode,
+				Name:               "Load Test Applicant",
+				FatherName:         "Load Test Father",
+				MotherName:         "Load Test Mother",
+				Phone:              "+8801700000000",
+				Address:            "Load test address",
+				DeliveryBranchCode: &office,
+				Status:             bookingModel.BookingStatusBooked,
+				BookingType:        bookingModel.BookingTypeAgent,
+				BookingDate:        now,
+				CreatedBy:          "loadtest",
+			})
+			if b == 0 {
+				barcodes = append(barcodes, barcode)
+			}
+		}
+		if err := tx.CreateInBatches(rows, 500).Error; err != nil {
+			return nil, nil, err
+		}
+
+		events := make([]bookingModel.BookingStatusEvent, 0, 2*items)
+		for _, row := range rows {
+			events = append(events,
+				bookingModel.BookingStatusEvent{BookingID: row.ID, Status: bookingModel.BookingStatusPreBooked, CreatedBy: "loadtest"},
+				bookingModel.BookingStatusEvent{BookingID: row.ID, Status: bookingModel.BookingStatusBooked, CreatedBy: "loadtest"},
+			)
+		}
+		if err := tx.CreateInBatches(events, 1000).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return bagIDs, barcodes, nil
+}
+
+func clean(args []string) {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	fs.Parse(args)
+
+	db, err := database.InitDB()
+	if err != nil {
+		logger.Error("Failed to connect to the database", err)
+		os.Exit(1)
+	}
+
+	// Seeded bagged bookings, plus the bookings the create scenario made from seeded slips
+	slipAppIDs := db.Model(&slip_parser.SlipParserRequest{}).Select("app_or_order_id").Where("request_id LIKE ?", fixturePrefix+"%")
+	var bookingIDs []uint
+	if err := db.Model(&bookingModel.Booking{}).
+		Where("current_bag_id LIKE ? OR app_or_order_id IN (?)", fixturePrefix+"-BAG-%", slipAppIDs).
+		Pluck("id", &bookingIDs).Error; err != nil {
+		logger.Error("Failed to find load-test bookings", err)
+		os.Exit(1)
+	}
+
+	steps := []struct {
+		name       string
+		perBooking bool
+		delete     func(tx *gorm.DB) *gorm.DB
+	}{
+		{"booking status events", true, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("booking_id IN ?", bookingIDs).Delete(&bookingModel.BookingStatusEvent{})
+		}},
+		{"booking events", true, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("booking_id IN ?", bookingIDs).Delete(&bookingModel.BookingEvent{})
+		}},
+		{"shipments", true, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("kind = ? AND source_id IN ?", shipmentModel.KindBooking, bookingIDs).Delete(&shipmentModel.Shipment{})
+		}},
+		{"bookings", true, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("id IN ?", bookingIDs).Delete(&bookingModel.Booking{})
+		}},
+		{"slip parses", false, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("request_id LIKE ?", fixturePrefix+"%").Delete(&slip_parser.SlipParserRequest{})
+		}},
+		{"transport line loads", false, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("bag_id LIKE ?", fixturePrefix+"-BAG-%").Delete(&bookingModel.TransportLineLoad{})
+		}},
+		{"bags", false, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("bag_id LIKE ?", fixturePrefix+"-BAG-%").Delete(&bookingModel.Bag{})
+		}},
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, step := range steps {
+			if step.perBooking && len(bookingIDs) == 0 {
+				continue
+			}
+			result := step.delete(tx)
+			if result.Error != nil {
+				return fmt.Errorf("failed to delete load-test %s: %w", step.name, result.Error)
+			}
+			logger.Info(fmt.Sprintf("Deleted %d load-test %s", result.RowsAffected, step.name))
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to remove load-test fixtures", err)
+		os.Exit(1)
+	}
+	logger.Success("Load-test fixtures removed")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"passport-booking/logger"
+	"sync/atomic"
+	"time"
+)
+
+// dmsStub answers the DMS endpoints the delivery path calls, after an optional fixed
+// latency, so a load test measures this service and not DMS. Point DMS_BASE_URL at it.
+func dmsStub(args []string) {
+	fs := flag.NewFlagSet("dms-stub", flag.ExitOnError)
+	addr := fs.String("addr", ":9099", "listen address")
+	latency := fs.Duration("latency", 50*time.Millisecond, "delay added to every answer, roughly DMS's own")
+	fs.Parse(args)
+
+	var barcodes atomic.Int64
+	reply := func(w http.ResponseWriter, status int, body interface{}) {
+		time.Sleep(*latency)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		reply(w, http.StatusOK, map[string]interface{}{"status": "success", "message": "ok (load-test stub)"})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dms/api/get-barcode/", func(w http.ResponseWriter, r *http.Request) {
+		n := barcodes.Add(1)
+		reply(w, http.StatusCreated, map[string]interface{}{"barcode": fmt.Sprintf("LTS%012d", n)})
+	})
+	mux.HandleFunc("/dms/book/article/", ok)
+	mux.HandleFunc("/rms/bag/add-article/", ok)
+	mux.HandleFunc("/rms/receive-bag/", ok)
+	mux.HandleFunc("/rms/receive-bag-item/", ok)
+	mux.HandleFunc("/dms/deliver/article/", ok)
+	mux.HandleFunc("/dms/reroute/article/", ok)
+
+	logger.Info(fmt.Sprintf("DMS stub listening on %s with %s latency", *addr, *latency))
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		logger.Error("DMS stub stopped", err)
+		os.Exit(1)
+	}
+}
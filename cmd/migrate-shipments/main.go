@@ -0,0 +1,42 @@
+// Command migrate-shipments backfills the shipments table from bookings and parcel
+// bookings. It is idempotent: run it once after deploying the shipments table, and
+// again after bulk updates that bypass the sync callbacks.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"passport-booking/database"
+	"passport-booking/logger"
+	"passport-booking/services/shipment"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report how many rows would be synced without writing")
+	batchSize := flag.Int("batch", 500, "rows fetched per query")
+	flag.Parse()
+
+	db, err := database.InitDB()
+	if err != nil {
+		logger.Error("Failed to connect to the database", err)
+		os.Exit(1)
+	}
+
+	logger.Info(fmt.Sprintf("Backfilling shipments (dry run: %v)", *dryRun))
+
+	failed := false
+	for _, table := range shipment.Tables() {
+		synced, err := shipment.Backfill(db, table, *batchSize, *dryRun)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to backfill shipments from %s", table), err)
+			failed = true
+			continue
+		}
+		logger.Success(fmt.Sprintf("%s: %d rows synced to shipments", table, synced))
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
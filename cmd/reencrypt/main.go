@@ -0,0 +1,111 @@
+// Command reencrypt re-seals encrypted columns with the keyring's active key.
+// Run it after rotating ENCRYPTION_ACTIVE_KEY_ID (or the active key in Vault);
+// old keys must stay in the keyring until it reports nothing left to rotate.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"passport-booking/database"
+	"passport-booking/logger"
+	"passport-booking/utils"
+
+	"gorm.io/gorm"
+)
+
+// encryptedColumns lists every column written through utils.EncryptData
+var encryptedColumns = map[string][]string{
+	"bookings":       {"delivery_phone_applied_otp_encrypted", "delivery_phone_confirmed_otp_encrypted"},
+	"booking_events": {"delivery_phone_applied_otp_encrypted", "delivery_phone_confirmed_otp_encrypted"},
+}
+
+type encryptedRow struct {
+	ID    uint
+	Value string
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report how many values would be re-encrypted without writing")
+	batchSize := flag.Int("batch", 500, "rows fetched per query")
+	flag.Parse()
+
+	db, err := database.InitDB()
+	if err != nil {
+		logger.Error("Failed to connect to the database", err)
+		os.Exit(1)
+	}
+
+	activeID, _, err := utils.DefaultKeyring().ActiveKey()
+	if err != nil {
+		logger.Error("Failed to load the active encryption key", err)
+		os.Exit(1)
+	}
+	logger.Info(fmt.Sprintf("Re-encrypting with active key %q (dry run: %v)", activeID, *dryRun))
+
+	failed := false
+	for table, columns := range encryptedColumns {
+		for _, column := range columns {
+			rotated, err := reencryptColumn(db, table, column, *batchSize, *dryRun)
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to re-encrypt %s.%s", table, column), err)
+				failed = true
+				continue
+			}
+			logger.Success(fmt.Sprintf("%s.%s: %d values re-encrypted", table, column, rotated))
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func reencryptColumn(db *gorm.DB, table, column string, batchSize int, dryRun bool) (int, error) {
+	rotated := 0
+	var lastID uint
+
+	for {
+		var rows []encryptedRow
+		err := db.Table(table).
+			Select(fmt.Sprintf("id, %s AS value", column)).
+			Where(fmt.Sprintf("id > ? AND %s IS NOT NULL AND %s <> ''", column, column), lastID).
+			Order("id").
+			Limit(batchSize).
+			Scan(&rows).Error
+		if err != nil {
+			return rotated, err
+		}
+		if len(rows) == 0 {
+			return rotated, nil
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+
+			stale, err := utils.NeedsReencrypt(row.Value)
+			if err != nil {
+				return rotated, err
+			}
+			if !stale {
+				continue
+			}
+
+			rotated++
+			if dryRun {
+				continue
+			}
+
+			sealed, err := utils.ReencryptData(row.Value)
+			if err != nil {
+				return rotated, fmt.Errorf("row %d: %w", row.ID, err)
+			}
+			// Only overwrite if nobody changed the value meanwhile
+			if err := db.Table(table).
+				Where(fmt.Sprintf("id = ? AND %s = ?", column), row.ID, row.Value).
+				Update(column, sealed).Error; err != nil {
+				return rotated, fmt.Errorf("row %d: %w", row.ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"passport-booking/logger"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Profile is the environment the process runs in
+type Profile string
+
+const (
+	ProfileDev     Profile = "dev"
+	ProfileStaging Profile = "staging"
+	ProfileProd    Profile = "prod"
+)
+
+// ProfileSettings are the debug toggles of a profile
+type ProfileSettings struct {
+	Profile Profile `json:"profile"`
+	// DebugPrints allows developer output such as an OTP whose SMS failed
+	DebugPrints bool `json:"debug_prints"`
+	// VerboseSQL logs every statement, with parameters masked
+	VerboseSQL bool `json:"verbose_sql"`
+	// MockIntegrations allows mock clients such as PASSPORT_SYSTEM_MOCK
+	MockIntegrations bool `json:"mock_integrations"`
+	// ShowPII leaves phone numbers unmasked in the log
+	ShowPII bool `json:"show_pii"`
+}
+
+var (
+	profileSettings ProfileSettings
+	profileOnce     sync.Once
+)
+
+// parseProfile reads APP_PROFILE, falling back to APP_ENV. Anything unknown, and no
+// setting at all, is prod so a misconfigured deployment fails safe.
+func parseProfile() Profile {
+	name := os.Getenv("APP_PROFILE")
+	if name == "" {
+		name = os.Getenv("APP_ENV")
+	}
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "dev", "development", "local":
+		return ProfileDev
+	case "staging", "stage", "test":
+		return ProfileStaging
+	}
+	return ProfileProd
+}
+
+// CurrentProfile returns the settings of the profile. Dev enables every toggle,
+// staging only mock integrations and prod none. DEBUG_PRINTS, DB_VERBOSE_SQL,
+// MOCK_INTEGRATIONS and LOG_SHOW_PII override them outside prod; prod ignores them so
+// no secret or personal data reaches stdout there.
+func CurrentProfile() ProfileSettings {
+	profileOnce.Do(func() {
+		p := parseProfile()
+		s := ProfileSettings{Profile: p}
+		switch p {
+		case ProfileDev:
+			s.DebugPrints, s.VerboseSQL, s.MockIntegrations, s.ShowPII = true, true, true, true
+		case ProfileStaging:
+			s.MockIntegrations = true
+		}
+		if p != ProfileProd {
+			s.DebugPrints = envBool("DEBUG_PRINTS", s.DebugPrints)
+			s.VerboseSQL = envBool("DB_VERBOSE_SQL", s.VerboseSQL)
+			s.MockIntegrations = envBool("MOCK_INTEGRATIONS", s.MockIntegrations)
+			s.ShowPII = envBool("LOG_SHOW_PII", s.ShowPII)
+		}
+		profileSettings = s
+	})
+	return profileSettings
+}
+
+func envBool(key string, fallback bool) bool {
+	if b, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return b
+	}
+	return fallback
+}
+
+// ApplyProfile applies the profile to the logger and reports it. Call it once the
+// environment is loaded.
+func ApplyProfile() ProfileSettings {
+	s := CurrentProfile()
+	logger.ShowPII(s.ShowPII)
+	logger.Info(fmt.Sprintf("Running with the %s profile (debug prints: %v, verbose SQL: %v, mock integrations: %v, PII in logs: %v)",
+		s.Profile, s.DebugPrints, s.VerboseSQL, s.MockIntegrations, s.ShowPII))
+	return s
+}
+
+// Debugf prints developer output to stdout when the profile allows debug prints
+func Debugf(format string, args ...interface{}) {
+	if CurrentProfile().DebugPrints {
+		fmt.Printf(format+"\n", args...)
+	}
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"passport-booking/logger"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretsProvider looks up credentials by name (e.g. DB_PASSWORD, SMS_AUTH_TOKEN).
+// ok is false when the provider has no value for the key.
+type SecretsProvider interface {
+	Get(key string) (value string, ok bool, err error)
+}
+
+// EnvSecretsProvider reads secrets from environment variables
+type EnvSecretsProvider struct{}
+
+// Get implements SecretsProvider
+func (EnvSecretsProvider) Get(key string) (string, bool, error) {
+	value, ok := os.LookupEnv(key)
+	return value, ok && value != "", nil
+}
+
+// FileSecretsProvider reads each secret from a file named after the key,
+// as mounted by Docker/Kubernetes secrets (e.g. /run/secrets/DB_PASSWORD)
+type FileSecretsProvider struct {
+	Dir string
+}
+
+// Get implements SecretsProvider
+func (p FileSecretsProvider) Get(key string) (string, bool, error) {
+	data, err := os.ReadFile(filepath.Join(p.Dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	value := strings.TrimSpace(string(data))
+	return value, value != "", nil
+}
+
+// VaultSecretsProvider reads secrets from the fields of a Vault KV v2 secret
+type VaultSecretsProvider struct {
+	Addr  string
+	Token string
+	Path  string
+}
+
+// Get implements SecretsProvider
+func (p VaultSecretsProvider) Get(key string) (string, bool, error) {
+	data, err := ReadVaultKV(p.Addr, p.Token, p.Path)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := data[key].(string)
+	return value, ok && value != "", nil
+}
+
+var vaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// ReadVaultKV returns the data of a Vault KV v2 secret
+func ReadVaultKV(addr, token, path string) (map[string]interface{}, error) {
+	addr = strings.TrimRight(addr, "/")
+	path = strings.Trim(path, "/")
+	if addr == "" || token == "" || path == "" {
+		return nil, errors.New("vault address, token and path must be set")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, addr+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := vaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("failed to decode vault secret: %w", err)
+	}
+	return secret.Data.Data, nil
+}
+
+var (
+	provider     SecretsProvider
+	providerOnce sync.Once
+)
+
+// Provider returns the secrets provider selected by SECRETS_PROVIDER: "env" (default),
+// "file" (SECRETS_DIR, default /run/secrets) or "vault" (VAULT_ADDR, VAULT_TOKEN, VAULT_SECRETS_PATH)
+func Provider() SecretsProvider {
+	providerOnce.Do(func() {
+		switch strings.ToLower(os.Getenv("SECRETS_PROVIDER")) {
+		case "file":
+			dir := os.Getenv("SECRETS_DIR")
+			if dir == "" {
+				dir = "/run/secrets"
+			}
+			provider = FileSecretsProvider{Dir: dir}
+		case "vault":
+			provider = VaultSecretsProvider{
+				Addr:  os.Getenv("VAULT_ADDR"),
+				Token: os.Getenv("VAULT_TOKEN"),
+				Path:  os.Getenv("VAULT_SECRETS_PATH"),
+			}
+		default:
+			provider = EnvSecretsProvider{}
+		}
+	})
+	return provider
+}
+
+// Secret returns the named secret from the configured provider, falling back to the
+// environment variable of the same name when the provider has no value
+func Secret(key string) string {
+	value, ok, err := Provider().Get(key)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to read secret %s, falling back to environment", key), err)
+	}
+	if ok {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+// WatchSecrets polls the given keys and calls onChange whenever a value differs from
+// the last one seen. It returns a function that stops the watcher.
+func WatchSecrets(interval time.Duration, keys []string, onChange func(key, value string)) func() {
+	last := make(map[string]string, len(keys))
+	for _, key := range keys {
+		last[key] = Secret(key)
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for _, key := range keys {
+					value := Secret(key)
+					if value != last[key] {
+						last[key] = value
+						onChange(key, value)
+					}
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
@@ -14,6 +14,9 @@ const (
 	PermPostmanFull        = "passport-booking.postman.full-permit"
 	PermCustomerFull       = "passport-booking.customer.full-permit"
 
+	// Read-only permissions, honoured on safe (GET/HEAD) requests only
+	PermViewerReadOnly = "passport-booking.viewer.read-only"
+
 	// Special permissions
 	PermAny = "any"
 )
@@ -25,4 +28,37 @@ var (
 		PermPassportDPMGFull,
 		PermPostOfficeFull,
 	}
+
+	// KnownPermissions are the permissions this service checks, so admins can spot
+	// stale or mistyped grants
+	KnownPermissions = []string{
+		PermSuperAdminFull,
+		PermEkdakDPMGFull,
+		PermPassportDPMGFull,
+		PermPostOfficeFull,
+		PermOrgSupervisorFull,
+		PermOperatorFull,
+		PermParcelOperatorFull,
+		PermAgentHasFull,
+		PermPostmanFull,
+		PermCustomerFull,
+		PermViewerReadOnly,
+	}
+
+	// ReadOnlyPermissions never grant access to mutating requests
+	ReadOnlyPermissions = []string{
+		PermViewerReadOnly,
+	}
+
+	// PIIPermissions need applicants' full phone numbers and addresses to do their
+	// work; everyone else sees them masked in list endpoints
+	PIIPermissions = []string{
+		PermSuperAdminFull,
+		PermPostOfficeFull,
+		PermOperatorFull,
+		PermParcelOperatorFull,
+		PermAgentHasFull,
+		PermPostmanFull,
+		PermCustomerFull,
+	}
 )
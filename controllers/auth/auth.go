@@ -2,12 +2,16 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"passport-booking/config"
 	"passport-booking/database"
 	httpServices "passport-booking/httpServices/sso"
 	"passport-booking/logger"
+	"passport-booking/middleware"
 	"passport-booking/models/user"
+	"passport-booking/services/impersonation"
 	"passport-booking/types"
 	"passport-booking/utils"
 	"strings"
@@ -117,6 +121,11 @@ func (h *AuthController) Register(c *fiber.Ctx) error {
 			Avatar:        "",                 // Set to empty string if null in response
 			Nonce:         0,                  // Default value
 			Permissions:   user.StringSlice{}, // Empty permissions array
+			// Locked out until a postmaster or admin approves the registration
+			ApprovalStatus: user.ApprovalStatusPending,
+		}
+		if branchCode := strings.TrimSpace(req.BranchCode); branchCode != "" {
+			newUser.RequestedBranchCode = &branchCode
 		}
 
 		// Handle nullable fields
@@ -218,7 +227,6 @@ func (h *AuthController) Login(c *fiber.Ctx) error {
 
 	// Check if user exists in local database, create if not exists
 	if loginResponse.Status == "success" && loginResponse.User.UUID != "" {
-		fmt.Println("Login Response Data: ")
 		var existingUser user.User
 		result := database.DB.Where("uuid = ?", loginResponse.User.UUID).First(&existingUser)
 
@@ -255,9 +263,26 @@ func (h *AuthController) Login(c *fiber.Ctx) error {
 			} else {
 				logger.Success("User created in local database successfully. UUID: " + newUser.Uuid)
 			}
+		} else if !existingUser.IsActive {
+			logger.Warning("Login refused for deactivated user. UUID: " + existingUser.Uuid)
+			return c.Status(fiber.StatusForbidden).JSON(types.ApiResponse{
+				Message: "Account is deactivated",
+				Status:  fiber.StatusForbidden,
+			})
+		} else if existingUser.ApprovalStatus != user.ApprovalStatusApproved {
+			logger.Warning("Login refused for unapproved user. UUID: " + existingUser.Uuid)
+			message := "Account is awaiting approval"
+			if existingUser.ApprovalStatus == user.ApprovalStatusRejected {
+				message = "Account registration was rejected"
+			}
+			return c.Status(fiber.StatusForbidden).JSON(types.ApiResponse{
+				Message: message,
+				Status:  fiber.StatusForbidden,
+				Data:    fiber.Map{"approval_status": existingUser.ApprovalStatus},
+			})
 		} else {
 			// User exists, optionally update their information
-			fmt.Printf("User already exists in local database. UUID: %s\n", existingUser.Uuid)
+			config.Debugf("User already exists in local database. UUID: %s", existingUser.Uuid)
 		}
 	}
 	// Set HTTP-only secure cookies for access and refresh tokens
@@ -348,3 +373,86 @@ func (h *AuthController) LogOut(c *fiber.Ctx) error {
 	logger.Success("Logout successful")
 	return c.Status(fiber.StatusOK).JSON(response)
 }
+
+// Impersonate issues a short-lived token that acts as an operator or postman for troubleshooting.
+// Every request made with it is tagged with the admin's UUID in API logs and booking events.
+func (h *AuthController) Impersonate(c *fiber.Ctx) error {
+	var req types.ImpersonateRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Error parsing request body", err)
+		return c.Status(fiber.StatusBadRequest).JSON(types.ApiResponse{
+			Message: "Invalid request payload",
+			Status:  fiber.StatusBadRequest,
+		})
+	}
+
+	if validationErr := req.Validate(); validationErr != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ApiResponse{
+			Message: validationErr,
+			Status:  fiber.StatusBadRequest,
+		})
+	}
+
+	admin, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+		})
+	}
+
+	// An impersonation token must not be used to start another impersonation
+	if admin.IsImpersonated() {
+		return c.Status(fiber.StatusForbidden).JSON(types.ApiResponse{
+			Message: "Impersonation tokens cannot impersonate other users",
+			Status:  fiber.StatusForbidden,
+		})
+	}
+
+	target, err := utils.GetUserByUUID(req.TargetUUID)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		msg := "Database error"
+		if err.Error() == "user not found" {
+			status = fiber.StatusNotFound
+			msg = "Target user not found"
+		}
+		return c.Status(status).JSON(types.ApiResponse{
+			Message: msg,
+			Status:  status,
+		})
+	}
+
+	token, session, err := impersonation.Issue(h.db, admin.User, target, req.Reason, time.Duration(req.TTLMinutes)*time.Minute)
+	if err != nil {
+		logger.Error("Failed to issue impersonation token", err)
+		status := fiber.StatusInternalServerError
+		msg := "Failed to issue impersonation token"
+		if errors.Is(err, impersonation.ErrNotImpersonable) || errors.Is(err, impersonation.ErrSelfImpersonate) {
+			status = fiber.StatusBadRequest
+			msg = err.Error()
+		}
+		return c.Status(status).JSON(types.ApiResponse{
+			Message: msg,
+			Status:  status,
+		})
+	}
+
+	logger.Warning(fmt.Sprintf("Admin %s started impersonating %s until %s: %s",
+		admin.UUID, target.Uuid, session.ExpiresAt.Format(time.RFC3339), req.Reason))
+
+	response := types.ApiResponse{
+		Message: "Impersonation token issued",
+		Status:  fiber.StatusOK,
+		Data: map[string]interface{}{
+			"access":      token,
+			"session_id":  session.TokenID,
+			"target_uuid": target.Uuid,
+			"permissions": session.Permissions,
+			"expires_at":  session.ExpiresAt,
+		},
+	}
+	result := c.Status(fiber.StatusOK).JSON(response)
+	h.loggerInstance.Log(utils.CreateSanitizedLogEntry(c))
+	return result
+}
@@ -3,21 +3,34 @@ package bag
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 	"io"
 	"net/http"
 	"os"
+	"passport-booking/config"
+	"passport-booking/constants"
 	"passport-booking/database"
+	"passport-booking/httpServices/dms"
 	"passport-booking/logger"
+	"passport-booking/middleware"
 	bookingModel "passport-booking/models/booking"
 	"passport-booking/models/user"
+	"passport-booking/services/bag_limit"
+	"passport-booking/services/bag_lock"
+	barcodeService "passport-booking/services/barcode"
 	"passport-booking/services/booking_event"
+	"passport-booking/services/branch_schedule"
+	devicePush "passport-booking/services/device_push"
+	"passport-booking/services/notification"
+	"passport-booking/services/transport_line"
 	"passport-booking/types"
 	bagType "passport-booking/types/bag"
 	bookingTypes "passport-booking/types/booking"
 	"passport-booking/utils"
+	"strings"
 	"time"
 )
 
@@ -93,7 +106,7 @@ func GetBranchList(c *fiber.Ctx) error {
 	}
 	req.Header.Set("Authorization", authHeader)
 
-	client := &http.Client{}
+	client := dms.NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		errorResponse := fiber.Map{"error": "Failed to call external API"}
@@ -134,7 +147,7 @@ func GetOperatorList(c *fiber.Ctx) error {
 
 	db := database.DB
 	if db == nil {
-		fmt.Println("DEBUG: db not found in context")
+		config.Debugf("DEBUG: db not found in context")
 		errorResponse := types.ApiResponse{
 			Message: "Database connection not found in context",
 			Status:  fiber.StatusInternalServerError,
@@ -258,7 +271,7 @@ func CreateBranchMapping(c *fiber.Ctx) error {
 	req.Header.Set("Authorization", authHeader)
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
+	client := dms.NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		errorResponse := types.ApiResponse{
@@ -442,7 +455,7 @@ func CreateBag(c *fiber.Ctx) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", authHeader)
 
-	client := &http.Client{}
+	client := dms.NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		errorResponse := types.ApiResponse{
@@ -476,6 +489,11 @@ func CreateBag(c *fiber.Ctx) error {
 				Status:  resp.StatusCode,
 				Data:    responseData,
 			}
+			if reqBody.BagID != "" {
+				if err := bag_limit.Open(database.DB, reqBody.BagID, reqBody.BagType, reqBody.DestOfficeCode, middleware.CurrentUserID(c)); err != nil {
+					logger.Error(fmt.Sprintf("Failed to record bag %s", reqBody.BagID), err)
+				}
+			}
 			c.Status(resp.StatusCode).JSON(successResponse)
 			// Serialize the response properly for logging
 			responseBytes, _ := json.Marshal(successResponse)
@@ -555,7 +573,34 @@ func AddItemToBag(c *fiber.Ctx) error {
 		return nil
 	}
 
-	db := database.DB
+	// Adds to one bag run one at a time so DMS and the local records see them in the same order
+	err := bag_lock.With(database.DB, reqBody.BagID, func() error {
+		return addItemToBag(c, reqBody, requestBody, authHeader)
+	})
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		message := "Failed to lock bag"
+		if errors.Is(err, bag_lock.ErrBusy) {
+			status = fiber.StatusConflict
+			message = fmt.Sprintf("Bag %s is busy with another item, please retry", reqBody.BagID)
+		} else {
+			logger.Error(fmt.Sprintf("Failed to lock bag %s", reqBody.BagID), err)
+		}
+		errorResponse := types.ApiResponse{
+			Message: message,
+			Status:  status,
+		}
+		c.Status(status).JSON(errorResponse)
+		responseBytes, _ := json.Marshal(errorResponse)
+		logRequest(c, string(responseBytes), requestBody)
+	}
+	return nil
+}
+
+// addItemToBag books the item in DMS if needed and adds it to the bag. It runs under
+// the bag lock; the item is only attached to the bag locally once DMS has accepted it.
+func addItemToBag(c *fiber.Ctx, reqBody bagType.AddItemRequest, requestBody, authHeader string) error {
+	db := database.DB.WithContext(c.UserContext())
 	var booking bookingModel.Booking
 	err := db.Where("app_or_order_id = ?", reqBody.OrderId).First(&booking).Error
 	if err != nil {
@@ -570,39 +615,101 @@ func AddItemToBag(c *fiber.Ctx) error {
 		return nil
 	}
 
-	// Safely extract user ID from JWT claims
-	var userID string
-	if userClaims := c.Locals("user"); userClaims != nil {
-		if claims, ok := userClaims.(map[string]interface{}); ok {
-			if username, exists := claims["username"]; exists {
-				if usernameStr, ok := username.(string); ok {
-					// Query the database to get the actual user ID
-					var authUser user.User
-					if err := db.Where("username = ?", usernameStr).First(&authUser).Error; err == nil {
-						// Convert user ID to string
-						userID = fmt.Sprintf("%d", authUser.ID)
-					}
+	if booking.DeletedAt != nil {
+		errorResponse := types.ApiResponse{
+			Message: fmt.Sprintf("Booking for order ID %s was deleted; restore it before adding it to a bag", reqBody.OrderId),
+			Status:  fiber.StatusConflict,
+		}
+		c.Status(fiber.StatusConflict).JSON(errorResponse)
+		responseBytes, _ := json.Marshal(errorResponse)
+		logRequest(c, string(responseBytes), requestBody)
+		return nil
+	}
+
+	// Only printed and dispatched passports may take a bag slot
+	if status, message := checkPassportDispatched(booking.AppOrOrderID); status != 0 {
+		errorResponse := types.ApiResponse{
+			Message: message,
+			Status:  status,
+		}
+		c.Status(status).JSON(errorResponse)
+		responseBytes, _ := json.Marshal(errorResponse)
+		logRequest(c, string(responseBytes), requestBody)
+		return nil
+	}
+
+	// Count the item against the bag's limits before touching DMS, giving it back if
+	// the add does not go through
+	weightGrams := reqBody.WeightGrams
+	if weightGrams <= 0 {
+		weightGrams = bag_limit.DefaultItemWeightGrams
+	}
+	// Re-adding an item the bag already holds does not take another slot
+	alreadyInBag := booking.CurrentBagID != nil && *booking.CurrentBagID == reqBody.BagID
+	if !alreadyInBag {
+		if err := bag_limit.Reserve(db, reqBody.BagID, reqBody.BagType, weightGrams); err != nil {
+			status := fiber.StatusConflict
+			errorResponse := types.ApiResponse{
+				Message: err.Error(),
+				Status:  status,
+			}
+			var limitErr *bag_limit.LimitError
+			switch {
+			case errors.As(err, &limitErr):
+				errorResponse.Data = fiber.Map{
+					"code":             "BAG_LIMIT_REACHED",
+					"item_count":       limitErr.Bag.ItemCount,
+					"max_items":        limitErr.MaxItems,
+					"weight_grams":     limitErr.Bag.WeightGrams,
+					"max_weight_grams": limitErr.MaxWeightGrams,
 				}
+			case errors.Is(err, bag_limit.ErrBagNotOpen):
+				errorResponse.Message = fmt.Sprintf("Bag %s is no longer open; open a new bag", reqBody.BagID)
+			default:
+				logger.Error("Failed to reserve bag capacity", err)
+				status = fiber.StatusInternalServerError
+				errorResponse.Status = status
+				errorResponse.Message = "Failed to check bag limits"
+			}
+			c.Status(status).JSON(errorResponse)
+			responseBytes, _ := json.Marshal(errorResponse)
+			logRequest(c, string(responseBytes), requestBody)
+			return nil
+		}
+	}
+	defer func() {
+		if code := c.Response().StatusCode(); !alreadyInBag && (code < 200 || code >= 300) {
+			if err := bag_limit.Release(database.DB, reqBody.BagID, weightGrams); err != nil {
+				logger.Error(fmt.Sprintf("Failed to release bag %s capacity", reqBody.BagID), err)
 			}
 		}
+	}()
+
+	// Attribute the item to the authenticated user, or to the system when unknown
+	userID := "system"
+	if id := middleware.CurrentUserID(c); id != 0 {
+		userID = fmt.Sprintf("%d", id)
 	}
 
-	// Fallback to empty string if userID couldn't be extracted
-	if userID == "" {
-		userID = "system" // or handle this case as appropriate for your application
+	// With DMS down the item is queued and synced later instead of failing the operator
+	if dms.Degraded() {
+		queued := queuedAddItem{Request: reqBody, WeightGrams: weightGrams, Reserved: !alreadyInBag}
+		return queueAddItem(c, db, &booking, queued, requestBody, userID)
 	}
 
 	if booking.Status == bookingModel.BookingStatusBooked {
-		// Already booked, create event for adding item to bag
-		if err := booking_event.SnapshotBookingToEvent(db, &booking, "item_added_to_bag", userID); err != nil {
-			// Log the error but don't fail the operation
-			fmt.Printf("Failed to create booking event: %v\n", err)
-		}
 		// Already booked, just add article
-		return callAddArticleAPI(c, authHeader, reqBody, strPtrToStr(booking.Barcode), os.Getenv("DMS_BASE_URL"), requestBody)
+		return addArticleAndAttach(c, db, &booking, authHeader, reqBody, strPtrToStr(booking.Barcode), requestBody, userID, "item_added_to_bag")
 	}
 
-	barcode, err := getBarcodeFromAPI(authHeader)
+	// Prefer a barcode fetched ahead of time so intake does not wait on DMS
+	barcode, pooled, err := barcodeService.TakePooled(db, reqBody.BranchCode)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to take pooled barcode for branch %s", reqBody.BranchCode), err)
+	}
+	if !pooled {
+		barcode, err = getBarcodeFromAPI(authHeader)
+	}
 	if err != nil {
 		errorResponse := types.ApiResponse{
 			Message: fmt.Sprintf("Failed to get barcode: %v", err),
@@ -648,24 +755,48 @@ func AddItemToBag(c *fiber.Ctx) error {
 		return nil
 	}
 
-	// Update booking status to booked and save barcode
+	if message, err := confirmBooking(db, &booking, barcode, reqBody.BranchCode, userID); err != nil {
+		logger.Error(fmt.Sprintf("Failed to confirm booking %d", booking.ID), err)
+		errorResponse := types.ApiResponse{
+			Message: message,
+			Status:  fiber.StatusInternalServerError,
+		}
+		c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
+		logRequest(c, "", requestBody)
+		return nil
+	}
+
+	return addArticleAndAttach(c, db, &booking, authHeader, reqBody, barcode, requestBody, userID, "")
+}
+
+// confirmBooking marks the booking booked with the DMS barcode, records the events and
+// notifies the applicant. On failure it returns the message to show the operator.
+func confirmBooking(db *gorm.DB, booking *bookingModel.Booking, barcode, branchCode, userID string) (string, error) {
+	if err := booking.Status.CheckTransition(bookingModel.BookingStatusBooked); err != nil {
+		return fmt.Sprintf("Booking is %s and cannot be booked", booking.Status), err
+	}
 	booking.Status = bookingModel.BookingStatusBooked
 	booking.Barcode = &barcode
-	booking.CurrentBagID = &reqBody.BagID
 	booking.BookingDate = time.Now()
 	booking.UpdatedBy = userID
 
+	// Items bagged after the branch cutoff leave on the next working day
+	if branchCode != "" {
+		schedule, err := branch_schedule.Find(db, branchCode)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to load schedule for branch %s", branchCode), err)
+		} else if schedule != nil {
+			dispatchDate := branch_schedule.NextDispatchDate(schedule, booking.BookingDate)
+			booking.ExpectedDispatchDate = &dispatchDate
+			booking.BaggedAfterCutoff = branch_schedule.AfterCutoff(schedule, booking.BookingDate)
+		}
+	}
+
 	// Use transaction to ensure both booking update and event creation succeed together
 	tx := db.Begin()
-	if err := tx.Save(&booking).Error; err != nil {
+	if err := tx.Save(booking).Error; err != nil {
 		tx.Rollback()
-		errorResponse := types.ApiResponse{
-			Message: "Failed to update booking status",
-			Status:  fiber.StatusInternalServerError,
-		}
-		c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
-		logRequest(c, "", requestBody)
-		return nil
+		return "Failed to update booking status", err
 	}
 
 	// Create booking status event for status change to booked
@@ -674,42 +805,60 @@ func AddItemToBag(c *fiber.Ctx) error {
 		Status:    booking.Status,
 		CreatedBy: userID,
 	}
-
 	if err := tx.Create(&bookingStatusEvent).Error; err != nil {
 		tx.Rollback()
-		errorResponse := types.ApiResponse{
-			Message: "Failed to create booking status event",
-			Status:  fiber.StatusInternalServerError,
-		}
-		c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
-		logRequest(c, "", requestBody)
-		return nil
+		return "Failed to create booking status event", err
 	}
 
 	// Create booking event for status change to booked and item added to bag
-	if err := booking_event.SnapshotBookingToEvent(tx, &booking, "booking_confirmed_and_item_added_to_bag", userID); err != nil {
+	if err := booking_event.SnapshotBookingToEvent(tx, booking, "booking_confirmed_and_item_added_to_bag", userID); err != nil {
 		tx.Rollback()
-		errorResponse := types.ApiResponse{
-			Message: "Failed to create booking event",
-			Status:  fiber.StatusInternalServerError,
-		}
-		c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
-		logRequest(c, "", requestBody)
-		return nil
+		return "Failed to create booking event", err
 	}
 
-	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
-		errorResponse := types.ApiResponse{
-			Message: "Failed to commit booking changes",
-			Status:  fiber.StatusInternalServerError,
+		return "Failed to commit booking changes", err
+	}
+
+	notification.Dispatch(notification.Notification{
+		Kind:      notification.KindBookingConfirmation,
+		UserID:    booking.UserID,
+		BookingID: booking.ID,
+		Phone:     booking.Phone,
+		Data:      notification.ForBooking(booking),
+	})
+	return "", nil
+}
+
+// addArticleAndAttach adds the booked item to the bag in DMS and only then records the
+// bag on the booking. When DMS rejects the add, the booking keeps its previous bag and a
+// failure event is left for the operator to retry from.
+func addArticleAndAttach(c *fiber.Ctx, db *gorm.DB, booking *bookingModel.Booking, authHeader string, reqBody bagType.AddItemRequest, barcode, requestBody, userID, successEvent string) error {
+	if err := callAddArticleAPI(c, authHeader, reqBody, barcode, os.Getenv("DMS_BASE_URL"), requestBody); err != nil {
+		return err
+	}
+
+	if code := c.Response().StatusCode(); code < 200 || code >= 300 {
+		if err := booking_event.SnapshotBookingToEvent(db, booking, "item_add_to_bag_failed", userID); err != nil {
+			logger.Error("Failed to create booking event", err)
 		}
-		c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
-		logRequest(c, "", requestBody)
 		return nil
 	}
 
-	return callAddArticleAPI(c, authHeader, reqBody, barcode, os.Getenv("DMS_BASE_URL"), requestBody)
+	booking.CurrentBagID = &reqBody.BagID
+	if err := db.Model(booking).Updates(map[string]interface{}{
+		"current_bag_id": reqBody.BagID,
+		"updated_by":     userID,
+	}).Error; err != nil {
+		logger.Error(fmt.Sprintf("Failed to attach booking %d to bag %s", booking.ID, reqBody.BagID), err)
+		return nil
+	}
+	if successEvent != "" {
+		if err := booking_event.SnapshotBookingToEvent(db, booking, successEvent, userID); err != nil {
+			logger.Error("Failed to create booking event", err)
+		}
+	}
+	return nil
 }
 
 // Helper function to call add-article API
@@ -724,11 +873,11 @@ func callAddArticleAPI(c *fiber.Ctx, authHeader string, reqBody bagType.AddItemR
 		return nil
 	}
 	//fmt.Println(barcode)
-	payload := map[string]interface{}{
-		"bag_type": reqBody.BagType,
-		"bag_id":   reqBody.BagID,
-		"index":    reqBody.Index,
-		"item_id":  barcode,
+	payload := dms.AddArticleRequest{
+		BagType: reqBody.BagType,
+		BagID:   reqBody.BagID,
+		Index:   reqBody.Index,
+		ItemID:  barcode,
 	}
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -753,8 +902,9 @@ func callAddArticleAPI(c *fiber.Ctx, authHeader string, reqBody bagType.AddItemR
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", authHeader)
-	client := &http.Client{}
+	client := dms.NewHTTPClient(0)
 	resp, err := client.Do(req)
+	dms.Record(err, statusOf(resp))
 	if err != nil {
 		errorResponse := types.ApiResponse{
 			Message: "Failed to call external API",
@@ -851,8 +1001,9 @@ func getBarcodeFromAPI(authHeader string) (string, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", authHeader)
 
-	client := &http.Client{}
+	client := dms.NewHTTPClient(0)
 	resp, err := client.Do(req)
+	dms.Record(err, statusOf(resp))
 	if err != nil {
 		return "", fmt.Errorf("failed to call barcode API: %v", err)
 	}
@@ -892,6 +1043,7 @@ func BookingDms(authHeader, barcode, orderID string) ([]byte, int, error) {
 	if err := db.
 		Preload("User").
 		Preload("DeliveryAddress").
+		Scopes(database.NotDeletedBookings).
 		Where("app_or_order_id = ?", orderID).
 		Where("status = ?", bookingModel.BookingStatusPreBooked).
 		First(&booking).Error; err != nil {
@@ -986,8 +1138,9 @@ func BookingDms(authHeader, barcode, orderID string) ([]byte, int, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", authHeader)
 
-	client := &http.Client{}
+	client := dms.NewHTTPClient(0)
 	resp, err := client.Do(req)
+	dms.Record(err, statusOf(resp))
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to call booking API: %v", err)
 	}
@@ -1001,6 +1154,14 @@ func BookingDms(authHeader, barcode, orderID string) ([]byte, int, error) {
 	return body, resp.StatusCode, nil
 }
 
+// statusOf is the status code of a DMS response, 0 when the call did not get one
+func statusOf(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
 func strPtrToStr(s *string) string {
 	if s != nil {
 		return *s
@@ -1041,6 +1202,50 @@ func CloseBag(c *fiber.Ctx) error {
 	requestBodyBytes, _ := json.Marshal(reqBody)
 	requestBody := string(requestBodyBytes)
 
+	// Closing after the branch cutoff misses today's dispatch and needs an explicit override
+	if reqBody.BranchCode != "" {
+		schedule, err := branch_schedule.Find(database.DB.WithContext(c.UserContext()), reqBody.BranchCode)
+		if err != nil {
+			errorResponse := types.ApiResponse{
+				Message: "Failed to load branch schedule",
+				Status:  fiber.StatusInternalServerError,
+			}
+			c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
+			logRequest(c, "", requestBody)
+			return nil
+		}
+
+		now := time.Now()
+		if schedule != nil && branch_schedule.AfterCutoff(schedule, now) {
+			if !reqBody.Override {
+				errorResponse := types.ApiResponse{
+					Message: fmt.Sprintf("Branch cutoff %s has passed; items in this bag will be dispatched on the next working day. Resend with override to close it anyway", schedule.CutoffAt),
+					Status:  fiber.StatusConflict,
+					Data: fiber.Map{
+						"code":               "AFTER_CUTOFF",
+						"cutoff_at":          schedule.CutoffAt,
+						"next_dispatch_date": branch_schedule.NextDispatchDate(schedule, now).Format("2006-01-02"),
+					},
+				}
+				c.Status(fiber.StatusConflict).JSON(errorResponse)
+				responseBytes, _ := json.Marshal(errorResponse)
+				logRequest(c, string(responseBytes), requestBody)
+				return nil
+			}
+			if strings.TrimSpace(reqBody.OverrideReason) == "" {
+				errorResponse := types.ApiResponse{
+					Message: "override_reason is required to close a bag after the branch cutoff",
+					Status:  fiber.StatusBadRequest,
+				}
+				c.Status(fiber.StatusBadRequest).JSON(errorResponse)
+				logRequest(c, "", requestBody)
+				return nil
+			}
+			logger.Warning(fmt.Sprintf("Bag %s closed after cutoff %s at branch %s: %s",
+				reqBody.BagID, schedule.CutoffAt, reqBody.BranchCode, reqBody.OverrideReason))
+		}
+	}
+
 	// Prepare payload using data from request
 	payload := map[string]interface{}{
 		"bag_id": reqBody.BagID,
@@ -1083,7 +1288,7 @@ func CloseBag(c *fiber.Ctx) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", authHeader)
 
-	client := &http.Client{}
+	client := dms.NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		errorResponse := types.ApiResponse{
@@ -1117,6 +1322,9 @@ func CloseBag(c *fiber.Ctx) error {
 				Status:  resp.StatusCode,
 				Data:    responseData,
 			}
+			if err := bag_limit.SetStatus(database.DB, reqBody.BagID, bookingModel.BagStatusClosed); err != nil {
+				logger.Error(fmt.Sprintf("Failed to mark bag %s closed", reqBody.BagID), err)
+			}
 			c.Status(resp.StatusCode).JSON(successResponse)
 			// Serialize the response properly for logging
 			responseBytes, _ := json.Marshal(successResponse)
@@ -1188,11 +1396,47 @@ func (bc *BagController) ReceiveBag(c *fiber.Ctx) error {
 	}
 	requestBodyBytes, _ := json.Marshal(reqBody)
 	requestBody := string(requestBodyBytes)
-	payload := map[string]interface{}{
-		"bag_id":           reqBody.BagID,
-		"recv_instruction": reqBody.RecvInstruction,
-		"line_id":          reqBody.LineID,
-		"receive_items":    reqBody.ReceiveItems,
+
+	// Only registered lines that go to the bag's destination may carry it
+	offices := []string{}
+	if office := strings.TrimSpace(reqBody.DestOfficeCode); office != "" {
+		offices = append(offices, office)
+	} else if err := database.DB.Model(&bookingModel.Booking{}).
+		Where("current_bag_id = ? AND delivery_branch_code IS NOT NULL AND delivery_branch_code <> ''", reqBody.BagID).
+		Distinct().Pluck("delivery_branch_code", &offices).Error; err != nil {
+		logger.Error("Failed to load bag destination offices", err)
+	}
+	line, err := transport_line.CheckReceive(database.DB, strings.ToUpper(strings.TrimSpace(reqBody.LineID)), reqBody.BagID, offices, time.Now())
+	if err != nil {
+		status := fiber.StatusBadRequest
+		message := err.Error()
+		var notServed *transport_line.OfficeNotServedError
+		switch {
+		case errors.Is(err, transport_line.ErrLineNotFound):
+			message = fmt.Sprintf("line_id %s is not a registered transport line", reqBody.LineID)
+		case errors.Is(err, transport_line.ErrLineInactive), errors.As(err, &notServed):
+		case errors.Is(err, transport_line.ErrCapacityReached):
+			status = fiber.StatusConflict
+		default:
+			logger.Error("Failed to check transport line", err)
+			status = fiber.StatusInternalServerError
+			message = "Failed to check transport line"
+		}
+		errorResponse := types.ApiResponse{
+			Message: message,
+			Status:  status,
+		}
+		c.Status(status).JSON(errorResponse)
+		responseBytes, _ := json.Marshal(errorResponse)
+		logRequest(c, string(responseBytes), requestBody)
+		return nil
+	}
+
+	payload := dms.ReceiveBagRequest{
+		BagID:           reqBody.BagID,
+		RecvInstruction: reqBody.RecvInstruction,
+		LineID:          line.Code,
+		ReceiveItems:    reqBody.ReceiveItems,
 	}
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -1227,7 +1471,7 @@ func (bc *BagController) ReceiveBag(c *fiber.Ctx) error {
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", authHeader)
-	client := &http.Client{}
+	client := dms.NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		errorResponse := types.ApiResponse{
@@ -1274,6 +1518,18 @@ func (bc *BagController) ReceiveBag(c *fiber.Ctx) error {
 			fmt.Printf("Failed to update bookings after bag received: %v\n", err)
 		}
 
+		if err := bag_limit.SetStatus(database.DB, reqBody.BagID, bookingModel.BagStatusReceived); err != nil {
+			logger.Error(fmt.Sprintf("Failed to mark bag %s received", reqBody.BagID), err)
+		}
+
+		office := ""
+		if len(offices) == 1 {
+			office = offices[0]
+		}
+		if err := transport_line.RecordLoad(database.DB, line, reqBody.BagID, office, middleware.CurrentUserID(c), time.Now()); err != nil {
+			logger.Error(fmt.Sprintf("Failed to record bag %s on line %s", reqBody.BagID, line.Code), err)
+		}
+
 		finalResponse := types.ApiResponse{
 			Message: "Bag received successfully",
 			Status:  resp.StatusCode,
@@ -1312,45 +1568,21 @@ func (bc *BagController) ReceiveBag(c *fiber.Ctx) error {
 
 // and creates booking status events and booking snapshots for each booking
 func (bc *BagController) updateBookingsAfterBagReceived(bagID string, c *fiber.Ctx) error {
-	db := database.DB
+	db := database.DB.WithContext(c.UserContext())
 	if db == nil {
 		return fmt.Errorf("database connection not found")
 	}
 
 	// Get user authentication information
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
+			Message: "User not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding user by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		return bc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
+	userInfo := current.User
 
 	userID := uint(userInfo.ID)
 
@@ -1375,23 +1607,29 @@ func (bc *BagController) updateBookingsAfterBagReceived(bagID string, c *fiber.C
 		}
 	}()
 
-	// Update each booking status and create events
-	for _, booking := range bookings {
-		// Update booking status based on user permissions
-		hasPostMasterPermission := false
-		for _, permission := range userPermission {
-			if permission == "passport-booking.postmaster.full-permit" {
-				hasPostMasterPermission = true
-				break
-			}
+	// Update booking status based on user permissions
+	hasPostMasterPermission := false
+	for _, permission := range userPermission {
+		if permission == "passport-booking.postmaster.full-permit" {
+			hasPostMasterPermission = true
+			break
 		}
+	}
+	next := bookingModel.BookingStatusReceivedByPostman
+	if hasPostMasterPermission {
+		// User has full permission, allow status update
+		next = bookingModel.BookingStatusReceivedByPostMaster
+	}
 
-		if hasPostMasterPermission {
-			// User has full permission, allow status update
-			booking.Status = bookingModel.BookingStatusReceivedByPostMaster
-		} else {
-			booking.Status = bookingModel.BookingStatusReceivedByPostman
+	// Update each booking status and create events
+	updated := 0
+	for _, booking := range bookings {
+		// Items already past this step, e.g. delivered or returned, keep their status
+		if err := booking.Status.CheckTransition(next); err != nil {
+			logger.Warning(fmt.Sprintf("Booking %d in bag %s not updated: %v", booking.ID, bagID, err))
+			continue
 		}
+		booking.Status = next
 		booking.UpdatedBy = fmt.Sprintf("%d", userID)
 
 		if err := tx.Save(&booking).Error; err != nil {
@@ -1416,6 +1654,7 @@ func (bc *BagController) updateBookingsAfterBagReceived(bagID string, c *fiber.C
 			tx.Rollback()
 			return fmt.Errorf("failed to create booking event for booking ID %d: %v", booking.ID, err)
 		}
+		updated++
 	}
 
 	// Commit the transaction
@@ -1423,7 +1662,12 @@ func (bc *BagController) updateBookingsAfterBagReceived(bagID string, c *fiber.C
 		return fmt.Errorf("failed to commit booking updates: %v", err)
 	}
 
-	fmt.Printf("Successfully updated %d bookings for bag ID %s to item_received_by_postman status\n", len(bookings), bagID)
+	fmt.Printf("Successfully updated %d bookings for bag ID %s to item_received_by_postman status\n", updated, bagID)
+
+	// The items are now with the postman who received the bag
+	if updated > 0 && next == bookingModel.BookingStatusReceivedByPostman {
+		devicePush.Dispatch(devicePush.ItemsAssigned(userID, updated, bagID))
+	}
 	return nil
 }
 
@@ -1450,49 +1694,27 @@ func (bc *BagController) Index(c *fiber.Ctx) error {
 	}
 
 	// Get user authentication information
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
+			Message: "User not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding user by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		return bc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
+	userInfo := current.User
 
 	// Convert user ID to string since CreatedBy field is varchar
 	userIDString := fmt.Sprintf("%d", userInfo.ID)
 
 	// Build query with filters and user restriction
 	// Use string user ID for created_by field since it's defined as varchar(255)
-	query := bc.DB.Model(&bookingModel.BookingStatusEvent{}).
-		Preload("Booking").
-		Preload("Booking.User").
-		Where("created_by = ?", userIDString)
+	query := bc.DB.WithContext(c.UserContext()).Model(&bookingModel.BookingStatusEvent{})
+
+	// Read-only viewers (auditors) see every operator's entries
+	if !middleware.GetUserPermissions(c)[constants.PermViewerReadOnly] {
+		query = query.Where("created_by = ?", userIDString)
+	}
 
 	// Apply status filter
 	if req.Status != "" {
@@ -1539,7 +1761,11 @@ func (bc *BagController) Index(c *fiber.Ctx) error {
 
 	// Apply pagination
 	var bookings []bookingModel.BookingStatusEvent
-	if err := query.Offset(req.GetOffset()).Limit(req.GetLimit()).Order("created_at DESC").Find(&bookings).Error; err != nil {
+	if err := query.
+		Preload("Booking").
+		Preload("Booking.User", database.UserSummary).
+		Offset(req.GetOffset()).Limit(req.GetLimit()).Order("created_at DESC").
+		Find(&bookings).Error; err != nil {
 		logger.Error("Failed to fetch bookings", err)
 		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -1553,9 +1779,25 @@ func (bc *BagController) Index(c *fiber.Ctx) error {
 	hasNext := req.Page < totalPages
 	hasPrev := req.Page > 1
 
+	// Apply ?fields= sparse fieldset if requested
+	if middleware.MaskPII(c) {
+		for i := range bookings {
+			bookingTypes.MaskBookingPII(&bookings[i].Booking)
+		}
+	}
+	data, err := utils.SelectFields(bookings, c.Query("fields"))
+	if err != nil {
+		logger.Error("Failed to apply field selection", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to prepare bookings",
+			Data:    nil,
+		})
+	}
+
 	// Prepare response
 	response := bookingTypes.BookingIndexResponse{
-		Data: bookings,
+		Data: data,
 		Pagination: bookingTypes.PaginationResponse{
 			CurrentPage: req.Page,
 			PerPage:     req.PerPage,
@@ -0,0 +1,181 @@
+package bag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/bag_limit"
+	"passport-booking/services/bag_lock"
+	barcodeService "passport-booking/services/barcode"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/dms_outbox"
+	"passport-booking/types"
+	bagType "passport-booking/types/bag"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// queuedAddItem is the outbox payload of an add-to-bag accepted while DMS was down
+type queuedAddItem struct {
+	Request     bagType.AddItemRequest `json:"request"`
+	WeightGrams int                    `json:"weight_grams"`
+	// Reserved is set when the item took a bag slot that must be given back if the
+	// sync fails for good
+	Reserved bool `json:"reserved"`
+}
+
+// queueAddItem records the add in the DMS outbox and tells the operator it will sync
+// later. The booking keeps its current status until the outbox is drained.
+func queueAddItem(c *fiber.Ctx, db *gorm.DB, booking *bookingModel.Booking, queued queuedAddItem, requestBody, userID string) error {
+	entry, err := dms_outbox.Enqueue(db, bookingModel.DMSOutboxAddItem, booking.ID, queued.Request.BagID, queued, userID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to queue booking %d for DMS", booking.ID), err)
+		errorResponse := types.ApiResponse{
+			Message: "DMS is unavailable and the item could not be queued, please retry",
+			Status:  fiber.StatusServiceUnavailable,
+		}
+		c.Status(fiber.StatusServiceUnavailable).JSON(errorResponse)
+		responseBytes, _ := json.Marshal(errorResponse)
+		logRequest(c, string(responseBytes), requestBody)
+		return nil
+	}
+
+	if err := booking_event.SnapshotBookingToEvent(db, booking, "item_queued_for_dms", userID); err != nil {
+		logger.Error("Failed to create booking event", err)
+	}
+
+	response := types.ApiResponse{
+		Message: "DMS is currently unavailable. The item has been queued and will sync to DMS automatically; keep it with the bag",
+		Status:  fiber.StatusAccepted,
+		Data: fiber.Map{
+			"code":           "QUEUED_FOR_SYNC",
+			"outbox_id":      entry.ID,
+			"booking_id":     booking.ID,
+			"bag_id":         queued.Request.BagID,
+			"booking_status": booking.Status,
+		},
+	}
+	c.Status(fiber.StatusAccepted).JSON(response)
+	responseBytes, _ := json.Marshal(response)
+	logRequest(c, string(responseBytes), requestBody)
+	return nil
+}
+
+// AddItemReplayer replays queued add-to-bag operations from the DMS outbox
+type AddItemReplayer struct{}
+
+// dmsFailure classifies a DMS answer: server errors are retried, anything else DMS
+// refused is permanent
+func dmsFailure(step string, statusCode int, body []byte) error {
+	err := fmt.Errorf("DMS %s returned status %d: %s", step, statusCode, string(body))
+	if statusCode >= 500 {
+		return err
+	}
+	return dms_outbox.Permanent(err)
+}
+
+// Replay books the item in DMS if it is still pre-booked, adds it to the bag and
+// attaches it locally, under the same bag lock as live adds
+func (AddItemReplayer) Replay(db *gorm.DB, authHeader string, entry *bookingModel.DMSOutboxEntry) error {
+	var queued queuedAddItem
+	if err := json.Unmarshal([]byte(entry.Payload), &queued); err != nil {
+		return dms_outbox.Permanent(fmt.Errorf("invalid outbox payload: %w", err))
+	}
+	reqBody := queued.Request
+
+	return bag_lock.With(db, reqBody.BagID, func() error {
+		var booking bookingModel.Booking
+		if err := db.First(&booking, entry.BookingID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return dms_outbox.Permanent(fmt.Errorf("booking %d not found", entry.BookingID))
+			}
+			return err
+		}
+
+		barcode := strPtrToStr(booking.Barcode)
+		switch booking.Status {
+		case bookingModel.BookingStatusPreBooked:
+			if err := dms.Allow(); err != nil {
+				return err
+			}
+			var err error
+			var pooled bool
+			barcode, pooled, err = barcodeService.TakePooled(db, reqBody.BranchCode)
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to take pooled barcode for branch %s", reqBody.BranchCode), err)
+			}
+			if !pooled {
+				if barcode, err = getBarcodeFromAPI(authHeader); err != nil {
+					return fmt.Errorf("failed to get barcode: %w", err)
+				}
+			}
+
+			if err := dms.Allow(); err != nil {
+				return err
+			}
+			body, statusCode, err := BookingDms(authHeader, barcode, booking.AppOrOrderID)
+			if err != nil {
+				return fmt.Errorf("failed to book article: %w", err)
+			}
+			if statusCode < 200 || statusCode >= 300 {
+				return dmsFailure("booking", statusCode, body)
+			}
+
+			if _, err := confirmBooking(db, &booking, barcode, reqBody.BranchCode, entry.CreatedBy); err != nil {
+				return dms_outbox.Permanent(fmt.Errorf("DMS booked %s but the booking could not be saved: %w", barcode, err))
+			}
+		case bookingModel.BookingStatusBooked:
+			// Booked by an earlier attempt, or the item was already booked when queued
+		default:
+			return dms_outbox.Permanent(fmt.Errorf("booking is %s and can no longer be added to a bag", booking.Status))
+		}
+
+		resp, err := dms.NewDMSService().AddArticle(authHeader, dms.AddArticleRequest{
+			BagType: reqBody.BagType,
+			BagID:   reqBody.BagID,
+			Index:   reqBody.Index,
+			ItemID:  barcode,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return dmsFailure("add article", resp.StatusCode, resp.Body)
+		}
+
+		booking.CurrentBagID = &reqBody.BagID
+		if err := db.Model(&booking).Updates(map[string]interface{}{
+			"current_bag_id": reqBody.BagID,
+			"updated_by":     dms_outbox.SystemActor,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to attach booking %d to bag %s: %w", booking.ID, reqBody.BagID, err)
+		}
+		if err := booking_event.SnapshotBookingToEvent(db, &booking, "item_added_to_bag_after_sync", dms_outbox.SystemActor); err != nil {
+			logger.Error("Failed to create booking event", err)
+		}
+		return nil
+	})
+}
+
+// Abandon gives the bag slot back and leaves a failure event for the operator
+func (AddItemReplayer) Abandon(db *gorm.DB, entry *bookingModel.DMSOutboxEntry, reason error) {
+	var queued queuedAddItem
+	if err := json.Unmarshal([]byte(entry.Payload), &queued); err == nil && queued.Reserved {
+		if err := bag_limit.Release(db, queued.Request.BagID, queued.WeightGrams); err != nil {
+			logger.Error(fmt.Sprintf("Failed to release bag %s capacity", queued.Request.BagID), err)
+		}
+	}
+
+	var booking bookingModel.Booking
+	if err := db.First(&booking, entry.BookingID).Error; err != nil {
+		logger.Error(fmt.Sprintf("Failed to load booking %d after DMS sync failure", entry.BookingID), err)
+		return
+	}
+	if err := booking_event.SnapshotBookingToEvent(db, &booking, "item_sync_to_dms_failed", dms_outbox.SystemActor); err != nil {
+		logger.Error("Failed to create booking event", err)
+	}
+}
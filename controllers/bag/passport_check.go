@@ -0,0 +1,60 @@
+package bag
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"passport-booking/httpServices/passport"
+	"passport-booking/logger"
+	"strings"
+	"sync"
+)
+
+var (
+	passportStatusOnce   sync.Once
+	passportStatusClient passport.Client
+)
+
+// passportStatus returns the passport system client, built on first use once the
+// environment is loaded; nil when no passport system is configured
+func passportStatus() passport.Client {
+	passportStatusOnce.Do(func() {
+		passportStatusClient = passport.NewClient()
+	})
+	return passportStatusClient
+}
+
+// passportCheckFailOpen lets items into bags while the passport system is unreachable
+func passportCheckFailOpen() bool {
+	return strings.EqualFold(os.Getenv("PASSPORT_STATUS_FAIL_OPEN"), "true")
+}
+
+// checkPassportDispatched confirms with the passport system that the passport for
+// applicationID has been printed and dispatched, so no bag slot is spent on an item
+// that is not there yet. It returns the HTTP status and message to reject with, or
+// 0 when the item may be bagged.
+func checkPassportDispatched(applicationID string) (int, string) {
+	client := passportStatus()
+	if client == nil {
+		return 0, ""
+	}
+
+	result, err := client.Status(applicationID)
+	if err != nil {
+		if errors.Is(err, passport.ErrNotFound) {
+			return http.StatusConflict, fmt.Sprintf("Application %s is unknown to the passport system", applicationID)
+		}
+		if passportCheckFailOpen() {
+			logger.Warning(fmt.Sprintf("Passport system status check failed for %s, bagging anyway: %v", applicationID, err))
+			return 0, ""
+		}
+		logger.Error(fmt.Sprintf("Passport system status check failed for %s", applicationID), err)
+		return http.StatusServiceUnavailable, "Could not confirm passport status with the passport system, please try again"
+	}
+
+	if !result.Dispatched() {
+		return http.StatusConflict, fmt.Sprintf("Passport for application %s is not dispatched yet (status: %s)", applicationID, result.Status)
+	}
+	return 0, ""
+}
@@ -0,0 +1,179 @@
+package bag
+
+import (
+	"errors"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/transport_line"
+	"passport-booking/types"
+	bagType "passport-booking/types/bag"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// currentUser resolves the authenticated user, responding with an error when it cannot
+func (bc *BagController) currentUser(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// lineResponse adds today's load and the next departure to a line
+func (bc *BagController) lineResponse(line bookingModel.TransportLine, now time.Time) (bagType.TransportLineResponse, error) {
+	loaded, err := transport_line.LoadedOn(bc.DB, line.ID, now)
+	if err != nil {
+		return bagType.TransportLineResponse{}, err
+	}
+	return bagType.TransportLineResponse{
+		TransportLine: line,
+		LoadedToday:   loaded,
+		NextDeparture: transport_line.NextDeparture(&line, now),
+	}, nil
+}
+
+// ListLines returns the transport lines, optionally only those serving an office
+func (bc *BagController) ListLines(c *fiber.Ctx) error {
+	var req bagType.TransportLineListRequest
+	if err := c.QueryParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Message: "Invalid query parameters",
+			Status:  fiber.StatusBadRequest,
+			Data:    nil,
+		})
+	}
+
+	query := bc.DB.Preload("Offices").Preload("Schedules")
+	if office := strings.TrimSpace(req.Office); office != "" {
+		query = query.Where("id IN (?)", bc.DB.Model(&bookingModel.TransportLineOffice{}).
+			Select("line_id").Where("office_code = ?", office))
+	}
+	if req.Active != nil {
+		query = query.Where("active = ?", *req.Active)
+	}
+
+	var lines []bookingModel.TransportLine
+	if err := query.Order("code").Find(&lines).Error; err != nil {
+		logger.Error("Failed to fetch transport lines", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Message: "Failed to fetch transport lines",
+			Status:  fiber.StatusInternalServerError,
+			Data:    nil,
+		})
+	}
+
+	now := time.Now()
+	response := make([]bagType.TransportLineResponse, 0, len(lines))
+	for _, line := range lines {
+		item, err := bc.lineResponse(line, now)
+		if err != nil {
+			logger.Error("Failed to count transport line loads", err)
+			return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+				Message: "Failed to fetch transport lines",
+				Status:  fiber.StatusInternalServerError,
+				Data:    nil,
+			})
+		}
+		response = append(response, item)
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Message: "Transport lines retrieved successfully",
+		Status:  fiber.StatusOK,
+		Data:    response,
+	})
+}
+
+// ShowLine returns one transport line by code
+func (bc *BagController) ShowLine(c *fiber.Ctx) error {
+	line, err := transport_line.Find(bc.DB, strings.ToUpper(strings.TrimSpace(c.Params("code"))))
+	if errors.Is(err, transport_line.ErrLineNotFound) {
+		return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Message: "Transport line not found",
+			Status:  fiber.StatusNotFound,
+			Data:    nil,
+		})
+	}
+	var response bagType.TransportLineResponse
+	if err == nil {
+		response, err = bc.lineResponse(*line, time.Now())
+	}
+	if err != nil {
+		logger.Error("Failed to fetch transport line", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Message: "Internal server error",
+			Status:  fiber.StatusInternalServerError,
+			Data:    nil,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Message: "Transport line retrieved successfully",
+		Status:  fiber.StatusOK,
+		Data:    response,
+	})
+}
+
+// SaveLine creates or updates a transport line, replacing its offices and schedules
+func (bc *BagController) SaveLine(c *fiber.Ctx) error {
+	var req bagType.TransportLineRequest
+	if err := c.BodyParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Message: "Invalid request body",
+			Status:  fiber.StatusBadRequest,
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Message: err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	line := bookingModel.TransportLine{
+		Code:          req.Code,
+		Name:          req.Name,
+		OriginOffice:  req.OriginOffice,
+		DailyCapacity: req.DailyCapacity,
+		Active:        req.Active == nil || *req.Active,
+		UpdatedByID:   userInfo.ID,
+	}
+	for _, office := range req.Offices {
+		line.Offices = append(line.Offices, bookingModel.TransportLineOffice{OfficeCode: office})
+	}
+	for _, s := range req.Schedules {
+		line.Schedules = append(line.Schedules, bookingModel.TransportLineSchedule{Weekday: s.Weekday, DepartsAt: s.DepartsAt})
+	}
+
+	if err := transport_line.Save(bc.DB.WithContext(c.UserContext()), &line); err != nil {
+		logger.Error("Failed to save transport line", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Message: "Failed to save transport line",
+			Status:  fiber.StatusInternalServerError,
+			Data:    nil,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Message: "Transport line saved successfully",
+		Status:  fiber.StatusOK,
+		Data:    line,
+	})
+}
@@ -0,0 +1,416 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/database"
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/models/otp"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/address_change"
+	"passport-booking/services/booking_lock"
+	otpService "passport-booking/services/otp"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// currentUser resolves the authenticated user, writing the error response itself on failure
+func (bc *BookingController) currentUser(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// loadChangeableBooking loads the booking for an address change and rejects it while a
+// postman holds the delivery lock
+func (bc *BookingController) loadChangeableBooking(c *fiber.Ctx, bookingID, userID uint) (*bookingModel.Booking, error) {
+	var booking bookingModel.Booking
+	if err := bc.DB.Preload("DeliveryAddress").Scopes(database.NotDeletedBookings).First(&booking, bookingID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find booking", err)
+		return nil, bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if err := booking_lock.Check(bc.DB, booking.ID, userID); err != nil {
+		var lockedErr *booking_lock.LockedError
+		if errors.As(err, &lockedErr) {
+			return nil, bc.sendResponseWithLog(c, fiber.StatusLocked, types.ApiResponse{
+				Status:  fiber.StatusLocked,
+				Message: "Booking is locked while delivery confirmation is in progress",
+				Data: map[string]interface{}{
+					"purpose":    lockedErr.Purpose,
+					"expires_at": lockedErr.ExpiresAt,
+				},
+			})
+		}
+		logger.Error("Failed to check booking lock", err)
+		return nil, bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Database error",
+			Data:    nil,
+		})
+	}
+
+	if err := address_change.Eligible(&booking); err != nil {
+		return nil, bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	return &booking, nil
+}
+
+// addressChangeErrorResponse maps address_change.Apply errors to responses
+func (bc *BookingController) addressChangeErrorResponse(c *fiber.Ctx, change *bookingModel.AddressChange, err error) error {
+	var ineligibleErr *address_change.IneligibleError
+	var rerouteErr *address_change.RerouteError
+	switch {
+	case errors.As(err, &ineligibleErr), errors.Is(err, address_change.ErrNotPending):
+		return bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	case errors.As(err, &rerouteErr):
+		logger.Error(fmt.Sprintf("DMS refused re-route for address change %d", change.ID), err)
+		return bc.sendResponseWithLog(c, fiber.StatusBadGateway, types.ApiResponse{
+			Status:  fiber.StatusBadGateway,
+			Message: "External delivery service refused the re-route",
+			Data: map[string]interface{}{
+				"change":            change,
+				"external_status":   rerouteErr.StatusCode,
+				"external_response": rerouteErr.Body,
+			},
+		})
+	case errors.Is(err, dms.ErrBaseURLNotSet):
+		logger.Error("DMS_BASE_URL environment variable is not set", nil)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "External service configuration error",
+			Data:    nil,
+		})
+	}
+
+	logger.Error("Failed to apply address change", err)
+	return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed to apply address change",
+		Data:    nil,
+	})
+}
+
+// RequestAddressChange stages a new delivery address for the applicant's booking and sends
+// an OTP to the verified delivery phone to confirm it
+func (bc *BookingController) RequestAddressChange(c *fiber.Ctx) error {
+	var req bookingTypes.AddressChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	booking, err := bc.loadChangeableBooking(c, req.BookingID, userInfo.ID)
+	if booking == nil {
+		return err
+	}
+
+	if booking.UserID != userInfo.ID {
+		return bc.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+			Status:  fiber.StatusForbidden,
+			Message: "You don't have permission to update this booking",
+			Data:    nil,
+		})
+	}
+
+	if booking.DeliveryPhone == nil || !booking.DeliveryPhoneAppliedVerified {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "A verified delivery phone is required to change the delivery address",
+			Data:    nil,
+		})
+	}
+
+	if handled, err := bc.requireConsent(c, booking); handled {
+		return err
+	}
+
+	var reason *string
+	if req.Reason != "" {
+		reason = &req.Reason
+	}
+	change, err := address_change.Stage(bc.DB.WithContext(c.UserContext()), booking, bookingModel.AddressChangeByApplicant, userInfo.ID, address_change.NewAddress{
+		Division:        req.Division,
+		District:        req.District,
+		PoliceStation:   req.PoliceStation,
+		PostOffice:      req.PostOffice,
+		PostOfficeCode:  req.DeliveryBranchCode,
+		StreetAddress:   req.StreetAddress,
+		DivisionBn:      req.DivisionBn,
+		DistrictBn:      req.DistrictBn,
+		PoliceStationBn: req.PoliceStationBn,
+		PostOfficeBn:    req.PostOfficeBn,
+		StreetAddressBn: req.StreetAddressBn,
+	}, reason)
+	if err != nil && change == nil {
+		logger.Error("Failed to stage address change", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to save address change",
+			Data:    nil,
+		})
+	}
+	if err != nil {
+		logger.Error("Failed to write booking event (delivery_address_change_requested)", err)
+	}
+
+	otpRecord, err := bc.OTPService.SendOTPWithBookingID(*booking.DeliveryPhone, otp.OTPPurposeAddressChange, &booking.ID)
+	if err != nil {
+		logger.Error("Failed to send address change OTP", err)
+		if otpService.IsRateLimited(err) {
+			return bc.sendResponseWithLog(c, fiber.StatusTooManyRequests, types.ApiResponse{
+				Status:  fiber.StatusTooManyRequests,
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to send OTP to delivery phone",
+			Data: map[string]interface{}{
+				"change":    change,
+				"otp_error": err.Error(),
+			},
+		})
+	}
+
+	logger.Success(fmt.Sprintf("Address change %d requested for booking ID: %d", change.ID, booking.ID))
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "OTP sent to confirm the address change",
+		Data: map[string]interface{}{
+			"change": change,
+			"otp_info": map[string]interface{}{
+				"otp_id":     otpRecord.ID,
+				"expires_at": otpRecord.ExpiresAt,
+				"phone":      booking.DeliveryPhone,
+			},
+		},
+	})
+}
+
+// VerifyAddressChange confirms a pending applicant address change with its OTP and
+// re-routes the booking
+func (bc *BookingController) VerifyAddressChange(c *fiber.Ctx) error {
+	var req bookingTypes.VerifyAddressChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	var change bookingModel.AddressChange
+	if err := bc.DB.First(&change, req.ChangeID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Address change not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find address change", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if change.InitiatedBy != bookingModel.AddressChangeByApplicant || change.Status != bookingModel.AddressChangeStatusPending {
+		return bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: address_change.ErrNotPending.Error(),
+			Data:    nil,
+		})
+	}
+
+	booking, err := bc.loadChangeableBooking(c, change.BookingID, userInfo.ID)
+	if booking == nil {
+		return err
+	}
+
+	if booking.UserID != userInfo.ID {
+		return bc.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+			Status:  fiber.StatusForbidden,
+			Message: "You don't have permission to update this booking",
+			Data:    nil,
+		})
+	}
+
+	if booking.DeliveryPhone == nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "No delivery phone found for this booking",
+			Data:    nil,
+		})
+	}
+
+	isValid, otpRecord, err := bc.OTPService.VerifyOTPWithDetails(*booking.DeliveryPhone, req.OTPCode, otp.OTPPurposeAddressChange, booking.ID)
+	if err != nil || !isValid {
+		message := "Invalid OTP"
+		if err != nil {
+			message = err.Error()
+		}
+		status := fiber.StatusBadRequest
+		data := map[string]interface{}{"success": false}
+		if otpRecord != nil {
+			now := bc.OTPService.Now()
+			if otpRecord.IsBlockedAt(now) {
+				status = fiber.StatusTooManyRequests
+			}
+			data["remaining_attempts"] = otpRecord.MaxRetries - otpRecord.RetryCount
+			data["is_blocked"] = otpRecord.IsBlockedAt(now)
+			data["is_expired"] = otpRecord.IsExpiredAt(now)
+		}
+		return bc.sendResponseWithLog(c, status, types.ApiResponse{
+			Status:  status,
+			Message: message,
+			Data:    data,
+		})
+	}
+
+	applied, err := address_change.Apply(bc.DB.WithContext(c.UserContext()), bc.DMS, c.Get("Authorization"), change.ID, strconv.FormatUint(uint64(userInfo.ID), 10))
+	if err != nil {
+		return bc.addressChangeErrorResponse(c, applied, err)
+	}
+
+	logger.Success(fmt.Sprintf("Address change %d applied for booking ID: %d", applied.ID, booking.ID))
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delivery address changed successfully",
+		Data:    applied,
+	})
+}
+
+// OperatorAddressChange redirects a booking on the applicant's behalf without an OTP
+func (bc *BookingController) OperatorAddressChange(c *fiber.Ctx) error {
+	var req bookingTypes.OperatorAddressChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	booking, err := bc.loadChangeableBooking(c, req.BookingID, userInfo.ID)
+	if booking == nil {
+		return err
+	}
+
+	change, err := address_change.Stage(bc.DB.WithContext(c.UserContext()), booking, bookingModel.AddressChangeByOperator, userInfo.ID, address_change.NewAddress{
+		Division:        req.Division,
+		District:        req.District,
+		PoliceStation:   req.PoliceStation,
+		PostOffice:      req.PostOffice,
+		PostOfficeCode:  req.DeliveryBranchCode,
+		StreetAddress:   req.StreetAddress,
+		DivisionBn:      req.DivisionBn,
+		DistrictBn:      req.DistrictBn,
+		PoliceStationBn: req.PoliceStationBn,
+		PostOfficeBn:    req.PostOfficeBn,
+		StreetAddressBn: req.StreetAddressBn,
+	}, &req.Reason)
+	if err != nil && change == nil {
+		logger.Error("Failed to stage address change", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to save address change",
+			Data:    nil,
+		})
+	}
+	if err != nil {
+		logger.Error("Failed to write booking event (delivery_address_change_requested)", err)
+	}
+
+	applied, err := address_change.Apply(bc.DB.WithContext(c.UserContext()), bc.DMS, c.Get("Authorization"), change.ID, strconv.FormatUint(uint64(userInfo.ID), 10))
+	if err != nil {
+		return bc.addressChangeErrorResponse(c, applied, err)
+	}
+
+	logger.Success(fmt.Sprintf("Address change %d applied by operator %s for booking ID: %d", applied.ID, userInfo.LegalName, booking.ID))
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delivery address changed successfully",
+		Data:    applied,
+	})
+}
@@ -0,0 +1,86 @@
+package booking
+
+import (
+	"passport-booking/database"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/transliterate"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// AddressLabel renders a booking's delivery address for its label. Domestic items
+// are labelled in Bangla for the postman; ?script=en|bn overrides the choice.
+func (bc *BookingController) AddressLabel(c *fiber.Ctx) error {
+	bookingID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || bookingID <= 0 {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid booking ID",
+			Data:    nil,
+		})
+	}
+
+	script := transliterate.ScriptFor(bookingTypes.DomesticCountry)
+	if s := c.Query("script"); s != "" {
+		parsed, ok := transliterate.ParseScript(s)
+		if !ok {
+			return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "script must be bn or en",
+				Data:    nil,
+			})
+		}
+		script = parsed
+	}
+
+	var booking bookingModel.Booking
+	if err := bc.DB.Preload("DeliveryAddress").Scopes(database.NotDeletedBookings).First(&booking, bookingID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to fetch booking for address label", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+	if booking.DeliveryAddress == nil {
+		return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Booking has no delivery address yet",
+			Data:    nil,
+		})
+	}
+
+	label := bookingTypes.AddressLabel{
+		BookingID: booking.ID,
+		Barcode:   booking.Barcode,
+		Name:      booking.Name,
+		Phone:     booking.Phone,
+		Script:    string(script),
+		Lines:     transliterate.Lines(booking.DeliveryAddress, script),
+	}
+	if booking.DeliveryPhone != nil {
+		label.Phone = *booking.DeliveryPhone
+	}
+	if middleware.MaskPII(c) {
+		label.MaskPII()
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Address label rendered successfully",
+		Data:    label,
+	})
+}
@@ -0,0 +1,173 @@
+package booking
+
+import (
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/app_id"
+	"passport-booking/services/booking_event"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// AppIDReviewQueue lists bookings whose application/order ID format could not be confirmed, oldest first
+func (bc *BookingController) AppIDReviewQueue(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	perPage, _ := strconv.Atoi(c.Query("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	query := bc.DB.Model(&bookingModel.Booking{}).Where("app_id_needs_review = ? AND deleted_at IS NULL", true)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.Error("Failed to count bookings awaiting ID review", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch bookings awaiting ID review",
+			Data:    nil,
+		})
+	}
+
+	var bookings []bookingModel.Booking
+	if err := query.Order("created_at").Offset((page - 1) * perPage).Limit(perPage).Find(&bookings).Error; err != nil {
+		logger.Error("Failed to fetch bookings awaiting ID review", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch bookings awaiting ID review",
+			Data:    nil,
+		})
+	}
+
+	totalPages := int((total + int64(perPage) - 1) / int64(perPage))
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Bookings awaiting ID review fetched successfully",
+		Data: bookingTypes.BookingIndexResponse{
+			Data: bookingTypes.NewBookingResponses(bookings),
+			Pagination: bookingTypes.PaginationResponse{
+				CurrentPage: page,
+				PerPage:     perPage,
+				Total:       total,
+				TotalPages:  totalPages,
+				HasNext:     page < totalPages,
+				HasPrev:     page > 1,
+			},
+		},
+	})
+}
+
+// ResolveAppIDReview clears the review flag once an operator has confirmed the ID
+func (bc *BookingController) ResolveAppIDReview(c *fiber.Ctx) error {
+	bookingID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid booking ID",
+			Data:    nil,
+		})
+	}
+
+	var req bookingTypes.AppIDReviewRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid request body",
+				Data:    nil,
+			})
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	var booking bookingModel.Booking
+	if err := bc.DB.First(&booking, bookingID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find booking", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if !booking.AppIDNeedsReview {
+		return bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Booking ID is not awaiting review",
+			Data:    nil,
+		})
+	}
+
+	// An operator naming the source system must name a known one
+	if req.SourceSystem != "" {
+		if _, err := app_id.Validate(req.SourceSystem, booking.AppOrOrderID); err != nil {
+			return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		booking.SourceSystem = &req.SourceSystem
+	}
+
+	now := time.Now()
+	actor := strconv.FormatUint(uint64(userInfo.ID), 10)
+	booking.AppIDNeedsReview = false
+	booking.AppIDReviewedByID = &userInfo.ID
+	booking.AppIDReviewedAt = &now
+	if req.Note != "" {
+		booking.AppIDReviewReason = &req.Note
+	}
+	booking.UpdatedBy = actor
+
+	err = bc.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&booking).Error; err != nil {
+			return err
+		}
+		return booking_event.SnapshotBookingToEvent(tx, &booking, "app_id_review_resolved", actor)
+	})
+	if err != nil {
+		logger.Error("Failed to resolve booking ID review", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to resolve booking ID review",
+			Data:    nil,
+		})
+	}
+
+	logger.Success(fmt.Sprintf("Application/order ID of booking %d confirmed by %s", booking.ID, userInfo.LegalName))
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Booking ID review resolved successfully",
+		Data:    bookingTypes.NewBookingResponse(&booking),
+	})
+}
@@ -1,15 +1,27 @@
 package booking
 
 import (
+	"errors"
 	"fmt"
+	"passport-booking/constants"
 	"passport-booking/database"
+	"passport-booking/httpServices/dms"
 	"passport-booking/logger"
+	"passport-booking/middleware"
 	addressModel "passport-booking/models/address"
 	bookingModel "passport-booking/models/booking"
 	"passport-booking/models/otp"
 	"passport-booking/models/slip_parser"
+	"passport-booking/services/app_id"
 	"passport-booking/services/booking_event"
+	"passport-booking/services/booking_lock"
+	"passport-booking/services/booking_quota"
+	"passport-booking/services/booking_reference"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/services/consent"
+	"passport-booking/services/delivery_hold"
 	otpService "passport-booking/services/otp"
+	"passport-booking/services/transliterate"
 	"passport-booking/types"
 	bookingTypes "passport-booking/types/booking"
 	"passport-booking/utils"
@@ -25,6 +37,9 @@ import (
 type BookingController struct {
 	DB             *gorm.DB
 	Logger         *logger.AsyncLogger
+	OTPService     otpService.OTPService
+	DMS            dms.Client
+	Holds          *delivery_hold.Service
 	loggerInstance *logger.AsyncLogger
 }
 
@@ -33,6 +48,9 @@ func NewBookingController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *Booking
 	return &BookingController{
 		DB:             db,
 		Logger:         asyncLogger,
+		OTPService:     otpService.NewOTPService(db),
+		DMS:            dms.NewDMSService(),
+		Holds:          delivery_hold.NewDeliveryHoldService(db),
 		loggerInstance: asyncLogger,
 	}
 }
@@ -73,50 +91,36 @@ func (bc *BookingController) Index(c *fiber.Ctx) error {
 	}
 
 	// Get user authentication information
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
+			Message: "User not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding user by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		return bc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
+	userInfo := current.User
 
 	userID := uint(userInfo.ID)
 
-	// Build query with filters and user restriction
-	query := bc.DB.Model(&bookingModel.Booking{}).Preload("User").Preload("DeliveryAddress").Where("user_id = ?", userID)
+	// Build query with filters and user restriction. Relations are preloaded on the
+	// page only, so the count stays a single query.
+	query := bc.DB.WithContext(c.UserContext()).Model(&bookingModel.Booking{}).Where("deleted_at IS NULL")
+
+	// Read-only viewers (auditors) see all bookings; everyone else only their own
+	if !middleware.GetUserPermissions(c)[constants.PermViewerReadOnly] {
+		query = query.Where("user_id = ?", userID)
+	}
 
 	// Apply status filter
 	if req.Status != "" {
 		query = query.Where("status = ?", req.Status)
 	}
 
+	if req.Reference != "" {
+		query = query.Where("reference = ?", booking_reference.Normalize(req.Reference))
+	}
+
 	// Apply date range filters
 	if req.FromDate != "" {
 		fromTime, err := req.ParseFromDate()
@@ -157,7 +161,11 @@ func (bc *BookingController) Index(c *fiber.Ctx) error {
 
 	// Apply pagination
 	var bookings []bookingModel.Booking
-	if err := query.Offset(req.GetOffset()).Limit(req.GetLimit()).Order("created_at DESC").Find(&bookings).Error; err != nil {
+	if err := query.
+		Preload("User", database.UserSummary).
+		Preload("DeliveryAddress", database.AddressSummary).
+		Offset(req.GetOffset()).Limit(req.GetLimit()).Order("created_at DESC").
+		Find(&bookings).Error; err != nil {
 		logger.Error("Failed to fetch bookings", err)
 		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -171,9 +179,21 @@ func (bc *BookingController) Index(c *fiber.Ctx) error {
 	hasNext := req.Page < totalPages
 	hasPrev := req.Page > 1
 
+	// Apply ?fields= sparse fieldset if requested
+	// Viewers and supervisors get phone numbers and addresses masked
+	data, err := utils.SelectFields(bookingTypes.NewBookingResponsesMasked(bookings, middleware.MaskPII(c)), c.Query("fields"))
+	if err != nil {
+		logger.Error("Failed to apply field selection", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to prepare bookings",
+			Data:    nil,
+		})
+	}
+
 	// Prepare response
 	response := bookingTypes.BookingIndexResponse{
-		Data: bookings,
+		Data: data,
 		Pagination: bookingTypes.PaginationResponse{
 			CurrentPage: req.Page,
 			PerPage:     req.PerPage,
@@ -213,41 +233,17 @@ func (bc *BookingController) Store(c *fiber.Ctx) error {
 		})
 	}
 
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
+			Message: "User not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
+	userInfo := current.User
 
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding user by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		return bc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
-
-	userPermission, ok := claims["permissions"].([]interface{})
+	userPermission, ok := current.Claims["permissions"].([]interface{})
 
 	if !ok {
 		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
@@ -305,7 +301,7 @@ func (bc *BookingController) Store(c *fiber.Ctx) error {
 
 	userID := uint(userInfo.ID)
 	var slipParserRequest slip_parser.SlipParserRequest
-	err = database.DB.Where("request_id = ?", req.RequestID).First(&slipParserRequest).Error
+	err := database.DB.Where("request_id = ?", req.RequestID).First(&slipParserRequest).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
@@ -322,17 +318,41 @@ func (bc *BookingController) Store(c *fiber.Ctx) error {
 		})
 	}
 
+	// Reject malformed application/order IDs; uncertain ones are booked but flagged for an operator
+	appID, err := app_id.Validate(req.SourceSystem, slipParserRequest.AppOrOrderID)
+	if err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if appID.Verdict == app_id.VerdictInvalid {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid application/order ID: %s", appID.Reason),
+			Data:    nil,
+		})
+	}
+
 	// Check if booking with the same AppOrOrderID already exists
 	var existingBooking bookingModel.Booking
 	err = database.DB.Preload("User").Where("app_or_order_id = ?", slipParserRequest.AppOrOrderID).First(&existingBooking).Error
 
-	if err == nil {
+	if err == nil && existingBooking.DeletedAt != nil {
+		// A deleted booking keeps its application/order ID until it is restored
+		return bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: "A booking for this application/order ID was deleted; ask an administrator to restore it",
+			Data:    fiber.Map{"booking_id": existingBooking.ID, "deleted_at": existingBooking.DeletedAt},
+		})
+	} else if err == nil {
 		// Booking already exists, return existing data
 		logger.Info(fmt.Sprintf("Booking with AppOrOrderID %s already exists", slipParserRequest.AppOrOrderID))
 		return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
 			Status:  fiber.StatusOK,
 			Message: "Booking already exists",
-			Data:    existingBooking,
+			Data:    bookingTypes.NewBookingResponse(&existingBooking),
 		})
 	} else if err != gorm.ErrRecordNotFound {
 		// Some other database error occurred
@@ -345,14 +365,33 @@ func (bc *BookingController) Store(c *fiber.Ctx) error {
 	}
 
 	var booking bookingModel.Booking
+	var quota *booking_quota.Usage
+	var quotaErr error
 
 	// Use DB.Transaction for automatic rollback on error
-	err = database.DB.Transaction(func(tx *gorm.DB) error {
+	err = database.DB.WithContext(c.UserContext()).Transaction(func(tx *gorm.DB) error {
+		// Daily quotas of the operator and their branches catch accounts churning out
+		// bookings. They stay locked until this transaction ends.
+		quota, quotaErr = booking_quota.Reserve(tx, userID, current.BranchCodes, time.Now())
+		if quotaErr != nil {
+			return quotaErr
+		}
+		if quota != nil && quota.Exceeded() {
+			logger.Warning(fmt.Sprintf("User %d is over the daily booking quota of %s %s (%d of %d); not enforced",
+				userID, quota.Scope, quota.Subject, quota.Used, quota.Limit))
+		}
+
+		reference, err := booking_reference.Next(tx, time.Now())
+		if err != nil {
+			logger.Error("Failed to issue booking reference", err)
+			return err
+		}
 
 		// Create booking record with basic information only
 		booking = bookingModel.Booking{
 			UserID:                userID,
 			AppOrOrderID:          slipParserRequest.AppOrOrderID,
+			Reference:             &reference,
 			Name:                  slipParserRequest.Name,
 			FatherName:            slipParserRequest.FatherName,
 			MotherName:            slipParserRequest.MotherName,
@@ -384,6 +423,15 @@ func (bc *BookingController) Store(c *fiber.Ctx) error {
 				StreetAddress:  &req.StreetAddress,
 			},
 			DeliveryBranchCode: &req.DeliveryBranchCode,
+			AppIDNeedsReview:   appID.Verdict == app_id.VerdictUncertain,
+		}
+		req.AddressBn.ApplyTo(booking.DeliveryAddress)
+		transliterate.Localize(booking.DeliveryAddress)
+		if appID.Source != "" {
+			booking.SourceSystem = &appID.Source
+		}
+		if booking.AppIDNeedsReview {
+			booking.AppIDReviewReason = &appID.Reason
 		}
 
 		if err := tx.Create(&booking).Error; err != nil {
@@ -407,9 +455,35 @@ func (bc *BookingController) Store(c *fiber.Ctx) error {
 			return err
 		}
 
+		if booking.AppIDNeedsReview {
+			if err := booking_event.SnapshotBookingToEvent(tx, &booking, "app_id_flagged_for_review", "system"); err != nil {
+				logger.Error("Failed to write booking event (app_id_flagged_for_review)", err)
+				return err
+			}
+		}
+
 		return nil
 	})
 
+	var exceeded *booking_quota.ExceededError
+	if errors.As(quotaErr, &exceeded) {
+		setQuotaHeaders(c, quota)
+		logger.Warning(fmt.Sprintf("Booking by user %d refused, %s %s reached its daily quota of %d",
+			userID, exceeded.Usage.Scope, exceeded.Usage.Subject, exceeded.Usage.Limit))
+		return bc.sendResponseWithLog(c, fiber.StatusTooManyRequests, types.ApiResponse{
+			Status:  fiber.StatusTooManyRequests,
+			Message: exceeded.Error(),
+			Data:    exceeded.Usage,
+		})
+	} else if quotaErr != nil {
+		logger.Error("Failed to check booking quota", quotaErr)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Database error",
+			Data:    nil,
+		})
+	}
+
 	if err != nil {
 		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -433,11 +507,19 @@ func (bc *BookingController) Store(c *fiber.Ctx) error {
 		})
 	}
 
+	if quota != nil {
+		quota.Used++
+		if quota.Remaining > 0 {
+			quota.Remaining--
+		}
+		setQuotaHeaders(c, quota)
+	}
+
 	// Return success response with basic booking data
 	return bc.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
 		Status:  fiber.StatusCreated,
 		Message: "Booking created successfully",
-		Data:    createdBooking,
+		Data:    bookingTypes.NewBookingResponse(&createdBooking),
 	})
 }
 
@@ -474,45 +556,21 @@ func (bc *BookingController) Update(c *fiber.Ctx) error {
 	}
 
 	// Get user information from token
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
+			Message: "User not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding user by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		return bc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
+	userInfo := current.User
 
 	userID := uint(userInfo.ID)
 
 	// Find the existing booking
 	var booking bookingModel.Booking
-	if err := bc.DB.Preload("User").Preload("DeliveryAddress").First(&booking, bookingID).Error; err != nil {
+	if err := bc.DB.Preload("User").Preload("DeliveryAddress").Scopes(database.NotDeletedBookings).First(&booking, bookingID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -537,6 +595,27 @@ func (bc *BookingController) Update(c *fiber.Ctx) error {
 		})
 	}
 
+	// Reject edits while a postman is confirming the delivery
+	if err := booking_lock.Check(bc.DB, booking.ID, userID); err != nil {
+		var lockedErr *booking_lock.LockedError
+		if errors.As(err, &lockedErr) {
+			return bc.sendResponseWithLog(c, fiber.StatusLocked, types.ApiResponse{
+				Status:  fiber.StatusLocked,
+				Message: "Booking is locked while delivery confirmation is in progress",
+				Data: map[string]interface{}{
+					"purpose":    lockedErr.Purpose,
+					"expires_at": lockedErr.ExpiresAt,
+				},
+			})
+		}
+		logger.Error("Failed to check booking lock", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Database error",
+			Data:    nil,
+		})
+	}
+
 	var address = booking.DeliveryAddress
 
 	// Check if address already exists for this booking
@@ -547,6 +626,8 @@ func (bc *BookingController) Update(c *fiber.Ctx) error {
 		address.StreetAddress = &req.StreetAddress
 		address.District = &req.District
 		address.Division = &req.Division
+		req.AddressBn.ApplyTo(address)
+		transliterate.Localize(address)
 		if err := bc.DB.Save(&address).Error; err != nil {
 			logger.Error("Failed to update existing address", err)
 			return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
@@ -560,7 +641,7 @@ func (bc *BookingController) Update(c *fiber.Ctx) error {
 		return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
 			Status:  fiber.StatusOK,
 			Message: "Booking delivery information updated successfully",
-			Data:    booking,
+			Data:    bookingTypes.NewBookingResponse(&booking),
 		})
 	}
 
@@ -572,20 +653,30 @@ func (bc *BookingController) Update(c *fiber.Ctx) error {
 
 }
 
-// show indivisual booking info
+// show indivisual booking info; ?identifier_type= selects barcode or app_or_order_id lookups (defaults to id)
 func (bc *BookingController) Show(c *fiber.Ctx) error {
 	bookingIDParam := c.Params("id")
-	bookingID, err := strconv.Atoi(bookingIDParam)
-	if err != nil || bookingID <= 0 {
+	identifierType := bookingTypes.IdentifierType(c.Query("identifier_type"))
+	if err := bookingTypes.NormalizeIdentifierType(&identifierType, bookingTypes.IdentifierTypeID); err != nil {
 		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
 			Status:  fiber.StatusBadRequest,
-			Message: "Invalid booking ID",
+			Message: err.Error(),
 			Data:    nil,
 		})
 	}
 
+	if identifierType == bookingTypes.IdentifierTypeID {
+		if bookingID, err := strconv.Atoi(bookingIDParam); err != nil || bookingID <= 0 {
+			return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid booking ID",
+				Data:    nil,
+			})
+		}
+	}
+
 	var booking bookingModel.Booking
-	if err := bc.DB.Preload("User").Preload("DeliveryAddress").First(&booking, bookingID).Error; err != nil {
+	if err := booking_resolver.Find(bc.DB.Preload("User").Preload("DeliveryAddress"), bookingIDParam, identifierType, &booking); err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -601,10 +692,25 @@ func (bc *BookingController) Show(c *fiber.Ctx) error {
 		})
 	}
 
+	// Let polling clients skip unchanged bookings
+	etag := utils.GenerateETag(fmt.Sprintf("booking:%d", booking.ID), booking.UpdatedAt)
+	if utils.CheckETag(c, etag) {
+		c.Status(fiber.StatusNotModified)
+		bc.logAPIRequest(c)
+		return nil
+	}
+
+	response := bookingTypes.NewBookingResponse(&booking)
+	phoneConsent, err := consent.Active(bc.DB, &booking)
+	if err != nil && !errors.Is(err, consent.ErrConsentRequired) {
+		logger.Error("Failed to fetch delivery phone consent", err)
+	}
+	response.PhoneConsent = bookingTypes.NewPhoneConsentResponse(phoneConsent)
+
 	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
 		Status:  fiber.StatusOK,
 		Message: "Booking fetched successfully",
-		Data:    booking,
+		Data:    response,
 	})
 }
 
@@ -620,7 +726,11 @@ func (bc *BookingController) GetBookingStatusEvent(c *fiber.Ctx) error {
 		})
 	}
 	var statusEvents []bookingModel.BookingStatusEvent
-	if err := bc.DB.Preload("Booking").Preload("Booking.User").Preload("Booking.DeliveryAddress").Where("booking_id = ?", bookingID).Order("created_at DESC").Find(&statusEvents).Error; err != nil {
+	if err := bc.DB.WithContext(c.UserContext()).
+		Preload("Booking").
+		Preload("Booking.User", database.UserSummary).
+		Preload("Booking.DeliveryAddress", database.AddressSummary).
+		Where("booking_id = ?", bookingID).Order("created_at DESC").Find(&statusEvents).Error; err != nil {
 		logger.Error("Failed to fetch booking status events", err)
 		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -628,10 +738,24 @@ func (bc *BookingController) GetBookingStatusEvent(c *fiber.Ctx) error {
 			Data:    nil,
 		})
 	}
+	if middleware.MaskPII(c) {
+		for i := range statusEvents {
+			bookingTypes.MaskBookingPII(&statusEvents[i].Booking)
+		}
+	}
+	data, err := utils.SelectFields(statusEvents, c.Query("fields"))
+	if err != nil {
+		logger.Error("Failed to apply field selection", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to prepare booking status events",
+			Data:    nil,
+		})
+	}
 	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
 		Status:  fiber.StatusOK,
 		Message: "Booking status events fetched successfully",
-		Data:    statusEvents,
+		Data:    data,
 	})
 }
 
@@ -658,45 +782,21 @@ func (bc *BookingController) DeliveryPhoneSendOtp(c *fiber.Ctx) error {
 	}
 
 	// Get user authentication information
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
+			Message: "User not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding user by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		return bc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
+	userInfo := current.User
 
 	userID := uint(userInfo.ID)
 
 	// Find the booking
 	var booking bookingModel.Booking
-	if err := bc.DB.First(&booking, req.BookingID).Error; err != nil {
+	if err := bc.DB.Scopes(database.NotDeletedBookings).First(&booking, req.BookingID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -729,6 +829,10 @@ func (bc *BookingController) DeliveryPhoneSendOtp(c *fiber.Ctx) error {
 		})
 	}
 
+	if handled, err := bc.requireConsent(c, &booking); handled {
+		return err
+	}
+
 	booking.DeliveryPhoneAppliedVerified = false // Reset verification status
 
 	if err := bc.DB.Save(&booking).Error; err != nil {
@@ -740,20 +844,19 @@ func (bc *BookingController) DeliveryPhoneSendOtp(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := booking_event.SnapshotBookingToEvent(bc.DB, &booking, "delivery_phone_send_otp", strconv.FormatUint(uint64(booking.UserID), 10)); err != nil {
+	if err := booking_event.SnapshotBookingToEvent(bc.DB.WithContext(c.UserContext()), &booking, "delivery_phone_send_otp", strconv.FormatUint(uint64(booking.UserID), 10)); err != nil {
 		logger.Error("Failed to write booking event (delivery_phone_send_otp)", err)
 	}
 
 	// Send OTP to the new delivery phone
-	otpSvc := otpService.NewOTPService(bc.DB)
-	otpRecord, err := otpSvc.SendOTPWithBookingID(*booking.DeliveryPhone, req.Purpose, &req.BookingID)
+	otpRecord, err := bc.OTPService.SendOTPWithBookingID(*booking.DeliveryPhone, req.Purpose, &req.BookingID)
 	if err != nil {
 		logger.Error("Failed to send OTP to delivery phone", err)
 
 		// Check if it's a blocking error that should be returned as error response
 		errMsg := err.Error()
 		if errMsg == "OTP requests are blocked permanently due to too many failed attempts" ||
-			(len(errMsg) > 20 && errMsg[:20] == "OTP requests are blocked until") {
+			(len(errMsg) > 20 && errMsg[:20] == "OTP requests are blocked until") || otpService.IsRateLimited(err) {
 			return bc.sendResponseWithLog(c, fiber.StatusTooManyRequests, types.ApiResponse{
 				Status:  fiber.StatusTooManyRequests,
 				Message: err.Error(),
@@ -766,7 +869,7 @@ func (bc *BookingController) DeliveryPhoneSendOtp(c *fiber.Ctx) error {
 			Status:  fiber.StatusInternalServerError,
 			Message: "Failed to send OTP to delivery phone",
 			Data: map[string]interface{}{
-				"booking":   booking,
+				"booking":   bookingTypes.NewBookingResponse(&booking),
 				"otp_error": err.Error(),
 			},
 		})
@@ -775,7 +878,7 @@ func (bc *BookingController) DeliveryPhoneSendOtp(c *fiber.Ctx) error {
 	}
 
 	responseData := map[string]interface{}{
-		"booking": booking,
+		"booking": bookingTypes.NewBookingResponse(&booking),
 	}
 
 	if otpRecord != nil {
@@ -816,7 +919,7 @@ func (bc *BookingController) VerifyDeliveryPhone(c *fiber.Ctx) error {
 
 	// Find the booking
 	var booking bookingModel.Booking
-	if err := bc.DB.First(&booking, req.BookingID).Error; err != nil {
+	if err := bc.DB.Scopes(database.NotDeletedBookings).First(&booking, req.BookingID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -849,17 +952,29 @@ func (bc *BookingController) VerifyDeliveryPhone(c *fiber.Ctx) error {
 		})
 	}
 
+	// A verified phone pre-books the booking, so it must not have moved past that yet
+	if booking.Status != bookingModel.BookingStatusPreBooked {
+		if err := booking.Status.CheckTransition(bookingModel.BookingStatusPreBooked); err != nil {
+			return bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+				Status:  fiber.StatusConflict,
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+	}
+
 	// Verify OTP using OTP service
-	otpSvc := otpService.NewOTPService(bc.DB)
-	isValid, otpRecord, err := otpSvc.VerifyOTPWithDetails(*booking.DeliveryPhone, req.OTPCode, req.Purpose)
+	isValid, otpRecord, err := bc.OTPService.VerifyOTPWithDetails(*booking.DeliveryPhone, req.OTPCode, req.Purpose, booking.ID)
 	if err != nil {
 		logger.Error("Failed to verify OTP", err)
 
 		// If we have an OTP record, we can provide more detailed error information
 		if otpRecord != nil {
+			// Judged by the OTP service's clock, as the verification was
+			now := bc.OTPService.Now()
 			remainingAttempts := otpRecord.MaxRetries - otpRecord.RetryCount
-			isBlocked := otpRecord.IsCurrentlyBlocked()
-			isExpired := otpRecord.IsExpired()
+			isBlocked := otpRecord.IsBlockedAt(now)
+			isExpired := otpRecord.IsExpiredAt(now)
 
 			// Handle OTP expiration separately
 			if isExpired {
@@ -952,39 +1067,15 @@ func (bc *BookingController) VerifyDeliveryPhone(c *fiber.Ctx) error {
 	}
 
 	// Get user authentication information for booking status event
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
+			Message: "User not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding user by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		return bc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
+	userInfo := current.User
 
 	userID := uint(userInfo.ID)
 
@@ -994,7 +1085,7 @@ func (bc *BookingController) VerifyDeliveryPhone(c *fiber.Ctx) error {
 		CreatedBy: strconv.FormatUint(uint64(userID), 10),
 	}
 
-	if err := bc.DB.Create(&bookingStatusEvent).Error; err != nil {
+	if err := bc.DB.WithContext(c.UserContext()).Create(&bookingStatusEvent).Error; err != nil {
 		logger.Error("Failed to create booking status event", err)
 		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -1003,14 +1094,14 @@ func (bc *BookingController) VerifyDeliveryPhone(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := booking_event.SnapshotBookingToEvent(bc.DB, &booking, "phone_applied_verified", strconv.FormatUint(uint64(booking.UserID), 10)); err != nil {
+	if err := booking_event.SnapshotBookingToEvent(bc.DB.WithContext(c.UserContext()), &booking, "phone_applied_verified", strconv.FormatUint(uint64(booking.UserID), 10)); err != nil {
 		logger.Error("Failed to write booking event (phone_applied_verified)", err)
 	}
 
 	logger.Success(fmt.Sprintf("Delivery phone verified for booking ID: %d", booking.ID))
 
 	responseData := map[string]interface{}{
-		"booking":  booking,
+		"booking":  bookingTypes.NewBookingResponse(&booking),
 		"verified": true,
 	}
 
@@ -1044,7 +1135,7 @@ func (bc *BookingController) GetOTPRetryInfo(c *fiber.Ctx) error {
 
 	// Find the booking to validate booking_id and phone match
 	var booking bookingModel.Booking
-	if err := bc.DB.First(&booking, req.BookingID).Error; err != nil {
+	if err := bc.DB.Scopes(database.NotDeletedBookings).First(&booking, req.BookingID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -1078,8 +1169,7 @@ func (bc *BookingController) GetOTPRetryInfo(c *fiber.Ctx) error {
 	}
 
 	// Get retry information from OTP service with the specified purpose
-	otpSvc := otpService.NewOTPService(bc.DB)
-	retryInfo, err := otpSvc.GetOTPRetryInfo(*booking.DeliveryPhone, req.Purpose)
+	retryInfo, err := bc.OTPService.GetOTPRetryInfo(*booking.DeliveryPhone, req.Purpose, booking.ID)
 	if err != nil {
 		logger.Error("Failed to get OTP retry info", err)
 		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
@@ -1123,7 +1213,7 @@ func (bc *BookingController) ResendOTP(c *fiber.Ctx) error {
 
 	// Find the booking to verify the phone number
 	var booking bookingModel.Booking
-	if err := bc.DB.First(&booking, req.BookingID).Error; err != nil {
+	if err := bc.DB.Scopes(database.NotDeletedBookings).First(&booking, req.BookingID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -1175,16 +1265,19 @@ func (bc *BookingController) ResendOTP(c *fiber.Ctx) error {
 		}
 	}
 
+	if handled, err := bc.requireConsent(c, &booking); handled {
+		return err
+	}
+
 	// Resend OTP using OTP service (will update existing unused OTP or create new one)
-	otpSvc := otpService.NewOTPService(bc.DB)
-	otpRecord, err := otpSvc.ResendOTPWithBookingID(*booking.DeliveryPhone, req.Purpose, &req.BookingID)
+	otpRecord, err := bc.OTPService.ResendOTPWithBookingID(*booking.DeliveryPhone, req.Purpose, &req.BookingID)
 	if err != nil {
 		logger.Error("Failed to send OTP", err)
 
 		// Check if it's a blocking error
 		errMsg := err.Error()
 		if errMsg == "OTP requests are blocked permanently due to too many failed attempts" ||
-			len(errMsg) > 20 && errMsg[:20] == "OTP requests are blocked until" {
+			len(errMsg) > 20 && errMsg[:20] == "OTP requests are blocked until" || otpService.IsRateLimited(err) {
 			return bc.sendResponseWithLog(c, fiber.StatusTooManyRequests, types.ApiResponse{
 				Status:  fiber.StatusTooManyRequests,
 				Message: err.Error(),
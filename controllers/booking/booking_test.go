@@ -0,0 +1,262 @@
+package booking
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"passport-booking/constants"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/models/otp"
+	"passport-booking/testutil/factory"
+	"passport-booking/testutil/mock"
+	"passport-booking/testutil/testauth"
+	"passport-booking/testutil/testdb"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// newTestController returns a controller on db. Its request log is never drained,
+// so a test can send at most 100 requests through it.
+func newTestController(db *gorm.DB) *BookingController {
+	asyncLogger := logger.NewAsyncLogger(nil)
+	return &BookingController{DB: db, Logger: asyncLogger, loggerInstance: asyncLogger}
+}
+
+// response is the decoded body of an ApiResponse
+type response struct {
+	Status  int                    `json:"status"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// send posts body, JSON encoded unless it is a string, and decodes the response
+func send(t *testing.T, app *fiber.App, method, path string, body interface{}) (int, response) {
+	t.Helper()
+	raw, ok := body.(string)
+	if !ok {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw = string(encoded)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(raw))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded response
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp.StatusCode, decoded
+}
+
+// TestIndexQueryCount checks that the booking list and its filters run a fixed number
+// of queries, within the route's budget, however many bookings the page holds
+func TestIndexQueryCount(t *testing.T) {
+	const budget = 4
+	today := time.Now().Format("2006-01-02")
+	tests := []struct {
+		name       string
+		permission string
+		query      string
+	}{
+		{"list", constants.PermAgentHasFull, ""},
+		{"status filter", constants.PermAgentHasFull, "status=" + string(bookingModel.BookingStatusInitial)},
+		{"reference search", constants.PermAgentHasFull, "reference=PBK-2026-000001"},
+		{"date range", constants.PermAgentHasFull, "from_date=" + today + "%2000:00:00&to_date=" + today + "%2023:59:59"},
+		{"viewer list", constants.PermViewerReadOnly, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts := make(map[int]int64)
+			for _, size := range []int{1, 20} {
+				tx := testdb.Tx(t)
+				owner := factory.CreateUser(t, tx)
+				for i := 0; i < size; i++ {
+					factory.CreateBooking(t, tx, func(b *bookingModel.Booking) {
+						b.UserID = owner.ID
+					})
+				}
+
+				app := fiber.New()
+				app.Get("/list", testauth.As(owner, tt.permission), middleware.QueryBudget(budget), newTestController(tx).Index)
+
+				resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/list?per_page=50&"+tt.query, nil), -1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.StatusCode != fiber.StatusOK {
+					t.Fatalf("%d bookings: status %d", size, resp.StatusCode)
+				}
+				count, err := strconv.ParseInt(resp.Header.Get("X-Query-Count"), 10, 64)
+				if err != nil {
+					t.Fatalf("%d bookings: bad X-Query-Count: %v", size, err)
+				}
+				if count > budget {
+					t.Errorf("%d bookings: %d queries, over the budget of %d", size, count, budget)
+				}
+				counts[size] = count
+			}
+			if counts[1] != counts[20] {
+				t.Errorf("query count grows with the page: %s", fmt.Sprint(counts))
+			}
+		})
+	}
+}
+
+// TestVerifyDeliveryPhoneRequest covers the rejections made before the booking is loaded
+func TestVerifyDeliveryPhoneRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        interface{}
+		wantMessage string
+	}{
+		{"malformed body", "{", "Invalid request body"},
+		{"missing booking", map[string]interface{}{"purpose": otp.OTPPurposeDeliveryApplyPhone, "otp_code": "123456"}, "booking_id is required"},
+		{"unknown purpose", map[string]interface{}{"booking_id": 1, "purpose": "login", "otp_code": "123456"}, "purpose must be either"},
+		{"short code", map[string]interface{}{"booking_id": 1, "purpose": otp.OTPPurposeDeliveryApplyPhone, "otp_code": "123"}, "otp_code must be exactly 6 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otps := &mock.OTPService{}
+			bc := newTestController(nil)
+			bc.OTPService = otps
+			app := fiber.New()
+			app.Post("/verify", bc.VerifyDeliveryPhone)
+
+			status, resp := send(t, app, fiber.MethodPost, "/verify", tt.body)
+			if status != fiber.StatusBadRequest {
+				t.Errorf("status %d, want 400", status)
+			}
+			if !strings.Contains(resp.Message, tt.wantMessage) {
+				t.Errorf("message %q, want it to contain %q", resp.Message, tt.wantMessage)
+			}
+			if len(otps.Calls()) != 0 {
+				t.Error("OTP verified for an invalid request")
+			}
+		})
+	}
+}
+
+func TestVerifyDeliveryPhone(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name       string
+		booking    func(*bookingModel.Booking)
+		verify     mock.Verification
+		wantStatus int
+		wantError  string
+		wantCalled bool
+		wantStored bool
+	}{
+		{
+			name:       "right code",
+			verify:     mock.Verification{OK: true, Record: &otp.OTP{OTPCode: "123456", MaxRetries: 3, ExpiresAt: future}},
+			wantStatus: fiber.StatusOK,
+			wantCalled: true,
+			wantStored: true,
+		},
+		{
+			name:       "wrong code",
+			verify:     mock.Verification{Record: &otp.OTP{RetryCount: 1, MaxRetries: 3, ExpiresAt: future}, Err: errors.New("invalid OTP. 2 attempts remaining")},
+			wantStatus: fiber.StatusBadRequest,
+			wantError:  "OTP_INVALID",
+			wantCalled: true,
+		},
+		{
+			name:       "expired code",
+			verify:     mock.Verification{Record: &otp.OTP{MaxRetries: 3, ExpiresAt: time.Now().Add(-time.Minute)}, Err: errors.New("OTP has expired")},
+			wantStatus: fiber.StatusBadRequest,
+			wantError:  "OTP_EXPIRED",
+			wantCalled: true,
+		},
+		{
+			name:       "blocked code",
+			verify:     mock.Verification{Record: &otp.OTP{RetryCount: 3, MaxRetries: 3, IsBlocked: true, BlockedUntil: &future, ExpiresAt: future}, Err: errors.New("OTP verification is blocked")},
+			wantStatus: fiber.StatusTooManyRequests,
+			wantError:  "OTP_BLOCKED",
+			wantCalled: true,
+		},
+		{
+			name:       "OTP service failure",
+			verify:     mock.Verification{Err: errors.New("database is down")},
+			wantStatus: fiber.StatusInternalServerError,
+			wantCalled: true,
+		},
+		{
+			name:       "no delivery phone",
+			booking:    func(b *bookingModel.Booking) { b.DeliveryPhone = nil },
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "phone already verified",
+			booking:    func(b *bookingModel.Booking) { b.DeliveryPhoneAppliedVerified = true },
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "booking already booked",
+			booking:    func(b *bookingModel.Booking) { b.Status = bookingModel.BookingStatusBooked },
+			wantStatus: fiber.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := testdb.Tx(t)
+			deliveryPhone := "+8801911111111"
+			booking := factory.CreateBooking(t, tx, func(b *bookingModel.Booking) {
+				b.DeliveryPhone = &deliveryPhone
+				if tt.booking != nil {
+					tt.booking(b)
+				}
+			})
+
+			otps := &mock.OTPService{Verify: tt.verify}
+			bc := newTestController(tx)
+			bc.OTPService = otps
+			app := fiber.New()
+			app.Post("/verify", testauth.As(&booking.User, constants.PermCustomerFull), bc.VerifyDeliveryPhone)
+
+			status, resp := send(t, app, fiber.MethodPost, "/verify", map[string]interface{}{
+				"booking_id": booking.ID,
+				"purpose":    otp.OTPPurposeDeliveryApplyPhone,
+				"otp_code":   "123456",
+			})
+			if status != tt.wantStatus {
+				t.Fatalf("status %d, want %d (%s)", status, tt.wantStatus, resp.Message)
+			}
+			if tt.wantError != "" && resp.Data["error"] != tt.wantError {
+				t.Errorf("error %v, want %s", resp.Data["error"], tt.wantError)
+			}
+
+			calls := otps.Calls()
+			if called := len(calls) > 0; called != tt.wantCalled {
+				t.Fatalf("OTP service called = %v, want %v", called, tt.wantCalled)
+			}
+			if tt.wantCalled && (calls[0].Phone != deliveryPhone || calls[0].Code != "123456") {
+				t.Errorf("verified %s for %s, want 123456 for the delivery phone", calls[0].Code, calls[0].Phone)
+			}
+
+			var stored bookingModel.Booking
+			if err := tx.First(&stored, booking.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.DeliveryPhoneAppliedVerified != (tt.wantStored || booking.DeliveryPhoneAppliedVerified) {
+				t.Errorf("delivery_phone_applied_verified = %v", stored.DeliveryPhoneAppliedVerified)
+			}
+			if tt.wantStored && stored.Status != bookingModel.BookingStatusPreBooked {
+				t.Errorf("status %s, want %s", stored.Status, bookingModel.BookingStatusPreBooked)
+			}
+		})
+	}
+}
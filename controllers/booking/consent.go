@@ -0,0 +1,143 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/database"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/consent"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RecordDeliveryPhoneConsent stores the applicant's consent to receive OTPs and
+// delivery messages on the booking's delivery phone
+func (bc *BookingController) RecordDeliveryPhoneConsent(c *fiber.Ctx) error {
+	var req bookingTypes.DeliveryPhoneConsentRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	// Consent is only valid for the wording currently shown to applicants
+	if req.TextVersion != consent.TextVersion() {
+		return bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Consent text version is outdated",
+			Data: map[string]interface{}{
+				"text_version": consent.TextVersion(),
+			},
+		})
+	}
+
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	userInfo := current.User
+
+	var booking bookingModel.Booking
+	if err := bc.DB.Scopes(database.NotDeletedBookings).First(&booking, req.BookingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find booking", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if booking.UserID != userInfo.ID {
+		return bc.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+			Status:  fiber.StatusForbidden,
+			Message: "You don't have permission to update this booking",
+			Data:    nil,
+		})
+	}
+
+	if booking.DeliveryPhone == nil || *booking.DeliveryPhone == "" {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "No delivery phone found for this booking",
+			Data:    nil,
+		})
+	}
+
+	actorID := strconv.FormatUint(uint64(userInfo.ID), 10)
+	record, err := consent.Record(bc.DB, &booking, req.Channel, req.TextVersion, actorID, c.IP(), c.Get(fiber.HeaderUserAgent))
+	if err != nil {
+		logger.Error("Failed to record delivery phone consent", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to record consent",
+			Data:    nil,
+		})
+	}
+
+	if err := booking_event.SnapshotBookingToEvent(bc.DB.WithContext(c.UserContext()), &booking, "delivery_phone_consent_recorded", actorID); err != nil {
+		logger.Error("Failed to write booking event (delivery_phone_consent_recorded)", err)
+	}
+
+	logger.Success(fmt.Sprintf("Delivery phone consent recorded for booking ID: %d (version %s, channel %s)", booking.ID, record.TextVersion, record.Channel))
+
+	return bc.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Consent recorded successfully",
+		Data:    bookingTypes.NewPhoneConsentResponse(record),
+	})
+}
+
+// requireConsent answers 428 when the booking's delivery phone has no recorded consent.
+// It returns handled=false when the caller may go on sending the OTP.
+func (bc *BookingController) requireConsent(c *fiber.Ctx, booking *bookingModel.Booking) (bool, error) {
+	_, err := consent.Active(bc.DB, booking)
+	if err == nil {
+		return false, nil
+	}
+	if errors.Is(err, consent.ErrConsentRequired) {
+		return true, bc.sendResponseWithLog(c, fiber.StatusPreconditionRequired, types.ApiResponse{
+			Status:  fiber.StatusPreconditionRequired,
+			Message: "Delivery phone consent is required before sending OTP",
+			Data: map[string]interface{}{
+				"code":         "CONSENT_REQUIRED",
+				"text_version": consent.TextVersion(),
+			},
+		})
+	}
+
+	logger.Error("Failed to check delivery phone consent", err)
+	return true, bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+		Status:  fiber.StatusInternalServerError,
+		Message: "Internal server error",
+		Data:    nil,
+	})
+}
@@ -0,0 +1,160 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_deletion"
+	"passport-booking/services/dms_outbox"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// deletionRequest parses the booking ID and the reason of a delete or restore
+func (bc *BookingController) deletionRequest(c *fiber.Ctx) (*bookingModel.Booking, *bookingTypes.BookingDeletionRequest, error) {
+	bookingID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, nil, bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid booking ID",
+			Data:    nil,
+		})
+	}
+
+	var req bookingTypes.BookingDeletionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, nil, bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return nil, nil, bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var booking bookingModel.Booking
+	if err := bc.DB.First(&booking, bookingID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find booking", err)
+		return nil, nil, bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+	return &booking, &req, nil
+}
+
+// deletionConflict answers a delete or restore the booking's state does not allow;
+// it returns false for other errors
+func (bc *BookingController) deletionConflict(c *fiber.Ctx, err error) (bool, error) {
+	var invalid *booking_deletion.ValidationError
+	switch {
+	case errors.As(err, &invalid),
+		errors.Is(err, booking_deletion.ErrAlreadyDeleted),
+		errors.Is(err, booking_deletion.ErrNotDeleted),
+		errors.Is(err, booking_deletion.ErrWindowPassed):
+	case errors.Is(err, dms_outbox.ErrInProgress):
+		err = fmt.Errorf("%w, please retry shortly", err)
+	default:
+		return false, nil
+	}
+	return true, bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+		Status:  fiber.StatusConflict,
+		Message: err.Error(),
+		Data:    nil,
+	})
+}
+
+// DeleteBooking soft-deletes a booking created or cancelled by mistake. It can be
+// restored within the restore window.
+func (bc *BookingController) DeleteBooking(c *fiber.Ctx) error {
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+	booking, req, err := bc.deletionRequest(c)
+	if booking == nil {
+		return err
+	}
+
+	deletion, err := booking_deletion.Delete(bc.DB.WithContext(c.UserContext()), booking, req.Reason, userInfo.ID)
+	if err != nil {
+		if handled, err := bc.deletionConflict(c, err); handled {
+			return err
+		}
+		logger.Error(fmt.Sprintf("Failed to delete booking %d", booking.ID), err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to delete booking",
+			Data:    nil,
+		})
+	}
+
+	logger.Warning(fmt.Sprintf("Booking %d deleted by %s: %s", booking.ID, userInfo.LegalName, req.Reason))
+
+	restorableUntil := deletion.DeletedAt.Add(booking_deletion.Window())
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Booking deleted successfully",
+		Data: bookingTypes.BookingDeletionResponse{
+			Booking:         bookingTypes.NewBookingResponse(booking),
+			Deletion:        deletion,
+			RestorableUntil: &restorableUntil,
+		},
+	})
+}
+
+// RestoreBooking brings back a deleted booking and replays the DMS intake held while
+// it was deleted
+func (bc *BookingController) RestoreBooking(c *fiber.Ctx) error {
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+	booking, req, err := bc.deletionRequest(c)
+	if booking == nil {
+		return err
+	}
+
+	deletion, err := booking_deletion.Restore(bc.DB.WithContext(c.UserContext()), booking, req.Reason, userInfo.ID)
+	if err != nil {
+		if handled, err := bc.deletionConflict(c, err); handled {
+			return err
+		}
+		logger.Error(fmt.Sprintf("Failed to restore booking %d", booking.ID), err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to restore booking",
+			Data:    nil,
+		})
+	}
+
+	logger.Success(fmt.Sprintf("Booking %d restored by %s", booking.ID, userInfo.LegalName))
+
+	response := bookingTypes.BookingDeletionResponse{Booking: bookingTypes.NewBookingResponse(booking)}
+	if deletion.ID != 0 {
+		response.Deletion = deletion
+	}
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Booking restored successfully",
+		Data:    response,
+	})
+}
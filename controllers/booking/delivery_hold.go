@@ -0,0 +1,204 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/database"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/delivery_hold"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// loadOwnBooking loads a booking and checks it belongs to userID
+func (bc *BookingController) loadOwnBooking(c *fiber.Ctx, bookingID, userID uint) (*bookingModel.Booking, error) {
+	var booking bookingModel.Booking
+	if err := bc.DB.Scopes(database.NotDeletedBookings).First(&booking, bookingID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find booking", err)
+		return nil, bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if booking.UserID != userID {
+		return nil, bc.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+			Status:  fiber.StatusForbidden,
+			Message: "You don't have permission to update this booking",
+			Data:    nil,
+		})
+	}
+	return &booking, nil
+}
+
+// CreateDeliveryHold keeps the applicant's item at the branch for the requested dates
+func (bc *BookingController) CreateDeliveryHold(c *fiber.Ctx) error {
+	var req bookingTypes.DeliveryHoldRequest
+	if err := c.BodyParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	booking, err := bc.loadOwnBooking(c, req.BookingID, userInfo.ID)
+	if booking == nil {
+		return err
+	}
+
+	var reason *string
+	if req.Reason != "" {
+		reason = &req.Reason
+	}
+	hold, err := bc.Holds.WithContext(c.UserContext()).Create(booking, req.StartsAt, req.EndsAt, reason, userInfo.ID)
+	if err != nil {
+		var validationErr *delivery_hold.ValidationError
+		if errors.As(err, &validationErr) {
+			return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: validationErr.Error(),
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to create delivery hold", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to create delivery hold",
+			Data:    nil,
+		})
+	}
+
+	logger.Success(fmt.Sprintf("Delivery hold %d created for booking ID: %d", hold.ID, booking.ID))
+
+	return bc.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Delivery hold created successfully",
+		Data:    hold,
+	})
+}
+
+// CancelDeliveryHold withdraws a hold; a hold already in effect ends immediately
+func (bc *BookingController) CancelDeliveryHold(c *fiber.Ctx) error {
+	holdID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid delivery hold ID",
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	var hold bookingModel.DeliveryHold
+	if err := bc.DB.First(&hold, holdID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Delivery hold not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find delivery hold", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	booking, err := bc.loadOwnBooking(c, hold.BookingID, userInfo.ID)
+	if booking == nil {
+		return err
+	}
+
+	if err := bc.Holds.WithContext(c.UserContext()).Cancel(&hold, booking, userInfo.ID); err != nil {
+		if errors.Is(err, delivery_hold.ErrHoldNotActive) {
+			return bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+				Status:  fiber.StatusConflict,
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to cancel delivery hold", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to cancel delivery hold",
+			Data:    nil,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delivery hold canceled successfully",
+		Data:    hold,
+	})
+}
+
+// ListDeliveryHolds returns the holds placed on a booking, newest first
+func (bc *BookingController) ListDeliveryHolds(c *fiber.Ctx) error {
+	bookingID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid booking ID",
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	booking, err := bc.loadOwnBooking(c, uint(bookingID), userInfo.ID)
+	if booking == nil {
+		return err
+	}
+
+	var holds []bookingModel.DeliveryHold
+	if err := bc.DB.Where("booking_id = ?", booking.ID).Order("starts_at DESC").Find(&holds).Error; err != nil {
+		logger.Error("Failed to fetch delivery holds", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch delivery holds",
+			Data:    nil,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delivery holds retrieved successfully",
+		Data:    holds,
+	})
+}
@@ -0,0 +1,90 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/signed_url"
+	"passport-booking/services/storage"
+	"passport-booking/types"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// proofURL returns a signed link to the delivery photo when the booking has one and
+// the caller owns the booking
+func (bc *BookingController) proofURL(c *fiber.Ctx, booking *bookingModel.Booking) (*string, *time.Time) {
+	if booking.UploadPhoto == nil || *booking.UploadPhoto == "" || booking.Barcode == nil {
+		return nil, nil
+	}
+	if middleware.CurrentUserID(c) != booking.UserID {
+		return nil, nil
+	}
+
+	link, expiresAt, err := signed_url.URL(signed_url.KindDeliveryPhoto, *booking.Barcode, time.Now())
+	if err != nil {
+		return nil, nil
+	}
+	return &link, &expiresAt
+}
+
+// ProofFile serves a proof file through a signed link. It needs no login: the
+// signature is the authorisation and expires with the link.
+func (bc *BookingController) ProofFile(c *fiber.Ctx) error {
+	kind := signed_url.Kind(c.Params("kind"))
+	ref := c.Params("ref")
+	if !kind.IsValid() {
+		return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "File not found",
+			Data:    nil,
+		})
+	}
+
+	if err := signed_url.Verify(kind, ref, c.Query("expires"), c.Query("sig"), time.Now()); err != nil {
+		status := fiber.StatusForbidden
+		switch {
+		case errors.Is(err, signed_url.ErrExpired):
+			status = fiber.StatusGone
+		case errors.Is(err, signed_url.ErrDisabled):
+			status = fiber.StatusNotFound
+		}
+		return bc.sendResponseWithLog(c, status, types.ApiResponse{
+			Status:  status,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var booking bookingModel.Booking
+	if err := bc.DB.Select("id", "upload_photo").Where("barcode = ?", ref).First(&booking).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch booking",
+			Data:    nil,
+		})
+	}
+	photos := storage.NewLocalStorage(storage.DeliveryPhotoDir)
+	if booking.UploadPhoto == nil || *booking.UploadPhoto == "" || !photos.Exists(*booking.UploadPhoto) {
+		return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "File not found",
+			Data:    nil,
+		})
+	}
+
+	// Browsers may keep the file until the link expires, but shared caches may not
+	// and the link must not leak through the Referer header
+	maxAge := int64(0)
+	if exp, err := strconv.ParseInt(c.Query("expires"), 10, 64); err == nil && exp > time.Now().Unix() {
+		maxAge = exp - time.Now().Unix()
+	}
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("private, max-age=%d", maxAge))
+	c.Set("Referrer-Policy", "no-referrer")
+	bc.logAPIRequest(c)
+	return c.SendFile(*booking.UploadPhoto)
+}
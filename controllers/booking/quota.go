@@ -0,0 +1,260 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/booking_quota"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// setQuotaHeaders reports the tightest booking quota of the caller
+func setQuotaHeaders(c *fiber.Ctx, usage *booking_quota.Usage) {
+	if usage == nil {
+		return
+	}
+	c.Set("X-Booking-Quota-Scope", string(usage.Scope))
+	c.Set("X-Booking-Quota-Limit", strconv.Itoa(usage.Limit))
+	c.Set("X-Booking-Quota-Used", strconv.FormatInt(usage.Used, 10))
+	c.Set("X-Booking-Quota-Remaining", strconv.FormatInt(usage.Remaining, 10))
+	c.Set("X-Booking-Quota-Reset", usage.ResetAt.Format(time.RFC3339))
+}
+
+// quotaSubject reads the scope and subject of a quota from the path. User subjects
+// must be the ID of a local user.
+func (bc *BookingController) quotaSubject(c *fiber.Ctx) (bookingModel.QuotaScope, string, error) {
+	scope := bookingModel.QuotaScope(c.Params("scope"))
+	subject := strings.TrimSpace(c.Params("subject"))
+	if !scope.IsValid() {
+		return "", "", bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "scope must be either 'user' or 'branch'",
+			Data:    nil,
+		})
+	}
+	if scope == bookingModel.QuotaScopeBranch {
+		if subject == "" {
+			return "", "", bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Branch code is required",
+				Data:    nil,
+			})
+		}
+		return scope, subject, nil
+	}
+
+	userID, err := strconv.ParseUint(subject, 10, 64)
+	if err != nil || userID == 0 {
+		return "", "", bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "User quotas are addressed by user ID",
+			Data:    nil,
+		})
+	}
+	var count int64
+	if err := bc.DB.Model(&userModel.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+		logger.Error("Failed to look up quota user", err)
+		return "", "", bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Database error",
+			Data:    nil,
+		})
+	}
+	if count == 0 {
+		return "", "", bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "User not found",
+			Data:    nil,
+		})
+	}
+	return scope, strconv.FormatUint(userID, 10), nil
+}
+
+// quotaResponse loads a quota row, if any, with today's usage
+func (bc *BookingController) quotaResponse(scope bookingModel.QuotaScope, subject string) (*bookingTypes.BookingQuotaResponse, error) {
+	response := &bookingTypes.BookingQuotaResponse{
+		DefaultLimit: booking_quota.DefaultLimit(scope),
+		Enforced:     booking_quota.Enforced(),
+	}
+	var quota bookingModel.BookingQuota
+	err := bc.DB.Where("scope = ? AND subject = ?", scope, subject).First(&quota).Error
+	if err == nil {
+		response.Quota = &quota
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if response.Usage, err = booking_quota.UsageOf(bc.DB, scope, subject, time.Now()); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// ListBookingQuotas lists the quotas set by admins, optionally of one scope
+func (bc *BookingController) ListBookingQuotas(c *fiber.Ctx) error {
+	query := bc.DB.Order("scope, subject")
+	if scope := bookingModel.QuotaScope(c.Query("scope")); scope != "" {
+		if !scope.IsValid() {
+			return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "scope must be either 'user' or 'branch'",
+				Data:    nil,
+			})
+		}
+		query = query.Where("scope = ?", scope)
+	}
+
+	quotas := []bookingModel.BookingQuota{}
+	if err := query.Find(&quotas).Error; err != nil {
+		logger.Error("Failed to list booking quotas", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to list booking quotas",
+			Data:    nil,
+		})
+	}
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Booking quotas fetched successfully",
+		Data: fiber.Map{
+			"defaults": fiber.Map{
+				"user":   booking_quota.DefaultLimit(bookingModel.QuotaScopeUser),
+				"branch": booking_quota.DefaultLimit(bookingModel.QuotaScopeBranch),
+			},
+			"enforced": booking_quota.Enforced(),
+			"quotas":   quotas,
+		},
+	})
+}
+
+// ShowBookingQuota returns the quota in effect for a user or branch and its usage today
+func (bc *BookingController) ShowBookingQuota(c *fiber.Ctx) error {
+	scope, subject, err := bc.quotaSubject(c)
+	if scope == "" {
+		return err
+	}
+
+	response, err := bc.quotaResponse(scope, subject)
+	if err != nil {
+		logger.Error("Failed to load booking quota", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to load booking quota",
+			Data:    nil,
+		})
+	}
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Booking quota fetched successfully",
+		Data:    response,
+	})
+}
+
+// SetBookingQuota sets the daily limit of a user or branch and, optionally, a
+// temporary override of it
+func (bc *BookingController) SetBookingQuota(c *fiber.Ctx) error {
+	scope, subject, err := bc.quotaSubject(c)
+	if scope == "" {
+		return err
+	}
+
+	var req bookingTypes.SetBookingQuotaRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(time.Now()); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	adminID := middleware.CurrentUserID(c)
+	quota := bookingModel.BookingQuota{
+		Scope:         scope,
+		Subject:       subject,
+		DailyLimit:    req.DailyLimit,
+		OverrideLimit: req.OverrideLimit,
+		OverrideUntil: req.OverrideEndsAt,
+		UpdatedByID:   adminID,
+	}
+	if req.Reason != "" {
+		quota.OverrideReason = &req.Reason
+	}
+	if err := bc.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "scope"}, {Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"daily_limit", "override_limit", "override_until", "override_reason", "updated_by_id", "updated_at",
+		}),
+	}).Create(&quota).Error; err != nil {
+		logger.Error("Failed to save booking quota", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to save booking quota",
+			Data:    nil,
+		})
+	}
+	logger.Info(fmt.Sprintf("Booking quota of %s %s set by user %d", scope, subject, adminID))
+
+	response, err := bc.quotaResponse(scope, subject)
+	if err != nil {
+		logger.Error("Failed to load booking quota", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Booking quota saved but could not be reloaded",
+			Data:    nil,
+		})
+	}
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Booking quota saved successfully",
+		Data:    response,
+	})
+}
+
+// DeleteBookingQuota drops the quota of a user or branch so the default applies again
+func (bc *BookingController) DeleteBookingQuota(c *fiber.Ctx) error {
+	scope, subject, err := bc.quotaSubject(c)
+	if scope == "" {
+		return err
+	}
+
+	result := bc.DB.Where("scope = ? AND subject = ?", scope, subject).Delete(&bookingModel.BookingQuota{})
+	if result.Error != nil {
+		logger.Error("Failed to delete booking quota", result.Error)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to delete booking quota",
+			Data:    nil,
+		})
+	}
+	if result.RowsAffected == 0 {
+		return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "No quota is set for this " + string(scope),
+			Data:    nil,
+		})
+	}
+	logger.Info(fmt.Sprintf("Booking quota of %s %s removed by user %d", scope, subject, middleware.CurrentUserID(c)))
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Booking quota removed; the default applies again",
+		Data:    nil,
+	})
+}
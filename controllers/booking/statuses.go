@@ -0,0 +1,25 @@
+package booking
+
+import (
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/models/parcel_booking"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Statuses returns the valid booking and parcel booking statuses with the
+// transitions between them, so clients do not hard-code them
+func (bc *BookingController) Statuses(c *fiber.Ctx) error {
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Statuses fetched successfully",
+		Data: fiber.Map{
+			"booking": bookingTypes.NewStatusWorkflow(
+				bookingModel.GetAllBookingStatuses(), bookingModel.BookingStatusTransitions()),
+			"parcel_booking": bookingTypes.NewStatusWorkflow(
+				parcel_booking.GetAllParcelBookingStatuses(), parcel_booking.ParcelBookingStatusTransitions()),
+		},
+	})
+}
@@ -0,0 +1,158 @@
+package booking
+
+import (
+	"encoding/base64"
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"passport-booking/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Track returns the status history of a booking by barcode
+func (bc *BookingController) Track(c *fiber.Ctx) error {
+	barcode := c.Params("barcode")
+	if barcode == "" {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Barcode is required",
+			Data:    nil,
+		})
+	}
+
+	var booking bookingModel.Booking
+	if err := booking_resolver.Find(bc.DB, barcode, bookingTypes.IdentifierTypeBarcode, &booking); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to fetch booking for tracking", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch booking",
+			Data:    nil,
+		})
+	}
+
+	var statusEvents []bookingModel.BookingStatusEvent
+	if err := bc.DB.Where("booking_id = ?", booking.ID).Order("created_at ASC").Find(&statusEvents).Error; err != nil {
+		logger.Error("Failed to fetch booking status events for tracking", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch tracking history",
+			Data:    nil,
+		})
+	}
+
+	// The owner also gets a short-lived link to the delivery photo; its expiry is part
+	// of the ETag so a cached response never holds an expired link
+	photoURL, photoURLExpiresAt := bc.proofURL(c, &booking)
+	photoExpiry := ""
+	if photoURLExpiresAt != nil {
+		photoExpiry = strconv.FormatInt(photoURLExpiresAt.Unix(), 10)
+	}
+
+	etag := utils.GenerateETag(fmt.Sprintf("track:%d", booking.ID), booking.UpdatedAt, strconv.Itoa(len(statusEvents)), photoExpiry)
+	if utils.CheckETag(c, etag) {
+		c.Status(fiber.StatusNotModified)
+		bc.logAPIRequest(c)
+		return nil
+	}
+
+	events := make([]bookingTypes.TrackingEventResponse, 0, len(statusEvents))
+	for _, event := range statusEvents {
+		events = append(events, bookingTypes.TrackingEventResponse{
+			Status:    event.Status,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Tracking information fetched successfully",
+		Data: bookingTypes.TrackingResponse{
+			Barcode:      barcode,
+			AppOrOrderID: booking.AppOrOrderID,
+			Status:       booking.Status,
+			UpdatedAt:    booking.UpdatedAt,
+
+			ExpectedDispatchDate: booking.ExpectedDispatchDate,
+			BaggedAfterCutoff:    booking.BaggedAfterCutoff,
+			Events:               events,
+
+			DeliveryPhotoURL:          photoURL,
+			DeliveryPhotoURLExpiresAt: photoURLExpiresAt,
+		},
+	})
+}
+
+// TrackQR returns a QR code encoding the tracking URL of a booking, as a PNG
+// image (?format=png) or a base64 data URI (default)
+func (bc *BookingController) TrackQR(c *fiber.Ctx) error {
+	barcode := utils.NormalizeBarcode(c.Params("barcode"))
+	if err := utils.ValidateBarcode(barcode); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var booking bookingModel.Booking
+	if err := booking_resolver.Find(bc.DB, barcode, bookingTypes.IdentifierTypeBarcode, &booking); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to fetch booking for QR code", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch booking",
+			Data:    nil,
+		})
+	}
+
+	size := c.QueryInt("size", 256)
+	if size < 64 || size > 1024 {
+		size = 256
+	}
+	trackingURL := utils.TrackingURL(barcode)
+
+	png, err := utils.GenerateQRCodePNG(trackingURL, size)
+	if err != nil {
+		logger.Error("Failed to generate QR code", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to generate QR code",
+			Data:    nil,
+		})
+	}
+
+	if c.Query("format") == "png" {
+		c.Set(fiber.HeaderContentType, "image/png")
+		bc.logAPIRequest(c)
+		return c.Status(fiber.StatusOK).Send(png)
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "QR code generated successfully",
+		Data: fiber.Map{
+			"barcode":      barcode,
+			"tracking_url": trackingURL,
+			"qr_code":      "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		},
+	})
+}
@@ -0,0 +1,53 @@
+package booking
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"passport-booking/utils"
+)
+
+// BenchmarkTrackingResponse is the tracking read after its two queries: ETag,
+// response building and JSON encoding of a booking with a typical history
+func BenchmarkTrackingResponse(b *testing.B) {
+	barcode := "EB123456789BD"
+	booking := bookingModel.Booking{
+		ID:           42,
+		AppOrOrderID: "OID1234567890",
+		Barcode:      &barcode,
+		Status:       bookingModel.BookingStatusBooked,
+		UpdatedAt:    time.Now(),
+	}
+	statusEvents := make([]bookingModel.BookingStatusEvent, 8)
+	for i := range statusEvents {
+		statusEvents[i] = bookingModel.BookingStatusEvent{BookingID: booking.ID, Status: bookingModel.BookingStatusBooked, CreatedAt: time.Now()}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = utils.GenerateETag(fmt.Sprintf("track:%d", booking.ID), booking.UpdatedAt, fmt.Sprint(len(statusEvents)))
+		events := make([]bookingTypes.TrackingEventResponse, 0, len(statusEvents))
+		for _, event := range statusEvents {
+			events = append(events, bookingTypes.TrackingEventResponse{Status: event.Status, CreatedAt: event.CreatedAt})
+		}
+		if _, err := json.Marshal(types.ApiResponse{
+			Status:  200,
+			Message: "Tracking information fetched successfully",
+			Data: bookingTypes.TrackingResponse{
+				Barcode:      *booking.Barcode,
+				AppOrOrderID: booking.AppOrOrderID,
+				Status:       booking.Status,
+				UpdatedAt:    booking.UpdatedAt,
+				Events:       events,
+			},
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
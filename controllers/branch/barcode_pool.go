@@ -0,0 +1,102 @@
+package branch
+
+import (
+	"fmt"
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	barcodeService "passport-booking/services/barcode"
+	"passport-booking/types"
+	branchTypes "passport-booking/types/branch"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListBarcodePools returns the pre-allocated barcode stock of every branch
+func (bc *BranchController) ListBarcodePools(c *fiber.Ctx) error {
+	levels, err := barcodeService.Levels(bc.DB)
+	if err != nil {
+		logger.Error("Failed to fetch barcode pool levels", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch barcode pools",
+			Data:    nil,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Barcode pools retrieved successfully",
+		Data: map[string]interface{}{
+			"target_size": barcodeService.PoolSize(),
+			"branches":    barcodeService.PoolBranches(),
+			"pools":       levels,
+		},
+	})
+}
+
+// RefillBarcodePool fetches barcodes from DMS into the pool of a branch with the
+// caller's DMS credentials
+func (bc *BranchController) RefillBarcodePool(c *fiber.Ctx) error {
+	branchCode, err := bc.branchCode(c)
+	if branchCode == "" {
+		return err
+	}
+
+	var req branchTypes.RefillPoolRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid request body",
+				Data:    nil,
+			})
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	service := barcodeService.NewBarcodeService(bc.DB, dms.NewDMSService())
+	authHeader := c.Get("Authorization")
+	var added int
+	if req.Count > 0 {
+		added, err = service.Refill(authHeader, branchCode, req.Count)
+	} else {
+		added, err = service.TopUp(authHeader, branchCode)
+	}
+	available, countErr := barcodeService.Available(bc.DB, branchCode)
+	if countErr != nil {
+		logger.Error("Failed to count pooled barcodes", countErr)
+	}
+	logger.Info(fmt.Sprintf("%s added %d barcodes to the pool of branch %s", userInfo.Username, added, branchCode))
+
+	data := map[string]interface{}{
+		"branch_code": branchCode,
+		"added":       added,
+		"available":   available,
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Barcode pool refill of branch %s stopped early", branchCode), err)
+		return bc.sendResponseWithLog(c, fiber.StatusBadGateway, types.ApiResponse{
+			Status:  fiber.StatusBadGateway,
+			Message: fmt.Sprintf("Refill stopped after %d barcodes: DMS request failed", added),
+			Data:    data,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Barcode pool refilled successfully",
+		Data:    data,
+	})
+}
@@ -0,0 +1,389 @@
+package branch
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/branch_schedule"
+	"passport-booking/services/stock_audit"
+	"passport-booking/types"
+	branchTypes "passport-booking/types/branch"
+	"passport-booking/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BranchController handles branch stock-takes and the incidents they raise
+type BranchController struct {
+	DB             *gorm.DB
+	Logger         *logger.AsyncLogger
+	loggerInstance *logger.AsyncLogger
+}
+
+// NewBranchController creates a new branch controller
+func NewBranchController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *BranchController {
+	return &BranchController{
+		DB:             db,
+		Logger:         asyncLogger,
+		loggerInstance: asyncLogger,
+	}
+}
+
+// Helper function to log API requests and responses
+func (bc *BranchController) logAPIRequest(c *fiber.Ctx) {
+	logEntry := utils.CreateSanitizedLogEntry(c)
+	bc.loggerInstance.Log(logEntry)
+}
+
+// Helper function to send response and log in one call
+func (bc *BranchController) sendResponseWithLog(c *fiber.Ctx, status int, response types.ApiResponse) error {
+	result := c.Status(status).JSON(response)
+	bc.logAPIRequest(c)
+	return result
+}
+
+// currentUser resolves the authenticated user, responding with an error when it cannot
+func (bc *BranchController) currentUser(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, bc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// branchCode reads the :code route param, responding with 400 when it is empty
+func (bc *BranchController) branchCode(c *fiber.Ctx) (string, error) {
+	code := strings.TrimSpace(c.Params("code"))
+	if code == "" {
+		return "", bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Branch code is required",
+			Data:    nil,
+		})
+	}
+	return code, nil
+}
+
+// CreateStockAudit reconciles the barcodes scanned on the branch shelf against the
+// items the branch should hold, opening an incident for every discrepancy
+func (bc *BranchController) CreateStockAudit(c *fiber.Ctx) error {
+	branchCode, err := bc.branchCode(c)
+	if branchCode == "" {
+		return err
+	}
+
+	var req branchTypes.StockAuditRequest
+	if err := c.BodyParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	var note *string
+	if req.Note != "" {
+		note = &req.Note
+	}
+	result, err := stock_audit.Run(bc.DB.WithContext(c.UserContext()), branchCode, req.Barcodes, note, userInfo.ID)
+	if err != nil {
+		logger.Error("Failed to run stock audit", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to run stock audit",
+			Data:    nil,
+		})
+	}
+
+	logger.Success(fmt.Sprintf("Stock audit %d at branch %s: %d matched, %d missing, %d unexpected",
+		result.Audit.ID, branchCode, result.Audit.MatchedCount, result.Audit.MissingCount, result.Audit.UnexpectedCount))
+
+	return bc.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Stock audit completed successfully",
+		Data: branchTypes.StockAuditResponse{
+			Audit:      result.Audit,
+			Missing:    result.Missing,
+			Unexpected: result.Unexpected,
+			Recovered:  result.Recovered,
+		},
+	})
+}
+
+// ShowStockAudit returns an audit with the incidents it opened
+func (bc *BranchController) ShowStockAudit(c *fiber.Ctx) error {
+	branchCode, err := bc.branchCode(c)
+	if branchCode == "" {
+		return err
+	}
+
+	auditID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid stock audit ID",
+			Data:    nil,
+		})
+	}
+
+	audit, err := stock_audit.Find(bc.DB, uint(auditID))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Error("Failed to find stock audit", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+	if audit == nil || audit.BranchCode != branchCode {
+		return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Stock audit not found",
+			Data:    nil,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Stock audit retrieved successfully",
+		Data:    audit,
+	})
+}
+
+// ListIncidents returns the stock incidents of a branch, newest first
+func (bc *BranchController) ListIncidents(c *fiber.Ctx) error {
+	branchCode, err := bc.branchCode(c)
+	if branchCode == "" {
+		return err
+	}
+
+	var req branchTypes.IncidentListRequest
+	if err := c.QueryParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query := bc.DB.Where("branch_code = ?", branchCode)
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.Kind != "" {
+		query = query.Where("kind = ?", req.Kind)
+	}
+
+	var incidents []bookingModel.StockIncident
+	if err := query.Order("created_at DESC").Find(&incidents).Error; err != nil {
+		logger.Error("Failed to fetch stock incidents", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch stock incidents",
+			Data:    nil,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Stock incidents retrieved successfully",
+		Data:    incidents,
+	})
+}
+
+// ResolveIncident closes a stock incident with the outcome of its investigation
+func (bc *BranchController) ResolveIncident(c *fiber.Ctx) error {
+	incidentID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid incident ID",
+			Data:    nil,
+		})
+	}
+
+	var req branchTypes.ResolveIncidentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	var incident bookingModel.StockIncident
+	if err := bc.DB.First(&incident, incidentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Stock incident not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find stock incident", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if err := stock_audit.Resolve(bc.DB.WithContext(c.UserContext()), &incident, req.Note, userInfo.ID); err != nil {
+		if errors.Is(err, stock_audit.ErrIncidentClosed) {
+			return bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+				Status:  fiber.StatusConflict,
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to resolve stock incident", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to resolve stock incident",
+			Data:    nil,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Stock incident resolved successfully",
+		Data:    incident,
+	})
+}
+
+// ShowSchedule returns the operating hours and dispatch cutoff of a branch
+func (bc *BranchController) ShowSchedule(c *fiber.Ctx) error {
+	branchCode, err := bc.branchCode(c)
+	if branchCode == "" {
+		return err
+	}
+
+	schedule, err := branch_schedule.Find(bc.DB, branchCode)
+	if err != nil {
+		logger.Error("Failed to find branch schedule", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+	if schedule == nil {
+		return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "No schedule configured for this branch",
+			Data:    nil,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Branch schedule retrieved successfully",
+		Data:    schedule,
+	})
+}
+
+// UpdateSchedule creates or replaces the operating hours and dispatch cutoff of a branch
+func (bc *BranchController) UpdateSchedule(c *fiber.Ctx) error {
+	branchCode, err := bc.branchCode(c)
+	if branchCode == "" {
+		return err
+	}
+
+	var req branchTypes.ScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	schedule := bookingModel.BranchSchedule{
+		BranchCode:  branchCode,
+		OpensAt:     req.OpensAt,
+		ClosesAt:    req.ClosesAt,
+		CutoffAt:    req.CutoffAt,
+		WorkingDays: req.WorkingDays,
+		UpdatedByID: userInfo.ID,
+	}
+	if err := bc.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "branch_code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"opens_at", "closes_at", "cutoff_at", "working_days", "updated_by_id", "updated_at"}),
+	}).Create(&schedule).Error; err != nil {
+		logger.Error("Failed to save branch schedule", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to save branch schedule",
+			Data:    nil,
+		})
+	}
+
+	saved, err := branch_schedule.Find(bc.DB, branchCode)
+	if err != nil || saved == nil {
+		saved = &schedule
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Branch schedule saved successfully",
+		Data:    saved,
+	})
+}
@@ -0,0 +1,156 @@
+package branch
+
+import (
+	"errors"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/branch_hierarchy"
+	"passport-booking/types"
+	branchTypes "passport-booking/types/branch"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// hierarchyFailed maps hierarchy errors to responses
+func (bc *BranchController) hierarchyFailed(c *fiber.Ctx, err error) error {
+	var parentErr *branch_hierarchy.ParentError
+	switch {
+	case errors.Is(err, branch_hierarchy.ErrUnitNotFound):
+		return bc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	case errors.Is(err, branch_hierarchy.ErrHasChildren), errors.Is(err, branch_hierarchy.ErrCodeTaken):
+		return bc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	case errors.As(err, &parentErr):
+		return bc.sendResponseWithLog(c, fiber.StatusUnprocessableEntity, types.ApiResponse{
+			Status:  fiber.StatusUnprocessableEntity,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	logger.Error("Failed to update branch hierarchy", err)
+	return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed to update branch hierarchy",
+		Data:    nil,
+	})
+}
+
+// Hierarchy returns the region → district → branch tree
+func (bc *BranchController) Hierarchy(c *fiber.Ctx) error {
+	tree, err := branch_hierarchy.Load(bc.DB)
+	if err != nil {
+		logger.Error("Failed to load branch hierarchy", err)
+		return bc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to load branch hierarchy",
+			Data:    nil,
+		})
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Branch hierarchy fetched successfully",
+		Data:    tree.Roots,
+	})
+}
+
+// CreateOrgUnit adds a region, district or branch to the tree
+func (bc *BranchController) CreateOrgUnit(c *fiber.Ctx) error {
+	var req branchTypes.OrgUnitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(true); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	unit := bookingModel.OrgUnit{
+		Code:        req.Code,
+		Name:        req.Name,
+		Level:       req.Level,
+		ParentID:    req.ParentID,
+		UpdatedByID: userInfo.ID,
+	}
+	if err := branch_hierarchy.Create(bc.DB, &unit); err != nil {
+		return bc.hierarchyFailed(c, err)
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Org unit created successfully",
+		Data:    unit,
+	})
+}
+
+// UpdateOrgUnit renames a unit or moves it under another parent
+func (bc *BranchController) UpdateOrgUnit(c *fiber.Ctx) error {
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+
+	var req branchTypes.OrgUnitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(false); err != nil {
+		return bc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := bc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	unit, err := branch_hierarchy.Update(bc.DB, uint(id), req.Name, req.ParentID, userInfo.ID)
+	if err != nil {
+		return bc.hierarchyFailed(c, err)
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Org unit updated successfully",
+		Data:    unit,
+	})
+}
+
+// DeleteOrgUnit removes a unit with nothing under it
+func (bc *BranchController) DeleteOrgUnit(c *fiber.Ctx) error {
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+
+	if err := branch_hierarchy.Delete(bc.DB, uint(id)); err != nil {
+		return bc.hierarchyFailed(c, err)
+	}
+
+	return bc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Org unit deleted successfully",
+		Data:    nil,
+	})
+}
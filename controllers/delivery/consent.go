@@ -0,0 +1,36 @@
+package delivery
+
+import (
+	"errors"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/consent"
+	"passport-booking/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requireConsent answers 428 when the applicant never consented to OTPs on the
+// booking's delivery phone. It returns handled=false when the OTP may be sent.
+func (dc *DeliveryController) requireConsent(c *fiber.Ctx, booking *bookingModel.Booking) (bool, error) {
+	_, err := consent.Active(dc.DB, booking)
+	if err == nil {
+		return false, nil
+	}
+	if errors.Is(err, consent.ErrConsentRequired) {
+		return true, dc.sendResponseWithLog(c, fiber.StatusPreconditionRequired, types.ApiResponse{
+			Status:  fiber.StatusPreconditionRequired,
+			Message: "Applicant has not consented to OTPs on the delivery phone",
+			Data: map[string]interface{}{
+				"code": "CONSENT_REQUIRED",
+			},
+		})
+	}
+
+	logger.Error("Failed to check delivery phone consent", err)
+	return true, dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+		Status:  fiber.StatusInternalServerError,
+		Message: "Internal server error",
+		Data:    nil,
+	})
+}
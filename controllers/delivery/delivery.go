@@ -1,12 +1,14 @@
 package delivery
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -14,11 +16,26 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"passport-booking/database"
+	"passport-booking/httpServices/dms"
+	"passport-booking/httpServices/nid"
 	"passport-booking/logger"
+	"passport-booking/middleware"
 	bookingModel "passport-booking/models/booking"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/app_id"
 	"passport-booking/services/booking_event"
+	"passport-booking/services/booking_lock"
+	"passport-booking/services/delivery_group"
+	"passport-booking/services/delivery_hold"
+	"passport-booking/services/delivery_repo"
+	"passport-booking/services/fraud"
+	"passport-booking/services/id_verification"
+	"passport-booking/services/notification"
 	otpService "passport-booking/services/otp"
+	"passport-booking/services/storage"
 	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
 	deliveryTypes "passport-booking/types/delivery"
 	"passport-booking/utils"
 )
@@ -27,6 +44,12 @@ import (
 type DeliveryController struct {
 	DB             *gorm.DB
 	Logger         *logger.AsyncLogger
+	OTPService     otpService.OTPService
+	DMS            dms.Client
+	Storage        storage.FileStorage
+	IDStorage      storage.FileStorage
+	NID            nid.Client // nil when no NID registry is configured
+	Bookings       delivery_repo.Repository
 	loggerInstance *logger.AsyncLogger
 }
 
@@ -35,6 +58,12 @@ func NewDeliveryController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *Delive
 	return &DeliveryController{
 		DB:             db,
 		Logger:         asyncLogger,
+		OTPService:     otpService.NewOTPService(db),
+		DMS:            dms.NewDMSService(),
+		Storage:        storage.NewLocalStorage(storage.DeliveryPhotoDir),
+		IDStorage:      storage.NewLocalStorage(storage.RecipientIDPhotoDir),
+		NID:            nid.NewClient(),
+		Bookings:       delivery_repo.NewDeliveryRepository(db),
 		loggerInstance: asyncLogger,
 	}
 }
@@ -74,44 +103,19 @@ func (dc *DeliveryController) DeliveryConfirmationSendOtp(c *fiber.Ctx) error {
 	}
 
 	// Get user authentication information (postman user)
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
+			Message: "Postman not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
+	postmanInfo := current.User
 
-	// Get postman user info
-	postmanInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding postman by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "Postman not found"
-		}
-		return dc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
-
-	// Find the booking by barcode
+	// Find the booking by the requested identifier (barcode by default)
 	var booking bookingModel.Booking
-	if err := dc.DB.Preload("User").Where("barcode = ?", req.BookingID).First(&booking).Error; err != nil {
+	if err := dc.Bookings.FindBooking(req.BookingID, req.IdentifierType, &booking); err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -155,6 +159,25 @@ func (dc *DeliveryController) DeliveryConfirmationSendOtp(c *fiber.Ctx) error {
 		})
 	}
 
+	if handled, err := dc.requireConsent(c, &booking); handled {
+		return err
+	}
+
+	// Hold the booking for this postman until the confirmation flow completes or
+	// times out. The lock is dropped again if no OTP session is handed out.
+	if _, err := booking_lock.Acquire(dc.DB, booking.ID, postmanInfo.ID, bookingModel.LockPurposeDeliveryConfirmation); err != nil {
+		return dc.lockedResponse(c, err)
+	}
+	sessionStarted := false
+	defer func() {
+		if sessionStarted {
+			return
+		}
+		if err := booking_lock.Release(dc.DB, booking.ID, postmanInfo.ID); err != nil {
+			logger.Error("Failed to release booking lock after a failed delivery confirmation OTP", err)
+		}
+	}()
+
 	// Reset verification status for delivery confirmation
 	booking.DeliveryPhoneConfirmedVerified = false
 
@@ -167,20 +190,19 @@ func (dc *DeliveryController) DeliveryConfirmationSendOtp(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := booking_event.SnapshotBookingToEvent(dc.DB, &booking, "delivery_confirmation_send_otp", strconv.FormatUint(uint64(postmanInfo.ID), 10)); err != nil {
+	if err := booking_event.SnapshotBookingToEvent(dc.DB.WithContext(c.UserContext()), &booking, "delivery_confirmation_send_otp", strconv.FormatUint(uint64(postmanInfo.ID), 10)); err != nil {
 		logger.Error("Failed to write booking event (delivery_confirmation_send_otp)", err)
 	}
 
 	// Send OTP to the delivery phone for confirmation
-	otpSvc := otpService.NewOTPService(dc.DB)
-	otpRecord, err := otpSvc.SendOTPWithBookingID(*booking.DeliveryPhone, req.Purpose, &booking.ID)
+	otpRecord, err := dc.OTPService.SendOTPWithBookingID(*booking.DeliveryPhone, req.Purpose, &booking.ID)
 	if err != nil {
 		logger.Error("Failed to send delivery confirmation OTP", err)
 
 		// Check if it's a blocking error that should be returned as error response
 		errMsg := err.Error()
 		if errMsg == "OTP requests are blocked permanently due to too many failed attempts" ||
-			(len(errMsg) > 20 && errMsg[:20] == "OTP requests are blocked until") {
+			(len(errMsg) > 20 && errMsg[:20] == "OTP requests are blocked until") || otpService.IsRateLimited(err) {
 			return dc.sendResponseWithLog(c, fiber.StatusTooManyRequests, types.ApiResponse{
 				Status:  fiber.StatusTooManyRequests,
 				Message: err.Error(),
@@ -193,7 +215,7 @@ func (dc *DeliveryController) DeliveryConfirmationSendOtp(c *fiber.Ctx) error {
 			Status:  fiber.StatusInternalServerError,
 			Message: "Failed to send delivery confirmation OTP",
 			Data: map[string]interface{}{
-				"booking":   booking,
+				"booking":   bookingTypes.NewBookingResponse(&booking),
 				"otp_error": err.Error(),
 			},
 		})
@@ -201,20 +223,36 @@ func (dc *DeliveryController) DeliveryConfirmationSendOtp(c *fiber.Ctx) error {
 		logger.Success(fmt.Sprintf("Delivery confirmation OTP sent to phone %s for booking ID: %d (Barcode: %s) by postman: %s", *booking.DeliveryPhone, booking.ID, req.BookingID, postmanInfo.LegalName))
 	}
 
+	// Bind the OTP to this postman; the returned session must be sent back on verify
+	var otpSession string
+	if otpRecord != nil {
+		otpSession, err = dc.OTPService.BindSession(otpRecord, booking.ID, postmanInfo.ID)
+		if err != nil {
+			logger.Error("Failed to bind delivery confirmation OTP session", err)
+			return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Failed to start delivery confirmation session",
+				Data:    nil,
+			})
+		}
+	}
+
 	responseData := map[string]interface{}{
-		"booking":      booking,
+		"booking":      bookingTypes.NewBookingResponse(&booking),
 		"postman_id":   postmanInfo.ID,
 		"postman_name": postmanInfo.LegalName,
 	}
 
 	if otpRecord != nil {
 		responseData["otp_info"] = map[string]interface{}{
-			"otp_id":     otpRecord.ID,
-			"expires_at": otpRecord.ExpiresAt,
-			"phone":      booking.DeliveryPhone,
-			"purpose":    req.Purpose,
+			"otp_id":      otpRecord.ID,
+			"expires_at":  otpRecord.ExpiresAt,
+			"phone":       booking.DeliveryPhone,
+			"purpose":     req.Purpose,
+			"otp_session": otpSession,
 		}
 	}
+	sessionStarted = true
 
 	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
 		Status:  fiber.StatusOK,
@@ -245,44 +283,19 @@ func (dc *DeliveryController) DeliveryConfirmationVerifyOtp(c *fiber.Ctx) error
 	}
 
 	// Get user authentication information (postman user)
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
+			Message: "Postman not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
+	postmanInfo := current.User
 
-	// Get postman user info
-	postmanInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding postman by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "Postman not found"
-		}
-		return dc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
-
-	// Find the booking by barcode
+	// Find the booking by the requested identifier (barcode by default)
 	var booking bookingModel.Booking
-	if err := dc.DB.Preload("User").Where("barcode = ?", req.BookingID).First(&booking).Error; err != nil {
+	if err := dc.Bookings.FindBooking(req.BookingID, req.IdentifierType, &booking); err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -298,6 +311,11 @@ func (dc *DeliveryController) DeliveryConfirmationVerifyOtp(c *fiber.Ctx) error
 		})
 	}
 
+	// Another postman may be mid-way through confirming this delivery
+	if err := dc.Bookings.CheckLock(booking.ID, postmanInfo.ID); err != nil {
+		return dc.lockedResponse(c, err)
+	}
+
 	// Check if booking has a delivery phone set
 	if booking.DeliveryPhone == nil {
 		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
@@ -315,17 +333,29 @@ func (dc *DeliveryController) DeliveryConfirmationVerifyOtp(c *fiber.Ctx) error
 		})
 	}
 
-	// Verify OTP using OTP service
-	otpSvc := otpService.NewOTPService(dc.DB)
-	isValid, otpRecord, err := otpSvc.VerifyOTPWithDetails(*booking.DeliveryPhone, req.OTPCode, req.Purpose)
+	// Verify OTP using OTP service; the session must belong to this postman's send-otp call
+	isValid, otpRecord, err := dc.OTPService.VerifyOTPWithSession(*booking.DeliveryPhone, req.OTPCode, req.Purpose, booking.ID, req.OTPSession, postmanInfo.ID)
 	if err != nil {
 		logger.Error("Failed to verify delivery confirmation OTP", err)
 
+		if errors.Is(err, otpService.ErrOTPSessionMismatch) {
+			return dc.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+				Status:  fiber.StatusForbidden,
+				Message: "This OTP was not requested from this session. Please request a new OTP",
+				Data: map[string]interface{}{
+					"error":   "OTP_SESSION_INVALID",
+					"success": false,
+				},
+			})
+		}
+
 		// If we have an OTP record, we can provide more detailed error information
 		if otpRecord != nil {
+			// Judged by the OTP service's clock, as the verification was
+			now := dc.OTPService.Now()
 			remainingAttempts := otpRecord.MaxRetries - otpRecord.RetryCount
-			isBlocked := otpRecord.IsCurrentlyBlocked()
-			isExpired := otpRecord.IsExpired()
+			isBlocked := otpRecord.IsBlockedAt(now)
+			isExpired := otpRecord.IsExpiredAt(now)
 
 			// Handle OTP expiration separately
 			if isExpired {
@@ -389,61 +419,31 @@ func (dc *DeliveryController) DeliveryConfirmationVerifyOtp(c *fiber.Ctx) error
 		})
 	}
 
-	// Encrypt OTP data for storage
-	var deliveryPhoneConfirmedOTPEncrypted string
-
-	if otpRecord != nil {
-		// Encrypt the confirmed OTP (the OTP code that was verified)
-		encryptedDeliveryPhoneConfirmedOTP, err := utils.EncryptData(otpRecord.OTPCode)
-		if err != nil {
-			logger.Error("Failed to encrypt delivery confirmation OTP", err)
-			// Continue without encryption rather than failing
-			deliveryPhoneConfirmedOTPEncrypted = ""
-		} else {
-			deliveryPhoneConfirmedOTPEncrypted = encryptedDeliveryPhoneConfirmedOTP
-		}
-	}
-	fmt.Println("delivery phone confirmed OTP", deliveryPhoneConfirmedOTPEncrypted)
-	// Mark delivery phone as confirmed and store encrypted OTP
-	booking.DeliveryPhoneConfirmedVerified = true
-	// Always assign the encrypted OTP field, even if it's empty
-	booking.DeliveryPhoneConfirmedOTPEncrypted = &deliveryPhoneConfirmedOTPEncrypted
-	//booking.Status = bookingModel.BookingStatusDelivered
-
-	// Save the updated booking with explicit field updates
-	if err := dc.DB.Save(&booking).Error; err != nil {
-		logger.Error("Failed to update delivery phone confirmation status", err)
-		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to update confirmation status",
-			Data:    nil,
+	// The OTP must have been issued for this booking, not another one on the same phone
+	if otpRecord == nil || otpRecord.BookingID != booking.ID {
+		return dc.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+			Status:  fiber.StatusForbidden,
+			Message: "This OTP was not issued for this booking. Please request a new OTP",
+			Data: map[string]interface{}{
+				"error":   "OTP_BOOKING_MISMATCH",
+				"success": false,
+			},
 		})
 	}
 
-	// Create booking status event
-	bookingStatusEvent := bookingModel.BookingStatusEvent{
-		BookingID: booking.ID,
-		Status:    booking.Status,
-		CreatedBy: strconv.FormatUint(uint64(postmanInfo.ID), 10),
-	}
-
-	if err := dc.DB.Create(&bookingStatusEvent).Error; err != nil {
-		logger.Error("Failed to create booking status event", err)
+	if err := dc.confirmDeliveryPhone(c, &booking, otpRecord.OTPCode, "delivery_phone_confirmed", postmanInfo.ID); err != nil {
+		logger.Error("Failed to record delivery phone confirmation", err)
 		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
 			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to create booking status event",
+			Message: "Failed to update confirmation status",
 			Data:    nil,
 		})
 	}
 
-	if err := booking_event.SnapshotBookingToEvent(dc.DB, &booking, "delivery_phone_confirmed", strconv.FormatUint(uint64(postmanInfo.ID), 10)); err != nil {
-		logger.Error("Failed to write booking event (delivery_phone_confirmed)", err)
-	}
-
 	logger.Success(fmt.Sprintf("Delivery confirmation verified for booking ID: %d (Barcode: %s) by postman: %s", booking.ID, req.BookingID, postmanInfo.LegalName))
 
 	responseData := map[string]interface{}{
-		"booking":      booking,
+		"booking":      bookingTypes.NewBookingResponse(&booking),
 		"verified":     true,
 		"postman_id":   postmanInfo.ID,
 		"postman_name": postmanInfo.LegalName,
@@ -456,6 +456,32 @@ func (dc *DeliveryController) DeliveryConfirmationVerifyOtp(c *fiber.Ctx) error
 	})
 }
 
+// confirmDeliveryPhone marks the booking's delivery phone confirmed with the verified
+// code and records the confirmation, as eventType, for the booking and its delivery group
+func (dc *DeliveryController) confirmDeliveryPhone(c *fiber.Ctx, booking *bookingModel.Booking, verifiedCode, eventType string, postmanID uint) error {
+	// Encrypt OTP data for storage
+	var deliveryPhoneConfirmedOTPEncrypted string
+
+	if verifiedCode != "" {
+		// Encrypt the confirmed OTP (the OTP code that was verified)
+		encryptedDeliveryPhoneConfirmedOTP, err := utils.EncryptData(verifiedCode)
+		if err != nil {
+			logger.Error("Failed to encrypt delivery confirmation OTP", err)
+			// Continue without encryption rather than failing
+			deliveryPhoneConfirmedOTPEncrypted = ""
+		} else {
+			deliveryPhoneConfirmedOTPEncrypted = encryptedDeliveryPhoneConfirmedOTP
+		}
+	}
+	// Mark delivery phone as confirmed and store encrypted OTP
+	booking.DeliveryPhoneConfirmedVerified = true
+	// Always assign the encrypted OTP field, even if it's empty
+	booking.DeliveryPhoneConfirmedOTPEncrypted = &deliveryPhoneConfirmedOTPEncrypted
+	//booking.Status = bookingModel.BookingStatusDelivered
+
+	return dc.Bookings.ConfirmDeliveryPhone(c.UserContext(), booking, eventType, postmanID)
+}
+
 // VerifyApplicationID verifies the application ID for delivery
 func (dc *DeliveryController) VerifyApplicationID(c *fiber.Ctx) error {
 	var req deliveryTypes.VerifyApplicationIDRequest
@@ -478,44 +504,19 @@ func (dc *DeliveryController) VerifyApplicationID(c *fiber.Ctx) error {
 	}
 
 	// Get user authentication information (postman user)
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
+			Message: "Postman not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
+	postmanInfo := current.User
 
-	// Get postman user info
-	postmanInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding postman by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "Postman not found"
-		}
-		return dc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
-
-	// Find the booking by barcode
+	// Find the booking by the requested identifier (barcode by default)
 	var booking bookingModel.Booking
-	if err := dc.DB.Preload("User").Where("barcode = ?", req.BookingID).First(&booking).Error; err != nil {
+	if err := dc.Bookings.FindBooking(req.BookingID, req.IdentifierType, &booking); err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -531,6 +532,11 @@ func (dc *DeliveryController) VerifyApplicationID(c *fiber.Ctx) error {
 		})
 	}
 
+	// Another postman may be mid-way through confirming this delivery
+	if err := dc.Bookings.CheckLock(booking.ID, postmanInfo.ID); err != nil {
+		return dc.lockedResponse(c, err)
+	}
+
 	// Validate booking status allows application ID verification
 	//if booking.Status != bookingModel.BookingStatusReceivedByPostman  {
 	//	return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
@@ -559,11 +565,16 @@ func (dc *DeliveryController) VerifyApplicationID(c *fiber.Ctx) error {
 		})
 	}
 
-	// Verify the application ID matches the booking's AppOrOrderID
-	if booking.AppOrOrderID != req.ApplicationID {
+	// Verify the application ID matches the booking's AppOrOrderID under the configured policy
+	matchMode, matched := app_id.Match(booking.AppOrOrderID, req.ApplicationID)
+	if !matched {
+		message := "Application ID does not match the booking record"
+		if app_id.FailsChecksum(req.ApplicationID) {
+			message = "Application ID fails its check digit; please check it for a typo"
+		}
 		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
 			Status:  fiber.StatusBadRequest,
-			Message: "Application ID does not match the booking record",
+			Message: message,
 			Data:    nil,
 		})
 	}
@@ -582,16 +593,17 @@ func (dc *DeliveryController) VerifyApplicationID(c *fiber.Ctx) error {
 	}
 
 	// Create booking event for application ID verification
-	if err := booking_event.SnapshotBookingToEvent(dc.DB, &booking, "application_id_verified", strconv.FormatUint(uint64(postmanInfo.ID), 10)); err != nil {
+	if err := booking_event.SnapshotBookingToEvent(dc.DB.WithContext(c.UserContext()), &booking, "application_id_verified", strconv.FormatUint(uint64(postmanInfo.ID), 10)); err != nil {
 		logger.Error("Failed to write booking event (application_id_verified)", err)
 	}
 
-	logger.Success(fmt.Sprintf("Application ID verified for booking ID: %d (Barcode: %s) by postman: %s", booking.ID, req.BookingID, postmanInfo.LegalName))
+	logger.Success(fmt.Sprintf("Application ID verified for booking ID: %d (Barcode: %s) by postman: %s, matched %s", booking.ID, req.BookingID, postmanInfo.LegalName, matchMode))
 
 	responseData := map[string]interface{}{
-		"booking":        booking,
+		"booking":        bookingTypes.NewBookingResponse(&booking),
 		"verified":       true,
 		"application_id": req.ApplicationID,
+		"match_mode":     matchMode,
 		"postman_id":     postmanInfo.ID,
 		"postman_name":   postmanInfo.LegalName,
 	}
@@ -615,45 +627,29 @@ func (dc *DeliveryController) UploadDeliveryPhoto(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get user authentication information (postman user)
-	claims, ok := c.Locals("user").(map[string]interface{})
-	if !ok {
-		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
-			Status:  fiber.StatusUnauthorized,
+	identifierType := bookingTypes.IdentifierType(c.FormValue("identifier_type"))
+	if err := bookingTypes.NormalizeIdentifierType(&identifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
 			Data:    nil,
 		})
 	}
 
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
+	// Get user authentication information (postman user)
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
 		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
+			Message: "Postman not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
+	postmanInfo := current.User
 
-	// Get postman user info
-	postmanInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding postman by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "Postman not found"
-		}
-		return dc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
-
-	// Find the booking by barcode
+	// Find the booking by the requested identifier (barcode by default)
 	var booking bookingModel.Booking
-	if err := dc.DB.Preload("User").Where("barcode = ?", bookingIDStr).First(&booking).Error; err != nil {
+	if err := dc.Bookings.FindBooking(bookingIDStr, identifierType, &booking); err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -669,10 +665,15 @@ func (dc *DeliveryController) UploadDeliveryPhoto(c *fiber.Ctx) error {
 		})
 	}
 
+	// Another postman may be mid-way through confirming this delivery
+	if err := dc.Bookings.CheckLock(booking.ID, postmanInfo.ID); err != nil {
+		return dc.lockedResponse(c, err)
+	}
+
 	// Check if photo is already uploaded
 	if booking.UploadPhoto != nil && *booking.UploadPhoto != "" {
 		// Check if the file actually exists on the filesystem
-		if _, err := os.Stat(*booking.UploadPhoto); err == nil {
+		if dc.Storage.Exists(*booking.UploadPhoto) {
 			return dc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
 				Status:  fiber.StatusConflict,
 				Message: "Photo already uploaded for this booking",
@@ -697,6 +698,40 @@ func (dc *DeliveryController) UploadDeliveryPhoto(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := validateDeliveryPhoto(file); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	filePath, filename, err := dc.storeDeliveryPhoto(c, &booking, file, postmanInfo.ID)
+	if err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	logger.Success(fmt.Sprintf("Delivery photo uploaded for booking ID: %d (Barcode: %s) by postman: %s", booking.ID, bookingIDStr, postmanInfo.LegalName))
+
+	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Photo uploaded successfully",
+		Data: fiber.Map{
+			"booking_id":   booking.ID,
+			"photo_path":   filePath,
+			"filename":     filename,
+			"postman_id":   postmanInfo.ID,
+			"postman_name": postmanInfo.LegalName,
+		},
+	})
+}
+
+// validateDeliveryPhoto accepts common image formats up to 10MB
+func validateDeliveryPhoto(file *multipart.FileHeader) error {
 	// Validate file type (only allow common image formats)
 	allowedTypes := map[string]bool{
 		"image/jpeg": true,
@@ -706,41 +741,27 @@ func (dc *DeliveryController) UploadDeliveryPhoto(c *fiber.Ctx) error {
 		"image/webp": true,
 	}
 
-	fileType := file.Header.Get("Content-Type")
-	if !allowedTypes[fileType] {
-		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
-			Status:  fiber.StatusBadRequest,
-			Message: "Invalid file type. Only JPEG, PNG, GIF, and WebP images are allowed",
-			Data:    nil,
-		})
+	if !allowedTypes[file.Header.Get("Content-Type")] {
+		return errors.New("Invalid file type. Only JPEG, PNG, GIF, and WebP images are allowed")
 	}
 
 	// Validate file size (max 10MB)
 	maxSize := int64(10 << 20) // 10MB
 	if file.Size > maxSize {
-		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
-			Status:  fiber.StatusBadRequest,
-			Message: "File size too large. Maximum size is 10MB",
-			Data:    nil,
-		})
-	}
-
-	// Create upload directory if it doesn't exist
-	uploadDir := "./upload_photos"
-	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
-		logger.Error("Failed to create upload directory", err)
-		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to create upload directory",
-			Data:    nil,
-		})
+		return errors.New("File size too large. Maximum size is 10MB")
 	}
+	return nil
+}
 
+// storeDeliveryPhoto saves a validated photo, links it to the booking and its
+// delivery group and returns the stored path and file name. Errors are logged
+// here and carry a message fit for the response.
+func (dc *DeliveryController) storeDeliveryPhoto(c *fiber.Ctx, booking *bookingModel.Booking, file *multipart.FileHeader, postmanID uint) (string, string, error) {
 	// Generate unique filename
 	fileExt := strings.ToLower(filepath.Ext(file.Filename))
 	if fileExt == "" {
 		// If no extension, try to determine from content type
-		switch fileType {
+		switch file.Header.Get("Content-Type") {
 		case "image/jpeg":
 			fileExt = ".jpg"
 		case "image/png":
@@ -756,51 +777,64 @@ func (dc *DeliveryController) UploadDeliveryPhoto(c *fiber.Ctx) error {
 
 	timestamp := time.Now().Format("20060102_150405")
 	filename := fmt.Sprintf("booking_%s%s", timestamp, fileExt)
-	filePath := fmt.Sprintf("%s/%s", uploadDir, filename)
+
+	// Fingerprint the photo so the fraud rules can spot it on other bookings
+	hash, err := hashUpload(file)
+	if err != nil {
+		logger.Error("Failed to read uploaded file", err)
+		return "", "", errors.New("Failed to read uploaded file")
+	}
 
 	// Save the file
-	if err := c.SaveFile(file, filePath); err != nil {
+	filePath, err := dc.Storage.Save(file, filename)
+	if err != nil {
 		logger.Error("Failed to save uploaded file", err)
-		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to save uploaded file",
-			Data:    nil,
-		})
+		return "", "", errors.New("Failed to save uploaded file")
 	}
 
 	// Update booking with photo path
-	if err := dc.DB.Model(&booking).Updates(bookingModel.Booking{
-		UploadPhoto: &filePath,
-		UpdatedAt:   time.Now(),
+	if err := dc.DB.Model(booking).Updates(bookingModel.Booking{
+		UploadPhoto:     &filePath,
+		UploadPhotoHash: &hash,
+		UpdatedAt:       time.Now(),
 	}).Error; err != nil {
 		logger.Error("Failed to update booking with photo path", err)
 		// Try to delete the uploaded file if database update fails
-		os.Remove(filePath)
-		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to update booking with photo information",
-			Data:    nil,
-		})
+		dc.Storage.Remove(filePath)
+		return "", "", errors.New("Failed to update booking with photo information")
 	}
 
+	postmanIDStr := strconv.FormatUint(uint64(postmanID), 10)
+
 	// Create booking event for photo upload
-	if err := booking_event.SnapshotBookingToEvent(dc.DB, &booking, "delivery_photo_uploaded", strconv.FormatUint(uint64(postmanInfo.ID), 10)); err != nil {
+	if err := booking_event.SnapshotBookingToEvent(dc.DB.WithContext(c.UserContext()), booking, "delivery_photo_uploaded", postmanIDStr); err != nil {
 		logger.Error("Failed to write booking event (delivery_photo_uploaded)", err)
 	}
 
-	logger.Success(fmt.Sprintf("Delivery photo uploaded for booking ID: %d (Barcode: %s) by postman: %s", booking.ID, bookingIDStr, postmanInfo.LegalName))
+	// One photo covers the whole delivery group
+	if err := delivery_group.Propagate(dc.DB.WithContext(c.UserContext()), booking, map[string]interface{}{
+		"upload_photo":      filePath,
+		"upload_photo_hash": hash,
+	}, "delivery_photo_uploaded", postmanIDStr); err != nil {
+		logger.Error("Failed to propagate delivery photo to delivery group", err)
+	}
 
-	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
-		Status:  fiber.StatusOK,
-		Message: "Photo uploaded successfully",
-		Data: fiber.Map{
-			"booking_id":   booking.ID,
-			"photo_path":   filePath,
-			"filename":     filename,
-			"postman_id":   postmanInfo.ID,
-			"postman_name": postmanInfo.LegalName,
-		},
-	})
+	return filePath, filename, nil
+}
+
+// hashUpload returns the hex SHA-256 of an uploaded file
+func hashUpload(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, src); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 // ItemDetails handles POST /delivered/itemdetails
@@ -823,25 +857,16 @@ func (dc *DeliveryController) ItemDetails(c *fiber.Ctx) error {
 			Data:    nil,
 		})
 	}
-	claims, ok := c.Locals("user").(map[string]interface{})
-	if !ok {
-		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid user claims",
-			Data:    nil,
-		})
-	}
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "User UUID not found in token",
+	req.Barcode = utils.NormalizeBarcode(req.Barcode)
+	if err := utils.ValidateBarcode(req.Barcode); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
 			Data:    nil,
 		})
 	}
-	// Optionally, get user info if needed (e.g., for ID)
-	postmanInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
+	postmanInfo, ok := middleware.GetCurrentUser(c)
+	if !ok {
 		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
 			Status:  fiber.StatusUnauthorized,
 			Message: "Postman not found",
@@ -852,7 +877,7 @@ func (dc *DeliveryController) ItemDetails(c *fiber.Ctx) error {
 	// Convert postmanInfo.ID to string for updated_by comparison
 	updatedByStr := fmt.Sprintf("%v", postmanInfo.ID)
 	//err = dc.DB.Where("barcode = ? AND status = ? AND updated_by = ?", req.Barcode, bookingModel.BookingItemStatusReceivedByPostman, updatedByStr).First(&booking).Error
-	err = dc.DB.Where("barcode = ? AND status IN (?) AND updated_by = ?", req.Barcode, []string{string(bookingModel.BookingItemStatusReceivedByPostman), string(bookingModel.BookingStatusReceivedByPostman)}, updatedByStr).First(&booking).Error
+	err := dc.DB.Scopes(database.NotDeletedBookings).Where("barcode = ? AND status IN (?) AND updated_by = ?", req.Barcode, []string{string(bookingModel.BookingItemStatusReceivedByPostman), string(bookingModel.BookingStatusReceivedByPostman)}, updatedByStr).First(&booking).Error
 
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -871,7 +896,7 @@ func (dc *DeliveryController) ItemDetails(c *fiber.Ctx) error {
 	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
 		Status:  fiber.StatusOK,
 		Message: "Booking details found",
-		Data:    booking,
+		Data:    bookingTypes.NewBookingResponse(&booking),
 	})
 }
 
@@ -914,7 +939,7 @@ func (dc *DeliveryController) ReceiveItem(c *fiber.Ctx) error {
 
 	// First find the booking by barcode (item_id is the barcode)
 	var booking bookingModel.Booking
-	if err := dc.DB.Preload("User").Where("barcode = ?", reqBody.ItemID).First(&booking).Error; err != nil {
+	if err := dc.DB.Preload("User").Scopes(database.NotDeletedBookings).Where("barcode = ?", reqBody.ItemID).First(&booking).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			errorResponse := types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -941,6 +966,11 @@ func (dc *DeliveryController) ReceiveItem(c *fiber.Ctx) error {
 		})
 	}
 
+	// The applicant asked for the item to stay at the branch for now
+	if err := dc.Bookings.CheckHold(booking.ID); err != nil {
+		return dc.onHoldResponse(c, err)
+	}
+
 	// Get bag_id from the booking
 	var bagID string
 	if booking.CurrentBagID != nil {
@@ -955,73 +985,27 @@ func (dc *DeliveryController) ReceiveItem(c *fiber.Ctx) error {
 		return nil
 	}
 	// Prepare payload for DMS API - using the new structure
-	payload := map[string]interface{}{
-		"bag_id":      bagID,
-		"item_id":     reqBody.ItemID,
-		"recieve_all": "1", // Set to "0" since we're receiving specific item
+	payload := dms.ReceiveBagItemRequest{
+		BagID:      bagID,
+		ItemID:     reqBody.ItemID,
+		ReceiveAll: "1", // Set to "0" since we're receiving specific item
 	}
 
-	jsonPayload, err := json.Marshal(payload)
+	resp, err := dc.DMS.ReceiveBagItem(authHeader, payload)
 	if err != nil {
-		errorResponse := types.ApiResponse{
-			Message: "Failed to marshal payload",
-			Status:  fiber.StatusInternalServerError,
+		message := "Failed to send request"
+		if errors.Is(err, dms.ErrBaseURLNotSet) {
+			message = err.Error()
 		}
-		c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
-		dc.logAPIRequest(c)
-		return nil
-	}
-
-	baseURL := os.Getenv("DMS_BASE_URL")
-	if baseURL == "" {
 		errorResponse := types.ApiResponse{
-			Message: "DMS_BASE_URL environment variable is not set",
-			Status:  fiber.StatusInternalServerError,
-		}
-		c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
-		dc.logAPIRequest(c)
-		return nil
-	}
-
-	// Use the correct endpoint
-	url := fmt.Sprintf("%s/rms/receive-bag-item/", baseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		errorResponse := types.ApiResponse{
-			Message: "Failed to create request",
-			Status:  fiber.StatusInternalServerError,
-		}
-		c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
-		dc.logAPIRequest(c)
-		return nil
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", authHeader)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		errorResponse := types.ApiResponse{
-			Message: "Failed to send request",
-			Status:  fiber.StatusInternalServerError,
-		}
-		c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
-		dc.logAPIRequest(c)
-		return nil
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		errorResponse := types.ApiResponse{
-			Message: "Failed to read response",
+			Message: message,
 			Status:  fiber.StatusInternalServerError,
 		}
 		c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
 		dc.logAPIRequest(c)
 		return nil
 	}
+	body := resp.Body
 
 	var responseData interface{}
 	if err := json.Unmarshal(body, &responseData); err != nil {
@@ -1078,26 +1062,16 @@ func (dc *DeliveryController) ReceiveItem(c *fiber.Ctx) error {
 
 // updateBookingAfterItemReceived updates the booking status after item is successfully received
 func (dc *DeliveryController) updateBookingAfterItemReceived(bookingID string, c *fiber.Ctx) error {
-	// Get user authentication information (postman user)
-	claims, ok := c.Locals("user").(map[string]interface{})
+	// Get the postman user behind the request
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
-		return fmt.Errorf("invalid user claims")
-	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return fmt.Errorf("user UUID not found in token")
-	}
-
-	// Get postman user info
-	postmanInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		return fmt.Errorf("failed to get postman info: %v", err)
+		return fmt.Errorf("postman not found")
 	}
+	postmanInfo := current.User
 
-	// Find the booking by barcode
+	// Find the booking by the requested identifier (barcode by default)
 	var booking bookingModel.Booking
-	if err := dc.DB.Preload("User").Where("barcode = ?", bookingID).First(&booking).Error; err != nil {
+	if err := dc.DB.Preload("User").Scopes(database.NotDeletedBookings).Where("barcode = ?", bookingID).First(&booking).Error; err != nil {
 		return fmt.Errorf("failed to find booking: %v", err)
 	}
 
@@ -1105,6 +1079,9 @@ func (dc *DeliveryController) updateBookingAfterItemReceived(bookingID string, c
 	postmanIDStr := strconv.FormatUint(uint64(postmanInfo.ID), 10)
 
 	// Update booking status to received by postman
+	if err := booking.Status.CheckTransition(bookingModel.BookingItemStatusReceivedByPostman); err != nil {
+		return err
+	}
 	booking.Status = bookingModel.BookingItemStatusReceivedByPostman
 	booking.UpdatedBy = postmanIDStr
 
@@ -1120,19 +1097,30 @@ func (dc *DeliveryController) updateBookingAfterItemReceived(bookingID string, c
 		CreatedBy: postmanIDStr,
 	}
 
-	if err := dc.DB.Create(&bookingStatusEvent).Error; err != nil {
+	if err := dc.DB.WithContext(c.UserContext()).Create(&bookingStatusEvent).Error; err != nil {
 		logger.Error("Failed to create booking status event", err)
 		// Don't return error for status event failure
 	}
 
 	// Create booking event for item received
-	if err := booking_event.SnapshotBookingToEvent(dc.DB, &booking, "item_received_by_postman", postmanIDStr); err != nil {
+	if err := booking_event.SnapshotBookingToEvent(dc.DB.WithContext(c.UserContext()), &booking, "item_received_by_postman", postmanIDStr); err != nil {
 		logger.Error("Failed to write booking event (item_received_by_postman)", err)
 		// Don't fail the request for this error
 	}
 
 	logger.Success(fmt.Sprintf("Item received by postman for booking ID: %d (Barcode: %s) by postman: %s", booking.ID, bookingID, postmanInfo.LegalName))
 
+	data := notification.ForBooking(&booking)
+	data.PostmanName = postmanInfo.LegalName
+	data.PostmanPhone = postmanInfo.Phone
+	notification.Dispatch(notification.Notification{
+		Kind:      notification.KindDeliverySchedule,
+		UserID:    booking.UserID,
+		BookingID: booking.ID,
+		Phone:     booking.Phone,
+		Data:      data,
+	})
+
 	return nil
 }
 
@@ -1168,44 +1156,19 @@ func (dc *DeliveryController) ItemDelivery(c *fiber.Ctx) error {
 	}
 
 	// Get user authentication information (postman user)
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
+			Message: "Postman not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
+	postmanInfo := current.User
 
-	// Get postman user info
-	postmanInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		logger.Error("Error finding postman by UUID", err)
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "Postman not found"
-		}
-		return dc.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
-
-	// Find the booking by barcode
+	// Find the booking by the requested identifier (barcode by default)
 	var booking bookingModel.Booking
-	if err := dc.DB.Preload("User").Where("barcode = ?", req.BookingID).First(&booking).Error; err != nil {
+	if err := dc.Bookings.FindBooking(req.BookingID, req.IdentifierType, &booking); err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
 				Status:  fiber.StatusNotFound,
@@ -1221,6 +1184,11 @@ func (dc *DeliveryController) ItemDelivery(c *fiber.Ctx) error {
 		})
 	}
 
+	// Another postman may be mid-way through confirming this delivery
+	if err := dc.Bookings.CheckLock(booking.ID, postmanInfo.ID); err != nil {
+		return dc.lockedResponse(c, err)
+	}
+
 	// Check if item is received by postman and updated_by matches authenticated user
 	postmanIDStr := strconv.FormatUint(uint64(postmanInfo.ID), 10)
 	if booking.Status != bookingModel.BookingItemStatusReceivedByPostman {
@@ -1264,6 +1232,14 @@ func (dc *DeliveryController) ItemDelivery(c *fiber.Ctx) error {
 		})
 	}
 
+	if id_verification.Required(&booking) {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Recipient ID must be verified before delivery",
+			Data:    nil,
+		})
+	}
+
 	// Check if booking status allows delivery
 	if booking.Status == bookingModel.BookingStatusDelivered {
 		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
@@ -1273,52 +1249,52 @@ func (dc *DeliveryController) ItemDelivery(c *fiber.Ctx) error {
 		})
 	}
 
-	// Prepare payload for external API call
-	payload := map[string]interface{}{
-		"article_id": booking.Barcode,
+	// A hold that started after the item was received still keeps it at the branch
+	if err := dc.Bookings.CheckHold(booking.ID); err != nil {
+		return dc.onHoldResponse(c, err)
 	}
 
-	jsonPayload, err := json.Marshal(payload)
+	// Run the anti-fraud rules before the delivery is reported upstream
+	decision, err := dc.Bookings.EvaluateFraud(fraud.Signals{
+		Booking:   &booking,
+		PostmanID: postmanInfo.ID,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Now:       time.Now(),
+	})
 	if err != nil {
-		logger.Error("Failed to marshal payload for external API", err)
-		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to prepare API request",
-			Data:    nil,
-		})
+		logger.Error("Failed to evaluate fraud rules", err)
 	}
-
-	// Get DMS base URL from environment
-	baseURL := os.Getenv("DMS_BASE_URL")
-	if baseURL == "" {
-		logger.Error("DMS_BASE_URL environment variable is not set", nil)
-		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "External service configuration error",
-			Data:    nil,
+	if decision.Blocked() {
+		var caseID uint
+		if decision.Case != nil {
+			caseID = decision.Case.ID
+		}
+		logger.Warning(fmt.Sprintf("Delivery of booking %d held for supervisor review (fraud case %d)", booking.ID, caseID))
+		return dc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Delivery is held for supervisor review",
+			Data: map[string]interface{}{
+				"code":          "DELIVERY_HELD",
+				"fraud_case_id": caseID,
+				"rules":         decision.Hits,
+			},
 		})
 	}
 
 	// Make external API call to deliver article
-	url := fmt.Sprintf("%s/dms/deliver/article/", baseURL)
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		logger.Error("Failed to create HTTP request for external API", err)
-		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to create external API request",
-			Data:    nil,
-		})
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", authHeader)
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(httpReq)
+	resp, err := dc.DMS.DeliverArticle(authHeader, dms.DeliverArticleRequest{
+		ArticleID: *booking.Barcode,
+	})
 	if err != nil {
+		if errors.Is(err, dms.ErrBaseURLNotSet) {
+			logger.Error("DMS_BASE_URL environment variable is not set", nil)
+			return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "External service configuration error",
+				Data:    nil,
+			})
+		}
 		logger.Error("Failed to call external delivery API", err)
 		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -1326,22 +1302,11 @@ func (dc *DeliveryController) ItemDelivery(c *fiber.Ctx) error {
 			Data:    nil,
 		})
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Error("Failed to read external API response", err)
-		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: "Failed to read external API response",
-			Data:    nil,
-		})
-	}
 
 	var externalAPIResponse interface{}
-	if err := json.Unmarshal(body, &externalAPIResponse); err != nil {
+	if err := json.Unmarshal(resp.Body, &externalAPIResponse); err != nil {
 		logger.Warning(fmt.Sprintf("Failed to decode external API response as JSON: %v", err))
-		externalAPIResponse = string(body)
+		externalAPIResponse = string(resp.Body)
 	}
 
 	// Check if external API call was successful
@@ -1358,12 +1323,11 @@ func (dc *DeliveryController) ItemDelivery(c *fiber.Ctx) error {
 	}
 
 	// External API call successful, update booking status
-	postmanIDStr = strconv.FormatUint(uint64(postmanInfo.ID), 10)
-	booking.Status = bookingModel.BookingStatusDelivered
-	booking.UpdatedBy = postmanIDStr
-
-	// Save the updated booking
-	if err := dc.DB.Save(&booking).Error; err != nil {
+	if err := dc.markDelivered(c, &booking, postmanInfo, req.Latitude, req.Longitude); err != nil {
+		var onHoldErr *delivery_hold.OnHoldError
+		if errors.As(err, &onHoldErr) {
+			return dc.onHoldResponse(c, err)
+		}
 		logger.Error("Failed to update booking status after delivery", err)
 		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
 			Status:  fiber.StatusInternalServerError,
@@ -1372,29 +1336,18 @@ func (dc *DeliveryController) ItemDelivery(c *fiber.Ctx) error {
 		})
 	}
 
-	// Create booking status event
-	bookingStatusEvent := bookingModel.BookingStatusEvent{
-		BookingID: booking.ID,
-		Status:    booking.Status,
-		CreatedBy: postmanIDStr,
-	}
-
-	if err := dc.DB.Create(&bookingStatusEvent).Error; err != nil {
-		logger.Error("Failed to create booking status event for delivery", err)
-		// Don't fail the request for this error
-	}
+	logger.Success(fmt.Sprintf("Item delivered successfully for booking ID: %d (Barcode: %s) by postman: %s", booking.ID, req.BookingID, postmanInfo.LegalName))
 
-	// Create booking event for delivery
-	if err := booking_event.SnapshotBookingToEvent(dc.DB, &booking, "item_delivered", postmanIDStr); err != nil {
-		logger.Error("Failed to write booking event (item_delivered)", err)
-		// Don't fail the request for this error
+	// Proof-of-delivery QR pointing at the tracking page
+	podQRCode, err := utils.GenerateQRCodeBase64(utils.TrackingURL(*booking.Barcode), 256)
+	if err != nil {
+		logger.Warning(fmt.Sprintf("Failed to generate proof-of-delivery QR code for booking %d: %v", booking.ID, err))
 	}
 
-	logger.Success(fmt.Sprintf("Item delivered successfully for booking ID: %d (Barcode: %s) by postman: %s", booking.ID, req.BookingID, postmanInfo.LegalName))
-
 	responseData := map[string]interface{}{
-		"booking":           booking,
+		"booking":           bookingTypes.NewBookingResponse(&booking),
 		"delivered":         true,
+		"pod_qr_code":       podQRCode,
 		"postman_id":        postmanInfo.ID,
 		"postman_name":      postmanInfo.LegalName,
 		"external_response": externalAPIResponse,
@@ -1406,3 +1359,40 @@ func (dc *DeliveryController) ItemDelivery(c *fiber.Ctx) error {
 		Data:    responseData,
 	})
 }
+
+// markDelivered stores a delivery confirmed by DMS, records its events, releases the
+// booking lock and sends the proof of delivery. Only a hold in effect or a failure to
+// save the booking is returned.
+func (dc *DeliveryController) markDelivered(c *fiber.Ctx, booking *bookingModel.Booking, postman *userModel.User, latitude, longitude *float64) error {
+	if err := booking.Status.CheckTransition(bookingModel.BookingStatusDelivered); err != nil {
+		return err
+	}
+	if err := dc.Bookings.CheckHold(booking.ID); err != nil {
+		return err
+	}
+	booking.Status = bookingModel.BookingStatusDelivered
+	booking.UpdatedBy = strconv.FormatUint(uint64(postman.ID), 10)
+	booking.DeliveredLatitude = latitude
+	booking.DeliveredLongitude = longitude
+
+	if err := dc.Bookings.MarkDelivered(c.UserContext(), booking, postman.ID); err != nil {
+		return err
+	}
+
+	deliveredAt := time.Now()
+	data := notification.ForBooking(booking)
+	data.DeliveredAt = &deliveredAt
+	if booking.DeliveryPhone != nil {
+		data.DeliveredTo = *booking.DeliveryPhone
+	}
+	data.PostmanName = postman.LegalName
+	notification.Dispatch(notification.Notification{
+		Kind:      notification.KindProofOfDelivery,
+		UserID:    booking.UserID,
+		BookingID: booking.ID,
+		Phone:     booking.Phone,
+		Data:      data,
+	})
+
+	return nil
+}
@@ -0,0 +1,464 @@
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"passport-booking/constants"
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	fraudModel "passport-booking/models/fraud"
+	"passport-booking/models/otp"
+	"passport-booking/services/booking_lock"
+	"passport-booking/services/clock"
+	"passport-booking/services/delivery_hold"
+	"passport-booking/services/fraud"
+	otpService "passport-booking/services/otp"
+	"passport-booking/testutil/factory"
+	"passport-booking/testutil/mock"
+	"passport-booking/testutil/testauth"
+	"passport-booking/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newTestController returns a controller with mocked bookings, OTP and DMS. Its
+// request log is never drained, so a test can send at most 100 requests through it.
+func newTestController(bookings *mock.Bookings, otps *mock.OTPService, client *mock.DMS) *DeliveryController {
+	asyncLogger := logger.NewAsyncLogger(nil)
+	return &DeliveryController{Logger: asyncLogger, OTPService: otps, DMS: client, Bookings: bookings, loggerInstance: asyncLogger}
+}
+
+// response is the decoded body of an ApiResponse
+type response struct {
+	Status  int                    `json:"status"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// send posts body, JSON encoded unless it is a string, with a bearer token, and
+// decodes the response
+func send(t *testing.T, app *fiber.App, path string, body interface{}) (int, response) {
+	t.Helper()
+	raw, ok := body.(string)
+	if !ok {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw = string(encoded)
+	}
+	req := httptest.NewRequest(fiber.MethodPost, path, bytes.NewBufferString(raw))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer test")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded response
+	json.NewDecoder(resp.Body).Decode(&decoded)
+	return resp.StatusCode, decoded
+}
+
+// barcodeSeq numbers the barcodes handed out by testBarcode
+var barcodeSeq atomic.Int64
+
+// testBarcode returns a unique barcode from the provisional range of the default
+// branch, which skips the S10 check digit
+func testBarcode() string {
+	barcode, err := utils.FormatProvisionalBarcode("000000", barcodeSeq.Add(1))
+	if err != nil {
+		panic(err)
+	}
+	return barcode
+}
+
+// TestDeliveryConfirmationVerifyOtpRequest covers the rejections made before the
+// booking is loaded
+func TestDeliveryConfirmationVerifyOtpRequest(t *testing.T) {
+	valid := map[string]interface{}{
+		"booking_id":  "PRV10000000001",
+		"otp_code":    "123456",
+		"purpose":     otp.OTPPurposeDeliveryConfirmPhone,
+		"otp_session": "session",
+	}
+	tests := []struct {
+		name        string
+		body        interface{}
+		auth        bool
+		wantStatus  int
+		wantMessage string
+	}{
+		{"malformed body", "{", true, fiber.StatusBadRequest, "Invalid request body"},
+		{"missing session", map[string]interface{}{"booking_id": "PRV10000000001", "otp_code": "123456", "purpose": otp.OTPPurposeDeliveryConfirmPhone}, true, fiber.StatusBadRequest, "otp_session is required"},
+		{"unknown purpose", map[string]interface{}{"booking_id": "PRV10000000001", "otp_code": "123456", "purpose": "login", "otp_session": "session"}, true, fiber.StatusBadRequest, "purpose must be either"},
+		{"no postman", valid, false, fiber.StatusUnauthorized, "Postman not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otps := &mock.OTPService{}
+			dc := newTestController(&mock.Bookings{}, otps, &mock.DMS{})
+			app := fiber.New()
+			if tt.auth {
+				postman := factory.BuildUser()
+				app.Use(testauth.As(&postman, constants.PermPostmanFull))
+			}
+			app.Post("/verify", dc.DeliveryConfirmationVerifyOtp)
+
+			status, resp := send(t, app, "/verify", tt.body)
+			if status != tt.wantStatus {
+				t.Errorf("status %d, want %d", status, tt.wantStatus)
+			}
+			if !strings.Contains(resp.Message, tt.wantMessage) {
+				t.Errorf("message %q, want it to contain %q", resp.Message, tt.wantMessage)
+			}
+			if len(otps.Calls()) != 0 {
+				t.Error("OTP verified for a rejected request")
+			}
+		})
+	}
+}
+
+func TestDeliveryConfirmationVerifyOtp(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name    string
+		booking func(*bookingModel.Booking)
+		lockErr error
+		barcode string
+		verify  mock.Verification
+		// otherBooking leaves the verified OTP on another booking sharing the phone
+		otherBooking bool
+		// clock is the OTP service's clock, nil for the system clock
+		clock      clock.Clock
+		wantStatus int
+		wantError  string
+		wantCalled bool
+		wantStored bool
+	}{
+		{
+			name:       "right code",
+			verify:     mock.Verification{OK: true, Record: &otp.OTP{OTPCode: "123456", MaxRetries: 3, ExpiresAt: future}},
+			wantStatus: fiber.StatusOK,
+			wantCalled: true,
+			wantStored: true,
+		},
+		{
+			name:         "code of another booking on the same phone",
+			verify:       mock.Verification{OK: true, Record: &otp.OTP{OTPCode: "123456", MaxRetries: 3, ExpiresAt: future}},
+			otherBooking: true,
+			wantStatus:   fiber.StatusForbidden,
+			wantError:    "OTP_BOOKING_MISMATCH",
+			wantCalled:   true,
+		},
+		{
+			name:       "session of another postman",
+			verify:     mock.Verification{Err: otpService.ErrOTPSessionMismatch},
+			wantStatus: fiber.StatusForbidden,
+			wantError:  "OTP_SESSION_INVALID",
+			wantCalled: true,
+		},
+		{
+			name:       "wrong code",
+			verify:     mock.Verification{Record: &otp.OTP{RetryCount: 1, MaxRetries: 3, ExpiresAt: future}, Err: errors.New("invalid OTP. 2 attempts remaining")},
+			wantStatus: fiber.StatusBadRequest,
+			wantError:  "OTP_INVALID",
+			wantCalled: true,
+		},
+		{
+			name:       "expired code",
+			verify:     mock.Verification{Record: &otp.OTP{MaxRetries: 3, ExpiresAt: time.Now().Add(-time.Minute)}, Err: errors.New("OTP has expired")},
+			wantStatus: fiber.StatusBadRequest,
+			wantError:  "OTP_EXPIRED",
+			wantCalled: true,
+		},
+		{
+			name:       "expired by the OTP service clock",
+			verify:     mock.Verification{Record: &otp.OTP{MaxRetries: 3, ExpiresAt: future}, Err: errors.New("OTP has expired")},
+			clock:      clock.NewFake(future.Add(time.Minute)),
+			wantStatus: fiber.StatusBadRequest,
+			wantError:  "OTP_EXPIRED",
+			wantCalled: true,
+		},
+		{
+			name:       "blocked code",
+			verify:     mock.Verification{Record: &otp.OTP{RetryCount: 3, MaxRetries: 3, IsBlocked: true, BlockedUntil: &future, ExpiresAt: future}, Err: errors.New("OTP verification is blocked")},
+			wantStatus: fiber.StatusTooManyRequests,
+			wantError:  "OTP_BLOCKED",
+			wantCalled: true,
+		},
+		{
+			name:       "unknown barcode",
+			barcode:    "PRV10009999999",
+			wantStatus: fiber.StatusNotFound,
+		},
+		{
+			name:       "locked by another postman",
+			lockErr:    &booking_lock.LockedError{HolderID: 99, Purpose: bookingModel.LockPurposeDeliveryConfirmation, ExpiresAt: future},
+			wantStatus: fiber.StatusLocked,
+		},
+		{
+			name:       "phone already confirmed",
+			booking:    func(b *bookingModel.Booking) { b.DeliveryPhoneConfirmedVerified = true },
+			wantStatus: fiber.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			postman := factory.BuildUser(factory.WithPermissions(constants.PermPostmanFull))
+			postman.ID = 1
+			barcode := testBarcode()
+			deliveryPhone := "+8801911111111"
+			booking := factory.BuildBooking(func(b *bookingModel.Booking) {
+				b.Barcode = &barcode
+				b.DeliveryPhone = &deliveryPhone
+				b.Status = bookingModel.BookingItemStatusReceivedByPostman
+				if tt.booking != nil {
+					tt.booking(b)
+				}
+			})
+			bookings := &mock.Bookings{LockErr: tt.lockErr}
+			booking.ID = bookings.Add(booking)
+			requested := barcode
+			if tt.barcode != "" {
+				requested = tt.barcode
+			}
+
+			verify := tt.verify
+			if verify.Record != nil {
+				record := *verify.Record
+				record.BookingID = booking.ID
+				if tt.otherBooking {
+					record.BookingID = booking.ID + 1
+				}
+				verify.Record = &record
+			}
+			otps := &mock.OTPService{Verify: verify, Clock: tt.clock}
+			app := fiber.New()
+			app.Post("/verify", testauth.As(&postman, constants.PermPostmanFull), newTestController(bookings, otps, &mock.DMS{}).DeliveryConfirmationVerifyOtp)
+
+			status, resp := send(t, app, "/verify", map[string]interface{}{
+				"booking_id":  requested,
+				"otp_code":    "123456",
+				"purpose":     otp.OTPPurposeDeliveryConfirmPhone,
+				"otp_session": "session",
+			})
+			if status != tt.wantStatus {
+				t.Fatalf("status %d, want %d (%s)", status, tt.wantStatus, resp.Message)
+			}
+			if tt.wantError != "" && resp.Data["error"] != tt.wantError {
+				t.Errorf("error %v, want %s", resp.Data["error"], tt.wantError)
+			}
+
+			calls := otps.Calls()
+			if called := len(calls) > 0; called != tt.wantCalled {
+				t.Fatalf("OTP service called = %v, want %v", called, tt.wantCalled)
+			}
+			if tt.wantCalled {
+				want := mock.VerifyCall{Phone: deliveryPhone, Code: "123456", Purpose: otp.OTPPurposeDeliveryConfirmPhone, BookingID: booking.ID, Session: "session", ActorID: postman.ID}
+				if calls[0] != want {
+					t.Errorf("verified %+v, want %+v", calls[0], want)
+				}
+			}
+
+			if stored := len(bookings.Confirmed()) > 0; stored != tt.wantStored {
+				t.Fatalf("delivery phone confirmation stored = %v, want %v", stored, tt.wantStored)
+			}
+			if tt.wantStored {
+				stored, _ := bookings.Get(booking.ID)
+				if !stored.DeliveryPhoneConfirmedVerified || stored.DeliveryPhoneConfirmedOTPEncrypted == nil {
+					t.Errorf("stored booking not confirmed: verified %v", stored.DeliveryPhoneConfirmedVerified)
+				}
+			}
+		})
+	}
+}
+
+// TestItemDeliveryRequest covers the rejections made before the booking is loaded
+func TestItemDeliveryRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        interface{}
+		auth        bool
+		wantStatus  int
+		wantMessage string
+	}{
+		{"malformed body", "{", true, fiber.StatusBadRequest, "Invalid request body"},
+		{"missing booking", map[string]interface{}{}, true, fiber.StatusBadRequest, "booking_id is required"},
+		{"latitude without longitude", map[string]interface{}{"booking_id": "PRV10000000001", "latitude": 23.8}, true, fiber.StatusBadRequest, "latitude and longitude must be provided together"},
+		{"no postman", map[string]interface{}{"booking_id": "PRV10000000001"}, false, fiber.StatusUnauthorized, "Postman not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mock.DMS{}
+			dc := newTestController(&mock.Bookings{}, &mock.OTPService{}, client)
+			app := fiber.New()
+			if tt.auth {
+				postman := factory.BuildUser()
+				app.Use(testauth.As(&postman, constants.PermPostmanFull))
+			}
+			app.Post("/deliver", dc.ItemDelivery)
+
+			status, resp := send(t, app, "/deliver", tt.body)
+			if status != tt.wantStatus {
+				t.Errorf("status %d, want %d", status, tt.wantStatus)
+			}
+			if !strings.Contains(resp.Message, tt.wantMessage) {
+				t.Errorf("message %q, want it to contain %q", resp.Message, tt.wantMessage)
+			}
+			if len(client.Delivered()) != 0 {
+				t.Error("delivery reported to DMS for a rejected request")
+			}
+		})
+	}
+}
+
+func TestItemDelivery(t *testing.T) {
+	t.Setenv("ID_VERIFICATION_MODE", "off")
+	ok := &dms.Response{StatusCode: http.StatusOK, Body: []byte(`{"status":"success"}`)}
+	hold := &bookingModel.DeliveryHold{StartsAt: time.Now().Add(-time.Hour), EndsAt: time.Now().Add(time.Hour), Status: bookingModel.DeliveryHoldStatusActive}
+	tests := []struct {
+		name        string
+		booking     func(b *bookingModel.Booking, postmanID uint)
+		deliver     *dms.Response
+		deliverErr  error
+		lockErr     error
+		holdErr     error
+		fraud       fraud.Decision
+		wantStatus  int
+		wantCalled  bool
+		wantBooking bookingModel.BookingStatus
+	}{
+		{
+			name:        "delivered",
+			deliver:     ok,
+			wantStatus:  fiber.StatusOK,
+			wantCalled:  true,
+			wantBooking: bookingModel.BookingStatusDelivered,
+		},
+		{
+			name:        "DMS refuses",
+			deliver:     &dms.Response{StatusCode: http.StatusInternalServerError, Body: []byte(`{"status":"error"}`)},
+			wantStatus:  fiber.StatusBadGateway,
+			wantCalled:  true,
+			wantBooking: bookingModel.BookingItemStatusReceivedByPostman,
+		},
+		{
+			name:        "DMS unreachable",
+			deliverErr:  errors.New("connection refused"),
+			wantStatus:  fiber.StatusInternalServerError,
+			wantCalled:  true,
+			wantBooking: bookingModel.BookingItemStatusReceivedByPostman,
+		},
+		{
+			name:        "DMS not configured",
+			deliverErr:  dms.ErrBaseURLNotSet,
+			wantStatus:  fiber.StatusInternalServerError,
+			wantCalled:  true,
+			wantBooking: bookingModel.BookingItemStatusReceivedByPostman,
+		},
+		{
+			name:        "locked by another postman",
+			deliver:     ok,
+			lockErr:     &booking_lock.LockedError{HolderID: 99, Purpose: bookingModel.LockPurposeDeliveryConfirmation, ExpiresAt: time.Now().Add(time.Hour)},
+			wantStatus:  fiber.StatusLocked,
+			wantBooking: bookingModel.BookingItemStatusReceivedByPostman,
+		},
+		{
+			name:        "hold started after pickup",
+			deliver:     ok,
+			holdErr:     &delivery_hold.OnHoldError{Hold: hold},
+			wantStatus:  fiber.StatusConflict,
+			wantBooking: bookingModel.BookingItemStatusReceivedByPostman,
+		},
+		{
+			name:        "held for fraud review",
+			deliver:     ok,
+			fraud:       fraud.Decision{Action: fraudModel.RuleActionBlock},
+			wantStatus:  fiber.StatusConflict,
+			wantBooking: bookingModel.BookingItemStatusReceivedByPostman,
+		},
+		{
+			name:        "not received yet",
+			booking:     func(b *bookingModel.Booking, _ uint) { b.Status = bookingModel.BookingStatusBooked },
+			deliver:     ok,
+			wantStatus:  fiber.StatusBadRequest,
+			wantBooking: bookingModel.BookingStatusBooked,
+		},
+		{
+			name: "received by another postman",
+			booking: func(b *bookingModel.Booking, postmanID uint) {
+				b.UpdatedBy = strconv.FormatUint(uint64(postmanID+1), 10)
+			},
+			deliver:     ok,
+			wantStatus:  fiber.StatusBadRequest,
+			wantBooking: bookingModel.BookingItemStatusReceivedByPostman,
+		},
+		{
+			name:        "phone not confirmed",
+			booking:     func(b *bookingModel.Booking, _ uint) { b.DeliveryPhoneConfirmedVerified = false },
+			deliver:     ok,
+			wantStatus:  fiber.StatusBadRequest,
+			wantBooking: bookingModel.BookingItemStatusReceivedByPostman,
+		},
+		{
+			name:        "no photo",
+			booking:     func(b *bookingModel.Booking, _ uint) { b.UploadPhoto = nil },
+			deliver:     ok,
+			wantStatus:  fiber.StatusBadRequest,
+			wantBooking: bookingModel.BookingItemStatusReceivedByPostman,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			postman := factory.BuildUser(factory.WithPermissions(constants.PermPostmanFull))
+			postman.ID = 1
+			barcode := testBarcode()
+			photo := "delivery/photo.jpg"
+			booking := factory.BuildBooking(func(b *bookingModel.Booking) {
+				b.Barcode = &barcode
+				b.Status = bookingModel.BookingItemStatusReceivedByPostman
+				b.UpdatedBy = strconv.FormatUint(uint64(postman.ID), 10)
+				b.DeliveryPhoneConfirmedVerified = true
+				b.DeliveryApplicationIDVerified = true
+				b.UploadPhoto = &photo
+				if tt.booking != nil {
+					tt.booking(b, postman.ID)
+				}
+			})
+			bookings := &mock.Bookings{LockErr: tt.lockErr, HoldErr: tt.holdErr, Fraud: tt.fraud}
+			booking.ID = bookings.Add(booking)
+
+			client := &mock.DMS{Deliver: tt.deliver, DeliverErr: tt.deliverErr}
+			app := fiber.New()
+			app.Post("/deliver", testauth.As(&postman, constants.PermPostmanFull), newTestController(bookings, &mock.OTPService{}, client).ItemDelivery)
+
+			status, resp := send(t, app, "/deliver", map[string]interface{}{"booking_id": barcode})
+			if status != tt.wantStatus {
+				t.Fatalf("status %d, want %d (%s)", status, tt.wantStatus, resp.Message)
+			}
+
+			delivered := client.Delivered()
+			if called := len(delivered) > 0; called != tt.wantCalled {
+				t.Fatalf("DMS called = %v, want %v", called, tt.wantCalled)
+			}
+			if tt.wantCalled && delivered[0].ArticleID != barcode {
+				t.Errorf("DMS delivered %s, want %s", delivered[0].ArticleID, barcode)
+			}
+
+			stored, _ := bookings.Get(booking.ID)
+			if stored.Status != tt.wantBooking {
+				t.Errorf("booking status %s, want %s", stored.Status, tt.wantBooking)
+			}
+		})
+	}
+}
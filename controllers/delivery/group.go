@@ -0,0 +1,426 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/services/delivery_group"
+	"passport-booking/services/delivery_hold"
+	"passport-booking/services/fraud"
+	"passport-booking/services/id_verification"
+	"passport-booking/types"
+	deliveryTypes "passport-booking/types/delivery"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// currentPostman resolves the authenticated postman, writing the error response itself on failure
+func (dc *DeliveryController) currentPostman(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "Postman not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// loadOwnGroup loads the group in the :id param and checks it belongs to the postman
+func (dc *DeliveryController) loadOwnGroup(c *fiber.Ctx, postmanID uint) (*bookingModel.DeliveryGroup, error) {
+	groupID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid delivery group ID",
+			Data:    nil,
+		})
+	}
+
+	group, err := delivery_group.Find(dc.DB, uint(groupID))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Delivery group not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to load delivery group", err)
+		return nil, dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if group.PostmanID != postmanID {
+		return nil, dc.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+			Status:  fiber.StatusForbidden,
+			Message: "You can only manage your own delivery groups",
+			Data:    nil,
+		})
+	}
+	return group, nil
+}
+
+// CreateGroup links bookings for the same recipient and address into a delivery group
+func (dc *DeliveryController) CreateGroup(c *fiber.Ctx) error {
+	var req deliveryTypes.CreateDeliveryGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	postmanInfo, err := dc.currentPostman(c)
+	if postmanInfo == nil {
+		return err
+	}
+
+	var bookings []bookingModel.Booking
+	if err := booking_resolver.FindMany(dc.DB.Preload("DeliveryAddress").Order("id"), req.BookingIDs, req.IdentifierType, &bookings); err != nil {
+		logger.Error("Failed to find bookings for delivery group", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+	if len(bookings) != len(req.BookingIDs) {
+		return dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "One or more bookings not found",
+			Data:    nil,
+		})
+	}
+
+	for _, b := range bookings {
+		if err := dc.Bookings.CheckLock(b.ID, postmanInfo.ID); err != nil {
+			return dc.lockedResponse(c, err)
+		}
+	}
+
+	group, err := delivery_group.Create(dc.DB.WithContext(c.UserContext()), postmanInfo.ID, bookings)
+	if err != nil {
+		var validationErr *delivery_group.ValidationError
+		if errors.As(err, &validationErr) {
+			return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: validationErr.Error(),
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to create delivery group", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to create delivery group",
+			Data:    nil,
+		})
+	}
+
+	group, err = delivery_group.Find(dc.DB, group.ID)
+	if err != nil {
+		logger.Error("Failed to reload delivery group", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	logger.Success(fmt.Sprintf("Delivery group %d created with %d bookings by postman: %s", group.ID, len(group.Bookings), postmanInfo.LegalName))
+
+	return dc.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Delivery group created successfully",
+		Data:    deliveryTypes.NewDeliveryGroupResponse(group),
+	})
+}
+
+// ShowGroup returns a delivery group with its bookings
+func (dc *DeliveryController) ShowGroup(c *fiber.Ctx) error {
+	postmanInfo, err := dc.currentPostman(c)
+	if postmanInfo == nil {
+		return err
+	}
+
+	group, err := dc.loadOwnGroup(c, postmanInfo.ID)
+	if group == nil {
+		return err
+	}
+
+	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delivery group retrieved successfully",
+		Data:    deliveryTypes.NewDeliveryGroupResponse(group),
+	})
+}
+
+// DissolveGroup releases the bookings of an open group for individual delivery
+func (dc *DeliveryController) DissolveGroup(c *fiber.Ctx) error {
+	postmanInfo, err := dc.currentPostman(c)
+	if postmanInfo == nil {
+		return err
+	}
+
+	group, err := dc.loadOwnGroup(c, postmanInfo.ID)
+	if group == nil {
+		return err
+	}
+
+	if err := delivery_group.Dissolve(dc.DB.WithContext(c.UserContext()), group.ID, strconv.FormatUint(uint64(postmanInfo.ID), 10)); err != nil {
+		if errors.Is(err, delivery_group.ErrGroupNotOpen) {
+			return dc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+				Status:  fiber.StatusConflict,
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to dissolve delivery group", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to dissolve delivery group",
+			Data:    nil,
+		})
+	}
+
+	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delivery group dissolved successfully",
+		Data:    nil,
+	})
+}
+
+// DeliverGroup delivers every remaining booking of a group after a single OTP
+// confirmation and photo. All members are checked before any is reported to DMS.
+func (dc *DeliveryController) DeliverGroup(c *fiber.Ctx) error {
+	var req deliveryTypes.DeliverGroupRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid request body",
+				Data:    nil,
+			})
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "Authorization header is required",
+			Data:    nil,
+		})
+	}
+
+	postmanInfo, err := dc.currentPostman(c)
+	if postmanInfo == nil {
+		return err
+	}
+
+	group, err := dc.loadOwnGroup(c, postmanInfo.ID)
+	if group == nil {
+		return err
+	}
+
+	if group.Status != bookingModel.DeliveryGroupStatusOpen {
+		return dc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: delivery_group.ErrGroupNotOpen.Error(),
+			Data:    nil,
+		})
+	}
+
+	// Check every pending member before reporting any delivery upstream
+	postmanIDStr := strconv.FormatUint(uint64(postmanInfo.ID), 10)
+	pending := make([]*bookingModel.Booking, 0, len(group.Bookings))
+	problems := make(map[uint]string)
+	for i := range group.Bookings {
+		b := &group.Bookings[i]
+		if b.Status == bookingModel.BookingStatusDelivered {
+			continue
+		}
+		pending = append(pending, b)
+
+		if err := dc.Bookings.CheckLock(b.ID, postmanInfo.ID); err != nil {
+			return dc.lockedResponse(c, err)
+		}
+
+		if err := dc.Bookings.CheckHold(b.ID); err != nil {
+			var onHoldErr *delivery_hold.OnHoldError
+			if !errors.As(err, &onHoldErr) {
+				return dc.onHoldResponse(c, err)
+			}
+			problems[b.ID] = "Item is on hold at the branch at the applicant's request"
+			continue
+		}
+
+		switch {
+		case b.Status != bookingModel.BookingItemStatusReceivedByPostman || b.UpdatedBy != postmanIDStr:
+			problems[b.ID] = "Item must be received by you before delivery"
+		case !b.DeliveryPhoneConfirmedVerified:
+			problems[b.ID] = "Delivery phone must be confirmed and verified before delivery"
+		case !b.DeliveryApplicationIDVerified:
+			problems[b.ID] = "Application ID must be verified before delivery"
+		case b.UploadPhoto == nil || *b.UploadPhoto == "":
+			problems[b.ID] = "Photo must be uploaded before delivery"
+		case id_verification.Required(b):
+			problems[b.ID] = "Recipient ID must be verified before delivery"
+		}
+	}
+
+	if len(pending) == 0 {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "All bookings in the group are already delivered",
+			Data:    nil,
+		})
+	}
+
+	if len(problems) > 0 {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Some bookings in the group are not ready for delivery",
+			Data:    map[string]interface{}{"problems": problems},
+		})
+	}
+
+	// Run the anti-fraud rules on each member; one held booking holds the group
+	now := time.Now()
+	for _, b := range pending {
+		decision, err := dc.Bookings.EvaluateFraud(fraud.Signals{
+			Booking:   b,
+			PostmanID: postmanInfo.ID,
+			Latitude:  req.Latitude,
+			Longitude: req.Longitude,
+			Now:       now,
+		})
+		if err != nil {
+			logger.Error("Failed to evaluate fraud rules", err)
+		}
+		if decision.Blocked() {
+			var caseID uint
+			if decision.Case != nil {
+				caseID = decision.Case.ID
+			}
+			logger.Warning(fmt.Sprintf("Delivery group %d held for supervisor review (booking %d, fraud case %d)", group.ID, b.ID, caseID))
+			return dc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+				Status:  fiber.StatusConflict,
+				Message: "Delivery is held for supervisor review",
+				Data: map[string]interface{}{
+					"code":          "DELIVERY_HELD",
+					"booking_id":    b.ID,
+					"fraud_case_id": caseID,
+					"rules":         decision.Hits,
+				},
+			})
+		}
+	}
+
+	results := make([]deliveryTypes.GroupDeliveryResult, 0, len(pending))
+	delivered := 0
+	for _, b := range pending {
+		result := deliveryTypes.GroupDeliveryResult{BookingID: b.ID, Barcode: *b.Barcode}
+
+		resp, err := dc.DMS.DeliverArticle(authHeader, dms.DeliverArticleRequest{
+			ArticleID: *b.Barcode,
+		})
+		if err != nil {
+			if errors.Is(err, dms.ErrBaseURLNotSet) {
+				logger.Error("DMS_BASE_URL environment variable is not set", nil)
+				return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+					Status:  fiber.StatusInternalServerError,
+					Message: "External service configuration error",
+					Data:    nil,
+				})
+			}
+			logger.Error("Failed to call external delivery API", err)
+			result.Error = "Failed to connect to external delivery service"
+			results = append(results, result)
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			var externalAPIResponse interface{}
+			if err := json.Unmarshal(resp.Body, &externalAPIResponse); err != nil {
+				externalAPIResponse = string(resp.Body)
+			}
+			logger.Error(fmt.Sprintf("External delivery API returned error for booking %d: %d (%v)", b.ID, resp.StatusCode, externalAPIResponse), nil)
+			result.Error = "External delivery service failed"
+			results = append(results, result)
+			continue
+		}
+
+		if err := dc.markDelivered(c, b, postmanInfo, req.Latitude, req.Longitude); err != nil {
+			var onHoldErr *delivery_hold.OnHoldError
+			if errors.As(err, &onHoldErr) {
+				result.Error = "Item is on hold at the branch at the applicant's request"
+				results = append(results, result)
+				continue
+			}
+			logger.Error("Failed to update booking status after delivery", err)
+			result.Error = "Failed to update booking status"
+			results = append(results, result)
+			continue
+		}
+
+		result.Delivered = true
+		delivered++
+		results = append(results, result)
+	}
+
+	logger.Success(fmt.Sprintf("Delivery group %d: %d of %d bookings delivered by postman: %s", group.ID, delivered, len(pending), postmanInfo.LegalName))
+
+	if reloaded, err := delivery_group.Find(dc.DB, group.ID); err == nil {
+		group = reloaded
+	}
+
+	status := fiber.StatusOK
+	message := "Delivery group delivered successfully"
+	if delivered < len(pending) {
+		status = fiber.StatusBadGateway
+		message = "Some bookings in the group could not be delivered"
+	}
+
+	return dc.sendResponseWithLog(c, status, types.ApiResponse{
+		Status:  status,
+		Message: message,
+		Data: map[string]interface{}{
+			"group":        deliveryTypes.NewDeliveryGroupResponse(group),
+			"results":      results,
+			"postman_id":   postmanInfo.ID,
+			"postman_name": postmanInfo.LegalName,
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package delivery
+
+import (
+	"errors"
+	"passport-booking/logger"
+	"passport-booking/services/delivery_hold"
+	"passport-booking/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// onHoldResponse answers 409 Conflict when the applicant put the booking on hold,
+// or 500 when the hold could not be checked
+func (dc *DeliveryController) onHoldResponse(c *fiber.Ctx, err error) error {
+	var onHoldErr *delivery_hold.OnHoldError
+	if errors.As(err, &onHoldErr) {
+		return dc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Item is on hold at the branch at the applicant's request",
+			Data: map[string]interface{}{
+				"code":       "DELIVERY_ON_HOLD",
+				"hold_id":    onHoldErr.Hold.ID,
+				"hold_until": onHoldErr.Hold.EndsAt,
+			},
+		})
+	}
+
+	logger.Error("Failed to check delivery hold", err)
+	return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+		Status:  fiber.StatusInternalServerError,
+		Message: "Internal server error",
+		Data:    nil,
+	})
+}
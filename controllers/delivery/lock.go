@@ -0,0 +1,33 @@
+package delivery
+
+import (
+	"errors"
+	"passport-booking/logger"
+	"passport-booking/services/booking_lock"
+	"passport-booking/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// lockedResponse answers 423 Locked when another postman holds the booking,
+// or 500 when the lock could not be checked
+func (dc *DeliveryController) lockedResponse(c *fiber.Ctx, err error) error {
+	var lockedErr *booking_lock.LockedError
+	if errors.As(err, &lockedErr) {
+		return dc.sendResponseWithLog(c, fiber.StatusLocked, types.ApiResponse{
+			Status:  fiber.StatusLocked,
+			Message: "Booking is locked by another delivery confirmation in progress",
+			Data: map[string]interface{}{
+				"purpose":    lockedErr.Purpose,
+				"expires_at": lockedErr.ExpiresAt,
+			},
+		})
+	}
+
+	logger.Error("Failed to check booking lock", err)
+	return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+		Status:  fiber.StatusInternalServerError,
+		Message: "Internal server error",
+		Data:    nil,
+	})
+}
@@ -0,0 +1,592 @@
+package delivery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"passport-booking/database"
+	"passport-booking/httpServices/dms"
+	"passport-booking/httpServices/sms"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	otpModel "passport-booking/models/otp"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/booking_lock"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/services/clock"
+	"passport-booking/services/delivery_hold"
+	"passport-booking/services/notification"
+	"passport-booking/services/offline_sync"
+	otpService "passport-booking/services/otp"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	deliveryTypes "passport-booking/types/delivery"
+	"passport-booking/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// OfflineKey hands the postman's app the key it signs offline bundles with.
+// The app fetches it while online and keeps it for when coverage drops.
+func (dc *DeliveryController) OfflineKey(c *fiber.Ctx) error {
+	postman, err := dc.currentPostman(c)
+	if postman == nil {
+		return err
+	}
+
+	key, err := offline_sync.DeviceKey(postman.ID)
+	if errors.Is(err, offline_sync.ErrDisabled) {
+		return dc.sendResponseWithLog(c, fiber.StatusServiceUnavailable, types.ApiResponse{
+			Status:  fiber.StatusServiceUnavailable,
+			Message: "Offline sync is not enabled",
+			Data:    nil,
+		})
+	}
+
+	// Not written to the API log; anyone holding the key can sign bundles
+	return c.Status(fiber.StatusOK).JSON(types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Offline sync key retrieved successfully",
+		Data: fiber.Map{
+			"key":         key,
+			"algorithm":   "HMAC-SHA256",
+			"max_actions": offline_sync.MaxActions(),
+			"max_age":     offline_sync.MaxAge().String(),
+		},
+	})
+}
+
+// OfflineSync applies a signed bundle of actions the postman performed offline.
+// Actions run in the order they were performed and each gets its own result:
+// the server state wins over an action the booking has since moved past.
+func (dc *DeliveryController) OfflineSync(c *fiber.Ctx) error {
+	postman, err := dc.currentPostman(c)
+	if postman == nil {
+		return err
+	}
+
+	rawBundle := c.FormValue("bundle")
+	signature := c.FormValue("signature")
+	if rawBundle == "" || signature == "" {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "bundle and signature are required",
+			Data:    nil,
+		})
+	}
+
+	if err := offline_sync.Verify(postman.ID, rawBundle, signature); err != nil {
+		if errors.Is(err, offline_sync.ErrDisabled) {
+			return dc.sendResponseWithLog(c, fiber.StatusServiceUnavailable, types.ApiResponse{
+				Status:  fiber.StatusServiceUnavailable,
+				Message: "Offline sync is not enabled",
+				Data:    nil,
+			})
+		}
+		logger.Warning(fmt.Sprintf("Rejected offline bundle with an invalid signature from postman %d", postman.ID))
+		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "Invalid bundle signature",
+			Data:    nil,
+		})
+	}
+
+	var bundle deliveryTypes.OfflineBundle
+	if err := json.Unmarshal([]byte(rawBundle), &bundle); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid bundle",
+			Data:    nil,
+		})
+	}
+	if err := bundle.Validate(offline_sync.MaxActions()); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	actions := bundle.Actions
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].PerformedAt.Before(actions[j].PerformedAt) })
+
+	results := make([]deliveryTypes.OfflineActionResult, 0, len(actions))
+	counts := map[string]int{}
+	for _, action := range actions {
+		result := dc.syncOfflineAction(c, postman, bundle.BundleID, action)
+		counts[result.Result]++
+		results = append(results, result)
+	}
+
+	logger.Info(fmt.Sprintf("Offline bundle %s from postman %d synced: %v", bundle.BundleID, postman.ID, counts))
+
+	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Offline bundle synced",
+		Data: fiber.Map{
+			"bundle_id": bundle.BundleID,
+			"summary":   counts,
+			"results":   results,
+		},
+	})
+}
+
+// syncOfflineAction applies one action at most once and reports its result. An
+// action synced before returns its stored result.
+func (dc *DeliveryController) syncOfflineAction(c *fiber.Ctx, postman *userModel.User, bundleID string, action deliveryTypes.OfflineAction) deliveryTypes.OfflineActionResult {
+	result := deliveryTypes.OfflineActionResult{ActionID: action.ID, Type: action.Type, Barcode: action.Barcode}
+
+	record := &bookingModel.OfflineSyncAction{
+		PostmanID:   postman.ID,
+		ActionID:    action.ID,
+		BundleID:    bundleID,
+		Type:        action.Type,
+		Barcode:     action.Barcode,
+		PerformedAt: action.PerformedAt,
+	}
+	claimed, err := offline_sync.Claim(dc.DB, record)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to claim offline action %s", action.ID), err)
+		result.Result, result.Message = deliveryTypes.OfflineResultRetry, "Failed to record the action"
+		return result
+	}
+	if !claimed {
+		previous, err := offline_sync.Recorded(dc.DB, postman.ID, action.ID)
+		if err != nil || previous.Result == offline_sync.ResultPending {
+			result.Result, result.Message = deliveryTypes.OfflineResultRetry, "Action is still being synced"
+			return result
+		}
+		result.Result, result.Message, result.BookingID = previous.Result, previous.Message, previous.BookingID
+		result.Replayed = true
+		return result
+	}
+
+	outcome, message := dc.applyOfflineAction(c, postman, action, record)
+	if err := offline_sync.Finish(dc.DB, record, outcome, message); err != nil {
+		logger.Error(fmt.Sprintf("Failed to store the result of offline action %s", action.ID), err)
+	}
+	result.Result, result.Message, result.BookingID = outcome, message, record.BookingID
+	return result
+}
+
+// applyOfflineAction checks an action against the booking as it is now and applies
+// it, setting the record's booking ID. It returns the result and its message.
+func (dc *DeliveryController) applyOfflineAction(c *fiber.Ctx, postman *userModel.User, action deliveryTypes.OfflineAction, record *bookingModel.OfflineSyncAction) (string, string) {
+	if err := offline_sync.CheckPerformedAt(action.PerformedAt, time.Now()); err != nil {
+		return deliveryTypes.OfflineResultRejected, err.Error()
+	}
+
+	var booking bookingModel.Booking
+	if err := dc.DB.Preload("User").Scopes(database.NotDeletedBookings).Where("barcode = ?", action.Barcode).First(&booking).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return deliveryTypes.OfflineResultRejected, "Booking not found"
+		}
+		logger.Error("Failed to find booking for offline action", err)
+		return deliveryTypes.OfflineResultRetry, "Failed to find booking"
+	}
+	record.BookingID = &booking.ID
+
+	// Another postman may be mid-way through confirming this delivery
+	if err := dc.Bookings.CheckLock(booking.ID, postman.ID); err != nil {
+		var lockedErr *booking_lock.LockedError
+		if errors.As(err, &lockedErr) {
+			return deliveryTypes.OfflineResultConflict, "Booking is locked by another delivery confirmation in progress"
+		}
+		logger.Error("Failed to check booking lock", err)
+		return deliveryTypes.OfflineResultRetry, "Failed to check booking lock"
+	}
+
+	switch action.Type {
+	case deliveryTypes.OfflineActionReceive:
+		return dc.applyOfflineReceive(c, &booking, action.Barcode)
+	case deliveryTypes.OfflineActionVerifyOTP:
+		return dc.applyOfflineVerifyOTP(c, postman, &booking, action)
+	case deliveryTypes.OfflineActionDeliveryCode:
+		return dc.applyOfflineDeliveryCode(c, postman, &booking, action)
+	default:
+		return dc.applyOfflinePhoto(c, postman, &booking, action)
+	}
+}
+
+func receivedByPostman(booking *bookingModel.Booking) bool {
+	return booking.Status == bookingModel.BookingStatusReceivedByPostman || booking.Status == bookingModel.BookingItemStatusReceivedByPostman
+}
+
+// applyOfflineReceive receives the item from its bag in DMS as ReceiveItem does
+func (dc *DeliveryController) applyOfflineReceive(c *fiber.Ctx, booking *bookingModel.Booking, barcode string) (string, string) {
+	if receivedByPostman(booking) {
+		return deliveryTypes.OfflineResultConflict, "Item was already received"
+	}
+	if booking.Status != bookingModel.BookingStatusReceivedByPostMaster {
+		return deliveryTypes.OfflineResultConflict, fmt.Sprintf("Booking is %s and can no longer be received", booking.Status)
+	}
+
+	if err := dc.Bookings.CheckHold(booking.ID); err != nil {
+		var onHoldErr *delivery_hold.OnHoldError
+		if errors.As(err, &onHoldErr) {
+			return deliveryTypes.OfflineResultConflict, "Item is on hold at the branch at the applicant's request"
+		}
+		logger.Error("Failed to check delivery hold", err)
+		return deliveryTypes.OfflineResultRetry, "Failed to check delivery hold"
+	}
+	if booking.CurrentBagID == nil {
+		return deliveryTypes.OfflineResultRejected, "No bag_id found for this booking"
+	}
+
+	resp, err := dc.DMS.ReceiveBagItem(c.Get("Authorization"), dms.ReceiveBagItemRequest{
+		BagID:      *booking.CurrentBagID,
+		ItemID:     barcode,
+		ReceiveAll: "1",
+	})
+	if err != nil {
+		logger.Error("Failed to receive offline item in DMS", err)
+		return deliveryTypes.OfflineResultRetry, "DMS is unavailable"
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := "Item reception failed"
+		var body map[string]interface{}
+		if json.Unmarshal(resp.Body, &body) == nil {
+			if m, ok := body["message"].(string); ok && m != "" {
+				message = m
+			}
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return deliveryTypes.OfflineResultRetry, message
+		}
+		return deliveryTypes.OfflineResultRejected, message
+	}
+
+	if err := dc.updateBookingAfterItemReceived(barcode, c); err != nil {
+		logger.Error("Failed to update booking after offline item received", err)
+	}
+	return deliveryTypes.OfflineResultApplied, "Item received"
+}
+
+// applyOfflineVerifyOTP checks a delivery OTP the postman captured offline. The
+// OTP must have been requested by this postman before going offline and is
+// judged as of when it was entered, so a code that expired before the sync
+// still counts.
+func (dc *DeliveryController) applyOfflineVerifyOTP(c *fiber.Ctx, postman *userModel.User, booking *bookingModel.Booking, action deliveryTypes.OfflineAction) (string, string) {
+	if !receivedByPostman(booking) {
+		return deliveryTypes.OfflineResultConflict, fmt.Sprintf("Booking is %s; the delivery phone can no longer be confirmed", booking.Status)
+	}
+	if booking.DeliveryPhone == nil {
+		return deliveryTypes.OfflineResultRejected, "No delivery phone found for this booking"
+	}
+	if booking.DeliveryPhoneConfirmedVerified {
+		return deliveryTypes.OfflineResultConflict, "Delivery phone is already confirmed"
+	}
+
+	asOf := &otpService.Service{DB: dc.DB, Clock: clock.NewFake(action.PerformedAt)}
+	purpose := otpModel.OTPPurposeDeliveryConfirmPhone
+	pending, err := asOf.GetOTPStatus(*booking.DeliveryPhone, purpose, booking.ID)
+	if err != nil {
+		logger.Error("Failed to look up OTP for offline verification", err)
+		return deliveryTypes.OfflineResultRetry, "Failed to look up OTP"
+	}
+	if pending == nil || pending.CreatedAt.After(action.PerformedAt) {
+		return deliveryTypes.OfflineResultRejected, "No OTP was valid for this booking when it was entered"
+	}
+	if pending.IssuedToID == nil || *pending.IssuedToID != postman.ID {
+		return deliveryTypes.OfflineResultRejected, "This OTP was not requested by this postman"
+	}
+
+	valid, otpRecord, err := asOf.VerifyOTPWithDetails(*booking.DeliveryPhone, action.OTPCode, purpose, booking.ID)
+	if err != nil {
+		return deliveryTypes.OfflineResultRejected, err.Error()
+	}
+	if !valid || otpRecord == nil || otpRecord.ID != pending.ID {
+		return deliveryTypes.OfflineResultRejected, "Invalid OTP"
+	}
+
+	if err := dc.confirmDeliveryPhone(c, booking, otpRecord.OTPCode, "delivery_phone_confirmed", postman.ID); err != nil {
+		// The OTP is spent, so retrying cannot succeed
+		logger.Error("Failed to record offline delivery phone confirmation", err)
+		return deliveryTypes.OfflineResultRejected, "OTP verified but the confirmation could not be saved; confirm the delivery online"
+	}
+	return deliveryTypes.OfflineResultApplied, "Delivery phone confirmed"
+}
+
+// applyOfflinePhoto stores a hand-over photo carried in the request, after
+// checking it is the file the signed bundle describes
+func (dc *DeliveryController) applyOfflinePhoto(c *fiber.Ctx, postman *userModel.User, booking *bookingModel.Booking, action deliveryTypes.OfflineAction) (string, string) {
+	if !receivedByPostman(booking) {
+		return deliveryTypes.OfflineResultConflict, fmt.Sprintf("Booking is %s; a delivery photo can no longer be added", booking.Status)
+	}
+	if booking.UploadPhoto != nil && *booking.UploadPhoto != "" && dc.Storage.Exists(*booking.UploadPhoto) {
+		return deliveryTypes.OfflineResultConflict, "Photo already uploaded for this booking"
+	}
+
+	file, err := c.FormFile(action.PhotoFile)
+	if err != nil {
+		return deliveryTypes.OfflineResultRejected, "Photo file is missing from the request"
+	}
+	if err := validateDeliveryPhoto(file); err != nil {
+		return deliveryTypes.OfflineResultRejected, err.Error()
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return deliveryTypes.OfflineResultRetry, "Failed to read photo file"
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, src)
+	src.Close()
+	if err != nil {
+		return deliveryTypes.OfflineResultRetry, "Failed to read photo file"
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != strings.ToLower(action.PhotoSHA256) {
+		return deliveryTypes.OfflineResultRejected, "Photo does not match the checksum in the signed bundle"
+	}
+
+	if _, _, err := dc.storeDeliveryPhoto(c, booking, file, postman.ID); err != nil {
+		return deliveryTypes.OfflineResultRetry, err.Error()
+	}
+	return deliveryTypes.OfflineResultApplied, "Photo uploaded"
+}
+
+// SetNoNetwork flags or clears a booking's delivery address as having no mobile
+// coverage, which lets offline delivery codes be issued for it. Postmasters and
+// admins only.
+func (dc *DeliveryController) SetNoNetwork(c *fiber.Ctx) error {
+	var req deliveryTypes.NoNetworkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	user, err := dc.currentPostman(c)
+	if user == nil {
+		return err
+	}
+
+	booking, err := dc.findOfflineBooking(c, req.BookingID, req.IdentifierType)
+	if booking == nil {
+		return err
+	}
+	if booking.DeliveryAddressID == nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Booking has no delivery address",
+			Data:    nil,
+		})
+	}
+
+	if err := offline_sync.SetNoNetwork(dc.DB, booking, *req.NoNetwork); err != nil {
+		logger.Error("Failed to update no-network flag", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update the delivery address",
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("Delivery address of booking %d marked no_network=%v by user %d", booking.ID, *req.NoNetwork, user.ID))
+
+	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delivery address updated successfully",
+		Data: fiber.Map{
+			"booking_id":          booking.ID,
+			"delivery_address_id": *booking.DeliveryAddressID,
+			"no_network":          *req.NoNetwork,
+		},
+	})
+}
+
+// IssueOfflineCode creates a one-time delivery code for a booking whose address has
+// no coverage and sends it to the applicant by SMS, or returns it once for printing
+// on the delivery notice. Any earlier code stops working. Postmasters and admins
+// only; the user who prints a code cannot redeem it.
+func (dc *DeliveryController) IssueOfflineCode(c *fiber.Ctx) error {
+	var req deliveryTypes.IssueOfflineCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	user, err := dc.currentPostman(c)
+	if user == nil {
+		return err
+	}
+
+	booking, err := dc.findOfflineBooking(c, req.BookingID, req.IdentifierType)
+	if booking == nil {
+		return err
+	}
+	if booking.Status == bookingModel.BookingStatusDelivered {
+		return dc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Booking is already delivered",
+			Data:    nil,
+		})
+	}
+
+	phone := booking.Phone
+	if booking.DeliveryPhone != nil && *booking.DeliveryPhone != "" {
+		phone = *booking.DeliveryPhone
+	}
+	if req.Channel == deliveryTypes.OfflineCodeChannelSMS && phone == "" {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Booking has no phone to send the code to; print it instead",
+			Data:    nil,
+		})
+	}
+
+	code, record, err := offline_sync.IssueCode(dc.DB, booking, req.Channel, user.ID, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, offline_sync.ErrNotNoNetwork):
+			return dc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+				Status:  fiber.StatusConflict,
+				Message: "The booking's delivery address is not flagged as no-network",
+				Data:    nil,
+			})
+		case errors.Is(err, offline_sync.ErrDisabled):
+			return dc.sendResponseWithLog(c, fiber.StatusServiceUnavailable, types.ApiResponse{
+				Status:  fiber.StatusServiceUnavailable,
+				Message: "Offline sync is not enabled",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to issue offline delivery code", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to issue offline delivery code",
+			Data:    nil,
+		})
+	}
+
+	if err := booking_event.SnapshotBookingToEvent(dc.DB.WithContext(c.UserContext()), booking, "offline_code_issued", strconv.FormatUint(uint64(user.ID), 10)); err != nil {
+		logger.Error("Failed to write booking event (offline_code_issued)", err)
+	}
+
+	data := fiber.Map{
+		"booking_id": booking.ID,
+		"code_id":    record.ID,
+		"channel":    record.Channel,
+		"expires_at": record.ExpiresAt,
+	}
+
+	if req.Channel == deliveryTypes.OfflineCodeChannelPrint {
+		// Shown once for the printed delivery notice; only its hash is stored, and
+		// this response is not written to the API log
+		data["code"] = code
+		logger.Info(fmt.Sprintf("Offline delivery code issued for printing for booking %d by user %d", booking.ID, user.ID))
+		return c.Status(fiber.StatusCreated).JSON(types.ApiResponse{
+			Status:  fiber.StatusCreated,
+			Message: "Offline delivery code issued; print it for the applicant",
+			Data:    data,
+		})
+	}
+
+	body, err := notification.RenderOfflineDeliveryCode(dc.DB, booking.ID, code, record.ExpiresAt)
+	if err == nil {
+		_, err = sms.NewSMSService().SendSMS(phone, body)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to send offline delivery code for booking %d", booking.ID), err)
+		return dc.sendResponseWithLog(c, fiber.StatusBadGateway, types.ApiResponse{
+			Status:  fiber.StatusBadGateway,
+			Message: "Failed to send the code by SMS; issue it again or print it",
+			Data:    data,
+		})
+	}
+
+	data["sent_to"] = utils.MaskPhone(phone)
+	logger.Info(fmt.Sprintf("Offline delivery code sent by SMS for booking %d by user %d", booking.ID, user.ID))
+	return dc.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Offline delivery code sent to the applicant",
+		Data:    data,
+	})
+}
+
+// findOfflineBooking resolves the booking in a request, writing the error response
+// itself when it cannot
+func (dc *DeliveryController) findOfflineBooking(c *fiber.Ctx, id string, idType bookingTypes.IdentifierType) (*bookingModel.Booking, error) {
+	var booking bookingModel.Booking
+	if err := booking_resolver.Find(dc.DB, id, idType, &booking); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find booking", err)
+		return nil, dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+	return &booking, nil
+}
+
+// applyOfflineDeliveryCode confirms the delivery with a code issued in advance for
+// a no-network address, standing in for the live OTP
+func (dc *DeliveryController) applyOfflineDeliveryCode(c *fiber.Ctx, postman *userModel.User, booking *bookingModel.Booking, action deliveryTypes.OfflineAction) (string, string) {
+	if !receivedByPostman(booking) {
+		return deliveryTypes.OfflineResultConflict, fmt.Sprintf("Booking is %s; the delivery can no longer be confirmed", booking.Status)
+	}
+	if booking.DeliveryPhoneConfirmedVerified {
+		return deliveryTypes.OfflineResultConflict, "Delivery is already confirmed"
+	}
+
+	noNetwork, err := offline_sync.NoNetwork(dc.DB, booking)
+	if err != nil {
+		logger.Error("Failed to check no-network flag", err)
+		return deliveryTypes.OfflineResultRetry, "Failed to check the delivery address"
+	}
+	if !noNetwork {
+		return deliveryTypes.OfflineResultRejected, "The delivery address is not flagged as no-network; verify the OTP instead"
+	}
+
+	code := strings.TrimSpace(action.DeliveryCode)
+	if err := offline_sync.RedeemCode(dc.DB, booking.ID, code, postman.ID, action.PerformedAt); err != nil {
+		if offline_sync.IsCodeError(err) {
+			return deliveryTypes.OfflineResultRejected, err.Error()
+		}
+		logger.Error("Failed to check offline delivery code", err)
+		return deliveryTypes.OfflineResultRetry, "Failed to check the delivery code"
+	}
+
+	if err := dc.confirmDeliveryPhone(c, booking, code, "delivery_code_confirmed", postman.ID); err != nil {
+		// The code is spent, so retrying cannot succeed
+		logger.Error("Failed to record offline delivery code confirmation", err)
+		return deliveryTypes.OfflineResultRejected, "Code accepted but the confirmation could not be saved; issue a new code"
+	}
+	return deliveryTypes.OfflineResultApplied, "Delivery confirmed with the offline code"
+}
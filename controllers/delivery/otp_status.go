@@ -0,0 +1,118 @@
+package delivery
+
+import (
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	otpTypes "passport-booking/types/otp"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BatchOTPStatus returns OTP and verification state for a list of bookings
+func (dc *DeliveryController) BatchOTPStatus(c *fiber.Ctx) error {
+	var req otpTypes.BatchOTPStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var bookings []bookingModel.Booking
+	if err := booking_resolver.FindMany(dc.DB, req.BookingIDs, req.IdentifierType, &bookings); err != nil {
+		logger.Error("Failed to fetch bookings for batch OTP status", err)
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	bookingsByKey := make(map[string]*bookingModel.Booking, len(bookings))
+	bookingIDs := make([]uint, 0, len(bookings))
+	for i := range bookings {
+		bookingsByKey[batchLookupKey(&bookings[i], req.IdentifierType)] = &bookings[i]
+		bookingIDs = append(bookingIDs, bookings[i].ID)
+	}
+
+	latestOTPs, err := dc.OTPService.GetLatestOTPsForBookings(bookingIDs, req.Purpose)
+	if err != nil {
+		logger.Error("Failed to fetch OTP records for batch OTP status", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch OTP status",
+			Data:    nil,
+		})
+	}
+
+	now := dc.OTPService.Now()
+	statuses := make([]otpTypes.BookingOTPStatus, 0, len(req.BookingIDs))
+	for _, identifier := range req.BookingIDs {
+		status := otpTypes.BookingOTPStatus{BookingID: identifier}
+
+		booking, found := bookingsByKey[identifier]
+		if !found {
+			statuses = append(statuses, status)
+			continue
+		}
+
+		status.Found = true
+		status.DeliveryPhoneAppliedVerified = booking.DeliveryPhoneAppliedVerified
+		status.DeliveryPhoneConfirmedVerified = booking.DeliveryPhoneConfirmedVerified
+		status.DeliveryApplicationIDVerified = booking.DeliveryApplicationIDVerified
+
+		if otpRecord, ok := latestOTPs[booking.ID]; ok {
+			status.OTPSent = true
+			status.OTPActive = otpRecord.IsValidAt(now)
+			status.OTPUsed = otpRecord.IsUsed
+			status.OTPBlocked = otpRecord.IsBlockedAt(now)
+			status.RemainingRetries = otpRecord.MaxRetries - otpRecord.RetryCount
+			status.BlockedUntil = otpRecord.BlockedUntil
+			status.ExpiresAt = &otpRecord.ExpiresAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	logger.Info(fmt.Sprintf("Batch OTP status served for %d bookings (%d found)", len(req.BookingIDs), len(bookings)))
+
+	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "OTP status fetched successfully",
+		Data:    statuses,
+	})
+}
+
+// batchLookupKey returns the identifier a booking was requested by
+func batchLookupKey(booking *bookingModel.Booking, idType bookingTypes.IdentifierType) string {
+	switch idType {
+	case bookingTypes.IdentifierTypeID:
+		return strconv.FormatUint(uint64(booking.ID), 10)
+	case bookingTypes.IdentifierTypeAppOrOrderID:
+		return booking.AppOrOrderID
+	case bookingTypes.IdentifierTypeReference:
+		if booking.Reference != nil {
+			return *booking.Reference
+		}
+		return ""
+	}
+	if booking.Barcode != nil {
+		return *booking.Barcode
+	}
+	return ""
+}
@@ -0,0 +1,221 @@
+package delivery
+
+import (
+	"fmt"
+	"passport-booking/httpServices/nid"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/services/id_verification"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"passport-booking/utils"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// VerifyRecipientID records the recipient's NID number and a photo of the card before
+// hand-over. The number is format-checked and, when a registry is configured, matched
+// against the applicant's name.
+func (dc *DeliveryController) VerifyRecipientID(c *fiber.Ctx) error {
+	if id_verification.CurrentMode() == id_verification.ModeOff {
+		return dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Recipient ID verification is not enabled",
+			Data:    nil,
+		})
+	}
+
+	bookingIDStr := c.FormValue("booking_id")
+	if bookingIDStr == "" {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Booking ID is required",
+			Data:    nil,
+		})
+	}
+
+	identifierType := bookingTypes.IdentifierType(c.FormValue("identifier_type"))
+	if err := bookingTypes.NormalizeIdentifierType(&identifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	nidNumber := id_verification.NormalizeNID(c.FormValue("nid_number"))
+	if err := id_verification.ValidateNIDFormat(nidNumber); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	file, err := c.FormFile("photo")
+	if err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Photo of the ID card is required",
+			Data:    nil,
+		})
+	}
+
+	allowedTypes := map[string]string{
+		"image/jpeg": ".jpg",
+		"image/jpg":  ".jpg",
+		"image/png":  ".png",
+		"image/webp": ".webp",
+	}
+	defaultExt, ok := allowedTypes[file.Header.Get("Content-Type")]
+	if !ok {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid file type. Only JPEG, PNG and WebP images are allowed",
+			Data:    nil,
+		})
+	}
+	if file.Size > 10<<20 {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "File size too large. Maximum size is 10MB",
+			Data:    nil,
+		})
+	}
+
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return dc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "Postman not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	postmanInfo := current.User
+
+	var booking bookingModel.Booking
+	if err := booking_resolver.Find(dc.DB, bookingIDStr, identifierType, &booking); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find booking", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if err := dc.Bookings.CheckLock(booking.ID, postmanInfo.ID); err != nil {
+		return dc.lockedResponse(c, err)
+	}
+
+	if booking.Status != bookingModel.BookingItemStatusReceivedByPostman {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Item must be received by postman before recipient ID verification",
+			Data:    nil,
+		})
+	}
+
+	// Optional registry lookup; without one the format check above is the verification
+	if dc.NID != nil {
+		result, err := dc.NID.Verify(nid.VerifyRequest{NID: nidNumber, Name: booking.Name})
+		if err != nil {
+			logger.Error("NID verification API call failed", err)
+			return dc.sendResponseWithLog(c, fiber.StatusBadGateway, types.ApiResponse{
+				Status:  fiber.StatusBadGateway,
+				Message: "NID verification service is unavailable",
+				Data:    nil,
+			})
+		}
+		if !result.Matched {
+			return dc.sendResponseWithLog(c, fiber.StatusUnprocessableEntity, types.ApiResponse{
+				Status:  fiber.StatusUnprocessableEntity,
+				Message: "NID does not match the applicant",
+				Data: map[string]interface{}{
+					"code":   "NID_MISMATCH",
+					"detail": result.Message,
+				},
+			})
+		}
+	}
+
+	encryptedNID, err := utils.EncryptData(nidNumber)
+	if err != nil {
+		logger.Error("Failed to encrypt NID number", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to record ID verification",
+			Data:    nil,
+		})
+	}
+
+	fileExt := strings.ToLower(filepath.Ext(file.Filename))
+	if fileExt == "" {
+		fileExt = defaultExt
+	}
+	filename := fmt.Sprintf("nid_%d_%s%s", booking.ID, time.Now().Format("20060102_150405"), fileExt)
+	filePath, err := dc.IDStorage.Save(file, filename)
+	if err != nil {
+		logger.Error("Failed to save ID photo", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to save uploaded file",
+			Data:    nil,
+		})
+	}
+
+	previousPhoto := booking.RecipientIDPhoto
+	now := time.Now()
+	last4 := id_verification.Last4(nidNumber)
+	if err := dc.DB.Model(&booking).Updates(map[string]interface{}{
+		"recipient_id_verified":    true,
+		"recipient_nid_encrypted":  encryptedNID,
+		"recipient_nid_last4":      last4,
+		"recipient_id_photo":       filePath,
+		"recipient_id_verified_at": now,
+	}).Error; err != nil {
+		logger.Error("Failed to update booking with ID verification", err)
+		dc.IDStorage.Remove(filePath)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to record ID verification",
+			Data:    nil,
+		})
+	}
+
+	// A re-verification replaces the earlier photo
+	if previousPhoto != nil && *previousPhoto != "" && *previousPhoto != filePath && dc.IDStorage.Exists(*previousPhoto) {
+		dc.IDStorage.Remove(*previousPhoto)
+	}
+
+	if err := booking_event.SnapshotBookingToEvent(dc.DB.WithContext(c.UserContext()), &booking, "recipient_id_verified", strconv.FormatUint(uint64(postmanInfo.ID), 10)); err != nil {
+		logger.Error("Failed to write booking event (recipient_id_verified)", err)
+	}
+
+	logger.Success(fmt.Sprintf("Recipient ID verified for booking ID: %d by postman: %s", booking.ID, postmanInfo.LegalName))
+
+	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Recipient ID verified successfully",
+		Data: fiber.Map{
+			"booking_id":          booking.ID,
+			"recipient_nid_last4": last4,
+			"registry_checked":    dc.NID != nil,
+			"verified_at":         now,
+		},
+	})
+}
@@ -0,0 +1,125 @@
+package delivery
+
+import (
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/booking_lock"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	deliveryTypes "passport-booking/types/delivery"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// MarkReturn records that the postman could not deliver the item and brought it back
+// to the branch, from where it is bagged back to the regional passport office
+func (dc *DeliveryController) MarkReturn(c *fiber.Ctx) error {
+	var req deliveryTypes.MarkReturnRequest
+	if err := c.BodyParser(&req); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	postmanInfo, err := dc.currentPostman(c)
+	if postmanInfo == nil {
+		return err
+	}
+
+	var booking bookingModel.Booking
+	if err := booking_resolver.Find(dc.DB, req.BookingID, req.IdentifierType, &booking); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return dc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find booking", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if err := dc.Bookings.CheckLock(booking.ID, postmanInfo.ID); err != nil {
+		return dc.lockedResponse(c, err)
+	}
+
+	postmanIDStr := strconv.FormatUint(uint64(postmanInfo.ID), 10)
+	if booking.Status != bookingModel.BookingItemStatusReceivedByPostman || booking.UpdatedBy != postmanIDStr {
+		return dc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "You can only return items that you have received for delivery",
+			Data:    nil,
+		})
+	}
+
+	if booking.DeliveryGroupID != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Booking belongs to a delivery group; dissolve the group first",
+			Data:    nil,
+		})
+	}
+
+	if err := booking.Status.CheckTransition(bookingModel.BookingStatusReturn); err != nil {
+		return dc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	booking.Status = bookingModel.BookingStatusReturn
+	booking.UpdatedBy = postmanIDStr
+	booking.ReturnReason = &req.Reason
+	if err := dc.DB.Save(&booking).Error; err != nil {
+		logger.Error("Failed to mark booking as returned", err)
+		return dc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update booking status",
+			Data:    nil,
+		})
+	}
+
+	bookingStatusEvent := bookingModel.BookingStatusEvent{
+		BookingID: booking.ID,
+		Status:    booking.Status,
+		CreatedBy: postmanIDStr,
+	}
+	if err := dc.DB.WithContext(c.UserContext()).Create(&bookingStatusEvent).Error; err != nil {
+		logger.Error("Failed to create booking status event for return", err)
+	}
+
+	if err := booking_event.SnapshotBookingToEvent(dc.DB.WithContext(c.UserContext()), &booking, "item_returned", postmanIDStr); err != nil {
+		logger.Error("Failed to write booking event (item_returned)", err)
+	}
+
+	if err := booking_lock.Release(dc.DB, booking.ID, postmanInfo.ID); err != nil {
+		logger.Error("Failed to release booking lock after return", err)
+	}
+
+	logger.Success(fmt.Sprintf("Item returned for booking ID: %d by postman: %s", booking.ID, postmanInfo.LegalName))
+
+	return dc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Item marked as returned",
+		Data:    bookingTypes.NewBookingResponse(&booking),
+	})
+}
@@ -0,0 +1,347 @@
+package evidence
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	evidenceModel "passport-booking/models/evidence"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/types"
+	evidenceTypes "passport-booking/types/evidence"
+	"passport-booking/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RequiredApprovals is the number of distinct admins, other than the requester,
+// that must approve a request before the evidence can be decrypted
+const RequiredApprovals = 2
+
+// revealWindow is how long an approved request stays usable
+const revealWindow = 24 * time.Hour
+
+// EvidenceController handles dual-authorized access to encrypted delivery evidence
+type EvidenceController struct {
+	DB             *gorm.DB
+	Logger         *logger.AsyncLogger
+	loggerInstance *logger.AsyncLogger
+}
+
+// NewEvidenceController creates a new evidence controller
+func NewEvidenceController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *EvidenceController {
+	return &EvidenceController{
+		DB:             db,
+		Logger:         asyncLogger,
+		loggerInstance: asyncLogger,
+	}
+}
+
+// Helper function to log API requests and responses
+func (ec *EvidenceController) logAPIRequest(c *fiber.Ctx) {
+	logEntry := utils.CreateSanitizedLogEntry(c)
+	ec.loggerInstance.Log(logEntry)
+}
+
+// Helper function to send response and log in one call
+func (ec *EvidenceController) sendResponseWithLog(c *fiber.Ctx, status int, response types.ApiResponse) error {
+	result := c.Status(status).JSON(response)
+	ec.logAPIRequest(c)
+	return result
+}
+
+// currentUser resolves the authenticated admin, responding with an error when it cannot
+func (ec *EvidenceController) currentUser(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, ec.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// Create opens an evidence request for a delivered booking
+func (ec *EvidenceController) Create(c *fiber.Ctx) error {
+	var req evidenceTypes.CreateEvidenceRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return ec.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return ec.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	requester, respErr := ec.currentUser(c)
+	if requester == nil {
+		return respErr
+	}
+
+	var booking bookingModel.Booking
+	if err := booking_resolver.Find(ec.DB, req.BookingID, req.IdentifierType, &booking); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ec.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find booking", err)
+		return ec.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if booking.DeliveryPhoneConfirmedOTPEncrypted == nil || *booking.DeliveryPhoneConfirmedOTPEncrypted == "" {
+		return ec.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "No delivery confirmation evidence stored for this booking",
+			Data:    nil,
+		})
+	}
+
+	request := evidenceModel.EvidenceRequest{
+		BookingID:     booking.ID,
+		Reason:        req.Reason,
+		Status:        evidenceModel.RequestStatusPending,
+		RequestedByID: requester.ID,
+	}
+	if err := ec.DB.Create(&request).Error; err != nil {
+		logger.Error("Failed to create evidence request", err)
+		return ec.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to create evidence request",
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("Evidence request %d opened for booking %d by user %d", request.ID, booking.ID, requester.ID))
+	return ec.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
+		Status:  fiber.StatusCreated,
+		Message: fmt.Sprintf("Evidence request created, %d approvals required", RequiredApprovals),
+		Data:    request,
+	})
+}
+
+// Approve records the current admin's approval of an evidence request
+func (ec *EvidenceController) Approve(c *fiber.Ctx) error {
+	requestID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return ec.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid evidence request ID",
+			Data:    nil,
+		})
+	}
+
+	approver, respErr := ec.currentUser(c)
+	if approver == nil {
+		return respErr
+	}
+
+	var request evidenceModel.EvidenceRequest
+	status, message := fiber.StatusOK, "Approval recorded"
+	err = ec.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, requestID).Error; err != nil {
+			return err
+		}
+
+		switch {
+		case request.Status != evidenceModel.RequestStatusPending:
+			status, message = fiber.StatusConflict, fmt.Sprintf("Evidence request is already %s", request.Status)
+			return nil
+		case request.RequestedByID == approver.ID:
+			status, message = fiber.StatusForbidden, "Requester cannot approve their own evidence request"
+			return nil
+		}
+
+		approval := evidenceModel.EvidenceApproval{RequestID: request.ID, ApproverID: approver.ID}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&approval)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			status, message = fiber.StatusConflict, "You have already approved this evidence request"
+			return nil
+		}
+
+		var approvals int64
+		if err := tx.Model(&evidenceModel.EvidenceApproval{}).Where("request_id = ?", request.ID).Count(&approvals).Error; err != nil {
+			return err
+		}
+		if approvals >= RequiredApprovals {
+			now := time.Now()
+			request.Status = evidenceModel.RequestStatusApproved
+			request.ApprovedAt = &now
+			message = "Evidence request approved"
+			return tx.Save(&request).Error
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ec.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Evidence request not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to approve evidence request", err)
+		return ec.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to approve evidence request",
+			Data:    nil,
+		})
+	}
+
+	if status == fiber.StatusOK {
+		logger.Info(fmt.Sprintf("Evidence request %d approved by user %d", request.ID, approver.ID))
+		ec.DB.Preload("Approvals").First(&request, request.ID)
+	}
+
+	return ec.sendResponseWithLog(c, status, types.ApiResponse{
+		Status:  status,
+		Message: message,
+		Data:    request,
+	})
+}
+
+// Reveal decrypts the delivery confirmation OTP for an approved request. The
+// requester may reveal it once, within the reveal window after approval.
+func (ec *EvidenceController) Reveal(c *fiber.Ctx) error {
+	requestID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return ec.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid evidence request ID",
+			Data:    nil,
+		})
+	}
+
+	requester, respErr := ec.currentUser(c)
+	if requester == nil {
+		return respErr
+	}
+
+	var request evidenceModel.EvidenceRequest
+	if err := ec.DB.Preload("Approvals").First(&request, requestID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ec.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Evidence request not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find evidence request", err)
+		return ec.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if request.RequestedByID != requester.ID {
+		return ec.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+			Status:  fiber.StatusForbidden,
+			Message: "Only the requester can reveal this evidence",
+			Data:    nil,
+		})
+	}
+
+	if request.Status != evidenceModel.RequestStatusApproved || request.ApprovedAt == nil {
+		return ec.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+			Status:  fiber.StatusForbidden,
+			Message: fmt.Sprintf("Evidence request is %s; %d approvals are required", request.Status, RequiredApprovals),
+			Data:    nil,
+		})
+	}
+
+	if time.Since(*request.ApprovedAt) > revealWindow {
+		return ec.sendResponseWithLog(c, fiber.StatusGone, types.ApiResponse{
+			Status:  fiber.StatusGone,
+			Message: "Evidence request approval has expired, open a new request",
+			Data:    nil,
+		})
+	}
+
+	var booking bookingModel.Booking
+	if err := ec.DB.First(&booking, request.BookingID).Error; err != nil {
+		logger.Error("Failed to find booking for evidence request", err)
+		return ec.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if booking.DeliveryPhoneConfirmedOTPEncrypted == nil {
+		return ec.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "No delivery confirmation evidence stored for this booking",
+			Data:    nil,
+		})
+	}
+
+	confirmedOTP, err := utils.DecryptData(*booking.DeliveryPhoneConfirmedOTPEncrypted)
+	if err != nil {
+		logger.Error("Failed to decrypt delivery confirmation evidence", err)
+		return ec.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to decrypt evidence",
+			Data:    nil,
+		})
+	}
+
+	// Mark the request as used; a second reveal needs a fresh approval round
+	now := time.Now()
+	result := ec.DB.Model(&evidenceModel.EvidenceRequest{}).
+		Where("id = ? AND status = ?", request.ID, evidenceModel.RequestStatusApproved).
+		Updates(map[string]interface{}{"status": evidenceModel.RequestStatusRevealed, "revealed_at": now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		logger.Error("Failed to mark evidence request as revealed", result.Error)
+		return ec.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Evidence request was already revealed",
+			Data:    nil,
+		})
+	}
+
+	if err := booking_event.SnapshotBookingToEvent(ec.DB.WithContext(c.UserContext()), &booking, "otp_evidence_revealed", strconv.FormatUint(uint64(requester.ID), 10)); err != nil {
+		logger.Error("Failed to write booking event (otp_evidence_revealed)", err)
+	}
+
+	logger.Warning(fmt.Sprintf("Delivery confirmation evidence for booking %d revealed to user %d (request %d)", booking.ID, requester.ID, request.ID))
+
+	// Responses from this endpoint are not written to the API log, so the OTP never lands in it
+	return c.Status(fiber.StatusOK).JSON(types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Evidence revealed",
+		Data: map[string]interface{}{
+			"request_id":             request.ID,
+			"booking_id":             booking.ID,
+			"delivery_phone":         booking.DeliveryPhone,
+			"delivery_confirmed_otp": confirmedOTP,
+			"revealed_at":            now,
+		},
+	})
+}
@@ -0,0 +1,191 @@
+package evidence
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/constants"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	evidenceModel "passport-booking/models/evidence"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/services/evidence_export"
+	"passport-booking/types"
+	evidenceTypes "passport-booking/types/evidence"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// CreateExport queues an encrypted evidence archive of a booking or of the bookings
+// delivered in a date range. The archive password is in this response only.
+func (ec *EvidenceController) CreateExport(c *fiber.Ctx) error {
+	var req evidenceTypes.CreateExportRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return ec.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return ec.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	requester, respErr := ec.currentUser(c)
+	if requester == nil {
+		return respErr
+	}
+
+	job := evidenceModel.ExportJob{
+		Reason:        req.Reason,
+		Status:        evidenceModel.ExportStatusQueued,
+		RequestedByID: requester.ID,
+	}
+	if req.BookingID != "" {
+		var booking bookingModel.Booking
+		if err := booking_resolver.Find(ec.DB, req.BookingID, req.IdentifierType, &booking); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ec.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+					Status:  fiber.StatusNotFound,
+					Message: "Booking not found",
+					Data:    nil,
+				})
+			}
+			logger.Error("Failed to find booking", err)
+			return ec.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Internal server error",
+				Data:    nil,
+			})
+		}
+		job.BookingID = &booking.ID
+	} else {
+		job.From, job.To = &req.From, &req.To
+	}
+
+	password, err := evidence_export.GeneratePassword()
+	if err != nil {
+		logger.Error("Failed to generate evidence export password", err)
+		return ec.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to create evidence export",
+			Data:    nil,
+		})
+	}
+
+	if err := ec.DB.Create(&job).Error; err != nil {
+		logger.Error("Failed to create evidence export job", err)
+		return ec.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to create evidence export",
+			Data:    nil,
+		})
+	}
+
+	if err := evidence_export.Enqueue(ec.DB, &job, password); err != nil {
+		logger.Error("Failed to queue evidence export job", err)
+		ec.DB.Model(&job).Updates(map[string]interface{}{"status": evidenceModel.ExportStatusFailed, "error": err.Error()})
+		return ec.sendResponseWithLog(c, fiber.StatusServiceUnavailable, types.ApiResponse{
+			Status:  fiber.StatusServiceUnavailable,
+			Message: "Export queue is busy, try again later",
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("Evidence export job %d queued by user %d", job.ID, requester.ID))
+
+	// Not written to the API log, so the archive password is never stored
+	return c.Status(fiber.StatusAccepted).JSON(types.ApiResponse{
+		Status:  fiber.StatusAccepted,
+		Message: "Evidence export queued. Keep the password, it is not shown again",
+		Data: map[string]interface{}{
+			"job":      job,
+			"password": password,
+			"decrypt":  fmt.Sprintf("openssl enc -d -aes-256-cbc -pbkdf2 -iter %d -md sha256 -in %s -out evidence.zip", evidence_export.PBKDF2Iterations, evidence_export.FileName(&job)),
+		},
+	})
+}
+
+// findExport loads the export job in :id for its requester or a super admin,
+// responding with an error when it cannot
+func (ec *EvidenceController) findExport(c *fiber.Ctx) (*evidenceModel.ExportJob, error) {
+	jobID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, ec.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid export job ID",
+			Data:    nil,
+		})
+	}
+
+	requester, respErr := ec.currentUser(c)
+	if requester == nil {
+		return nil, respErr
+	}
+
+	var job evidenceModel.ExportJob
+	if err := ec.DB.First(&job, jobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ec.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Export job not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find evidence export job", err)
+		return nil, ec.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	if job.RequestedByID != requester.ID && !middleware.GetUserPermissions(c)[constants.PermSuperAdminFull] {
+		return nil, ec.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+			Status:  fiber.StatusForbidden,
+			Message: "Only the requester can access this export",
+			Data:    nil,
+		})
+	}
+	return &job, nil
+}
+
+// GetExport returns the status of an evidence export job
+func (ec *EvidenceController) GetExport(c *fiber.Ctx) error {
+	job, respErr := ec.findExport(c)
+	if job == nil {
+		return respErr
+	}
+	return ec.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: fmt.Sprintf("Evidence export is %s", job.Status),
+		Data:    job,
+	})
+}
+
+// DownloadExport serves the encrypted archive of a completed export job
+func (ec *EvidenceController) DownloadExport(c *fiber.Ctx) error {
+	job, respErr := ec.findExport(c)
+	if job == nil {
+		return respErr
+	}
+	if job.Status != evidenceModel.ExportStatusCompleted || job.FilePath == "" {
+		return ec.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: fmt.Sprintf("Evidence export is %s", job.Status),
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("Evidence export job %d downloaded", job.ID))
+	ec.logAPIRequest(c)
+	return c.Download(job.FilePath, evidence_export.FileName(job))
+}
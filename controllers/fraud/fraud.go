@@ -0,0 +1,326 @@
+package fraud
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	fraudModel "passport-booking/models/fraud"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/booking_event"
+	devicePush "passport-booking/services/device_push"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	fraudTypes "passport-booking/types/fraud"
+	"passport-booking/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FraudController manages anti-fraud rule configuration and supervisor review of held deliveries
+type FraudController struct {
+	DB             *gorm.DB
+	Logger         *logger.AsyncLogger
+	loggerInstance *logger.AsyncLogger
+}
+
+// NewFraudController creates a new fraud controller
+func NewFraudController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *FraudController {
+	return &FraudController{
+		DB:             db,
+		Logger:         asyncLogger,
+		loggerInstance: asyncLogger,
+	}
+}
+
+// Helper function to log API requests and responses
+func (fc *FraudController) logAPIRequest(c *fiber.Ctx) {
+	logEntry := utils.CreateSanitizedLogEntry(c)
+	fc.loggerInstance.Log(logEntry)
+}
+
+// Helper function to send response and log in one call
+func (fc *FraudController) sendResponseWithLog(c *fiber.Ctx, status int, response types.ApiResponse) error {
+	result := c.Status(status).JSON(response)
+	fc.logAPIRequest(c)
+	return result
+}
+
+// currentUser resolves the authenticated supervisor, responding with an error when it cannot
+func (fc *FraudController) currentUser(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, fc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// ListRules returns the configured fraud rules
+func (fc *FraudController) ListRules(c *fiber.Ctx) error {
+	var rules []fraudModel.FraudRule
+	if err := fc.DB.Order("code").Find(&rules).Error; err != nil {
+		logger.Error("Failed to fetch fraud rules", err)
+		return fc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch fraud rules",
+			Data:    nil,
+		})
+	}
+
+	return fc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fraud rules fetched successfully",
+		Data:    rules,
+	})
+}
+
+// UpdateRule enables/disables a rule or changes its action and thresholds
+func (fc *FraudController) UpdateRule(c *fiber.Ctx) error {
+	var req fraudTypes.UpdateRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return fc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return fc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	admin, respErr := fc.currentUser(c)
+	if admin == nil {
+		return respErr
+	}
+
+	var rule fraudModel.FraudRule
+	if err := fc.DB.Where("code = ?", c.Params("code")).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Fraud rule not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to fetch fraud rule", err)
+		return fc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Database error",
+			Data:    nil,
+		})
+	}
+
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.Action != "" {
+		rule.Action = req.Action
+	}
+	if req.Params != nil {
+		params, err := req.EncodedParams()
+		if err != nil {
+			return fc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid params",
+				Data:    nil,
+			})
+		}
+		rule.Params = params
+	}
+	rule.UpdatedBy = strconv.FormatUint(uint64(admin.ID), 10)
+
+	if err := fc.DB.Save(&rule).Error; err != nil {
+		logger.Error("Failed to update fraud rule", err)
+		return fc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update fraud rule",
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("Fraud rule %s updated by %s", rule.Code, admin.Username))
+
+	return fc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fraud rule updated successfully",
+		Data:    rule,
+	})
+}
+
+// ListCases returns fraud cases, newest first
+func (fc *FraudController) ListCases(c *fiber.Ctx) error {
+	var req fraudTypes.CaseListRequest
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Failed to parse query parameters", err)
+		return fc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return fc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query := fc.DB.Model(&fraudModel.FraudCase{})
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.PostmanID != 0 {
+		query = query.Where("postman_id = ?", req.PostmanID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.Error("Failed to count fraud cases", err)
+		return fc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch fraud cases",
+			Data:    nil,
+		})
+	}
+
+	var cases []fraudModel.FraudCase
+	if err := query.Order("created_at DESC").Offset((req.Page - 1) * req.PerPage).Limit(req.PerPage).Find(&cases).Error; err != nil {
+		logger.Error("Failed to fetch fraud cases", err)
+		return fc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch fraud cases",
+			Data:    nil,
+		})
+	}
+
+	totalPages := int((total + int64(req.PerPage) - 1) / int64(req.PerPage))
+	return fc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fraud cases fetched successfully",
+		Data: bookingTypes.BookingIndexResponse{
+			Data: cases,
+			Pagination: bookingTypes.PaginationResponse{
+				CurrentPage: req.Page,
+				PerPage:     req.PerPage,
+				Total:       total,
+				TotalPages:  totalPages,
+				HasNext:     req.Page < totalPages,
+				HasPrev:     req.Page > 1,
+			},
+		},
+	})
+}
+
+// ReviewCase releases a held delivery or confirms the suspicion
+func (fc *FraudController) ReviewCase(c *fiber.Ctx) error {
+	caseID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return fc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid fraud case ID",
+			Data:    nil,
+		})
+	}
+
+	var req fraudTypes.ReviewCaseRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return fc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return fc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	reviewer, respErr := fc.currentUser(c)
+	if reviewer == nil {
+		return respErr
+	}
+
+	var fraudCase fraudModel.FraudCase
+	var booking bookingModel.Booking
+	err = fc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&fraudCase, caseID).Error; err != nil {
+			return err
+		}
+		if fraudCase.Status != fraudModel.CaseStatusOpen {
+			return errCaseReviewed
+		}
+		// The postman cannot clear their own case even if they also hold a supervisor role
+		if fraudCase.PostmanID == reviewer.ID {
+			return errSelfReview
+		}
+
+		now := time.Now()
+		fraudCase.Status = req.Decision
+		fraudCase.ReviewedByID = &reviewer.ID
+		fraudCase.ReviewedAt = &now
+		fraudCase.ReviewNote = &req.Note
+		if err := tx.Save(&fraudCase).Error; err != nil {
+			return err
+		}
+
+		booking.ID = fraudCase.BookingID
+		return booking_event.SnapshotBookingToEvent(tx, &booking, "fraud_case_"+string(req.Decision), strconv.FormatUint(uint64(reviewer.ID), 10))
+	})
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		msg := "Failed to review fraud case"
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status, msg = fiber.StatusNotFound, "Fraud case not found"
+		case errors.Is(err, errCaseReviewed):
+			status, msg = fiber.StatusConflict, "Fraud case has already been reviewed"
+		case errors.Is(err, errSelfReview):
+			status, msg = fiber.StatusForbidden, "You cannot review a case raised on your own delivery"
+		default:
+			logger.Error("Failed to review fraud case", err)
+		}
+		return fc.sendResponseWithLog(c, status, types.ApiResponse{
+			Status:  status,
+			Message: msg,
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("Fraud case %d %s by %s", fraudCase.ID, fraudCase.Status, reviewer.Username))
+	if fraudCase.Action == fraudModel.RuleActionBlock {
+		devicePush.Dispatch(devicePush.EscalationReviewed(fraudCase.PostmanID, fraudCase.ID, fraudCase.Status == fraudModel.CaseStatusReleased))
+	}
+
+	return fc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fraud case reviewed successfully",
+		Data:    fraudCase,
+	})
+}
+
+var (
+	errCaseReviewed = errors.New("fraud case already reviewed")
+	errSelfReview   = errors.New("reviewer is the postman of the case")
+)
@@ -0,0 +1,355 @@
+package integration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/dms_callback"
+	"passport-booking/services/dms_outbox"
+	"passport-booking/types"
+	integrationTypes "passport-booking/types/integration"
+	"passport-booking/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// IntegrationController receives callbacks from external systems and manages how they
+// map onto local data
+type IntegrationController struct {
+	DB             *gorm.DB
+	Logger         *logger.AsyncLogger
+	loggerInstance *logger.AsyncLogger
+}
+
+// NewIntegrationController creates a new integration controller
+func NewIntegrationController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *IntegrationController {
+	return &IntegrationController{
+		DB:             db,
+		Logger:         asyncLogger,
+		loggerInstance: asyncLogger,
+	}
+}
+
+// Helper function to log API requests and responses
+func (ic *IntegrationController) logAPIRequest(c *fiber.Ctx) {
+	logEntry := utils.CreateSanitizedLogEntry(c)
+	ic.loggerInstance.Log(logEntry)
+}
+
+// Helper function to send response and log in one call
+func (ic *IntegrationController) sendResponseWithLog(c *fiber.Ctx, status int, response types.ApiResponse) error {
+	result := c.Status(status).JSON(response)
+	ic.logAPIRequest(c)
+	return result
+}
+
+// currentUser resolves the authenticated admin, responding with an error when it cannot
+func (ic *IntegrationController) currentUser(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, ic.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// DMSCallback receives an article status update pushed by DMS. The request is signed
+// with DMS_CALLBACK_SECRET; repeated deliveries of the same event are acknowledged
+// without being applied again.
+func (ic *IntegrationController) DMSCallback(c *fiber.Ctx) error {
+	body := c.Body()
+	if err := dms_callback.Verify(body, c.Get("X-DMS-Timestamp"), c.Get("X-DMS-Signature"), time.Now()); err != nil {
+		if errors.Is(err, dms_callback.ErrNotConfigured) {
+			logger.Error("DMS callback received but DMS_CALLBACK_SECRET is not set", nil)
+			return ic.sendResponseWithLog(c, fiber.StatusServiceUnavailable, types.ApiResponse{
+				Status:  fiber.StatusServiceUnavailable,
+				Message: "Callback endpoint is not configured",
+				Data:    nil,
+			})
+		}
+		logger.Warning(fmt.Sprintf("Rejected DMS callback from %s: %v", c.IP(), err))
+		return ic.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "Invalid signature",
+			Data:    nil,
+		})
+	}
+
+	var payload integrationTypes.DMSCallbackPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ic.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := payload.Validate(); err != nil {
+		return ic.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	callback, duplicate, err := dms_callback.Process(ic.DB, payload, body)
+	if err != nil {
+		// DMS retries on 5xx, which is what we want for transient failures
+		logger.Error(fmt.Sprintf("Failed to process DMS callback for %s", payload.ArticleID), err)
+		return ic.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to process callback",
+			Data:    nil,
+		})
+	}
+
+	message := "Callback processed"
+	if duplicate {
+		message = "Callback already processed"
+	} else if callback.Outcome != bookingModel.DMSCallbackApplied && callback.Outcome != bookingModel.DMSCallbackUnchanged {
+		logger.Warning(fmt.Sprintf("DMS callback %s for %s (%s) not applied: %s", callback.EventID, callback.Barcode, callback.DMSStatus, callback.Outcome))
+	}
+
+	return ic.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Data: map[string]interface{}{
+			"event_id": callback.EventID,
+			"outcome":  callback.Outcome,
+		},
+	})
+}
+
+// ListMappings returns the DMS status mappings
+func (ic *IntegrationController) ListMappings(c *fiber.Ctx) error {
+	var mappings []bookingModel.DMSStatusMapping
+	if err := ic.DB.Order("dms_status").Find(&mappings).Error; err != nil {
+		logger.Error("Failed to fetch DMS status mappings", err)
+		return ic.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch DMS status mappings",
+			Data:    nil,
+		})
+	}
+
+	return ic.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "DMS status mappings fetched successfully",
+		Data:    mappings,
+	})
+}
+
+// SaveMapping creates or changes the booking status a DMS status maps to
+func (ic *IntegrationController) SaveMapping(c *fiber.Ctx) error {
+	var req integrationTypes.SaveMappingRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return ic.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return ic.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	admin, respErr := ic.currentUser(c)
+	if admin == nil {
+		return respErr
+	}
+
+	mapping, err := dms_callback.SaveMapping(ic.DB, req, admin.ID)
+	if err != nil {
+		logger.Error("Failed to save DMS status mapping", err)
+		return ic.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to save DMS status mapping",
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("DMS status %s mapped to %s (active: %t) by %s", mapping.DMSStatus, mapping.BookingStatus, mapping.Active, admin.Username))
+
+	return ic.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "DMS status mapping saved successfully",
+		Data:    mapping,
+	})
+}
+
+// ListCallbacks returns the most recent callbacks, optionally for one barcode
+func (ic *IntegrationController) ListCallbacks(c *fiber.Ctx) error {
+	query := ic.DB.Model(&bookingModel.DMSCallback{})
+	if barcode := c.Query("barcode"); barcode != "" {
+		query = query.Where("barcode = ?", barcode)
+	}
+	if outcome := c.Query("outcome"); outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	var callbacks []bookingModel.DMSCallback
+	if err := query.Order("created_at DESC").Limit(limit).Find(&callbacks).Error; err != nil {
+		logger.Error("Failed to fetch DMS callbacks", err)
+		return ic.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch DMS callbacks",
+			Data:    nil,
+		})
+	}
+
+	return ic.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "DMS callbacks fetched successfully",
+		Data:    callbacks,
+	})
+}
+
+// DMSStatus returns the circuit breaker state, the mode switch and the outbox backlog
+func (ic *IntegrationController) DMSStatus(c *fiber.Ctx) error {
+	counts, err := dms_outbox.Counts(ic.DB)
+	if err != nil {
+		logger.Error("Failed to count DMS outbox entries", err)
+		return ic.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch DMS status",
+			Data:    nil,
+		})
+	}
+
+	return ic.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "DMS status fetched successfully",
+		Data: fiber.Map{
+			"breaker": dms.Status(),
+			"outbox":  counts,
+		},
+	})
+}
+
+// SetDMSMode switches intake between auto (degrade while the circuit is open), forced
+// degraded and forced normal operation on this instance
+func (ic *IntegrationController) SetDMSMode(c *fiber.Ctx) error {
+	var req integrationTypes.SetDMSModeRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return ic.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return ic.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	admin, respErr := ic.currentUser(c)
+	if admin == nil {
+		return respErr
+	}
+
+	dms.SetMode(dms.Mode(req.Mode))
+	logger.Info(fmt.Sprintf("DMS mode switched to %s by %s", req.Mode, admin.Username))
+
+	return ic.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "DMS mode updated successfully",
+		Data:    dms.Status(),
+	})
+}
+
+// ListOutbox returns queued DMS operations, optionally filtered by status or booking
+func (ic *IntegrationController) ListOutbox(c *fiber.Ctx) error {
+	query := ic.DB.Model(&bookingModel.DMSOutboxEntry{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if bookingID := c.QueryInt("booking_id", 0); bookingID > 0 {
+		query = query.Where("booking_id = ?", bookingID)
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	var entries []bookingModel.DMSOutboxEntry
+	if err := query.Order("id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		logger.Error("Failed to fetch DMS outbox entries", err)
+		return ic.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch DMS outbox entries",
+			Data:    nil,
+		})
+	}
+
+	return ic.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "DMS outbox entries fetched successfully",
+		Data:    entries,
+	})
+}
+
+// RetryOutboxEntry puts a failed outbox entry back in the queue
+func (ic *IntegrationController) RetryOutboxEntry(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return ic.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid outbox entry ID",
+			Data:    nil,
+		})
+	}
+
+	entry, err := dms_outbox.Retry(ic.DB, uint(id))
+	switch {
+	case errors.Is(err, dms_outbox.ErrNotFound):
+		return ic.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	case errors.Is(err, dms_outbox.ErrNotFailed):
+		return ic.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+			Data:    entry,
+		})
+	case err != nil:
+		logger.Error(fmt.Sprintf("Failed to retry DMS outbox entry %d", id), err)
+		return ic.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to retry outbox entry",
+			Data:    nil,
+		})
+	}
+
+	return ic.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Outbox entry queued for retry",
+		Data:    entry,
+	})
+}
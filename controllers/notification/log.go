@@ -0,0 +1,192 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	notificationModel "passport-booking/models/notification"
+	notificationService "passport-booking/services/notification"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	notificationTypes "passport-booking/types/notification"
+	"passport-booking/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListLog returns delivery log entries, newest first
+func (nc *NotificationController) ListLog(c *fiber.Ctx) error {
+	var req notificationTypes.LogListRequest
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Failed to parse query parameters", err)
+		return nc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return nc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query := nc.DB.Model(&notificationModel.NotificationLog{})
+	if req.BookingID != 0 {
+		query = query.Where("booking_id = ?", req.BookingID)
+	}
+	if req.UserID != 0 {
+		query = query.Where("user_id = ?", req.UserID)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.Channel != "" {
+		query = query.Where("channel = ?", req.Channel)
+	}
+	if req.Kind != "" {
+		query = query.Where("kind = ?", req.Kind)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.Error("Failed to count notification log", err)
+		return nc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch notification log",
+			Data:    nil,
+		})
+	}
+
+	var entries []notificationModel.NotificationLog
+	if err := query.Order("created_at DESC").Offset((req.Page - 1) * req.PerPage).Limit(req.PerPage).Find(&entries).Error; err != nil {
+		logger.Error("Failed to fetch notification log", err)
+		return nc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch notification log",
+			Data:    nil,
+		})
+	}
+
+	// Call-log browsing by supervisors shows recipients and message bodies masked
+	if middleware.MaskPII(c) {
+		for i := range entries {
+			entries[i].Recipient = utils.MaskRecipient(entries[i].Recipient)
+			entries[i].Body = utils.MaskPhonesIn(entries[i].Body)
+		}
+	}
+
+	totalPages := int((total + int64(req.PerPage) - 1) / int64(req.PerPage))
+	return nc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Notification log fetched successfully",
+		Data: bookingTypes.BookingIndexResponse{
+			Data: entries,
+			Pagination: bookingTypes.PaginationResponse{
+				CurrentPage: req.Page,
+				PerPage:     req.PerPage,
+				Total:       total,
+				TotalPages:  totalPages,
+				HasNext:     req.Page < totalPages,
+				HasPrev:     req.Page > 1,
+			},
+		},
+	})
+}
+
+// ResendLog sends a failed notification again on the same channel
+func (nc *NotificationController) ResendLog(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid notification ID",
+			Data:    nil,
+		})
+	}
+
+	admin, respErr := nc.currentUser(c)
+	if admin == nil {
+		return respErr
+	}
+
+	attempt, err := notificationService.ResendLog(nc.DB, uint(id), admin.ID)
+	if attempt == nil {
+		status := fiber.StatusInternalServerError
+		msg := "Failed to resend notification"
+		switch {
+		case errors.Is(err, notificationService.ErrLogNotFound):
+			status, msg = fiber.StatusNotFound, "Notification not found"
+		case errors.Is(err, notificationService.ErrNotFailed):
+			status, msg = fiber.StatusConflict, "Only failed notifications can be resent"
+		case errors.Is(err, notificationService.ErrAlreadyResent):
+			status, msg = fiber.StatusConflict, "Notification was already resent; resend the latest attempt instead"
+		default:
+			logger.Error("Failed to resend notification", err)
+		}
+		return nc.sendResponseWithLog(c, status, types.ApiResponse{
+			Status:  status,
+			Message: msg,
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("Notification %d resent by %s: %s", id, admin.Username, attempt.Status))
+
+	// The attempt is recorded either way; a provider failure is reported in its status
+	message := "Notification resent successfully"
+	if attempt.Status == notificationModel.DeliveryStatusFailed {
+		message = "Notification resend failed"
+	}
+	return nc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Data:    attempt,
+	})
+}
+
+// ResendFailedForBooking resends every notification of a booking whose latest attempt failed
+func (nc *NotificationController) ResendFailedForBooking(c *fiber.Ctx) error {
+	bookingID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid booking ID",
+			Data:    nil,
+		})
+	}
+
+	admin, respErr := nc.currentUser(c)
+	if admin == nil {
+		return respErr
+	}
+
+	attempts, err := notificationService.ResendFailedForBooking(nc.DB, uint(bookingID), admin.ID)
+	if err != nil {
+		logger.Error("Failed to resend booking notifications", err)
+		return nc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to resend notifications",
+			Data:    nil,
+		})
+	}
+
+	sent := 0
+	for _, attempt := range attempts {
+		if attempt.Status == notificationModel.DeliveryStatusSent {
+			sent++
+		}
+	}
+	logger.Info(fmt.Sprintf("%d of %d failed notifications of booking %d resent by %s", sent, len(attempts), bookingID, admin.Username))
+
+	return nc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: fmt.Sprintf("%d of %d failed notifications resent", sent, len(attempts)),
+		Data:    attempts,
+	})
+}
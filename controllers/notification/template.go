@@ -0,0 +1,313 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	userModel "passport-booking/models/user"
+	notificationService "passport-booking/services/notification"
+	"passport-booking/types"
+	notificationTypes "passport-booking/types/notification"
+	preferenceTypes "passport-booking/types/preference"
+	"passport-booking/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// NotificationController manages the wording of applicant SMS and email notifications
+type NotificationController struct {
+	DB             *gorm.DB
+	Logger         *logger.AsyncLogger
+	loggerInstance *logger.AsyncLogger
+}
+
+// NewNotificationController creates a new notification controller
+func NewNotificationController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *NotificationController {
+	return &NotificationController{
+		DB:             db,
+		Logger:         asyncLogger,
+		loggerInstance: asyncLogger,
+	}
+}
+
+// Helper function to log API requests and responses
+func (nc *NotificationController) logAPIRequest(c *fiber.Ctx) {
+	logEntry := utils.CreateSanitizedLogEntry(c)
+	nc.loggerInstance.Log(logEntry)
+}
+
+// Helper function to send response and log in one call
+func (nc *NotificationController) sendResponseWithLog(c *fiber.Ctx, status int, response types.ApiResponse) error {
+	result := c.Status(status).JSON(response)
+	nc.logAPIRequest(c)
+	return result
+}
+
+// currentUser resolves the authenticated admin, responding with an error when it cannot
+func (nc *NotificationController) currentUser(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, nc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// templateParams reads and checks the :kind and :lang route parameters
+func (nc *NotificationController) templateParams(c *fiber.Ctx) (notificationService.Kind, string, bool) {
+	kind := notificationService.Kind(c.Params("kind"))
+	lang := c.Params("lang")
+	if !notificationService.Known(kind) {
+		return "", "", false
+	}
+	if lang != preferenceTypes.LanguageEnglish && lang != preferenceTypes.LanguageBangla {
+		return "", "", false
+	}
+	return kind, lang, true
+}
+
+// ListTemplates returns the wording in use for every kind and language together with
+// the variables templates can refer to
+func (nc *NotificationController) ListTemplates(c *fiber.Ctx) error {
+	var summaries []notificationTypes.TemplateSummary
+	for _, kind := range notificationService.Kinds() {
+		for _, lang := range []string{preferenceTypes.LanguageEnglish, preferenceTypes.LanguageBangla} {
+			text, active, err := notificationService.Active(nc.DB, kind, lang)
+			if err != nil {
+				logger.Error("Failed to fetch message templates", err)
+				return nc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+					Status:  fiber.StatusInternalServerError,
+					Message: "Failed to fetch message templates",
+					Data:    nil,
+				})
+			}
+			summary := notificationTypes.TemplateSummary{
+				Kind:     string(kind),
+				Language: lang,
+				Subject:  text.Subject,
+				Email:    text.Email,
+				SMS:      text.SMS,
+				Builtin:  active == nil,
+			}
+			if active != nil {
+				summary.ActiveVersion = active.Version
+			}
+			summaries = append(summaries, summary)
+		}
+	}
+
+	return nc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Message templates fetched successfully",
+		Data: map[string]interface{}{
+			"variables": notificationService.Variables(),
+			"templates": summaries,
+		},
+	})
+}
+
+// ListVersions returns the stored versions of one kind and language, newest first
+func (nc *NotificationController) ListVersions(c *fiber.Ctx) error {
+	kind, lang, ok := nc.templateParams(c)
+	if !ok {
+		return nc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Unknown notification kind or language",
+			Data:    nil,
+		})
+	}
+
+	versions, err := notificationService.Versions(nc.DB, kind, lang)
+	if err != nil {
+		logger.Error("Failed to fetch message template versions", err)
+		return nc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch message template versions",
+			Data:    nil,
+		})
+	}
+
+	return nc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Message template versions fetched successfully",
+		Data:    versions,
+	})
+}
+
+// SaveTemplate stores new wording as the next version of a kind and language
+func (nc *NotificationController) SaveTemplate(c *fiber.Ctx) error {
+	var req notificationTypes.SaveTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return nc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return nc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	admin, respErr := nc.currentUser(c)
+	if admin == nil {
+		return respErr
+	}
+
+	tmpl, err := notificationService.SaveVersion(nc.DB, notificationService.Kind(req.Kind), req.Language,
+		req.Subject, req.Email, req.SMS, req.Note, admin.ID, req.Activate)
+	if err != nil {
+		var invalid *notificationService.InvalidTemplateError
+		switch {
+		case errors.Is(err, notificationService.ErrUnknownKind):
+			return nc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Unknown notification kind",
+				Data:    nil,
+			})
+		case errors.As(err, &invalid):
+			return nc.sendResponseWithLog(c, fiber.StatusUnprocessableEntity, types.ApiResponse{
+				Status:  fiber.StatusUnprocessableEntity,
+				Message: invalid.Error(),
+				Data:    map[string]interface{}{"part": invalid.Part, "variables": notificationService.Variables()},
+			})
+		}
+		logger.Error("Failed to save message template", err)
+		return nc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to save message template",
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("Message template %s/%s v%d saved by %s (active: %t)", tmpl.Kind, tmpl.Language, tmpl.Version, admin.Username, tmpl.Active))
+
+	return nc.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Message template saved successfully",
+		Data:    tmpl,
+	})
+}
+
+// PreviewTemplate renders wording with sample data without saving it
+func (nc *NotificationController) PreviewTemplate(c *fiber.Ctx) error {
+	var req notificationTypes.PreviewTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return nc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return nc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	rendered, err := notificationService.Preview(notificationService.Kind(req.Kind), req.Subject, req.Email, req.SMS)
+	if err != nil {
+		return nc.sendResponseWithLog(c, fiber.StatusUnprocessableEntity, types.ApiResponse{
+			Status:  fiber.StatusUnprocessableEntity,
+			Message: err.Error(),
+			Data:    map[string]interface{}{"variables": notificationService.Variables()},
+		})
+	}
+
+	return nc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Message template rendered successfully",
+		Data:    rendered,
+	})
+}
+
+// ActivateVersion puts a stored version back in use, e.g. to roll back a bad edit
+func (nc *NotificationController) ActivateVersion(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid template ID",
+			Data:    nil,
+		})
+	}
+
+	admin, respErr := nc.currentUser(c)
+	if admin == nil {
+		return respErr
+	}
+
+	tmpl, err := notificationService.Activate(nc.DB, uint(id))
+	if err != nil {
+		if errors.Is(err, notificationService.ErrTemplateNotFound) {
+			return nc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Message template not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to activate message template", err)
+		return nc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to activate message template",
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("Message template %s/%s v%d activated by %s", tmpl.Kind, tmpl.Language, tmpl.Version, admin.Username))
+
+	return nc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Message template activated successfully",
+		Data:    tmpl,
+	})
+}
+
+// ResetTemplate switches a kind and language back to the built-in wording
+func (nc *NotificationController) ResetTemplate(c *fiber.Ctx) error {
+	kind, lang, ok := nc.templateParams(c)
+	if !ok {
+		return nc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Unknown notification kind or language",
+			Data:    nil,
+		})
+	}
+
+	admin, respErr := nc.currentUser(c)
+	if admin == nil {
+		return respErr
+	}
+
+	if err := notificationService.Reset(nc.DB, kind, lang); err != nil {
+		logger.Error("Failed to reset message template", err)
+		return nc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to reset message template",
+			Data:    nil,
+		})
+	}
+
+	logger.Info(fmt.Sprintf("Message template %s/%s reset to built-in by %s", kind, lang, admin.Username))
+
+	return nc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Message template reset to the built-in wording",
+		Data:    nil,
+	})
+}
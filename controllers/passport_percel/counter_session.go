@@ -0,0 +1,269 @@
+package passport_percel
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/models/parcel_booking"
+	"passport-booking/services/branch_schedule"
+	"passport-booking/services/counter_session"
+	"passport-booking/types"
+	parcel_booking_types "passport-booking/types/parcel_booking"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// sessionReport loads the bookings of a session; open sessions get running totals
+func (pbc *ParcelBookingController) sessionReport(session parcel_booking.CounterSession) (*parcel_booking_types.CounterSessionReport, error) {
+	report := parcel_booking_types.CounterSessionReport{Session: session}
+	if err := pbc.DB.Model(&parcel_booking.ParcelBooking{}).
+		Select("id, barcode, current_status, total_charge, booking_date").
+		Where("counter_session_id = ?", session.ID).
+		Order("id").
+		Scan(&report.Bookings).Error; err != nil {
+		return nil, err
+	}
+
+	if session.Status == parcel_booking.CounterSessionOpen {
+		totals, err := counter_session.TotalsOf(pbc.DB, session.ID)
+		if err != nil {
+			return nil, err
+		}
+		report.Session.BookingCount = totals.BookingCount
+		report.Session.CollectedFees = totals.CollectedFees
+		report.Session.ExpectedCash = session.OpeningFloat + totals.CollectedFees
+	}
+	return &report, nil
+}
+
+// OpenCounterSession starts the operator's shift at a counter with its cash float
+func (pbc *ParcelBookingController) OpenCounterSession(c *fiber.Ctx) error {
+	var req parcel_booking_types.OpenCounterSessionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := pbc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	session, err := counter_session.Open(pbc.DB.WithContext(c.UserContext()), userInfo.ID, req.BranchCode, req.OpeningFloat)
+	if err != nil {
+		if errors.Is(err, counter_session.ErrAlreadyOpen) {
+			return pbc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+				Status:  fiber.StatusConflict,
+				Message: "You already have an open counter session; close it first",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to open counter session", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to open counter session",
+			Data:    nil,
+		})
+	}
+
+	return pbc.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Counter session opened successfully",
+		Data:    session,
+	})
+}
+
+// CurrentCounterSession returns the operator's open session with its running totals
+func (pbc *ParcelBookingController) CurrentCounterSession(c *fiber.Ctx) error {
+	userInfo, err := pbc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	session, err := counter_session.Current(pbc.DB, userInfo.ID)
+	if errors.Is(err, counter_session.ErrNoOpenSession) {
+		return pbc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "No open counter session",
+			Data:    nil,
+		})
+	}
+	var report *parcel_booking_types.CounterSessionReport
+	if err == nil {
+		report, err = pbc.sessionReport(*session)
+	}
+	if err != nil {
+		logger.Error("Failed to load counter session", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Counter session retrieved successfully",
+		Data:    report,
+	})
+}
+
+// CloseCounterSession ends the operator's shift, reconciling the counted cash against
+// the opening float and the fees of the bookings submitted during it
+func (pbc *ParcelBookingController) CloseCounterSession(c *fiber.Ctx) error {
+	var req parcel_booking_types.CloseCounterSessionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := pbc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	session, err := counter_session.Close(pbc.DB.WithContext(c.UserContext()), userInfo.ID, *req.DeclaredCash, req.Note)
+	if err != nil {
+		if errors.Is(err, counter_session.ErrNoOpenSession) {
+			return pbc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "No open counter session",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to close counter session", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to close counter session",
+			Data:    nil,
+		})
+	}
+
+	if session.Variance != nil && *session.Variance != 0 {
+		logger.Warning(fmt.Sprintf("Counter session %d closed by operator %d with cash variance %.2f",
+			session.ID, session.OperatorID, *session.Variance))
+	}
+
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Counter session closed successfully",
+		Data:    session,
+	})
+}
+
+// ListCounterSessions is the per-session cash report for reconciliation
+func (pbc *ParcelBookingController) ListCounterSessions(c *fiber.Ctx) error {
+	var req parcel_booking_types.CounterSessionListRequest
+	if err := c.QueryParser(&req); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query := pbc.DB.Model(&parcel_booking.CounterSession{})
+	if req.BranchCode != "" {
+		query = query.Where("branch_code = ?", req.BranchCode)
+	}
+	if req.OperatorID != 0 {
+		query = query.Where("operator_id = ?", req.OperatorID)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.From != "" {
+		from, _ := time.ParseInLocation("2006-01-02", req.From, branch_schedule.Location())
+		query = query.Where("opened_at >= ?", from)
+	}
+	if req.To != "" {
+		to, _ := time.ParseInLocation("2006-01-02", req.To, branch_schedule.Location())
+		query = query.Where("opened_at < ?", to.AddDate(0, 0, 1))
+	}
+
+	var sessions []parcel_booking.CounterSession
+	if err := query.Order("opened_at DESC").Limit(500).Find(&sessions).Error; err != nil {
+		logger.Error("Failed to fetch counter sessions", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch counter sessions",
+			Data:    nil,
+		})
+	}
+
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Counter sessions retrieved successfully",
+		Data:    sessions,
+	})
+}
+
+// ShowCounterSession returns one session with the bookings it collected fees for
+func (pbc *ParcelBookingController) ShowCounterSession(c *fiber.Ctx) error {
+	sessionID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid counter session ID",
+			Data:    nil,
+		})
+	}
+
+	var session parcel_booking.CounterSession
+	err = pbc.DB.First(&session, sessionID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return pbc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Counter session not found",
+			Data:    nil,
+		})
+	}
+	var report *parcel_booking_types.CounterSessionReport
+	if err == nil {
+		report, err = pbc.sessionReport(session)
+	}
+	if err != nil {
+		logger.Error("Failed to load counter session", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Counter session retrieved successfully",
+		Data:    report,
+	})
+}
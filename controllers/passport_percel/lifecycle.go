@@ -0,0 +1,547 @@
+package passport_percel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	otpModel "passport-booking/models/otp"
+	"passport-booking/models/parcel_booking"
+	userModel "passport-booking/models/user"
+	otpService "passport-booking/services/otp"
+	"passport-booking/types"
+	parcel_booking_types "passport-booking/types/parcel_booking"
+	"passport-booking/utils"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// currentUser resolves the authenticated user, responding with an error when it cannot
+func (pbc *ParcelBookingController) currentUser(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, pbc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User not found",
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// findByBarcode loads a parcel booking, responding with 404/500 when it cannot
+func (pbc *ParcelBookingController) findByBarcode(c *fiber.Ctx, barcode string) (*parcel_booking.ParcelBooking, error) {
+	var parcel parcel_booking.ParcelBooking
+	if err := pbc.DB.Preload("User").Where("barcode = ?", barcode).First(&parcel).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, pbc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Parcel booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to find parcel booking", err)
+		return nil, pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+	return &parcel, nil
+}
+
+// recordStatusEvent appends to the parcel's status history without failing the request
+func (pbc *ParcelBookingController) recordStatusEvent(c *fiber.Ctx, parcelID uint, status string, userID uint) {
+	event := parcel_booking.ParcelBookingStatusEvent{
+		ParcelBookingID: parcelID,
+		Status:          status,
+		CreatedBy:       userID,
+	}
+	if err := pbc.DB.WithContext(c.UserContext()).Create(&event).Error; err != nil {
+		logger.Error(fmt.Sprintf("Failed to create parcel booking status event (%s) for parcel_booking_id: %d", status, parcelID), err)
+	}
+}
+
+// Receive records that a booked parcel has arrived at the regional passport office
+func (pbc *ParcelBookingController) Receive(c *fiber.Ctx) error {
+	var req parcel_booking_types.ReceiveParcelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request format",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := pbc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	parcel, err := pbc.findByBarcode(c, req.Barcode)
+	if parcel == nil {
+		return err
+	}
+
+	if parcel.CurrentStatus == string(parcel_booking.ParcelBookingStatusReceived) {
+		return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+			Status:  fiber.StatusOK,
+			Message: "Parcel is already received",
+			Data:    parcel_booking_types.NewParcelBookingResponse(parcel),
+		})
+	}
+	if parcel.CurrentStatus != string(parcel_booking.ParcelBookingStatusBooked) {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Parcel booking must be booked before it can be received",
+			Data:    nil,
+		})
+	}
+
+	now := time.Now()
+	parcel.CurrentStatus = string(parcel_booking.ParcelBookingStatusReceived)
+	parcel.ReceivedDate = &now
+	parcel.UpdatedBy = fmt.Sprintf("%d", userInfo.ID)
+	if err := pbc.DB.Save(parcel).Error; err != nil {
+		logger.Error("Failed to mark parcel booking received", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update parcel booking",
+			Data:    nil,
+		})
+	}
+	pbc.recordStatusEvent(c, parcel.ID, parcel.CurrentStatus, userInfo.ID)
+
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Parcel received successfully",
+		Data:    parcel_booking_types.NewParcelBookingResponse(parcel),
+	})
+}
+
+// DeliverySendOtp sends the hand-over OTP to the phone on the parcel booking
+func (pbc *ParcelBookingController) DeliverySendOtp(c *fiber.Ctx) error {
+	var req parcel_booking_types.ParcelDeliverySendOtpRequest
+	if err := c.BodyParser(&req); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request format",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := pbc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	parcel, err := pbc.findByBarcode(c, req.Barcode)
+	if parcel == nil {
+		return err
+	}
+
+	if parcel.CurrentStatus != string(parcel_booking.ParcelBookingStatusReceived) {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Parcel must be received before delivery confirmation",
+			Data:    nil,
+		})
+	}
+
+	// Reset verification for this hand-over attempt
+	if err := pbc.DB.Model(parcel).Update("delivery_phone_verified", false).Error; err != nil {
+		logger.Error("Failed to reset parcel delivery verification", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update parcel booking",
+			Data:    nil,
+		})
+	}
+	parcel.DeliveryPhoneVerified = false
+
+	// Parcel bookings are not bookings; booking ID 0 marks a non-booking OTP as in SendOTP
+	noBooking := uint(0)
+	otpRecord, err := pbc.OTPService.SendOTPWithBookingID(parcel.Phone, otpModel.OTPPurposeParcelDelivery, &noBooking)
+	if err != nil {
+		logger.Error("Failed to send parcel delivery OTP", err)
+		errMsg := err.Error()
+		if errMsg == "OTP requests are blocked permanently due to too many failed attempts" ||
+			strings.HasPrefix(errMsg, "OTP requests are blocked until") || otpService.IsRateLimited(err) {
+			return pbc.sendResponseWithLog(c, fiber.StatusTooManyRequests, types.ApiResponse{
+				Status:  fiber.StatusTooManyRequests,
+				Message: errMsg,
+				Data:    nil,
+			})
+		}
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to send delivery confirmation OTP",
+			Data: fiber.Map{
+				"otp_error": errMsg,
+			},
+		})
+	}
+
+	// Bind the OTP to this user; the returned session must be sent back on verify
+	otpSession, err := pbc.OTPService.BindSession(otpRecord, noBooking, userInfo.ID)
+	if err != nil {
+		logger.Error("Failed to bind parcel delivery OTP session", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to start delivery confirmation session",
+			Data:    nil,
+		})
+	}
+
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delivery confirmation OTP sent successfully",
+		Data: fiber.Map{
+			"parcel_booking": parcel_booking_types.NewParcelBookingResponse(parcel),
+			"otp_info": fiber.Map{
+				"otp_id":      otpRecord.ID,
+				"expires_at":  otpRecord.ExpiresAt,
+				"phone":       parcel.Phone,
+				"purpose":     otpModel.OTPPurposeParcelDelivery,
+				"otp_session": otpSession,
+			},
+		},
+	})
+}
+
+// DeliveryVerifyOtp checks the hand-over OTP entered by the applicant
+func (pbc *ParcelBookingController) DeliveryVerifyOtp(c *fiber.Ctx) error {
+	var req parcel_booking_types.ParcelDeliveryVerifyOtpRequest
+	if err := c.BodyParser(&req); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request format",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := pbc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	parcel, err := pbc.findByBarcode(c, req.Barcode)
+	if parcel == nil {
+		return err
+	}
+
+	if parcel.CurrentStatus != string(parcel_booking.ParcelBookingStatusReceived) {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Parcel must be received before delivery confirmation",
+			Data:    nil,
+		})
+	}
+
+	// Parcel delivery OTPs are issued with booking ID 0, see DeliverySendOtp
+	isValid, _, err := pbc.OTPService.VerifyOTPWithSession(parcel.Phone, req.OTPCode, otpModel.OTPPurposeParcelDelivery, 0, req.OTPSession, userInfo.ID)
+	if err != nil {
+		if errors.Is(err, otpService.ErrOTPSessionMismatch) {
+			return pbc.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+				Status:  fiber.StatusForbidden,
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to verify parcel delivery OTP", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if !isValid {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid OTP code",
+			Data:    nil,
+		})
+	}
+
+	if err := pbc.DB.Model(parcel).Update("delivery_phone_verified", true).Error; err != nil {
+		logger.Error("Failed to mark parcel delivery phone verified", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update parcel booking",
+			Data:    nil,
+		})
+	}
+	parcel.DeliveryPhoneVerified = true
+
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delivery phone verified successfully",
+		Data:    parcel_booking_types.NewParcelBookingResponse(parcel),
+	})
+}
+
+// UploadDeliveryPhoto stores the hand-over photo of a received parcel
+func (pbc *ParcelBookingController) UploadDeliveryPhoto(c *fiber.Ctx) error {
+	barcode := utils.NormalizeBarcode(c.FormValue("barcode"))
+	if barcode == "" {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "barcode is required",
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := pbc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	parcel, err := pbc.findByBarcode(c, barcode)
+	if parcel == nil {
+		return err
+	}
+
+	if parcel.CurrentStatus != string(parcel_booking.ParcelBookingStatusReceived) {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Parcel must be received before a delivery photo is uploaded",
+			Data:    nil,
+		})
+	}
+
+	if parcel.UploadPhoto != nil && *parcel.UploadPhoto != "" && pbc.Storage.Exists(*parcel.UploadPhoto) {
+		return pbc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: "Photo already uploaded for this parcel",
+			Data: fiber.Map{
+				"parcel_booking_id": parcel.ID,
+				"existing_photo":    *parcel.UploadPhoto,
+			},
+		})
+	}
+
+	file, err := c.FormFile("photo")
+	if err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Photo file is required",
+			Data:    nil,
+		})
+	}
+
+	allowedTypes := map[string]string{
+		"image/jpeg": ".jpg",
+		"image/jpg":  ".jpg",
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+		"image/webp": ".webp",
+	}
+	fileType := file.Header.Get("Content-Type")
+	defaultExt, ok := allowedTypes[fileType]
+	if !ok {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid file type. Only JPEG, PNG, GIF, and WebP images are allowed",
+			Data:    nil,
+		})
+	}
+	if file.Size > 10<<20 {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "File size too large. Maximum size is 10MB",
+			Data:    nil,
+		})
+	}
+
+	fileExt := strings.ToLower(filepath.Ext(file.Filename))
+	if fileExt == "" {
+		fileExt = defaultExt
+	}
+	filename := fmt.Sprintf("parcel_%d_%s%s", parcel.ID, time.Now().Format("20060102_150405"), fileExt)
+
+	filePath, err := pbc.Storage.Save(file, filename)
+	if err != nil {
+		logger.Error("Failed to save uploaded file", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to save uploaded file",
+			Data:    nil,
+		})
+	}
+
+	if err := pbc.DB.Model(parcel).Update("upload_photo", filePath).Error; err != nil {
+		logger.Error("Failed to update parcel booking with photo path", err)
+		pbc.Storage.Remove(filePath)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update parcel booking with photo information",
+			Data:    nil,
+		})
+	}
+
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Photo uploaded successfully",
+		Data: fiber.Map{
+			"parcel_booking_id": parcel.ID,
+			"photo_path":        filePath,
+			"filename":          filename,
+		},
+	})
+}
+
+// Deliver hands a received parcel to the applicant once the OTP is verified and a
+// photo is on file, reporting the delivery to DMS
+func (pbc *ParcelBookingController) Deliver(c *fiber.Ctx) error {
+	var req parcel_booking_types.ParcelDeliverRequest
+	if err := c.BodyParser(&req); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request format",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return pbc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "Authorization header is required",
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := pbc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	parcel, err := pbc.findByBarcode(c, req.Barcode)
+	if parcel == nil {
+		return err
+	}
+
+	if parcel.CurrentStatus == string(parcel_booking.ParcelBookingStatusDelivered) {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Parcel is already delivered",
+			Data:    nil,
+		})
+	}
+	if parcel.CurrentStatus != string(parcel_booking.ParcelBookingStatusReceived) {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Parcel must be received before delivery. Please receive the parcel first.",
+			Data:    nil,
+		})
+	}
+	if !parcel.DeliveryPhoneVerified {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Delivery phone must be verified before delivery",
+			Data:    nil,
+		})
+	}
+	if parcel.UploadPhoto == nil || *parcel.UploadPhoto == "" {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Photo must be uploaded before delivery",
+			Data:    nil,
+		})
+	}
+
+	resp, err := pbc.DMS.DeliverArticle(authHeader, dms.DeliverArticleRequest{
+		ArticleID: parcel.Barcode,
+	})
+	if err != nil {
+		logger.Error("Failed to call external delivery API", err)
+		message := "Failed to connect to external delivery service"
+		if errors.Is(err, dms.ErrBaseURLNotSet) {
+			message = "External service configuration error"
+		}
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: message,
+			Data:    nil,
+		})
+	}
+
+	var externalAPIResponse interface{}
+	if err := json.Unmarshal(resp.Body, &externalAPIResponse); err != nil {
+		externalAPIResponse = string(resp.Body)
+	}
+	if resp.StatusCode != http.StatusOK {
+		logger.Error(fmt.Sprintf("External delivery API returned error: %d", resp.StatusCode), nil)
+		return pbc.sendResponseWithLog(c, fiber.StatusBadGateway, types.ApiResponse{
+			Status:  fiber.StatusBadGateway,
+			Message: "External delivery service failed",
+			Data: fiber.Map{
+				"external_status":   resp.StatusCode,
+				"external_response": externalAPIResponse,
+			},
+		})
+	}
+
+	now := time.Now()
+	parcel.CurrentStatus = string(parcel_booking.ParcelBookingStatusDelivered)
+	parcel.DeliveredDate = &now
+	parcel.DeliveredLatitude = req.Latitude
+	parcel.DeliveredLongitude = req.Longitude
+	parcel.UpdatedBy = fmt.Sprintf("%d", userInfo.ID)
+	if err := pbc.DB.Save(parcel).Error; err != nil {
+		logger.Error("Failed to update parcel booking status after delivery", err)
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update parcel booking status",
+			Data:    nil,
+		})
+	}
+	pbc.recordStatusEvent(c, parcel.ID, parcel.CurrentStatus, userInfo.ID)
+
+	logger.Success(fmt.Sprintf("Parcel delivered successfully for parcel_booking_id: %d (Barcode: %s) by: %s", parcel.ID, parcel.Barcode, userInfo.LegalName))
+
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Parcel delivered successfully",
+		Data: fiber.Map{
+			"parcel_booking":    parcel_booking_types.NewParcelBookingResponse(parcel),
+			"delivered":         true,
+			"external_response": externalAPIResponse,
+		},
+	})
+}
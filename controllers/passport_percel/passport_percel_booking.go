@@ -1,14 +1,20 @@
 package passport_percel
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"passport-booking/database"
+	"passport-booking/httpServices/dms"
 	"passport-booking/logger"
+	"passport-booking/middleware"
+	barcodeModel "passport-booking/models/barcode"
 	"passport-booking/models/parcel_booking"
+	barcodeService "passport-booking/services/barcode"
+	"passport-booking/services/counter_session"
+	"passport-booking/services/localtime"
+	otpService "passport-booking/services/otp"
+	parcelPush "passport-booking/services/parcel_push"
+	"passport-booking/services/storage"
 	"passport-booking/types"
 	parcel_booking_types "passport-booking/types/parcel_booking"
 	"passport-booking/utils"
@@ -20,15 +26,26 @@ import (
 
 // ParcelBookingController handles parcel booking related HTTP requests
 type ParcelBookingController struct {
-	DB     *gorm.DB
-	Logger *logger.AsyncLogger
+	DB         *gorm.DB
+	Logger     *logger.AsyncLogger
+	Barcodes   *barcodeService.Service
+	Pusher     *parcelPush.Pusher
+	DMS        dms.Client
+	OTPService otpService.OTPService
+	Storage    storage.FileStorage
 }
 
 // NewParcelBookingController creates a new parcel booking controller
 func NewParcelBookingController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *ParcelBookingController {
+	dmsClient := dms.NewDMSService()
 	return &ParcelBookingController{
-		DB:     db,
-		Logger: asyncLogger,
+		DB:         db,
+		Logger:     asyncLogger,
+		Barcodes:   barcodeService.NewBarcodeService(db, dmsClient),
+		Pusher:     parcelPush.NewPusher(db, dmsClient),
+		DMS:        dmsClient,
+		OTPService: otpService.NewOTPService(db),
+		Storage:    storage.NewLocalStorage(storage.DeliveryPhotoDir),
 	}
 }
 
@@ -59,42 +76,17 @@ func (pbc *ParcelBookingController) Store(c *fiber.Ctx) error {
 		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, response)
 	}
 
-	// Get user authentication information (following booking.go pattern)
-	claims, ok := c.Locals("user").(map[string]interface{})
+	// Get the authenticated user
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		response := types.ApiResponse{
 			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid user claims",
+			Message: "User not found",
 			Data:    nil,
 		}
 		return pbc.sendResponseWithLog(c, fiber.StatusUnauthorized, response)
 	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		response := types.ApiResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "User UUID not found in token",
-			Data:    nil,
-		}
-		return pbc.sendResponseWithLog(c, fiber.StatusUnauthorized, response)
-	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		response := types.ApiResponse{
-			Status:  status,
-			Message: msg,
-			Data:    nil,
-		}
-		return pbc.sendResponseWithLog(c, status, response)
-	}
+	userInfo := current.User
 
 	userID := uint(userInfo.ID)
 
@@ -109,16 +101,18 @@ func (pbc *ParcelBookingController) Store(c *fiber.Ctx) error {
 		response := types.ApiResponse{
 			Status:  fiber.StatusOK,
 			Message: "Existing parcel booking found",
-			Data:    existingParcel,
+			Data:    parcel_booking_types.NewParcelBookingResponse(&existingParcel),
 		}
 		return pbc.sendResponseWithLog(c, fiber.StatusOK, response)
 	}
 
-	// Generate barcode from API before creating the parcel booking
+	// Generate barcode from API before creating the parcel booking, falling
+	// back to a provisional barcode when DMS is down and the fallback is enabled
 	var barcode string
+	var provisional bool
 	authHeader := c.Get("Authorization")
 	if authHeader != "" {
-		generatedBarcode, err := pbc.getBarcodeFromAPI(authHeader)
+		generatedBarcode, isProvisional, err := pbc.Barcodes.Obtain(authHeader, request.PostCode)
 		if err != nil {
 			// Log the error and return the actual error message - don't create parcel without barcode
 			logger.Error("Failed to generate barcode", err)
@@ -130,6 +124,7 @@ func (pbc *ParcelBookingController) Store(c *fiber.Ctx) error {
 			return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, response)
 		}
 		barcode = generatedBarcode
+		provisional = isProvisional
 	} else {
 		// No authorization header provided
 		response := types.ApiResponse{
@@ -165,6 +160,12 @@ func (pbc *ParcelBookingController) Store(c *fiber.Ctx) error {
 		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, response)
 	}
 
+	if provisional {
+		if err := pbc.Barcodes.Attach(pbc.DB, barcode, barcodeModel.EntityTypeParcelBooking, newParcel.ID); err != nil {
+			logger.Error(fmt.Sprintf("Failed to attach provisional barcode %s to parcel_booking_id: %d", barcode, newParcel.ID), err)
+		}
+	}
+
 	// Create initial parcel booking status event
 	initialEvent := parcel_booking.ParcelBookingStatusEvent{
 		ParcelBookingID: newParcel.ID,
@@ -172,7 +173,7 @@ func (pbc *ParcelBookingController) Store(c *fiber.Ctx) error {
 		CreatedBy:       userID,
 	}
 
-	if err := pbc.DB.Create(&initialEvent).Error; err != nil {
+	if err := pbc.DB.WithContext(c.UserContext()).Create(&initialEvent).Error; err != nil {
 		// Log the error but don't fail the entire operation
 		// since the parcel booking was created successfully
 		logger.Error(fmt.Sprintf("Failed to create initial parcel booking status event for parcel_booking_id: %d", newParcel.ID), err)
@@ -184,65 +185,12 @@ func (pbc *ParcelBookingController) Store(c *fiber.Ctx) error {
 	response := types.ApiResponse{
 		Status:  fiber.StatusCreated,
 		Message: "Parcel booking created successfully",
-		Data:    newParcel,
+		Data:    parcel_booking_types.NewParcelBookingResponse(&newParcel),
 	}
 
 	return pbc.sendResponseWithLog(c, fiber.StatusCreated, response)
 }
 
-// getBarcodeFromAPI generates a barcode by calling the external DMS API
-func (pbc *ParcelBookingController) getBarcodeFromAPI(authHeader string) (string, error) {
-	baseURL := os.Getenv("DMS_BASE_URL")
-	url := fmt.Sprintf("%s/dms/api/get-barcode/", baseURL)
-
-	payload := map[string]interface{}{
-		"service_type": "letter",
-	}
-
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal payload: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", authHeader)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to call barcode API: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
-	}
-
-	// Accept both 200 and 201 as success status codes
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("barcode API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var barcodeResp map[string]interface{}
-	if err := json.Unmarshal(body, &barcodeResp); err != nil {
-		return "", fmt.Errorf("failed to parse barcode response: %v", err)
-	}
-
-	// Extract barcode from response
-	barcode, ok := barcodeResp["barcode"].(string)
-	if !ok {
-		return "", fmt.Errorf("barcode not found in response")
-	}
-
-	return barcode, nil
-}
-
 // StorePendingBooking handles updating a parcel booking status to pending
 func (pbc *ParcelBookingController) StorePendingBooking(c *fiber.Ctx) error {
 	var request parcel_booking_types.StorePendingBookingRequest
@@ -257,42 +205,17 @@ func (pbc *ParcelBookingController) StorePendingBooking(c *fiber.Ctx) error {
 		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, response)
 	}
 
-	// Get user authentication information
-	claims, ok := c.Locals("user").(map[string]interface{})
+	// Get the authenticated user
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		response := types.ApiResponse{
 			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid user claims",
-			Data:    nil,
-		}
-		return pbc.sendResponseWithLog(c, fiber.StatusUnauthorized, response)
-	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		response := types.ApiResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "User UUID not found in token",
+			Message: "User not found",
 			Data:    nil,
 		}
 		return pbc.sendResponseWithLog(c, fiber.StatusUnauthorized, response)
 	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		response := types.ApiResponse{
-			Status:  status,
-			Message: msg,
-			Data:    nil,
-		}
-		return pbc.sendResponseWithLog(c, status, response)
-	}
+	userInfo := current.User
 
 	userID := uint(userInfo.ID)
 
@@ -340,7 +263,7 @@ func (pbc *ParcelBookingController) StorePendingBooking(c *fiber.Ctx) error {
 		CreatedBy:       userID,
 	}
 
-	if err := pbc.DB.Create(&statusEvent).Error; err != nil {
+	if err := pbc.DB.WithContext(c.UserContext()).Create(&statusEvent).Error; err != nil {
 		// Log the error but don't fail the entire operation
 		logger.Error(fmt.Sprintf("Failed to create parcel booking status event for parcel_booking_id: %d", parcelBooking.ID), err)
 	}
@@ -351,7 +274,7 @@ func (pbc *ParcelBookingController) StorePendingBooking(c *fiber.Ctx) error {
 	response := types.ApiResponse{
 		Status:  statusCode,
 		Message: message,
-		Data:    parcelBooking,
+		Data:    parcel_booking_types.NewParcelBookingResponse(&parcelBooking),
 	}
 
 	return pbc.sendResponseWithLog(c, statusCode, response)
@@ -371,42 +294,17 @@ func (pbc *ParcelBookingController) StoreSubmit(c *fiber.Ctx) error {
 		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, response)
 	}
 
-	// Get user authentication information
-	claims, ok := c.Locals("user").(map[string]interface{})
+	// Get the authenticated user
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		response := types.ApiResponse{
 			Status:  fiber.StatusUnauthorized,
-			Message: "Invalid user claims",
+			Message: "User not found",
 			Data:    nil,
 		}
 		return pbc.sendResponseWithLog(c, fiber.StatusUnauthorized, response)
 	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		response := types.ApiResponse{
-			Status:  fiber.StatusUnauthorized,
-			Message: "User UUID not found in token",
-			Data:    nil,
-		}
-		return pbc.sendResponseWithLog(c, fiber.StatusUnauthorized, response)
-	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		response := types.ApiResponse{
-			Status:  status,
-			Message: msg,
-			Data:    nil,
-		}
-		return pbc.sendResponseWithLog(c, status, response)
-	}
+	userInfo := current.User
 
 	userID := uint(userInfo.ID)
 
@@ -437,7 +335,7 @@ func (pbc *ParcelBookingController) StoreSubmit(c *fiber.Ctx) error {
 		response := types.ApiResponse{
 			Status:  fiber.StatusOK,
 			Message: "Parcel booking is already submitted",
-			Data:    parcelBooking,
+			Data:    parcel_booking_types.NewParcelBookingResponse(&parcelBooking),
 		}
 		return pbc.sendResponseWithLog(c, fiber.StatusOK, response)
 	}
@@ -453,42 +351,50 @@ func (pbc *ParcelBookingController) StoreSubmit(c *fiber.Ctx) error {
 		return pbc.sendResponseWithLog(c, fiber.StatusUnauthorized, response)
 	}
 
-	dmsBody, dmsStatusCode, err := pbc.BookingDms(authHeader, request.Barcode, parcelBooking.ID)
-	if err != nil {
-		// Log the error with more details
-		//logger.Error(fmt.Sprintf("DMS booking failed for barcode %s: %v", request.Barcode, err))
-		logger.Error("DMS booking failed", err)
-		response := types.ApiResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: fmt.Sprintf("Failed to call external booking API: %v", err),
-			Data:    nil,
+	// Link the fee to the operator's counter session for cash reconciliation
+	if err := counter_session.Attach(pbc.DB, parcelBooking.ID, userID); err != nil {
+		if !errors.Is(err, counter_session.ErrNoOpenSession) {
+			logger.Error(fmt.Sprintf("Failed to link parcel_booking_id %d to counter session", parcelBooking.ID), err)
+		} else if counter_session.Required() {
+			response := types.ApiResponse{
+				Status:  fiber.StatusConflict,
+				Message: "Open a counter session before submitting bookings",
+				Data:    nil,
+			}
+			return pbc.sendResponseWithLog(c, fiber.StatusConflict, response)
 		}
-		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, response)
 	}
 
-	// Handle DMS API response
-	if dmsStatusCode != http.StatusOK && dmsStatusCode != http.StatusCreated {
-		// Log the DMS response for debugging
-		//logger.Error(fmt.Sprintf("DMS API returned status %d for barcode %s. Response: %s", dmsStatusCode, request.Barcode, string(dmsBody)))
-		logger.Error("Failed to Booking", err)
-		response := types.ApiResponse{
-			Status:  fiber.StatusInternalServerError,
-			Message: fmt.Sprintf("DMS API returned status %d", dmsStatusCode),
-			Data:    string(dmsBody),
+	parcel, err := pbc.Pusher.Push(authHeader, parcelBooking.ID, userID, fmt.Sprintf("%d", userID))
+	if err != nil {
+		var pushErr *parcelPush.PushError
+		if errors.Is(err, dms.ErrUnavailable) && parcel.NextPushAt != nil {
+			// The booking is saved as pending and the push worker submits it once DMS is back
+			response := types.ApiResponse{
+				Status:  fiber.StatusAccepted,
+				Message: "DMS is currently unavailable. The booking has been saved and will sync to DMS automatically",
+				Data:    parcel_booking_types.NewParcelBookingResponse(parcel),
+			}
+			return pbc.sendResponseWithLog(c, fiber.StatusAccepted, response)
+		}
+		if errors.As(err, &pushErr) {
+			logger.Error("DMS booking failed", err)
+			message := fmt.Sprintf("Failed to call external booking API: %v", err)
+			if pushErr.StatusCode != 0 {
+				message = fmt.Sprintf("DMS API returned status %d", pushErr.StatusCode)
+			}
+			if parcel.NextPushAt != nil {
+				message += "; retry scheduled"
+			}
+			response := types.ApiResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: message,
+				Data:    parcel_booking_types.NewParcelBookingResponse(parcel),
+			}
+			return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, response)
 		}
-		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, response)
-	}
-
-	// Log successful DMS response
-	logger.Info(fmt.Sprintf("DMS booking successful for barcode %s. Status: %d", request.Barcode, dmsStatusCode))
-
-	// Update parcel booking status to booked
-	now := time.Now()
-	parcelBooking.CurrentStatus = string(parcel_booking.ParcelBookingStatusBooked)
-	parcelBooking.BookingDate = &now
-	parcelBooking.UpdatedBy = fmt.Sprintf("%d", userID)
 
-	if err := pbc.DB.Save(&parcelBooking).Error; err != nil {
+		logger.Error(fmt.Sprintf("Failed to submit parcel_booking_id: %d", parcelBooking.ID), err)
 		response := types.ApiResponse{
 			Status:  fiber.StatusInternalServerError,
 			Message: "Failed to update parcel booking status",
@@ -497,141 +403,15 @@ func (pbc *ParcelBookingController) StoreSubmit(c *fiber.Ctx) error {
 		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, response)
 	}
 
-	// Create parcel booking status event for booked status
-	statusEvent := parcel_booking.ParcelBookingStatusEvent{
-		ParcelBookingID: parcelBooking.ID,
-		Status:          string(parcel_booking.ParcelBookingStatusBooked),
-		CreatedBy:       userID,
-	}
-
-	if err := pbc.DB.Create(&statusEvent).Error; err != nil {
-		// Log the error but don't fail the entire operation
-		logger.Error(fmt.Sprintf("Failed to create parcel booking status event for parcel_booking_id: %d", parcelBooking.ID), err)
-	}
-
-	// Load the user relationship for response
-	pbc.DB.Preload("User").First(&parcelBooking, parcelBooking.ID)
-
 	response := types.ApiResponse{
 		Status:  fiber.StatusOK,
 		Message: "Parcel booking submitted successfully",
-		Data:    parcelBooking,
+		Data:    parcel_booking_types.NewParcelBookingResponse(parcel),
 	}
 
 	return pbc.sendResponseWithLog(c, fiber.StatusOK, response)
 }
 
-// BookingDms calls the external DMS API to book a parcel
-func (pbc *ParcelBookingController) BookingDms(authHeader, barcode string, parcelBookingID uint) ([]byte, int, error) {
-	baseURL := os.Getenv("DMS_BASE_URL")
-	url := fmt.Sprintf("%s/dms/book/article/", baseURL)
-
-	// Find the parcel booking by ID with user relationship
-	var parcelBooking parcel_booking.ParcelBooking
-	if err := pbc.DB.
-		Preload("User").
-		Where("id = ?", parcelBookingID).
-		First(&parcelBooking).Error; err != nil {
-		return nil, 0, fmt.Errorf("parcel booking not found: %v", err)
-	}
-
-	// Check if parcel booking exists and is in pending status
-	if parcelBooking.ID == 0 {
-		return nil, 0, fmt.Errorf("parcel booking not found")
-	}
-
-	if parcelBooking.CurrentStatus != string(parcel_booking.ParcelBookingStatusPending) {
-		return nil, 0, fmt.Errorf("parcel booking is not in pending status")
-	}
-
-	// Check if required user data is loaded
-	if parcelBooking.User.Uuid == "" {
-		return nil, 0, fmt.Errorf("user information not found for parcel booking")
-	}
-
-	// Create the actual request body structure
-	payload := map[string]interface{}{
-		"ad_pod_id":        "1",
-		"article_desc":     "Passport Delivery",
-		"article_price":    100,
-		"barcode":          barcode,
-		"city_post_status": "No",
-		"delivery_branch":  "100000",
-		"emts_branch_code": "100000",
-		"height":           10,
-		"hnddevice":        "web",
-		"image_pod":        "0",
-		"image_src":        "No",
-		"insurance_price":  "0",
-		"is_bulk_mail":     "No",
-		"isCharge":         "Yes",
-		"is_city_post":     "No",
-		"is_international": false,
-		"isStation":        "No",
-		"length":           10,
-		"receiver": map[string]interface{}{
-			"address_type":   "home",
-			"country":        "Bangladesh",
-			"district":       parcelBooking.RpoName, // Using RpoName as district
-			"division":       "",                    // Can be enhanced if needed
-			"phone_number":   parcelBooking.Phone,
-			"police_station": "",
-			"post_office":    parcelBooking.PostCode,
-			"street_address": parcelBooking.RpoAddress,
-			"user_uuid":      parcelBooking.User.Uuid,
-			"username":       parcelBooking.User.Username,
-			"zone":           "Zone 1",
-		},
-		"sender": map[string]interface{}{
-			"address_type":   "office",
-			"country":        "Bangladesh",
-			"district":       "Dhaka",
-			"division":       "Dhaka",
-			"phone_number":   "018XXXXXXXX",
-			"police_station": "Gulshan",
-			"post_office":    "Gulshan",
-			"street_address": "456, Gulshan, Dhaka",
-			"user_uuid":      parcelBooking.User.Uuid,
-			"username":       "passport-office",
-			"zone":           "Zone 2",
-		},
-		"service_name": "letter",
-		"set_ad":       "No",
-		"vas_type":     "Registry",
-		"vp_amount":    "0",
-		"vp_service":   "No",
-		"weight":       100,
-		"width":        10,
-	}
-
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to marshal payload: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", authHeader)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to call booking API: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to read response: %v", err)
-	}
-
-	return body, resp.StatusCode, nil
-}
-
 // Index handles listing parcel bookings with pagination and filtering
 func (pbc *ParcelBookingController) Index(c *fiber.Ctx) error {
 	// Default pagination
@@ -646,15 +426,15 @@ func (pbc *ParcelBookingController) Index(c *fiber.Ctx) error {
 	status := c.Query("status")
 
 	// Build the query
-	query := pbc.DB.Model(&parcel_booking.ParcelBooking{})
+	query := pbc.DB.WithContext(c.UserContext()).Model(&parcel_booking.ParcelBooking{})
 
 	if barcode != "" {
 		query = query.Where("barcode LIKE ?", "%"+barcode+"%")
 	}
 
 	if startDateStr != "" && endDateStr != "" {
-		startDate, err1 := time.Parse("2006-01-02", startDateStr)
-		endDate, err2 := time.Parse("2006-01-02", endDateStr)
+		startDate, err1 := localtime.ParseDate("2006-01-02", startDateStr)
+		endDate, err2 := localtime.ParseDate("2006-01-02", endDateStr)
 		if err1 == nil && err2 == nil {
 			query = query.Where("created_at BETWEEN ? AND ?", startDate, endDate.Add(24*time.Hour))
 		}
@@ -677,7 +457,7 @@ func (pbc *ParcelBookingController) Index(c *fiber.Ctx) error {
 
 	// Get parcel bookings with pagination
 	var parcelBookings []parcel_booking.ParcelBooking
-	if err := query.Preload("User").Offset(offset).Limit(limit).Order("created_at desc").Find(&parcelBookings).Error; err != nil {
+	if err := query.Preload("User", database.UserSummary).Offset(offset).Limit(limit).Order("created_at desc").Find(&parcelBookings).Error; err != nil {
 		response := types.ApiResponse{
 			Status:  fiber.StatusInternalServerError,
 			Message: "Failed to retrieve parcel bookings",
@@ -686,11 +466,24 @@ func (pbc *ParcelBookingController) Index(c *fiber.Ctx) error {
 		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, response)
 	}
 
+	parcelResponses := parcel_booking_types.NewParcelBookingResponsesMasked(parcelBookings, middleware.MaskPII(c))
+
+	// Apply ?fields= sparse fieldset if requested
+	data, err := utils.SelectFields(parcelResponses, c.Query("fields"))
+	if err != nil {
+		response := types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to prepare parcel bookings",
+			Data:    nil,
+		}
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, response)
+	}
+
 	response := types.ApiResponse{
 		Status:  fiber.StatusOK,
 		Message: "Parcel bookings retrieved successfully",
 		Data: fiber.Map{
-			"data":      parcelBookings,
+			"data":      data,
 			"total":     total,
 			"page":      page,
 			"limit":     limit,
@@ -700,3 +493,106 @@ func (pbc *ParcelBookingController) Index(c *fiber.Ctx) error {
 
 	return pbc.sendResponseWithLog(c, fiber.StatusOK, response)
 }
+
+// ReconcileBarcodes swaps pending provisional barcodes for DMS barcodes
+func (pbc *ParcelBookingController) ReconcileBarcodes(c *fiber.Ctx) error {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		response := types.ApiResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "Authorization header required",
+			Data:    nil,
+		}
+		return pbc.sendResponseWithLog(c, fiber.StatusUnauthorized, response)
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	reconciled, err := pbc.Barcodes.ReconcilePending(authHeader, limit)
+	if err != nil {
+		logger.Error("Failed to reconcile provisional barcodes", err)
+		response := types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to reconcile provisional barcodes",
+			Data:    nil,
+		}
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, response)
+	}
+
+	response := types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Provisional barcodes reconciled",
+		Data: fiber.Map{
+			"reconciled": reconciled,
+		},
+	}
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, response)
+}
+
+// StuckPushes lists parcel bookings whose DMS submission keeps failing
+func (pbc *ParcelBookingController) StuckPushes(c *fiber.Ctx) error {
+	var req parcel_booking_types.StuckPushRequest
+	if err := c.QueryParser(&req); err != nil {
+		response := types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		}
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, response)
+	}
+	if err := req.Validate(); err != nil {
+		response := types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		}
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, response)
+	}
+
+	parcels, err := parcelPush.Stuck(pbc.DB, time.Duration(req.OlderThanMinutes)*time.Minute, req.Limit)
+	if err != nil {
+		logger.Error("Failed to load stuck parcel pushes", err)
+		response := types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to load stuck pushes",
+			Data:    nil,
+		}
+		return pbc.sendResponseWithLog(c, fiber.StatusInternalServerError, response)
+	}
+
+	now := time.Now()
+	maxAttempts := parcelPush.MaxAttempts()
+	items := make([]parcel_booking_types.StuckPush, 0, len(parcels))
+	for _, p := range parcels {
+		since := p.CreatedAt
+		if p.PendingDate != nil {
+			since = *p.PendingDate
+		}
+		items = append(items, parcel_booking_types.StuckPush{
+			ID:           p.ID,
+			Barcode:      p.Barcode,
+			RpoName:      p.RpoName,
+			PostCode:     p.PostCode,
+			PushAttempts: p.PushAttempts,
+			LastError:    p.PushLastError,
+			LastPushAt:   p.LastPushAt,
+			NextPushAt:   p.NextPushAt,
+			GaveUp:       p.NextPushAt == nil || p.PushAttempts >= maxAttempts,
+			AgeMinutes:   int64(now.Sub(since).Minutes()),
+		})
+	}
+
+	response := types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Stuck parcel pushes retrieved successfully",
+		Data: fiber.Map{
+			"data":         items,
+			"total":        len(items),
+			"max_attempts": maxAttempts,
+		},
+	}
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, response)
+}
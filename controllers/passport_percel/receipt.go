@@ -0,0 +1,164 @@
+package passport_percel
+
+import (
+	"fmt"
+	"passport-booking/models/parcel_booking"
+	"passport-booking/services/branch_schedule"
+	"passport-booking/types"
+	parcel_booking_types "passport-booking/types/parcel_booking"
+	"passport-booking/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// receiptFor builds the customer receipt of a completed parcel booking
+func receiptFor(parcel *parcel_booking.ParcelBooking) parcel_booking_types.Receipt {
+	bookedAt := parcel.CreatedAt
+	if parcel.BookingDate != nil {
+		bookedAt = *parcel.BookingDate
+	}
+	operator := parcel.User.LegalName
+	if operator == "" {
+		operator = parcel.User.Username
+	}
+	trackingURL := utils.TrackingURL(parcel.Barcode)
+
+	return parcel_booking_types.Receipt{
+		ReceiptNo:   fmt.Sprintf("PB-%s-%06d", bookedAt.Format("20060102"), parcel.ID),
+		Barcode:     parcel.Barcode,
+		BookedAt:    bookedAt,
+		Operator:    operator,
+		RpoName:     parcel.RpoName,
+		RpoAddress:  parcel.RpoAddress,
+		PostCode:    parcel.PostCode,
+		Phone:       parcel.Phone,
+		ServiceType: parcel.ServiceType,
+		VasType:     parcel.VasType,
+		Insured:     parcel.Insured,
+		Price:       parcel.Price,
+		TotalCharge: parcel.TotalCharge,
+		TrackingURL: trackingURL,
+		TrackingInstruction: fmt.Sprintf("Track your parcel at %s or quote barcode %s at any post office. Keep this receipt until delivery.",
+			trackingURL, parcel.Barcode),
+	}
+}
+
+// receiptFields is the label/value body shared by the printed formats
+func receiptFields(r parcel_booking_types.Receipt) [][2]string {
+	insured := "No"
+	if r.Insured {
+		insured = "Yes"
+	}
+	fields := [][2]string{
+		{"Receipt No", r.ReceiptNo},
+		{"Date", r.BookedAt.In(branch_schedule.Location()).Format("02 Jan 2006 15:04")},
+		{"Operator", r.Operator},
+		{"RPO", r.RpoName},
+		{"Post Code", r.PostCode},
+		{"Phone", r.Phone},
+		{"Service", r.ServiceType},
+	}
+	if r.VasType != "" {
+		fields = append(fields, [2]string{"VAS", r.VasType})
+	}
+	return append(fields,
+		[2]string{"Insured", insured},
+		[2]string{"Price", fmt.Sprintf("Tk %.2f", r.Price)},
+	)
+}
+
+// receiptESCPOS lays the receipt out for an 80mm thermal counter printer
+func receiptESCPOS(r parcel_booking_types.Receipt) []byte {
+	p := utils.NewESCPOS().
+		Center(true).Bold(true).Line("Bangladesh Post Office").Bold(false).
+		Line("Passport Parcel Booking Receipt").
+		Center(false).Rule()
+	for _, f := range receiptFields(r) {
+		p.Pair(f[0], f[1])
+	}
+	p.Line("Deliver to: "+r.RpoAddress).
+		Rule().
+		Bold(true).Pair("Total", fmt.Sprintf("Tk %.2f", r.TotalCharge)).Bold(false).
+		Rule().
+		Center(true).Barcode(r.Barcode).
+		Center(false).Line(r.TrackingInstruction).
+		Line("").Line("").Cut()
+	return p.Bytes()
+}
+
+// receiptPDFLines lays the receipt out as the text of the PDF
+func receiptPDFLines(r parcel_booking_types.Receipt) []string {
+	lines := []string{
+		"Bangladesh Post Office",
+		"Passport Parcel Booking Receipt",
+		strings.Repeat("-", 60),
+	}
+	for _, f := range receiptFields(r) {
+		lines = append(lines, fmt.Sprintf("%-14s %s", f[0]+":", f[1]))
+	}
+	return append(lines,
+		fmt.Sprintf("%-14s %s", "Deliver to:", r.RpoAddress),
+		strings.Repeat("-", 60),
+		fmt.Sprintf("%-14s Tk %.2f", "Total:", r.TotalCharge),
+		strings.Repeat("-", 60),
+		fmt.Sprintf("%-14s %s", "Barcode:", r.Barcode),
+		"",
+		r.TrackingInstruction,
+	)
+}
+
+// Receipt returns the customer receipt of a completed counter booking as JSON, as
+// ESC/POS commands for the counter printer (?format=escpos) or as a PDF (?format=pdf)
+func (pbc *ParcelBookingController) Receipt(c *fiber.Ctx) error {
+	var req parcel_booking_types.ReceiptRequest
+	if err := c.QueryParser(&req); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return pbc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	parcel, err := pbc.findByBarcode(c, utils.NormalizeBarcode(c.Params("barcode")))
+	if parcel == nil {
+		return err
+	}
+
+	switch parcel_booking.ParcelBookingStatus(parcel.CurrentStatus) {
+	case parcel_booking.ParcelBookingStatusBooked, parcel_booking.ParcelBookingStatusReceived, parcel_booking.ParcelBookingStatusDelivered:
+	default:
+		return pbc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: fmt.Sprintf("Receipt is available once the booking is completed; it is %s", parcel.CurrentStatus),
+			Data:    nil,
+		})
+	}
+
+	receipt := receiptFor(parcel)
+	switch req.Format {
+	case "escpos":
+		c.Set(fiber.HeaderContentType, "application/octet-stream")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="receipt-%s.bin"`, parcel.Barcode))
+		pbc.logAPIRequest(c)
+		return c.Status(fiber.StatusOK).Send(receiptESCPOS(receipt))
+	case "pdf":
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="receipt-%s.pdf"`, parcel.Barcode))
+		pbc.logAPIRequest(c)
+		return c.Status(fiber.StatusOK).Send(utils.TextPDF("Receipt "+receipt.ReceiptNo, receiptPDFLines(receipt)))
+	}
+
+	return pbc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Receipt generated successfully",
+		Data:    receipt,
+	})
+}
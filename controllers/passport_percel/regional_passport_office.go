@@ -2,6 +2,7 @@ package passport_percel
 
 import (
 	"passport-booking/logger"
+	"passport-booking/middleware"
 	"passport-booking/models/regional_passport_office"
 	"passport-booking/types"
 	regional_passport_office_types "passport-booking/types/regional_passport_office"
@@ -53,38 +54,15 @@ func (rpo *RegionalPassportOfficeController) StoreRegionalPassportOffice(c *fibe
 		return rpo.sendResponseWithLog(c, fiber.StatusBadRequest, response)
 	}
 
-	claims, ok := c.Locals("user").(map[string]interface{})
+	current, ok := middleware.GetCurrentUser(c)
 	if !ok {
 		return rpo.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "Invalid user claims",
+			Message: "User not found",
 			Status:  fiber.StatusUnauthorized,
 			Data:    nil,
 		})
 	}
-
-	userUUID, ok := claims["uuid"].(string)
-	if !ok || userUUID == "" {
-		return rpo.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
-			Message: "User UUID not found in token",
-			Status:  fiber.StatusUnauthorized,
-			Data:    nil,
-		})
-	}
-
-	userInfo, err := utils.GetUserByUUID(userUUID)
-	if err != nil {
-		status := fiber.StatusInternalServerError
-		msg := "Database error"
-		if err.Error() == "user not found" {
-			status = fiber.StatusUnauthorized
-			msg = "User not found"
-		}
-		return rpo.sendResponseWithLog(c, status, types.ApiResponse{
-			Message: msg,
-			Status:  status,
-			Data:    nil,
-		})
-	}
+	userInfo := current.User
 
 	// Create a new regional passport office
 	office := regional_passport_office.RegionalPassportOffice{
@@ -0,0 +1,222 @@
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/booking_event"
+	privacyService "passport-booking/services/privacy"
+	"passport-booking/services/storage"
+	"passport-booking/types"
+	privacyTypes "passport-booking/types/privacy"
+	"passport-booking/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// PrivacyController handles applicant data erasure and legal holds
+type PrivacyController struct {
+	DB             *gorm.DB
+	Logger         *logger.AsyncLogger
+	Storage        storage.FileStorage
+	loggerInstance *logger.AsyncLogger
+}
+
+// NewPrivacyController creates a new privacy controller
+func NewPrivacyController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *PrivacyController {
+	return &PrivacyController{
+		DB:             db,
+		Logger:         asyncLogger,
+		Storage:        storage.NewLocalStorage(storage.DeliveryPhotoDir),
+		loggerInstance: asyncLogger,
+	}
+}
+
+// Helper function to log API requests and responses
+func (pc *PrivacyController) logAPIRequest(c *fiber.Ctx) {
+	logEntry := utils.CreateSanitizedLogEntry(c)
+	pc.loggerInstance.Log(logEntry)
+}
+
+// Helper function to send response and log in one call
+func (pc *PrivacyController) sendResponseWithLog(c *fiber.Ctx, status int, response types.ApiResponse) error {
+	result := c.Status(status).JSON(response)
+	pc.logAPIRequest(c)
+	return result
+}
+
+// currentUser resolves the authenticated admin, responding with an error when it cannot
+func (pc *PrivacyController) currentUser(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, pc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// Erase immediately anonymizes the applicant data of a booking (right to erasure)
+func (pc *PrivacyController) Erase(c *fiber.Ctx) error {
+	bookingID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return pc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid booking ID",
+			Data:    nil,
+		})
+	}
+
+	var req privacyTypes.EraseRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return pc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return pc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	admin, respErr := pc.currentUser(c)
+	if admin == nil {
+		return respErr
+	}
+
+	actorID := strconv.FormatUint(uint64(admin.ID), 10)
+	if err := privacyService.Anonymize(pc.DB.WithContext(c.UserContext()), pc.Storage, uint(bookingID), actorID); err != nil {
+		status := fiber.StatusInternalServerError
+		msg := "Failed to erase booking data"
+		switch {
+		case errors.Is(err, privacyService.ErrBookingNotFound):
+			status, msg = fiber.StatusNotFound, "Booking not found"
+		case errors.Is(err, privacyService.ErrLegalHold):
+			status, msg = fiber.StatusConflict, "Booking is under legal hold and cannot be erased"
+		case errors.Is(err, privacyService.ErrAlreadyAnonymized):
+			status, msg = fiber.StatusConflict, "Booking data has already been erased"
+		default:
+			logger.Error(fmt.Sprintf("Failed to erase booking %d", bookingID), err)
+		}
+		return pc.sendResponseWithLog(c, status, types.ApiResponse{
+			Status:  status,
+			Message: msg,
+			Data:    nil,
+		})
+	}
+
+	logger.Warning(fmt.Sprintf("Booking %d personal data erased by %s: %s", bookingID, admin.Username, req.Reason))
+
+	return pc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Booking personal data erased successfully",
+		Data: map[string]interface{}{
+			"booking_id": bookingID,
+		},
+	})
+}
+
+// SetLegalHold places or lifts a legal hold, which exempts a booking from erasure
+func (pc *PrivacyController) SetLegalHold(c *fiber.Ctx) error {
+	bookingID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return pc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid booking ID",
+			Data:    nil,
+		})
+	}
+
+	var req privacyTypes.LegalHoldRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return pc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return pc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	admin, respErr := pc.currentUser(c)
+	if admin == nil {
+		return respErr
+	}
+
+	var booking bookingModel.Booking
+	if err := pc.DB.First(&booking, bookingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return pc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to fetch booking", err)
+		return pc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Database error",
+			Data:    nil,
+		})
+	}
+
+	var reason *string
+	if *req.LegalHold {
+		reason = &req.Reason
+	}
+
+	actorID := strconv.FormatUint(uint64(admin.ID), 10)
+	if err := pc.DB.Model(&booking).Updates(map[string]interface{}{
+		"legal_hold":        *req.LegalHold,
+		"legal_hold_reason": reason,
+		"updated_by":        actorID,
+	}).Error; err != nil {
+		logger.Error("Failed to update legal hold", err)
+		return pc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to update legal hold",
+			Data:    nil,
+		})
+	}
+
+	booking.LegalHold = *req.LegalHold
+	booking.LegalHoldReason = reason
+
+	eventType := "legal_hold_released"
+	if *req.LegalHold {
+		eventType = "legal_hold_placed"
+	}
+	if err := booking_event.SnapshotBookingToEvent(pc.DB.WithContext(c.UserContext()), &booking, eventType, actorID); err != nil {
+		logger.Error(fmt.Sprintf("Failed to write booking event (%s)", eventType), err)
+	}
+
+	return pc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Legal hold updated successfully",
+		Data: map[string]interface{}{
+			"booking_id":        booking.ID,
+			"legal_hold":        booking.LegalHold,
+			"legal_hold_reason": booking.LegalHoldReason,
+		},
+	})
+}
@@ -0,0 +1,66 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/services/research_dataset"
+	"passport-booking/types"
+	reportTypes "passport-booking/types/report"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AnonymizedDataset returns delivery outcomes aggregated into k-anonymous cells with
+// no personal data, for sharing with the ministry's statistics division
+func (rc *ReportController) AnonymizedDataset(c *fiber.Ctx) error {
+	var req reportTypes.AnonymizedDatasetRequest
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Failed to parse query parameters", err)
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(research_dataset.MinK()); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	dataset, err := research_dataset.Build(rc.DB.WithContext(c.UserContext()), req.From, req.To, req.K)
+	if err != nil {
+		logger.Error("Failed to build anonymized dataset", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to build anonymized dataset",
+			Data:    nil,
+		})
+	}
+
+	if req.Format == reportTypes.FormatCSV {
+		var buf bytes.Buffer
+		if err := research_dataset.WriteCSV(&buf, dataset); err != nil {
+			logger.Error("Failed to write anonymized dataset CSV", err)
+			return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Failed to build anonymized dataset",
+				Data:    nil,
+			})
+		}
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="delivery-dataset-%s-%s-k%d.csv"`, dataset.From, dataset.To, dataset.K))
+		rc.logAPIRequest(c)
+		return c.Status(fiber.StatusOK).Send(buf.Bytes())
+	}
+
+	return rc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Anonymized dataset fetched successfully",
+		Data:    dataset,
+	})
+}
@@ -0,0 +1,184 @@
+package report
+
+import (
+	"math"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/types"
+	reportTypes "passport-booking/types/report"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Statuses in which a booking has reached its branch but is not yet delivered
+var inboundStatuses = []bookingModel.BookingStatus{
+	bookingModel.BookingStatusReceivedByPostman,
+	bookingModel.BookingStatusReceivedByPostMaster,
+}
+
+var backlogStatuses = []bookingModel.BookingStatus{
+	bookingModel.BookingStatusBooked,
+	bookingModel.BookingStatusReceivedByPostman,
+	bookingModel.BookingStatusReceivedByPostMaster,
+	bookingModel.BookingItemStatusReceivedByPostman,
+}
+
+// BranchCapacity compares each delivery branch's inbound volume with the number of
+// postmen working it and shows how old its open backlog is
+func (rc *ReportController) BranchCapacity(c *fiber.Ctx) error {
+	var req reportTypes.CapacityRequest
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Failed to parse query parameters", err)
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	since := time.Now().AddDate(0, 0, -req.Days)
+	branches := make(map[string]*reportTypes.BranchCapacity)
+	branchFor := func(code string) *reportTypes.BranchCapacity {
+		b, ok := branches[code]
+		if !ok {
+			b = &reportTypes.BranchCapacity{BranchCode: code}
+			branches[code] = b
+		}
+		return b
+	}
+
+	failed := func(err error) error {
+		logger.Error("Failed to build branch capacity report", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to build capacity report",
+			Data:    nil,
+		})
+	}
+
+	// Inbound volume and active postmen per branch
+	var volume []struct {
+		BranchCode string
+		Inbound    int64
+		Postmen    int64
+	}
+	volumeQuery := rc.DB.Table("booking_status_events AS e").
+		Joins("JOIN bookings AS b ON b.id = e.booking_id").
+		Select(`b.delivery_branch_code AS branch_code,
+			COUNT(DISTINCT e.booking_id) FILTER (WHERE e.status IN ?) AS inbound,
+			COUNT(DISTINCT e.created_by) FILTER (WHERE e.status = ?) AS postmen`,
+			inboundStatuses, bookingModel.BookingItemStatusReceivedByPostman).
+		Where("e.created_at >= ? AND b.delivery_branch_code IS NOT NULL", since)
+	if req.BranchCode != "" {
+		volumeQuery = volumeQuery.Where("b.delivery_branch_code = ?", req.BranchCode)
+	}
+	if err := volumeQuery.Group("b.delivery_branch_code").Scan(&volume).Error; err != nil {
+		return failed(err)
+	}
+
+	for _, v := range volume {
+		b := branchFor(v.BranchCode)
+		b.Inbound = v.Inbound
+		b.Postmen = v.Postmen
+		b.InboundPerDay = math.Round(float64(v.Inbound)/float64(req.Days)*100) / 100
+		if v.Postmen > 0 {
+			perPerson := math.Round(float64(v.Inbound)/float64(v.Postmen)*100) / 100
+			b.InboundPerPerson = &perPerson
+		}
+	}
+
+	// Open backlog by age of the last status change. Items on a delivery hold are left
+	// out and the clock of a finished hold restarts when the hold ends.
+	var backlog []struct {
+		BranchCode     string
+		Total          int64
+		UnderOneDay    int64
+		OneToThreeDays int64
+		ThreeToSeven   int64
+		OverSevenDays  int64
+		OldestHours    float64
+	}
+	backlogQuery := rc.DB.Table("(?) AS o", rc.DB.Table("bookings AS b").
+		Select(`b.delivery_branch_code AS branch_code,
+			EXTRACT(EPOCH FROM (NOW() - GREATEST(
+				COALESCE(
+					(SELECT MAX(e.created_at) FROM booking_status_events e WHERE e.booking_id = b.id),
+					b.updated_at),
+				(SELECT MAX(h.ends_at) FROM booking_delivery_holds h
+					WHERE h.booking_id = b.id AND h.status = 'active' AND h.ends_at <= NOW())))) / 3600 AS age_hours`).
+		Where("b.status IN ? AND b.deleted_at IS NULL AND b.delivery_branch_code IS NOT NULL", backlogStatuses).
+		Where(`NOT EXISTS (SELECT 1 FROM booking_delivery_holds h
+			WHERE h.booking_id = b.id AND h.status = 'active' AND h.starts_at <= NOW() AND h.ends_at > NOW())`).
+		Scopes(func(db *gorm.DB) *gorm.DB {
+			if req.BranchCode != "" {
+				return db.Where("b.delivery_branch_code = ?", req.BranchCode)
+			}
+			return db
+		})).
+		Select(`branch_code,
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE age_hours < 24) AS under_one_day,
+			COUNT(*) FILTER (WHERE age_hours >= 24 AND age_hours < 72) AS one_to_three_days,
+			COUNT(*) FILTER (WHERE age_hours >= 72 AND age_hours < 168) AS three_to_seven,
+			COUNT(*) FILTER (WHERE age_hours >= 168) AS over_seven_days,
+			MAX(age_hours) AS oldest_hours`).
+		Group("branch_code")
+	if err := backlogQuery.Scan(&backlog).Error; err != nil {
+		return failed(err)
+	}
+
+	for _, o := range backlog {
+		b := branchFor(o.BranchCode)
+		b.Backlog = o.Total
+		b.BacklogAge = reportTypes.BacklogAge{
+			UnderOneDay:    o.UnderOneDay,
+			OneToThreeDays: o.OneToThreeDays,
+			ThreeToSeven:   o.ThreeToSeven,
+			OverSevenDays:  o.OverSevenDays,
+		}
+		b.OldestBacklogHrs = math.Round(o.OldestHours*10) / 10
+	}
+
+	result := make([]reportTypes.BranchCapacity, 0, len(branches))
+	for _, b := range branches {
+		result = append(result, *b)
+	}
+	// Most loaded branches first: branches without postmen, then by inbound per postman
+	sort.Slice(result, func(i, j int) bool {
+		li, lj := load(result[i]), load(result[j])
+		if li != lj {
+			return li > lj
+		}
+		return result[i].BranchCode < result[j].BranchCode
+	})
+
+	return rc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Branch capacity fetched successfully",
+		Data: map[string]interface{}{
+			"days":     req.Days,
+			"branches": result,
+		},
+	})
+}
+
+func load(b reportTypes.BranchCapacity) float64 {
+	if b.InboundPerPerson == nil {
+		if b.Inbound == 0 && b.Backlog == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return *b.InboundPerPerson
+}
@@ -0,0 +1,202 @@
+package report
+
+import (
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	userModel "passport-booking/models/user"
+	"passport-booking/types"
+	reportTypes "passport-booking/types/report"
+	"passport-booking/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// custodianOf names who physically holds the item while a booking is in status
+func custodianOf(status bookingModel.BookingStatus) string {
+	switch status {
+	case bookingModel.BookingStatusReceivedByPostman, bookingModel.BookingStatusReceivedByPostMaster, bookingModel.BookingStatusReturn:
+		return "branch"
+	case bookingModel.BookingItemStatusReceivedByPostman:
+		return "postman"
+	case bookingModel.BookingStatusReturnInTransit:
+		return "in_transit"
+	case bookingModel.BookingStatusDelivered:
+		return "recipient"
+	default:
+		return "regional_passport_office"
+	}
+}
+
+// CustodyChain lists who held a booking's item when, derived from its status events.
+// ?format=pdf returns the same chain as a printable document for legal requests.
+func (rc *ReportController) CustodyChain(c *fiber.Ctx) error {
+	bookingID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil || bookingID == 0 {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid booking ID",
+			Data:    nil,
+		})
+	}
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "pdf" {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "format must be one of 'json' or 'pdf'",
+			Data:    nil,
+		})
+	}
+
+	var booking bookingModel.Booking
+	if err := rc.DB.First(&booking, bookingID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return rc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Booking not found",
+				Data:    nil,
+			})
+		}
+		return rc.custodyFailed(c, err)
+	}
+
+	var events []bookingModel.BookingStatusEvent
+	if err := rc.DB.Where("booking_id = ?", booking.ID).Order("created_at, id").Find(&events).Error; err != nil {
+		return rc.custodyFailed(c, err)
+	}
+
+	// Resolve numeric actor IDs to names in one query; "system" and the like stay as-is
+	var actorIDs []uint
+	for _, e := range events {
+		if id, err := strconv.ParseUint(e.CreatedBy, 10, 32); err == nil {
+			actorIDs = append(actorIDs, uint(id))
+		}
+	}
+	names := map[uint]string{}
+	if len(actorIDs) > 0 {
+		var users []userModel.User
+		if err := rc.DB.Select("id, legal_name").Where("id IN ?", actorIDs).Find(&users).Error; err != nil {
+			return rc.custodyFailed(c, err)
+		}
+		for _, u := range users {
+			names[u.ID] = u.LegalName
+		}
+	}
+
+	now := time.Now()
+	chain := reportTypes.CustodyChain{
+		BookingID:     booking.ID,
+		AppOrOrderID:  booking.AppOrOrderID,
+		Barcode:       booking.Barcode,
+		CurrentStatus: string(booking.Status),
+		GeneratedAt:   now,
+		Entries:       make([]reportTypes.CustodyEntry, 0, len(events)),
+	}
+	for i, e := range events {
+		entry := reportTypes.CustodyEntry{
+			Status:         string(e.Status),
+			Custodian:      custodianOf(e.Status),
+			RecordedByName: e.CreatedBy,
+			ImpersonatedBy: e.ImpersonatedBy,
+			From:           e.CreatedAt,
+		}
+		if entry.Custodian == "branch" {
+			entry.Location = booking.DeliveryBranchCode
+		}
+		if id, err := strconv.ParseUint(e.CreatedBy, 10, 32); err == nil {
+			uid := uint(id)
+			entry.RecordedByID = &uid
+			if name, ok := names[uid]; ok {
+				entry.RecordedByName = name
+			}
+		}
+
+		end := now
+		if i+1 < len(events) {
+			until := events[i+1].CreatedAt
+			entry.Until = &until
+			end = until
+		} else if e.Status == bookingModel.BookingStatusDelivered || e.Status == bookingModel.BookingStatusReturnedToRPO {
+			// Custody ends on hand-over; the last leg has no running duration
+			end = e.CreatedAt
+		}
+		entry.DurationSeconds = int64(end.Sub(e.CreatedAt).Seconds())
+		chain.Entries = append(chain.Entries, entry)
+	}
+
+	if format == "pdf" {
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="custody-%s.pdf"`, booking.AppOrOrderID))
+		rc.logAPIRequest(c)
+		return c.Status(fiber.StatusOK).Send(utils.TextPDF("Chain of custody "+booking.AppOrOrderID, custodyLines(chain)))
+	}
+
+	return rc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Custody chain generated successfully",
+		Data:    chain,
+	})
+}
+
+func (rc *ReportController) custodyFailed(c *fiber.Ctx, err error) error {
+	logger.Error("Failed to build custody chain", err)
+	return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed to build custody chain",
+		Data:    nil,
+	})
+}
+
+// custodyLines lays the chain out as the text of the PDF export
+func custodyLines(chain reportTypes.CustodyChain) []string {
+	const layout = "2006-01-02 15:04:05 MST"
+	barcode := "-"
+	if chain.Barcode != nil {
+		barcode = *chain.Barcode
+	}
+
+	lines := []string{
+		"CHAIN OF CUSTODY",
+		"",
+		fmt.Sprintf("Booking ID:     %d", chain.BookingID),
+		fmt.Sprintf("Application ID: %s", chain.AppOrOrderID),
+		fmt.Sprintf("Barcode:        %s", barcode),
+		fmt.Sprintf("Current status: %s", chain.CurrentStatus),
+		fmt.Sprintf("Generated at:   %s", chain.GeneratedAt.Format(layout)),
+		"",
+	}
+	for i, e := range chain.Entries {
+		custodian := e.Custodian
+		if e.Location != nil {
+			custodian += " " + *e.Location
+		}
+		until := "present"
+		if e.Until != nil {
+			until = e.Until.Format(layout)
+		}
+		recordedBy := e.RecordedByName
+		if e.RecordedByID != nil {
+			recordedBy = fmt.Sprintf("%s (user %d)", recordedBy, *e.RecordedByID)
+		}
+
+		lines = append(lines,
+			fmt.Sprintf("%d. %s - held by %s", i+1, e.Status, custodian),
+			fmt.Sprintf("   From:        %s", e.From.Format(layout)),
+			fmt.Sprintf("   Until:       %s", until),
+			fmt.Sprintf("   Duration:    %s", (time.Duration(e.DurationSeconds)*time.Second).String()),
+			fmt.Sprintf("   Recorded by: %s", recordedBy),
+		)
+		if e.ImpersonatedBy != nil {
+			lines = append(lines, fmt.Sprintf("   Impersonated by: %s", *e.ImpersonatedBy))
+		}
+		lines = append(lines, "")
+	}
+	if len(chain.Entries) == 0 {
+		lines = append(lines, "No status changes recorded.")
+	}
+	return lines
+}
@@ -0,0 +1,115 @@
+package report
+
+import (
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/types"
+	reportTypes "passport-booking/types/report"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DeliveryHeatmap returns delivered bookings aggregated into rounded coordinate cells
+// or delivery post codes as a GeoJSON FeatureCollection
+func (rc *ReportController) DeliveryHeatmap(c *fiber.Ctx) error {
+	var req reportTypes.HeatmapRequest
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Failed to parse query parameters", err)
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query := rc.DB.Table("bookings AS b").
+		Where("b.status = ? AND b.deleted_at IS NULL", bookingModel.BookingStatusDelivered).
+		Where("b.updated_at >= ? AND b.updated_at < ?", req.From, req.To)
+	if req.BranchCode != "" {
+		query = query.Where("b.delivery_branch_code = ?", req.BranchCode)
+	}
+
+	features := make([]reportTypes.GeoJSONFeature, 0)
+	if req.GroupBy == reportTypes.HeatmapGroupPostCode {
+		var rows []struct {
+			PostCode string
+			Total    int64
+		}
+		if err := query.Joins("JOIN addresses AS a ON a.id = b.delivery_address_id").
+			Select("a.post_office_code AS post_code, COUNT(*) AS total").
+			Where("a.post_office_code IS NOT NULL AND a.post_office_code <> ''").
+			Group("a.post_office_code").
+			Having("COUNT(*) >= ?", req.MinCount).
+			Order("total DESC").
+			Scan(&rows).Error; err != nil {
+			return rc.heatmapFailed(c, err)
+		}
+
+		for _, row := range rows {
+			features = append(features, reportTypes.GeoJSONFeature{
+				Type:       "Feature",
+				Properties: map[string]interface{}{"post_code": row.PostCode, "count": row.Total},
+			})
+		}
+	} else {
+		var rows []struct {
+			Latitude  float64
+			Longitude float64
+			Total     int64
+		}
+		// Coordinates are rounded in SQL so raw positions never leave the database
+		if err := query.
+			Select(fmt.Sprintf("ROUND(b.delivered_latitude::numeric, %d) AS latitude, ROUND(b.delivered_longitude::numeric, %d) AS longitude, COUNT(*) AS total", req.Precision, req.Precision)).
+			Where("b.delivered_latitude IS NOT NULL AND b.delivered_longitude IS NOT NULL").
+			Group("1, 2").
+			Having("COUNT(*) >= ?", req.MinCount).
+			Scan(&rows).Error; err != nil {
+			return rc.heatmapFailed(c, err)
+		}
+
+		for _, row := range rows {
+			features = append(features, reportTypes.GeoJSONFeature{
+				Type: "Feature",
+				Geometry: &reportTypes.GeoJSONPoint{
+					Type:        "Point",
+					Coordinates: [2]float64{row.Longitude, row.Latitude},
+				},
+				Properties: map[string]interface{}{"count": row.Total},
+			})
+		}
+	}
+
+	return rc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delivery heatmap fetched successfully",
+		Data: map[string]interface{}{
+			"group_by":  req.GroupBy,
+			"precision": req.Precision,
+			"min_count": req.MinCount,
+			"from_date": req.From.Format("2006-01-02"),
+			"to_date":   req.To.AddDate(0, 0, -1).Format("2006-01-02"),
+			"geojson": reportTypes.GeoJSONFeatureCollection{
+				Type:     "FeatureCollection",
+				Features: features,
+			},
+		},
+	})
+}
+
+func (rc *ReportController) heatmapFailed(c *fiber.Ctx, err error) error {
+	logger.Error("Failed to build delivery heatmap", err)
+	return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+		Status:  fiber.StatusInternalServerError,
+		Message: "Failed to build delivery heatmap",
+		Data:    nil,
+	})
+}
@@ -0,0 +1,212 @@
+package report
+
+import (
+	"errors"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	reportModel "passport-booking/models/report"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/postman_metrics"
+	"passport-booking/types"
+	reportTypes "passport-booking/types/report"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// metricRow is a postman metric joined with the postman's user record
+type metricRow struct {
+	reportModel.PostmanMetric
+	Username  string
+	LegalName string
+}
+
+func (r metricRow) entry(rank int) reportTypes.PostmanMetricEntry {
+	return reportTypes.PostmanMetricEntry{
+		Rank:             rank,
+		PostmanID:        r.PostmanID,
+		Username:         r.Username,
+		LegalName:        r.LegalName,
+		Period:           r.Period,
+		BranchCode:       r.BranchCode,
+		ItemsReceived:    r.ItemsReceived,
+		Delivered:        r.Delivered,
+		OnTimeRate:       r.OnTimeRate,
+		FirstAttemptRate: r.FirstAttemptRate,
+		AvgItemsPerDay:   r.AvgItemsPerDay,
+		Score:            r.Score,
+	}
+}
+
+func (rc *ReportController) metricsQuery() *gorm.DB {
+	return rc.DB.Table("postman_metrics AS m").
+		Select("m.*, u.username, u.legal_name").
+		Joins("LEFT JOIN users AS u ON u.id = m.postman_id")
+}
+
+// Leaderboard ranks postmen by their score for a month
+func (rc *ReportController) Leaderboard(c *fiber.Ctx) error {
+	var req reportTypes.LeaderboardRequest
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Failed to parse query parameters", err)
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query := rc.metricsQuery().Where("m.period = ?", req.Period)
+	if req.BranchCode != "" {
+		query = query.Where("m.branch_code = ?", req.BranchCode)
+	}
+
+	var rows []metricRow
+	if err := query.Order("m.score DESC, m.delivered DESC, m.postman_id ASC").Limit(req.Limit).Scan(&rows).Error; err != nil {
+		logger.Error("Failed to fetch postman leaderboard", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch leaderboard",
+			Data:    nil,
+		})
+	}
+
+	entries := make([]reportTypes.PostmanMetricEntry, 0, len(rows))
+	for i, row := range rows {
+		entries = append(entries, row.entry(i+1))
+	}
+
+	return rc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Leaderboard fetched successfully",
+		Data: map[string]interface{}{
+			"period":      req.Period,
+			"branch_code": req.BranchCode,
+			"entries":     entries,
+		},
+	})
+}
+
+// Scorecard returns one postman's metrics for a month along with the preceding months
+func (rc *ReportController) Scorecard(c *fiber.Ctx) error {
+	postmanID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid postman ID",
+			Data:    nil,
+		})
+	}
+	return rc.scorecard(c, uint(postmanID))
+}
+
+// MyScorecard returns the authenticated postman's own scorecard
+func (rc *ReportController) MyScorecard(c *fiber.Ctx) error {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return rc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User not found",
+			Data:    nil,
+		})
+	}
+	return rc.scorecard(c, current.ID)
+}
+
+func (rc *ReportController) scorecard(c *fiber.Ctx, postmanID uint) error {
+	var req reportTypes.ScorecardRequest
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Failed to parse query parameters", err)
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	start, _, _ := postman_metrics.PeriodBounds(req.Period)
+	oldest := start.AddDate(0, -(req.Months - 1), 0).Format(postman_metrics.PeriodLayout)
+
+	var rows []metricRow
+	if err := rc.metricsQuery().
+		Where("m.postman_id = ? AND m.period >= ? AND m.period <= ?", postmanID, oldest, req.Period).
+		Order("m.period DESC").
+		Scan(&rows).Error; err != nil {
+		logger.Error("Failed to fetch postman scorecard", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch scorecard",
+			Data:    nil,
+		})
+	}
+
+	response := reportTypes.ScorecardResponse{History: make([]reportTypes.PostmanMetricEntry, 0, len(rows))}
+	for _, row := range rows {
+		rank, err := rc.rankOf(row.PostmanMetric)
+		if err != nil {
+			logger.Error("Failed to rank postman metric", err)
+			return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+				Status:  fiber.StatusInternalServerError,
+				Message: "Failed to fetch scorecard",
+				Data:    nil,
+			})
+		}
+
+		entry := row.entry(rank)
+		if row.Period == req.Period {
+			response.Current = &entry
+		}
+		response.History = append(response.History, entry)
+	}
+
+	if len(rows) == 0 {
+		var postman userModel.User
+		if err := rc.DB.Select("id").First(&postman, postmanID).Error; err != nil {
+			status := fiber.StatusInternalServerError
+			msg := "Failed to fetch scorecard"
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				status = fiber.StatusNotFound
+				msg = "Postman not found"
+			}
+			return rc.sendResponseWithLog(c, status, types.ApiResponse{
+				Status:  status,
+				Message: msg,
+				Data:    nil,
+			})
+		}
+	}
+
+	return rc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Scorecard fetched successfully",
+		Data:    response,
+	})
+}
+
+// rankOf returns the leaderboard position of metric within its period
+func (rc *ReportController) rankOf(metric reportModel.PostmanMetric) (int, error) {
+	var ahead int64
+	err := rc.DB.Model(&reportModel.PostmanMetric{}).
+		Where("period = ?", metric.Period).
+		Where("score > ? OR (score = ? AND delivered > ?) OR (score = ? AND delivered = ? AND postman_id < ?)",
+			metric.Score, metric.Score, metric.Delivered, metric.Score, metric.Delivered, metric.PostmanID).
+		Count(&ahead).Error
+	return int(ahead) + 1, err
+}
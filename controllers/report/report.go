@@ -0,0 +1,185 @@
+package report
+
+import (
+	"database/sql"
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/localtime"
+	"passport-booking/types"
+	reportTypes "passport-booking/types/report"
+	"passport-booking/utils"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ReportController serves aggregated reporting endpoints
+type ReportController struct {
+	DB             *gorm.DB
+	Logger         *logger.AsyncLogger
+	loggerInstance *logger.AsyncLogger
+}
+
+// NewReportController creates a new report controller
+func NewReportController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *ReportController {
+	return &ReportController{
+		DB:             db,
+		Logger:         asyncLogger,
+		loggerInstance: asyncLogger,
+	}
+}
+
+// Helper function to log API requests and responses
+func (rc *ReportController) logAPIRequest(c *fiber.Ctx) {
+	logEntry := utils.CreateSanitizedLogEntry(c)
+	rc.loggerInstance.Log(logEntry)
+}
+
+// Helper function to send response and log in one call
+func (rc *ReportController) sendResponseWithLog(c *fiber.Ctx, status int, response types.ApiResponse) error {
+	result := c.Status(status).JSON(response)
+	rc.logAPIRequest(c)
+	return result
+}
+
+// seriesRow is one grouped row returned by the time-series queries
+type seriesRow struct {
+	Period     time.Time
+	BranchCode *string
+	Status     string
+	Total      int64
+}
+
+// TimeSeries returns created, booked, delivered and failed (returned) booking counts per period
+func (rc *ReportController) TimeSeries(c *fiber.Ctx) error {
+	var req reportTypes.TimeSeriesRequest
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Failed to parse query parameters", err)
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	// interval is whitelisted by Validate, so it is safe to inline. Periods follow
+	// calendar days in the user-facing zone rather than the UTC session zone.
+	period := fmt.Sprintf("date_trunc('%s', %%s AT TIME ZONE @zone) AT TIME ZONE @zone AS period", req.Interval)
+	zone := sql.Named("zone", localtime.Zone().String())
+	branch := "NULL::varchar AS branch_code"
+	groupBy := "period"
+	if req.GroupByBranch {
+		branch = "b.delivery_branch_code AS branch_code"
+		groupBy = "period, b.delivery_branch_code"
+	}
+
+	// Bookings created per period
+	var created []seriesRow
+	createdQuery := rc.DB.Table("bookings AS b").
+		Select(fmt.Sprintf(period, "b.created_at")+", "+branch+", 'created' AS status, COUNT(*) AS total", zone).
+		Where("b.created_at >= ? AND b.created_at < ?", req.From, req.To).
+		Where("b.deleted_at IS NULL")
+	if req.BranchCode != "" {
+		createdQuery = createdQuery.Where("b.delivery_branch_code = ?", req.BranchCode)
+	}
+	if err := createdQuery.Group(groupBy).Scan(&created).Error; err != nil {
+		logger.Error("Failed to aggregate created bookings", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to build time series",
+			Data:    nil,
+		})
+	}
+
+	// Status transitions per period, taken from the status event history
+	var transitions []seriesRow
+	transitionQuery := rc.DB.Table("booking_status_events AS e").
+		Joins("JOIN bookings AS b ON b.id = e.booking_id").
+		Select(fmt.Sprintf(period, "e.created_at")+", "+branch+", e.status AS status, COUNT(DISTINCT e.booking_id) AS total", zone).
+		Where("e.created_at >= ? AND e.created_at < ?", req.From, req.To).
+		Where("e.status IN ?", []bookingModel.BookingStatus{
+			bookingModel.BookingStatusBooked,
+			bookingModel.BookingStatusDelivered,
+			bookingModel.BookingStatusReturn,
+		})
+	if req.BranchCode != "" {
+		transitionQuery = transitionQuery.Where("b.delivery_branch_code = ?", req.BranchCode)
+	}
+	if err := transitionQuery.Group(groupBy + ", e.status").Scan(&transitions).Error; err != nil {
+		logger.Error("Failed to aggregate booking status events", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to build time series",
+			Data:    nil,
+		})
+	}
+
+	return rc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Time series fetched successfully",
+		Data: reportTypes.TimeSeriesResponse{
+			Interval: req.Interval,
+			From:     req.From.Format("2006-01-02"),
+			To:       req.To.AddDate(0, 0, -1).Format("2006-01-02"),
+			Points:   mergeSeries(append(created, transitions...)),
+		},
+	})
+}
+
+// mergeSeries folds the per-status rows into one point per period and branch
+func mergeSeries(rows []seriesRow) []reportTypes.TimeSeriesPoint {
+	points := make(map[string]*reportTypes.TimeSeriesPoint)
+	for _, row := range rows {
+		key := row.Period.Format(time.RFC3339)
+		if row.BranchCode != nil {
+			key += "|" + *row.BranchCode
+		}
+
+		point, ok := points[key]
+		if !ok {
+			point = &reportTypes.TimeSeriesPoint{Period: row.Period, BranchCode: row.BranchCode}
+			points[key] = point
+		}
+
+		switch row.Status {
+		case "created":
+			point.Created += row.Total
+		case string(bookingModel.BookingStatusBooked):
+			point.Booked += row.Total
+		case string(bookingModel.BookingStatusDelivered):
+			point.Delivered += row.Total
+		case string(bookingModel.BookingStatusReturn):
+			point.Failed += row.Total
+		}
+	}
+
+	result := make([]reportTypes.TimeSeriesPoint, 0, len(points))
+	for _, point := range points {
+		result = append(result, *point)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Period.Equal(result[j].Period) {
+			return result[i].Period.Before(result[j].Period)
+		}
+		return branchKey(result[i].BranchCode) < branchKey(result[j].BranchCode)
+	})
+	return result
+}
+
+func branchKey(code *string) string {
+	if code == nil {
+		return ""
+	}
+	return *code
+}
@@ -0,0 +1,198 @@
+package report
+
+import (
+	"math"
+	"passport-booking/constants"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/branch_hierarchy"
+	"passport-booking/services/user_admin"
+	"passport-booking/types"
+	reportTypes "passport-booking/types/report"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Roles that see every branch in rollups; anyone else sees the branches they are
+// mapped to
+var nationwidePermissions = []string{
+	constants.PermSuperAdminFull,
+	constants.PermPassportDPMGFull,
+	constants.PermEkdakDPMGFull,
+	constants.PermOrgSupervisorFull,
+	constants.PermViewerReadOnly,
+}
+
+// branchKPIs are the raw counts of one delivery branch
+type branchKPIs struct {
+	BranchCode        string
+	Booked            int64
+	Delivered         int64
+	Returned          int64
+	Backlog           int64
+	DeliveryHoursSum  float64
+	DeliveryHoursRows int64
+}
+
+// Rollup sums delivery KPIs from branches up to districts or regions of the branch
+// hierarchy. Branches missing from the hierarchy are reported under "unassigned".
+func (rc *ReportController) Rollup(c *fiber.Ctx) error {
+	var req reportTypes.RollupRequest
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Failed to parse query parameters", err)
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	failed := func(err error) error {
+		logger.Error("Failed to build rollup report", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to build rollup report",
+			Data:    nil,
+		})
+	}
+
+	tree, err := branch_hierarchy.Load(rc.DB)
+	if err != nil {
+		return failed(err)
+	}
+
+	// Branch restriction from ?root= and from the caller's own mappings
+	var scope []string
+	if req.Root != "" {
+		if _, ok := tree.Find(req.Root); !ok {
+			return rc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Unknown root unit",
+				Data:    nil,
+			})
+		}
+		scope = tree.BranchCodes([]string{req.Root})
+	}
+	if !hasAny(middleware.GetUserPermissions(c), nationwidePermissions) {
+		own, err := user_admin.BranchScope(rc.DB, middleware.CurrentUserID(c))
+		if err != nil {
+			return failed(err)
+		}
+		scope = intersect(scope, own, req.Root != "")
+		if len(scope) == 0 {
+			scope = []string{""} // mapped to nothing: match no branch
+		}
+	}
+
+	var rows []branchKPIs
+	query := rc.DB.Table("bookings AS b").
+		Select(`b.delivery_branch_code AS branch_code,
+			COUNT(*) FILTER (WHERE b.created_at >= ? AND b.created_at < ?) AS booked,
+			COUNT(*) FILTER (WHERE d.created_at IS NOT NULL) AS delivered,
+			COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM booking_status_events r
+				WHERE r.booking_id = b.id AND r.status = ? AND r.created_at >= ? AND r.created_at < ?)) AS returned,
+			COUNT(*) FILTER (WHERE b.status IN ?) AS backlog,
+			COALESCE(SUM(EXTRACT(EPOCH FROM (d.created_at - b.created_at)) / 3600), 0) AS delivery_hours_sum,
+			COUNT(d.created_at) AS delivery_hours_rows`,
+			req.From, req.To, bookingModel.BookingStatusReturn, req.From, req.To, backlogStatuses).
+		Joins(`LEFT JOIN LATERAL (SELECT MIN(e.created_at) AS created_at FROM booking_status_events e
+			WHERE e.booking_id = b.id AND e.status = ? AND e.created_at >= ? AND e.created_at < ?) d ON TRUE`,
+			bookingModel.BookingStatusDelivered, req.From, req.To).
+		Where("b.deleted_at IS NULL AND b.delivery_branch_code IS NOT NULL")
+	if scope != nil {
+		query = query.Where("b.delivery_branch_code IN ?", scope)
+	}
+	if err := query.Group("b.delivery_branch_code").Scan(&rows).Error; err != nil {
+		return failed(err)
+	}
+
+	type unitTotals struct {
+		entry      reportTypes.RollupEntry
+		hoursSum   float64
+		hoursCount int64
+	}
+	units := make(map[string]*unitTotals)
+	for _, row := range rows {
+		code, name := "unassigned", "Not in the branch hierarchy"
+		if n, ok := tree.AncestorAt(row.BranchCode, req.Level); ok {
+			code, name = n.Code, n.Name
+		} else if req.Level == bookingModel.OrgLevelBranch {
+			code, name = row.BranchCode, row.BranchCode
+		}
+		u, ok := units[code]
+		if !ok {
+			u = &unitTotals{entry: reportTypes.RollupEntry{Code: code, Name: name, Level: req.Level}}
+			units[code] = u
+		}
+		u.entry.Branches++
+		u.entry.Booked += row.Booked
+		u.entry.Delivered += row.Delivered
+		u.entry.Returned += row.Returned
+		u.entry.Backlog += row.Backlog
+		u.hoursSum += row.DeliveryHoursSum
+		u.hoursCount += row.DeliveryHoursRows
+	}
+
+	result := make([]reportTypes.RollupEntry, 0, len(units))
+	for _, u := range units {
+		if finished := u.entry.Delivered + u.entry.Returned; finished > 0 {
+			rate := math.Round(float64(u.entry.Delivered)/float64(finished)*1000) / 1000
+			u.entry.DeliveryRate = &rate
+		}
+		if u.hoursCount > 0 {
+			days := math.Round(u.hoursSum/float64(u.hoursCount)/24*10) / 10
+			u.entry.AvgDeliveryDays = &days
+		}
+		result = append(result, u.entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Code < result[j].Code })
+
+	return rc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Rollup fetched successfully",
+		Data: fiber.Map{
+			"level":     req.Level,
+			"root":      req.Root,
+			"from_date": req.From.Format("2006-01-02"),
+			"to_date":   req.To.AddDate(0, 0, -1).Format("2006-01-02"),
+			"units":     result,
+		},
+	})
+}
+
+func hasAny(held map[string]bool, permissions []string) bool {
+	for _, p := range permissions {
+		if held[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// intersect narrows scope to own; without a prior scope (restricted false) own is
+// the scope
+func intersect(scope, own []string, restricted bool) []string {
+	if !restricted {
+		return own
+	}
+	allowed := make(map[string]bool, len(own))
+	for _, code := range own {
+		allowed[code] = true
+	}
+	var out []string
+	for _, code := range scope {
+		if allowed[code] {
+			out = append(out, code)
+		}
+	}
+	return out
+}
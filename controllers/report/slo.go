@@ -0,0 +1,50 @@
+package report
+
+import (
+	"passport-booking/logger"
+	"passport-booking/services/slo"
+	"passport-booking/types"
+	reportTypes "passport-booking/types/report"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SLOReport returns the availability and latency objectives of the booking and
+// delivery endpoints for a month, with how much of each error budget was spent
+func (rc *ReportController) SLOReport(c *fiber.Ctx) error {
+	var req reportTypes.SLORequest
+	if err := c.QueryParser(&req); err != nil {
+		logger.Error("Failed to parse query parameters", err)
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	now := time.Now()
+	if err := req.Validate(now); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	report, err := slo.Report(rc.DB.WithContext(c.UserContext()), req.Start, now)
+	if err != nil {
+		logger.Error("Failed to build SLO report", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to build SLO report",
+			Data:    nil,
+		})
+	}
+
+	return rc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "SLO report fetched successfully",
+		Data:    report,
+	})
+}
@@ -0,0 +1,318 @@
+package returns
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	bookingModel "passport-booking/models/booking"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/services/return_manifest"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	returnTypes "passport-booking/types/returns"
+	"passport-booking/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ReturnController handles bagging undelivered passports back to regional passport offices
+type ReturnController struct {
+	DB             *gorm.DB
+	Logger         *logger.AsyncLogger
+	loggerInstance *logger.AsyncLogger
+}
+
+// NewReturnController creates a new return controller
+func NewReturnController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *ReturnController {
+	return &ReturnController{
+		DB:             db,
+		Logger:         asyncLogger,
+		loggerInstance: asyncLogger,
+	}
+}
+
+// Helper function to log API requests and responses
+func (rc *ReturnController) logAPIRequest(c *fiber.Ctx) {
+	logEntry := utils.CreateSanitizedLogEntry(c)
+	rc.loggerInstance.Log(logEntry)
+}
+
+// Helper function to send response and log in one call
+func (rc *ReturnController) sendResponseWithLog(c *fiber.Ctx, status int, response types.ApiResponse) error {
+	result := c.Status(status).JSON(response)
+	rc.logAPIRequest(c)
+	return result
+}
+
+// currentUser resolves the authenticated user, responding with an error when it cannot
+func (rc *ReturnController) currentUser(c *fiber.Ctx) (*userModel.User, error) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, rc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Message: "User not found",
+			Status:  fiber.StatusUnauthorized,
+			Data:    nil,
+		})
+	}
+	return current.User, nil
+}
+
+// manifestID parses the :id route param, responding with 400 when it is invalid
+func (rc *ReturnController) manifestID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil || id == 0 {
+		return 0, rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid manifest ID",
+			Data:    nil,
+		})
+	}
+	return uint(id), nil
+}
+
+// manifestErrorResponse maps return_manifest errors to responses
+func (rc *ReturnController) manifestErrorResponse(c *fiber.Ctx, err error, action string) error {
+	var validationErr *return_manifest.ValidationError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return rc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Return manifest not found",
+			Data:    nil,
+		})
+	case errors.Is(err, return_manifest.ErrRPONotFound):
+		return rc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	case errors.As(err, &validationErr):
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: validationErr.Error(),
+			Data:    nil,
+		})
+	case errors.Is(err, return_manifest.ErrWrongStatus):
+		return rc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	logger.Error(fmt.Sprintf("Failed to %s return manifest", action), err)
+	return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+		Status:  fiber.StatusInternalServerError,
+		Message: fmt.Sprintf("Failed to %s return manifest", action),
+		Data:    nil,
+	})
+}
+
+// respondWithManifest reloads the manifest and sends it
+func (rc *ReturnController) respondWithManifest(c *fiber.Ctx, status int, message string, manifestID uint) error {
+	manifest, err := return_manifest.Find(rc.DB, manifestID)
+	if err != nil {
+		return rc.manifestErrorResponse(c, err, "load")
+	}
+	return rc.sendResponseWithLog(c, status, types.ApiResponse{
+		Status:  status,
+		Message: message,
+		Data:    returnTypes.NewManifestResponse(manifest),
+	})
+}
+
+// CreateManifest bags returned bookings for a regional passport office
+func (rc *ReturnController) CreateManifest(c *fiber.Ctx) error {
+	var req returnTypes.CreateManifestRequest
+	if err := c.BodyParser(&req); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := rc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	var bookings []bookingModel.Booking
+	if err := booking_resolver.FindMany(rc.DB.Order("id"), req.BookingIDs, req.IdentifierType, &bookings); err != nil {
+		logger.Error("Failed to find bookings for return manifest", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Internal server error",
+			Data:    nil,
+		})
+	}
+	if len(bookings) != len(req.BookingIDs) {
+		return rc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "One or more bookings not found",
+			Data:    nil,
+		})
+	}
+
+	manifest, err := return_manifest.Create(rc.DB.WithContext(c.UserContext()), req.RPOID, bookings, userInfo.ID)
+	if err != nil {
+		return rc.manifestErrorResponse(c, err, "create")
+	}
+
+	logger.Success(fmt.Sprintf("Return manifest %s created with %d bookings by %s", manifest.ManifestNo, len(bookings), userInfo.LegalName))
+
+	return rc.respondWithManifest(c, fiber.StatusCreated, "Return manifest created successfully", manifest.ID)
+}
+
+// Dispatch marks an open manifest as sent to the regional passport office
+func (rc *ReturnController) Dispatch(c *fiber.Ctx) error {
+	manifestID, err := rc.manifestID(c)
+	if manifestID == 0 {
+		return err
+	}
+
+	userInfo, err := rc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	if err := return_manifest.Dispatch(rc.DB.WithContext(c.UserContext()), manifestID, userInfo.ID); err != nil {
+		return rc.manifestErrorResponse(c, err, "dispatch")
+	}
+
+	return rc.respondWithManifest(c, fiber.StatusOK, "Return manifest dispatched successfully", manifestID)
+}
+
+// Acknowledge records the regional passport office's receipt of a dispatched manifest
+func (rc *ReturnController) Acknowledge(c *fiber.Ctx) error {
+	manifestID, err := rc.manifestID(c)
+	if manifestID == 0 {
+		return err
+	}
+
+	var req returnTypes.AcknowledgeRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid request body",
+				Data:    nil,
+			})
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	userInfo, err := rc.currentUser(c)
+	if userInfo == nil {
+		return err
+	}
+
+	var note *string
+	if req.Note != "" {
+		note = &req.Note
+	}
+	if err := return_manifest.Acknowledge(rc.DB.WithContext(c.UserContext()), manifestID, req.ReceivedBookingIDs, note, userInfo.ID); err != nil {
+		return rc.manifestErrorResponse(c, err, "acknowledge")
+	}
+
+	return rc.respondWithManifest(c, fiber.StatusOK, "Return manifest acknowledged successfully", manifestID)
+}
+
+// Show returns a manifest with its items, for printing and checking the bag
+func (rc *ReturnController) Show(c *fiber.Ctx) error {
+	manifestID, err := rc.manifestID(c)
+	if manifestID == 0 {
+		return err
+	}
+
+	return rc.respondWithManifest(c, fiber.StatusOK, "Return manifest retrieved successfully", manifestID)
+}
+
+// Index lists return manifests, newest first
+func (rc *ReturnController) Index(c *fiber.Ctx) error {
+	var req returnTypes.ManifestListRequest
+	if err := c.QueryParser(&req); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return rc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query := rc.DB.Model(&bookingModel.ReturnManifest{})
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.RPOID != 0 {
+		query = query.Where("rpo_id = ?", req.RPOID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.Error("Failed to count return manifests", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch return manifests",
+			Data:    nil,
+		})
+	}
+
+	var manifests []bookingModel.ReturnManifest
+	if err := query.Preload("Items").Order("created_at DESC").Offset((req.Page - 1) * req.PerPage).Limit(req.PerPage).Find(&manifests).Error; err != nil {
+		logger.Error("Failed to fetch return manifests", err)
+		return rc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch return manifests",
+			Data:    nil,
+		})
+	}
+
+	data := make([]returnTypes.ManifestResponse, 0, len(manifests))
+	for i := range manifests {
+		data = append(data, returnTypes.NewManifestResponse(&manifests[i]))
+	}
+
+	totalPages := int((total + int64(req.PerPage) - 1) / int64(req.PerPage))
+	return rc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Return manifests fetched successfully",
+		Data: bookingTypes.BookingIndexResponse{
+			Data: data,
+			Pagination: bookingTypes.PaginationResponse{
+				CurrentPage: req.Page,
+				PerPage:     req.PerPage,
+				Total:       total,
+				TotalPages:  totalPages,
+				HasNext:     req.Page < totalPages,
+				HasPrev:     req.Page > 1,
+			},
+		},
+	})
+}
@@ -0,0 +1,61 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	"passport-booking/services/chaos"
+	"passport-booking/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Chaos returns the fault injection settings in effect
+func (sc *SystemController) Chaos(c *fiber.Ctx) error {
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fault injection settings fetched successfully",
+		Data: fiber.Map{
+			"allowed":  chaos.Allowed(),
+			"settings": chaos.Current(),
+		},
+	})
+}
+
+// UpdateChaos changes the fault injection settings until the next restart. Fields
+// left out of the body keep their current value.
+func (sc *SystemController) UpdateChaos(c *fiber.Ctx) error {
+	settings := chaos.Current()
+	if err := c.BodyParser(&settings); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return sc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+
+	if err := chaos.Update(settings); err != nil {
+		status := fiber.StatusBadRequest
+		if errors.Is(err, chaos.ErrNotAllowed) {
+			status = fiber.StatusForbidden
+		}
+		return sc.sendResponseWithLog(c, status, types.ApiResponse{
+			Status:  status,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	logger.Warning(fmt.Sprintf("Fault injection settings changed by %s: enabled %v, latency %.1f%%, DMS errors %.1f%%, DB timeouts %.1f%%",
+		middleware.CurrentUserUUID(c), settings.Enabled, settings.LatencyPercent, settings.DMSErrorPercent, settings.DBTimeoutPercent))
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Fault injection settings updated successfully",
+		Data: fiber.Map{
+			"allowed":  chaos.Allowed(),
+			"settings": chaos.Current(),
+		},
+	})
+}
@@ -0,0 +1,210 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	"passport-booking/services/dead_letter"
+	"passport-booking/types"
+	systemTypes "passport-booking/types/system"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// deadLetterQueue reads the :queue route parameter, responding when it is unknown
+func (sc *SystemController) deadLetterQueue(c *fiber.Ctx) (dead_letter.Queue, bool) {
+	queue := dead_letter.Queue(c.Params("queue"))
+	if !queue.IsValid() {
+		sc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Unknown dead-letter queue",
+			Data:    dead_letter.Queues(),
+		})
+		return "", false
+	}
+	return queue, true
+}
+
+// deadLetterError responds to a failed dead-letter operation
+func (sc *SystemController) deadLetterError(c *fiber.Ctx, err error, action string) error {
+	status, message := fiber.StatusInternalServerError, "Failed to "+action+" item"
+	var invalid *dead_letter.InvalidEditError
+	switch {
+	case errors.Is(err, dead_letter.ErrNotFound):
+		status, message = fiber.StatusNotFound, "Item not found"
+	case errors.Is(err, dead_letter.ErrNotDeadLettered):
+		status, message = fiber.StatusConflict, "Item is not dead-lettered; it was already replayed, discarded or never failed"
+	case errors.Is(err, dead_letter.ErrEditNotSupported), errors.Is(err, dead_letter.ErrReasonRequired), errors.As(err, &invalid):
+		status, message = fiber.StatusBadRequest, err.Error()
+	default:
+		logger.Error(fmt.Sprintf("Failed to %s dead-lettered item", action), err)
+	}
+	return sc.sendResponseWithLog(c, status, types.ApiResponse{
+		Status:  status,
+		Message: message,
+		Data:    nil,
+	})
+}
+
+// DeadLetterCounts returns how many items are dead-lettered in each queue
+func (sc *SystemController) DeadLetterCounts(c *fiber.Ctx) error {
+	counts, err := dead_letter.Counts(sc.DB.WithContext(c.UserContext()))
+	if err != nil {
+		logger.Error("Failed to count dead-lettered items", err)
+		return sc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to count dead-lettered items",
+			Data:    nil,
+		})
+	}
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Dead-letter counts fetched successfully",
+		Data:    counts,
+	})
+}
+
+// ListDeadLetters returns the dead-lettered items of a queue, newest first; pass
+// before_id the last ID of a page to get the next one
+func (sc *SystemController) ListDeadLetters(c *fiber.Ctx) error {
+	queue, ok := sc.deadLetterQueue(c)
+	if !ok {
+		return nil
+	}
+
+	items, err := dead_letter.List(sc.DB.WithContext(c.UserContext()), queue, uint(c.QueryInt("before_id", 0)), c.QueryInt("limit", 0))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to list dead-lettered %s items", queue), err)
+		return sc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch dead-lettered items",
+			Data:    nil,
+		})
+	}
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Dead-lettered items fetched successfully",
+		Data:    items,
+	})
+}
+
+// ShowDeadLetter returns an item with its payload, error history and the replays
+// and discards done on it
+func (sc *SystemController) ShowDeadLetter(c *fiber.Ctx) error {
+	queue, ok := sc.deadLetterQueue(c)
+	if !ok {
+		return nil
+	}
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+
+	detail, err := dead_letter.Get(sc.DB.WithContext(c.UserContext()), queue, uint(id))
+	if err != nil {
+		return sc.deadLetterError(c, err, "fetch")
+	}
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Dead-lettered item fetched successfully",
+		Data:    detail,
+	})
+}
+
+// ReplayDeadLetter sends an item again, after replacing its payload or recipient
+// when the request carries one
+func (sc *SystemController) ReplayDeadLetter(c *fiber.Ctx) error {
+	queue, ok := sc.deadLetterQueue(c)
+	if !ok {
+		return nil
+	}
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+
+	var req systemTypes.ReplayDeadLetterRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("Failed to parse request body", err)
+			return sc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+				Status:  fiber.StatusBadRequest,
+				Message: "Invalid request body",
+				Data:    nil,
+			})
+		}
+	}
+	if err := req.Validate(); err != nil {
+		return sc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return sc.sendResponseWithLog(c, fiber.StatusUnauthorized, types.ApiResponse{
+			Status:  fiber.StatusUnauthorized,
+			Message: "User not found",
+			Data:    nil,
+		})
+	}
+	actor := dead_letter.Actor{UUID: current.UUID, UserID: current.ID}
+
+	edit := dead_letter.Edit{Payload: req.Payload, Recipient: req.Recipient}
+	result, err := dead_letter.Replay(sc.DB.WithContext(c.UserContext()), queue, uint(id), edit, req.Reason, actor)
+	if result == nil {
+		return sc.deadLetterError(c, err, "replay")
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Replay of dead-lettered %s item %d", queue, id), err)
+	}
+
+	logger.Info(fmt.Sprintf("Dead-lettered %s item %d replayed by %s (edited: %t)", queue, id, actor.UUID, edit.Payload != nil || edit.Recipient != ""))
+	message := "Item replayed"
+	if queue == dead_letter.QueueOutbox {
+		message = "Item queued for the next outbox drain"
+	}
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Data:    result,
+	})
+}
+
+// DiscardDeadLetter drops an item for good with the reason given
+func (sc *SystemController) DiscardDeadLetter(c *fiber.Ctx) error {
+	queue, ok := sc.deadLetterQueue(c)
+	if !ok {
+		return nil
+	}
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+
+	var req systemTypes.DiscardDeadLetterRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return sc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return sc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	actor := dead_letter.Actor{UUID: middleware.CurrentUserUUID(c), UserID: middleware.CurrentUserID(c)}
+
+	action, err := dead_letter.Discard(sc.DB.WithContext(c.UserContext()), queue, uint(id), req.Reason, actor)
+	if err != nil {
+		return sc.deadLetterError(c, err, "discard")
+	}
+
+	logger.Info(fmt.Sprintf("Dead-lettered %s item %d discarded by %s: %s", queue, id, actor.UUID, req.Reason))
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Item discarded",
+		Data:    action,
+	})
+}
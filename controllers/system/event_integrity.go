@@ -0,0 +1,48 @@
+package system
+
+import (
+	"errors"
+	"passport-booking/logger"
+	"passport-booking/services/booking_event"
+	"passport-booking/types"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EventIntegrity replays a booking's events, expanding compacted diffs, and
+// reports whether the result matches the booking as stored
+func (sc *SystemController) EventIntegrity(c *fiber.Ctx) error {
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+
+	issue, err := booking_event.CheckBooking(sc.DB.WithContext(c.UserContext()), uint(id))
+	if errors.Is(err, booking_event.ErrBookingNotFound) {
+		return sc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Booking not found",
+			Data:    nil,
+		})
+	}
+	if err != nil {
+		logger.Error("Failed to check booking event integrity", err)
+		return sc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to check event integrity",
+			Data:    nil,
+		})
+	}
+
+	message := "Booking replays from its events"
+	if issue != nil {
+		message = "Booking does not replay from its events"
+	}
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Data: fiber.Map{
+			"booking_id": id,
+			"consistent": issue == nil,
+			"issue":      issue,
+		},
+	})
+}
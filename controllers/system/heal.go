@@ -0,0 +1,71 @@
+package system
+
+import (
+	"fmt"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	"passport-booking/services/auto_heal"
+	"passport-booking/types"
+	systemTypes "passport-booking/types/system"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Heal runs an auto-heal command from the runbook: recompute-status,
+// regenerate-status-events or relink-bags. Without apply it is a dry run.
+func (sc *SystemController) Heal(c *fiber.Ctx) error {
+	command := auto_heal.Command(c.Params("command"))
+	if !command.IsValid() {
+		return sc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "Unknown heal command",
+			Data:    nil,
+		})
+	}
+
+	var req systemTypes.HealRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return sc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return sc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	actor := middleware.CurrentUserUUID(c)
+	result, err := auto_heal.Run(sc.DB.WithContext(c.UserContext()), command, auto_heal.Options{
+		BookingIDs: req.BookingIDs,
+		All:        req.All,
+		Apply:      req.Apply,
+		Force:      req.Force,
+		Limit:      req.Limit,
+		Actor:      actor,
+	})
+	if err != nil {
+		logger.Error(fmt.Sprintf("Auto-heal %s failed", command), err)
+		return sc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Auto-heal failed",
+			Data:    result,
+		})
+	}
+
+	message := fmt.Sprintf("Dry run found %d fixes", len(result.Changes))
+	if req.Apply {
+		message = fmt.Sprintf("Auto-heal %s processed %d fixes", command, len(result.Changes))
+		logger.Info(fmt.Sprintf("Auto-heal %s by %s processed %d fixes", command, actor, len(result.Changes)))
+	}
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Data:    result,
+	})
+}
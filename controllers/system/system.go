@@ -0,0 +1,163 @@
+package system
+
+import (
+	"errors"
+	"passport-booking/database"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	barcodeModel "passport-booking/models/barcode"
+	"passport-booking/services/barcode_guard"
+	"passport-booking/services/query_log"
+	"passport-booking/services/workerpool"
+	"passport-booking/types"
+	"passport-booking/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SystemController exposes the runtime state of the service to administrators
+type SystemController struct {
+	DB             *gorm.DB
+	Logger         *logger.AsyncLogger
+	loggerInstance *logger.AsyncLogger
+}
+
+// NewSystemController creates a new system controller
+func NewSystemController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *SystemController {
+	return &SystemController{
+		DB:             db,
+		Logger:         asyncLogger,
+		loggerInstance: asyncLogger,
+	}
+}
+
+// Helper function to log API requests and responses
+func (sc *SystemController) logAPIRequest(c *fiber.Ctx) {
+	logEntry := utils.CreateSanitizedLogEntry(c)
+	sc.loggerInstance.Log(logEntry)
+}
+
+// Helper function to send response and log in one call
+func (sc *SystemController) sendResponseWithLog(c *fiber.Ctx, status int, response types.ApiResponse) error {
+	result := c.Status(status).JSON(response)
+	sc.logAPIRequest(c)
+	return result
+}
+
+// WorkerPool returns the background worker pool counters per queue
+func (sc *SystemController) WorkerPool(c *fiber.Ctx) error {
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Worker pool stats fetched successfully",
+		Data:    workerpool.Default().Stats(),
+	})
+}
+
+// SlowQueries returns the slow query counters and the latest slow statements
+func (sc *SystemController) SlowQueries(c *fiber.Ctx) error {
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Slow query stats fetched successfully",
+		Data:    query_log.Default().Stats(),
+	})
+}
+
+// UnusedIndexes returns the indexes Postgres has not scanned since its statistics
+// were last reset
+func (sc *SystemController) UnusedIndexes(c *fiber.Ctx) error {
+	unused, err := database.UnusedIndexes(sc.DB)
+	if err != nil {
+		logger.Error("Failed to read index usage", err)
+		return sc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to read index usage",
+			Data:    nil,
+		})
+	}
+	resetAt, err := database.StatsResetAt(sc.DB)
+	if err != nil {
+		logger.Error("Failed to read when index statistics were reset", err)
+	}
+
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Unused indexes fetched successfully",
+		Data: fiber.Map{
+			"stats_reset_at": resetAt,
+			"unused":         unused,
+		},
+	})
+}
+
+// Health is the readiness probe: 503 while the database cannot be reached
+func (sc *SystemController) Health(c *fiber.Ctx) error {
+	if !database.Healthy() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(types.ApiResponse{
+			Status:  fiber.StatusServiceUnavailable,
+			Message: "Database unavailable",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "OK",
+	})
+}
+
+// BlockedSources lists the sources held back from barcode lookups. ?all=true also
+// returns the latest lifted and expired blocks.
+func (sc *SystemController) BlockedSources(c *fiber.Ctx) error {
+	guard := barcode_guard.Default(sc.DB)
+
+	var blocks []barcodeModel.ProbeBlock
+	var err error
+	if c.QueryBool("all") {
+		blocks, err = guard.Recent(200)
+	} else {
+		blocks, err = guard.Active(time.Now())
+	}
+	if err != nil {
+		logger.Error("Failed to fetch barcode probe blocks", err)
+		return sc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch blocked sources",
+			Data:    nil,
+		})
+	}
+
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Blocked sources fetched successfully",
+		Data:    blocks,
+	})
+}
+
+// LiftBlockedSource ends a barcode probe block early
+func (sc *SystemController) LiftBlockedSource(c *fiber.Ctx) error {
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+
+	block, err := barcode_guard.Default(sc.DB).Lift(uint(id), middleware.CurrentUserUUID(c), time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return sc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "No active block with this ID",
+			Data:    nil,
+		})
+	}
+	if err != nil {
+		logger.Error("Failed to lift barcode probe block", err)
+		return sc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to lift block",
+			Data:    nil,
+		})
+	}
+
+	return sc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Block lifted successfully",
+		Data:    block,
+	})
+}
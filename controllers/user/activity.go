@@ -0,0 +1,71 @@
+package user
+
+import (
+	"passport-booking/logger"
+	"passport-booking/services/user_activity"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	account "passport-booking/types/user"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Activity lists what a user did in a date range: booking events they wrote, bags
+// they opened or loaded and parcel status changes, newest first
+func (uc *UserAdminController) Activity(c *fiber.Ctx) error {
+	target, err := uc.targetUser(c)
+	if target == nil {
+		return err
+	}
+
+	var req account.ActivityRequest
+	if err := c.QueryParser(&req); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+	filter, err := req.Filter(time.Now())
+	if err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	feed, err := user_activity.List(uc.DB.WithContext(c.UserContext()), target.ID, filter)
+	if err != nil {
+		logger.Error("Failed to fetch user activity", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch activity",
+			Data:    nil,
+		})
+	}
+
+	totalPages := int((feed.Total + int64(req.PerPage) - 1) / int64(req.PerPage))
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Activity fetched successfully",
+		Data: account.ActivityResponse{
+			UserID:    target.ID,
+			Username:  target.Username,
+			LegalName: target.LegalName,
+			From:      filter.From,
+			To:        filter.To,
+			Summary:   feed.Summary,
+			Entries:   feed.Entries,
+			Pagination: bookingTypes.PaginationResponse{
+				CurrentPage: req.Page,
+				PerPage:     req.PerPage,
+				Total:       feed.Total,
+				TotalPages:  totalPages,
+				HasNext:     req.Page < totalPages,
+				HasPrev:     req.Page > 1,
+			},
+		},
+	})
+}
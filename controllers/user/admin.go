@@ -0,0 +1,380 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"passport-booking/constants"
+	"passport-booking/logger"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/user_admin"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	account "passport-booking/types/user"
+	"passport-booking/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// UserAdminController lets super admins manage local user records without editing
+// the database directly
+type UserAdminController struct {
+	DB             *gorm.DB
+	Logger         *logger.AsyncLogger
+	loggerInstance *logger.AsyncLogger
+}
+
+// NewUserAdminController creates a new user admin controller
+func NewUserAdminController(db *gorm.DB, asyncLogger *logger.AsyncLogger) *UserAdminController {
+	return &UserAdminController{
+		DB:             db,
+		Logger:         asyncLogger,
+		loggerInstance: asyncLogger,
+	}
+}
+
+// Helper function to log API requests and responses
+func (uc *UserAdminController) logAPIRequest(c *fiber.Ctx) {
+	logEntry := utils.CreateSanitizedLogEntry(c)
+	uc.loggerInstance.Log(logEntry)
+}
+
+// Helper function to send response and log in one call
+func (uc *UserAdminController) sendResponseWithLog(c *fiber.Ctx, status int, response types.ApiResponse) error {
+	result := c.Status(status).JSON(response)
+	uc.logAPIRequest(c)
+	return result
+}
+
+// currentAdmin resolves the administrator making the request, responding with an
+// error when it cannot
+func (uc *UserAdminController) currentAdmin(c *fiber.Ctx) (*userModel.User, error) {
+	admin, status, message := currentUser(c)
+	if admin == nil {
+		return nil, uc.sendResponseWithLog(c, status, types.ApiResponse{
+			Status:  status,
+			Message: message,
+			Data:    nil,
+		})
+	}
+	return admin, nil
+}
+
+// targetUser loads the user in the :id route param, responding with 404 when missing
+func (uc *UserAdminController) targetUser(c *fiber.Ctx) (*userModel.User, error) {
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+
+	var target userModel.User
+	if err := uc.DB.First(&target, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, uc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "User not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to fetch user", err)
+		return nil, uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch user",
+			Data:    nil,
+		})
+	}
+	return &target, nil
+}
+
+// Index lists users, optionally searched by name, phone or email and filtered by
+// permission, branch and active status
+func (uc *UserAdminController) Index(c *fiber.Ctx) error {
+	var req account.UserListRequest
+	if err := c.QueryParser(&req); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query := uc.DB.WithContext(c.UserContext()).Model(&userModel.User{}).Where("deleted_at IS NULL")
+	if req.Search != "" {
+		like := "%" + strings.ToLower(req.Search) + "%"
+		query = query.Where("LOWER(username) LIKE ? OR LOWER(legal_name) LIKE ? OR phone LIKE ? OR LOWER(email) LIKE ?",
+			like, like, like, like)
+	}
+	if req.Permission != "" {
+		encoded, _ := json.Marshal([]string{req.Permission})
+		query = query.Where("permissions::jsonb @> ?", string(encoded))
+	}
+	if req.BranchCode != "" {
+		query = query.Where("id IN (?)", uc.DB.Model(&userModel.UserBranch{}).Select("user_id").Where("branch_code = ?", req.BranchCode))
+	}
+	if req.Active != nil {
+		query = query.Where("is_active = ?", *req.Active)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.Error("Failed to count users", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch users",
+			Data:    nil,
+		})
+	}
+
+	var users []userModel.User
+	if err := query.Order("id DESC").Offset((req.Page - 1) * req.PerPage).Limit(req.PerPage).Find(&users).Error; err != nil {
+		logger.Error("Failed to fetch users", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch users",
+			Data:    nil,
+		})
+	}
+
+	totalPages := int((total + int64(req.PerPage) - 1) / int64(req.PerPage))
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Users fetched successfully",
+		Data: bookingTypes.BookingIndexResponse{
+			Data: users,
+			Pagination: bookingTypes.PaginationResponse{
+				CurrentPage: req.Page,
+				PerPage:     req.PerPage,
+				Total:       total,
+				TotalPages:  totalPages,
+				HasNext:     req.Page < totalPages,
+				HasPrev:     req.Page > 1,
+			},
+		},
+	})
+}
+
+// Show returns a user with their local branch mappings
+func (uc *UserAdminController) Show(c *fiber.Ctx) error {
+	target, err := uc.targetUser(c)
+	if target == nil {
+		return err
+	}
+
+	var branches []userModel.UserBranch
+	if err := uc.DB.Where("user_id = ?", target.ID).Order("branch_code").Find(&branches).Error; err != nil {
+		logger.Error("Failed to fetch user branches", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch user branches",
+			Data:    nil,
+		})
+	}
+
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "User fetched successfully",
+		Data: fiber.Map{
+			"user":     target,
+			"branches": branches,
+		},
+	})
+}
+
+// Permissions explains the permissions stored for a user: whether this service
+// knows them, whether they are read-only and whether they see unmasked PII
+func (uc *UserAdminController) Permissions(c *fiber.Ctx) error {
+	target, err := uc.targetUser(c)
+	if target == nil {
+		return err
+	}
+
+	permissions := make([]fiber.Map, 0, len(target.Permissions))
+	for _, permission := range target.Permissions {
+		permissions = append(permissions, fiber.Map{
+			"permission": permission,
+			"known":      contains(constants.KnownPermissions, permission),
+			"read_only":  contains(constants.ReadOnlyPermissions, permission),
+			"sees_pii":   contains(constants.PIIPermissions, permission),
+		})
+	}
+
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "User permissions fetched successfully",
+		Data: fiber.Map{
+			"user_id":     target.ID,
+			"is_active":   target.IsActive,
+			"permissions": permissions,
+		},
+	})
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SetActive switches a user on or off
+func (uc *UserAdminController) SetActive(c *fiber.Ctx) error {
+	admin, err := uc.currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+	target, err := uc.targetUser(c)
+	if target == nil {
+		return err
+	}
+
+	var req account.SetActiveRequest
+	if err := c.BodyParser(&req); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	if err := user_admin.SetActive(uc.DB, target, admin, *req.Active, req.Reason); err != nil {
+		if errors.Is(err, user_admin.ErrSelf) {
+			return uc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+				Status:  fiber.StatusConflict,
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to change user active status", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to change user status",
+			Data:    nil,
+		})
+	}
+	target.IsActive = *req.Active
+
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "User status updated successfully",
+		Data:    target,
+	})
+}
+
+// MapBranch maps a user to a branch locally. The DMS side of the mapping is still
+// made through POST /bag/branch-mapping.
+func (uc *UserAdminController) MapBranch(c *fiber.Ctx) error {
+	admin, err := uc.currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+	target, err := uc.targetUser(c)
+	if target == nil {
+		return err
+	}
+
+	var req account.BranchMappingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	mapping, err := user_admin.MapBranch(uc.DB, target, admin, req.BranchCode, req.Relationship, req.Reason)
+	if err != nil {
+		logger.Error("Failed to map user to branch", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to map user to branch",
+			Data:    nil,
+		})
+	}
+
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "User mapped to branch successfully",
+		Data:    mapping,
+	})
+}
+
+// UnmapBranch removes a user's local mapping to the branch in :code
+func (uc *UserAdminController) UnmapBranch(c *fiber.Ctx) error {
+	admin, err := uc.currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+	target, err := uc.targetUser(c)
+	if target == nil {
+		return err
+	}
+
+	err = user_admin.UnmapBranch(uc.DB, target, admin, c.Params("code"), strings.TrimSpace(c.Query("reason")))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return uc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "User is not mapped to this branch",
+			Data:    nil,
+		})
+	}
+	if err != nil {
+		logger.Error("Failed to unmap user from branch", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to unmap user from branch",
+			Data:    nil,
+		})
+	}
+
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "User unmapped from branch successfully",
+		Data:    nil,
+	})
+}
+
+// Audit returns the admin changes made to a user, newest first
+func (uc *UserAdminController) Audit(c *fiber.Ctx) error {
+	target, err := uc.targetUser(c)
+	if target == nil {
+		return err
+	}
+
+	var entries []userModel.UserAdminAudit
+	if err := uc.DB.Where("user_id = ?", target.ID).Order("created_at DESC").Limit(200).Find(&entries).Error; err != nil {
+		logger.Error("Failed to fetch user audit trail", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch audit trail",
+			Data:    nil,
+		})
+	}
+
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Audit trail fetched successfully",
+		Data:    entries,
+	})
+}
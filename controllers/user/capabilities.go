@@ -0,0 +1,40 @@
+package user
+
+import (
+	"passport-booking/middleware"
+	"passport-booking/types"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetCapabilities returns the API features the authenticated user is allowed to call,
+// derived from the permission guards registered on the routes
+func GetCapabilities(c *fiber.Ctx) error {
+	userPermissions := middleware.GetUserPermissions(c)
+
+	permissions := make([]string, 0, len(userPermissions))
+	for perm, granted := range userPermissions {
+		if granted {
+			permissions = append(permissions, perm)
+		}
+	}
+	sort.Strings(permissions)
+
+	routes := middleware.CapabilitiesFor(userPermissions)
+	features := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		features[route.Feature] = true
+	}
+
+	response := types.ApiResponse{
+		Message: "Capabilities fetched successfully",
+		Status:  fiber.StatusOK,
+		Data: map[string]interface{}{
+			"permissions": permissions,
+			"features":    features,
+			"routes":      routes,
+		},
+	}
+	return c.JSON(&response)
+}
@@ -0,0 +1,178 @@
+package user
+
+import (
+	"errors"
+	"passport-booking/constants"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/user_admin"
+	"passport-booking/types"
+	account "passport-booking/types/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// CreateDelegation lets a postmaster hand some of their own permissions to another
+// user until a set time, e.g. an acting postmaster during leave
+func (uc *UserAdminController) CreateDelegation(c *fiber.Ctx) error {
+	grantor, err := uc.currentAdmin(c)
+	if grantor == nil {
+		return err
+	}
+
+	var req account.DelegationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(time.Now(), user_admin.MaxDelegation()); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	var grantee userModel.User
+	if err := uc.DB.Where("id = ? AND is_active = ? AND approval_status = ?", req.GranteeID, true, userModel.ApprovalStatusApproved).First(&grantee).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Grantee not found or not active",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to fetch grantee", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch grantee",
+			Data:    nil,
+		})
+	}
+
+	delegation, err := user_admin.Delegate(uc.DB, grantor, &grantee, middleware.OwnPermissions(c),
+		req.Permissions, *req.StartsAt, req.ExpiresAt, req.Reason)
+	if err != nil {
+		var notHeld *user_admin.NotHeldError
+		if errors.Is(err, user_admin.ErrDelegateSelf) || errors.As(err, &notHeld) {
+			return uc.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+				Status:  fiber.StatusForbidden,
+				Message: err.Error(),
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to create delegation", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to create delegation",
+			Data:    nil,
+		})
+	}
+
+	return uc.sendResponseWithLog(c, fiber.StatusCreated, types.ApiResponse{
+		Status:  fiber.StatusCreated,
+		Message: "Delegation created successfully",
+		Data:    delegation,
+	})
+}
+
+// Delegations lists the delegations the caller granted or received; super admins
+// may list all of them
+func (uc *UserAdminController) Delegations(c *fiber.Ctx) error {
+	caller, err := uc.currentAdmin(c)
+	if caller == nil {
+		return err
+	}
+
+	var req account.DelegationListRequest
+	if err := c.QueryParser(&req); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query := uc.DB.Model(&userModel.Delegation{})
+	switch req.Scope {
+	case "granted":
+		query = query.Where("grantor_id = ?", caller.ID)
+	case "received":
+		query = query.Where("grantee_id = ?", caller.ID)
+	case "all":
+		if !middleware.OwnPermissions(c)[constants.PermSuperAdminFull] {
+			return uc.sendResponseWithLog(c, fiber.StatusForbidden, types.ApiResponse{
+				Status:  fiber.StatusForbidden,
+				Message: "Only super admins can list all delegations",
+				Data:    nil,
+			})
+		}
+	}
+	if req.ActiveOnly {
+		query = query.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	var delegations []userModel.Delegation
+	if err := query.Order("created_at DESC").Limit(200).Find(&delegations).Error; err != nil {
+		logger.Error("Failed to fetch delegations", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch delegations",
+			Data:    nil,
+		})
+	}
+
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delegations fetched successfully",
+		Data:    delegations,
+	})
+}
+
+// RevokeDelegation ends a delegation before it expires
+func (uc *UserAdminController) RevokeDelegation(c *fiber.Ctx) error {
+	caller, err := uc.currentAdmin(c)
+	if caller == nil {
+		return err
+	}
+
+	id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+	anyone := middleware.OwnPermissions(c)[constants.PermSuperAdminFull]
+	delegation, err := user_admin.Revoke(uc.DB, uint(id), caller, anyone, strings.TrimSpace(c.Query("reason")))
+	if errors.Is(err, user_admin.ErrDelegationNotFound) {
+		return uc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+			Status:  fiber.StatusNotFound,
+			Message: "No active delegation with this ID granted by you",
+			Data:    nil,
+		})
+	}
+	if err != nil {
+		logger.Error("Failed to revoke delegation", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to revoke delegation",
+			Data:    nil,
+		})
+	}
+
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Delegation revoked successfully",
+		Data:    delegation,
+	})
+}
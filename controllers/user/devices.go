@@ -0,0 +1,120 @@
+package user
+
+import (
+	"passport-booking/database"
+	"passport-booking/logger"
+	userModel "passport-booking/models/user"
+	devicePush "passport-booking/services/device_push"
+	"passport-booking/types"
+	deviceTypes "passport-booking/types/device"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListDevices returns the push devices registered by the authenticated user
+func ListDevices(c *fiber.Ctx) error {
+	userInfo, status, msg := currentUser(c)
+	if userInfo == nil {
+		return c.Status(status).JSON(types.ApiResponse{Message: msg, Status: status, Data: nil})
+	}
+
+	devices, err := devicePush.List(database.DB, userInfo.ID)
+	if err != nil {
+		logger.Error("Error fetching devices", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ApiResponse{
+			Message: "Error fetching devices",
+			Status:  fiber.StatusInternalServerError,
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(types.ApiResponse{
+		Message: "Devices fetched successfully",
+		Status:  fiber.StatusOK,
+		Data:    devices,
+	})
+}
+
+// RegisterDevice stores the push token of the mobile app for the authenticated user.
+// The app calls it on every start and whenever the provider rotates the token.
+func RegisterDevice(c *fiber.Ctx) error {
+	var req deviceTypes.RegisterDeviceRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return c.Status(fiber.StatusBadRequest).JSON(types.ApiResponse{
+			Message: "Invalid request body",
+			Status:  fiber.StatusBadRequest,
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ApiResponse{
+			Message: err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Data:    nil,
+		})
+	}
+
+	userInfo, status, msg := currentUser(c)
+	if userInfo == nil {
+		return c.Status(status).JSON(types.ApiResponse{Message: msg, Status: status, Data: nil})
+	}
+
+	device, err := devicePush.Register(database.DB, userInfo.ID, req.Token, userModel.DevicePlatform(req.Platform), req.AppVersion)
+	if err != nil {
+		logger.Error("Error registering device", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ApiResponse{
+			Message: "Error registering device",
+			Status:  fiber.StatusInternalServerError,
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(types.ApiResponse{
+		Message: "Device registered successfully",
+		Status:  fiber.StatusOK,
+		Data:    device,
+	})
+}
+
+// RemoveDevice unregisters one of the authenticated user's devices, e.g. on logout
+func RemoveDevice(c *fiber.Ctx) error {
+	deviceID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ApiResponse{
+			Message: "Invalid device ID",
+			Status:  fiber.StatusBadRequest,
+			Data:    nil,
+		})
+	}
+
+	userInfo, status, msg := currentUser(c)
+	if userInfo == nil {
+		return c.Status(status).JSON(types.ApiResponse{Message: msg, Status: status, Data: nil})
+	}
+
+	removed, err := devicePush.Remove(database.DB, userInfo.ID, uint(deviceID))
+	if err != nil {
+		logger.Error("Error removing device", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ApiResponse{
+			Message: "Error removing device",
+			Status:  fiber.StatusInternalServerError,
+			Data:    nil,
+		})
+	}
+	if !removed {
+		return c.Status(fiber.StatusNotFound).JSON(types.ApiResponse{
+			Message: "Device not found",
+			Status:  fiber.StatusNotFound,
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(types.ApiResponse{
+		Message: "Device removed successfully",
+		Status:  fiber.StatusOK,
+		Data:    nil,
+	})
+}
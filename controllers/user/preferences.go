@@ -0,0 +1,90 @@
+package user
+
+import (
+	"passport-booking/database"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	userModel "passport-booking/models/user"
+	preferenceService "passport-booking/services/preference"
+	"passport-booking/types"
+	preferenceTypes "passport-booking/types/preference"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetPreferences returns the authenticated user's preferences with defaults applied
+func GetPreferences(c *fiber.Ctx) error {
+	userInfo, status, msg := currentUser(c)
+	if userInfo == nil {
+		return c.Status(status).JSON(types.ApiResponse{Message: msg, Status: status, Data: nil})
+	}
+
+	prefs, err := preferenceService.Get(database.DB, userInfo.ID)
+	if err != nil {
+		logger.Error("Error fetching user preferences", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ApiResponse{
+			Message: "Error fetching preferences",
+			Status:  fiber.StatusInternalServerError,
+			Data:    nil,
+		})
+	}
+
+	return c.JSON(types.ApiResponse{
+		Message: "Preferences fetched successfully",
+		Status:  fiber.StatusOK,
+		Data:    prefs,
+	})
+}
+
+// UpdatePreferences stores the preferences present in the request body
+func UpdatePreferences(c *fiber.Ctx) error {
+	var req preferenceTypes.UpdatePreferencesRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse request body", err)
+		return c.Status(fiber.StatusBadRequest).JSON(types.ApiResponse{
+			Message: "Invalid request body",
+			Status:  fiber.StatusBadRequest,
+			Data:    nil,
+		})
+	}
+
+	if err := req.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(types.ApiResponse{
+			Message: err.Error(),
+			Status:  fiber.StatusBadRequest,
+			Data:    nil,
+		})
+	}
+
+	userInfo, status, msg := currentUser(c)
+	if userInfo == nil {
+		return c.Status(status).JSON(types.ApiResponse{Message: msg, Status: status, Data: nil})
+	}
+
+	prefs, err := preferenceService.Update(database.DB, userInfo.ID, req)
+	if err != nil {
+		logger.Error("Error updating user preferences", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(types.ApiResponse{
+			Message: "Error updating preferences",
+			Status:  fiber.StatusInternalServerError,
+			Data:    nil,
+		})
+	}
+
+	logger.Success("User preferences updated successfully")
+	return c.JSON(types.ApiResponse{
+		Message: "Preferences updated successfully",
+		Status:  fiber.StatusOK,
+		Data:    prefs,
+	})
+}
+
+// currentUser loads the user behind the request token. When the user cannot be
+// resolved it returns nil with the status and message to respond with.
+func currentUser(c *fiber.Ctx) (*userModel.User, int, string) {
+	current, ok := middleware.GetCurrentUser(c)
+	if !ok {
+		return nil, fiber.StatusUnauthorized, "User not found"
+	}
+	return current.User, fiber.StatusOK, ""
+}
@@ -0,0 +1,216 @@
+package user
+
+import (
+	"errors"
+	"passport-booking/constants"
+	"passport-booking/logger"
+	"passport-booking/middleware"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/notification"
+	"passport-booking/services/user_admin"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	account "passport-booking/types/user"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// reviewableRegistrations limits the registrations query to those the caller may
+// review: super admins see every branch, postmasters the branches they are mapped
+// to, including every branch under a mapped district or region
+func (uc *UserAdminController) reviewableRegistrations(c *fiber.Ctx, admin *userModel.User) (*gorm.DB, error) {
+	query := uc.DB.WithContext(c.UserContext()).Model(&userModel.User{}).Where("deleted_at IS NULL")
+	if middleware.GetUserPermissions(c)[constants.PermSuperAdminFull] {
+		return query, nil
+	}
+	codes, err := user_admin.BranchScope(uc.DB, admin.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(codes) == 0 {
+		return query.Where("1 = 0"), nil
+	}
+	return query.Where("requested_branch_code IN ?", codes), nil
+}
+
+// Registrations is the onboarding queue: self-registered operators waiting for a
+// decision, or past decisions with ?status=
+func (uc *UserAdminController) Registrations(c *fiber.Ctx) error {
+	admin, err := uc.currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+
+	var req account.RegistrationListRequest
+	if err := c.QueryParser(&req); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid query parameters",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query, err := uc.reviewableRegistrations(c, admin)
+	if err != nil {
+		logger.Error("Failed to resolve branch scope", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch registrations",
+			Data:    nil,
+		})
+	}
+	query = query.Where("approval_status = ?", req.Status)
+	if req.BranchCode != "" {
+		query = query.Where("requested_branch_code = ?", req.BranchCode)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.Error("Failed to count registrations", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch registrations",
+			Data:    nil,
+		})
+	}
+
+	// Oldest first so the queue is worked in arrival order
+	var users []userModel.User
+	if err := query.Order("created_at ASC").Offset((req.Page - 1) * req.PerPage).Limit(req.PerPage).Find(&users).Error; err != nil {
+		logger.Error("Failed to fetch registrations", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch registrations",
+			Data:    nil,
+		})
+	}
+
+	totalPages := int((total + int64(req.PerPage) - 1) / int64(req.PerPage))
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: "Registrations fetched successfully",
+		Data: bookingTypes.BookingIndexResponse{
+			Data: users,
+			Pagination: bookingTypes.PaginationResponse{
+				CurrentPage: req.Page,
+				PerPage:     req.PerPage,
+				Total:       total,
+				TotalPages:  totalPages,
+				HasNext:     req.Page < totalPages,
+				HasPrev:     req.Page > 1,
+			},
+		},
+	})
+}
+
+// ApproveRegistration lets a pending operator in
+func (uc *UserAdminController) ApproveRegistration(c *fiber.Ctx) error {
+	return uc.reviewRegistration(c, true)
+}
+
+// RejectRegistration turns a pending operator away
+func (uc *UserAdminController) RejectRegistration(c *fiber.Ctx) error {
+	return uc.reviewRegistration(c, false)
+}
+
+// reviewRegistration records the decision on the registration in :id and tells the
+// operator about it
+func (uc *UserAdminController) reviewRegistration(c *fiber.Ctx, approve bool) error {
+	admin, err := uc.currentAdmin(c)
+	if admin == nil {
+		return err
+	}
+
+	var req account.ReviewRegistrationRequest
+	if err := c.BodyParser(&req); err != nil && len(c.Body()) > 0 {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: "Invalid request body",
+			Data:    nil,
+		})
+	}
+	if err := req.Validate(approve); err != nil {
+		return uc.sendResponseWithLog(c, fiber.StatusBadRequest, types.ApiResponse{
+			Status:  fiber.StatusBadRequest,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+
+	query, err := uc.reviewableRegistrations(c, admin)
+	if err != nil {
+		logger.Error("Failed to resolve branch scope", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch registration",
+			Data:    nil,
+		})
+	}
+
+	var target userModel.User
+	if err := query.Where("id = ?", c.Params("id")).First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uc.sendResponseWithLog(c, fiber.StatusNotFound, types.ApiResponse{
+				Status:  fiber.StatusNotFound,
+				Message: "Registration not found",
+				Data:    nil,
+			})
+		}
+		logger.Error("Failed to fetch registration", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to fetch registration",
+			Data:    nil,
+		})
+	}
+
+	kind := notification.KindAccountRejected
+	if approve {
+		kind = notification.KindAccountApproved
+		err = user_admin.Approve(uc.DB, &target, admin, req.Note)
+	} else {
+		err = user_admin.Reject(uc.DB, &target, admin, req.Note)
+	}
+	if errors.Is(err, user_admin.ErrNotPending) {
+		return uc.sendResponseWithLog(c, fiber.StatusConflict, types.ApiResponse{
+			Status:  fiber.StatusConflict,
+			Message: err.Error(),
+			Data:    nil,
+		})
+	}
+	if err != nil {
+		logger.Error("Failed to review registration", err)
+		return uc.sendResponseWithLog(c, fiber.StatusInternalServerError, types.ApiResponse{
+			Status:  fiber.StatusInternalServerError,
+			Message: "Failed to review registration",
+			Data:    nil,
+		})
+	}
+
+	data := notification.Data{Name: target.LegalName, Reason: req.Note}
+	if data.Name == "" {
+		data.Name = target.Username
+	}
+	if target.RequestedBranchCode != nil {
+		data.DeliveryBranch = *target.RequestedBranchCode
+	}
+	notification.Dispatch(notification.Notification{Kind: kind, UserID: target.ID, Data: data})
+
+	message := "Registration rejected"
+	if approve {
+		message = "Registration approved"
+	}
+	return uc.sendResponseWithLog(c, fiber.StatusOK, types.ApiResponse{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Data:    target,
+	})
+}
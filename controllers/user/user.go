@@ -5,8 +5,10 @@ import (
 	"passport-booking/database"
 	"passport-booking/logger"
 	"passport-booking/models/user"
+	preferenceService "passport-booking/services/preference"
 	"passport-booking/types"
 	"passport-booking/utils"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -45,6 +47,12 @@ func GetUserInfo(c *fiber.Ctx) error {
 		return c.JSON(&response)
 	}
 
+	prefs, err := preferenceService.Get(database.DB, user.ID)
+	if err != nil {
+		logger.Error("Error fetching user preferences", err)
+		prefs = preferenceService.Defaults()
+	}
+
 	// Construct user info response
 	userInfo := map[string]interface{}{
 		"uid":            user.Uuid,
@@ -57,8 +65,9 @@ func GetUserInfo(c *fiber.Ctx) error {
 		"created_by":     user.CreatedByID,
 		"approved_by":    user.ApprovedByID,
 		"permissions":    user.Permissions,
-		"created_at":     user.CreatedAt.Format("2006-01-02 15:04:05"),
-		"updated_at":     user.UpdatedAt.Format("2006-01-02 15:04:05"),
+		"preferences":    prefs,
+		"created_at":     user.CreatedAt.UTC().Format(time.RFC3339),
+		"updated_at":     user.UpdatedAt.UTC().Format(time.RFC3339),
 	}
 
 	// Send successful response
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"passport-booking/logger"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultConnectAttempts      = 10
+	defaultConnectBackoffMs     = 500
+	defaultConnectMaxBackoffSec = 30
+	defaultHealthCheckSeconds   = 15
+	pingTimeout                 = 5 * time.Second
+)
+
+// healthy is cleared while the periodic ping fails
+var healthy atomic.Bool
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// backoff returns the wait before retry attempt (1-based): it doubles from
+// DB_CONNECT_BACKOFF_MS up to DB_CONNECT_MAX_BACKOFF_SECONDS, and a random half of it
+// is jitter so replicas starting together do not retry in step
+func backoff(attempt int) time.Duration {
+	base := time.Duration(envInt("DB_CONNECT_BACKOFF_MS", defaultConnectBackoffMs)) * time.Millisecond
+	max := time.Duration(envInt("DB_CONNECT_MAX_BACKOFF_SECONDS", defaultConnectMaxBackoffSec)) * time.Second
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func ping(sqlDB *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// waitForDB pings the database until it answers, up to DB_CONNECT_ATTEMPTS (default
+// 10) times, so the service can start before Postgres is ready
+func waitForDB(sqlDB *sql.DB) error {
+	attempts := envInt("DB_CONNECT_ATTEMPTS", defaultConnectAttempts)
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ping(sqlDB); err == nil {
+			healthy.Store(true)
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		wait := backoff(attempt)
+		logger.Warning(fmt.Sprintf("Database not ready (attempt %d/%d), retrying in %s: %v", attempt, attempts, wait.Round(time.Millisecond), err))
+		time.Sleep(wait)
+	}
+	return fmt.Errorf("database not reachable after %d attempts: %w", attempts, err)
+}
+
+// Healthy reports whether the last periodic ping reached the database
+func Healthy() bool {
+	return healthy.Load()
+}
+
+// WatchHealth pings the database every DB_HEALTH_CHECK_SECONDS (default 15). When a
+// ping fails the idle connections are dropped, since they are likely dead, and the
+// pool is pinged again with backoff until the database is back. The returned function
+// stops the check.
+func WatchHealth(sqlDB *sql.DB) func() {
+	interval := time.Duration(envInt("DB_HEALTH_CHECK_SECONDS", defaultHealthCheckSeconds)) * time.Second
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	heal := func(err error) {
+		healthy.Store(false)
+		logger.Error("Database ping failed, reconnecting", err)
+		for attempt := 1; ; attempt++ {
+			sqlDB.SetMaxIdleConns(0)
+			sqlDB.SetMaxIdleConns(defaultMaxIdleConns)
+			if err := ping(sqlDB); err == nil {
+				healthy.Store(true)
+				logger.Success(fmt.Sprintf("Database reconnected after %d attempts", attempt))
+				return
+			}
+			select {
+			case <-time.After(backoff(attempt)):
+			case <-done:
+				return
+			}
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := ping(sqlDB); err != nil {
+					heal(err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
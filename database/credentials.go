@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"passport-booking/config"
+	"passport-booking/logger"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// dbCredentials holds the credentials used for new pool connections. They are read
+// on every connect so rotated credentials apply without restarting the process.
+const (
+	defaultMaxIdleConns = 2 // database/sql default
+	connMaxLifetime     = 30 * time.Minute
+)
+
+var dbCredentials = struct {
+	sync.RWMutex
+	user     string
+	password string
+}{}
+
+func setDBCredentials(user, password string) {
+	dbCredentials.Lock()
+	defer dbCredentials.Unlock()
+	dbCredentials.user = user
+	dbCredentials.password = password
+}
+
+// openConnPool opens a pgx-backed pool that injects the current credentials into
+// each new connection
+func openConnPool() (*sql.DB, error) {
+	sslmode := os.Getenv("DB_SSLMODE") // Optional: "disable", "require", etc.
+	if sslmode == "" {
+		sslmode = "disable"
+	}
+
+	setDBCredentials(config.Secret("DB_USERNAME"), config.Secret("DB_PASSWORD"))
+
+	// Credentials are left out of the DSN and filled in by BeforeConnect. Sessions run
+	// in UTC so now(), date_trunc and naive timestamps do not depend on the server.
+	dsn := fmt.Sprintf("host=%s port=%s dbname=%s sslmode=%s timezone=UTC",
+		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_DATABASE"), sslmode)
+	config.Debugf("DSN: %s", dsn)
+
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database configuration: %w", err)
+	}
+
+	return stdlib.OpenDB(*connConfig, stdlib.OptionBeforeConnect(func(ctx context.Context, cfg *pgx.ConnConfig) error {
+		dbCredentials.RLock()
+		defer dbCredentials.RUnlock()
+		cfg.User = dbCredentials.user
+		cfg.Password = dbCredentials.password
+		return nil
+	})), nil
+}
+
+// WatchCredentials reloads DB_USERNAME and DB_PASSWORD from the secrets provider every
+// DB_CREDENTIALS_RELOAD_SECONDS (default 60). On rotation idle connections are dropped
+// so the pool reconnects with the new credentials; busy connections finish their work
+// and are replaced once they exceed their lifetime.
+func WatchCredentials(sqlDB *sql.DB) func() {
+	interval := 60 * time.Second
+	if v := os.Getenv("DB_CREDENTIALS_RELOAD_SECONDS"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			interval = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return config.WatchSecrets(interval, []string{"DB_USERNAME", "DB_PASSWORD"}, func(key, _ string) {
+		logger.Warning(fmt.Sprintf("Database credential %s rotated, reconnecting", key))
+		setDBCredentials(config.Secret("DB_USERNAME"), config.Secret("DB_PASSWORD"))
+		reconnect(sqlDB)
+	})
+}
+
+// reconnect drops the pool's idle connections and checks that the new credentials work
+func reconnect(sqlDB *sql.DB) {
+	sqlDB.SetMaxIdleConns(0)
+	sqlDB.SetMaxIdleConns(defaultMaxIdleConns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		logger.Error("Database reconnect with rotated credentials failed", err)
+		return
+	}
+	logger.Success("Database reconnected with rotated credentials")
+}
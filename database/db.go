@@ -2,18 +2,30 @@ package database
 
 import (
 	"fmt"
-	"os"
 	"strings"
+	"time"
 
 	"passport-booking/logger"
 	"passport-booking/models/address"
+	"passport-booking/models/barcode"
 	"passport-booking/models/booking"
+	"passport-booking/models/evidence"
+	"passport-booking/models/fraud"
 	"passport-booking/models/log"
+	notificationModel "passport-booking/models/notification"
 	"passport-booking/models/otp"
 	"passport-booking/models/parcel_booking"
 	"passport-booking/models/regional_passport_office"
+	"passport-booking/models/report"
+	shipmentModel "passport-booking/models/shipment"
 	"passport-booking/models/slip_parser"
 	"passport-booking/models/user"
+	"passport-booking/services/attribution"
+	"passport-booking/services/chaos"
+	"passport-booking/services/impersonation"
+	"passport-booking/services/query_count"
+	"passport-booking/services/query_log"
+	"passport-booking/services/shipment"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
@@ -29,39 +41,97 @@ func InitDB() (*gorm.DB, error) {
 		logger.Error("Error loading .env file", err)
 	}
 
-	// Get database configuration from environment variables
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	database := os.Getenv("DB_DATABASE")
-	user := os.Getenv("DB_USERNAME")
-	password := os.Getenv("DB_PASSWORD")
-	sslmode := os.Getenv("DB_SSLMODE") // Optional: "disable", "require", etc.
-
-	// Set default sslmode if not provided
-	if sslmode == "" {
-		sslmode = "disable"
+	// Credentials come from the secrets provider and are re-read on rotation
+	sqlDB, err := openConnPool()
+	if err != nil {
+		logger.Error("Failed to configure the database connection", err)
+		return nil, err
 	}
+	sqlDB.SetMaxIdleConns(defaultMaxIdleConns)
+	// Connections are recycled periodically so rotated credentials reach busy ones too
+	sqlDB.SetConnMaxLifetime(connMaxLifetime)
 
-	// Build PostgreSQL DSN string
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, database, sslmode)
-
-	fmt.Println("DSN:", dsn)
+	// Postgres may still be starting, e.g. when both come up together in a cluster
+	if err := waitForDB(sqlDB); err != nil {
+		logger.Error("Failed to reach the database", err)
+		return nil, err
+	}
 
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// Failed and slow statements are logged with their parameters masked
+	DB, err = gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:  query_log.Default(),
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	})
 	if err != nil {
 		logger.Error("Failed to connect to the database", err)
 		return nil, err
 	}
 	logger.Success("Successfully connected to the database")
+	WatchCredentials(sqlDB)
+	WatchHealth(sqlDB)
+	if err := impersonation.RegisterAuditCallback(DB); err != nil {
+		logger.Error("Failed to register impersonation audit callback", err)
+		return nil, err
+	}
+	if err := attribution.RegisterCallback(DB); err != nil {
+		logger.Error("Failed to register actor attribution callback", err)
+		return nil, err
+	}
+	if err := shipment.RegisterCallbacks(DB); err != nil {
+		logger.Error("Failed to register shipment sync callbacks", err)
+		return nil, err
+	}
+	if err := query_count.RegisterCallbacks(DB); err != nil {
+		logger.Error("Failed to register query count callbacks", err)
+		return nil, err
+	}
+	if err := chaos.RegisterCallbacks(DB); err != nil {
+		logger.Error("Failed to register fault injection callbacks", err)
+		return nil, err
+	}
+	// Replicas starting together take turns migrating under an advisory lock; the
+	// ones that should never migrate run with DB_AUTO_MIGRATE=false
+	if autoMigrateEnabled() {
+		if err := withMigrationLock(DB, migrateSchema); err != nil {
+			logger.Error("Failed to migrate the database", err)
+			return nil, err
+		}
+	} else {
+		logger.Warning("Migrations are disabled by DB_AUTO_MIGRATE; the schema is only checked")
+	}
+
+	// Catch code that moved on while the schema did not
+	if err := checkSchemaAtStartup(); err != nil {
+		logger.Error("Database schema check failed", err)
+		return nil, err
+	}
+
+	return DB, nil
+}
+
+// Migrate makes db the package connection and brings its schema up to date, for
+// tools and test databases that do not go through InitDB
+func Migrate(db *gorm.DB) error {
+	DB = db
+	return withMigrationLock(db, autoMigrate)
+}
+
+// migrateSchema migrates the models and then adds the constraints and indexes
+// AutoMigrate does not manage
+func migrateSchema() error {
 	// Run auto migration for all models
 	if err := autoMigrate(); err != nil {
 		logger.Error("Failed to run auto migration", err)
-		return nil, err
+		return err
 	}
 	logger.Success("All auto migrations completed successfully")
 
+	// Timestamps are stored as timestamptz so they mean the same on every server
+	if err := checkTimestampColumns(); err != nil {
+		logger.Error("Failed to check timestamp columns", err)
+		return err
+	}
+
 	// Handle foreign key constraints after migrations
 	if err := createForeignKeyConstraints(); err != nil {
 		logger.Error("Failed to create foreign key constraints", err)
@@ -80,25 +150,23 @@ func InitDB() (*gorm.DB, error) {
 		logger.Success("All indexes created successfully")
 	}
 
-	return DB, nil
+	// Status columns only accept the statuses registered in the models
+	if err := createStatusConstraints(); err != nil {
+		logger.Error("Failed to create status constraints", err)
+		logger.Warning("Continuing without some status constraints")
+	}
+	return nil
 }
 
-// autoMigrate runs auto migration for all models
-func autoMigrate() error {
-	// First, migrate models without foreign key constraints in stages
-
+// migrationStages returns the models in the order they are migrated, in stages so
+// models come after the ones their foreign keys point at
+func migrationStages() [][]interface{} {
 	// Stage 1: Core foundation models
 	stage1Models := []interface{}{
 		&user.User{},
 		&address.Address{},
 	}
 
-	for _, model := range stage1Models {
-		if err := DB.AutoMigrate(model); err != nil {
-			return fmt.Errorf("failed to migrate %T: %w", model, err)
-		}
-	}
-
 	// Stage 2: Models with dependencies on Stage 1
 	stage2Models := []interface{}{
 		&booking.Booking{},
@@ -108,12 +176,6 @@ func autoMigrate() error {
 		&otp.OTPEvent{},
 	}
 
-	for _, model := range stage2Models {
-		if err := DB.AutoMigrate(model); err != nil {
-			return fmt.Errorf("failed to migrate %T: %w", model, err)
-		}
-	}
-
 	// Stage 3: Remaining models
 	remainingModels := []interface{}{
 		// Logging
@@ -125,14 +187,99 @@ func autoMigrate() error {
 		// Parcel Booking
 		&parcel_booking.ParcelBooking{},
 		&parcel_booking.ParcelBookingStatusEvent{},
+		&parcel_booking.CounterSession{},
+		// Provisional barcodes
+		&barcode.ProvisionalBarcode{},
+		&barcode.BarcodeSequence{},
+		&barcode.PooledBarcode{},
+		// Sources held back for probing barcodes
+		&barcode.ProbeBlock{},
+		// User preferences
+		&user.UserPreference{},
+		// Push notification device tokens
+		&user.DeviceToken{},
+		// Managed notification templates
+		&notificationModel.MessageTemplate{},
+		// Outbound notification delivery log
+		&notificationModel.NotificationLog{},
+		// Impersonation audit
+		&user.ImpersonationSession{},
+		// Local branch mappings and the admin audit trail
+		&user.UserBranch{},
+		&user.UserAdminAudit{},
+		// Temporary permission grants
+		&user.Delegation{},
+		// Booking soft locks
+		&booking.BookingLock{},
+		// OTP evidence access
+		&evidence.EvidenceRequest{},
+		&evidence.EvidenceApproval{},
+		&evidence.ExportJob{},
+		// Delivery phone consent audit
+		&booking.PhoneConsent{},
+		// DMS status callbacks
+		&booking.DMSStatusMapping{},
+		&booking.DMSCallback{},
+		// Intake queued while DMS was unavailable
+		&booking.DMSOutboxEntry{},
+		// Delivery anti-fraud rules and cases
+		&fraud.FraudRule{},
+		&fraud.FraudCase{},
+		// Postman performance metrics
+		&report.PostmanMetric{},
+		// Per-route request counts and latencies for the SLO report
+		&report.RequestMetric{},
+		// Multi-booking delivery groups
+		&booking.DeliveryGroup{},
+		// Delivery address redirects
+		&booking.AddressChange{},
+		// Applicant delivery holds
+		&booking.DeliveryHold{},
+		// Daily booking creation quotas per operator and branch
+		&booking.BookingQuota{},
+		// Soft deletes of bookings and their restores
+		&booking.BookingDeletion{},
+		// Return bags back to regional passport offices
+		&booking.ReturnManifest{},
+		&booking.ReturnManifestItem{},
+		// Branch stock audits and the incidents they raise
+		&booking.StockAudit{},
+		&booking.StockIncident{},
+		// Yearly sequences behind booking references
+		&booking.ReferenceSequence{},
+		// Branch operating hours and dispatch cutoffs
+		&booking.BranchSchedule{},
+		// Region → district → branch tree for rollups and scoped permissions
+		&booking.OrgUnit{},
+		// Local bag contents for item-count and weight limits
+		&booking.Bag{},
+		// Transport lines bags are received onto, with their schedules and loads
+		&booking.TransportLine{},
+		&booking.TransportLineOffice{},
+		&booking.TransportLineSchedule{},
+		&booking.TransportLineLoad{},
+		// Shared core of bookings and parcel bookings
+		&shipmentModel.Shipment{},
+		// Postman actions synced from offline mode
+		&booking.OfflineSyncAction{},
+		&booking.OfflineDeliveryCode{},
+		// Failed outbox replays and what administrators did with dead-lettered items
+		&booking.DMSOutboxAttempt{},
+		&booking.DeadLetterAction{},
 	}
 
-	for _, model := range remainingModels {
-		if err := DB.AutoMigrate(model); err != nil {
-			return fmt.Errorf("failed to migrate %T: %w", model, err)
+	return [][]interface{}{stage1Models, stage2Models, remainingModels}
+}
+
+// autoMigrate runs auto migration for all models
+func autoMigrate() error {
+	for _, stage := range migrationStages() {
+		for _, model := range stage {
+			if err := DB.AutoMigrate(model); err != nil {
+				return fmt.Errorf("failed to migrate %T: %w", model, err)
+			}
 		}
 	}
-
 	return nil
 }
 
@@ -197,6 +344,39 @@ func createIndexes() error {
 		if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_bookings_created_at ON bookings(created_at)").Error; err != nil {
 			return fmt.Errorf("failed to create booking created_at index: %w", err)
 		}
+		// Composite indexes for the delivery flow: lookups by barcode, bag contents and
+		// a postman's items are always filtered by status as well
+		if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_bookings_barcode_status ON bookings(barcode, status)").Error; err != nil {
+			return fmt.Errorf("failed to create booking barcode/status index: %w", err)
+		}
+		if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_bookings_current_bag_id_status ON bookings(current_bag_id, status)").Error; err != nil {
+			return fmt.Errorf("failed to create booking current_bag_id/status index: %w", err)
+		}
+		if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_bookings_updated_by_status ON bookings(updated_by, status)").Error; err != nil {
+			return fmt.Errorf("failed to create booking updated_by/status index: %w", err)
+		}
+	}
+
+	// OTP indexes
+	if tableExists("otps") {
+		// Serves the latest unused OTP of a phone and purpose
+		if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_otps_phone_purpose_is_used_created_at ON otps(phone, purpose, is_used, created_at DESC)").Error; err != nil {
+			return fmt.Errorf("failed to create otp phone/purpose/is_used/created_at index: %w", err)
+		}
+		// At most one unused OTP per phone, purpose and booking, so bookings sharing a
+		// delivery phone each keep their own code. Duplicates left by concurrent sends
+		// before the index existed are retired, keeping the newest.
+		if err := DB.Exec(`UPDATE otps SET is_used = true WHERE is_used = false AND id NOT IN (
+			SELECT DISTINCT ON (phone, purpose, booking_id) id FROM otps WHERE is_used = false ORDER BY phone, purpose, booking_id, created_at DESC, id DESC)`).Error; err != nil {
+			return fmt.Errorf("failed to retire duplicate unused otps: %w", err)
+		}
+		if err := DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS uniq_otps_active_phone_purpose_booking ON otps(phone, purpose, booking_id) WHERE is_used = false").Error; err != nil {
+			return fmt.Errorf("failed to create otp active phone/purpose/booking unique index: %w", err)
+		}
+		// Superseded by the index above, which also scopes by booking
+		if err := DB.Exec("DROP INDEX IF EXISTS uniq_otps_active_phone_purpose").Error; err != nil {
+			return fmt.Errorf("failed to drop otp active phone/purpose unique index: %w", err)
+		}
 	}
 
 	// Log indexes
@@ -0,0 +1,241 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"passport-booking/logger"
+
+	"gorm.io/gorm"
+)
+
+// MigrationStrategy is how the dynamic migrator changes the type of a column
+type MigrationStrategy string
+
+const (
+	// StrategyInPlace alters the column type directly, rewriting the table under an
+	// exclusive lock
+	StrategyInPlace MigrationStrategy = "in_place"
+	// StrategyExpandContract adds a column of the new type, backfills it in
+	// batches while a trigger keeps it in step with writes, swaps the two in a
+	// short transaction and drops the old one
+	StrategyExpandContract MigrationStrategy = "expand_contract"
+
+	// Suffixes of the columns used while a column is being replaced
+	expandSuffix   = "__new"
+	contractSuffix = "__old"
+
+	defaultBackfillBatchSize = 1000
+	defaultBackfillPauseMs   = 100
+)
+
+// Operation types of an expand/contract plan, in the order they run
+const (
+	OpExpandColumn   = "expand_column"
+	OpBackfillColumn = "backfill_column"
+	OpCheckNotNull   = "check_not_null"
+	OpSwapColumn     = "swap_column"
+	OpDropOldColumn  = "drop_old_column"
+)
+
+// migrationStrategy reads DB_MIGRATION_STRATEGY, in_place unless set to
+// expand_contract
+func migrationStrategy() MigrationStrategy {
+	if MigrationStrategy(strings.TrimSpace(os.Getenv("DB_MIGRATION_STRATEGY"))) == StrategyExpandContract {
+		return StrategyExpandContract
+	}
+	return StrategyInPlace
+}
+
+// BackfillSpec is the copy of a column into its replacement done by the backfill
+// worker
+type BackfillSpec struct {
+	Table      string
+	PrimaryKey string
+	Source     string
+	Target     string
+	Type       string
+}
+
+// batchSQL copies up to limit rows not copied yet. Rows whose source is NULL stay
+// NULL and are never picked, so a stopped backfill resumes where it left off.
+func (b BackfillSpec) batchSQL(limit int) string {
+	return fmt.Sprintf(`UPDATE "%s" SET "%s" = CAST("%s" AS %s) WHERE "%s" IN (SELECT "%s" FROM "%s" WHERE "%s" IS NULL AND "%s" IS NOT NULL LIMIT %d)`,
+		b.Table, b.Target, b.Source, b.Type, b.PrimaryKey, b.PrimaryKey, b.Table, b.Target, b.Source, limit)
+}
+
+// syncTrigger names the trigger, and its function, that copies writes to the
+// replacement column while the backfill runs
+func syncTrigger(table, column string) string {
+	return fmt.Sprintf("%s_%s_expand_sync", table, column)
+}
+
+// singlePrimaryKey returns the primary key column of a model with exactly one
+func singlePrimaryKey(modelInfo ModelInfo) (string, bool) {
+	var pk string
+	for _, f := range modelInfo.Fields {
+		if f.PrimaryKey {
+			if pk != "" {
+				return "", false
+			}
+			pk = f.Name
+		}
+	}
+	return pk, pk != ""
+}
+
+// expandContractPlan replaces the in-place type change of field with the steps of
+// an expand/contract change. Indexes and constraints on the old column go with it
+// in the drop step; AutoMigrate and createIndexes add them back on the next start.
+func (dm *DynamicMigrator) expandContractPlan(modelInfo ModelInfo, field FieldInfo, oldField FieldInfo) []MigrationOperation {
+	table, column := modelInfo.TableName, field.Name
+	pk, _ := singlePrimaryKey(modelInfo)
+	newCol, oldCol := column+expandSuffix, column+contractSuffix
+	trigger := syncTrigger(table, column)
+	spec := BackfillSpec{Table: table, PrimaryKey: pk, Source: column, Target: newCol, Type: field.Type}
+
+	expand := []string{
+		fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "%s" %s`, table, newCol, field.Type),
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION "%s"() RETURNS trigger AS $$ BEGIN NEW."%s" := CAST(NEW."%s" AS %s); RETURN NEW; END $$ LANGUAGE plpgsql`,
+			trigger, newCol, column, field.Type),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS "%s" ON "%s"`, trigger, table),
+		fmt.Sprintf(`CREATE TRIGGER "%s" BEFORE INSERT OR UPDATE ON "%s" FOR EACH ROW EXECUTE FUNCTION "%s"()`, trigger, table, trigger),
+	}
+
+	// The swap catches up rows written between the last batch and the lock, then
+	// frees the old column of its constraints so writes that no longer set it pass
+	swap := []string{
+		fmt.Sprintf(`LOCK TABLE "%s" IN SHARE ROW EXCLUSIVE MODE`, table),
+		spec.batchSQL(1 << 30),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS "%s" ON "%s"`, trigger, table),
+		fmt.Sprintf(`DROP FUNCTION IF EXISTS "%s"()`, trigger),
+		fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" DROP NOT NULL, ALTER COLUMN "%s" DROP DEFAULT`, table, column, column),
+		fmt.Sprintf(`ALTER TABLE "%s" RENAME COLUMN "%s" TO "%s"`, table, column, oldCol),
+		fmt.Sprintf(`ALTER TABLE "%s" RENAME COLUMN "%s" TO "%s"`, table, newCol, column),
+	}
+	if field.Default != nil {
+		swap = append(swap, fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" SET DEFAULT %s`,
+			table, column, dm.formatDefaultValue(field.Default, field.Type)))
+	}
+	// SET NOT NULL skips its table scan when a validated check already proves it
+	check := fmt.Sprintf("%s_%s_not_null", table, column)
+	if field.NotNull {
+		swap = append(swap,
+			fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" SET NOT NULL`, table, column),
+			fmt.Sprintf(`ALTER TABLE "%s" DROP CONSTRAINT IF EXISTS "%s"`, table, check),
+		)
+	}
+
+	described := func(step string) string {
+		return fmt.Sprintf("%s column %s.%s (%s -> %s)", step, table, column, oldField.Type, field.Type)
+	}
+	plan := []MigrationOperation{
+		{
+			Type: OpExpandColumn, TableName: table, ColumnName: column, OldField: &oldField, NewField: &field,
+			Statements: expand, SQL: strings.Join(expand, ";\n"), Description: described("Expand"),
+		},
+		{
+			Type: OpBackfillColumn, TableName: table, ColumnName: column, Backfill: &spec,
+			SQL:         "-- repeated until no rows change\n" + spec.batchSQL(backfillBatchSize()),
+			Description: described("Backfill"),
+		},
+	}
+	if field.NotNull {
+		// Added unvalidated and validated separately: validating only blocks schema
+		// changes, not writes, while it scans
+		plan = append(plan,
+			MigrationOperation{
+				Type: OpCheckNotNull, TableName: table, ColumnName: column,
+				SQL: fmt.Sprintf(`ALTER TABLE "%s" DROP CONSTRAINT IF EXISTS "%s", ADD CONSTRAINT "%s" CHECK ("%s" IS NOT NULL) NOT VALID`,
+					table, check, check, newCol),
+				Description: described("Add not-null check to"),
+			},
+			MigrationOperation{
+				Type: OpCheckNotNull, TableName: table, ColumnName: column,
+				SQL:         fmt.Sprintf(`ALTER TABLE "%s" VALIDATE CONSTRAINT "%s"`, table, check),
+				Description: described("Validate not-null check of"),
+			},
+		)
+	}
+	return append(plan,
+		MigrationOperation{
+			Type: OpSwapColumn, TableName: table, ColumnName: column,
+			Statements: swap, SQL: strings.Join(swap, ";\n"), Description: described("Swap"),
+		},
+		MigrationOperation{
+			Type: OpDropOldColumn, TableName: table, ColumnName: oldCol,
+			SQL:         dm.generateDropColumnSQL(table, oldCol),
+			Description: fmt.Sprintf("Drop replaced column %s.%s", table, oldCol),
+		},
+	)
+}
+
+func backfillBatchSize() int {
+	return envInt("DB_BACKFILL_BATCH_SIZE", defaultBackfillBatchSize)
+}
+
+// Backfill copies spec.Source into spec.Target in batches of DB_BACKFILL_BATCH_SIZE
+// rows (default 1000), each in its own statement, pausing DB_BACKFILL_PAUSE_MS
+// (default 100) between them so deliveries writing to the table are not held up.
+// It returns the number of rows copied.
+func Backfill(db *gorm.DB, spec BackfillSpec) (int64, error) {
+	batch := backfillBatchSize()
+	pause := time.Duration(envInt("DB_BACKFILL_PAUSE_MS", defaultBackfillPauseMs)) * time.Millisecond
+	sql := spec.batchSQL(batch)
+
+	var total int64
+	for batches := 1; ; batches++ {
+		result := db.Exec(sql)
+		if result.Error != nil {
+			return total, fmt.Errorf("backfill of %s.%s failed after %d rows: %w", spec.Table, spec.Target, total, result.Error)
+		}
+		total += result.RowsAffected
+		if result.RowsAffected == 0 {
+			return total, nil
+		}
+		if batches%50 == 0 {
+			logger.Info(fmt.Sprintf("Backfilled %d rows of %s.%s", total, spec.Table, spec.Target))
+		}
+		time.Sleep(pause)
+	}
+}
+
+// executePhased runs an expand/contract plan one step at a time. Unlike the
+// in-place plan it is not one transaction: each step commits on its own, so the
+// backfill never holds locks for long. Every step can be run again, so a failed
+// plan is resumed by running the migration again.
+func (dm *DynamicMigrator) executePhased(operations []MigrationOperation) error {
+	for i, op := range operations {
+		logger.Debug(fmt.Sprintf("[%d/%d] %s", i+1, len(operations), op.Description))
+
+		var err error
+		switch {
+		case op.Backfill != nil:
+			var rows int64
+			rows, err = Backfill(dm.db, *op.Backfill)
+			if err == nil {
+				logger.Info(fmt.Sprintf("Backfilled %d rows of %s.%s", rows, op.Backfill.Table, op.Backfill.Target))
+			}
+		case len(op.Statements) > 0:
+			err = dm.db.Transaction(func(tx *gorm.DB) error {
+				for _, stmt := range op.Statements {
+					if err := tx.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		default:
+			err = dm.db.Exec(op.SQL).Error
+		}
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to execute migration: %s", op.Description), err)
+			return err
+		}
+
+		logger.Success(fmt.Sprintf("✅ %s", op.Description))
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package database
+
+import "gorm.io/gorm"
+
+// IndexUsage is the scan count of one index since the statistics were last reset
+type IndexUsage struct {
+	Table     string `json:"table"`
+	Index     string `json:"index"`
+	Scans     int64  `json:"scans"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// UnusedIndexes lists the indexes Postgres has never scanned since its statistics
+// were reset, largest first. Primary keys and unique indexes are left out since they
+// enforce constraints whether or not queries use them.
+func UnusedIndexes(db *gorm.DB) ([]IndexUsage, error) {
+	var unused []IndexUsage
+	err := db.Raw(`SELECT s.relname AS "table", s.indexrelname AS "index", s.idx_scan AS scans,
+			pg_relation_size(s.indexrelid) AS size_bytes
+		FROM pg_stat_user_indexes s
+		JOIN pg_index i ON i.indexrelid = s.indexrelid
+		WHERE s.idx_scan = 0 AND NOT i.indisunique AND NOT i.indisprimary
+		ORDER BY pg_relation_size(s.indexrelid) DESC, s.relname, s.indexrelname`).
+		Scan(&unused).Error
+	return unused, err
+}
+
+// StatsResetAt returns when the index statistics of the current database were last
+// reset, empty when they never were
+func StatsResetAt(db *gorm.DB) (string, error) {
+	var resetAt *string
+	err := db.Raw(`SELECT stats_reset::text FROM pg_stat_database WHERE datname = current_database()`).
+		Scan(&resetAt).Error
+	if err != nil || resetAt == nil {
+		return "", err
+	}
+	return *resetAt, nil
+}
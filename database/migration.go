@@ -60,22 +60,35 @@ type MigrationOperation struct {
 	ReferencedColumn string
 	OnUpdate         string
 	OnDelete         string
+	// Expand/contract steps: statements run together in one transaction, or the
+	// batched copy done by the backfill worker
+	Statements []string
+	Backfill   *BackfillSpec
 }
 
 // DynamicMigrator handles dynamic database migrations
 type DynamicMigrator struct {
-	db     *gorm.DB
-	models []ModelInfo
+	db       *gorm.DB
+	models   []ModelInfo
+	strategy MigrationStrategy
 }
 
-// NewDynamicMigrator creates a new dynamic migrator instance
+// NewDynamicMigrator creates a new dynamic migrator instance. Type changes use the
+// strategy set in DB_MIGRATION_STRATEGY.
 func NewDynamicMigrator(db *gorm.DB) *DynamicMigrator {
 	return &DynamicMigrator{
-		db:     db,
-		models: getRegisteredModels(),
+		db:       db,
+		models:   getRegisteredModels(),
+		strategy: migrationStrategy(),
 	}
 }
 
+// WithStrategy sets how column types are changed
+func (dm *DynamicMigrator) WithStrategy(strategy MigrationStrategy) *DynamicMigrator {
+	dm.strategy = strategy
+	return dm
+}
+
 // getRegisteredModels returns all registered models for migration
 func getRegisteredModels() []ModelInfo {
 	models := []interface{}{
@@ -404,7 +417,7 @@ func getFieldType(fieldType reflect.Type, gormTag string) string {
 		return "boolean"
 	default:
 		if fieldType == reflect.TypeOf(time.Time{}) {
-			return "timestamp"
+			return "timestamptz"
 		}
 		return "text"
 	}
@@ -611,6 +624,12 @@ func (dm *DynamicMigrator) analyzeTable(modelInfo ModelInfo) ([]MigrationOperati
 
 			if dm.isColumnModified(existingCol, field) {
 				oldField := dm.convertColumnToField(existingCol)
+				// Only a type change rewrites the table; the other changes stay in place
+				typeChanged := dm.normalizeType(existingCol.Type) != dm.normalizeExpectedType(field.Type)
+				if _, ok := singlePrimaryKey(modelInfo); ok && typeChanged && dm.strategy == StrategyExpandContract {
+					operations = append(operations, dm.expandContractPlan(modelInfo, field, oldField)...)
+					continue
+				}
 				op := MigrationOperation{
 					Type:        "modify_column",
 					TableName:   modelInfo.TableName,
@@ -1144,7 +1163,7 @@ func (dm *DynamicMigrator) normalizeExpectedType(fieldType string) string {
 
 	// Time types
 	case fieldType == "time.time" || fieldType == "datetime":
-		return "timestamp"
+		return "timestamptz"
 
 	// Binary types
 	case fieldType == "[]byte" || fieldType == "bytes":
@@ -1285,6 +1304,10 @@ func (dm *DynamicMigrator) ExecuteMigrations(operations []MigrationOperation) er
 
 	logger.Success(fmt.Sprintf("🚀 Executing %d migration operations...", len(operations)))
 
+	if dm.strategy == StrategyExpandContract {
+		return dm.executePhased(operations)
+	}
+
 	// Execute in transaction
 	return dm.db.Transaction(func(tx *gorm.DB) error {
 		for i, op := range operations {
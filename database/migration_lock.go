@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"passport-booking/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// migrationLockKey is the Postgres advisory lock held while migrating. It only
+	// has to differ from other advisory locks taken in the same database.
+	migrationLockKey int64 = 0x5042_4d49_4752 // "PBMIGR"
+
+	defaultMigrationLockTimeoutSec = 300
+	migrationLockPoll              = 2 * time.Second
+)
+
+// ErrMigrationLockTimeout is returned when another replica held the migration lock
+// for longer than DB_MIGRATION_LOCK_TIMEOUT_SECONDS
+var ErrMigrationLockTimeout = errors.New("timed out waiting for the migration lock")
+
+// withMigrationLock runs migrate while holding the migration advisory lock, so
+// replicas booting together do not run AutoMigrate and CREATE INDEX against each
+// other. A replica that has to wait runs migrate after the holder is done, which
+// then finds nothing left to change. The wait is capped by
+// DB_MIGRATION_LOCK_TIMEOUT_SECONDS (default 300).
+func withMigrationLock(db *gorm.DB, migrate func() error) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	timeout := time.Duration(envInt("DB_MIGRATION_LOCK_TIMEOUT_SECONDS", defaultMigrationLockTimeoutSec)) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Advisory locks belong to a session, so one connection takes and releases it
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection for the migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	if err := acquireMigrationLock(ctx, conn); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			logger.Error("Failed to release the migration lock", err)
+		}
+	}()
+
+	return migrate()
+}
+
+// acquireMigrationLock polls for the lock until ctx is done, logging once when
+// another replica holds it
+func acquireMigrationLock(ctx context.Context, conn *sql.Conn) error {
+	waiting := false
+	for {
+		var locked bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&locked); err != nil {
+			if ctx.Err() != nil {
+				return ErrMigrationLockTimeout
+			}
+			return fmt.Errorf("failed to take the migration lock: %w", err)
+		}
+		if locked {
+			if waiting {
+				logger.Info("Migration lock acquired")
+			}
+			return nil
+		}
+		if !waiting {
+			logger.Info("Another instance is migrating the database; waiting for it to finish")
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return ErrMigrationLockTimeout
+		case <-time.After(migrationLockPoll):
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package database
+
+import "gorm.io/gorm"
+
+// UserSummary limits a preloaded user to the columns list responses show, leaving
+// permissions and contact details of the account out of the row
+func UserSummary(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "uuid", "username", "legal_name", "phone")
+}
+
+// AddressSummary limits a preloaded delivery address to the columns of
+// BookingAddressResponse
+func AddressSummary(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "division", "district", "police_station", "post_office", "post_office_code", "street_address",
+		"division_bn", "district_bn", "police_station_bn", "post_office_bn", "street_address_bn")
+}
+
+// NotDeletedBookings leaves soft-deleted bookings out of a bookings query. Handlers
+// that read or change a booking on behalf of a user scope their lookup with it, so a
+// deleted booking behaves as missing until it is restored.
+func NotDeletedBookings(db *gorm.DB) *gorm.DB {
+	return db.Where("bookings.deleted_at IS NULL")
+}
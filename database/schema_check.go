@@ -0,0 +1,174 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"passport-booking/config"
+	"passport-booking/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// SchemaDrift is one difference between a model and the table it maps to
+type SchemaDrift struct {
+	Table    string `json:"table"`
+	Column   string `json:"column,omitempty"`
+	Problem  string `json:"problem"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+const (
+	DriftMissingTable  = "missing_table"
+	DriftMissingColumn = "missing_column"
+	DriftTypeMismatch  = "type_mismatch"
+	// DriftRequiredColumn is a NOT NULL column without a default that the model
+	// leaves out or may leave empty, so inserts fail
+	DriftRequiredColumn = "required_column"
+)
+
+func (d SchemaDrift) String() string {
+	name := d.Table
+	if d.Column != "" {
+		name += "." + d.Column
+	}
+	if d.Expected != "" || d.Actual != "" {
+		return fmt.Sprintf("%s: %s (model %s, database %s)", name, d.Problem, d.Expected, d.Actual)
+	}
+	return fmt.Sprintf("%s: %s", name, d.Problem)
+}
+
+// autoMigrateEnabled reports whether DB_AUTO_MIGRATE allows migrating at startup
+// (default true). Environments that migrate out of band turn it off.
+func autoMigrateEnabled() bool {
+	if b, err := strconv.ParseBool(os.Getenv("DB_AUTO_MIGRATE")); err == nil {
+		return b
+	}
+	return true
+}
+
+// CheckSchema compares the columns of every migrated table with its model, using
+// the same introspection as the dynamic migrator. Types are compared by kind
+// (text, integer, time...) since sizes and serial types vary between environments.
+func CheckSchema(db *gorm.DB) ([]SchemaDrift, error) {
+	dm := &DynamicMigrator{db: db}
+	var drift []SchemaDrift
+	for _, stage := range migrationStages() {
+		for _, model := range stage {
+			stmt := &gorm.Statement{DB: db}
+			if err := stmt.Parse(model); err != nil {
+				return nil, fmt.Errorf("failed to parse %T: %w", model, err)
+			}
+			tableDrift, err := checkTable(dm, stmt.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check table %s: %w", stmt.Schema.Table, err)
+			}
+			drift = append(drift, tableDrift...)
+		}
+	}
+	return drift, nil
+}
+
+func checkTable(dm *DynamicMigrator, sch *schema.Schema) ([]SchemaDrift, error) {
+	exists, err := dm.tableExists(sch.Table)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return []SchemaDrift{{Table: sch.Table, Problem: DriftMissingTable}}, nil
+	}
+
+	columns, err := dm.getTableColumns(sch.Table)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]ColumnInfo, len(columns))
+	for _, col := range columns {
+		existing[col.Name] = col
+	}
+
+	var drift []SchemaDrift
+	for _, name := range sch.DBNames {
+		field := sch.FieldsByDBName[name]
+		if field.IgnoreMigration {
+			continue
+		}
+		col, ok := existing[name]
+		if !ok {
+			drift = append(drift, SchemaDrift{Table: sch.Table, Column: name, Problem: DriftMissingColumn})
+			continue
+		}
+		expected := typeKind(dm.normalizeExpectedType(string(field.DataType)))
+		actual := typeKind(dm.normalizeType(col.Type))
+		if expected != actual {
+			drift = append(drift, SchemaDrift{Table: sch.Table, Column: name, Problem: DriftTypeMismatch, Expected: expected, Actual: actual})
+		}
+		if required(col) && !field.NotNull && !field.PrimaryKey && !field.HasDefaultValue {
+			drift = append(drift, SchemaDrift{Table: sch.Table, Column: name, Problem: DriftRequiredColumn, Expected: "nullable", Actual: "not null"})
+		}
+	}
+	for _, col := range columns {
+		if _, ok := sch.FieldsByDBName[col.Name]; !ok && required(col) {
+			drift = append(drift, SchemaDrift{Table: sch.Table, Column: col.Name, Problem: DriftRequiredColumn, Expected: "absent", Actual: "not null"})
+		}
+	}
+	return drift, nil
+}
+
+// required reports whether inserts must give the column a value
+func required(col ColumnInfo) bool {
+	return !col.IsNullable && col.Default == nil && !col.IsPrimaryKey
+}
+
+// typeKind groups a normalized column type with the types it is interchangeable with
+func typeKind(t string) string {
+	switch t {
+	case "varchar", "text", "string":
+		return "text"
+	case "integer", "bigint", "smallint", "int", "uint":
+		return "integer"
+	case "real", "double precision", "decimal", "float":
+		return "number"
+	case "boolean", "bool":
+		return "boolean"
+	case "timestamptz", "timestamp", "date", "time":
+		return "time"
+	case "json", "jsonb":
+		return "json"
+	case "bytea", "bytes":
+		return "binary"
+	}
+	return t
+}
+
+// checkSchemaAtStartup runs CheckSchema unless DB_SCHEMA_CHECK=false. Drift is
+// logged, and in the prod profile it stops the start so code does not run against
+// a schema it does not match.
+func checkSchemaAtStartup() error {
+	if b, err := strconv.ParseBool(os.Getenv("DB_SCHEMA_CHECK")); err == nil && !b {
+		return nil
+	}
+	drift, err := CheckSchema(DB)
+	if err != nil {
+		return err
+	}
+	if len(drift) == 0 {
+		logger.Success("Database schema matches the models")
+		return nil
+	}
+
+	lines := make([]string, len(drift))
+	for i, d := range drift {
+		lines[i] = d.String()
+	}
+	summary := fmt.Sprintf("Database schema differs from the models in %d places: %s", len(drift), strings.Join(lines, "; "))
+	if config.CurrentProfile().Profile == config.ProfileProd {
+		return fmt.Errorf("%s", summary)
+	}
+	logger.Warning(summary)
+	return nil
+}
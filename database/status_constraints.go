@@ -0,0 +1,118 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"passport-booking/logger"
+	"passport-booking/models/booking"
+	"passport-booking/models/parcel_booking"
+)
+
+// statusColumn is a column that may only hold the statuses of one enum
+type statusColumn struct {
+	Table  string
+	Column string
+	Values []string
+}
+
+// statusColumns lists the status columns and the enum registry they follow
+func statusColumns() []statusColumn {
+	bookingStatuses := make([]string, 0, len(booking.GetAllBookingStatuses()))
+	for _, s := range booking.GetAllBookingStatuses() {
+		bookingStatuses = append(bookingStatuses, string(s))
+	}
+	parcelStatuses := make([]string, 0, len(parcel_booking.GetAllParcelBookingStatuses()))
+	for _, s := range parcel_booking.GetAllParcelBookingStatuses() {
+		parcelStatuses = append(parcelStatuses, string(s))
+	}
+
+	return []statusColumn{
+		{Table: "bookings", Column: "status", Values: bookingStatuses},
+		{Table: "booking_status_events", Column: "status", Values: bookingStatuses},
+		{Table: "dms_status_mappings", Column: "booking_status", Values: bookingStatuses},
+		{Table: "parcel_bookings", Column: "current_status", Values: parcelStatuses},
+	}
+}
+
+// constraintPrefix starts the names of the check constraints of the column
+func (sc statusColumn) constraintPrefix() string {
+	return fmt.Sprintf("chk_%s_%s_", sc.Table, sc.Column)
+}
+
+// constraintName ends in a hash of the allowed values, so a changed registry
+// gives a new name and the constraint is replaced on the next start
+func (sc statusColumn) constraintName() string {
+	values := append([]string(nil), sc.Values...)
+	sort.Strings(values)
+	sum := sha256.Sum256([]byte(strings.Join(values, ",")))
+	return sc.constraintPrefix() + hex.EncodeToString(sum[:4])
+}
+
+func (sc statusColumn) checkSQL() string {
+	quoted := make([]string, len(sc.Values))
+	for i, v := range sc.Values {
+		quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+	return fmt.Sprintf(`"%s" IN (%s)`, sc.Column, strings.Join(quoted, ", "))
+}
+
+// createStatusConstraints adds a check constraint limiting each status column to
+// its registered values. A constraint is added unvalidated first, so it holds for
+// new writes at once; rows with unknown statuses then only make the validation
+// fail, which is logged with the values to clean up.
+func createStatusConstraints() error {
+	for _, sc := range statusColumns() {
+		if !tableExists(sc.Table) {
+			continue
+		}
+		name := sc.constraintName()
+
+		var existing []string
+		if err := DB.Raw(`SELECT conname FROM pg_constraint WHERE conrelid = ?::regclass AND contype = 'c'`, sc.Table).
+			Scan(&existing).Error; err != nil {
+			return fmt.Errorf("failed to list check constraints of %s: %w", sc.Table, err)
+		}
+		current := false
+		var stale []string
+		for _, c := range existing {
+			switch {
+			case c == name:
+				current = true
+			case strings.HasPrefix(c, sc.constraintPrefix()):
+				stale = append(stale, c)
+			}
+		}
+		if current && len(stale) == 0 {
+			continue
+		}
+
+		if !current {
+			parts := make([]string, 0, len(stale)+1)
+			for _, c := range stale {
+				parts = append(parts, fmt.Sprintf(`DROP CONSTRAINT "%s"`, c))
+			}
+			parts = append(parts, fmt.Sprintf(`ADD CONSTRAINT "%s" CHECK (%s) NOT VALID`, name, sc.checkSQL()))
+			if err := DB.Exec(fmt.Sprintf(`ALTER TABLE "%s" %s`, sc.Table, strings.Join(parts, ", "))).Error; err != nil {
+				return fmt.Errorf("failed to add status constraint to %s.%s: %w", sc.Table, sc.Column, err)
+			}
+			if err := DB.Exec(fmt.Sprintf(`ALTER TABLE "%s" VALIDATE CONSTRAINT "%s"`, sc.Table, name)).Error; err != nil {
+				var unknown []string
+				DB.Raw(fmt.Sprintf(`SELECT DISTINCT "%s" FROM "%s" WHERE NOT (%s)`, sc.Column, sc.Table, sc.checkSQL())).Scan(&unknown)
+				logger.Warning(fmt.Sprintf("Rows of %s have statuses outside the registry in %s: %s; the constraint only applies to new writes until they are fixed",
+					sc.Table, sc.Column, strings.Join(unknown, ", ")))
+			}
+			continue
+		}
+
+		for _, c := range stale {
+			if err := DB.Exec(fmt.Sprintf(`ALTER TABLE "%s" DROP CONSTRAINT "%s"`, sc.Table, c)).Error; err != nil {
+				return fmt.Errorf("failed to drop status constraint %s: %w", c, err)
+			}
+		}
+	}
+	return nil
+}
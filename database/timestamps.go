@@ -0,0 +1,64 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"passport-booking/logger"
+	"passport-booking/services/localtime"
+)
+
+// naiveTimestampColumn is a column storing a timestamp without a time zone
+type naiveTimestampColumn struct {
+	TableName  string
+	ColumnName string
+}
+
+// checkTimestampColumns looks for timestamp columns without a time zone. Their
+// values depend on the zone of whichever server wrote them, so they are
+// reported, and converted to timestamptz when DB_CONVERT_TIMESTAMPS=true. The
+// existing values are read as local time in DB_LEGACY_TIMEZONE, by default the
+// user-facing zone the servers have been running in.
+func checkTimestampColumns() error {
+	var columns []naiveTimestampColumn
+	if err := DB.Raw(`
+		SELECT c.table_name, c.column_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables t
+			ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = CURRENT_SCHEMA()
+		AND t.table_type = 'BASE TABLE'
+		AND c.data_type = 'timestamp without time zone'
+		ORDER BY c.table_name, c.column_name`).Scan(&columns).Error; err != nil {
+		return fmt.Errorf("failed to list timestamp columns: %w", err)
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.TableName + "." + col.ColumnName
+	}
+	if os.Getenv("DB_CONVERT_TIMESTAMPS") != "true" {
+		logger.Warning(fmt.Sprintf("Timestamp columns without a time zone: %s. Set DB_CONVERT_TIMESTAMPS=true to convert them to timestamptz",
+			strings.Join(names, ", ")))
+		return nil
+	}
+
+	zone := os.Getenv("DB_LEGACY_TIMEZONE")
+	if zone == "" {
+		zone = localtime.Zone().String()
+	}
+	for _, col := range columns {
+		// Identifiers come from the catalog and are quoted; the zone is a literal
+		sql := fmt.Sprintf(`ALTER TABLE %q ALTER COLUMN %q TYPE timestamptz USING %q AT TIME ZONE '%s'`,
+			col.TableName, col.ColumnName, col.ColumnName, strings.ReplaceAll(zone, "'", "''"))
+		if err := DB.Exec(sql).Error; err != nil {
+			return fmt.Errorf("failed to convert %s.%s to timestamptz: %w", col.TableName, col.ColumnName, err)
+		}
+	}
+	logger.Success(fmt.Sprintf("Converted %d timestamp columns to timestamptz, reading old values as %s", len(columns), zone))
+	return nil
+}
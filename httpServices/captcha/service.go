@@ -0,0 +1,115 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"passport-booking/config"
+	"passport-booking/logger"
+	"strings"
+	"time"
+)
+
+// Site verification endpoints; all three providers share the same form API
+var verifyURLs = map[string]string{
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+	"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+	"turnstile": "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+}
+
+// ErrMissingToken is returned when a challenge is required but the client sent no token
+var ErrMissingToken = errors.New("captcha token is required")
+
+// ErrRejected is returned when the provider does not accept the token
+var ErrRejected = errors.New("captcha verification failed")
+
+// Verifier checks a challenge token solved by the client so the provider can be
+// swapped or stubbed
+type Verifier interface {
+	// Provider names the provider, reported to clients so they render the right widget
+	Provider() string
+	// SiteKey is the public key clients render the widget with
+	SiteKey() string
+	Verify(token, remoteIP string) error
+}
+
+// SiteVerifier verifies tokens against a provider's siteverify endpoint
+type SiteVerifier struct {
+	client    *http.Client
+	provider  string
+	verifyURL string
+	secret    string
+	siteKey   string
+}
+
+// NewVerifier picks the provider from CAPTCHA_PROVIDER (recaptcha, hcaptcha or
+// turnstile) with its key in the CAPTCHA_SECRET secret and CAPTCHA_SITE_KEY. It returns
+// nil when no provider is configured, which switches challenges off.
+func NewVerifier() Verifier {
+	provider := strings.ToLower(strings.TrimSpace(os.Getenv("CAPTCHA_PROVIDER")))
+	if provider == "" || provider == "none" {
+		return nil
+	}
+
+	verifyURL, ok := verifyURLs[provider]
+	if !ok {
+		logger.Warning(fmt.Sprintf("Unknown CAPTCHA_PROVIDER %q, captcha challenges are disabled", provider))
+		return nil
+	}
+	secret := config.Secret("CAPTCHA_SECRET")
+	if secret == "" {
+		logger.Warning("CAPTCHA_SECRET is not set, captcha challenges are disabled")
+		return nil
+	}
+
+	return &SiteVerifier{
+		client:    &http.Client{Timeout: 10 * time.Second},
+		provider:  provider,
+		verifyURL: verifyURL,
+		secret:    secret,
+		siteKey:   os.Getenv("CAPTCHA_SITE_KEY"),
+	}
+}
+
+// Provider implements Verifier
+func (v *SiteVerifier) Provider() string { return v.provider }
+
+// SiteKey implements Verifier
+func (v *SiteVerifier) SiteKey() string { return v.siteKey }
+
+// Verify implements Verifier
+func (v *SiteVerifier) Verify(token, remoteIP string) error {
+	if strings.TrimSpace(token) == "" {
+		return ErrMissingToken
+	}
+
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	resp, err := v.client.PostForm(v.verifyURL, form)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", v.provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", v.provider, resp.StatusCode)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", v.provider, err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrRejected, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
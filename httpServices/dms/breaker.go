@@ -0,0 +1,181 @@
+package dms
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailures        = 5
+	defaultBreakerCooldownSeconds = 30
+)
+
+// ErrUnavailable is returned instead of calling DMS while the circuit is open or
+// degraded mode is switched on
+var ErrUnavailable = errors.New("DMS is unavailable, degraded mode is active")
+
+// Mode decides whether intake talks to DMS or queues for later
+type Mode string
+
+const (
+	// ModeAuto degrades while the circuit breaker is open
+	ModeAuto Mode = "auto"
+	// ModeDegraded always queues, e.g. during announced DMS maintenance
+	ModeDegraded Mode = "degraded"
+	// ModeNormal always calls DMS, ignoring the circuit breaker
+	ModeNormal Mode = "normal"
+)
+
+// ParseMode validates a mode name
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModeAuto, ModeDegraded, ModeNormal:
+		return m, nil
+	}
+	return "", fmt.Errorf("mode must be one of %s, %s or %s", ModeAuto, ModeDegraded, ModeNormal)
+}
+
+// Circuit states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// BreakerStatus is a snapshot of the breaker and mode switch
+type BreakerStatus struct {
+	Mode                Mode       `json:"mode"`
+	Circuit             string     `json:"circuit"`
+	Degraded            bool       `json:"degraded"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// breaker counts consecutive DMS failures across the process. After
+// DMS_BREAKER_FAILURES (default 5) it opens for DMS_BREAKER_COOLDOWN_SECONDS
+// (default 30), then lets calls through again: the first success closes it, a
+// failure opens it for another cooldown.
+type breaker struct {
+	mu        sync.Mutex
+	mode      Mode
+	failures  int
+	openedAt  *time.Time
+	retryAt   *time.Time
+	lastError string
+}
+
+var circuit = &breaker{mode: initialMode()}
+
+// initialMode reads DMS_MODE, defaulting to auto
+func initialMode() Mode {
+	if m, err := ParseMode(os.Getenv("DMS_MODE")); err == nil {
+		return m
+	}
+	return ModeAuto
+}
+
+func breakerEnvInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+func (b *breaker) state(now time.Time) string {
+	switch {
+	case b.retryAt == nil:
+		return CircuitClosed
+	case now.Before(*b.retryAt):
+		return CircuitOpen
+	default:
+		return CircuitHalfOpen
+	}
+}
+
+func (b *breaker) degraded(now time.Time) bool {
+	switch b.mode {
+	case ModeDegraded:
+		return true
+	case ModeNormal:
+		return false
+	}
+	return b.state(now) == CircuitOpen
+}
+
+// Degraded reports whether intake should queue DMS work instead of calling DMS
+func Degraded() bool {
+	circuit.mu.Lock()
+	defer circuit.mu.Unlock()
+	return circuit.degraded(time.Now())
+}
+
+// Allow returns ErrUnavailable when DMS must not be called right now
+func Allow() error {
+	if Degraded() {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// Record feeds the outcome of a DMS call into the breaker. Transport errors and 5xx
+// answers count as failures; any other answer means DMS is up.
+func Record(err error, statusCode int) {
+	circuit.mu.Lock()
+	defer circuit.mu.Unlock()
+
+	if err == nil && statusCode < 500 {
+		circuit.failures = 0
+		circuit.openedAt = nil
+		circuit.retryAt = nil
+		circuit.lastError = ""
+		return
+	}
+
+	now := time.Now()
+	circuit.failures++
+	if err != nil {
+		circuit.lastError = err.Error()
+	} else {
+		circuit.lastError = fmt.Sprintf("DMS returned status %d", statusCode)
+	}
+
+	// A failed probe while half open re-opens right away
+	if circuit.state(now) == CircuitHalfOpen || circuit.failures >= breakerEnvInt("DMS_BREAKER_FAILURES", defaultBreakerFailures) {
+		retryAt := now.Add(time.Duration(breakerEnvInt("DMS_BREAKER_COOLDOWN_SECONDS", defaultBreakerCooldownSeconds)) * time.Second)
+		if circuit.openedAt == nil {
+			circuit.openedAt = &now
+		}
+		circuit.retryAt = &retryAt
+	}
+}
+
+// SetMode switches between auto, forced degraded and forced normal operation. The
+// switch is per process and falls back to DMS_MODE on restart.
+func SetMode(m Mode) {
+	circuit.mu.Lock()
+	defer circuit.mu.Unlock()
+	circuit.mode = m
+}
+
+// Status returns the current breaker state and mode
+func Status() BreakerStatus {
+	circuit.mu.Lock()
+	defer circuit.mu.Unlock()
+	now := time.Now()
+	return BreakerStatus{
+		Mode:                circuit.mode,
+		Circuit:             circuit.state(now),
+		Degraded:            circuit.degraded(now),
+		ConsecutiveFailures: circuit.failures,
+		OpenedAt:            circuit.openedAt,
+		RetryAt:             circuit.retryAt,
+		LastError:           circuit.lastError,
+	}
+}
@@ -0,0 +1,124 @@
+package dms
+
+// The structs below are the exact JSON shapes DMS expects. Field names are
+// part of the external contract and must not be "corrected" (for example
+// recieve_all, hnddevice and isCharge are spelled the way DMS reads them).
+// TestSchemaGolden pins each payload against testdata/*.golden.
+
+// Address represents a sender or receiver block in a book article payload
+type Address struct {
+	AddressType   string `json:"address_type"`
+	Country       string `json:"country"`
+	District      string `json:"district"`
+	Division      string `json:"division"`
+	PhoneNumber   string `json:"phone_number"`
+	PoliceStation string `json:"police_station"`
+	PostOffice    string `json:"post_office"`
+	StreetAddress string `json:"street_address"`
+	UserUUID      string `json:"user_uuid"`
+	Username      string `json:"username"`
+	Zone          string `json:"zone"`
+}
+
+// BookArticleRequest represents the payload for /dms/book/article/ sent for passport bookings
+type BookArticleRequest struct {
+	FromNumber      string  `json:"form_number"`
+	AdPodID         string  `json:"ad_pod_id"`
+	ArticleDesc     string  `json:"article_desc"`
+	ArticlePrice    int     `json:"article_price"`
+	Barcode         string  `json:"barcode"`
+	CityPostStatus  string  `json:"city_post_status"`
+	DeliveryBranch  string  `json:"delivery_branch"`
+	EmtsBranchCode  string  `json:"emts_branch_code"`
+	Height          int     `json:"height"`
+	HndDevice       string  `json:"hnd_device"`
+	ImagePod        string  `json:"image_pod"`
+	ImageSrc        string  `json:"image_src"`
+	InsurancePrice  string  `json:"insurance_price"`
+	IsBulkMail      string  `json:"is_bulk_mail"`
+	IsCharge        string  `json:"is_charge"`
+	IsCityPost      string  `json:"is_city_post"`
+	IsInternational bool    `json:"is_international"`
+	IsStation       string  `json:"is_station"`
+	Length          int     `json:"length"`
+	ServiceName     string  `json:"service_name"`
+	SetAd           string  `json:"set_ad"`
+	VasType         string  `json:"vas_type"`
+	VpAmount        string  `json:"vp_amount"`
+	VpService       string  `json:"vp_service"`
+	Weight          int     `json:"weight"`
+	Width           int     `json:"width"`
+	Receiver        Address `json:"receiver"`
+	Sender          Address `json:"sender"`
+}
+
+// ParcelBookArticleRequest represents the payload for /dms/book/article/ sent for parcel bookings
+type ParcelBookArticleRequest struct {
+	AdPodID         string  `json:"ad_pod_id"`
+	ArticleDesc     string  `json:"article_desc"`
+	ArticlePrice    int     `json:"article_price"`
+	Barcode         string  `json:"barcode"`
+	CityPostStatus  string  `json:"city_post_status"`
+	DeliveryBranch  string  `json:"delivery_branch"`
+	EmtsBranchCode  string  `json:"emts_branch_code"`
+	Height          int     `json:"height"`
+	HndDevice       string  `json:"hnddevice"`
+	ImagePod        string  `json:"image_pod"`
+	ImageSrc        string  `json:"image_src"`
+	InsurancePrice  string  `json:"insurance_price"`
+	IsBulkMail      string  `json:"is_bulk_mail"`
+	IsCharge        string  `json:"isCharge"`
+	IsCityPost      string  `json:"is_city_post"`
+	IsInternational bool    `json:"is_international"`
+	IsStation       string  `json:"isStation"`
+	Length          int     `json:"length"`
+	Receiver        Address `json:"receiver"`
+	Sender          Address `json:"sender"`
+	ServiceName     string  `json:"service_name"`
+	SetAd           string  `json:"set_ad"`
+	VasType         string  `json:"vas_type"`
+	VpAmount        string  `json:"vp_amount"`
+	VpService       string  `json:"vp_service"`
+	Weight          int     `json:"weight"`
+	Width           int     `json:"width"`
+}
+
+// AddArticleRequest represents the payload for /rms/bag/add-article/
+type AddArticleRequest struct {
+	BagType string `json:"bag_type"`
+	BagID   string `json:"bag_id"`
+	Index   int    `json:"index"`
+	ItemID  string `json:"item_id"`
+}
+
+// ReceiveBagRequest represents the payload for /rms/receive-bag/
+type ReceiveBagRequest struct {
+	BagID           string `json:"bag_id"`
+	RecvInstruction string `json:"recv_instruction"`
+	LineID          string `json:"line_id"`
+	ReceiveItems    string `json:"receive_items"`
+}
+
+// ReceiveBagItemRequest represents the payload for /rms/receive-bag-item/
+type ReceiveBagItemRequest struct {
+	BagID      string `json:"bag_id"`
+	ItemID     string `json:"item_id"`
+	ReceiveAll string `json:"recieve_all"`
+}
+
+// DeliverArticleRequest represents the payload for /dms/deliver/article/
+type DeliverArticleRequest struct {
+	ArticleID string `json:"article_id"`
+}
+
+// RerouteArticleRequest represents the payload for /dms/reroute/article/
+type RerouteArticleRequest struct {
+	ArticleID      string  `json:"article_id"`
+	DeliveryBranch string  `json:"delivery_branch"`
+	Receiver       Address `json:"receiver"`
+}
+
+// GetBarcodeRequest represents the payload for /dms/api/get-barcode/
+type GetBarcodeRequest struct {
+	ServiceType string `json:"service_type"`
+}
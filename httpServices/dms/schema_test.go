@@ -0,0 +1,153 @@
+package dms
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func sampleAddress(addressType string) Address {
+	return Address{
+		AddressType:   addressType,
+		Country:       "Bangladesh",
+		District:      "Dhaka",
+		Division:      "Dhaka",
+		PhoneNumber:   "+8801700000000",
+		PoliceStation: "Mirpur",
+		PostOffice:    "Mirpur",
+		StreetAddress: "House 12, Road 5",
+		UserUUID:      "00000000-0000-4000-8000-000000000001",
+		Username:      "operator",
+		Zone:          "Dhaka North",
+	}
+}
+
+// TestSchemaGolden pins the JSON DMS receives for every request. A diff here is a
+// change to the external contract: field names such as recieve_all, hnddevice and
+// isCharge are spelled the way DMS reads them. Regenerate with
+// go test ./httpServices/dms -run TestSchemaGolden -update only when DMS changed.
+func TestSchemaGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload interface{}
+	}{
+		{"book_article", BookArticleRequest{
+			FromNumber:      "OID1234567890",
+			AdPodID:         "ad-pod",
+			ArticleDesc:     "Passport",
+			ArticlePrice:    100,
+			Barcode:         "EB123456789BD",
+			CityPostStatus:  "no",
+			DeliveryBranch:  "1216",
+			EmtsBranchCode:  "1207",
+			Height:          1,
+			HndDevice:       "web",
+			ImagePod:        "pod.png",
+			ImageSrc:        "src.png",
+			InsurancePrice:  "0",
+			IsBulkMail:      "no",
+			IsCharge:        "yes",
+			IsCityPost:      "no",
+			IsInternational: false,
+			IsStation:       "no",
+			Length:          2,
+			ServiceName:     "passport",
+			SetAd:           "no",
+			VasType:         "none",
+			VpAmount:        "0",
+			VpService:       "no",
+			Weight:          3,
+			Width:           4,
+			Receiver:        sampleAddress("receiver"),
+			Sender:          sampleAddress("sender"),
+		}},
+		{"parcel_book_article", ParcelBookArticleRequest{
+			AdPodID:         "ad-pod",
+			ArticleDesc:     "Parcel",
+			ArticlePrice:    100,
+			Barcode:         "EP123456789BD",
+			CityPostStatus:  "no",
+			DeliveryBranch:  "1216",
+			EmtsBranchCode:  "1207",
+			Height:          1,
+			HndDevice:       "web",
+			ImagePod:        "pod.png",
+			ImageSrc:        "src.png",
+			InsurancePrice:  "0",
+			IsBulkMail:      "no",
+			IsCharge:        "yes",
+			IsCityPost:      "no",
+			IsInternational: false,
+			IsStation:       "no",
+			Length:          2,
+			Receiver:        sampleAddress("receiver"),
+			Sender:          sampleAddress("sender"),
+			ServiceName:     "parcel",
+			SetAd:           "no",
+			VasType:         "none",
+			VpAmount:        "0",
+			VpService:       "no",
+			Weight:          3,
+			Width:           4,
+		}},
+		{"add_article", AddArticleRequest{BagType: "passport", BagID: "BAG00000001", Index: 1, ItemID: "EB123456789BD"}},
+		{"receive_bag", ReceiveBagRequest{BagID: "BAG00000001", RecvInstruction: "all", LineID: "LINE-1", ReceiveItems: "EB123456789BD,EB123456790BD"}},
+		{"receive_bag_item", ReceiveBagItemRequest{BagID: "BAG00000001", ItemID: "EB123456789BD", ReceiveAll: "no"}},
+		{"deliver_article", DeliverArticleRequest{ArticleID: "EB123456789BD"}},
+		{"reroute_article", RerouteArticleRequest{ArticleID: "EB123456789BD", DeliveryBranch: "1216", Receiver: sampleAddress("receiver")}},
+		{"get_barcode", GetBarcodeRequest{ServiceType: "passport"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(tt.payload, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("missing golden file, run with -update: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s payload changed, the DMS contract is broken\ngot:\n%s\nwant:\n%s", tt.name, got, want)
+			}
+		})
+	}
+}
+
+// BenchmarkReceiveBagPayload500 encodes the DMS receive request of a 500 item bag
+func BenchmarkReceiveBagPayload500(b *testing.B) {
+	items := make([]string, 500)
+	for i := range items {
+		items[i] = fmt.Sprintf("EB%09dBD", i)
+	}
+	receiveItems := strings.Join(items, ",")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(ReceiveBagRequest{
+			BagID:           "LT-BAG-000000-000",
+			RecvInstruction: "all",
+			LineID:          "LT-LINE",
+			ReceiveItems:    receiveItems,
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package dms
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"passport-booking/config"
+	"passport-booking/services/chaos"
+	"strings"
+	"time"
+)
+
+// ErrBaseURLNotSet is returned when DMS_BASE_URL is missing from the environment
+var ErrBaseURLNotSet = errors.New("DMS_BASE_URL environment variable is not set")
+
+// Client is the DMS surface used by the delivery flow
+type Client interface {
+	ReceiveBagItem(authHeader string, req ReceiveBagItemRequest) (*Response, error)
+	DeliverArticle(authHeader string, req DeliverArticleRequest) (*Response, error)
+	RerouteArticle(authHeader string, req RerouteArticleRequest) (*Response, error)
+	GetBarcode(authHeader string, req GetBarcodeRequest) (string, error)
+	BookParcelArticle(authHeader string, req ParcelBookArticleRequest) (*Response, error)
+}
+
+// Response holds the raw status and body returned by DMS
+type Response struct {
+	StatusCode int
+	Body       []byte
+}
+
+// DMSService calls the DMS HTTP API
+type DMSService struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewDMSService creates a new DMS service using DMS_BASE_URL
+func NewDMSService() *DMSService {
+	return &DMSService{
+		client:  NewHTTPClient(30 * time.Second),
+		baseURL: strings.TrimRight(config.Secret("DMS_BASE_URL"), "/"),
+	}
+}
+
+// NewHTTPClient returns the client every DMS call goes through. Its transport lets
+// staging inject DMS failures; see services/chaos.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: chaos.DMSTransport(http.DefaultTransport),
+	}
+}
+
+// ReceiveBagItem marks an item inside a bag as received
+func (s *DMSService) ReceiveBagItem(authHeader string, req ReceiveBagItemRequest) (*Response, error) {
+	return s.post("/rms/receive-bag-item/", authHeader, req)
+}
+
+// DeliverArticle marks an article as delivered
+func (s *DMSService) DeliverArticle(authHeader string, req DeliverArticleRequest) (*Response, error) {
+	return s.post("/dms/deliver/article/", authHeader, req)
+}
+
+// RerouteArticle moves a booked article to another delivery branch and receiver address
+func (s *DMSService) RerouteArticle(authHeader string, req RerouteArticleRequest) (*Response, error) {
+	return s.post("/dms/reroute/article/", authHeader, req)
+}
+
+// BookParcelArticle books a parcel booking article
+func (s *DMSService) BookParcelArticle(authHeader string, req ParcelBookArticleRequest) (*Response, error) {
+	return s.post("/dms/book/article/", authHeader, req)
+}
+
+// AddArticle adds a booked article to a bag
+func (s *DMSService) AddArticle(authHeader string, req AddArticleRequest) (*Response, error) {
+	return s.post("/rms/bag/add-article/", authHeader, req)
+}
+
+// ServiceAuthHeader is the Authorization header background jobs use to call DMS
+// without a user request; empty when DMS_SERVICE_TOKEN is not configured
+func ServiceAuthHeader() string {
+	// Re-read per call so a rotated token is picked up
+	if token := config.Secret("DMS_SERVICE_TOKEN"); token != "" {
+		return "Bearer " + token
+	}
+	return ""
+}
+
+// GetBarcode asks DMS to allocate a new article barcode
+func (s *DMSService) GetBarcode(authHeader string, req GetBarcodeRequest) (string, error) {
+	resp, err := s.post("/dms/api/get-barcode/", authHeader, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call barcode API: %w", err)
+	}
+
+	// Accept both 200 and 201 as success status codes
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("barcode API returned status %d: %s", resp.StatusCode, string(resp.Body))
+	}
+
+	var barcodeResp map[string]interface{}
+	if err := json.Unmarshal(resp.Body, &barcodeResp); err != nil {
+		return "", fmt.Errorf("failed to parse barcode response: %v", err)
+	}
+
+	barcode, ok := barcodeResp["barcode"].(string)
+	if !ok {
+		return "", fmt.Errorf("barcode not found in response")
+	}
+
+	return barcode, nil
+}
+
+func (s *DMSService) post(path, authHeader string, payload interface{}) (*Response, error) {
+	if s.baseURL == "" {
+		return nil, ErrBaseURLNotSet
+	}
+	if err := Allow(); err != nil {
+		return nil, err
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", s.baseURL+path, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", authHeader)
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		Record(err, 0)
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		Record(err, 0)
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	Record(nil, resp.StatusCode)
+
+	return &Response{StatusCode: resp.StatusCode, Body: body}, nil
+}
@@ -0,0 +1,177 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"passport-booking/config"
+	"passport-booking/logger"
+	"strings"
+	"time"
+)
+
+// Attachment is a file sent along with a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is one outgoing email
+type Message struct {
+	To          string
+	Subject     string
+	Text        string
+	Attachments []Attachment
+}
+
+// Sender is the email surface consumed by other services so the provider can be
+// swapped or stubbed
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTPSender delivers mail through an SMTP relay with STARTTLS. Amazon SES is used
+// through its SMTP interface.
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// NewSender picks the provider from EMAIL_PROVIDER:
+//   - smtp uses SMTP_HOST, SMTP_PORT (587), SMTP_USERNAME and SMTP_PASSWORD
+//   - ses uses the SES SMTP endpoint of AWS_REGION with SES_SMTP_USERNAME and SES_SMTP_PASSWORD
+//
+// Anything else returns a LogSender so environments without mail only log messages.
+// EMAIL_FROM sets the sender address.
+func NewSender() Sender {
+	from := os.Getenv("EMAIL_FROM")
+	if from == "" {
+		from = "no-reply@ekdak.com"
+	}
+
+	switch strings.ToLower(os.Getenv("EMAIL_PROVIDER")) {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPSender{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: config.Secret("SMTP_USERNAME"),
+			Password: config.Secret("SMTP_PASSWORD"),
+			From:     from,
+		}
+	case "ses":
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = "ap-south-1"
+		}
+		return &SMTPSender{
+			Host:     fmt.Sprintf("email-smtp.%s.amazonaws.com", region),
+			Port:     "587",
+			Username: config.Secret("SES_SMTP_USERNAME"),
+			Password: config.Secret("SES_SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+	return LogSender{}
+}
+
+// Send implements Sender
+func (s *SMTPSender) Send(msg Message) error {
+	if s.Host == "" {
+		return fmt.Errorf("SMTP host is not configured")
+	}
+	body, err := buildMIME(s.From, msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	if err := smtp.SendMail(net.JoinHostPort(s.Host, s.Port), auth, s.From, []string{msg.To}, body); err != nil {
+		logger.Error(fmt.Sprintf("Failed to send email to %s", msg.To), err)
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	logger.Info(fmt.Sprintf("Email sent successfully to %s", msg.To))
+	return nil
+}
+
+// LogSender only logs messages, for environments without a mail provider
+type LogSender struct{}
+
+// Send implements Sender
+func (LogSender) Send(msg Message) error {
+	logger.Info(fmt.Sprintf("Email provider not configured, skipping %q to %s (%d attachments)",
+		msg.Subject, msg.To, len(msg.Attachments)))
+	return nil
+}
+
+// buildMIME renders msg as a UTF-8 multipart/mixed message
+func buildMIME(from string, msg Message) ([]byte, error) {
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64(&b, []byte(msg.Text))
+
+	for _, a := range msg.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		fmt.Fprintf(&b, "Content-Type: %s; name=%q\r\n", contentType, a.Filename)
+		b.WriteString("Content-Transfer-Encoding: base64\r\n")
+		fmt.Fprintf(&b, "Content-Disposition: attachment; filename=%q\r\n\r\n", a.Filename)
+		writeBase64(&b, a.Data)
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+// writeBase64 writes data base64-encoded in 76 character lines
+func writeBase64(b *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76])
+		b.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	b.WriteString("\r\n")
+}
+
+func randomBoundary() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "pb-" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,86 @@
+package nid
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"passport-booking/config"
+	"strings"
+	"time"
+)
+
+// Client verifies a national ID against an external registry
+type Client interface {
+	Verify(req VerifyRequest) (*VerifyResult, error)
+}
+
+// VerifyRequest is what is sent to the NID verification API
+type VerifyRequest struct {
+	NID  string `json:"nid"`
+	Name string `json:"name"`
+}
+
+// VerifyResult is the registry's answer
+type VerifyResult struct {
+	Matched bool   `json:"matched"`
+	Message string `json:"message,omitempty"`
+}
+
+// NewClient returns the HTTP client when NID_VERIFY_BASE_URL is configured, otherwise
+// nil so callers fall back to format validation only
+func NewClient() Client {
+	baseURL := strings.TrimRight(config.Secret("NID_VERIFY_BASE_URL"), "/")
+	if baseURL == "" {
+		return nil
+	}
+	return &HTTPClient{
+		client:  &http.Client{Timeout: 15 * time.Second},
+		baseURL: baseURL,
+	}
+}
+
+// HTTPClient calls the NID verification HTTP API
+type HTTPClient struct {
+	client  *http.Client
+	baseURL string
+}
+
+// Verify posts the NID and holder name and reports whether the registry matched them
+func (s *HTTPClient) Verify(req VerifyRequest) (*VerifyResult, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", s.baseURL+"/verify", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	// Re-read per request so a rotated token is picked up
+	if token := config.Secret("NID_VERIFY_TOKEN"); token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("NID verification API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result VerifyResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse NID verification response: %w", err)
+	}
+	return &result, nil
+}
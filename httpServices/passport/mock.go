@@ -0,0 +1,58 @@
+package passport
+
+import (
+	"passport-booking/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockClient stands in for the passport system in development and staging. Every
+// application counts as dispatched unless Set says otherwise.
+type MockClient struct {
+	mu       sync.RWMutex
+	statuses map[string]string
+}
+
+// NewMockClient creates a mock; applications listed in PASSPORT_SYSTEM_MOCK_PENDING
+// (comma separated) are reported as still printing
+func NewMockClient() *MockClient {
+	m := &MockClient{statuses: make(map[string]string)}
+	for _, id := range strings.Split(config.Secret("PASSPORT_SYSTEM_MOCK_PENDING"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			m.statuses[id] = StatusPrinting
+		}
+	}
+	return m
+}
+
+// Set fixes the status reported for applicationID; an empty status means not found
+func (m *MockClient) Set(applicationID, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.statuses[applicationID] = status
+}
+
+// Status reports the status set for applicationID, or dispatched by default
+func (m *MockClient) Status(applicationID string) (*StatusResult, error) {
+	m.mu.RLock()
+	status, ok := m.statuses[applicationID]
+	m.mu.RUnlock()
+
+	if !ok {
+		status = StatusDispatched
+	}
+	if status == "" {
+		return nil, ErrNotFound
+	}
+
+	result := &StatusResult{ApplicationID: applicationID, Status: status, Message: "mock passport system"}
+	now := time.Now()
+	if status == StatusPrinted || status == StatusDispatched {
+		result.PrintedAt = &now
+	}
+	if status == StatusDispatched {
+		result.DispatchedAt = &now
+	}
+	return result, nil
+}
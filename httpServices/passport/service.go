@@ -0,0 +1,99 @@
+package passport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"passport-booking/config"
+	"strings"
+	"time"
+)
+
+// Issuance statuses reported by the passport system, in order
+const (
+	StatusEnrolled   = "enrolled"
+	StatusPrinting   = "printing"
+	StatusPrinted    = "printed"
+	StatusDispatched = "dispatched"
+)
+
+// ErrNotFound is returned when the passport system has no such application
+var ErrNotFound = errors.New("application not found in passport system")
+
+// Client asks the passport issuance system where an application stands
+type Client interface {
+	Status(applicationID string) (*StatusResult, error)
+}
+
+// StatusResult is the issuance state of one application
+type StatusResult struct {
+	ApplicationID string     `json:"application_id"`
+	Status        string     `json:"status"`
+	PrintedAt     *time.Time `json:"printed_at,omitempty"`
+	DispatchedAt  *time.Time `json:"dispatched_at,omitempty"`
+	Message       string     `json:"message,omitempty"`
+}
+
+// Dispatched reports whether the passport has been printed and sent out for delivery
+func (r *StatusResult) Dispatched() bool {
+	return r.Status == StatusDispatched
+}
+
+// NewClient returns the HTTP client when PASSPORT_SYSTEM_BASE_URL is configured, the
+// mock when PASSPORT_SYSTEM_MOCK is true and the profile allows mocks, otherwise nil so callers skip the check
+func NewClient() Client {
+	if baseURL := strings.TrimRight(config.Secret("PASSPORT_SYSTEM_BASE_URL"), "/"); baseURL != "" {
+		return &HTTPClient{
+			client:  &http.Client{Timeout: 10 * time.Second},
+			baseURL: baseURL,
+		}
+	}
+	if config.CurrentProfile().MockIntegrations && strings.EqualFold(config.Secret("PASSPORT_SYSTEM_MOCK"), "true") {
+		return NewMockClient()
+	}
+	return nil
+}
+
+// HTTPClient calls the passport system's status API
+type HTTPClient struct {
+	client  *http.Client
+	baseURL string
+}
+
+// Status fetches the issuance state of applicationID
+func (s *HTTPClient) Status(applicationID string) (*StatusResult, error) {
+	httpReq, err := http.NewRequest("GET", s.baseURL+"/applications/"+url.PathEscape(applicationID)+"/status", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// Re-read per request so a rotated token is picked up
+	if token := config.Secret("PASSPORT_SYSTEM_TOKEN"); token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("passport system returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result StatusResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse passport system response: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,232 @@
+package push
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"passport-booking/config"
+	"passport-booking/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmSendURL  = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+	googleToken = "https://oauth2.googleapis.com/token"
+)
+
+// ErrInvalidToken is returned when the provider reports the device token as no
+// longer registered, so callers can drop it
+var ErrInvalidToken = errors.New("push token is not registered")
+
+// Message is one push to a single device
+type Message struct {
+	Token string
+	Title string
+	Body  string
+	Data  map[string]string // delivered to the app alongside the notification
+}
+
+// Sender is the push surface consumed by other services so the provider can be
+// swapped or stubbed
+type Sender interface {
+	Send(msg Message) error
+}
+
+// serviceAccount holds the fields of a Firebase service account key used to mint
+// access tokens
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCMSender delivers pushes through the Firebase Cloud Messaging HTTP v1 API
+type FCMSender struct {
+	client    *http.Client
+	projectID string
+	email     string
+	key       *rsa.PrivateKey
+	tokenURI  string
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewSender picks the provider from PUSH_PROVIDER. fcm reads the service account key
+// from the FCM_SERVICE_ACCOUNT_JSON secret; FCM_PROJECT_ID overrides its project.
+// Anything else, or an unusable key, returns a LogSender so environments without push
+// only log messages.
+func NewSender() Sender {
+	if strings.ToLower(os.Getenv("PUSH_PROVIDER")) != "fcm" {
+		return LogSender{}
+	}
+
+	sender, err := NewFCMSender(config.Secret("FCM_SERVICE_ACCOUNT_JSON"), os.Getenv("FCM_PROJECT_ID"))
+	if err != nil {
+		logger.Error("Failed to configure FCM, push notifications will only be logged", err)
+		return LogSender{}
+	}
+	return sender
+}
+
+// NewFCMSender creates a sender from a service account key. projectID overrides the
+// project named in the key when set.
+func NewFCMSender(serviceAccountJSON, projectID string) (*FCMSender, error) {
+	if serviceAccountJSON == "" {
+		return nil, fmt.Errorf("FCM service account key is not configured")
+	}
+
+	var account serviceAccount
+	if err := json.Unmarshal([]byte(serviceAccountJSON), &account); err != nil {
+		return nil, fmt.Errorf("invalid FCM service account key: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid FCM service account private key: %w", err)
+	}
+	if projectID == "" {
+		projectID = account.ProjectID
+	}
+	if projectID == "" || account.ClientEmail == "" {
+		return nil, fmt.Errorf("FCM service account key is missing project_id or client_email")
+	}
+	tokenURI := account.TokenURI
+	if tokenURI == "" {
+		tokenURI = googleToken
+	}
+
+	return &FCMSender{
+		client:    &http.Client{Timeout: 30 * time.Second},
+		projectID: projectID,
+		email:     account.ClientEmail,
+		key:       key,
+		tokenURI:  tokenURI,
+	}, nil
+}
+
+// token returns a cached OAuth access token, exchanging a freshly signed assertion
+// when the cached one is about to expire
+func (s *FCMSender) token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && time.Now().Before(s.expiresAt.Add(-time.Minute)) {
+		return s.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.email,
+		"scope": fcmScope,
+		"aud":   s.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign FCM token assertion: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	resp, err := s.client.PostForm(s.tokenURI, form)
+	if err != nil {
+		return "", fmt.Errorf("failed to request FCM access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("FCM token endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", fmt.Errorf("failed to decode FCM access token: %w", err)
+	}
+
+	s.accessToken = tokenResp.AccessToken
+	s.expiresAt = now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	return s.accessToken, nil
+}
+
+// Send implements Sender
+func (s *FCMSender) Send(msg Message) error {
+	accessToken, err := s.token()
+	if err != nil {
+		logger.Error("Failed to obtain FCM access token", err)
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"message": map[string]interface{}{
+			"token": msg.Token,
+			"notification": map[string]string{
+				"title": msg.Title,
+				"body":  msg.Body,
+			},
+			"data": msg.Data,
+			"android": map[string]string{
+				"priority": "high",
+			},
+		},
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal push message: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf(fcmSendURL, s.projectID), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		logger.Error("Failed to send push notification", err)
+		return fmt.Errorf("failed to send push notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusOK {
+		logger.Info("Push notification sent successfully")
+		return nil
+	}
+
+	// FCM answers 404 UNREGISTERED (or 400 for a malformed token) once the app is
+	// uninstalled or the token rotated
+	if resp.StatusCode == http.StatusNotFound || strings.Contains(string(body), "UNREGISTERED") ||
+		(resp.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "registration token")) {
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("FCM returned status %d: %s", resp.StatusCode, string(body))
+}
+
+// LogSender only logs messages, for environments without a push provider
+type LogSender struct{}
+
+// Send implements Sender
+func (LogSender) Send(msg Message) error {
+	logger.Info(fmt.Sprintf("Push provider not configured, skipping %q", msg.Title))
+	return nil
+}
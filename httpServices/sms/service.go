@@ -6,11 +6,19 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"passport-booking/config"
 	"passport-booking/logger"
 	"time"
 )
 
+// Sender is the SMS surface consumed by other services so they can be
+// exercised without hitting the message broker
+type Sender interface {
+	SendSMS(phoneNumber, message string) (*SMSResponse, error)
+	SendOTP(phoneNumber, otpCode string) error
+	SendDeliveryNotification(phoneNumber, bookingID string) error
+}
+
 // SMSService handles SMS operations
 type SMSService struct {
 	client    *http.Client
@@ -33,12 +41,12 @@ type SMSResponse struct {
 
 // NewSMSService creates a new SMS service
 func NewSMSService() *SMSService {
-	apiURL := os.Getenv("SMS_API_URL")
+	apiURL := config.Secret("SMS_API_URL")
 	if apiURL == "" {
 		apiURL = "https://ekdak.com/message-broker/send-sms/" // Default URL
 	}
 
-	authToken := os.Getenv("SMS_AUTH_TOKEN")
+	authToken := config.Secret("SMS_AUTH_TOKEN")
 	if authToken == "" {
 		authToken = "Token 8d3690ef76134d9abd78f9cbde655dd46446a032" // Default token
 	}
@@ -76,7 +84,7 @@ func (s *SMSService) SendSMS(phoneNumber, message string) (*SMSResponse, error)
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", s.authToken)
+	req.Header.Set("Authorization", s.currentAuthToken())
 
 	// Make the request
 	resp, err := s.client.Do(req)
@@ -141,3 +149,11 @@ func (s *SMSService) SendDeliveryNotification(phoneNumber, bookingID string) err
 	logger.Info(fmt.Sprintf("Delivery notification sent successfully to %s", phoneNumber))
 	return nil
 }
+
+// currentAuthToken re-reads SMS_AUTH_TOKEN so a rotated token is used without a restart
+func (s *SMSService) currentAuthToken() string {
+	if token := config.Secret("SMS_AUTH_TOKEN"); token != "" {
+		return token
+	}
+	return s.authToken
+}
@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
+	"passport-booking/config"
 	"passport-booking/types"
 	"time"
 )
@@ -130,7 +130,6 @@ func (c *SSOClient) RequestDMSLoginUser(req types.LoginDMSRequest) (*types.Login
 
 func (c *SSOClient) RequestRegisterUser(req types.RegisterUserRequest) (*types.RegisterUserResponse, error) {
 	body, err := json.Marshal(req)
-	fmt.Printf("Request Register User: %s\n", string(body))
 	if err != nil {
 		return nil, err
 	}
@@ -146,10 +145,9 @@ func (c *SSOClient) RequestRegisterUser(req types.RegisterUserRequest) (*types.R
 	// Only set Authorization header if Access token is provided and not empty
 	if req.Access != "" {
 		authHeader := "Bearer " + req.Access
-		fmt.Printf("Setting Authorization header: '%s'\n", authHeader)
 		httpReq.Header.Set("Authorization", authHeader)
 	} else {
-		fmt.Println("No Access token provided, making request without Authorization header")
+		config.Debugf("No Access token provided, making request without Authorization header")
 	}
 
 	resp, err := c.httpClient.Do(httpReq)
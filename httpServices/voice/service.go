@@ -0,0 +1,93 @@
+package voice
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"passport-booking/config"
+	"passport-booking/logger"
+	"strings"
+	"time"
+)
+
+// Caller is the voice OTP surface consumed by other services so the provider can be
+// swapped or stubbed
+type Caller interface {
+	CallOTP(phoneNumber, otpCode string) error
+}
+
+// HTTPCaller places text-to-speech calls through a voice gateway that accepts a JSON
+// POST with the number and the text to read out
+type HTTPCaller struct {
+	client    *http.Client
+	apiURL    string
+	authToken string
+}
+
+// CallRequest represents the voice call request payload
+type CallRequest struct {
+	PhoneNumber string `json:"phone_number"`
+	Text        string `json:"text"`
+	Repeat      int    `json:"repeat"`
+}
+
+// NewCaller picks the provider from VOICE_PROVIDER. http calls VOICE_API_URL with the
+// VOICE_AUTH_TOKEN secret. Anything else returns nil, which disables voice OTPs.
+func NewCaller() Caller {
+	if strings.ToLower(os.Getenv("VOICE_PROVIDER")) != "http" {
+		return nil
+	}
+
+	apiURL := config.Secret("VOICE_API_URL")
+	if apiURL == "" {
+		logger.Error("VOICE_PROVIDER is http but VOICE_API_URL is not set, voice OTPs are disabled", nil)
+		return nil
+	}
+
+	return &HTTPCaller{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		apiURL:    apiURL,
+		authToken: config.Secret("VOICE_AUTH_TOKEN"),
+	}
+}
+
+// CallOTP calls phoneNumber and reads otpCode out digit by digit, twice
+func (v *HTTPCaller) CallOTP(phoneNumber, otpCode string) error {
+	digits := strings.Join(strings.Split(otpCode, ""), ", ")
+	payload, err := json.Marshal(CallRequest{
+		PhoneNumber: phoneNumber,
+		Text:        fmt.Sprintf("Your passport delivery verification code is %s. Please do not share this code with anyone.", digits),
+		Repeat:      2,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal voice call request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", v.apiURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create voice call request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if v.authToken != "" {
+		req.Header.Set("Authorization", v.authToken)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send voice call request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("voice API returned error status: %d, message: %s", resp.StatusCode, string(body))
+	}
+
+	logger.Info(fmt.Sprintf("Voice OTP call placed to %s", phoneNumber))
+	return nil
+}
@@ -0,0 +1,32 @@
+// Bag receive: each iteration receives one seeded bag of 500 items, which updates every
+// booking in it. The token's account needs the postman or post office permission.
+import http from 'k6/http';
+import { check } from 'k6';
+import exec from 'k6/execution';
+import { baseURL, params, fixtures } from './common.js';
+
+export const options = {
+  scenarios: {
+    receive: {
+      executor: 'shared-iterations',
+      vus: 4,
+      iterations: fixtures.bag_ids.length,
+      maxDuration: '10m',
+    },
+  },
+  thresholds: {
+    http_req_failed: ['rate<0.01'],
+    http_req_duration: ['p(95)<3000'],
+  },
+};
+
+export default function () {
+  const bagID = fixtures.bag_ids[exec.scenario.iterationInTest];
+  const res = http.post(`${baseURL}/api/bag/receive`, JSON.stringify({
+    bag_id: bagID,
+    recv_instruction: 'all',
+    line_id: fixtures.line_id,
+    receive_items: '',
+  }), Object.assign({ timeout: '60s' }, params));
+  check(res, { 'bag received': (r) => r.status === 200 });
+}
@@ -0,0 +1,35 @@
+// Booking create: each iteration books one seeded slip parse. The token's account
+// needs the agent or customer permission.
+import http from 'k6/http';
+import { check } from 'k6';
+import exec from 'k6/execution';
+import { baseURL, params, fixtures } from './common.js';
+
+export const options = {
+  scenarios: {
+    create: {
+      executor: 'shared-iterations',
+      vus: 20,
+      iterations: Math.min(fixtures.request_ids.length, 1000),
+      maxDuration: '5m',
+    },
+  },
+  thresholds: {
+    http_req_failed: ['rate<0.01'],
+    http_req_duration: ['p(95)<400', 'p(99)<800'],
+  },
+};
+
+export default function () {
+  const requestID = fixtures.request_ids[exec.scenario.iterationInTest];
+  const res = http.post(`${baseURL}/api/booking/create`, JSON.stringify({
+    request_id: requestID,
+    delivery_branch_code: 'LT-OFFICE',
+    division: 'Dhaka',
+    district: 'Dhaka',
+    police_station: 'Gulshan',
+    post_office: 'Gulshan',
+    street_address: 'Load test address',
+  }), params);
+  check(res, { 'booking created': (r) => r.status === 200 || r.status === 201 });
+}
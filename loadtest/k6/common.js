@@ -0,0 +1,22 @@
+// Shared settings of the load-test scenarios. Run them against a staging instance
+// whose DMS_BASE_URL points at `go run ./cmd/loadtest dms-stub`.
+//
+//   BASE_URL  service under test, default http://localhost:8004
+//   TOKEN     bearer token of the account fixtures were seeded for
+//   FIXTURES  path of the seed output, default ../fixtures.json
+import { fail } from 'k6';
+
+export const baseURL = __ENV.BASE_URL || 'http://localhost:8004';
+
+if (!__ENV.TOKEN) {
+  fail('TOKEN is required');
+}
+
+export const params = {
+  headers: {
+    Authorization: `Bearer ${__ENV.TOKEN}`,
+    'Content-Type': 'application/json',
+  },
+};
+
+export const fixtures = JSON.parse(open(__ENV.FIXTURES || '../fixtures.json'));
@@ -0,0 +1,37 @@
+// Tracking reads: a steady mix of fresh reads and conditional reads that should be
+// answered with 304 from the ETag.
+import http from 'k6/http';
+import { check } from 'k6';
+import { baseURL, params, fixtures } from './common.js';
+
+export const options = {
+  scenarios: {
+    track: {
+      executor: 'constant-arrival-rate',
+      rate: 200,
+      timeUnit: '1s',
+      duration: '2m',
+      preAllocatedVUs: 50,
+      maxVUs: 200,
+    },
+  },
+  thresholds: {
+    http_req_failed: ['rate<0.01'],
+    http_req_duration: ['p(95)<150', 'p(99)<300'],
+  },
+};
+
+const etags = {};
+
+export default function () {
+  const barcode = fixtures.barcodes[Math.floor(Math.random() * fixtures.barcodes.length)];
+  const headers = Object.assign({}, params.headers);
+  if (etags[barcode] && Math.random() < 0.5) {
+    headers['If-None-Match'] = etags[barcode];
+  }
+  const res = http.get(`${baseURL}/api/booking/track/${barcode}`, { headers });
+  check(res, { 'tracking read': (r) => r.status === 200 || r.status === 304 });
+  if (res.headers.Etag) {
+    etags[barcode] = res.headers.Etag;
+  }
+}
@@ -35,6 +35,7 @@ func (logger *AsyncLogger) ProcessLog() {
 			RequestHeaders:  logEntry.RequestHeaders,
 			ResponseHeaders: logEntry.ResponseHeaders,
 			StatusCode:      logEntry.StatusCode,
+			ImpersonatedBy:  logEntry.ImpersonatedBy,
 			CreatedAt:       logEntry.CreatedAt,
 		}
 
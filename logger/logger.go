@@ -28,60 +28,60 @@ func init() {
 
 // ✅ সাকসেস লগ প্রিন্ট করার ফাংশন
 func Success(message string) {
-	log.Info("✅ " + message)
+	log.Info("✅ " + redact(message))
 }
 func Error(message string, err error) {
 	if err != nil {
-		log.Error("❌ " + message + ": " + err.Error())
+		log.Error("❌ " + redact(message+": "+err.Error()))
 	} else {
-		log.Error("❌ " + message)
+		log.Error("❌ " + redact(message))
 
 	}
 }
 func Warning(message string) {
-	log.Warn("⚠️ " + message)
+	log.Warn("⚠️ " + redact(message))
 }
 
 func Debug(message string) {
-	log.Debug("🐛 " + message)
+	log.Debug("🐛 " + redact(message))
 }
 
 func Info(message string) {
-	log.Info("ℹ️ " + message)
+	log.Info("ℹ️ " + redact(message))
 }
 
 func Fatal(message string) {
-	log.Fatal("💥 " + message)
+	log.Fatal("💥 " + redact(message))
 	os.Exit(1)
 }
 
 func Panic(message string) {
-	log.Panic("💥 " + message)
+	log.Panic("💥 " + redact(message))
 	os.Exit(1)
 }
 
 func Println(message string) {
-	log.Info("📝 " + message) // Use Info for general logging
+	log.Info("📝 " + redact(message)) // Use Info for general logging
 }
 
 func Printf(format string, args ...interface{}) {
-	log.Info(fmt.Sprintf("📝 "+format, args...)) // Use Info for general logging
+	log.Info("📝 " + redact(fmt.Sprintf(format, args...))) // Use Info for general logging
 
 }
 func Print(message string) {
-	log.Info("📝 " + message) // Use Info for general logging
+	log.Info("📝 " + redact(message)) // Use Info for general logging
 }
 func PrintfWithLevel(level log.Level, format string, args ...interface{}) {
 	switch level {
 	case log.LevelInfo:
-		log.Info(fmt.Sprintf("ℹ️ "+format, args...))
+		log.Info("ℹ️ " + redact(fmt.Sprintf(format, args...)))
 	case log.LevelError:
-		log.Error(fmt.Sprintf("❌ "+format, args...))
+		log.Error("❌ " + redact(fmt.Sprintf(format, args...)))
 	case log.LevelWarn:
-		log.Warn(fmt.Sprintf("⚠️ "+format, args...))
+		log.Warn("⚠️ " + redact(fmt.Sprintf(format, args...)))
 	case log.LevelDebug:
-		log.Debug(fmt.Sprintf("🐛 "+format, args...))
+		log.Debug("🐛 " + redact(fmt.Sprintf(format, args...)))
 	default:
-		log.Info(fmt.Sprintf("📝 "+format, args...)) // Default to Info for unknown levels
+		log.Info("📝 " + redact(fmt.Sprintf(format, args...))) // Default to Info for unknown levels
 	}
 }
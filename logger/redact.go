@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"regexp"
+	"sync/atomic"
+)
+
+// showPII is off unless the environment profile turns it on, so phone numbers are
+// masked even in messages logged before the profile is applied
+var showPII atomic.Bool
+
+// phonePattern matches Bangladeshi mobile numbers with or without the country code
+var phonePattern = regexp.MustCompile(`(?:\+88|\b88|\b)01[3-9]\d{8}\b`)
+
+// ShowPII switches masking of phone numbers in log messages off (true) or on (false)
+func ShowPII(show bool) {
+	showPII.Store(show)
+}
+
+// redact masks every phone number in message but its last three digits
+func redact(message string) string {
+	if showPII.Load() {
+		return message
+	}
+	return phonePattern.ReplaceAllStringFunc(message, func(phone string) string {
+		return "********" + phone[len(phone)-3:]
+	})
+}
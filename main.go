@@ -1,12 +1,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"passport-booking/config"
+	"passport-booking/controllers/bag"
 	"passport-booking/database"
 	"passport-booking/database/seeders"
 	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
 	"passport-booking/routes"
+	"passport-booking/services/barcode"
+	"passport-booking/services/booking_event"
+	devicePush "passport-booking/services/device_push"
+	dmsOutbox "passport-booking/services/dms_outbox"
+	"passport-booking/services/evidence_export"
+	"passport-booking/services/fraud"
+	"passport-booking/services/localtime"
+	"passport-booking/services/notification"
+	"passport-booking/services/parcel_push"
+	"passport-booking/services/postman_metrics"
+	"passport-booking/services/postmaster_digest"
+	"passport-booking/services/privacy"
+	"passport-booking/services/slo"
+	"passport-booking/services/storage"
+	"passport-booking/services/workerpool"
+	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,6 +36,9 @@ import (
 )
 
 func main() {
+	// Timestamps are kept and served in UTC; services/localtime renders them for people
+	localtime.UseUTC()
+
 	app := fiber.New(fiber.Config{
 		ReadBufferSize:  32768, // 32KB read buffer
 		WriteBufferSize: 32768, // 32KB write buffer
@@ -27,6 +51,8 @@ func main() {
 		logger.Error("Error loading .env file", env)
 		fmt.Println("Error loading .env file", env)
 	}
+	// Debug output, verbose SQL, mocks and PII in logs depend on the profile
+	config.ApplyProfile()
 	// Use your custom logger to print a success message.
 	logger.Success("Server is running on ip: " + os.Getenv("APP_HOST") + " port: " + os.Getenv("APP_PORT") +
 		"\n\t\t\t\t\t\t******************************************************************************************\n")
@@ -41,6 +67,57 @@ func main() {
 	// Run seeders only if data doesn't exist
 	logger.Success("Checking if database seeding is needed...")
 	seeders.SeedRegionalPassportOffices(db)
+	if err := fraud.SeedRules(db); err != nil {
+		logger.Error("Failed to seed fraud rules", err)
+	}
+
+	// Evidence exports hold their password in memory, so ones cut short cannot resume
+	if err := evidence_export.FailInterrupted(db); err != nil {
+		logger.Error("Failed to close interrupted evidence exports", err)
+	}
+
+	// Applicant notifications over SMS and email
+	notification.Init(db)
+
+	// Keep managed notification templates in step with the DB
+	stopTemplateReloader := notification.StartTemplateReloader(db)
+	defer stopTemplateReloader()
+
+	// Push notifications to the postman app
+	devicePush.Init(db)
+
+	// Recompute postman performance metrics in the background
+	stopPostmanMetrics := postman_metrics.NewPostmanMetricsService(db).StartScheduler()
+	defer stopPostmanMetrics()
+
+	// Anonymize applicant PII once the retention period has passed
+	stopRetention := privacy.StartScheduler(db, storage.NewLocalStorage(storage.DeliveryPhotoDir))
+	defer stopRetention()
+
+	// Keep branch barcode pools stocked from DMS
+	stopBarcodePool := barcode.StartPoolScheduler(db)
+	defer stopBarcodePool()
+
+	// Replay intake queued while DMS was unavailable
+	dmsOutbox.Register(bookingModel.DMSOutboxAddItem, bag.AddItemReplayer{})
+	stopDMSOutbox := dmsOutbox.StartScheduler(db)
+	defer stopDMSOutbox()
+
+	// Retry failed parcel booking submissions to DMS
+	stopParcelPush := parcel_push.StartScheduler(db)
+	defer stopParcelPush()
+
+	// Compact old booking event snapshots and check bookings replay from their events
+	stopEventMaintenance := booking_event.StartScheduler(db)
+	defer stopEventMaintenance()
+
+	// Send postmasters their morning branch digest
+	stopPostmasterDigest := postmaster_digest.StartScheduler(db)
+	defer stopPostmasterDigest()
+
+	// Flush per-route request counts for the SLO report
+	stopRequestMetrics := slo.StartScheduler(db)
+	defer stopRequestMetrics()
 
 	// Initialize the async logger with the database connection
 	// go logger.AsyncLogger(db)
@@ -61,17 +138,34 @@ func main() {
 		},
 		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
 		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
-		ExposeHeaders:    "Content-Length, Authorization",
+		ExposeHeaders:    "Content-Length, Authorization, X-Booking-Quota-Scope, X-Booking-Quota-Limit, X-Booking-Quota-Used, X-Booking-Quota-Remaining, X-Booking-Quota-Reset",
 		AllowCredentials: true,
 	}))
 
 	// Use new consolidated routes
 	routes.SetupRoutes(app, db)
 
+	// Stop taking requests on SIGINT/SIGTERM so queued background work can drain below
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		<-quit
+		logger.Info("Shutting down, draining background work")
+		if err := app.Shutdown(); err != nil {
+			logger.Error("Failed to shut down the server", err)
+		}
+	}()
+
 	// app_host := "0.0.0.0"
 	app_host := os.Getenv("APP_HOST")
 	// app_port := "8004"
 	app_port := os.Getenv("APP_PORT")
 	app.Listen(app_host + ":" + app_port)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), workerpool.DrainTimeout())
+	defer cancel()
+	if err := workerpool.Shutdown(drainCtx); err != nil {
+		logger.Error("Background work was still running at shutdown", err)
+	}
 	// Additional application code can follow...
 }
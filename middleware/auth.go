@@ -11,6 +11,7 @@ import (
 
 // RequirePermissions is a helper function that creates a middleware with specific permissions
 func RequirePermissions(permissions ...string) fiber.Handler {
+	trackPermissions(permissions)
 	return IsAuthenticated(permissions)
 }
 
@@ -18,41 +19,51 @@ func RequirePermissions(permissions ...string) fiber.Handler {
 func RequireAnyPermission(permissions ...string) fiber.Handler {
 	// Add "any" to allow flexible permission checking
 	allPerms := append(permissions, constants.PermAny)
+	trackPermissions(allPerms)
 	return IsAuthenticated(allPerms)
 }
 
 // RequireAuthentication only requires valid authentication without specific permissions
 func RequireAuthentication() fiber.Handler {
+	trackPermissions([]string{constants.PermAny})
 	return IsAuthenticated([]string{constants.PermAny})
 }
 
 // CheckPermissionInController checks if user has specific permission within a controller
 func CheckPermissionInController(c *fiber.Ctx, requiredPermission string) bool {
-	userPermissions, ok := c.Locals("permissions").(map[string]bool)
-	if !ok {
-		// Fallback to extracting from user claims
-		userClaims, ok := c.Locals("user").(jwt.MapClaims)
-		if !ok {
-			return false
-		}
-		userPermissions = extractUserPermissionsFromClaims(userClaims)
-	}
-
-	return userPermissions[requiredPermission]
+	return GetUserPermissions(c)[requiredPermission]
 }
 
 // GetUserPermissions returns all user permissions from context
 func GetUserPermissions(c *fiber.Ctx) map[string]bool {
-	userPermissions, ok := c.Locals("permissions").(map[string]bool)
-	if !ok {
-		// Fallback to extracting from user claims
-		userClaims, ok := c.Locals("user").(jwt.MapClaims)
-		if !ok {
-			return make(map[string]bool)
+	if userPermissions, ok := c.Locals("permissions").(map[string]bool); ok {
+		return userPermissions
+	}
+	// Fallback to the authenticated caller's token permissions
+	if current, _ := c.Locals(currentUserKey).(*CurrentUser); current != nil {
+		return current.Permissions
+	}
+	return make(map[string]bool)
+}
+
+// OwnPermissions returns the caller's permissions without those delegated to them
+func OwnPermissions(c *fiber.Ctx) map[string]bool {
+	if current, _ := c.Locals(currentUserKey).(*CurrentUser); current != nil {
+		return current.OwnPermissions()
+	}
+	return GetUserPermissions(c)
+}
+
+// MaskPII reports whether the caller's role should see phone numbers and addresses
+// masked, i.e. it holds none of constants.PIIPermissions
+func MaskPII(c *fiber.Ctx) bool {
+	permissions := GetUserPermissions(c)
+	for _, p := range constants.PIIPermissions {
+		if permissions[p] {
+			return false
 		}
-		return extractUserPermissionsFromClaims(userClaims)
 	}
-	return userPermissions
+	return true
 }
 
 func extractUserPermissionsFromClaims(claims jwt.MapClaims) map[string]bool {
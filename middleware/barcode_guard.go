@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"fmt"
+	barcodeModel "passport-booking/models/barcode"
+	"passport-booking/services/barcode_guard"
+	"passport-booking/types"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// requestSources identifies the caller by IP and, when signed in, by token subject
+func requestSources(c *fiber.Ctx) []string {
+	sources := []string{"ip:" + c.IP()}
+	if uuid := CurrentUserUUID(c); uuid != "" {
+		sources = append(sources, "user:"+uuid)
+	}
+	return sources
+}
+
+// BarcodeProbeGuard watches lookups by the barcode in the named path parameter.
+// Sources that keep missing are throttled with 429 and sources walking through
+// sequential barcodes are blocked with 403, both until the block runs out or an
+// administrator lifts it.
+func BarcodeProbeGuard(db *gorm.DB, param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		guard := barcode_guard.Default(db)
+		sources := requestSources(c)
+		now := time.Now()
+
+		if block := guard.Check(sources, now); block != nil {
+			status := fiber.StatusTooManyRequests
+			if block.Action == barcodeModel.ProbeActionBlock {
+				status = fiber.StatusForbidden
+			}
+			retryAfter := int(block.BlockedUntil.Sub(now).Seconds()) + 1
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			return c.Status(status).JSON(types.ApiResponse{
+				Status:  status,
+				Message: fmt.Sprintf("Too many failed lookups, try again after %s", block.BlockedUntil.Format(time.RFC3339)),
+			})
+		}
+
+		err := c.Next()
+
+		switch c.Response().StatusCode() {
+		case fiber.StatusOK, fiber.StatusNotModified:
+			guard.Record(sources, c.Params(param), true, now)
+		case fiber.StatusNotFound:
+			guard.Record(sources, c.Params(param), false, now)
+		}
+		return err
+	}
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"passport-booking/constants"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RouteCapability describes a registered route and the permissions that guard it
+type RouteCapability struct {
+	Feature     string   `json:"feature"`
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Permissions []string `json:"permissions"`
+}
+
+// routeRegistry collects the permission guards attached to routes while they are
+// being registered. Permission middlewares park their permission list in pending
+// and the OnRoute hook pairs it with the route that is registered next.
+var routeRegistry = struct {
+	sync.Mutex
+	pending []string
+	routes  []RouteCapability
+}{}
+
+func trackPermissions(permissions []string) {
+	routeRegistry.Lock()
+	defer routeRegistry.Unlock()
+	routeRegistry.pending = append([]string(nil), permissions...)
+}
+
+// TrackRoutePermissions records the permissions of every route registered on app
+// from this point on. It must be called before the routes are set up.
+func TrackRoutePermissions(app *fiber.App) {
+	app.Hooks().OnRoute(func(route fiber.Route) error {
+		routeRegistry.Lock()
+		defer routeRegistry.Unlock()
+
+		// GET routes are registered twice (HEAD first), keep the guard for the GET
+		if route.Method == fiber.MethodHead {
+			return nil
+		}
+
+		permissions := routeRegistry.pending
+		routeRegistry.pending = nil
+		// A guard registered through Use is the route's only handler; group-level
+		// guards are not tied to a single feature so they are left out
+		if permissions == nil || len(route.Handlers) < 2 {
+			return nil
+		}
+
+		routeRegistry.routes = append(routeRegistry.routes, RouteCapability{
+			Feature:     featureFromPath(route.Path),
+			Method:      route.Method,
+			Path:        route.Path,
+			Permissions: permissions,
+		})
+		return nil
+	})
+}
+
+// RegisteredCapabilities returns every guarded route known to the registry
+func RegisteredCapabilities() []RouteCapability {
+	routeRegistry.Lock()
+	defer routeRegistry.Unlock()
+
+	routes := make([]RouteCapability, len(routeRegistry.routes))
+	copy(routes, routeRegistry.routes)
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Feature == routes[j].Feature {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Feature < routes[j].Feature
+	})
+	return routes
+}
+
+// CapabilitiesFor returns the registered routes the given permission set can access,
+// using the same any-of rule as IsAuthenticated
+func CapabilitiesFor(userPermissions map[string]bool) []RouteCapability {
+	allowed := make([]RouteCapability, 0)
+	for _, route := range RegisteredCapabilities() {
+		for _, perm := range permissionsForMethod(route.Method, route.Permissions) {
+			if perm == constants.PermAny || userPermissions[perm] {
+				allowed = append(allowed, route)
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+// featureFromPath turns "/api/booking/track/:barcode/qr" into "booking.track.qr"
+func featureFromPath(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/api"), "/")
+	parts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if segment == "" || strings.HasPrefix(segment, ":") || segment == "*" {
+			continue
+		}
+		parts = append(parts, strings.ReplaceAll(segment, "_", "-"))
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/httpServices/captcha"
+	"passport-booking/logger"
+	"passport-booking/services/velocity"
+	"passport-booking/types"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CaptchaTokenHeader carries the solved challenge on the retried request
+const CaptchaTokenHeader = "X-Captcha-Token"
+
+var (
+	captchaOnce     sync.Once
+	captchaVerifier captcha.Verifier
+)
+
+func currentCaptchaVerifier() captcha.Verifier {
+	captchaOnce.Do(func() {
+		captchaVerifier = captcha.NewVerifier()
+	})
+	return captchaVerifier
+}
+
+// CaptchaOnVelocity lets a client call the route limit times per window, counted per
+// IP and per signed-in user, then asks for a solved CAPTCHA in X-Captcha-Token before
+// serving more. A passed challenge starts the client's count over. envKey overrides
+// limit. Without a configured provider the route is left open and breaches are only
+// logged.
+func CaptchaOnVelocity(limit int, window time.Duration, envKey string) fiber.Handler {
+	if raw := os.Getenv(envKey); envKey != "" && raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	hits := velocity.NewWindow(window)
+
+	return func(c *fiber.Ctx) error {
+		now := time.Now()
+		keys := requestSources(c)
+
+		over := false
+		for _, key := range keys {
+			if hits.Hit(key, now) > limit {
+				over = true
+			}
+		}
+		if !over {
+			return c.Next()
+		}
+
+		verifier := currentCaptchaVerifier()
+		if verifier == nil {
+			logger.Warning(fmt.Sprintf("%s %s velocity limit of %d per %s exceeded by %v, no captcha provider configured",
+				c.Method(), c.Route().Path, limit, window, keys))
+			return c.Next()
+		}
+
+		err := verifier.Verify(c.Get(CaptchaTokenHeader), c.IP())
+		if err != nil && !errors.Is(err, captcha.ErrMissingToken) && !errors.Is(err, captcha.ErrRejected) {
+			// The provider itself failed; do not lock applicants out over it
+			logger.Error("Captcha verification unavailable, letting request through", err)
+			return c.Next()
+		}
+		if err != nil {
+			return c.Status(fiber.StatusPreconditionRequired).JSON(types.ApiResponse{
+				Status:  fiber.StatusPreconditionRequired,
+				Message: "Please complete the captcha challenge to continue",
+				Data: fiber.Map{
+					"captcha_required": true,
+					"provider":         verifier.Provider(),
+					"site_key":         verifier.SiteKey(),
+					"header":           CaptchaTokenHeader,
+				},
+			})
+		}
+
+		for _, key := range keys {
+			hits.Reset(key)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"passport-booking/services/chaos"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// chaosExempt are paths fault injection leaves alone: probes, and the switch that
+// turns it off again
+var chaosExempt = []string{"/api/health", "/api/system/chaos"}
+
+// Chaos injects random latency and database timeouts into a share of requests when
+// fault injection is enabled (staging only, see services/chaos). Affected responses
+// carry X-Chaos with the faults injected.
+func Chaos() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !chaos.Current().Enabled {
+			return c.Next()
+		}
+		for _, path := range chaosExempt {
+			if strings.HasPrefix(c.Path(), path) {
+				return c.Next()
+			}
+		}
+
+		var injected []string
+		if delay := chaos.Latency(); delay > 0 {
+			time.Sleep(delay)
+			injected = append(injected, "latency="+delay.String())
+		}
+		if chaos.DBTimeout() {
+			c.SetUserContext(chaos.WithDBTimeout(c.UserContext()))
+			injected = append(injected, "db_timeout")
+		}
+		if len(injected) > 0 {
+			c.Set("X-Chaos", strings.Join(injected, ","))
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"errors"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/impersonation"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// currentUserKey is the locals key IsAuthenticated stores the caller under
+const currentUserKey = "current_user"
+
+// CurrentUser is the authenticated caller, built once per request by IsAuthenticated
+// so handlers neither re-assert the raw claims nor look the user up again.
+type CurrentUser struct {
+	// ID is the local user ID, 0 when the token's user has not been synced locally
+	ID       uint
+	UUID     string
+	Username string
+	Name     string
+	// Permissions holds every permission the caller may use, delegated ones included
+	Permissions map[string]bool
+	// Delegated holds the permissions only held through a delegation
+	Delegated map[string]bool
+	// BranchCodes are the branches, districts or regions the user is mapped to
+	BranchCodes []string
+	// ImpersonatedBy is the UUID of the admin behind an impersonation token
+	ImpersonatedBy string
+
+	// User is the local user record, nil when not synced
+	User *userModel.User
+	// Claims are the verified token claims
+	Claims jwt.MapClaims
+}
+
+// newCurrentUser builds the caller from verified claims and, when db is set, the
+// local user record and branch mappings
+func newCurrentUser(db *gorm.DB, claims jwt.MapClaims) (*CurrentUser, error) {
+	current := &CurrentUser{
+		Permissions: extractUserPermissionsFromClaims(claims),
+		Delegated:   make(map[string]bool),
+		Claims:      claims,
+	}
+	current.UUID, _ = claims["uuid"].(string)
+	current.Username, _ = claims["username"].(string)
+	if claims["typ"] == impersonation.TokenType {
+		current.ImpersonatedBy, _ = claims["impersonated_by"].(string)
+	}
+	delegated, _ := claims["delegated_permissions"].([]interface{})
+	for _, p := range delegated {
+		if perm, ok := p.(string); ok {
+			current.Delegated[perm] = true
+		}
+	}
+
+	if db == nil || current.UUID == "" {
+		return current, nil
+	}
+	var user userModel.User
+	if err := db.Where("uuid = ?", current.UUID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return current, nil
+		}
+		return nil, err
+	}
+	current.User = &user
+	current.ID = user.ID
+	current.Username = user.Username
+	current.Name = user.LegalName
+	if err := db.Model(&userModel.UserBranch{}).Where("user_id = ?", user.ID).Order("branch_code").Pluck("branch_code", &current.BranchCodes).Error; err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// SetCurrentUser attaches the authenticated caller to the request. IsAuthenticated
+// calls it; handler tests use it to stand in for a token.
+func SetCurrentUser(c *fiber.Ctx, current *CurrentUser) {
+	c.Locals(currentUserKey, current)
+}
+
+// GetCurrentUser returns the authenticated caller. ok is false on routes without
+// authentication and when the token's user has no local record.
+func GetCurrentUser(c *fiber.Ctx) (current *CurrentUser, ok bool) {
+	current, _ = c.Locals(currentUserKey).(*CurrentUser)
+	return current, current != nil && current.User != nil
+}
+
+// CurrentUserUUID returns the authenticated caller's UUID, empty on routes without
+// authentication
+func CurrentUserUUID(c *fiber.Ctx) string {
+	if current, _ := c.Locals(currentUserKey).(*CurrentUser); current != nil {
+		return current.UUID
+	}
+	return ""
+}
+
+// CurrentUserID returns the authenticated caller's local user ID, 0 when unknown
+func CurrentUserID(c *fiber.Ctx) uint {
+	if current, _ := c.Locals(currentUserKey).(*CurrentUser); current != nil {
+		return current.ID
+	}
+	return 0
+}
+
+// HasPermission reports whether the caller holds the permission, delegated or not
+func (u *CurrentUser) HasPermission(permission string) bool {
+	return u.Permissions[permission]
+}
+
+// OwnPermissions returns the caller's permissions without those delegated to them
+func (u *CurrentUser) OwnPermissions() map[string]bool {
+	own := make(map[string]bool, len(u.Permissions))
+	for perm := range u.Permissions {
+		if !u.Delegated[perm] {
+			own[perm] = true
+		}
+	}
+	return own
+}
+
+// IsImpersonated reports whether the request runs on an impersonation token
+func (u *CurrentUser) IsImpersonated() bool {
+	return u.Claims["typ"] == impersonation.TokenType
+}
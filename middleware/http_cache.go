@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+)
+
+// Compression returns a gzip/brotli/deflate compression middleware. The level
+// can be tuned with COMPRESSION_LEVEL (-1 disabled, 0 default, 1 best speed,
+// 2 best compression).
+func Compression() fiber.Handler {
+	level := compress.LevelBestSpeed
+	if raw := os.Getenv("COMPRESSION_LEVEL"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil {
+			level = compress.Level(parsed)
+		}
+	}
+	return compress.New(compress.Config{Level: level})
+}
+
+// CacheControl sets a private Cache-Control header on successful GET
+// responses. envKey, when set in the environment, overrides maxAge in seconds.
+func CacheControl(maxAge time.Duration, envKey string) fiber.Handler {
+	if raw := os.Getenv(envKey); envKey != "" && raw != "" {
+		if seconds, err := strconv.Atoi(raw); err == nil {
+			maxAge = time.Duration(seconds) * time.Second
+		}
+	}
+	header := fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds()))
+
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Method() == fiber.MethodGet && c.Response().StatusCode() == fiber.StatusOK {
+			c.Set(fiber.HeaderCacheControl, header)
+		}
+		return nil
+	}
+}
+
+// NoCache forces clients to revalidate (e.g. via ETag) before reusing a response
+func NoCache() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Method() == fiber.MethodGet {
+			c.Set(fiber.HeaderCacheControl, "no-cache")
+		}
+		return nil
+	}
+}
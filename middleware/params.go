@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"passport-booking/types"
+	bookingTypes "passport-booking/types/booking"
+	"passport-booking/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ParamSource is where a validated parameter is read from
+type ParamSource string
+
+const (
+	ParamPath  ParamSource = "path"
+	ParamQuery ParamSource = "query"
+	ParamForm  ParamSource = "form"
+	// ParamBody reads a top-level field of a JSON body
+	ParamBody ParamSource = "body"
+)
+
+// ParamRule validates one request parameter before the handler runs
+type ParamRule struct {
+	Source   ParamSource
+	Name     string
+	Required bool
+	// Check validates a non-empty value; c gives access to sibling parameters
+	Check func(c *fiber.Ctx, value string) error
+}
+
+// PathID requires the path parameter to be a positive numeric ID
+func PathID(name string) ParamRule {
+	return ParamRule{Source: ParamPath, Name: name, Required: true, Check: func(_ *fiber.Ctx, v string) error {
+		return utils.ValidateNumericID(v)
+	}}
+}
+
+// PathBarcode requires the path parameter to be a well-formed barcode
+func PathBarcode(name string) ParamRule {
+	return ParamRule{Source: ParamPath, Name: name, Required: true, Check: func(_ *fiber.Ctx, v string) error {
+		return utils.ValidateBarcode(v)
+	}}
+}
+
+// BodyBarcode requires the JSON body field to be a well-formed barcode
+func BodyBarcode(name string) ParamRule {
+	return ParamRule{Source: ParamBody, Name: name, Required: true, Check: func(_ *fiber.Ctx, v string) error {
+		return utils.ValidateBarcode(v)
+	}}
+}
+
+// FormPhone validates a mobile number form field
+func FormPhone(name string, required bool) ParamRule {
+	return ParamRule{Source: ParamForm, Name: name, Required: required, Check: func(_ *fiber.Ctx, v string) error {
+		return utils.ValidatePhone(v)
+	}}
+}
+
+// FormBookingIdentifier validates a booking identifier form field against the
+// format its identifier type form field names, defaulting to defaultType
+func FormBookingIdentifier(name, typeField string, defaultType bookingTypes.IdentifierType) ParamRule {
+	return ParamRule{Source: ParamForm, Name: name, Required: true, Check: func(c *fiber.Ctx, v string) error {
+		return checkBookingIdentifier(bookingTypes.IdentifierType(c.FormValue(typeField)), defaultType, v)
+	}}
+}
+
+// PathBookingIdentifier validates a booking identifier path parameter against the
+// format its identifier type query parameter names, defaulting to defaultType
+func PathBookingIdentifier(name, typeQuery string, defaultType bookingTypes.IdentifierType) ParamRule {
+	return ParamRule{Source: ParamPath, Name: name, Required: true, Check: func(c *fiber.Ctx, v string) error {
+		return checkBookingIdentifier(bookingTypes.IdentifierType(c.Query(typeQuery)), defaultType, v)
+	}}
+}
+
+func checkBookingIdentifier(identifierType, defaultType bookingTypes.IdentifierType, v string) error {
+	if err := bookingTypes.NormalizeIdentifierType(&identifierType, defaultType); err != nil {
+		return err
+	}
+	switch identifierType {
+	case bookingTypes.IdentifierTypeID:
+		return utils.ValidateNumericID(v)
+	case bookingTypes.IdentifierTypeBarcode:
+		return utils.ValidateBarcode(v)
+	}
+	return utils.ValidateIdentifier(v)
+}
+
+func (r ParamRule) value(c *fiber.Ctx, body *map[string]interface{}) string {
+	switch r.Source {
+	case ParamPath:
+		return strings.TrimSpace(c.Params(r.Name))
+	case ParamQuery:
+		return strings.TrimSpace(c.Query(r.Name))
+	case ParamForm:
+		return strings.TrimSpace(c.FormValue(r.Name))
+	case ParamBody:
+		if *body == nil {
+			*body = map[string]interface{}{}
+			// A malformed body is left for the handler to reject
+			_ = json.Unmarshal(c.Body(), body)
+		}
+		switch v := (*body)[r.Name].(type) {
+		case nil:
+			return ""
+		case string:
+			return strings.TrimSpace(v)
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+// ValidateParams checks the parameters of a route and answers 422 with a message per
+// invalid parameter, so every endpoint reports bad IDs, barcodes and phones the same
+// way
+func ValidateParams(rules ...ParamRule) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body map[string]interface{}
+		invalid := map[string]string{}
+		for _, r := range rules {
+			v := r.value(c, &body)
+			if v == "" {
+				if r.Required {
+					invalid[r.Name] = fmt.Sprintf("%s is required", r.Name)
+				}
+				continue
+			}
+			if err := r.Check(c, v); err != nil {
+				invalid[r.Name] = err.Error()
+			}
+		}
+		if len(invalid) > 0 {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(types.ApiResponse{
+				Status:  fiber.StatusUnprocessableEntity,
+				Message: "Invalid request parameters",
+				Data:    invalid,
+			})
+		}
+		return c.Next()
+	}
+}
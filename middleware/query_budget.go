@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"fmt"
+	"os"
+	"passport-booking/logger"
+	"passport-booking/services/query_count"
+	"passport-booking/types"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// QueryBudget counts the queries a handler runs on the request context and reports
+// them in X-Query-Count. A request going over max is logged, or rejected with 500
+// when QUERY_BUDGET_ENFORCE is true so a regression fails loudly in staging.
+func QueryBudget(max int64) fiber.Handler {
+	enforce, _ := strconv.ParseBool(os.Getenv("QUERY_BUDGET_ENFORCE"))
+
+	return func(c *fiber.Ctx) error {
+		ctx, counter := query_count.WithCounter(c.UserContext())
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		count := counter.Count()
+		c.Set("X-Query-Count", strconv.FormatInt(count, 10))
+		if count > max {
+			msg := fmt.Sprintf("%s %s ran %d queries, over its budget of %d", c.Method(), c.Route().Path, count, max)
+			logger.Warning(msg)
+			if enforce {
+				return c.Status(fiber.StatusInternalServerError).JSON(types.ApiResponse{
+					Status:  fiber.StatusInternalServerError,
+					Message: msg,
+				})
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"errors"
+	"passport-booking/services/slo"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestMetrics counts every request against its route pattern for the SLO report.
+// Errors returned by the handler are counted with the status the error handler will
+// send, so an unhandled error counts as a 500.
+func RequestMetrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+		slo.Default().Observe(c.Method(), c.Route().Path, status, time.Since(start), start)
+		return err
+	}
+}
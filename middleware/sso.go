@@ -12,8 +12,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"passport-booking/config"
+	"passport-booking/constants"
+	"passport-booking/database"
+	"passport-booking/services/impersonation"
+	"passport-booking/services/user_admin"
 	"passport-booking/types"
 	"strings"
+	"time"
 )
 
 // FetchPublicKey fetches the public key from the given URL.
@@ -123,8 +129,7 @@ func init_jwt() *rsa.PublicKey {
 func hasPermission(jwtToken string, requiredPermissions []string) (map[string]interface{}, bool) {
 	//log.Printf("Checking permissions for token. Required permissions: %v", requiredPermissions)
 
-	claims, err := VerifyJWT(jwtToken)
-	log.Println("Verifying JWT token...", claims)
+	claims, err := verifyToken(jwtToken)
 	if err != nil {
 		log.Printf("JWT verification failed: %v", err)
 		return nil, false
@@ -132,6 +137,8 @@ func hasPermission(jwtToken string, requiredPermissions []string) (map[string]in
 
 	//log.Printf("JWT verified successfully. Claims: %v", claims)
 
+	addDelegatedPermissions(claims)
+
 	// If "any" is passed, just verify the token without checking specific permissions
 	for _, requiredPerm := range requiredPermissions {
 		if requiredPerm == "any" {
@@ -169,6 +176,73 @@ func hasPermission(jwtToken string, requiredPermissions []string) (map[string]in
 	return claims, false // No matching permissions found
 }
 
+// addDelegatedPermissions merges the permissions delegated to the token's user into
+// its claims, so every permission check downstream honours them until they expire.
+// They are also listed under delegated_permissions so they cannot be passed on.
+// Impersonation tokens carry a fixed permission snapshot and are left alone.
+func addDelegatedPermissions(claims jwt.MapClaims) {
+	uuid, _ := claims["uuid"].(string)
+	if uuid == "" || database.DB == nil || claims["typ"] == impersonation.TokenType {
+		return
+	}
+	delegated := user_admin.DelegatedPermissions(database.DB, uuid, time.Now())
+	if len(delegated) == 0 {
+		return
+	}
+
+	permissions, _ := claims["permissions"].([]interface{})
+	held := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		if perm, ok := p.(string); ok {
+			held[perm] = true
+		}
+	}
+
+	var extra []interface{}
+	for _, p := range delegated {
+		if !held[p] {
+			held[p] = true
+			permissions = append(permissions, p)
+			extra = append(extra, p)
+		}
+	}
+	if len(extra) == 0 {
+		return
+	}
+	claims["permissions"] = permissions
+	claims["delegated_permissions"] = extra
+}
+
+// permissionsForMethod drops read-only permissions from the accepted set on
+// mutating requests, so a viewer gets 403 even if a route lists the viewer role
+func permissionsForMethod(method string, requiredPermissions []string) []string {
+	if method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions {
+		return requiredPermissions
+	}
+
+	readOnly := make(map[string]bool, len(constants.ReadOnlyPermissions))
+	for _, perm := range constants.ReadOnlyPermissions {
+		readOnly[perm] = true
+	}
+
+	allowed := make([]string, 0, len(requiredPermissions))
+	for _, perm := range requiredPermissions {
+		if !readOnly[perm] {
+			allowed = append(allowed, perm)
+		}
+	}
+	return allowed
+}
+
+// verifyToken verifies SSO tokens with the SSO public key and locally issued
+// impersonation tokens with the impersonation secret
+func verifyToken(jwtToken string) (jwt.MapClaims, error) {
+	if impersonation.IsImpersonationToken(jwtToken) {
+		return impersonation.Verify(jwtToken)
+	}
+	return VerifyJWT(jwtToken)
+}
+
 // IsAuthenticated is a middleware that checks for a valid JWT token
 func IsAuthenticated(requiredPermissions []string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -208,9 +282,9 @@ func IsAuthenticated(requiredPermissions []string) fiber.Handler {
 		if len(jwtToken) > 50 {
 			tokenPreview = jwtToken[:50] + "..."
 		}
-		log.Printf("Extracted JWT token: %s", tokenPreview)
+		config.Debugf("Extracted JWT token: %s", tokenPreview)
 
-		decodedClaims, hasAccess := hasPermission(jwtToken, requiredPermissions)
+		decodedClaims, hasAccess := hasPermission(jwtToken, permissionsForMethod(c.Method(), requiredPermissions))
 		if !hasAccess {
 			log.Println("Access denied - insufficient permissions")
 			return c.Status(403).JSON(fiber.Map{"status": "error", "error": "Insufficient permissions"})
@@ -221,9 +295,25 @@ func IsAuthenticated(requiredPermissions []string) fiber.Handler {
 			return c.Status(http.StatusUnauthorized).JSON(types.ApiResponse{Message: "Session expired. Login again.", Status: fiber.StatusBadRequest})
 		}
 
+		// Tokens stay valid at the SSO after a local deactivation, so refuse them here
+		if uuid, _ := decodedClaims["uuid"].(string); uuid != "" && database.DB != nil && user_admin.IsInactive(database.DB, uuid) {
+			return c.Status(fiber.StatusForbidden).JSON(types.ApiResponse{Message: "Account is deactivated or awaiting approval", Status: fiber.StatusForbidden})
+		}
+
 		//log.Println("Authentication successful, proceeding to next handler")
-		// Optionally attach claims to context
-		c.Locals("user", decodedClaims)
+		// Attach the caller to the context once so handlers need not look it up again
+		current, err := newCurrentUser(database.DB, jwt.MapClaims(decodedClaims))
+		if err != nil {
+			log.Printf("Failed to load authenticated user: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(types.ApiResponse{Message: "Database error", Status: fiber.StatusInternalServerError})
+		}
+		SetCurrentUser(c, current)
+
+		// Tag the request so audit logs and booking events record the impersonating admin
+		if current.ImpersonatedBy != "" {
+			c.Locals("impersonated_by", current.ImpersonatedBy)
+			c.SetUserContext(impersonation.WithImpersonator(c.UserContext(), current.ImpersonatedBy))
+		}
 
 		return c.Next()
 	}
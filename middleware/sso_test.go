@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"passport-booking/constants"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ssoSigner serves a fresh RSA public key the way the SSO does and returns a function
+// signing tokens with the matching private key
+func ssoSigner(t *testing.T) func(permissions ...string) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"key": publicKey})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("PUBLIC_KEY_URL", server.URL)
+
+	return func(permissions ...string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"username":    "tester",
+			"permissions": permissions,
+			"exp":         time.Now().Add(time.Hour).Unix(),
+		}).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+}
+
+func TestPermissionsForMethod(t *testing.T) {
+	required := []string{constants.PermPostOfficeFull, constants.PermViewerReadOnly}
+	tests := []struct {
+		method string
+		want   []string
+	}{
+		{fiber.MethodGet, required},
+		{fiber.MethodHead, required},
+		{fiber.MethodOptions, required},
+		{fiber.MethodPost, []string{constants.PermPostOfficeFull}},
+		{fiber.MethodPut, []string{constants.PermPostOfficeFull}},
+		{fiber.MethodPatch, []string{constants.PermPostOfficeFull}},
+		{fiber.MethodDelete, []string{constants.PermPostOfficeFull}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			got := permissionsForMethod(tt.method, required)
+			if len(got) != len(tt.want) {
+				t.Fatalf("permissionsForMethod(%s) = %v, want %v", tt.method, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("permissionsForMethod(%s) = %v, want %v", tt.method, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestViewerCannotMutate checks that a route listing the viewer role serves a viewer
+// only on reads and answers every mutating method with 403
+func TestViewerCannotMutate(t *testing.T) {
+	sign := ssoSigner(t)
+	viewer := sign(constants.PermViewerReadOnly)
+	postOffice := sign(constants.PermPostOfficeFull)
+
+	app := fiber.New()
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.All("/resource", RequirePermissions(constants.PermPostOfficeFull, constants.PermViewerReadOnly), ok)
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		want   int
+	}{
+		{"viewer reads", viewer, fiber.MethodGet, fiber.StatusOK},
+		{"viewer creates", viewer, fiber.MethodPost, fiber.StatusForbidden},
+		{"viewer updates", viewer, fiber.MethodPut, fiber.StatusForbidden},
+		{"viewer patches", viewer, fiber.MethodPatch, fiber.StatusForbidden},
+		{"viewer deletes", viewer, fiber.MethodDelete, fiber.StatusForbidden},
+		{"post office reads", postOffice, fiber.MethodGet, fiber.StatusOK},
+		{"post office creates", postOffice, fiber.MethodPost, fiber.StatusOK},
+		{"post office deletes", postOffice, fiber.MethodDelete, fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/resource", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s %s: status %d, want %d", tt.name, tt.method, resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -4,14 +4,22 @@ import (
 	"time"
 )
 
-// Address represents sender or recipient address information
+// Address represents sender or recipient address information. The plain fields
+// hold the English rendering sent to DMS; the *Bn fields hold the Bangla one
+// postmen read on labels.
 type Address struct {
-	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Division       *string   `gorm:"size:255" json:"division,omitempty"`
-	District       *string   `gorm:"size:255" json:"district,omitempty"`
-	PoliceStation  *string   `gorm:"size:255" json:"police_station,omitempty"`
-	PostOffice     *string   `gorm:"size:255" json:"post_office,omitempty"`
-	PostOfficeCode *string   `gorm:"size:255" json:"post_office_code,omitempty"`
-	StreetAddress  *string   `gorm:"size:255" json:"street_address,omitempty"`
-	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Division        *string   `gorm:"size:255" json:"division,omitempty"`
+	District        *string   `gorm:"size:255" json:"district,omitempty"`
+	PoliceStation   *string   `gorm:"size:255" json:"police_station,omitempty"`
+	PostOffice      *string   `gorm:"size:255" json:"post_office,omitempty"`
+	PostOfficeCode  *string   `gorm:"size:255" json:"post_office_code,omitempty"`
+	StreetAddress   *string   `gorm:"size:255" json:"street_address,omitempty"`
+	DivisionBn      *string   `gorm:"size:255" json:"division_bn,omitempty"`
+	DistrictBn      *string   `gorm:"size:255" json:"district_bn,omitempty"`
+	PoliceStationBn *string   `gorm:"size:255" json:"police_station_bn,omitempty"`
+	PostOfficeBn    *string   `gorm:"size:255" json:"post_office_bn,omitempty"`
+	StreetAddressBn *string   `gorm:"size:255" json:"street_address_bn,omitempty"`
+	NoNetwork       bool      `gorm:"default:false" json:"no_network"` // no mobile coverage; deliveries use pre-issued offline codes
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
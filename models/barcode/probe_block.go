@@ -0,0 +1,32 @@
+package barcode
+
+import "time"
+
+// ProbeAction is how a source caught probing barcodes is held back
+type ProbeAction string
+
+const (
+	ProbeActionThrottle ProbeAction = "throttle" // 429 until BlockedUntil
+	ProbeActionBlock    ProbeAction = "block"    // 403 until BlockedUntil
+)
+
+// ProbeBlock records a client IP or token held back from barcode lookups after
+// too many misses or a run of sequential barcodes
+type ProbeBlock struct {
+	ID           uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	Source       string      `gorm:"type:varchar(255);not null;index" json:"source"` // ip:<addr> or user:<uuid>
+	Action       ProbeAction `gorm:"type:varchar(20);not null" json:"action"`
+	Reason       string      `gorm:"type:text;not null" json:"reason"`
+	Lookups      int         `gorm:"not null" json:"lookups"`
+	Misses       int         `gorm:"not null" json:"misses"`
+	Sample       string      `gorm:"type:text" json:"sample"` // comma separated barcodes of the longest sequential run
+	BlockedUntil time.Time   `gorm:"not null;index" json:"blocked_until"`
+	LiftedBy     *string     `gorm:"type:varchar(255)" json:"lifted_by,omitempty"`
+	LiftedAt     *time.Time  `json:"lifted_at,omitempty"`
+	CreatedAt    time.Time   `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName sets the table name for the ProbeBlock model
+func (ProbeBlock) TableName() string {
+	return "barcode_probe_blocks"
+}
@@ -0,0 +1,67 @@
+package barcode
+
+import "time"
+
+// ProvisionalBarcode records a locally issued barcode that still has to be
+// swapped for a DMS barcode. Pending rows act as the reconciliation outbox.
+type ProvisionalBarcode struct {
+	ID uint `gorm:"primaryKey;autoIncrement" json:"id"`
+
+	Barcode    string `gorm:"type:varchar(50);not null;uniqueIndex" json:"barcode"`
+	BranchCode string `gorm:"type:varchar(50);not null;index" json:"branch_code"`
+	Sequence   int64  `gorm:"not null" json:"sequence"`
+
+	EntityType EntityType `gorm:"type:varchar(50);index" json:"entity_type"`
+	EntityID   uint       `gorm:"index" json:"entity_id"`
+
+	Status       ProvisionalBarcodeStatus `gorm:"type:varchar(20);not null;default:pending;index" json:"status"`
+	DMSBarcode   *string                  `gorm:"type:varchar(50)" json:"dms_barcode,omitempty"`
+	Attempts     int                      `gorm:"default:0" json:"attempts"`
+	LastError    *string                  `gorm:"type:text" json:"last_error,omitempty"`
+	ReconciledAt *time.Time               `json:"reconciled_at,omitempty"`
+	CreatedAt    time.Time                `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time                `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// BarcodeSequence holds the last provisional sequence issued for a branch
+type BarcodeSequence struct {
+	BranchCode string    `gorm:"type:varchar(50);primaryKey" json:"branch_code"`
+	LastValue  int64     `gorm:"not null;default:0" json:"last_value"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// EntityType identifies which table a provisional barcode was assigned to
+type EntityType string
+
+const (
+	EntityTypeBooking       EntityType = "booking"
+	EntityTypeParcelBooking EntityType = "parcel_booking"
+)
+
+// ProvisionalBarcodeStatus represents the reconciliation state
+type ProvisionalBarcodeStatus string
+
+const (
+	ProvisionalBarcodeStatusPending    ProvisionalBarcodeStatus = "pending"
+	ProvisionalBarcodeStatusReconciled ProvisionalBarcodeStatus = "reconciled"
+)
+
+// PooledBarcode is a DMS barcode fetched ahead of time for a branch so intake does not
+// wait on DMS. Available rows are handed out oldest first and marked consumed.
+type PooledBarcode struct {
+	ID         uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	Barcode    string              `gorm:"type:varchar(50);not null;uniqueIndex" json:"barcode"`
+	BranchCode string              `gorm:"type:varchar(50);not null;index:idx_pooled_barcodes_branch_status" json:"branch_code"`
+	Status     PooledBarcodeStatus `gorm:"type:varchar(20);not null;default:available;index:idx_pooled_barcodes_branch_status" json:"status"`
+	ConsumedAt *time.Time          `json:"consumed_at,omitempty"`
+	CreatedAt  time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// PooledBarcodeStatus is whether a pooled barcode has been handed out
+type PooledBarcodeStatus string
+
+const (
+	PooledBarcodeStatusAvailable PooledBarcodeStatus = "available"
+	PooledBarcodeStatusConsumed  PooledBarcodeStatus = "consumed"
+)
@@ -0,0 +1,55 @@
+package booking
+
+import (
+	"passport-booking/models/address"
+	"time"
+)
+
+// AddressChangeInitiator is who asked for the delivery address change
+type AddressChangeInitiator string
+
+const (
+	AddressChangeByApplicant AddressChangeInitiator = "applicant" // confirmed by OTP on the delivery phone
+	AddressChangeByOperator  AddressChangeInitiator = "operator"
+)
+
+// AddressChangeStatus is the state of an address change request
+type AddressChangeStatus string
+
+const (
+	AddressChangeStatusPending  AddressChangeStatus = "pending" // waiting for the applicant's OTP
+	AddressChangeStatusApplied  AddressChangeStatus = "applied"
+	AddressChangeStatusFailed   AddressChangeStatus = "failed" // DMS refused the re-route
+	AddressChangeStatusCanceled AddressChangeStatus = "canceled"
+)
+
+// AddressChange redirects a booking to another delivery address after booking. The
+// old address row is kept so the before and after addresses stay on record.
+type AddressChange struct {
+	ID            uint                   `gorm:"primaryKey;autoIncrement" json:"id"`
+	BookingID     uint                   `gorm:"not null;index" json:"booking_id"`
+	InitiatedBy   AddressChangeInitiator `gorm:"type:varchar(20);not null" json:"initiated_by"`
+	RequestedByID uint                   `gorm:"not null;index" json:"requested_by_id"`
+	Reason        *string                `gorm:"type:text" json:"reason,omitempty"`
+	Status        AddressChangeStatus    `gorm:"type:varchar(20);not null;default:pending;index" json:"status"`
+
+	OldAddressID  *uint            `json:"old_address_id,omitempty"`
+	OldAddress    *address.Address `gorm:"foreignKey:OldAddressID" json:"old_address,omitempty"`
+	OldBranchCode *string          `gorm:"type:varchar(100)" json:"old_branch_code,omitempty"`
+	NewAddressID  uint             `gorm:"not null" json:"new_address_id"`
+	NewAddress    *address.Address `gorm:"foreignKey:NewAddressID" json:"new_address,omitempty"`
+	NewBranchCode string           `gorm:"type:varchar(100);not null" json:"new_branch_code"`
+
+	// DMS re-route outcome; empty when the booking had no barcode yet
+	RerouteStatusCode *int    `json:"reroute_status_code,omitempty"`
+	RerouteResponse   *string `gorm:"type:text" json:"reroute_response,omitempty"`
+
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the AddressChange model
+func (AddressChange) TableName() string {
+	return "booking_address_changes"
+}
@@ -0,0 +1,33 @@
+package booking
+
+import "time"
+
+// BagStatus is the local lifecycle state of a DMS bag
+type BagStatus string
+
+const (
+	BagStatusOpen     BagStatus = "open"
+	BagStatusClosed   BagStatus = "closed"
+	BagStatusReceived BagStatus = "received"
+)
+
+// Bag tracks what has been put into a DMS bag so its item-count and weight limits can
+// be enforced before DMS is called
+type Bag struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	BagID          string     `gorm:"type:varchar(100);not null;uniqueIndex" json:"bag_id"`
+	BagType        string     `gorm:"type:varchar(50)" json:"bag_type,omitempty"`
+	DestOfficeCode string     `gorm:"type:varchar(100)" json:"dest_office_code,omitempty"`
+	Status         BagStatus  `gorm:"type:varchar(20);not null;default:open;index" json:"status"`
+	ItemCount      int        `gorm:"not null;default:0" json:"item_count"`
+	WeightGrams    int        `gorm:"not null;default:0" json:"weight_grams"`
+	CreatedByID    uint       `gorm:"index" json:"created_by_id"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the Bag model
+func (Bag) TableName() string {
+	return "bags"
+}
@@ -11,10 +11,11 @@ type Booking struct {
 	ID uint `gorm:"primaryKey;autoIncrement" json:"id"`
 
 	// Foreign key for users relationship
-	UserID uint      `gorm:"not null" json:"user_id"`
+	UserID uint      `gorm:"not null;index" json:"user_id"`
 	User   user.User `gorm:"foreignKey:UserID" json:"user"`
 
 	AppOrOrderID string  `gorm:"type:varchar(255);not null;unique" json:"app_or_order_id"`
+	Reference    *string `gorm:"type:varchar(50);uniqueIndex" json:"reference,omitempty"` // e.g. PBK-2026-000123, issued at creation
 	CurrentBagID *string `gorm:"type:varchar(255);index" json:"current_bag_id,omitempty"`
 	Barcode      *string `gorm:"type:varchar(255)" json:"barcode,omitempty"`
 	Name         string  `gorm:"type:varchar(255);not null" json:"name"`
@@ -33,6 +34,9 @@ type Booking struct {
 	EmergencyContactName  *string `gorm:"type:varchar(255)" json:"emergency_contact_name,omitempty"`
 	EmergencyContactPhone *string `gorm:"type:varchar(20)" json:"emergency_contact_phone,omitempty"`
 	DeliveryBranchCode    *string `gorm:"type:varchar(100)" json:"delivery_branch_code,omitempty"`
+	// Postman device location captured at hand-over
+	DeliveredLatitude  *float64 `gorm:"type:double precision" json:"delivered_latitude,omitempty"`
+	DeliveredLongitude *float64 `gorm:"type:double precision" json:"delivered_longitude,omitempty"`
 	// Foreign key for address relationship
 	DeliveryAddressID *uint            `json:"delivery_address_id,omitempty"`
 	DeliveryAddress   *address.Address `gorm:"foreignKey:DeliveryAddressID" json:"delivery_address,omitempty"`
@@ -46,6 +50,38 @@ type Booking struct {
 	UpdatedAt   time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
 	DeletedAt   *time.Time    `gorm:"index" json:"deleted_at,omitempty"`     // Soft delete field
 	UploadPhoto *string       `gorm:"type:varchar(500)" json:"upload_photo"` // Photo path storage
+	// SHA-256 of the delivery photo, to catch one photo reused across bookings
+	UploadPhotoHash *string `gorm:"type:varchar(64);index" json:"-"`
+
+	// Delivery group shared with other bookings for the same recipient, if any
+	DeliveryGroupID *uint `gorm:"index" json:"delivery_group_id,omitempty"`
+
+	// Day the item is expected to leave its booking branch; rolled to the next working
+	// day when it was bagged after the branch cutoff
+	ExpectedDispatchDate *time.Time `gorm:"type:date" json:"expected_dispatch_date,omitempty"`
+	BaggedAfterCutoff    bool       `gorm:"default:false" json:"bagged_after_cutoff"`
+
+	// Why the postman brought the item back undelivered
+	ReturnReason *string `gorm:"type:text" json:"return_reason,omitempty"`
+
+	// Passport system that issued AppOrOrderID; IDs of uncertain format wait for an operator
+	SourceSystem      *string    `gorm:"type:varchar(50);index" json:"source_system,omitempty"`
+	AppIDNeedsReview  bool       `gorm:"default:false;index" json:"app_id_needs_review"`
+	AppIDReviewReason *string    `gorm:"type:text" json:"app_id_review_reason,omitempty"`
+	AppIDReviewedByID *uint      `json:"app_id_reviewed_by_id,omitempty"`
+	AppIDReviewedAt   *time.Time `json:"app_id_reviewed_at,omitempty"`
+
+	// Recipient NID check before hand-over; the number is stored encrypted
+	RecipientIDVerified   bool       `gorm:"default:false" json:"recipient_id_verified"`
+	RecipientNIDEncrypted *string    `gorm:"type:text" json:"-"`
+	RecipientNIDLast4     *string    `gorm:"type:varchar(4)" json:"recipient_nid_last4,omitempty"`
+	RecipientIDPhoto      *string    `gorm:"type:varchar(500)" json:"recipient_id_photo,omitempty"`
+	RecipientIDVerifiedAt *time.Time `json:"recipient_id_verified_at,omitempty"`
+
+	// Privacy: bookings under legal hold are never anonymized; AnonymizedAt is set once PII is erased
+	LegalHold       bool       `gorm:"default:false;index" json:"legal_hold"`
+	LegalHoldReason *string    `gorm:"type:text" json:"legal_hold_reason,omitempty"`
+	AnonymizedAt    *time.Time `gorm:"index" json:"anonymized_at,omitempty"`
 }
 
 // BookingStatus represents the status of a booking
@@ -59,6 +95,8 @@ const (
 	BookingStatusReceivedByPostman     BookingStatus = "bag_received_by_postman"
 	BookingStatusReceivedByPostMaster  BookingStatus = "received_by_postmaster"
 	BookingStatusReturn                BookingStatus = "return"
+	BookingStatusReturnInTransit       BookingStatus = "return_in_transit_to_rpo"
+	BookingStatusReturnedToRPO         BookingStatus = "returned_to_rpo"
 	BookingStatusDelivered             BookingStatus = "delivered"
 )
 
@@ -0,0 +1,26 @@
+package booking
+
+import "time"
+
+// BookingDeletion records an administrator soft-deleting a booking and, if it was
+// brought back, the restore. A booking deleted twice has two rows.
+type BookingDeletion struct {
+	ID        uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+	BookingID uint          `gorm:"not null;index" json:"booking_id"`
+	Status    BookingStatus `gorm:"type:varchar(30);not null" json:"status"` // status when deleted
+	Reason    string        `gorm:"type:text;not null" json:"reason"`
+	// DMS outbox entries held back while the booking is deleted
+	SuspendedEntries int        `gorm:"not null;default:0" json:"suspended_entries"`
+	DeletedByID      uint       `gorm:"not null" json:"deleted_by_id"`
+	DeletedAt        time.Time  `gorm:"not null;index" json:"deleted_at"`
+	RestoredByID     *uint      `json:"restored_by_id,omitempty"`
+	RestoredAt       *time.Time `json:"restored_at,omitempty"`
+	RestoreReason    *string    `gorm:"type:text" json:"restore_reason,omitempty"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the BookingDeletion model
+func (BookingDeletion) TableName() string {
+	return "booking_deletions"
+}
@@ -16,6 +16,7 @@ type BookingEvent struct {
 
 	// DO NOT make this unique here (events are many per booking)
 	AppOrOrderID  string  `gorm:"type:varchar(255);not null;index" json:"app_or_order_id"`
+	Reference     *string `gorm:"type:varchar(50);index" json:"reference,omitempty"`
 	CurrentBagID  *string `gorm:"type:varchar(255);index" json:"current_bag_id,omitempty"`
 	Barcode       *string `gorm:"type:varchar(255);index" json:"barcode,omitempty"`
 	Name          string  `gorm:"type:varchar(255);not null" json:"name"`
@@ -45,7 +46,18 @@ type BookingEvent struct {
 	EventType   string        `gorm:"type:varchar(50);not null;index" json:"event_type"` // created, updated, delivery_phone_send_otp, phone_applied_verified, otp_resent, etc.
 	CreatedBy   string        `gorm:"type:varchar(255);not null" json:"created_by"`
 	CreatedAt   time.Time     `gorm:"autoCreateTime;index" json:"created_at"`
-	UpdatedBy   string        `gorm:"type:varchar(255)" json:"updated_by,omitempty"`
-	UpdatedAt   time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt   *time.Time    `gorm:"index" json:"deleted_at,omitempty"`
+	UpdatedBy   string        `gorm:"type:varchar(255);index" json:"updated_by,omitempty"`
+	// Display name and role of UpdatedBy at write time
+	UpdatedByName string `gorm:"type:varchar(255)" json:"updated_by_name,omitempty"`
+	UpdatedByRole string `gorm:"type:varchar(50)" json:"updated_by_role,omitempty"`
+	// UUID of the admin acting through an impersonation token
+	ImpersonatedBy *string    `gorm:"type:varchar(255);index" json:"impersonated_by,omitempty"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt      *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+
+	// Compacted rows keep the columns above that identify the event but blank the
+	// applicant payload; Diff then holds the payload fields that changed since the
+	// previous event as JSON. See services/booking_event/compaction.go.
+	Compacted bool    `gorm:"default:false;index" json:"compacted"`
+	Diff      *string `gorm:"type:text" json:"diff,omitempty"`
 }
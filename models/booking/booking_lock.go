@@ -0,0 +1,27 @@
+package booking
+
+import "time"
+
+// LockPurpose describes why a booking is locked
+type LockPurpose string
+
+const (
+	LockPurposeDeliveryConfirmation LockPurpose = "delivery_confirmation"
+)
+
+// BookingLock is a short-lived soft lock held by one user while a multi-step flow
+// (e.g. delivery confirmation) is in progress. Expired rows are treated as free.
+type BookingLock struct {
+	ID        uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	BookingID uint        `gorm:"not null;uniqueIndex" json:"booking_id"`
+	HolderID  uint        `gorm:"not null;index" json:"holder_id"`
+	Purpose   LockPurpose `gorm:"type:varchar(50);not null" json:"purpose"`
+	ExpiresAt time.Time   `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the BookingLock model
+func (BookingLock) TableName() string {
+	return "booking_locks"
+}
@@ -0,0 +1,46 @@
+package booking
+
+import "time"
+
+// QuotaScope is what a booking quota limits
+type QuotaScope string
+
+const (
+	QuotaScopeUser   QuotaScope = "user"   // bookings one operator creates
+	QuotaScopeBranch QuotaScope = "branch" // bookings the operators mapped to a branch create together
+)
+
+// IsValid reports whether s is a known quota scope
+func (s QuotaScope) IsValid() bool {
+	return s == QuotaScopeUser || s == QuotaScopeBranch
+}
+
+// BookingQuota sets how many bookings a user or branch may create per local day,
+// replacing the default from the environment. An admin can raise or lower it for a
+// while with an override, e.g. for a planned bulk intake.
+type BookingQuota struct {
+	ID    uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Scope QuotaScope `gorm:"type:varchar(20);not null;uniqueIndex:idx_booking_quota_subject" json:"scope"`
+	// User ID or branch code
+	Subject string `gorm:"type:varchar(100);not null;uniqueIndex:idx_booking_quota_subject" json:"subject"`
+	// Nil keeps the default of the scope; zero is unlimited
+	DailyLimit *int `json:"daily_limit,omitempty"`
+
+	OverrideLimit  *int       `json:"override_limit,omitempty"`
+	OverrideUntil  *time.Time `json:"override_until,omitempty"` // exclusive
+	OverrideReason *string    `gorm:"type:text" json:"override_reason,omitempty"`
+
+	UpdatedByID uint      `gorm:"not null" json:"updated_by_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the BookingQuota model
+func (BookingQuota) TableName() string {
+	return "booking_quotas"
+}
+
+// OverrideActive reports whether the override replaces the limit at t
+func (q *BookingQuota) OverrideActive(t time.Time) bool {
+	return q.OverrideLimit != nil && q.OverrideUntil != nil && t.Before(*q.OverrideUntil)
+}
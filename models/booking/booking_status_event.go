@@ -14,8 +14,14 @@ type BookingStatusEvent struct {
 
 	Status    BookingStatus `gorm:"size:30;not null;index" json:"status"`
 	CreatedBy string        `gorm:"type:varchar(255);not null" json:"created_by"`
-	CreatedAt time.Time     `gorm:"autoCreateTime;index" json:"created_at"`
-	UpdatedAt time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+	// Display name and role of CreatedBy at write time, so timelines stay readable
+	// after the user is renamed or changes role
+	CreatedByName string `gorm:"type:varchar(255)" json:"created_by_name"`
+	CreatedByRole string `gorm:"type:varchar(50)" json:"created_by_role"`
+	// UUID of the admin acting through an impersonation token
+	ImpersonatedBy *string   `gorm:"type:varchar(255);index" json:"impersonated_by,omitempty"`
+	CreatedAt      time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 // TableName sets the table name for the BookingStatusEvent model
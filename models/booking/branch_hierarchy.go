@@ -0,0 +1,43 @@
+package booking
+
+import "time"
+
+// OrgLevel is the depth of a unit in the postal hierarchy
+type OrgLevel string
+
+const (
+	OrgLevelRegion   OrgLevel = "region"
+	OrgLevelDistrict OrgLevel = "district"
+	OrgLevelBranch   OrgLevel = "branch"
+)
+
+// ParentLevel is the level a unit of this level hangs under; empty for regions
+func (l OrgLevel) ParentLevel() OrgLevel {
+	switch l {
+	case OrgLevelDistrict:
+		return OrgLevelRegion
+	case OrgLevelBranch:
+		return OrgLevelDistrict
+	}
+	return ""
+}
+
+// OrgUnit is a node of the region → district → branch tree. Branch units use the
+// DMS branch code, so bookings roll up through their delivery_branch_code.
+type OrgUnit struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code        string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"code"`
+	Name        string    `gorm:"type:varchar(255);not null" json:"name"`
+	Level       OrgLevel  `gorm:"type:varchar(20);not null;index" json:"level"`
+	ParentID    *uint     `gorm:"index" json:"parent_id,omitempty"`
+	UpdatedByID uint      `json:"updated_by_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Parent *OrgUnit `gorm:"foreignKey:ParentID;constraint:OnDelete:RESTRICT" json:"-"`
+}
+
+// TableName sets the table name for the OrgUnit model
+func (OrgUnit) TableName() string {
+	return "org_units"
+}
@@ -0,0 +1,23 @@
+package booking
+
+import "time"
+
+// BranchSchedule holds a branch's operating hours and the daily cutoff after which
+// bags are no longer dispatched the same day
+type BranchSchedule struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	BranchCode string `gorm:"type:varchar(100);not null;uniqueIndex" json:"branch_code"`
+	OpensAt    string `gorm:"type:varchar(5);not null" json:"opens_at"`  // HH:MM
+	ClosesAt   string `gorm:"type:varchar(5);not null" json:"closes_at"` // HH:MM
+	CutoffAt   string `gorm:"type:varchar(5);not null" json:"cutoff_at"` // HH:MM, last bag close for same-day dispatch
+	// Comma-separated weekdays the branch works, 0 = Sunday
+	WorkingDays string    `gorm:"type:varchar(20);not null" json:"working_days"`
+	UpdatedByID uint      `json:"updated_by_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the BranchSchedule model
+func (BranchSchedule) TableName() string {
+	return "branch_schedules"
+}
@@ -0,0 +1,32 @@
+package booking
+
+import "time"
+
+// DeadLetterActionKind is what an administrator did with a dead-lettered item
+type DeadLetterActionKind string
+
+const (
+	DeadLetterReplay  DeadLetterActionKind = "replay"  // sent again, possibly after editing
+	DeadLetterDiscard DeadLetterActionKind = "discard" // dropped for good
+)
+
+// DeadLetterAction records one replay or discard of an item that failed for good in
+// the DMS outbox, the notification log or the DMS callback log. Queue and ItemID
+// point at the row in the queue's own table.
+type DeadLetterAction struct {
+	ID            uint                 `gorm:"primaryKey;autoIncrement" json:"id"`
+	Queue         string               `gorm:"type:varchar(20);not null;index:idx_dead_letter_actions_item" json:"queue"`
+	ItemID        uint                 `gorm:"not null;index:idx_dead_letter_actions_item" json:"item_id"`
+	Action        DeadLetterActionKind `gorm:"type:varchar(20);not null" json:"action"`
+	Reason        string               `gorm:"type:text" json:"reason,omitempty"`
+	Edited        bool                 `gorm:"not null;default:false" json:"edited"`
+	PayloadBefore string               `gorm:"type:text" json:"payload_before,omitempty"` // set when the replay edited the item
+	Error         string               `gorm:"type:text" json:"error,omitempty"`
+	ActorID       string               `gorm:"type:varchar(100);not null" json:"actor_id"`
+	CreatedAt     time.Time            `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName sets the table name for the DeadLetterAction model
+func (DeadLetterAction) TableName() string {
+	return "dead_letter_actions"
+}
@@ -0,0 +1,31 @@
+package booking
+
+import "time"
+
+// DeliveryGroupStatus is the lifecycle state of a delivery group
+type DeliveryGroupStatus string
+
+const (
+	DeliveryGroupStatusOpen      DeliveryGroupStatus = "open"
+	DeliveryGroupStatusDelivered DeliveryGroupStatus = "delivered"
+	DeliveryGroupStatusDissolved DeliveryGroupStatus = "dissolved"
+)
+
+// DeliveryGroup links bookings for the same recipient and address so the postman
+// confirms the OTP and takes the photo once for all of them
+type DeliveryGroup struct {
+	ID            uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	PostmanID     uint                `gorm:"not null;index" json:"postman_id"`
+	DeliveryPhone string              `gorm:"type:varchar(20);not null" json:"delivery_phone"`
+	Status        DeliveryGroupStatus `gorm:"type:varchar(20);not null;default:open;index" json:"status"`
+	DeliveredAt   *time.Time          `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Bookings []Booking `gorm:"foreignKey:DeliveryGroupID" json:"bookings,omitempty"`
+}
+
+// TableName sets the table name for the DeliveryGroup model
+func (DeliveryGroup) TableName() string {
+	return "delivery_groups"
+}
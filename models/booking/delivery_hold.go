@@ -0,0 +1,38 @@
+package booking
+
+import "time"
+
+// DeliveryHoldStatus is the state of a delivery hold
+type DeliveryHoldStatus string
+
+const (
+	DeliveryHoldStatusActive   DeliveryHoldStatus = "active"
+	DeliveryHoldStatusCanceled DeliveryHoldStatus = "canceled"
+)
+
+// DeliveryHold keeps an item at the branch between StartsAt and EndsAt at the
+// applicant's request, e.g. while they are away. Held time does not count against
+// delivery SLAs.
+type DeliveryHold struct {
+	ID            uint               `gorm:"primaryKey;autoIncrement" json:"id"`
+	BookingID     uint               `gorm:"not null;index" json:"booking_id"`
+	StartsAt      time.Time          `gorm:"not null;index" json:"starts_at"`
+	EndsAt        time.Time          `gorm:"not null;index" json:"ends_at"`
+	Reason        *string            `gorm:"type:text" json:"reason,omitempty"`
+	Status        DeliveryHoldStatus `gorm:"type:varchar(20);not null;default:active;index" json:"status"`
+	RequestedByID uint               `gorm:"not null" json:"requested_by_id"`
+	CanceledByID  *uint              `json:"canceled_by_id,omitempty"`
+	CanceledAt    *time.Time         `json:"canceled_at,omitempty"`
+	CreatedAt     time.Time          `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time          `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the DeliveryHold model
+func (DeliveryHold) TableName() string {
+	return "booking_delivery_holds"
+}
+
+// InEffect reports whether the hold keeps the item at the branch at t
+func (h *DeliveryHold) InEffect(t time.Time) bool {
+	return h.Status == DeliveryHoldStatusActive && !t.Before(h.StartsAt) && t.Before(h.EndsAt)
+}
@@ -0,0 +1,52 @@
+package booking
+
+import "time"
+
+// DMSStatusMapping maps an article status reported by DMS onto a local booking status.
+// Statuses without an active mapping are recorded but leave the booking untouched.
+type DMSStatusMapping struct {
+	ID            uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+	DMSStatus     string        `gorm:"type:varchar(100);not null;uniqueIndex" json:"dms_status"`
+	BookingStatus BookingStatus `gorm:"type:varchar(30);not null" json:"booking_status"`
+	Active        bool          `gorm:"not null;default:true" json:"active"`
+	UpdatedByID   uint          `gorm:"not null" json:"updated_by_id"`
+	CreatedAt     time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the DMSStatusMapping model
+func (DMSStatusMapping) TableName() string {
+	return "dms_status_mappings"
+}
+
+// DMSCallbackOutcome is what a status callback did to the booking
+type DMSCallbackOutcome string
+
+const (
+	DMSCallbackApplied   DMSCallbackOutcome = "applied"   // booking moved to the mapped status
+	DMSCallbackUnchanged DMSCallbackOutcome = "unchanged" // booking already had the mapped status
+	DMSCallbackUnmapped  DMSCallbackOutcome = "unmapped"  // no active mapping for the DMS status
+	DMSCallbackNotFound  DMSCallbackOutcome = "not_found" // no booking with the article barcode
+	DMSCallbackFinal     DMSCallbackOutcome = "final"     // booking is delivered or returned and is not moved
+	DMSCallbackIllegal   DMSCallbackOutcome = "illegal"   // mapped status does not follow the booking's status in the workflow
+	DMSCallbackStale     DMSCallbackOutcome = "stale"     // older than the last update applied to the booking
+)
+
+// DMSCallback records every status callback received from DMS. EventID is unique so a
+// redelivered callback is recognised and not applied twice.
+type DMSCallback struct {
+	ID         uint               `gorm:"primaryKey;autoIncrement" json:"id"`
+	EventID    string             `gorm:"type:varchar(128);not null;uniqueIndex" json:"event_id"`
+	Barcode    string             `gorm:"type:varchar(100);not null;index" json:"barcode"`
+	DMSStatus  string             `gorm:"type:varchar(100);not null" json:"dms_status"`
+	BookingID  *uint              `gorm:"index" json:"booking_id,omitempty"`
+	Outcome    DMSCallbackOutcome `gorm:"type:varchar(20);not null;index" json:"outcome"`
+	OccurredAt *time.Time         `json:"occurred_at,omitempty"`
+	Payload    string             `gorm:"type:text" json:"payload"`
+	CreatedAt  time.Time          `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName sets the table name for the DMSCallback model
+func (DMSCallback) TableName() string {
+	return "dms_callbacks"
+}
@@ -0,0 +1,62 @@
+package booking
+
+import "time"
+
+// DMSOutboxOperation is the DMS call an outbox entry replays
+type DMSOutboxOperation string
+
+const (
+	// DMSOutboxAddItem books the item in DMS if needed and adds it to the bag
+	DMSOutboxAddItem DMSOutboxOperation = "bag_add_item"
+)
+
+// DMSOutboxStatus is where an outbox entry is in its lifecycle
+type DMSOutboxStatus string
+
+const (
+	DMSOutboxPending    DMSOutboxStatus = "pending"    // waiting for DMS to come back
+	DMSOutboxProcessing DMSOutboxStatus = "processing" // claimed by the drain worker
+	DMSOutboxDone       DMSOutboxStatus = "done"       // replayed against DMS
+	DMSOutboxFailed     DMSOutboxStatus = "failed"     // rejected by DMS or out of attempts
+	DMSOutboxDiscarded  DMSOutboxStatus = "discarded"  // failed and dropped by an administrator
+	DMSOutboxSuspended  DMSOutboxStatus = "suspended"  // held while its booking is deleted
+)
+
+// DMSOutboxEntry is intake work accepted while DMS was unavailable. The booking stays
+// in its pre-DMS state until the drain worker replays the entry.
+type DMSOutboxEntry struct {
+	ID            uint               `gorm:"primaryKey;autoIncrement" json:"id"`
+	Operation     DMSOutboxOperation `gorm:"type:varchar(30);not null" json:"operation"`
+	BookingID     uint               `gorm:"not null;index" json:"booking_id"`
+	BagID         string             `gorm:"type:varchar(100);index" json:"bag_id"`
+	Payload       string             `gorm:"type:text;not null" json:"payload"`
+	Status        DMSOutboxStatus    `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	Attempts      int                `gorm:"not null;default:0" json:"attempts"`
+	LastError     *string            `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `gorm:"not null;index" json:"next_attempt_at"`
+	LockedUntil   *time.Time         `json:"locked_until,omitempty"`
+	CompletedAt   *time.Time         `json:"completed_at,omitempty"`
+	CreatedBy     string             `gorm:"type:varchar(100);not null" json:"created_by"`
+	CreatedAt     time.Time          `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time          `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the DMSOutboxEntry model
+func (DMSOutboxEntry) TableName() string {
+	return "dms_outbox_entries"
+}
+
+// DMSOutboxAttempt is the error of one failed replay of an outbox entry, kept so the
+// dead-letter browser can show how an entry got there
+type DMSOutboxAttempt struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	EntryID   uint      `gorm:"not null;index" json:"entry_id"`
+	Attempt   int       `gorm:"not null" json:"attempt"`
+	Error     string    `gorm:"type:text;not null" json:"error"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName sets the table name for the DMSOutboxAttempt model
+func (DMSOutboxAttempt) TableName() string {
+	return "dms_outbox_attempts"
+}
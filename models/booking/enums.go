@@ -1,22 +1,58 @@
 package booking
 
+import "fmt"
+
 // Helper methods for BookingStatus
 func (bs BookingStatus) String() string {
 	return string(bs)
 }
 
 func (bs BookingStatus) IsValid() bool {
-	switch bs {
-	case BookingStatusInitial, BookingStatusPreBooked, BookingStatusBooked, BookingStatusReceivedByPostman, BookingStatusReturn, BookingStatusDelivered:
-		return true
-	default:
-		return false
+	for _, status := range GetAllBookingStatuses() {
+		if bs == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransitionTo reports whether the booking workflow moves from bs to next
+func (bs BookingStatus) CanTransitionTo(next BookingStatus) bool {
+	for _, status := range BookingStatusTransitions()[bs] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is returned when a status write is not a move of the booking workflow
+type TransitionError struct {
+	From BookingStatus
+	To   BookingStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("booking cannot move from %s to %s", e.From, e.To)
+}
+
+// CheckTransition returns *TransitionError unless the workflow moves from bs to next.
+// Every write of a booking's status goes through it.
+func (bs BookingStatus) CheckTransition(next BookingStatus) error {
+	if bs.CanTransitionTo(next) {
+		return nil
 	}
+	return &TransitionError{From: bs, To: next}
 }
 
 // IsCompleted returns true if the booking is in a completed state
 func (bs BookingStatus) IsCompleted() bool {
-	return bs == BookingStatusDelivered || bs == BookingStatusReturn
+	return bs == BookingStatusDelivered || bs.IsReturn()
+}
+
+// IsReturn returns true once the item is on its way back to, or back at, the regional passport office
+func (bs BookingStatus) IsReturn() bool {
+	return bs == BookingStatusReturn || bs == BookingStatusReturnInTransit || bs == BookingStatusReturnedToRPO
 }
 
 // CanBePrinted returns true if the booking can be printed
@@ -26,7 +62,7 @@ func (bs BookingStatus) CanBePrinted() bool {
 
 // CanBeUpdated returns true if the booking status can be updated
 func (bs BookingStatus) CanBeUpdated() bool {
-	return bs != BookingStatusDelivered && bs != BookingStatusReturn
+	return !bs.IsCompleted()
 }
 
 // GetAllBookingStatuses returns all valid booking statuses
@@ -37,7 +73,28 @@ func GetAllBookingStatuses() []BookingStatus {
 		BookingStatusBooked,
 		BookingStatusReceivedByPostMaster,
 		BookingStatusReceivedByPostman,
+		BookingItemStatusReceivedByPostman,
 		BookingStatusReturn,
+		BookingStatusReturnInTransit,
+		BookingStatusReturnedToRPO,
 		BookingStatusDelivered,
 	}
 }
+
+// BookingStatusTransitions returns the statuses the workflow moves each status to.
+// Delivered and returned bookings are final and have none. Status changes from DMS
+// callbacks and auto-heal are held to the same table.
+func BookingStatusTransitions() map[BookingStatus][]BookingStatus {
+	return map[BookingStatus][]BookingStatus{
+		BookingStatusInitial:               {BookingStatusPreBooked},
+		BookingStatusPreBooked:             {BookingStatusBooked},
+		BookingStatusBooked:                {BookingStatusReceivedByPostMaster, BookingStatusReceivedByPostman, BookingItemStatusReceivedByPostman},
+		BookingStatusReceivedByPostMaster:  {BookingStatusReceivedByPostman, BookingItemStatusReceivedByPostman},
+		BookingStatusReceivedByPostman:     {BookingItemStatusReceivedByPostman, BookingStatusDelivered},
+		BookingItemStatusReceivedByPostman: {BookingStatusDelivered, BookingStatusReturn},
+		BookingStatusReturn:                {BookingStatusReturnInTransit},
+		BookingStatusReturnInTransit:       {BookingStatusReturnedToRPO},
+		BookingStatusDelivered:             {},
+		BookingStatusReturnedToRPO:         {},
+	}
+}
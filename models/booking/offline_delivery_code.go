@@ -0,0 +1,25 @@
+package booking
+
+import "time"
+
+// OfflineDeliveryCode is a one-time code handed to the applicant before delivery to
+// an address without coverage. The postman enters it offline in place of the live
+// OTP and it is checked when the postman syncs. Only a keyed hash of the code is kept.
+type OfflineDeliveryCode struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	BookingID  uint       `gorm:"not null;index" json:"booking_id"`
+	CodeHash   string     `gorm:"type:varchar(64);not null" json:"-"`
+	Channel    string     `gorm:"type:varchar(10);not null" json:"channel"` // sms or print
+	IssuedByID uint       `gorm:"not null" json:"issued_by_id"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	Attempts   int        `gorm:"not null;default:0" json:"attempts"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	UsedByID   *uint      `json:"used_by_id,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName sets the table name for the OfflineDeliveryCode model
+func (OfflineDeliveryCode) TableName() string {
+	return "offline_delivery_codes"
+}
@@ -0,0 +1,25 @@
+package booking
+
+import "time"
+
+// OfflineSyncAction records the outcome of one action a postman performed offline
+// and synced later. The postman's action ID is unique, so a bundle sent again
+// after a dropped response gets its earlier results instead of being replayed.
+type OfflineSyncAction struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	PostmanID   uint      `gorm:"not null;uniqueIndex:idx_offline_sync_actions_postman_action" json:"postman_id"`
+	ActionID    string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_offline_sync_actions_postman_action" json:"action_id"`
+	BundleID    string    `gorm:"type:varchar(64);not null;index" json:"bundle_id"`
+	Type        string    `gorm:"type:varchar(30);not null" json:"type"`
+	Barcode     string    `gorm:"type:varchar(255);not null" json:"barcode"`
+	BookingID   *uint     `gorm:"index" json:"booking_id,omitempty"`
+	Result      string    `gorm:"type:varchar(20);not null;index" json:"result"`
+	Message     string    `gorm:"type:text" json:"message,omitempty"`
+	PerformedAt time.Time `gorm:"not null" json:"performed_at"`
+	SyncedAt    time.Time `gorm:"autoCreateTime" json:"synced_at"`
+}
+
+// TableName sets the table name for the OfflineSyncAction model
+func (OfflineSyncAction) TableName() string {
+	return "offline_sync_actions"
+}
@@ -0,0 +1,32 @@
+package booking
+
+import "time"
+
+// ConsentChannel is how the applicant gave consent
+type ConsentChannel string
+
+const (
+	ConsentChannelWeb           ConsentChannel = "web"
+	ConsentChannelMobileApp     ConsentChannel = "mobile_app"
+	ConsentChannelAgentAssisted ConsentChannel = "agent_assisted"
+)
+
+// PhoneConsent records the applicant's explicit consent to be contacted on a delivery
+// phone. Rows are append-only; the latest row for the booking's current phone applies.
+type PhoneConsent struct {
+	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	BookingID   uint           `gorm:"not null;index" json:"booking_id"`
+	Phone       string         `gorm:"type:varchar(20);not null" json:"phone"`
+	Channel     ConsentChannel `gorm:"type:varchar(30);not null" json:"channel"`
+	TextVersion string         `gorm:"type:varchar(50);not null" json:"text_version"`
+	RecordedBy  string         `gorm:"type:varchar(255);not null" json:"recorded_by"`
+	IPAddress   string         `gorm:"type:varchar(64)" json:"ip_address"`
+	UserAgent   string         `gorm:"type:varchar(500)" json:"user_agent"`
+	ConsentedAt time.Time      `gorm:"not null;index" json:"consented_at"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName sets the table name for the PhoneConsent model
+func (PhoneConsent) TableName() string {
+	return "booking_phone_consents"
+}
@@ -0,0 +1,17 @@
+package booking
+
+import "time"
+
+// ReferenceSequence holds the last booking reference number issued for a prefix and
+// year; numbering restarts at 1 each year
+type ReferenceSequence struct {
+	Prefix    string    `gorm:"type:varchar(20);primaryKey" json:"prefix"`
+	Year      int       `gorm:"primaryKey;autoIncrement:false" json:"year"`
+	LastValue int64     `gorm:"not null;default:0" json:"last_value"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the ReferenceSequence model
+func (ReferenceSequence) TableName() string {
+	return "booking_reference_sequences"
+}
@@ -0,0 +1,62 @@
+package booking
+
+import "time"
+
+// ReturnManifestStatus is the state of a return bag sent back to a regional passport office
+type ReturnManifestStatus string
+
+const (
+	ReturnManifestStatusOpen         ReturnManifestStatus = "open"         // items are being bagged
+	ReturnManifestStatusDispatched   ReturnManifestStatus = "dispatched"   // bag left the branch
+	ReturnManifestStatusAcknowledged ReturnManifestStatus = "acknowledged" // RPO confirmed what it received
+)
+
+// ReturnItemStatus is the state of one returned booking on a manifest
+type ReturnItemStatus string
+
+const (
+	ReturnItemStatusBagged   ReturnItemStatus = "bagged"
+	ReturnItemStatusReceived ReturnItemStatus = "received"
+	ReturnItemStatusMissing  ReturnItemStatus = "missing" // not in the bag when the RPO opened it
+)
+
+// ReturnManifest lists the undelivered passports a branch bags back to a regional
+// passport office, and the office's acknowledgment of what arrived
+type ReturnManifest struct {
+	ID               uint                 `gorm:"primaryKey;autoIncrement" json:"id"`
+	ManifestNo       string               `gorm:"type:varchar(50);uniqueIndex" json:"manifest_no"`
+	RPOID            uint                 `gorm:"column:rpo_id;not null;index" json:"rpo_id"`
+	BranchCode       *string              `gorm:"type:varchar(100);index" json:"branch_code,omitempty"`
+	Status           ReturnManifestStatus `gorm:"type:varchar(20);not null;default:open;index" json:"status"`
+	CreatedByID      uint                 `gorm:"not null" json:"created_by_id"`
+	DispatchedByID   *uint                `json:"dispatched_by_id,omitempty"`
+	DispatchedAt     *time.Time           `json:"dispatched_at,omitempty"`
+	AcknowledgedByID *uint                `json:"acknowledged_by_id,omitempty"`
+	AcknowledgedAt   *time.Time           `json:"acknowledged_at,omitempty"`
+	AcknowledgeNote  *string              `gorm:"type:text" json:"acknowledge_note,omitempty"`
+	CreatedAt        time.Time            `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time            `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Items []ReturnManifestItem `gorm:"foreignKey:ManifestID" json:"items,omitempty"`
+}
+
+// TableName sets the table name for the ReturnManifest model
+func (ReturnManifest) TableName() string {
+	return "return_manifests"
+}
+
+// ReturnManifestItem is one booking on a return manifest
+type ReturnManifestItem struct {
+	ID         uint             `gorm:"primaryKey;autoIncrement" json:"id"`
+	ManifestID uint             `gorm:"not null;index;uniqueIndex:idx_return_manifest_booking" json:"manifest_id"`
+	BookingID  uint             `gorm:"not null;index;uniqueIndex:idx_return_manifest_booking" json:"booking_id"`
+	Booking    *Booking         `gorm:"foreignKey:BookingID" json:"booking,omitempty"`
+	Status     ReturnItemStatus `gorm:"type:varchar(20);not null;default:bagged" json:"status"`
+	CreatedAt  time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time        `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the ReturnManifestItem model
+func (ReturnManifestItem) TableName() string {
+	return "return_manifest_items"
+}
@@ -0,0 +1,64 @@
+package booking
+
+import "time"
+
+// StockIncidentKind is the kind of discrepancy a stock audit found
+type StockIncidentKind string
+
+const (
+	StockIncidentMissing    StockIncidentKind = "missing"    // expected at the branch but not scanned
+	StockIncidentUnexpected StockIncidentKind = "unexpected" // scanned but not expected at the branch
+)
+
+// StockIncidentStatus is the follow-up state of a stock incident
+type StockIncidentStatus string
+
+const (
+	StockIncidentStatusOpen     StockIncidentStatus = "open"
+	StockIncidentStatusResolved StockIncidentStatus = "resolved"
+)
+
+// StockAudit is one physical stock-take at a branch: the barcodes scanned on the shelf
+// reconciled against the items the system expects the branch to hold
+type StockAudit struct {
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	BranchCode      string    `gorm:"type:varchar(100);not null;index" json:"branch_code"`
+	ExpectedCount   int       `gorm:"not null" json:"expected_count"`
+	ScannedCount    int       `gorm:"not null" json:"scanned_count"`
+	MatchedCount    int       `gorm:"not null" json:"matched_count"`
+	MissingCount    int       `gorm:"not null" json:"missing_count"`
+	UnexpectedCount int       `gorm:"not null" json:"unexpected_count"`
+	Note            *string   `gorm:"type:text" json:"note,omitempty"`
+	CreatedByID     uint      `gorm:"not null" json:"created_by_id"`
+	CreatedAt       time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+
+	Incidents []StockIncident `gorm:"foreignKey:AuditID" json:"incidents,omitempty"`
+}
+
+// TableName sets the table name for the StockAudit model
+func (StockAudit) TableName() string {
+	return "stock_audits"
+}
+
+// StockIncident is a discrepancy raised by a stock audit. An open incident is not
+// raised again by later audits, and a missing item that turns up closes its incident.
+type StockIncident struct {
+	ID             uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	AuditID        uint                `gorm:"not null;index" json:"audit_id"`
+	BranchCode     string              `gorm:"type:varchar(100);not null;index" json:"branch_code"`
+	Kind           StockIncidentKind   `gorm:"type:varchar(20);not null" json:"kind"`
+	Barcode        string              `gorm:"type:varchar(255);not null;index" json:"barcode"`
+	BookingID      *uint               `gorm:"index" json:"booking_id,omitempty"` // nil when the barcode matches no booking
+	BookingStatus  *BookingStatus      `gorm:"type:varchar(50)" json:"booking_status,omitempty"`
+	Status         StockIncidentStatus `gorm:"type:varchar(20);not null;default:open;index" json:"status"`
+	ResolvedByID   *uint               `json:"resolved_by_id,omitempty"`
+	ResolvedAt     *time.Time          `json:"resolved_at,omitempty"`
+	ResolutionNote *string             `gorm:"type:text" json:"resolution_note,omitempty"`
+	CreatedAt      time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the StockIncident model
+func (StockIncident) TableName() string {
+	return "stock_incidents"
+}
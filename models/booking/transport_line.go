@@ -0,0 +1,67 @@
+package booking
+
+import "time"
+
+// TransportLine is a registered mail line bags are received onto; Code is what DMS
+// takes as line_id
+type TransportLine struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code         string `gorm:"type:varchar(50);not null;uniqueIndex" json:"code"`
+	Name         string `gorm:"type:varchar(150);not null" json:"name"`
+	OriginOffice string `gorm:"type:varchar(100);not null;index" json:"origin_office"`
+	// Bags the line carries per day across all its departures
+	DailyCapacity int       `gorm:"not null;default:0" json:"daily_capacity"`
+	Active        bool      `gorm:"not null;default:true" json:"active"`
+	UpdatedByID   uint      `json:"updated_by_id"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Offices   []TransportLineOffice   `gorm:"foreignKey:LineID;constraint:OnDelete:CASCADE" json:"offices,omitempty"`
+	Schedules []TransportLineSchedule `gorm:"foreignKey:LineID;constraint:OnDelete:CASCADE" json:"schedules,omitempty"`
+}
+
+// TableName sets the table name for the TransportLine model
+func (TransportLine) TableName() string {
+	return "transport_lines"
+}
+
+// TransportLineOffice is a destination office a line serves
+type TransportLineOffice struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	LineID     uint   `gorm:"not null;uniqueIndex:idx_line_office" json:"line_id"`
+	OfficeCode string `gorm:"type:varchar(100);not null;uniqueIndex:idx_line_office;index" json:"office_code"`
+}
+
+// TableName sets the table name for the TransportLineOffice model
+func (TransportLineOffice) TableName() string {
+	return "transport_line_offices"
+}
+
+// TransportLineSchedule is one weekly departure of a line
+type TransportLineSchedule struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	LineID    uint   `gorm:"not null;index" json:"line_id"`
+	Weekday   int    `gorm:"not null" json:"weekday"`                    // 0 = Sunday
+	DepartsAt string `gorm:"type:varchar(5);not null" json:"departs_at"` // HH:MM
+}
+
+// TableName sets the table name for the TransportLineSchedule model
+func (TransportLineSchedule) TableName() string {
+	return "transport_line_schedules"
+}
+
+// TransportLineLoad records a bag received onto a line, counted against its daily capacity
+type TransportLineLoad struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	LineID      uint      `gorm:"not null;uniqueIndex:idx_line_load_bag;index:idx_line_load_date" json:"line_id"`
+	BagID       string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_line_load_bag" json:"bag_id"`
+	OfficeCode  string    `gorm:"type:varchar(100)" json:"office_code,omitempty"`
+	LoadDate    time.Time `gorm:"type:date;not null;index:idx_line_load_date" json:"load_date"`
+	CreatedByID uint      `gorm:"index" json:"created_by_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName sets the table name for the TransportLineLoad model
+func (TransportLineLoad) TableName() string {
+	return "transport_line_loads"
+}
@@ -0,0 +1,46 @@
+package evidence
+
+import (
+	"passport-booking/models/booking"
+	"passport-booking/models/user"
+	"time"
+)
+
+// RequestStatus is the lifecycle state of an evidence request
+type RequestStatus string
+
+const (
+	RequestStatusPending  RequestStatus = "pending"
+	RequestStatusApproved RequestStatus = "approved"
+	RequestStatusRevealed RequestStatus = "revealed"
+)
+
+// EvidenceRequest asks to decrypt the delivery confirmation OTP of a booking for a dispute.
+// It can only be revealed after the required number of admins other than the requester approve it.
+type EvidenceRequest struct {
+	ID uint `gorm:"primaryKey;autoIncrement" json:"id"`
+
+	BookingID uint            `gorm:"not null;index" json:"booking_id"`
+	Booking   booking.Booking `gorm:"foreignKey:BookingID" json:"-"`
+
+	Reason        string        `gorm:"type:text;not null" json:"reason"`
+	Status        RequestStatus `gorm:"type:varchar(20);not null;default:pending;index" json:"status"`
+	RequestedByID uint          `gorm:"not null;index" json:"requested_by_id"`
+	RequestedBy   user.User     `gorm:"foreignKey:RequestedByID" json:"-"`
+
+	Approvals []EvidenceApproval `gorm:"foreignKey:RequestID" json:"approvals"`
+
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+	RevealedAt *time.Time `json:"revealed_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// EvidenceApproval is one admin's sign-off on an evidence request
+type EvidenceApproval struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RequestID  uint      `gorm:"not null;uniqueIndex:idx_evidence_approvals_request_approver" json:"request_id"`
+	ApproverID uint      `gorm:"not null;uniqueIndex:idx_evidence_approvals_request_approver" json:"approver_id"`
+	Approver   user.User `gorm:"foreignKey:ApproverID" json:"-"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,47 @@
+package evidence
+
+import (
+	"passport-booking/models/user"
+	"time"
+)
+
+// ExportStatus is the lifecycle state of an evidence export job
+type ExportStatus string
+
+const (
+	ExportStatusQueued    ExportStatus = "queued"
+	ExportStatusRunning   ExportStatus = "running"
+	ExportStatusCompleted ExportStatus = "completed"
+	ExportStatusFailed    ExportStatus = "failed"
+)
+
+// ExportJob builds an encrypted archive of the delivery evidence of one booking, or
+// of the bookings delivered in a date range, for the passport office's audit team.
+// The archive password is returned once when the job is created and never stored.
+type ExportJob struct {
+	ID uint `gorm:"primaryKey;autoIncrement" json:"id"`
+
+	BookingID *uint      `gorm:"index" json:"booking_id,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"` // exclusive
+	Reason    string     `gorm:"type:text;not null" json:"reason"`
+
+	Status        ExportStatus `gorm:"type:varchar(20);not null;default:queued;index" json:"status"`
+	RequestedByID uint         `gorm:"not null;index" json:"requested_by_id"`
+	RequestedBy   user.User    `gorm:"foreignKey:RequestedByID" json:"-"`
+
+	BookingCount int    `json:"booking_count"`
+	FilePath     string `gorm:"type:varchar(500)" json:"-"`
+	FileSize     int64  `json:"file_size"`
+	Error        string `gorm:"type:text" json:"error,omitempty"`
+
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the ExportJob model
+func (ExportJob) TableName() string {
+	return "evidence_export_jobs"
+}
@@ -0,0 +1,60 @@
+package fraud
+
+import "time"
+
+// RuleAction is what happens to a delivery when a rule fires
+type RuleAction string
+
+const (
+	RuleActionFlag  RuleAction = "flag"  // delivery goes through, case is opened for review
+	RuleActionBlock RuleAction = "block" // delivery is held until a supervisor releases it
+)
+
+// CaseStatus is the review state of a fraud case
+type CaseStatus string
+
+const (
+	CaseStatusOpen     CaseStatus = "open"
+	CaseStatusReleased CaseStatus = "released" // supervisor allowed the delivery
+	CaseStatusRejected CaseStatus = "rejected" // supervisor confirmed the suspicion
+)
+
+// FraudRule configures one rule of the delivery anti-fraud engine. The rule logic is
+// registered in code under Code; Params holds its JSON encoded thresholds.
+type FraudRule struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code        string     `gorm:"type:varchar(100);not null;uniqueIndex" json:"code"`
+	Description string     `gorm:"type:text" json:"description"`
+	Enabled     bool       `gorm:"default:true" json:"enabled"`
+	Action      RuleAction `gorm:"type:varchar(20);not null" json:"action"`
+	Params      string     `gorm:"type:text;not null;default:'{}'" json:"params"`
+	UpdatedBy   string     `gorm:"type:varchar(255)" json:"updated_by,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the FraudRule model
+func (FraudRule) TableName() string {
+	return "fraud_rules"
+}
+
+// FraudCase records the rules that fired on a delivery attempt and its review
+type FraudCase struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	BookingID    uint       `gorm:"not null;index" json:"booking_id"`
+	PostmanID    uint       `gorm:"not null;index" json:"postman_id"`
+	Action       RuleAction `gorm:"type:varchar(20);not null" json:"action"`
+	RuleCodes    string     `gorm:"type:text;not null" json:"rule_codes"` // comma separated
+	Details      string     `gorm:"type:text" json:"details"`             // JSON encoded rule hits
+	Status       CaseStatus `gorm:"type:varchar(20);not null;default:open;index" json:"status"`
+	ReviewedByID *uint      `gorm:"index" json:"reviewed_by_id,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote   *string    `gorm:"type:text" json:"review_note,omitempty"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the FraudCase model
+func (FraudCase) TableName() string {
+	return "fraud_cases"
+}
@@ -14,5 +14,6 @@ type Log struct {
 	ResponseBody    string    `gorm:"type:text" json:"response_body"`
 	ResponseHeaders string    `gorm:"type:text" json:"response_headers"`
 	StatusCode      int       `gorm:"type:int" json:"status_code"`
+	ImpersonatedBy  *string   `gorm:"type:varchar(255);index" json:"impersonated_by,omitempty"`
 	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
\ No newline at end of file
@@ -0,0 +1,46 @@
+package notification
+
+import "time"
+
+// Channel is the medium a notification went out through
+type Channel string
+
+const (
+	ChannelSMS   Channel = "sms"
+	ChannelEmail Channel = "email"
+)
+
+// DeliveryStatus is the outcome of handing a notification to the provider
+type DeliveryStatus string
+
+const (
+	DeliveryStatusSent   DeliveryStatus = "sent"
+	DeliveryStatusFailed DeliveryStatus = "failed"
+)
+
+// NotificationLog records one notification handed to a provider on one channel.
+// Payload keeps the template data so a failed send can be rebuilt and resent.
+type NotificationLog struct {
+	ID               uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	BookingID        *uint          `gorm:"index" json:"booking_id,omitempty"`
+	UserID           uint           `gorm:"not null;index" json:"user_id"`
+	Kind             string         `gorm:"type:varchar(50);not null;index" json:"kind"`
+	Channel          Channel        `gorm:"type:varchar(20);not null" json:"channel"`
+	Recipient        string         `gorm:"type:varchar(255);not null" json:"recipient"`
+	Language         string         `gorm:"type:varchar(5);not null" json:"language"`
+	TemplateVersion  int            `gorm:"not null;default:0" json:"template_version"` // 0 is the built-in text
+	Subject          string         `gorm:"type:varchar(255)" json:"subject,omitempty"`
+	Body             string         `gorm:"type:text;not null" json:"body"`
+	Payload          string         `gorm:"type:text" json:"-"`
+	Status           DeliveryStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	ProviderResponse string         `gorm:"type:text" json:"provider_response,omitempty"`
+	Error            string         `gorm:"type:text" json:"error,omitempty"`
+	ResendOfID       *uint          `gorm:"index" json:"resend_of_id,omitempty"`
+	ResentByID       *uint          `json:"resent_by_id,omitempty"`
+	CreatedAt        time.Time      `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName sets the table name for the NotificationLog model
+func (NotificationLog) TableName() string {
+	return "notification_logs"
+}
@@ -0,0 +1,26 @@
+package notification
+
+import "time"
+
+// MessageTemplate is one version of the wording of a notification kind in one
+// language. Saving a template adds a new version; at most one version per kind and
+// language is active, and kinds without an active version use the built-in text.
+type MessageTemplate struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Kind        string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_message_templates_version" json:"kind"`
+	Language    string    `gorm:"type:varchar(5);not null;uniqueIndex:idx_message_templates_version" json:"language"`
+	Version     int       `gorm:"not null;uniqueIndex:idx_message_templates_version" json:"version"`
+	Subject     string    `gorm:"type:varchar(255);not null" json:"subject"`
+	Email       string    `gorm:"type:text;not null" json:"email"`
+	SMS         string    `gorm:"type:text;not null" json:"sms"`
+	Active      bool      `gorm:"not null;default:false;index" json:"active"`
+	Note        string    `gorm:"type:text" json:"note,omitempty"`
+	CreatedByID uint      `gorm:"not null;index" json:"created_by_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the MessageTemplate model
+func (MessageTemplate) TableName() string {
+	return "message_templates"
+}
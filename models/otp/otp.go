@@ -21,9 +21,16 @@ type OTP struct {
 	IsBlocked     bool       `gorm:"default:false" json:"is_blocked"`
 	BlockedUntil  *time.Time `gorm:"index" json:"blocked_until,omitempty"`
 	LastAttemptAt *time.Time `gorm:"index" json:"last_attempt_at,omitempty"`
+	ResendCount   int        `gorm:"default:0" json:"resend_count"`
+	LastSentAt    *time.Time `json:"last_sent_at,omitempty"`
 	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
-	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Delivery confirmation OTPs are bound to the postman and device that requested them
+	SessionTokenHash *string `gorm:"type:varchar(64);index" json:"-"`
+	IssuedToID       *uint   `gorm:"index" json:"issued_to_id,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 // OTPPurpose represents the purpose of the OTP
@@ -32,20 +39,40 @@ type OTPPurpose string
 const (
 	OTPPurposeDeliveryApplyPhone   OTPPurpose = "delivery_phone_apply_verification"
 	OTPPurposeDeliveryConfirmPhone OTPPurpose = "delivery_phone_confirm_verification"
+	OTPPurposeAddressChange        OTPPurpose = "delivery_address_change_verification"
+	OTPPurposeParcelDelivery       OTPPurpose = "parcel_delivery_verification"
 )
 
+// BlockDuration is how long an OTP stays blocked after its retries run out
+const BlockDuration = 15 * time.Minute
+
 // IsExpired checks if the OTP has expired
 func (o *OTP) IsExpired() bool {
-	return time.Now().After(o.ExpiresAt)
+	return o.IsExpiredAt(time.Now())
+}
+
+// IsExpiredAt checks if the OTP has expired at now
+func (o *OTP) IsExpiredAt(now time.Time) bool {
+	return now.After(o.ExpiresAt)
 }
 
 // IsValid checks if the OTP is valid (not used and not expired)
 func (o *OTP) IsValid() bool {
-	return !o.IsUsed && !o.IsExpired() && !o.IsBlocked
+	return o.IsValidAt(time.Now())
+}
+
+// IsValidAt checks if the OTP is valid at now
+func (o *OTP) IsValidAt(now time.Time) bool {
+	return !o.IsUsed && !o.IsExpiredAt(now) && !o.IsBlocked
 }
 
 // IsBlocked checks if the OTP is blocked due to too many retry attempts
 func (o *OTP) IsCurrentlyBlocked() bool {
+	return o.IsBlockedAt(time.Now())
+}
+
+// IsBlockedAt checks if the OTP is blocked at now
+func (o *OTP) IsBlockedAt(now time.Time) bool {
 	if !o.IsBlocked {
 		return false
 	}
@@ -56,7 +83,7 @@ func (o *OTP) IsCurrentlyBlocked() bool {
 	}
 
 	// Check if the block period has expired
-	if time.Now().After(*o.BlockedUntil) {
+	if now.After(*o.BlockedUntil) {
 		return false
 	}
 
@@ -65,20 +92,28 @@ func (o *OTP) IsCurrentlyBlocked() bool {
 
 // CanRetry checks if the OTP can be retried
 func (o *OTP) CanRetry() bool {
-	return !o.IsUsed && !o.IsExpired() && !o.IsCurrentlyBlocked() && o.RetryCount < o.MaxRetries
+	return o.CanRetryAt(time.Now())
+}
+
+// CanRetryAt checks if the OTP can be retried at now
+func (o *OTP) CanRetryAt(now time.Time) bool {
+	return !o.IsUsed && !o.IsExpiredAt(now) && !o.IsBlockedAt(now) && o.RetryCount < o.MaxRetries
 }
 
 // IncrementRetry increments the retry count and blocks if max retries exceeded
 func (o *OTP) IncrementRetry() {
-	now := time.Now()
+	o.IncrementRetryAt(time.Now())
+}
+
+// IncrementRetryAt records a failed attempt made at now
+func (o *OTP) IncrementRetryAt(now time.Time) {
 	o.RetryCount++
 	o.LastAttemptAt = &now
 
 	// Block if max retries exceeded
 	if o.RetryCount >= o.MaxRetries {
 		o.IsBlocked = true
-		// Block for 15 minutes after max retries
-		blockUntil := now.Add(15 * time.Minute)
+		blockUntil := now.Add(BlockDuration)
 		o.BlockedUntil = &blockUntil
 	}
 }
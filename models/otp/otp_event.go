@@ -26,4 +26,10 @@ type OTPEvent struct {
 	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 
 	EventType string `gorm:"type:varchar(50);not null" json:"event_type"` // created, verified, expired, etc.
+
+	// Delivery events (sms_sent, sms_failed, voice_sent, voice_failed) record the
+	// channel, when it was attempted and the provider error
+	Channel   string     `gorm:"type:varchar(10)" json:"channel,omitempty"`
+	SentAt    *time.Time `gorm:"index" json:"sent_at,omitempty"`
+	SendError string     `gorm:"type:text" json:"send_error,omitempty"`
 }
@@ -0,0 +1,44 @@
+package otp
+
+import (
+	"testing"
+	"time"
+
+	"passport-booking/services/clock"
+)
+
+func TestOTPWindows(t *testing.T) {
+	start := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		advance     time.Duration
+		wantExpired bool
+		wantBlocked bool
+	}{
+		{"when blocked", 0, false, true},
+		{"before expiry", 4 * time.Minute, false, true},
+		{"after expiry", 5*time.Minute + time.Second, true, true},
+		{"at the end of the block", BlockDuration, true, true},
+		{"after the block", BlockDuration + time.Second, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(start)
+			o := &OTP{MaxRetries: 3, ExpiresAt: fake.Now().Add(5 * time.Minute)}
+			for i := 0; i < o.MaxRetries; i++ {
+				o.IncrementRetryAt(fake.Now())
+			}
+
+			fake.Advance(tt.advance)
+			if got := o.IsExpiredAt(fake.Now()); got != tt.wantExpired {
+				t.Errorf("IsExpiredAt = %v, want %v", got, tt.wantExpired)
+			}
+			if got := o.IsBlockedAt(fake.Now()); got != tt.wantBlocked {
+				t.Errorf("IsBlockedAt = %v, want %v", got, tt.wantBlocked)
+			}
+			if o.CanRetryAt(fake.Now()) {
+				t.Errorf("CanRetryAt = true after %d failed attempts", o.MaxRetries)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package parcel_booking
+
+import "time"
+
+// CounterSessionStatus is the state of an operator's counter session
+type CounterSessionStatus string
+
+const (
+	CounterSessionOpen   CounterSessionStatus = "open"
+	CounterSessionClosed CounterSessionStatus = "closed"
+)
+
+// CounterSession is one operator shift at a post office counter. Parcel bookings
+// submitted during the shift are linked to it so the cash drawer can be reconciled
+// against the fees they collected.
+type CounterSession struct {
+	ID           uint                 `gorm:"primaryKey;autoIncrement" json:"id"`
+	OperatorID   uint                 `gorm:"not null;index;uniqueIndex:idx_counter_sessions_open_operator,where:status = 'open'" json:"operator_id"`
+	BranchCode   string               `gorm:"type:varchar(100);not null;index" json:"branch_code"`
+	Status       CounterSessionStatus `gorm:"type:varchar(20);not null;default:open;index" json:"status"`
+	OpeningFloat float64              `gorm:"type:decimal(10,2);not null;default:0" json:"opening_float"`
+	OpenedAt     time.Time            `gorm:"not null" json:"opened_at"`
+	ClosedAt     *time.Time           `json:"closed_at,omitempty"`
+	// Filled in when the session closes
+	BookingCount  int       `gorm:"not null;default:0" json:"booking_count"`
+	CollectedFees float64   `gorm:"type:decimal(10,2);not null;default:0" json:"collected_fees"`
+	ExpectedCash  float64   `gorm:"type:decimal(10,2);not null;default:0" json:"expected_cash"` // float plus fees
+	DeclaredCash  *float64  `gorm:"type:decimal(10,2)" json:"declared_cash,omitempty"`
+	Variance      *float64  `gorm:"type:decimal(10,2)" json:"variance,omitempty"` // declared minus expected
+	CloseNote     *string   `gorm:"type:text" json:"close_note,omitempty"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the CounterSession model
+func (CounterSession) TableName() string {
+	return "counter_sessions"
+}
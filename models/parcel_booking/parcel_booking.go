@@ -23,13 +23,26 @@ type ParcelBooking struct {
 	Price         float64 `gorm:"type:decimal(10,2)"       json:"price"`
 	Insured       bool    `gorm:"default:false"            json:"insured"`
 	CurrentStatus string  `gorm:"size:50;not null;column:current_status" json:"current_status"`
-	PushStatus    int     `gorm:"default:0"                json:"push_status"`
+	PushStatus    int     `gorm:"default:0;index"          json:"push_status"`
+	PushAttempts  int     `gorm:"default:0"                json:"push_attempts"`
+	PushLastError *string `gorm:"type:text"                json:"push_last_error,omitempty"`
 	UpdatedBy     string  `gorm:"type:varchar(255)" json:"updated_by,omitempty"`
+	// Counter session the fees were collected in
+	CounterSessionID *uint `gorm:"index" json:"counter_session_id,omitempty"`
+
+	// Hand-over to the applicant
+	DeliveryPhoneVerified bool     `gorm:"default:false"          json:"delivery_phone_verified"`
+	UploadPhoto           *string  `gorm:"type:text"              json:"upload_photo,omitempty"`
+	DeliveredLatitude     *float64 `gorm:"type:double precision" json:"delivered_latitude,omitempty"`
+	DeliveredLongitude    *float64 `gorm:"type:double precision" json:"delivered_longitude,omitempty"`
 
 	CreatedAt     time.Time  `gorm:"autoCreateTime"           json:"created_at"`
 	PendingDate   *time.Time `json:"pending_date"`
 	BookingDate   *time.Time `json:"booking_date"`
+	ReceivedDate  *time.Time `json:"received_date"`
 	DeliveredDate *time.Time `json:"delivered_date"`
+	LastPushAt    *time.Time `json:"last_push_at,omitempty"`
+	NextPushAt    *time.Time `gorm:"index"                    json:"next_push_at,omitempty"`
 	UpdatedAt     time.Time  `gorm:"autoUpdateTime"           json:"updated_at"`
 }
 
@@ -39,6 +52,39 @@ const (
 	ParcelBookingStatusInitial   ParcelBookingStatus = "initial"
 	ParcelBookingStatusPending   ParcelBookingStatus = "pending"
 	ParcelBookingStatusBooked    ParcelBookingStatus = "booked"
+	ParcelBookingStatusReceived  ParcelBookingStatus = "received"
 	ParcelBookingStatusReturn    ParcelBookingStatus = "return"
 	ParcelBookingStatusDelivered ParcelBookingStatus = "delivered"
 )
+
+// GetAllParcelBookingStatuses returns all valid parcel booking statuses
+func GetAllParcelBookingStatuses() []ParcelBookingStatus {
+	return []ParcelBookingStatus{
+		ParcelBookingStatusInitial,
+		ParcelBookingStatusPending,
+		ParcelBookingStatusBooked,
+		ParcelBookingStatusReceived,
+		ParcelBookingStatusReturn,
+		ParcelBookingStatusDelivered,
+	}
+}
+
+// ParcelBookingStatusTransitions returns the statuses the parcel workflow moves each
+// status to. Delivered and returned parcels are final.
+func ParcelBookingStatusTransitions() map[ParcelBookingStatus][]ParcelBookingStatus {
+	return map[ParcelBookingStatus][]ParcelBookingStatus{
+		ParcelBookingStatusInitial:   {ParcelBookingStatusPending},
+		ParcelBookingStatusPending:   {ParcelBookingStatusBooked},
+		ParcelBookingStatusBooked:    {ParcelBookingStatusReceived},
+		ParcelBookingStatusReceived:  {ParcelBookingStatusDelivered, ParcelBookingStatusReturn},
+		ParcelBookingStatusReturn:    {},
+		ParcelBookingStatusDelivered: {},
+	}
+}
+
+// Values of ParcelBooking.PushStatus, tracking submission of the booking to DMS
+const (
+	PushStatusNotPushed = 0
+	PushStatusSuccess   = 1
+	PushStatusFailed    = 2
+)
@@ -15,6 +15,11 @@ type ParcelBookingStatusEvent struct {
 	Status    string    `gorm:"size:50;not null" json:"status"` // e.g. "Booked", "Delivered"
 	CreatedBy uint      `gorm:"not null;index"   json:"created_by"`
 	User      user.User `gorm:"foreignKey:CreatedBy" json:"user"`
+	// Display name and role of CreatedBy at write time
+	CreatedByName string `gorm:"type:varchar(255)" json:"created_by_name"`
+	CreatedByRole string `gorm:"type:varchar(50)" json:"created_by_role"`
+	// UUID of the admin acting through an impersonation token
+	ImpersonatedBy *string `gorm:"type:varchar(255);index" json:"impersonated_by,omitempty"`
 
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
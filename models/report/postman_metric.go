@@ -0,0 +1,33 @@
+package report
+
+import "time"
+
+// PostmanMetric holds one postman's delivery performance for a calendar month.
+// Rows are recomputed by the postman metrics job and read by the leaderboard.
+type PostmanMetric struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	PostmanID uint   `gorm:"not null;uniqueIndex:idx_postman_metric_period" json:"postman_id"`
+	Period    string `gorm:"type:varchar(7);not null;uniqueIndex:idx_postman_metric_period;index" json:"period"` // YYYY-MM
+	// Delivery branch the postman delivered to most often in the period
+	BranchCode *string `gorm:"type:varchar(100);index" json:"branch_code,omitempty"`
+
+	ItemsReceived         int64 `gorm:"not null;default:0" json:"items_received"`
+	Delivered             int64 `gorm:"not null;default:0" json:"delivered"`
+	OnTimeDelivered       int64 `gorm:"not null;default:0" json:"on_time_delivered"`
+	FirstAttemptDelivered int64 `gorm:"not null;default:0" json:"first_attempt_delivered"`
+	ActiveDays            int64 `gorm:"not null;default:0" json:"active_days"`
+
+	OnTimeRate       float64 `gorm:"not null;default:0" json:"on_time_rate"`
+	FirstAttemptRate float64 `gorm:"not null;default:0" json:"first_attempt_rate"`
+	AvgItemsPerDay   float64 `gorm:"not null;default:0" json:"avg_items_per_day"`
+	Score            float64 `gorm:"not null;default:0;index" json:"score"`
+
+	ComputedAt time.Time `gorm:"not null" json:"computed_at"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the PostmanMetric model
+func (PostmanMetric) TableName() string {
+	return "postman_metrics"
+}
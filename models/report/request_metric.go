@@ -0,0 +1,32 @@
+package report
+
+import "time"
+
+// RequestMetric counts the requests one route served in one hour, with how many
+// failed and a cumulative latency histogram. Rows are flushed by the request metrics
+// recorder and read by the SLO report.
+type RequestMetric struct {
+	ID     uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Method string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_request_metric_route_hour" json:"method"`
+	Route  string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_request_metric_route_hour" json:"route"`
+	Hour   time.Time `gorm:"not null;uniqueIndex:idx_request_metric_route_hour;index" json:"hour"` // start of the hour, UTC
+
+	Requests int64 `gorm:"not null;default:0" json:"requests"`
+	Errors   int64 `gorm:"not null;default:0" json:"errors"` // 5xx responses
+
+	// Requests answered within each latency bound, cumulative
+	Le100ms  int64 `gorm:"column:le_100ms;not null;default:0" json:"le_100ms"`
+	Le250ms  int64 `gorm:"column:le_250ms;not null;default:0" json:"le_250ms"`
+	Le500ms  int64 `gorm:"column:le_500ms;not null;default:0" json:"le_500ms"`
+	Le1000ms int64 `gorm:"column:le_1000ms;not null;default:0" json:"le_1000ms"`
+	Le2500ms int64 `gorm:"column:le_2500ms;not null;default:0" json:"le_2500ms"`
+	Le5000ms int64 `gorm:"column:le_5000ms;not null;default:0" json:"le_5000ms"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName sets the table name for the RequestMetric model
+func (RequestMetric) TableName() string {
+	return "request_metrics"
+}
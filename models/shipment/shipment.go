@@ -0,0 +1,43 @@
+package shipment
+
+import "time"
+
+// Kind names the record a shipment mirrors
+type Kind string
+
+const (
+	KindBooking       Kind = "booking"
+	KindParcelBooking Kind = "parcel_booking"
+)
+
+// Stage is the lifecycle position shared by every kind of shipment
+type Stage string
+
+const (
+	StageCreated        Stage = "created"
+	StageBooked         Stage = "booked"
+	StageAtBranch       Stage = "at_branch"
+	StageOutForDelivery Stage = "out_for_delivery"
+	StageDelivered      Stage = "delivered"
+	StageReturning      Stage = "returning"
+	StageReturned       Stage = "returned"
+)
+
+// Shipment is the common core of a Booking or ParcelBooking: who owns it, how it is
+// tracked and where it is in its lifecycle. Kind and SourceID point back at the record.
+type Shipment struct {
+	ID       uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	Kind     Kind `gorm:"size:20;not null;uniqueIndex:idx_shipments_source" json:"kind"`
+	SourceID uint `gorm:"not null;uniqueIndex:idx_shipments_source" json:"source_id"`
+
+	UserID       uint    `gorm:"not null;index" json:"user_id"`
+	Barcode      *string `gorm:"type:varchar(255);index" json:"barcode,omitempty"`
+	Phone        string  `gorm:"type:varchar(20)" json:"phone"`
+	Stage        Stage   `gorm:"size:30;not null;index" json:"stage"`
+	SourceStatus string  `gorm:"size:50;not null" json:"source_status"` // status as stored on the source record
+
+	BookedAt    *time.Time `json:"booked_at,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,52 @@
+package user
+
+import "time"
+
+// UserBranch maps a user to a branch locally, mirroring the mapping made in DMS.
+// BranchCode may also name a district or region of the branch hierarchy, which
+// grants the user every branch under it.
+type UserBranch struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_user_branches_user_branch" json:"user_id"`
+	BranchCode   string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_user_branches_user_branch;index" json:"branch_code"`
+	Relationship string    `gorm:"type:varchar(50)" json:"relationship"`
+	MappedByID   uint      `gorm:"not null" json:"mapped_by_id"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TableName sets the table name for the UserBranch model
+func (UserBranch) TableName() string {
+	return "user_branches"
+}
+
+// AdminAction is a change made to a user through the admin endpoints
+type AdminAction string
+
+const (
+	AdminActionActivate    AdminAction = "activate"
+	AdminActionDeactivate  AdminAction = "deactivate"
+	AdminActionMapBranch   AdminAction = "map_branch"
+	AdminActionUnmapBranch AdminAction = "unmap_branch"
+	AdminActionApprove     AdminAction = "approve"
+	AdminActionReject      AdminAction = "reject"
+	AdminActionDelegate    AdminAction = "delegate"
+	AdminActionRevoke      AdminAction = "revoke_delegation"
+)
+
+// UserAdminAudit records who changed a user through the admin endpoints and why
+type UserAdminAudit struct {
+	ID        uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint        `gorm:"not null;index" json:"user_id"`
+	ActorID   uint        `gorm:"not null;index" json:"actor_id"`
+	Action    AdminAction `gorm:"type:varchar(30);not null" json:"action"`
+	Details   string      `gorm:"type:text" json:"details"`
+	Reason    string      `gorm:"type:text" json:"reason"`
+	CreatedAt time.Time   `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// TableName sets the table name for the UserAdminAudit model
+func (UserAdminAudit) TableName() string {
+	return "user_admin_audits"
+}
@@ -0,0 +1,28 @@
+package user
+
+import "time"
+
+// Delegation temporarily grants a set of permissions from one user to another,
+// e.g. an acting postmaster during leave. The auth middleware adds the permissions
+// to the grantee's token claims between StartsAt and ExpiresAt.
+type Delegation struct {
+	ID          uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	GrantorID   uint        `gorm:"not null;index" json:"grantor_id"`
+	GranteeID   uint        `gorm:"not null;index" json:"grantee_id"`
+	GranteeUUID string      `gorm:"type:varchar(255);not null;index" json:"grantee_uuid"`
+	Permissions StringSlice `gorm:"type:json;not null" json:"permissions"`
+	Reason      string      `gorm:"type:text;not null" json:"reason"`
+	StartsAt    time.Time   `gorm:"not null" json:"starts_at"`
+	ExpiresAt   time.Time   `gorm:"not null;index" json:"expires_at"`
+	RevokedAt   *time.Time  `json:"revoked_at,omitempty"`
+	RevokedByID *uint       `json:"revoked_by_id,omitempty"`
+	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"created_at"`
+
+	Grantor User `gorm:"foreignKey:GrantorID" json:"-"`
+	Grantee User `gorm:"foreignKey:GranteeID" json:"-"`
+}
+
+// TableName sets the table name for the Delegation model
+func (Delegation) TableName() string {
+	return "delegations"
+}
@@ -0,0 +1,26 @@
+package user
+
+import "time"
+
+// DevicePlatform is the mobile platform a push token was issued for
+type DevicePlatform string
+
+const (
+	DevicePlatformAndroid DevicePlatform = "android"
+	DevicePlatformIOS     DevicePlatform = "ios"
+)
+
+// DeviceToken is a push token registered by the mobile app for a user. A token belongs
+// to one user at a time; registering it again moves it to the new user.
+type DeviceToken struct {
+	ID         uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint           `gorm:"not null;index" json:"user_id"`
+	Token      string         `gorm:"type:varchar(512);not null;uniqueIndex" json:"token"`
+	Platform   DevicePlatform `gorm:"type:varchar(20);not null" json:"platform"`
+	AppVersion string         `gorm:"type:varchar(50)" json:"app_version,omitempty"`
+	LastSeenAt time.Time      `gorm:"not null" json:"last_seen_at"`
+	CreatedAt  time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
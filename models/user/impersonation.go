@@ -0,0 +1,20 @@
+package user
+
+import "time"
+
+// ImpersonationSession is the audit record of an impersonation token issued by support staff
+type ImpersonationSession struct {
+	ID          uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	TokenID     string      `gorm:"type:varchar(64);not null;uniqueIndex" json:"token_id"`
+	AdminID     uint        `gorm:"not null;index" json:"admin_id"`
+	AdminUUID   string      `gorm:"type:varchar(255);not null" json:"admin_uuid"`
+	TargetID    uint        `gorm:"not null;index" json:"target_id"`
+	TargetUUID  string      `gorm:"type:varchar(255);not null" json:"target_uuid"`
+	Reason      string      `gorm:"type:text;not null" json:"reason"`
+	Permissions StringSlice `gorm:"type:json" json:"permissions"`
+	ExpiresAt   time.Time   `gorm:"not null" json:"expires_at"`
+	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"created_at"`
+
+	Admin  User `gorm:"foreignKey:AdminID" json:"-"`
+	Target User `gorm:"foreignKey:TargetID" json:"-"`
+}
@@ -0,0 +1,27 @@
+package user
+
+import "time"
+
+// PreferenceKey names a single user preference
+type PreferenceKey string
+
+const (
+	PreferenceLanguage            PreferenceKey = "language"
+	PreferenceNotificationChannel PreferenceKey = "notification_channel"
+	PreferenceDefaultBranch       PreferenceKey = "default_branch"
+	PreferencePushItemsAssigned   PreferenceKey = "push_items_assigned"
+	PreferencePushEscalations     PreferenceKey = "push_escalations"
+	PreferencePostmasterDigest    PreferenceKey = "postmaster_digest"
+)
+
+// UserPreference stores one preference value per user and key
+type UserPreference struct {
+	ID        uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint          `gorm:"not null;uniqueIndex:idx_user_preferences_user_key" json:"user_id"`
+	Key       PreferenceKey `gorm:"type:varchar(50);not null;uniqueIndex:idx_user_preferences_user_key" json:"key"`
+	Value     string        `gorm:"type:varchar(255);not null" json:"value"`
+	CreatedAt time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
@@ -24,6 +24,14 @@ type User struct {
 	CreatedByID  *uint       `gorm:"index" json:"created_by_id,omitempty"`
 	ApprovedByID *uint       `gorm:"index" json:"approved_by_id,omitempty"`
 	Permissions  StringSlice `gorm:"type:json" json:"permissions"` // Use JSON column to store slice of strings
+	// IsActive is switched off by administrators to lock the user out locally
+	IsActive bool `gorm:"not null;default:true" json:"is_active"`
+
+	// Self-registered operators wait for a postmaster or admin to approve them
+	ApprovalStatus      ApprovalStatus `gorm:"type:varchar(20);not null;default:approved;index" json:"approval_status"`
+	RequestedBranchCode *string        `gorm:"type:varchar(50);index" json:"requested_branch_code,omitempty"`
+	ReviewedAt          *time.Time     `json:"reviewed_at,omitempty"`
+	ReviewNote          *string        `gorm:"type:text" json:"review_note,omitempty"`
 
 	// Self-referencing relationships
 	CreatedByUser  *User `gorm:"foreignKey:CreatedByID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"created_by,omitempty"`
@@ -34,6 +42,15 @@ type User struct {
 	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty"`
 }
 
+// ApprovalStatus is where a registration stands in the onboarding review
+type ApprovalStatus string
+
+const (
+	ApprovalStatusPending  ApprovalStatus = "pending"
+	ApprovalStatusApproved ApprovalStatus = "approved"
+	ApprovalStatusRejected ApprovalStatus = "rejected"
+)
+
 // StringSlice is a custom type to handle JSON serialization for PostgreSQL
 type StringSlice []string
 
@@ -6,18 +6,32 @@ import (
 	"passport-booking/controllers/auth"
 	"passport-booking/controllers/bag"
 	"passport-booking/controllers/booking"
+	"passport-booking/controllers/branch"
 	"passport-booking/controllers/delivery"
+	"passport-booking/controllers/evidence"
+	"passport-booking/controllers/fraud"
+	"passport-booking/controllers/integration"
+	"passport-booking/controllers/notification"
 	"passport-booking/controllers/passport_percel"
+	"passport-booking/controllers/privacy"
+	"passport-booking/controllers/report"
+	"passport-booking/controllers/returns"
+	"passport-booking/controllers/system"
 	"passport-booking/controllers/user"
 	httpServices "passport-booking/httpServices/sso"
 	"passport-booking/logger"
 	"passport-booking/middleware"
+	bookingTypes "passport-booking/types/booking"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
 func SetupRoutes(app *fiber.App, db *gorm.DB) {
+	// Record route permission guards for /me/capabilities
+	middleware.TrackRoutePermissions(app)
+
 	//ssoClient := httpServices.NewClient(os.Getenv("SSO_BASE_URL"))
 	dmsClient := httpServices.NewClient(os.Getenv("DMS_BASE_URL"))
 	asyncLogger := logger.NewAsyncLogger(db)
@@ -25,8 +39,18 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	bookingController := booking.NewBookingController(db, asyncLogger)
 	bagController := bag.NewBagController(db, asyncLogger)
 	deliveryController := delivery.NewDeliveryController(db, asyncLogger)
+	evidenceController := evidence.NewEvidenceController(db, asyncLogger)
+	fraudController := fraud.NewFraudController(db, asyncLogger)
 	regionalPassportOfficeController := passport_percel.NewRegionalPassportOfficeController(db, asyncLogger)
 	parcelBookingController := passport_percel.NewParcelBookingController(db, asyncLogger)
+	reportController := report.NewReportController(db, asyncLogger)
+	privacyController := privacy.NewPrivacyController(db, asyncLogger)
+	returnController := returns.NewReturnController(db, asyncLogger)
+	branchController := branch.NewBranchController(db, asyncLogger)
+	notificationController := notification.NewNotificationController(db, asyncLogger)
+	integrationController := integration.NewIntegrationController(db, asyncLogger)
+	systemController := system.NewSystemController(db, asyncLogger)
+	userAdminController := user.NewUserAdminController(db, asyncLogger)
 
 	// Start the async logger processing goroutine
 	go asyncLogger.ProcessLog()
@@ -41,17 +65,21 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	/*=============================================================================
 	| Public Routes
 	===============================================================================*/
-	api := app.Group("/api")
+	api := app.Group("/api", middleware.RequestMetrics(), middleware.Chaos(), middleware.Compression())
 	api.Post("/get-service-token", authController.GetServiceToken)
 	api.Post("/login", authController.Login)
 	api.Post("/register", authController.Register)
+	// Readiness probe; not logged so probes do not flood the request log
+	api.Get("/health", systemController.Health)
+	// Delivery photos behind short-lived signed links; the signature replaces login
+	api.Get("/public/proof/:kind/:ref", middleware.ValidateParams(middleware.PathBarcode("ref")), bookingController.ProofFile)
 
 	/*=============================================================================
 	Bag api routes
 	===============================================================================*/
 	bagGroup := api.Group("/bag")
 
-	bagGroup.Get("/branch-list", middleware.RequirePermissions(constants.PermSuperAdminFull), bag.GetBranchList)
+	bagGroup.Get("/branch-list", middleware.CacheControl(10*time.Minute, "CACHE_MAX_AGE_BRANCHES"), middleware.RequirePermissions(constants.PermSuperAdminFull), bag.GetBranchList)
 	bagGroup.Get("/operator-list", middleware.RequirePermissions(constants.PermSuperAdminFull), bag.GetOperatorList)
 	bagGroup.Post("/branch-mapping", middleware.RequirePermissions(constants.PermSuperAdminFull), bag.CreateBranchMapping)
 	bagGroup.Post("/create", middleware.RequirePermissions(constants.PermOperatorFull), bag.CreateBag)
@@ -60,13 +88,27 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	bagGroup.Get("/booking_list", middleware.RequirePermissions(
 		constants.PermOperatorFull,
 		constants.PermAgentHasFull,
-	), bagController.Index)
+		constants.PermViewerReadOnly,
+	), middleware.QueryBudget(4), bagController.Index)
 
 	bagGroup.Post("/receive", middleware.RequirePermissions(
 		constants.PermPostmanFull,
 		constants.PermPostOfficeFull,
 	), bagController.ReceiveBag)
 
+	// Transport lines bags are received onto
+	bagGroup.Get("/lines", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostmanFull,
+		constants.PermPostOfficeFull,
+	), bagController.ListLines)
+	bagGroup.Get("/lines/:code", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostmanFull,
+		constants.PermPostOfficeFull,
+	), bagController.ShowLine)
+	bagGroup.Put("/lines", middleware.RequirePermissions(constants.PermSuperAdminFull), bagController.SaveLine)
+
 	/*=============================================================================
 	| Protected Routes
 	===============================================================================*/
@@ -80,10 +122,30 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	authGroup.Get("/profile", user.GetUserInfo)
 	authGroup.Post("/logout", authController.LogOut)
 
+	// Support staff impersonation of operators and postmen
+	api.Post("/auth/impersonate", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), authController.Impersonate)
+
+	/*=============================================================================
+	| Current User Routes
+	===============================================================================*/
+	meGroup := api.Group("/me")
+
+	meGroup.Get("/capabilities", middleware.RequireAuthentication(), user.GetCapabilities)
+	meGroup.Get("/preferences", middleware.RequireAuthentication(), user.GetPreferences)
+	meGroup.Put("/preferences", middleware.RequireAuthentication(), user.UpdatePreferences)
+	meGroup.Get("/devices", middleware.RequireAuthentication(), user.ListDevices)
+	meGroup.Post("/devices", middleware.RequireAuthentication(), user.RegisterDevice)
+	meGroup.Delete("/devices/:id", middleware.RequireAuthentication(), middleware.ValidateParams(middleware.PathID("id")), user.RemoveDevice)
+
+	// Valid statuses and transitions of bookings and parcel bookings
+	api.Get("/statuses", middleware.RequireAuthentication(), middleware.CacheControl(time.Hour, "CACHE_MAX_AGE_STATUSES"), bookingController.Statuses)
+
 	/*=============================================================================
 	| Booking Routes
 	===============================================================================*/
-	bookingGroup := api.Group("/booking")
+	bookingGroup := api.Group("/booking", middleware.NoCache())
 
 	bookingGroup.Post("/create", middleware.RequirePermissions(
 		constants.PermAgentHasFull,
@@ -99,11 +161,31 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 		constants.PermAgentHasFull,
 		constants.PermCustomerFull,
 		constants.PermOperatorFull,
-	), bookingController.Index)
+		constants.PermViewerReadOnly,
+	), middleware.QueryBudget(4), bookingController.Index)
 	bookingGroup.Get("/details/:id", middleware.RequirePermissions(
 		constants.PermAgentHasFull,
 		constants.PermCustomerFull,
-	), bookingController.Show)
+		constants.PermViewerReadOnly,
+	), middleware.ValidateParams(middleware.PathBookingIdentifier("id", "identifier_type", bookingTypes.IdentifierTypeID)), middleware.BarcodeProbeGuard(db, "id"), bookingController.Show)
+
+	bookingGroup.Get("/track/:barcode", middleware.RequirePermissions(
+		constants.PermAgentHasFull,
+		constants.PermCustomerFull,
+		constants.PermOperatorFull,
+		constants.PermPostmanFull,
+		constants.PermPostOfficeFull,
+		constants.PermViewerReadOnly,
+	), middleware.ValidateParams(middleware.PathBarcode("barcode")), middleware.BarcodeProbeGuard(db, "barcode"), middleware.CaptchaOnVelocity(20, time.Minute, "CAPTCHA_TRACK_LIMIT"), bookingController.Track)
+
+	bookingGroup.Get("/track/:barcode/qr", middleware.RequirePermissions(
+		constants.PermAgentHasFull,
+		constants.PermCustomerFull,
+		constants.PermOperatorFull,
+		constants.PermPostmanFull,
+		constants.PermPostOfficeFull,
+		constants.PermViewerReadOnly,
+	), middleware.ValidateParams(middleware.PathBarcode("barcode")), middleware.BarcodeProbeGuard(db, "barcode"), middleware.CaptchaOnVelocity(20, time.Minute, "CAPTCHA_TRACK_LIMIT"), bookingController.TrackQR)
 
 	bookingGroup.Post("/parse-passport-slip", middleware.RequirePermissions(
 		constants.PermAgentHasFull,
@@ -113,17 +195,100 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	bookingGroup.Get("/get-booking-status-event/:id", middleware.RequirePermissions(
 		constants.PermAgentHasFull,
 		constants.PermCustomerFull,
-	), bookingController.GetBookingStatusEvent)
+		constants.PermViewerReadOnly,
+	), middleware.QueryBudget(4), middleware.ValidateParams(middleware.PathID("id")), bookingController.GetBookingStatusEvent)
+
+	// Redirect delivery to another address
+	bookingGroup.Post("/address-change", middleware.RequirePermissions(
+		constants.PermAgentHasFull,
+		constants.PermCustomerFull,
+	), bookingController.RequestAddressChange)
+
+	bookingGroup.Post("/address-change/verify", middleware.RequirePermissions(
+		constants.PermAgentHasFull,
+		constants.PermCustomerFull,
+	), bookingController.VerifyAddressChange)
+
+	bookingGroup.Post("/address-change/operator", middleware.RequirePermissions(
+		constants.PermOperatorFull,
+		constants.PermSuperAdminFull,
+	), bookingController.OperatorAddressChange)
+
+	// Vacation holds keep the item at the branch for a date range
+	bookingGroup.Post("/delivery-holds", middleware.RequirePermissions(
+		constants.PermAgentHasFull,
+		constants.PermCustomerFull,
+	), bookingController.CreateDeliveryHold)
+
+	bookingGroup.Get("/delivery-holds/booking/:id", middleware.RequirePermissions(
+		constants.PermAgentHasFull,
+		constants.PermCustomerFull,
+	), middleware.ValidateParams(middleware.PathID("id")), bookingController.ListDeliveryHolds)
+
+	bookingGroup.Delete("/delivery-holds/:id", middleware.RequirePermissions(
+		constants.PermAgentHasFull,
+		constants.PermCustomerFull,
+	), middleware.ValidateParams(middleware.PathID("id")), bookingController.CancelDeliveryHold)
+
+	// Delivery address in the script the label is printed in
+	bookingGroup.Get("/:id/address-label", middleware.RequirePermissions(
+		constants.PermOperatorFull,
+		constants.PermPostmanFull,
+		constants.PermPostOfficeFull,
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), bookingController.AddressLabel)
+
+	// Application/order IDs whose format could not be confirmed at booking
+	bookingGroup.Get("/app-id-review", middleware.RequirePermissions(
+		constants.PermOperatorFull,
+		constants.PermSuperAdminFull,
+	), bookingController.AppIDReviewQueue)
+
+	bookingGroup.Post("/app-id-review/:id", middleware.RequirePermissions(
+		constants.PermOperatorFull,
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), bookingController.ResolveAppIDReview)
+
+	// Daily booking creation quotas per operator and branch, with temporary overrides
+	bookingGroup.Get("/quotas", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), bookingController.ListBookingQuotas)
+
+	bookingGroup.Get("/quotas/:scope/:subject", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), bookingController.ShowBookingQuota)
+
+	bookingGroup.Put("/quotas/:scope/:subject", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), bookingController.SetBookingQuota)
+
+	bookingGroup.Delete("/quotas/:scope/:subject", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), bookingController.DeleteBookingQuota)
+
+	// Soft delete of bookings created or cancelled by mistake, restorable for a window
+	bookingGroup.Delete("/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), bookingController.DeleteBooking)
+
+	bookingGroup.Post("/:id/restore", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), bookingController.RestoreBooking)
 
 	/*=============================================================================
 	| OTP Routes for Booking
 	===============================================================================*/
 
 	// Delivery phone management routes
+	bookingGroup.Post("/delivery-phone-consent", middleware.RequirePermissions(
+		constants.PermAgentHasFull,
+		constants.PermCustomerFull,
+	), bookingController.RecordDeliveryPhoneConsent)
+
 	bookingGroup.Post("/delivery-phone-send-otp", middleware.RequirePermissions(
 		constants.PermAgentHasFull,
 		constants.PermCustomerFull,
-	), bookingController.DeliveryPhoneSendOtp)
+	), middleware.CaptchaOnVelocity(5, time.Hour, "CAPTCHA_OTP_REQUEST_LIMIT"), bookingController.DeliveryPhoneSendOtp)
 
 	bookingGroup.Post("/verify-delivery-phone", middleware.RequirePermissions(
 		constants.PermAgentHasFull,
@@ -138,7 +303,7 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	bookingGroup.Post("/resend-otp", middleware.RequirePermissions(
 		constants.PermAgentHasFull,
 		constants.PermCustomerFull,
-	), bookingController.ResendOTP)
+	), middleware.CaptchaOnVelocity(5, time.Hour, "CAPTCHA_OTP_REQUEST_LIMIT"), bookingController.ResendOTP)
 
 	/*=============================================================================
 	| OTP Routes for Delivery Confirmation
@@ -157,9 +322,13 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 		constants.PermPostmanFull,
 	), deliveryController.VerifyApplicationID)
 
+	deliveredGroup.Post("/verify-recipient-id", middleware.RequirePermissions(
+		constants.PermPostmanFull,
+	), deliveryController.VerifyRecipientID)
+
 	deliveredGroup.Post("/upload-photo", middleware.RequirePermissions(
 		constants.PermPostmanFull,
-	), deliveryController.UploadDeliveryPhoto)
+	), middleware.ValidateParams(middleware.FormBookingIdentifier("booking_id", "identifier_type", bookingTypes.IdentifierTypeBarcode)), deliveryController.UploadDeliveryPhoto)
 
 	deliveredGroup.Post("/item-delivery", middleware.RequirePermissions(
 		constants.PermPostmanFull,
@@ -167,20 +336,567 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 
 	deliveredGroup.Post("/itemdetails", middleware.RequirePermissions(
 		constants.PermPostmanFull,
-	), deliveryController.ItemDetails)
+	), middleware.ValidateParams(middleware.BodyBarcode("barcode")), deliveryController.ItemDetails)
 
 	deliveredGroup.Post("/receive", middleware.RequirePermissions(
 		constants.PermPostmanFull,
 	), deliveryController.ReceiveItem)
 
+	deliveredGroup.Post("/return", middleware.RequirePermissions(
+		constants.PermPostmanFull,
+	), deliveryController.MarkReturn)
+
+	deliveredGroup.Post("/groups", middleware.RequirePermissions(
+		constants.PermPostmanFull,
+	), deliveryController.CreateGroup)
+
+	deliveredGroup.Get("/groups/:id", middleware.RequirePermissions(
+		constants.PermPostmanFull,
+	), middleware.ValidateParams(middleware.PathID("id")), deliveryController.ShowGroup)
+
+	deliveredGroup.Post("/groups/:id/deliver", middleware.RequirePermissions(
+		constants.PermPostmanFull,
+	), middleware.ValidateParams(middleware.PathID("id")), deliveryController.DeliverGroup)
+
+	deliveredGroup.Delete("/groups/:id", middleware.RequirePermissions(
+		constants.PermPostmanFull,
+	), middleware.ValidateParams(middleware.PathID("id")), deliveryController.DissolveGroup)
+
+	// Actions performed without connectivity, signed with the postman's offline key
+	deliveredGroup.Get("/offline/key", middleware.NoCache(), middleware.RequirePermissions(
+		constants.PermPostmanFull,
+	), deliveryController.OfflineKey)
+
+	deliveredGroup.Post("/offline/sync", middleware.RequirePermissions(
+		constants.PermPostmanFull,
+	), deliveryController.OfflineSync)
+
+	// Addresses without coverage get a delivery code in advance instead of a live OTP.
+	// Postmen redeem these codes, so they cannot flag addresses or issue codes.
+	deliveredGroup.Post("/offline/no-network", middleware.RequirePermissions(
+		constants.PermPostOfficeFull,
+		constants.PermSuperAdminFull,
+	), deliveryController.SetNoNetwork)
+
+	deliveredGroup.Post("/offline/codes", middleware.NoCache(), middleware.RequirePermissions(
+		constants.PermPostOfficeFull,
+		constants.PermSuperAdminFull,
+	), deliveryController.IssueOfflineCode)
+
+	/*=============================================================================
+	| OTP Status Routes
+	===============================================================================*/
+	otpGroup := api.Group("/otp")
+
+	otpGroup.Post("/status/batch", middleware.RequirePermissions(
+		constants.PermPostmanFull,
+		constants.PermPostOfficeFull,
+	), deliveryController.BatchOTPStatus)
+
+	/*=============================================================================
+	| Delivery Evidence Routes (dual authorization)
+	===============================================================================*/
+	evidenceGroup := api.Group("/evidence", middleware.NoCache())
+
+	evidenceGroup.Post("/requests", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), evidenceController.Create)
+
+	evidenceGroup.Post("/requests/:id/approve", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+	), middleware.ValidateParams(middleware.PathID("id")), evidenceController.Approve)
+
+	evidenceGroup.Post("/requests/:id/reveal", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), evidenceController.Reveal)
+
+	// Encrypted evidence archives for the passport office's audit team, built in the background
+	evidenceGroup.Post("/exports", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+	), evidenceController.CreateExport)
+
+	evidenceGroup.Get("/exports/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+	), middleware.ValidateParams(middleware.PathID("id")), evidenceController.GetExport)
+
+	evidenceGroup.Get("/exports/:id/download", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+	), middleware.ValidateParams(middleware.PathID("id")), evidenceController.DownloadExport)
+
+	/*=============================================================================
+	| Anti-Fraud Routes (rule configuration and review of held deliveries)
+	===============================================================================*/
+	fraudGroup := api.Group("/fraud", middleware.NoCache())
+
+	fraudGroup.Get("/rules", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermOrgSupervisorFull,
+	), fraudController.ListRules)
+
+	fraudGroup.Put("/rules/:code", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), fraudController.UpdateRule)
+
+	fraudGroup.Get("/cases", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermOrgSupervisorFull,
+		constants.PermPostOfficeFull,
+	), fraudController.ListCases)
+
+	fraudGroup.Post("/cases/:id/review", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermOrgSupervisorFull,
+		constants.PermPostOfficeFull,
+	), middleware.ValidateParams(middleware.PathID("id")), fraudController.ReviewCase)
+
+	/*=============================================================================
+	| Notification Template Routes (SMS and email wording, versioned)
+	===============================================================================*/
+	templateGroup := api.Group("/notification-templates", middleware.NoCache())
+
+	templateGroup.Get("/", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), notificationController.ListTemplates)
+
+	templateGroup.Post("/", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), notificationController.SaveTemplate)
+
+	templateGroup.Post("/preview", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), notificationController.PreviewTemplate)
+
+	templateGroup.Post("/versions/:id/activate", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), notificationController.ActivateVersion)
+
+	templateGroup.Get("/:kind/:lang/versions", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), notificationController.ListVersions)
+
+	templateGroup.Delete("/:kind/:lang", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), notificationController.ResetTemplate)
+
+	/*=============================================================================
+	| Notification Log Routes (outbound SMS/email and resend of failures)
+	===============================================================================*/
+	notificationGroup := api.Group("/notifications", middleware.NoCache())
+
+	notificationGroup.Get("/log", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermOrgSupervisorFull,
+	), notificationController.ListLog)
+
+	notificationGroup.Post("/log/:id/resend", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermOrgSupervisorFull,
+	), middleware.ValidateParams(middleware.PathID("id")), notificationController.ResendLog)
+
+	notificationGroup.Post("/bookings/:id/resend", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermOrgSupervisorFull,
+	), middleware.ValidateParams(middleware.PathID("id")), notificationController.ResendFailedForBooking)
+
+	/*=============================================================================
+	| Integration Routes (callbacks pushed by external systems)
+	===============================================================================*/
+	integrationGroup := api.Group("/integrations", middleware.NoCache())
+
+	// Authenticated by its HMAC signature rather than a user token
+	integrationGroup.Post("/dms/callback", integrationController.DMSCallback)
+
+	integrationGroup.Get("/dms/mappings", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), integrationController.ListMappings)
+
+	integrationGroup.Put("/dms/mappings", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), integrationController.SaveMapping)
+
+	integrationGroup.Get("/dms/callbacks", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), integrationController.ListCallbacks)
+
+	// Circuit breaker, degraded-mode switch and the queue drained when DMS is back
+	integrationGroup.Get("/dms/status", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermOrgSupervisorFull,
+	), integrationController.DMSStatus)
+
+	integrationGroup.Put("/dms/mode", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), integrationController.SetDMSMode)
+
+	integrationGroup.Get("/dms/outbox", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermOrgSupervisorFull,
+	), integrationController.ListOutbox)
+
+	integrationGroup.Post("/dms/outbox/:id/retry", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), integrationController.RetryOutboxEntry)
+
+	/*=============================================================================
+	| Return Routes (undelivered passports bagged back to the RPO)
+	===============================================================================*/
+	returnGroup := api.Group("/returns", middleware.NoCache())
+
+	returnGroup.Get("/manifests", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+		constants.PermPassportDPMGFull,
+	), returnController.Index)
+
+	returnGroup.Post("/manifests", middleware.RequirePermissions(
+		constants.PermPostOfficeFull,
+	), returnController.CreateManifest)
+
+	returnGroup.Get("/manifests/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+		constants.PermPassportDPMGFull,
+	), middleware.ValidateParams(middleware.PathID("id")), returnController.Show)
+
+	returnGroup.Post("/manifests/:id/dispatch", middleware.RequirePermissions(
+		constants.PermPostOfficeFull,
+	), middleware.ValidateParams(middleware.PathID("id")), returnController.Dispatch)
+
+	returnGroup.Post("/manifests/:id/acknowledge", middleware.RequirePermissions(
+		constants.PermPassportDPMGFull,
+	), middleware.ValidateParams(middleware.PathID("id")), returnController.Acknowledge)
+
+	/*=============================================================================
+	| System Routes (runtime state of the service)
+	===============================================================================*/
+	systemGroup := api.Group("/system", middleware.NoCache())
+
+	systemGroup.Get("/worker-pool", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), systemController.WorkerPool)
+
+	systemGroup.Get("/slow-queries", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), systemController.SlowQueries)
+
+	systemGroup.Get("/unused-indexes", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), systemController.UnusedIndexes)
+
+	// Sources held back for probing barcodes
+	systemGroup.Get("/blocked-sources", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), systemController.BlockedSources)
+
+	systemGroup.Delete("/blocked-sources/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), systemController.LiftBlockedSource)
+
+	// Runbook fixes for bookings stuck in inconsistent states; dry run unless apply is set
+	systemGroup.Post("/heal/:command", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), systemController.Heal)
+
+	// Replays a booking's events and compares the result with the booking
+	systemGroup.Get("/event-integrity/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), systemController.EventIntegrity)
+
+	// Items the outbox, notification and DMS callback queues gave up on
+	systemGroup.Get("/dead-letters", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), systemController.DeadLetterCounts)
+
+	systemGroup.Get("/dead-letters/:queue", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), systemController.ListDeadLetters)
+
+	systemGroup.Get("/dead-letters/:queue/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), systemController.ShowDeadLetter)
+
+	systemGroup.Post("/dead-letters/:queue/:id/replay", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), systemController.ReplayDeadLetter)
+
+	systemGroup.Post("/dead-letters/:queue/:id/discard", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), systemController.DiscardDeadLetter)
+
+	// Fault injection for staging; refused under the prod profile
+	systemGroup.Get("/chaos", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), systemController.Chaos)
+
+	systemGroup.Put("/chaos", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), systemController.UpdateChaos)
+
+	/*=============================================================================
+	| User Administration Routes
+	===============================================================================*/
+	userAdminGroup := api.Group("/admin/users", middleware.NoCache())
+
+	userAdminGroup.Get("/", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), userAdminController.Index)
+
+	userAdminGroup.Get("/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), userAdminController.Show)
+
+	userAdminGroup.Get("/:id/permissions", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), userAdminController.Permissions)
+
+	userAdminGroup.Put("/:id/active", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), userAdminController.SetActive)
+
+	userAdminGroup.Post("/:id/branches", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), userAdminController.MapBranch)
+
+	userAdminGroup.Delete("/:id/branches/:code", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), userAdminController.UnmapBranch)
+
+	userAdminGroup.Get("/:id/audit", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), userAdminController.Audit)
+
+	// Actions a postman or operator performed, for HR reviews and incident investigations
+	userAdminGroup.Get("/:id/activity", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), userAdminController.Activity)
+
+	// Operator onboarding queue; postmasters review registrations for their branches
+	registrationGroup := api.Group("/admin/registrations", middleware.NoCache())
+
+	registrationGroup.Get("/", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+	), userAdminController.Registrations)
+
+	registrationGroup.Post("/:id/approve", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+	), middleware.ValidateParams(middleware.PathID("id")), userAdminController.ApproveRegistration)
+
+	registrationGroup.Post("/:id/reject", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+	), middleware.ValidateParams(middleware.PathID("id")), userAdminController.RejectRegistration)
+
+	// Temporary permission grants, e.g. an acting postmaster during leave
+	delegationGroup := api.Group("/delegations", middleware.NoCache())
+
+	delegationGroup.Post("/", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+	), userAdminController.CreateDelegation)
+
+	delegationGroup.Get("/", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+	), userAdminController.Delegations)
+
+	delegationGroup.Delete("/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+	), middleware.ValidateParams(middleware.PathID("id")), userAdminController.RevokeDelegation)
+
+	/*=============================================================================
+	| Privacy Routes (erasure and legal hold)
+	===============================================================================*/
+	privacyGroup := api.Group("/privacy", middleware.NoCache())
+
+	privacyGroup.Post("/bookings/:id/erase", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), privacyController.Erase)
+
+	privacyGroup.Put("/bookings/:id/legal-hold", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), privacyController.SetLegalHold)
+
+	/*=============================================================================
+	| Report Routes
+	===============================================================================*/
+	reportGroup := api.Group("/reports", middleware.NoCache())
+
+	reportGroup.Get("/timeseries", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermPostOfficeFull,
+		constants.PermOrgSupervisorFull,
+		constants.PermViewerReadOnly,
+	), reportController.TimeSeries)
+
+	// KPIs rolled up from branches to districts and regions of the branch hierarchy
+	reportGroup.Get("/rollup", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermPostOfficeFull,
+		constants.PermOrgSupervisorFull,
+		constants.PermViewerReadOnly,
+	), reportController.Rollup)
+
+	reportGroup.Get("/branches/capacity", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermOrgSupervisorFull,
+		constants.PermViewerReadOnly,
+	), reportController.BranchCapacity)
+
+	reportGroup.Get("/deliveries/heatmap", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermOrgSupervisorFull,
+		constants.PermViewerReadOnly,
+	), reportController.DeliveryHeatmap)
+
+	// Anonymized delivery outcomes for the ministry's statistics division
+	reportGroup.Get("/research/dataset", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+	), reportController.AnonymizedDataset)
+
+	// Monthly availability and latency error budgets of the booking and delivery APIs
+	reportGroup.Get("/slo", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermViewerReadOnly,
+	), reportController.SLOReport)
+
+	reportGroup.Get("/postmen/leaderboard", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermPostOfficeFull,
+		constants.PermOrgSupervisorFull,
+		constants.PermViewerReadOnly,
+	), reportController.Leaderboard)
+
+	reportGroup.Get("/postmen/me/scorecard", middleware.RequirePermissions(
+		constants.PermPostmanFull,
+	), reportController.MyScorecard)
+
+	reportGroup.Get("/postmen/:id/scorecard", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermPostOfficeFull,
+		constants.PermOrgSupervisorFull,
+		constants.PermViewerReadOnly,
+	), middleware.ValidateParams(middleware.PathID("id")), reportController.Scorecard)
+
+	reportGroup.Get("/bookings/:id/custody", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermOrgSupervisorFull,
+	), middleware.ValidateParams(middleware.PathID("id")), reportController.CustodyChain)
+
+	/*=============================================================================
+	| Branch Routes
+	===============================================================================*/
+	branchGroup := api.Group("/branch", middleware.NoCache())
+
+	branchGroup.Get("/:code/inventory", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermOrgSupervisorFull,
+	), reportController.BranchInventory)
+
+	branchGroup.Get("/:code/schedule", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermOrgSupervisorFull,
+	), branchController.ShowSchedule)
+
+	branchGroup.Put("/:code/schedule", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+	), branchController.UpdateSchedule)
+
+	branchGroup.Post("/:code/stock-audits", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+	), branchController.CreateStockAudit)
+
+	branchGroup.Get("/:code/stock-audits/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermOrgSupervisorFull,
+	), middleware.ValidateParams(middleware.PathID("id")), branchController.ShowStockAudit)
+
+	branchGroup.Get("/:code/incidents", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermOrgSupervisorFull,
+	), branchController.ListIncidents)
+
+	branchGroup.Patch("/incidents/:id/resolve", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermOrgSupervisorFull,
+	), middleware.ValidateParams(middleware.PathID("id")), branchController.ResolveIncident)
+
+	// Region → district → branch tree behind rollup reports and scoped mappings
+	branchGroup.Get("/hierarchy", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPassportDPMGFull,
+		constants.PermEkdakDPMGFull,
+		constants.PermPostOfficeFull,
+		constants.PermOrgSupervisorFull,
+		constants.PermViewerReadOnly,
+	), branchController.Hierarchy)
+
+	branchGroup.Post("/hierarchy", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), branchController.CreateOrgUnit)
+
+	branchGroup.Put("/hierarchy/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), branchController.UpdateOrgUnit)
+
+	branchGroup.Delete("/hierarchy/:id", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), middleware.ValidateParams(middleware.PathID("id")), branchController.DeleteOrgUnit)
+
+	// Barcodes pre-allocated from DMS for intake
+	branchGroup.Get("/barcode-pools", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), branchController.ListBarcodePools)
+
+	branchGroup.Post("/:code/barcode-pool/refill", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+	), branchController.RefillBarcodePool)
+
 	/*=============================================================================
 	| Regional Passport Office Routes
 	===============================================================================*/
-	regionalOfficeGroup := api.Group("/regional-passport-office")
+	regionalOfficeGroup := api.Group("/regional-passport-office", middleware.CacheControl(time.Hour, "CACHE_MAX_AGE_RPO"))
 
 	// Get list of all regional passport offices (public route)
 	regionalOfficeGroup.Get("/list", middleware.RequirePermissions(
 		constants.PermParcelOperatorFull,
+		constants.PermViewerReadOnly,
 	), regionalPassportOfficeController.GetRegionalPassportOffices)
 
 	regionalOfficeGroup.Post("/store", middleware.RequirePermissions(
@@ -208,5 +924,78 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 
 	parcelBookingGroup.Get("/list", middleware.RequirePermissions(
 		constants.PermParcelOperatorFull,
-	), parcelBookingController.Index)
+		constants.PermViewerReadOnly,
+	), middleware.QueryBudget(3), parcelBookingController.Index)
+
+	// Swap provisional barcodes issued while DMS was down
+	parcelBookingGroup.Post("/reconcile-barcodes", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermParcelOperatorFull,
+	), parcelBookingController.ReconcileBarcodes)
+
+	// Parcel arrival at the RPO and hand-over to the applicant
+	parcelBookingGroup.Post("/receive", middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+		constants.PermParcelOperatorFull,
+	), parcelBookingController.Receive)
+
+	parcelBookingGroup.Post("/deliver/send-otp", middleware.RequirePermissions(
+		constants.PermPostOfficeFull,
+		constants.PermParcelOperatorFull,
+	), parcelBookingController.DeliverySendOtp)
+
+	parcelBookingGroup.Post("/deliver/verify-otp", middleware.RequirePermissions(
+		constants.PermPostOfficeFull,
+		constants.PermParcelOperatorFull,
+	), parcelBookingController.DeliveryVerifyOtp)
+
+	parcelBookingGroup.Post("/deliver/photo", middleware.RequirePermissions(
+		constants.PermPostOfficeFull,
+		constants.PermParcelOperatorFull,
+	), parcelBookingController.UploadDeliveryPhoto)
+
+	parcelBookingGroup.Post("/deliver", middleware.RequirePermissions(
+		constants.PermPostOfficeFull,
+		constants.PermParcelOperatorFull,
+	), parcelBookingController.Deliver)
+
+	// Operator counter sessions for daily cash reconciliation
+	parcelBookingGroup.Post("/sessions/open", middleware.RequirePermissions(
+		constants.PermParcelOperatorFull,
+	), parcelBookingController.OpenCounterSession)
+
+	parcelBookingGroup.Get("/sessions/current", middleware.NoCache(), middleware.RequirePermissions(
+		constants.PermParcelOperatorFull,
+	), parcelBookingController.CurrentCounterSession)
+
+	parcelBookingGroup.Post("/sessions/close", middleware.RequirePermissions(
+		constants.PermParcelOperatorFull,
+	), parcelBookingController.CloseCounterSession)
+
+	parcelBookingGroup.Get("/sessions", middleware.NoCache(), middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+		constants.PermOrgSupervisorFull,
+	), parcelBookingController.ListCounterSessions)
+
+	parcelBookingGroup.Get("/sessions/:id", middleware.NoCache(), middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+		constants.PermOrgSupervisorFull,
+	), middleware.ValidateParams(middleware.PathID("id")), parcelBookingController.ShowCounterSession)
+
+	// Customer receipt for the counter printer
+	parcelBookingGroup.Get("/receipt/:barcode", middleware.NoCache(), middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermPostOfficeFull,
+		constants.PermParcelOperatorFull,
+	), middleware.ValidateParams(middleware.PathBarcode("barcode")), parcelBookingController.Receipt)
+
+	// Parcel bookings whose DMS submission keeps failing
+	parcelBookingGroup.Get("/stuck-pushes", middleware.NoCache(), middleware.RequirePermissions(
+		constants.PermSuperAdminFull,
+		constants.PermParcelOperatorFull,
+		constants.PermViewerReadOnly,
+	), parcelBookingController.StuckPushes)
 }
@@ -0,0 +1,247 @@
+package address_change
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/database"
+	"passport-booking/httpServices/dms"
+	addressModel "passport-booking/models/address"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/transliterate"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IneligibleError explains why a booking cannot be redirected
+type IneligibleError struct {
+	Reason string
+}
+
+func (e *IneligibleError) Error() string {
+	return e.Reason
+}
+
+// ErrNotPending is returned when a change is applied twice or after it was replaced
+var ErrNotPending = errors.New("address change is no longer pending")
+
+// RerouteError is returned when DMS refuses the re-route; the change is marked failed
+type RerouteError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *RerouteError) Error() string {
+	return fmt.Sprintf("DMS re-route returned status %d", e.StatusCode)
+}
+
+// NewAddress is the requested delivery address. The delivery branch is the post office
+// serving it.
+type NewAddress struct {
+	Division       string
+	District       string
+	PoliceStation  string
+	PostOffice     string
+	PostOfficeCode string
+	StreetAddress  string
+	// Bangla renderings; empty ones are transliterated from the English fields
+	DivisionBn      string
+	DistrictBn      string
+	PoliceStationBn string
+	PostOfficeBn    string
+	StreetAddressBn string
+}
+
+// Eligible reports whether the booking can still be sent to another address
+func Eligible(b *bookingModel.Booking) error {
+	if b.Status.IsCompleted() {
+		return &IneligibleError{Reason: fmt.Sprintf("booking is already %s", b.Status)}
+	}
+	if b.Status == bookingModel.BookingItemStatusReceivedByPostman {
+		return &IneligibleError{Reason: "booking is already out for delivery"}
+	}
+	if b.AnonymizedAt != nil {
+		return &IneligibleError{Reason: "booking has been erased"}
+	}
+	if b.DeliveryGroupID != nil {
+		return &IneligibleError{Reason: "booking belongs to a delivery group; dissolve the group first"}
+	}
+	return nil
+}
+
+// Stage stores the new address and a change record for booking. Earlier pending changes
+// for the booking are canceled so only the latest request can be confirmed.
+func Stage(db *gorm.DB, b *bookingModel.Booking, initiator bookingModel.AddressChangeInitiator, requestedByID uint, addr NewAddress, reason *string) (*bookingModel.AddressChange, error) {
+	if err := Eligible(b); err != nil {
+		return nil, err
+	}
+
+	change := bookingModel.AddressChange{
+		BookingID:     b.ID,
+		InitiatedBy:   initiator,
+		RequestedByID: requestedByID,
+		Reason:        reason,
+		Status:        bookingModel.AddressChangeStatusPending,
+		OldAddressID:  b.DeliveryAddressID,
+		OldBranchCode: b.DeliveryBranchCode,
+		NewBranchCode: addr.PostOfficeCode,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		newAddress := addressModel.Address{
+			Division:        &addr.Division,
+			District:        &addr.District,
+			PoliceStation:   &addr.PoliceStation,
+			PostOffice:      &addr.PostOffice,
+			PostOfficeCode:  &addr.PostOfficeCode,
+			StreetAddress:   &addr.StreetAddress,
+			DivisionBn:      optional(addr.DivisionBn),
+			DistrictBn:      optional(addr.DistrictBn),
+			PoliceStationBn: optional(addr.PoliceStationBn),
+			PostOfficeBn:    optional(addr.PostOfficeBn),
+			StreetAddressBn: optional(addr.StreetAddressBn),
+		}
+		transliterate.Localize(&newAddress)
+		if err := tx.Create(&newAddress).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&bookingModel.AddressChange{}).
+			Where("booking_id = ? AND status = ?", b.ID, bookingModel.AddressChangeStatusPending).
+			Update("status", bookingModel.AddressChangeStatusCanceled).Error; err != nil {
+			return err
+		}
+
+		change.NewAddressID = newAddress.ID
+		change.NewAddress = &newAddress
+		return tx.Create(&change).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Before snapshot, taken while the booking still points at the old address
+	if err := booking_event.SnapshotBookingToEvent(db, b, "delivery_address_change_requested", strconv.FormatUint(uint64(requestedByID), 10)); err != nil {
+		return &change, err
+	}
+	return &change, nil
+}
+
+// Apply re-routes the article in DMS when it is already booked there, then points the
+// booking at the new address and branch. A DMS refusal marks the change failed.
+func Apply(db *gorm.DB, dmsClient dms.Client, authHeader string, changeID uint, actor string) (*bookingModel.AddressChange, error) {
+	var change bookingModel.AddressChange
+	if err := db.Preload("NewAddress").First(&change, changeID).Error; err != nil {
+		return nil, err
+	}
+	if change.Status != bookingModel.AddressChangeStatusPending {
+		return &change, ErrNotPending
+	}
+
+	var b bookingModel.Booking
+	if err := db.Preload("User").Scopes(database.NotDeletedBookings).First(&b, change.BookingID).Error; err != nil {
+		return &change, err
+	}
+	if err := Eligible(&b); err != nil {
+		return &change, err
+	}
+
+	if b.Barcode != nil && *b.Barcode != "" {
+		resp, err := dmsClient.RerouteArticle(authHeader, dms.RerouteArticleRequest{
+			ArticleID:      *b.Barcode,
+			DeliveryBranch: change.NewBranchCode,
+			Receiver:       receiver(&b, change.NewAddress),
+		})
+		if err != nil {
+			return &change, err
+		}
+
+		body := string(resp.Body)
+		change.RerouteStatusCode = &resp.StatusCode
+		change.RerouteResponse = &body
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			change.Status = bookingModel.AddressChangeStatusFailed
+			if err := db.Save(&change).Error; err != nil {
+				return &change, err
+			}
+			return &change, &RerouteError{StatusCode: resp.StatusCode, Body: body}
+		}
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Claim the change so a concurrent confirmation cannot apply it twice
+		result := tx.Model(&bookingModel.AddressChange{}).
+			Where("id = ? AND status = ?", change.ID, bookingModel.AddressChangeStatusPending).
+			Updates(map[string]interface{}{
+				"status":              bookingModel.AddressChangeStatusApplied,
+				"applied_at":          now,
+				"reroute_status_code": change.RerouteStatusCode,
+				"reroute_response":    change.RerouteResponse,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotPending
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(database.NotDeletedBookings).First(&b, b.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&b).Updates(map[string]interface{}{
+			"delivery_address_id":  change.NewAddressID,
+			"delivery_branch_code": change.NewBranchCode,
+			"updated_by":           actor,
+		}).Error; err != nil {
+			return err
+		}
+
+		// After snapshot, now pointing at the new address
+		return booking_event.SnapshotBookingToEvent(tx, &b, "delivery_address_changed", actor)
+	})
+	if err != nil {
+		return &change, err
+	}
+
+	change.Status = bookingModel.AddressChangeStatusApplied
+	change.AppliedAt = &now
+	return &change, nil
+}
+
+// receiver builds the DMS receiver block for the new address
+func receiver(b *bookingModel.Booking, addr *addressModel.Address) dms.Address {
+	value := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+
+	r := dms.Address{
+		AddressType: "home",
+		Country:     "Bangladesh",
+		PhoneNumber: b.Phone,
+		UserUUID:    b.User.Uuid,
+		Username:    b.User.Username,
+	}
+	if addr != nil {
+		r.Division = value(addr.Division)
+		r.District = value(addr.District)
+		r.PoliceStation = value(addr.PoliceStation)
+		r.PostOffice = value(addr.PostOffice)
+		r.StreetAddress = value(addr.StreetAddress)
+	}
+	return r
+}
+
+// optional maps an empty string to nil
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
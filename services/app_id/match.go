@@ -0,0 +1,89 @@
+package app_id
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MatchMode is how an application ID presented at delivery matched the booking
+type MatchMode string
+
+const (
+	MatchExact      MatchMode = "exact"       // identical to the stored ID
+	MatchNormalized MatchMode = "normalized"  // equal once separators and case are ignored
+	MatchLastDigits MatchMode = "last_digits" // the trailing characters of the stored ID
+
+	defaultLastDigits = 6
+	minLastDigits     = 4
+)
+
+// strictness orders the modes; a policy accepts its own mode and every stricter one
+var strictness = map[MatchMode]int{
+	MatchExact:      0,
+	MatchNormalized: 1,
+	MatchLastDigits: 2,
+}
+
+// MatchPolicy is the loosest mode accepted at delivery (APP_ID_MATCH_POLICY:
+// exact, normalized or last_digits; default normalized)
+func MatchPolicy() MatchMode {
+	policy := MatchMode(strings.ToLower(strings.TrimSpace(os.Getenv("APP_ID_MATCH_POLICY"))))
+	if _, ok := strictness[policy]; ok {
+		return policy
+	}
+	return MatchNormalized
+}
+
+// LastDigits is how many trailing characters the last_digits mode requires
+// (APP_ID_MATCH_LAST_DIGITS, default 6, at least 4)
+func LastDigits() int {
+	if v := os.Getenv("APP_ID_MATCH_LAST_DIGITS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= minLastDigits {
+			return n
+		}
+	}
+	return defaultLastDigits
+}
+
+// Normalize drops spaces, dashes, slashes and other separators printed on
+// receipts and upper-cases the rest
+func Normalize(id string) string {
+	var b strings.Builder
+	for _, r := range id {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// Match compares the ID an applicant presents with the stored one under the
+// configured policy and reports the strictest mode that matched
+func Match(stored, presented string) (MatchMode, bool) {
+	if stored == presented {
+		return MatchExact, true
+	}
+
+	policy := strictness[MatchPolicy()]
+	s, p := Normalize(stored), Normalize(presented)
+	if s == "" || p == "" {
+		return "", false
+	}
+	if policy >= strictness[MatchNormalized] && s == p {
+		return MatchNormalized, true
+	}
+	if policy >= strictness[MatchLastDigits] && len(p) >= LastDigits() && len(p) < len(s) && strings.HasSuffix(s, p) {
+		return MatchLastDigits, true
+	}
+	return "", false
+}
+
+// FailsChecksum reports whether a presented ID is recognized by a registered
+// system but fails its check digit, which points to a typo rather than the
+// wrong receipt
+func FailsChecksum(presented string) bool {
+	result, err := Validate("", Normalize(presented))
+	return err == nil && result.Verdict == VerdictInvalid
+}
@@ -0,0 +1,95 @@
+package app_id
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Verdict is what a validator concludes about an application/order ID
+type Verdict string
+
+const (
+	VerdictValid     Verdict = "valid"
+	VerdictInvalid   Verdict = "invalid"   // looks like this system's ID but is malformed
+	VerdictUncertain Verdict = "uncertain" // plausible, but needs a human to confirm
+	VerdictUnknown   Verdict = "unknown"   // does not look like this system's ID at all
+)
+
+// Validator checks IDs issued by one passport system
+type Validator interface {
+	Source() string
+	Description() string
+	Check(id string) (Verdict, string)
+}
+
+// Result is the outcome of validating an ID
+type Result struct {
+	Source  string  `json:"source,omitempty"` // empty when no system recognized the ID
+	Verdict Verdict `json:"verdict"`
+	Reason  string  `json:"reason,omitempty"`
+}
+
+var registry = struct {
+	sync.RWMutex
+	validators map[string]Validator
+}{validators: make(map[string]Validator)}
+
+// Register makes a validator available under its source system name
+func Register(v Validator) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.validators[v.Source()] = v
+}
+
+// Registered returns the registered validators ordered by source
+func Registered() []Validator {
+	registry.RLock()
+	defer registry.RUnlock()
+	validators := make([]Validator, 0, len(registry.validators))
+	for _, v := range registry.validators {
+		validators = append(validators, v)
+	}
+	sort.Slice(validators, func(i, j int) bool { return validators[i].Source() < validators[j].Source() })
+	return validators
+}
+
+func lookup(source string) (Validator, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	v, ok := registry.validators[source]
+	return v, ok
+}
+
+// Validate checks id against the named source system. With no source the system is
+// detected from the ID; an ID no system recognizes is uncertain rather than invalid,
+// since it may come from a system without a registered validator.
+func Validate(source, id string) (Result, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return Result{Source: source, Verdict: VerdictInvalid, Reason: "application/order ID is empty"}, nil
+	}
+
+	if source != "" {
+		v, ok := lookup(source)
+		if !ok {
+			return Result{}, fmt.Errorf("unsupported source_system: %s", source)
+		}
+		verdict, reason := v.Check(id)
+		if verdict == VerdictUnknown {
+			verdict = VerdictInvalid
+			if reason == "" {
+				reason = fmt.Sprintf("not a %s ID", v.Description())
+			}
+		}
+		return Result{Source: source, Verdict: verdict, Reason: reason}, nil
+	}
+
+	for _, v := range Registered() {
+		if verdict, reason := v.Check(id); verdict != VerdictUnknown {
+			return Result{Source: v.Source(), Verdict: verdict, Reason: reason}, nil
+		}
+	}
+	return Result{Verdict: VerdictUncertain, Reason: "no known passport system recognizes this ID format"}, nil
+}
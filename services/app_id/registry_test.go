@@ -0,0 +1,79 @@
+package app_id
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		id          string
+		wantSource  string
+		wantVerdict Verdict
+		wantErr     bool
+	}{
+		{"e-passport by source", "epassport", "OID1234567890", "epassport", VerdictValid, false},
+		{"e-passport detected", "", "OID1234567890", "epassport", VerdictValid, false},
+		{"MRP detected", "", "1234567890128", "mrp", VerdictValid, false},
+		{"MRP check digit", "mrp", "1234567890123", "mrp", VerdictInvalid, false},
+		{"unusual length", "", "OID123", "epassport", VerdictUncertain, false},
+		{"other system's ID for the source", "mrp", "OID1234567890", "mrp", VerdictInvalid, false},
+		{"no system recognizes it", "", "ABC-42", "", VerdictUncertain, false},
+		{"empty", "", "  ", "", VerdictInvalid, false},
+		{"unregistered source", "visa", "OID1234567890", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Validate(tt.source, tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if result.Source != tt.wantSource || result.Verdict != tt.wantVerdict {
+				t.Errorf("got %s/%s, want %s/%s (%s)", result.Source, result.Verdict, tt.wantSource, tt.wantVerdict, result.Reason)
+			}
+		})
+	}
+}
+
+// stubValidator recognizes a single ID
+type stubValidator struct{ source, id string }
+
+func (s stubValidator) Source() string      { return s.source }
+func (s stubValidator) Description() string { return s.source }
+func (s stubValidator) Check(id string) (Verdict, string) {
+	if id == s.id {
+		return VerdictValid, ""
+	}
+	return VerdictUnknown, ""
+}
+
+func TestRegister(t *testing.T) {
+	Register(stubValidator{source: "aaa-test", id: "STUB-1"})
+	t.Cleanup(func() {
+		registry.Lock()
+		delete(registry.validators, "aaa-test")
+		registry.Unlock()
+	})
+
+	if v, ok := lookup("aaa-test"); !ok || v.Source() != "aaa-test" {
+		t.Fatalf("registered validator not found, got %v", v)
+	}
+	registered := Registered()
+	for i := 1; i < len(registered); i++ {
+		if registered[i-1].Source() > registered[i].Source() {
+			t.Errorf("validators not ordered by source: %s before %s", registered[i-1].Source(), registered[i].Source())
+		}
+	}
+	if result, err := Validate("", "STUB-1"); err != nil || result.Source != "aaa-test" {
+		t.Errorf("detected %q (%v), want aaa-test", result.Source, err)
+	}
+}
+
+// BenchmarkAppIDValidate checks the application ID every booking create validates
+func BenchmarkAppIDValidate(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Validate("", "OID1234567890"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package app_id
+
+import (
+	"fmt"
+	"regexp"
+)
+
+func init() {
+	Register(patternValidator{
+		source:      "epassport",
+		description: "e-passport online application",
+		shape:       regexp.MustCompile(`^OID\d+$`),
+		exact:       regexp.MustCompile(`^OID\d{10}$`),
+	})
+	Register(patternValidator{
+		source:      "mrp",
+		description: "MRP enrolment",
+		shape:       regexp.MustCompile(`^\d{11,15}$`),
+		exact:       regexp.MustCompile(`^\d{13}$`),
+		checksum:    luhn,
+	})
+}
+
+// patternValidator recognizes a system's IDs by shape, then accepts those matching the
+// exact format and, when set, passing the checksum. Right shape with the wrong length
+// is left for review; a failed checksum is rejected.
+type patternValidator struct {
+	source      string
+	description string
+	shape       *regexp.Regexp
+	exact       *regexp.Regexp
+	checksum    func(string) bool
+}
+
+func (p patternValidator) Source() string { return p.source }
+
+func (p patternValidator) Description() string { return p.description }
+
+func (p patternValidator) Check(id string) (Verdict, string) {
+	if !p.shape.MatchString(id) {
+		return VerdictUnknown, ""
+	}
+	if !p.exact.MatchString(id) {
+		return VerdictUncertain, fmt.Sprintf("unusual length for a %s ID", p.description)
+	}
+	if p.checksum != nil && !p.checksum(id) {
+		return VerdictInvalid, fmt.Sprintf("%s ID fails its check digit", p.description)
+	}
+	return VerdictValid, ""
+}
+
+// luhn reports whether a string of digits carries a valid Luhn check digit
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
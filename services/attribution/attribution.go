@@ -0,0 +1,176 @@
+// Package attribution records who wrote an event in a readable form. Events keep
+// the actor as a stringified user ID (or a system actor such as "system:dms-outbox")
+// and this package denormalizes the actor's display name and role next to it at
+// write time, so timelines need no join and survive renames.
+package attribution
+
+import (
+	"fmt"
+	"passport-booking/constants"
+	userModel "passport-booking/models/user"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RoleSystem is the role recorded for non-user actors
+const RoleSystem = "system"
+
+// field names one actor reference of a model and the columns it is described in
+type field struct {
+	Ref  string // holds the user ID or system actor
+	Name string
+	Role string
+}
+
+// attributedFields are the actor references described on write
+var attributedFields = []field{
+	{Ref: "CreatedBy", Name: "CreatedByName", Role: "CreatedByRole"},
+	{Ref: "UpdatedBy", Name: "UpdatedByName", Role: "UpdatedByRole"},
+}
+
+// rolePermissions maps the permission granting each role, most senior first; a
+// user holding several is attributed the first
+var rolePermissions = []struct {
+	Permission string
+	Role       string
+}{
+	{constants.PermSuperAdminFull, "super-admin"},
+	{constants.PermEkdakDPMGFull, "dpmg"},
+	{constants.PermPassportDPMGFull, "dpmg"},
+	{constants.PermPostOfficeFull, "postmaster"},
+	{constants.PermOrgSupervisorFull, "supervisor"},
+	{constants.PermOperatorFull, "operator"},
+	{constants.PermParcelOperatorFull, "parcel-operator"},
+	{constants.PermPostmanFull, "postman"},
+	{constants.PermAgentHasFull, "agent"},
+	{constants.PermCustomerFull, "customer"},
+	{constants.PermViewerReadOnly, "viewer"},
+}
+
+// Actor is the readable form of an actor reference
+type Actor struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// cacheTTL bounds how long a resolved user is reused, so a rename shows up on
+// later events without a restart
+const cacheTTL = 5 * time.Minute
+
+type cachedActor struct {
+	actor    Actor
+	loadedAt time.Time
+}
+
+var cache = struct {
+	sync.Mutex
+	actors map[uint]cachedActor
+}{actors: make(map[uint]cachedActor)}
+
+// Role returns the role a user's permissions grant, empty when none is known
+func Role(permissions []string) string {
+	held := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		held[p] = true
+	}
+	for _, rp := range rolePermissions {
+		if held[rp.Permission] {
+			return rp.Role
+		}
+	}
+	return ""
+}
+
+// Describe returns the name and role of a user
+func Describe(user *userModel.User) Actor {
+	name := strings.TrimSpace(user.LegalName)
+	if name == "" {
+		name = user.Username
+	}
+	return Actor{Name: name, Role: Role(user.Permissions)}
+}
+
+// Resolve describes an actor reference. User IDs are looked up; any other non-empty
+// reference is a system actor and named by itself. ok is false when the reference
+// is empty or names no user.
+func Resolve(db *gorm.DB, ref string) (Actor, bool, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return Actor{}, false, nil
+	}
+	id, err := strconv.ParseUint(ref, 10, 64)
+	if err != nil {
+		return Actor{Name: ref, Role: RoleSystem}, true, nil
+	}
+
+	cache.Lock()
+	cached, hit := cache.actors[uint(id)]
+	cache.Unlock()
+	if hit && time.Since(cached.loadedAt) < cacheTTL {
+		return cached.actor, true, nil
+	}
+
+	var users []userModel.User
+	if err := db.Session(&gorm.Session{NewDB: true}).Where("id = ?", id).Limit(1).Find(&users).Error; err != nil {
+		return Actor{}, false, err
+	}
+	if len(users) == 0 {
+		return Actor{}, false, nil
+	}
+	actor := Describe(&users[0])
+
+	cache.Lock()
+	cache.actors[uint(id)] = cachedActor{actor: actor, loadedAt: time.Now()}
+	cache.Unlock()
+	return actor, true, nil
+}
+
+// RegisterCallback fills the actor name and role of every created row that has
+// them and leaves them blank, from the actor reference next to them
+func RegisterCallback(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:create").Register("attribution:describe", func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.Schema == nil {
+			return
+		}
+		for _, f := range attributedFields {
+			ref := tx.Statement.Schema.LookUpField(f.Ref)
+			name := tx.Statement.Schema.LookUpField(f.Name)
+			role := tx.Statement.Schema.LookUpField(f.Role)
+			if ref == nil || name == nil || role == nil {
+				continue
+			}
+
+			describe := func(rv reflect.Value) {
+				if current, zero := name.ValueOf(tx.Statement.Context, rv); !zero && current != "" {
+					return
+				}
+				value, zero := ref.ValueOf(tx.Statement.Context, rv)
+				if zero {
+					return
+				}
+				// Attribution is best effort: a lookup failure must not fail the write
+				actor, ok, err := Resolve(tx, fmt.Sprint(value))
+				if err != nil || !ok {
+					return
+				}
+				_ = name.Set(tx.Statement.Context, rv, actor.Name)
+				_ = role.Set(tx.Statement.Context, rv, actor.Role)
+			}
+
+			rv := reflect.Indirect(tx.Statement.ReflectValue)
+			switch rv.Kind() {
+			case reflect.Struct:
+				describe(rv)
+			case reflect.Slice, reflect.Array:
+				for i := 0; i < rv.Len(); i++ {
+					describe(reflect.Indirect(rv.Index(i)))
+				}
+			}
+		}
+	})
+}
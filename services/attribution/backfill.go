@@ -0,0 +1,73 @@
+package attribution
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Column is an actor reference column and the columns describing it
+type Column struct {
+	Table string
+	Ref   string
+	Name  string
+	Role  string
+}
+
+// Columns are the event columns described on write; rows written before the
+// describing columns existed are filled in by Backfill
+var Columns = []Column{
+	{Table: "booking_status_events", Ref: "created_by", Name: "created_by_name", Role: "created_by_role"},
+	{Table: "booking_events", Ref: "updated_by", Name: "updated_by_name", Role: "updated_by_role"},
+	{Table: "parcel_booking_status_events", Ref: "created_by", Name: "created_by_name", Role: "created_by_role"},
+}
+
+// BackfillResult reports what Backfill found in one column
+type BackfillResult struct {
+	Table      string `json:"table"`
+	Column     string `json:"column"`
+	Actors     int    `json:"actors"`     // distinct references described
+	Unresolved int    `json:"unresolved"` // references naming no user
+	Rows       int64  `json:"rows"`       // rows filled, or that would be on a dry run
+}
+
+// Backfill describes every row of col whose actor has no name yet. Rows are
+// updated one actor at a time, so a rerun picks up where a failed one stopped.
+func Backfill(db *gorm.DB, col Column, dryRun bool) (*BackfillResult, error) {
+	result := &BackfillResult{Table: col.Table, Column: col.Ref}
+	missing := fmt.Sprintf("(%s IS NULL OR %s = '')", col.Name, col.Name)
+	ref := fmt.Sprintf("CAST(%s AS TEXT)", col.Ref)
+
+	var refs []string
+	if err := db.Table(col.Table).Where(missing).Distinct(ref).Pluck(ref, &refs).Error; err != nil {
+		return result, fmt.Errorf("failed to list actors of %s.%s: %w", col.Table, col.Ref, err)
+	}
+
+	for _, r := range refs {
+		actor, ok, err := Resolve(db, r)
+		if err != nil {
+			return result, fmt.Errorf("failed to resolve actor %q: %w", r, err)
+		}
+		if !ok {
+			result.Unresolved++
+			continue
+		}
+		result.Actors++
+
+		query := db.Table(col.Table).Where(missing).Where(ref+" = ?", r)
+		if dryRun {
+			var count int64
+			if err := query.Count(&count).Error; err != nil {
+				return result, err
+			}
+			result.Rows += count
+			continue
+		}
+		update := query.Updates(map[string]interface{}{col.Name: actor.Name, col.Role: actor.Role})
+		if update.Error != nil {
+			return result, fmt.Errorf("failed to describe actor %q in %s: %w", r, col.Table, update.Error)
+		}
+		result.Rows += update.RowsAffected
+	}
+	return result, nil
+}
@@ -0,0 +1,302 @@
+// Package auto_heal repairs bookings left in inconsistent states, the fixes the
+// runbook used to do by hand in SQL. Every command can run as a dry run that only
+// reports what it would change, and every applied change is snapshotted to the
+// booking's event log.
+package auto_heal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CLIActor is recorded as the actor of fixes made from the command line
+const CLIActor = "system:auto-heal"
+
+// Command names one fix
+type Command string
+
+const (
+	CommandRecomputeStatus       Command = "recompute-status"
+	CommandRegenerateStatusEvent Command = "regenerate-status-events"
+	CommandRelinkBags            Command = "relink-bags"
+)
+
+// IsValid reports whether c names a known fix
+func (c Command) IsValid() bool {
+	switch c {
+	case CommandRecomputeStatus, CommandRegenerateStatusEvent, CommandRelinkBags:
+		return true
+	}
+	return false
+}
+
+// statusEventTolerance is how far apart a snapshot and the status event written in
+// the same request may be and still count as the same transition
+const statusEventTolerance = 5 * time.Minute
+
+// ErrNoScope is returned when neither booking IDs nor all bookings were asked for
+var ErrNoScope = errors.New("give booking IDs or ask for all bookings")
+
+// Options select the bookings a command looks at and whether it writes
+type Options struct {
+	BookingIDs []uint
+	All        bool // scan every booking, up to Limit candidates
+	Apply      bool // false only reports what would change
+	Force      bool // recompute-status: also move delivered or returned bookings and make moves the workflow does not allow
+	Limit      int
+	Actor      string // recorded in UpdatedBy and CreatedBy
+}
+
+// Change is one fix found, and made unless it was a dry run
+type Change struct {
+	BookingID uint       `json:"booking_id"`
+	From      string     `json:"from,omitempty"`
+	To        string     `json:"to"`
+	At        *time.Time `json:"at,omitempty"`
+	Applied   bool       `json:"applied"`
+	Skipped   string     `json:"skipped,omitempty"` // why a fix was found but not made
+}
+
+// Result reports what a command found and did
+type Result struct {
+	Command Command  `json:"command"`
+	DryRun  bool     `json:"dry_run"`
+	Changes []Change `json:"changes"`
+}
+
+// DefaultLimit caps the bookings one run fixes; AUTO_HEAL_LIMIT overrides it
+func DefaultLimit() int {
+	if v, err := strconv.Atoi(os.Getenv("AUTO_HEAL_LIMIT")); err == nil && v > 0 {
+		return v
+	}
+	return 500
+}
+
+// Run executes command with opts
+func Run(db *gorm.DB, command Command, opts Options) (*Result, error) {
+	if len(opts.BookingIDs) == 0 && !opts.All {
+		return nil, ErrNoScope
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultLimit()
+	}
+	if opts.Actor == "" {
+		opts.Actor = CLIActor
+	}
+
+	result := &Result{Command: command, DryRun: !opts.Apply, Changes: []Change{}}
+	var err error
+	switch command {
+	case CommandRecomputeStatus:
+		err = recomputeStatus(db, opts, result)
+	case CommandRegenerateStatusEvent:
+		err = regenerateStatusEvents(db, opts, result)
+	case CommandRelinkBags:
+		err = relinkBags(db, opts, result)
+	default:
+		err = fmt.Errorf("unknown command %q", command)
+	}
+	return result, err
+}
+
+// scope narrows a candidate query to the requested bookings
+func scope(query *gorm.DB, opts Options) *gorm.DB {
+	if len(opts.BookingIDs) > 0 {
+		query = query.Where("b.id IN ?", opts.BookingIDs)
+	}
+	return query.Where("b.deleted_at IS NULL").Order("b.id").Limit(opts.Limit)
+}
+
+// recomputeStatus moves bookings to the status mapped from the latest DMS callback
+// of their barcode, when it differs from the one stored
+func recomputeStatus(db *gorm.DB, opts Options, result *Result) error {
+	var rows []struct {
+		ID        uint
+		Status    bookingModel.BookingStatus
+		DMSStatus bookingModel.BookingStatus
+	}
+	err := scope(db.Table("bookings AS b").
+		Select("b.id, b.status, m.booking_status AS dms_status").
+		Joins(`JOIN LATERAL (SELECT c.dms_status FROM dms_callbacks c WHERE c.barcode = b.barcode
+			ORDER BY COALESCE(c.occurred_at, c.created_at) DESC, c.id DESC LIMIT 1) latest ON true`).
+		Joins("JOIN dms_status_mappings m ON m.dms_status = latest.dms_status AND m.active = true").
+		Where("b.status <> m.booking_status"), opts).
+		Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to compare bookings with DMS: %w", err)
+	}
+
+	for _, row := range rows {
+		change := Change{BookingID: row.ID, From: string(row.Status), To: string(row.DMSStatus)}
+		final := row.Status == bookingModel.BookingStatusDelivered || row.Status == bookingModel.BookingStatusReturnedToRPO
+		switch {
+		case final && !opts.Force:
+			change.Skipped = "booking is final, use force to move it"
+		case !row.Status.CanTransitionTo(row.DMSStatus) && !opts.Force:
+			change.Skipped = "the booking workflow does not allow this move, use force to make it"
+		case opts.Apply:
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var booking bookingModel.Booking
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, row.ID).Error; err != nil {
+					return err
+				}
+				if booking.Status != row.Status {
+					return errChanged
+				}
+				booking.Status = row.DMSStatus
+				booking.UpdatedBy = opts.Actor
+				if err := tx.Save(&booking).Error; err != nil {
+					return err
+				}
+				if err := tx.Create(&bookingModel.BookingStatusEvent{
+					BookingID: booking.ID,
+					Status:    booking.Status,
+					CreatedBy: opts.Actor,
+				}).Error; err != nil {
+					return err
+				}
+				return booking_event.SnapshotBookingToEvent(tx, &booking, "auto_heal_status", opts.Actor)
+			})
+			if err := applied(&change, err); err != nil {
+				return fmt.Errorf("failed to recompute status of booking %d: %w", row.ID, err)
+			}
+		}
+		result.Changes = append(result.Changes, change)
+	}
+	return nil
+}
+
+// regenerateStatusEvents writes the status events missing for transitions the
+// booking's event snapshots show. The snapshot's UpdatedAt is when it was taken.
+func regenerateStatusEvents(db *gorm.DB, opts Options, result *Result) error {
+	var bookings []struct {
+		ID           uint
+		AppOrOrderID string
+	}
+	query := db.Table("bookings AS b").Select("b.id, b.app_or_order_id")
+	if opts.All {
+		// Cheap first pass: bookings whose current status has no event at all
+		query = query.Where("NOT EXISTS (SELECT 1 FROM booking_status_events e WHERE e.booking_id = b.id AND e.status = b.status)")
+	}
+	if err := scope(query, opts).Scan(&bookings).Error; err != nil {
+		return fmt.Errorf("failed to find bookings: %w", err)
+	}
+
+	for _, b := range bookings {
+		var snapshots []bookingModel.BookingEvent
+		if err := db.Select("id", "status", "updated_by", "updated_at").
+			Where("app_or_order_id = ?", b.AppOrOrderID).Order("id").Find(&snapshots).Error; err != nil {
+			return fmt.Errorf("failed to load snapshots of booking %d: %w", b.ID, err)
+		}
+
+		previous := bookingModel.BookingStatusInitial
+		for _, s := range snapshots {
+			if s.Status == previous {
+				continue
+			}
+			previous = s.Status
+
+			var count int64
+			if err := db.Model(&bookingModel.BookingStatusEvent{}).
+				Where("booking_id = ? AND status = ? AND created_at BETWEEN ? AND ?",
+					b.ID, s.Status, s.UpdatedAt.Add(-statusEventTolerance), s.UpdatedAt.Add(statusEventTolerance)).
+				Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to check status events of booking %d: %w", b.ID, err)
+			}
+			if count > 0 {
+				continue
+			}
+
+			at := s.UpdatedAt
+			change := Change{BookingID: b.ID, To: string(s.Status), At: &at}
+			if opts.Apply {
+				actor := s.UpdatedBy
+				if actor == "" {
+					actor = opts.Actor
+				}
+				err := db.Create(&bookingModel.BookingStatusEvent{
+					BookingID: b.ID,
+					Status:    s.Status,
+					CreatedBy: actor,
+					CreatedAt: at,
+				}).Error
+				if err := applied(&change, err); err != nil {
+					return fmt.Errorf("failed to write status event of booking %d: %w", b.ID, err)
+				}
+			}
+			result.Changes = append(result.Changes, change)
+		}
+	}
+	return nil
+}
+
+// relinkBags restores current_bag_id on bookings that lost it while their latest
+// snapshot still shows them in a bag that exists
+func relinkBags(db *gorm.DB, opts Options, result *Result) error {
+	var rows []struct {
+		ID    uint
+		BagID string
+	}
+	err := scope(db.Table("bookings AS b").
+		Select("b.id, latest.current_bag_id AS bag_id").
+		Joins(`JOIN LATERAL (SELECT e.current_bag_id FROM booking_events e WHERE e.app_or_order_id = b.app_or_order_id
+			ORDER BY e.id DESC LIMIT 1) latest ON true`).
+		Joins("JOIN bags g ON g.bag_id = latest.current_bag_id").
+		Where("b.current_bag_id IS NULL"), opts).
+		Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("failed to find bookings without a bag: %w", err)
+	}
+
+	for _, row := range rows {
+		change := Change{BookingID: row.ID, To: row.BagID}
+		if opts.Apply {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var booking bookingModel.Booking
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, row.ID).Error; err != nil {
+					return err
+				}
+				if booking.CurrentBagID != nil {
+					return errChanged
+				}
+				if err := tx.Model(&booking).Updates(map[string]interface{}{
+					"current_bag_id": row.BagID,
+					"updated_by":     opts.Actor,
+				}).Error; err != nil {
+					return err
+				}
+				return booking_event.SnapshotBookingToEvent(tx, &booking, "auto_heal_bag_relink", opts.Actor)
+			})
+			if err := applied(&change, err); err != nil {
+				return fmt.Errorf("failed to relink booking %d: %w", row.ID, err)
+			}
+		}
+		result.Changes = append(result.Changes, change)
+	}
+	return nil
+}
+
+// errChanged aborts a fix when the booking changed after it was picked
+var errChanged = errors.New("booking changed since it was checked")
+
+// applied marks change as made, or as skipped when the booking moved underneath,
+// and returns any other error
+func applied(change *Change, err error) error {
+	switch {
+	case err == nil:
+		change.Applied = true
+	case errors.Is(err, errChanged):
+		change.Skipped = err.Error()
+	default:
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package bag_limit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultItemWeightGrams is used when an item is added without a weight; it matches the
+// weight passports are booked with in DMS
+const DefaultItemWeightGrams = 100
+
+// MaxItems is how many items one bag may hold, BAG_MAX_ITEMS (default 50)
+func MaxItems() int {
+	return envInt("BAG_MAX_ITEMS", 50)
+}
+
+// MaxWeightGrams is the heaviest a bag may get, BAG_MAX_WEIGHT_GRAMS (default 30kg)
+func MaxWeightGrams() int {
+	return envInt("BAG_MAX_WEIGHT_GRAMS", 30000)
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// ErrBagNotOpen is returned when items are added to a closed or received bag
+var ErrBagNotOpen = errors.New("bag is not open")
+
+// LimitError is returned when one more item would take the bag over a limit
+type LimitError struct {
+	Bag            bookingModel.Bag
+	MaxItems       int
+	MaxWeightGrams int
+}
+
+func (e *LimitError) Error() string {
+	if e.Bag.ItemCount >= e.MaxItems {
+		return fmt.Sprintf("bag %s is full (%d of %d items); close it and open a new bag",
+			e.Bag.BagID, e.Bag.ItemCount, e.MaxItems)
+	}
+	return fmt.Sprintf("bag %s would exceed its weight limit (%d of %d g); close it and open a new bag",
+		e.Bag.BagID, e.Bag.WeightGrams, e.MaxWeightGrams)
+}
+
+// Open records a bag created in DMS, reopening it if the ID was seen before
+func Open(db *gorm.DB, bagID, bagType, destOfficeCode string, userID uint) error {
+	bag := bookingModel.Bag{
+		BagID:          bagID,
+		BagType:        bagType,
+		DestOfficeCode: destOfficeCode,
+		Status:         bookingModel.BagStatusOpen,
+		CreatedByID:    userID,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "bag_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"bag_type", "dest_office_code", "updated_at"}),
+	}).Create(&bag).Error
+}
+
+// Reserve counts one item of weightGrams against the bag, returning a *LimitError when
+// that would exceed a limit. Bags created before local tracking are picked up as open.
+// The check and increment are a single conditional update so concurrent adds cannot
+// overfill a bag.
+func Reserve(db *gorm.DB, bagID, bagType string, weightGrams int) error {
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&bookingModel.Bag{
+		BagID:   bagID,
+		BagType: bagType,
+		Status:  bookingModel.BagStatusOpen,
+	}).Error; err != nil {
+		return err
+	}
+
+	maxItems, maxWeight := MaxItems(), MaxWeightGrams()
+	result := db.Model(&bookingModel.Bag{}).
+		Where("bag_id = ? AND status = ?", bagID, bookingModel.BagStatusOpen).
+		Where("item_count + 1 <= ? AND weight_grams + ? <= ?", maxItems, weightGrams, maxWeight).
+		Updates(map[string]interface{}{
+			"item_count":   gorm.Expr("item_count + 1"),
+			"weight_grams": gorm.Expr("weight_grams + ?", weightGrams),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil
+	}
+
+	var bag bookingModel.Bag
+	if err := db.Where("bag_id = ?", bagID).First(&bag).Error; err != nil {
+		return err
+	}
+	if bag.Status != bookingModel.BagStatusOpen {
+		return ErrBagNotOpen
+	}
+	return &LimitError{Bag: bag, MaxItems: maxItems, MaxWeightGrams: maxWeight}
+}
+
+// Release gives back an item reserved for an add that did not go through
+func Release(db *gorm.DB, bagID string, weightGrams int) error {
+	return db.Model(&bookingModel.Bag{}).
+		Where("bag_id = ? AND item_count > 0", bagID).
+		Updates(map[string]interface{}{
+			"item_count":   gorm.Expr("item_count - 1"),
+			"weight_grams": gorm.Expr("GREATEST(weight_grams - ?, 0)", weightGrams),
+		}).Error
+}
+
+// SetStatus moves a bag to closed or received
+func SetStatus(db *gorm.DB, bagID string, status bookingModel.BagStatus) error {
+	updates := map[string]interface{}{"status": status}
+	if status == bookingModel.BagStatusClosed {
+		updates["closed_at"] = time.Now()
+	}
+	return db.Model(&bookingModel.Bag{}).Where("bag_id = ?", bagID).Updates(updates).Error
+}
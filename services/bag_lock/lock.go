@@ -0,0 +1,50 @@
+package bag_lock
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrBusy is returned when another add to the same bag did not finish within the wait
+var ErrBusy = errors.New("bag is busy with another operation")
+
+// Wait is how long an add waits for the bag, BAG_LOCK_WAIT_SECONDS (default 15)
+func Wait() time.Duration {
+	if v := os.Getenv("BAG_LOCK_WAIT_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 15 * time.Second
+}
+
+const pollInterval = 200 * time.Millisecond
+
+// With runs fn while holding a Postgres advisory lock keyed on bagID, so operations on
+// one bag are serialized across every instance of the service. The lock lives on a
+// pinned connection and is released when fn returns, including on panic.
+func With(db *gorm.DB, bagID string, fn func() error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		deadline := time.Now().Add(Wait())
+		for {
+			var locked bool
+			if err := conn.Raw("SELECT pg_try_advisory_lock(hashtext(?))", "bag:"+bagID).Scan(&locked).Error; err != nil {
+				return err
+			}
+			if locked {
+				break
+			}
+			if time.Now().After(deadline) {
+				return ErrBusy
+			}
+			time.Sleep(pollInterval)
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(hashtext(?))", "bag:"+bagID)
+
+		return fn()
+	})
+}
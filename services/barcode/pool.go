@@ -0,0 +1,182 @@
+package barcode
+
+import (
+	"fmt"
+	"os"
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	barcodeModel "passport-booking/models/barcode"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPoolSize            = 200
+	defaultPoolIntervalMinutes = 10
+	// MaxRefill caps how many barcodes a single refill requests from DMS
+	MaxRefill = 1000
+)
+
+// PoolSize is the number of available barcodes kept per branch; BARCODE_POOL_SIZE
+// overrides the default of 200
+func PoolSize() int {
+	return envInt("BARCODE_POOL_SIZE", defaultPoolSize)
+}
+
+// PoolBranches lists the branches the refill job keeps stocked, from the comma
+// separated BARCODE_POOL_BRANCHES
+func PoolBranches() []string {
+	var branches []string
+	for _, code := range strings.Split(os.Getenv("BARCODE_POOL_BRANCHES"), ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			branches = append(branches, code)
+		}
+	}
+	return branches
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// TakePooled hands out the oldest available pooled barcode of the branch. ok is false
+// when the pool is empty. Concurrent callers skip rows another transaction is taking
+// instead of waiting on them.
+func TakePooled(db *gorm.DB, branchCode string) (barcode string, ok bool, err error) {
+	if branchCode == "" {
+		return "", false, nil
+	}
+
+	var taken []string
+	err = db.Raw(`UPDATE pooled_barcodes SET status = ?, consumed_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM pooled_barcodes
+			WHERE branch_code = ? AND status = ?
+			ORDER BY id LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING barcode`,
+		barcodeModel.PooledBarcodeStatusConsumed, time.Now(), time.Now(),
+		branchCode, barcodeModel.PooledBarcodeStatusAvailable).
+		Scan(&taken).Error
+	if err != nil || len(taken) == 0 {
+		return "", false, err
+	}
+	return taken[0], true, nil
+}
+
+// Available returns how many pooled barcodes the branch has left
+func Available(db *gorm.DB, branchCode string) (int64, error) {
+	var count int64
+	err := db.Model(&barcodeModel.PooledBarcode{}).
+		Where("branch_code = ? AND status = ?", branchCode, barcodeModel.PooledBarcodeStatusAvailable).
+		Count(&count).Error
+	return count, err
+}
+
+// PoolLevel is the stock of one branch's pool
+type PoolLevel struct {
+	BranchCode string `json:"branch_code"`
+	Available  int64  `json:"available"`
+	Consumed   int64  `json:"consumed"`
+}
+
+// Levels returns the pool stock of every branch that has one
+func Levels(db *gorm.DB) ([]PoolLevel, error) {
+	var levels []PoolLevel
+	err := db.Model(&barcodeModel.PooledBarcode{}).
+		Select(`branch_code,
+			COUNT(*) FILTER (WHERE status = ?) AS available,
+			COUNT(*) FILTER (WHERE status = ?) AS consumed`,
+			barcodeModel.PooledBarcodeStatusAvailable, barcodeModel.PooledBarcodeStatusConsumed).
+		Group("branch_code").Order("branch_code").
+		Scan(&levels).Error
+	return levels, err
+}
+
+// Refill fetches count barcodes from DMS into the branch pool and returns how many
+// were added. It stops at the first DMS failure, keeping what was fetched so far.
+func (s *Service) Refill(authHeader, branchCode string, count int) (int, error) {
+	added := 0
+	for added < count {
+		barcode, err := s.DMS.GetBarcode(authHeader, dms.GetBarcodeRequest{ServiceType: "letter"})
+		if err != nil {
+			return added, fmt.Errorf("DMS barcode request failed after %d barcodes: %w", added, err)
+		}
+		if err := s.DB.Create(&barcodeModel.PooledBarcode{
+			Barcode:    barcode,
+			BranchCode: branchCode,
+			Status:     barcodeModel.PooledBarcodeStatusAvailable,
+		}).Error; err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
+
+// TopUp refills the branch pool up to PoolSize and returns how many were added
+func (s *Service) TopUp(authHeader, branchCode string) (int, error) {
+	available, err := Available(s.DB, branchCode)
+	if err != nil {
+		return 0, err
+	}
+	missing := PoolSize() - int(available)
+	if missing <= 0 {
+		return 0, nil
+	}
+	if missing > MaxRefill {
+		missing = MaxRefill
+	}
+	return s.Refill(authHeader, branchCode, missing)
+}
+
+// StartPoolScheduler tops up the pool of every branch in BARCODE_POOL_BRANCHES every
+// BARCODE_POOL_INTERVAL_MINUTES (default 10) with the DMS service token. It does
+// nothing while no branches or no service token are configured. The returned
+// function stops the job.
+func StartPoolScheduler(db *gorm.DB) func() {
+	interval := time.Duration(envInt("BARCODE_POOL_INTERVAL_MINUTES", defaultPoolIntervalMinutes)) * time.Minute
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	s := NewBarcodeService(db, dms.NewDMSService())
+
+	run := func() {
+		authHeader := dms.ServiceAuthHeader()
+		if authHeader == "" {
+			return
+		}
+		for _, branch := range PoolBranches() {
+			added, err := s.TopUp(authHeader, branch)
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to top up barcode pool of branch %s", branch), err)
+			}
+			if added > 0 {
+				logger.Info(fmt.Sprintf("Added %d barcodes to the pool of branch %s", added, branch))
+			}
+		}
+	}
+
+	go func() {
+		run()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,191 @@
+package barcode
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	barcodeModel "passport-booking/models/barcode"
+	"passport-booking/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Service issues article barcodes, falling back to a provisional local range
+// when DMS is unavailable and BARCODE_FALLBACK_ENABLED is set
+type Service struct {
+	DB  *gorm.DB
+	DMS dms.Client
+}
+
+// NewBarcodeService creates a new barcode service
+func NewBarcodeService(db *gorm.DB, dmsClient dms.Client) *Service {
+	return &Service{
+		DB:  db,
+		DMS: dmsClient,
+	}
+}
+
+// FallbackEnabled reports whether provisional barcodes may be issued
+func FallbackEnabled() bool {
+	return strings.EqualFold(os.Getenv("BARCODE_FALLBACK_ENABLED"), "true")
+}
+
+// IsProvisional reports whether a barcode was issued from the local range
+func IsProvisional(barcode string) bool {
+	return utils.IsProvisionalBarcode(barcode)
+}
+
+// Obtain returns a DMS barcode, taken from the branch pool when it has one and
+// requested from DMS otherwise, or a provisional one for branchCode when DMS fails
+// and the fallback is enabled. provisional is true in the latter case.
+func (s *Service) Obtain(authHeader, branchCode string) (barcode string, provisional bool, err error) {
+	if pooled, ok, err := TakePooled(s.DB, branchCode); err != nil {
+		logger.Error(fmt.Sprintf("Failed to take pooled barcode for branch %s", branchCode), err)
+	} else if ok {
+		return pooled, false, nil
+	}
+
+	barcode, dmsErr := s.DMS.GetBarcode(authHeader, dms.GetBarcodeRequest{ServiceType: "letter"})
+	if dmsErr == nil {
+		return barcode, false, nil
+	}
+
+	if !FallbackEnabled() {
+		return "", false, dmsErr
+	}
+
+	logger.Warning(fmt.Sprintf("DMS barcode generation failed, issuing provisional barcode: %v", dmsErr))
+	barcode, err = s.issueProvisional(branchCode)
+	if err != nil {
+		return "", false, fmt.Errorf("DMS barcode failed (%v) and provisional fallback failed: %w", dmsErr, err)
+	}
+	return barcode, true, nil
+}
+
+// issueProvisional allocates the next sequence in the branch range
+func (s *Service) issueProvisional(branchCode string) (string, error) {
+	if branchCode == "" {
+		branchCode = "000000"
+	}
+
+	var barcode string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		seq := barcodeModel.BarcodeSequence{BranchCode: branchCode}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("branch_code = ?", branchCode).First(&seq).Error; err != nil {
+			return err
+		}
+
+		seq.LastValue++
+		if err := tx.Save(&seq).Error; err != nil {
+			return err
+		}
+
+		var err error
+		barcode, err = utils.FormatProvisionalBarcode(branchCode, seq.LastValue)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&barcodeModel.ProvisionalBarcode{
+			Barcode:    barcode,
+			BranchCode: branchCode,
+			Sequence:   seq.LastValue,
+			Status:     barcodeModel.ProvisionalBarcodeStatusPending,
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return barcode, nil
+}
+
+// Attach links a provisional barcode to the record that now carries it
+func (s *Service) Attach(db *gorm.DB, barcode string, entityType barcodeModel.EntityType, entityID uint) error {
+	return db.Model(&barcodeModel.ProvisionalBarcode{}).
+		Where("barcode = ?", barcode).
+		Updates(map[string]interface{}{"entity_type": entityType, "entity_id": entityID}).Error
+}
+
+// Reconcile swaps a provisional barcode for a DMS barcode on its entity and
+// returns the barcode that should be used from now on
+func (s *Service) Reconcile(authHeader, provisionalBarcode string) (string, error) {
+	var record barcodeModel.ProvisionalBarcode
+	if err := s.DB.Where("barcode = ?", provisionalBarcode).First(&record).Error; err != nil {
+		return "", fmt.Errorf("provisional barcode not found: %w", err)
+	}
+
+	if record.Status == barcodeModel.ProvisionalBarcodeStatusReconciled && record.DMSBarcode != nil {
+		return *record.DMSBarcode, nil
+	}
+
+	dmsBarcode, err := s.DMS.GetBarcode(authHeader, dms.GetBarcodeRequest{ServiceType: "letter"})
+	if err != nil {
+		msg := err.Error()
+		s.DB.Model(&record).Updates(map[string]interface{}{
+			"attempts":   record.Attempts + 1,
+			"last_error": msg,
+		})
+		return "", fmt.Errorf("failed to obtain DMS barcode: %w", err)
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		table := ""
+		switch record.EntityType {
+		case barcodeModel.EntityTypeBooking:
+			table = "bookings"
+		case barcodeModel.EntityTypeParcelBooking:
+			table = "parcel_bookings"
+		default:
+			return fmt.Errorf("provisional barcode %s is not attached to a record", record.Barcode)
+		}
+
+		if err := tx.Table(table).Where("id = ? AND barcode = ?", record.EntityID, record.Barcode).
+			Update("barcode", dmsBarcode).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&record).Updates(map[string]interface{}{
+			"status":        barcodeModel.ProvisionalBarcodeStatusReconciled,
+			"dms_barcode":   dmsBarcode,
+			"attempts":      record.Attempts + 1,
+			"last_error":    nil,
+			"reconciled_at": &now,
+		}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to swap provisional barcode: %w", err)
+	}
+
+	logger.Success(fmt.Sprintf("Provisional barcode %s reconciled to %s", provisionalBarcode, dmsBarcode))
+	return dmsBarcode, nil
+}
+
+// ReconcilePending reconciles up to limit pending provisional barcodes and
+// returns how many were swapped
+func (s *Service) ReconcilePending(authHeader string, limit int) (int, error) {
+	var pending []barcodeModel.ProvisionalBarcode
+	if err := s.DB.Where("status = ? AND entity_id > 0", barcodeModel.ProvisionalBarcodeStatusPending).
+		Order("created_at ASC").Limit(limit).Find(&pending).Error; err != nil {
+		return 0, err
+	}
+
+	reconciled := 0
+	for _, record := range pending {
+		if _, err := s.Reconcile(authHeader, record.Barcode); err != nil {
+			logger.Error(fmt.Sprintf("Failed to reconcile provisional barcode %s", record.Barcode), err)
+			continue
+		}
+		reconciled++
+	}
+	return reconciled, nil
+}
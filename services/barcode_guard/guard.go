@@ -0,0 +1,281 @@
+package barcode_guard
+
+import (
+	"fmt"
+	"os"
+	"passport-booking/logger"
+	barcodeModel "passport-booking/models/barcode"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How often an instance reloads active blocks so blocks raised or lifted by other
+// instances take effect
+const refreshInterval = time.Minute
+
+// lookup is one barcode lookup made by a source
+type lookup struct {
+	at      time.Time
+	barcode string
+	found   bool
+}
+
+// Guard watches barcode lookups per source and holds back sources that miss too
+// often or walk through sequential barcodes. Lookups are tracked in memory per
+// instance; blocks are stored so every instance and the admin view see them.
+type Guard struct {
+	db *gorm.DB
+
+	window        time.Duration
+	missLimit     int
+	missPercent   int
+	sequentialRun int
+	sequentialGap int64
+	throttleFor   time.Duration
+	blockFor      time.Duration
+
+	mu       sync.Mutex
+	lookups  map[string][]lookup
+	blocks   map[string]barcodeModel.ProbeBlock
+	loadedAt time.Time
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultGuard *Guard
+)
+
+// Default returns the process wide guard, created on first use
+func Default(db *gorm.DB) *Guard {
+	defaultOnce.Do(func() {
+		defaultGuard = New(db)
+	})
+	return defaultGuard
+}
+
+// New creates a guard configured from the environment:
+//   - BARCODE_GUARD_WINDOW_SECONDS (600) lookups are judged over this window
+//   - BARCODE_GUARD_MISS_LIMIT (10) and BARCODE_GUARD_MISS_PERCENT (60) throttle a
+//     source with at least that many not-found lookups making up that share
+//   - BARCODE_GUARD_SEQUENTIAL_RUN (5) blocks a source whose lookups hit that many
+//     barcodes in a row no more than BARCODE_GUARD_SEQUENTIAL_GAP (10) apart
+//   - BARCODE_GUARD_THROTTLE_MINUTES (5) and BARCODE_GUARD_BLOCK_MINUTES (60)
+func New(db *gorm.DB) *Guard {
+	return &Guard{
+		db:            db,
+		window:        time.Duration(envInt("BARCODE_GUARD_WINDOW_SECONDS", 600)) * time.Second,
+		missLimit:     envInt("BARCODE_GUARD_MISS_LIMIT", 10),
+		missPercent:   envInt("BARCODE_GUARD_MISS_PERCENT", 60),
+		sequentialRun: envInt("BARCODE_GUARD_SEQUENTIAL_RUN", 5),
+		sequentialGap: int64(envInt("BARCODE_GUARD_SEQUENTIAL_GAP", 10)),
+		throttleFor:   time.Duration(envInt("BARCODE_GUARD_THROTTLE_MINUTES", 5)) * time.Minute,
+		blockFor:      time.Duration(envInt("BARCODE_GUARD_BLOCK_MINUTES", 60)) * time.Minute,
+		lookups:       make(map[string][]lookup),
+		blocks:        make(map[string]barcodeModel.ProbeBlock),
+	}
+}
+
+// Check returns the active block of the first held back source, or nil
+func (g *Guard) Check(sources []string, now time.Time) *barcodeModel.ProbeBlock {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.refresh(now)
+	for _, source := range sources {
+		if block, ok := g.blocks[source]; ok && block.BlockedUntil.After(now) {
+			return &block
+		}
+	}
+	return nil
+}
+
+// Record notes a lookup by each source and raises a block when the source's recent
+// lookups look like probing
+func (g *Guard) Record(sources []string, barcode string, found bool, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, source := range sources {
+		recent := g.trim(g.lookups[source], now)
+		recent = append(recent, lookup{at: now, barcode: barcode, found: found})
+		g.lookups[source] = recent
+
+		if block, ok := g.blocks[source]; ok && block.BlockedUntil.After(now) {
+			continue
+		}
+		block := g.evaluate(source, recent, now)
+		if block == nil {
+			continue
+		}
+		if err := g.db.Create(block).Error; err != nil {
+			logger.Error("Failed to store barcode probe block", err)
+		}
+		g.blocks[source] = *block
+		delete(g.lookups, source)
+		logger.Warning(fmt.Sprintf("Barcode probing: %s %s until %s, %s",
+			block.Action, source, block.BlockedUntil.Format(time.RFC3339), block.Reason))
+	}
+}
+
+// evaluate applies the sequential and miss rate rules to a source's lookups
+func (g *Guard) evaluate(source string, recent []lookup, now time.Time) *barcodeModel.ProbeBlock {
+	misses := 0
+	for _, l := range recent {
+		if !l.found {
+			misses++
+		}
+	}
+	block := &barcodeModel.ProbeBlock{Source: source, Lookups: len(recent), Misses: misses}
+
+	if run := longestSequentialRun(recent, g.sequentialGap); len(run) >= g.sequentialRun {
+		block.Action = barcodeModel.ProbeActionBlock
+		block.Reason = fmt.Sprintf("%d sequential barcodes looked up within %s", len(run), g.window)
+		block.Sample = strings.Join(run, ",")
+		block.BlockedUntil = now.Add(g.blockFor)
+		return block
+	}
+	if misses >= g.missLimit && misses*100 >= g.missPercent*len(recent) {
+		block.Action = barcodeModel.ProbeActionThrottle
+		block.Reason = fmt.Sprintf("%d of %d lookups within %s were not found", misses, len(recent), g.window)
+		block.BlockedUntil = now.Add(g.throttleFor)
+		return block
+	}
+	return nil
+}
+
+// longestSequentialRun returns the longest run of consecutive lookups whose barcodes
+// share a prefix and step by at most gap, e.g. EA100000123BD, EA100000124BD, ...
+func longestSequentialRun(recent []lookup, gap int64) []string {
+	var longest, run []string
+	var prevPrefix string
+	var prevNumber int64
+	for _, l := range recent {
+		prefix, number, ok := splitBarcode(l.barcode)
+		if !ok {
+			run = nil
+			continue
+		}
+		step := number - prevNumber
+		if step < 0 {
+			step = -step
+		}
+		if len(run) > 0 && prefix == prevPrefix && step > 0 && step <= gap {
+			run = append(run, l.barcode)
+		} else {
+			run = []string{l.barcode}
+		}
+		prevPrefix, prevNumber = prefix, number
+		if len(run) > len(longest) {
+			longest = run
+		}
+	}
+	return longest
+}
+
+// splitBarcode separates the longest digit group of a barcode from the rest
+func splitBarcode(barcode string) (string, int64, bool) {
+	start, end := -1, -1
+	for i := 0; i < len(barcode); {
+		if barcode[i] < '0' || barcode[i] > '9' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(barcode) && barcode[j] >= '0' && barcode[j] <= '9' {
+			j++
+		}
+		if j-i > end-start {
+			start, end = i, j
+		}
+		i = j
+	}
+	if start < 0 || end-start > 18 {
+		return "", 0, false
+	}
+	number, err := strconv.ParseInt(barcode[start:end], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return barcode[:start] + "#" + barcode[end:], number, true
+}
+
+// trim drops the lookups that fell out of the window
+func (g *Guard) trim(recent []lookup, now time.Time) []lookup {
+	cutoff := now.Add(-g.window)
+	i := 0
+	for i < len(recent) && !recent[i].at.After(cutoff) {
+		i++
+	}
+	return recent[i:]
+}
+
+// refresh reloads the active blocks and forgets idle sources once per interval
+func (g *Guard) refresh(now time.Time) {
+	if now.Sub(g.loadedAt) < refreshInterval {
+		return
+	}
+	g.loadedAt = now
+
+	var active []barcodeModel.ProbeBlock
+	if err := g.db.Where("lifted_at IS NULL AND blocked_until > ?", now).Order("created_at").Find(&active).Error; err != nil {
+		logger.Error("Failed to load barcode probe blocks", err)
+	} else {
+		g.blocks = make(map[string]barcodeModel.ProbeBlock, len(active))
+		for _, block := range active {
+			g.blocks[block.Source] = block
+		}
+	}
+
+	for source, recent := range g.lookups {
+		if len(g.trim(recent, now)) == 0 {
+			delete(g.lookups, source)
+		}
+	}
+}
+
+// Active lists the blocks in force, newest first
+func (g *Guard) Active(now time.Time) ([]barcodeModel.ProbeBlock, error) {
+	var blocks []barcodeModel.ProbeBlock
+	err := g.db.Where("lifted_at IS NULL AND blocked_until > ?", now).Order("created_at DESC").Find(&blocks).Error
+	return blocks, err
+}
+
+// Recent lists the latest blocks including lifted and expired ones
+func (g *Guard) Recent(limit int) ([]barcodeModel.ProbeBlock, error) {
+	var blocks []barcodeModel.ProbeBlock
+	err := g.db.Order("created_at DESC").Limit(limit).Find(&blocks).Error
+	return blocks, err
+}
+
+// Lift ends a block early. It returns gorm.ErrRecordNotFound when no active block
+// has the id.
+func (g *Guard) Lift(id uint, by string, now time.Time) (*barcodeModel.ProbeBlock, error) {
+	var block barcodeModel.ProbeBlock
+	if err := g.db.Where("id = ? AND lifted_at IS NULL", id).First(&block).Error; err != nil {
+		return nil, err
+	}
+	block.LiftedBy = &by
+	block.LiftedAt = &now
+	if err := g.db.Model(&block).Updates(map[string]interface{}{"lifted_by": by, "lifted_at": now}).Error; err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	delete(g.blocks, block.Source)
+	delete(g.lookups, block.Source)
+	g.mu.Unlock()
+	return &block, nil
+}
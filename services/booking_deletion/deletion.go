@@ -0,0 +1,167 @@
+// Package booking_deletion soft-deletes bookings created or cancelled by mistake and
+// restores them within a window. DMS has no call to cancel an item, so a booking can
+// only be deleted before DMS holds it in a bag; intake queued for DMS is held in the
+// outbox while the booking is deleted and replayed once it is restored.
+package booking_deletion
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/clock"
+	"passport-booking/services/dms_outbox"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultWindowHours = 72
+
+// ValidationError explains why a booking cannot be deleted or restored
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Reason
+}
+
+var (
+	ErrAlreadyDeleted = errors.New("booking is already deleted")
+	ErrNotDeleted     = errors.New("booking is not deleted")
+	ErrWindowPassed   = errors.New("the restore window has passed")
+)
+
+// Clock decides whether the restore window has passed; tests can swap in a fake
+var Clock clock.Clock = clock.System
+
+// deletableStatuses are the statuses a booking can be deleted in: the item has not
+// been handed over to the post yet
+var deletableStatuses = map[bookingModel.BookingStatus]bool{
+	bookingModel.BookingStatusInitial:   true,
+	bookingModel.BookingStatusPreBooked: true,
+	bookingModel.BookingStatusBooked:    true,
+}
+
+// Window is how long after deletion a booking can still be restored;
+// BOOKING_RESTORE_WINDOW_HOURS overrides it
+func Window() time.Duration {
+	hours := defaultWindowHours
+	if v := os.Getenv("BOOKING_RESTORE_WINDOW_HOURS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			hours = n
+		}
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// Delete soft-deletes the booking and holds back its queued DMS intake
+func Delete(db *gorm.DB, b *bookingModel.Booking, reason string, actorID uint) (*bookingModel.BookingDeletion, error) {
+	if b.DeletedAt != nil {
+		return nil, ErrAlreadyDeleted
+	}
+	if !deletableStatuses[b.Status] {
+		return nil, &ValidationError{Reason: fmt.Sprintf("booking is already %s and can no longer be deleted", b.Status)}
+	}
+	if b.CurrentBagID != nil {
+		return nil, &ValidationError{Reason: fmt.Sprintf("booking is in bag %s in DMS and can no longer be deleted", *b.CurrentBagID)}
+	}
+
+	now := Clock.Now()
+	actor := strconv.FormatUint(uint64(actorID), 10)
+	deletion := bookingModel.BookingDeletion{
+		BookingID:   b.ID,
+		Status:      b.Status,
+		Reason:      reason,
+		DeletedByID: actorID,
+		DeletedAt:   now,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		suspended, err := dms_outbox.Suspend(tx, b.ID)
+		if err != nil {
+			return err
+		}
+		deletion.SuspendedEntries = suspended
+
+		// Only the first of two concurrent deletes gets through
+		result := tx.Model(&bookingModel.Booking{}).
+			Where("id = ? AND deleted_at IS NULL", b.ID).
+			Updates(map[string]interface{}{"deleted_at": now, "updated_by": actor})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyDeleted
+		}
+		if err := tx.Create(&deletion).Error; err != nil {
+			return err
+		}
+
+		b.DeletedAt = &now
+		b.UpdatedBy = actor
+		return booking_event.SnapshotBookingToEvent(tx, b, "booking_deleted", actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// Restore brings back a booking deleted less than Window ago and puts its held DMS
+// intake back in the outbox queue
+func Restore(db *gorm.DB, b *bookingModel.Booking, reason string, actorID uint) (*bookingModel.BookingDeletion, error) {
+	if b.DeletedAt == nil {
+		return nil, ErrNotDeleted
+	}
+	now := Clock.Now()
+	if now.Sub(*b.DeletedAt) > Window() {
+		return nil, ErrWindowPassed
+	}
+	if b.AnonymizedAt != nil {
+		return nil, &ValidationError{Reason: "booking has been erased"}
+	}
+
+	actor := strconv.FormatUint(uint64(actorID), 10)
+	var deletion bookingModel.BookingDeletion
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("booking_id = ? AND restored_at IS NULL", b.ID).Order("id DESC").First(&deletion).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		result := tx.Model(&bookingModel.Booking{}).
+			Where("id = ? AND deleted_at IS NOT NULL", b.ID).
+			Updates(map[string]interface{}{"deleted_at": nil, "updated_by": actor})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotDeleted
+		}
+
+		if _, err := dms_outbox.Resume(tx, b.ID); err != nil {
+			return err
+		}
+
+		// Bookings deleted before deletions were recorded have no row to close
+		if deletion.ID != 0 {
+			deletion.RestoredByID = &actorID
+			deletion.RestoredAt = &now
+			deletion.RestoreReason = &reason
+			if err := tx.Model(&deletion).Select("restored_by_id", "restored_at", "restore_reason").Updates(&deletion).Error; err != nil {
+				return err
+			}
+		}
+
+		b.DeletedAt = nil
+		b.UpdatedBy = actor
+		return booking_event.SnapshotBookingToEvent(tx, b, "booking_restored", actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
@@ -0,0 +1,454 @@
+package booking_event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/logger"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// Problems reported by the integrity check
+	ProblemNoEvents      = "no_events"
+	ProblemBrokenChain   = "broken_chain"
+	ProblemStateMismatch = "state_mismatch"
+
+	defaultCheckpointEvery   = 20
+	defaultCompactAfterDays  = 30
+	defaultIntegrityLimit    = 5000
+	defaultMaintenanceHours  = 24
+	maintenanceBatchSize     = 200
+	maxLoggedIntegrityIssues = 20
+)
+
+var ErrBookingNotFound = errors.New("booking not found")
+
+// payloadColumns are the applicant fields compaction moves into Diff. Columns
+// that readers filter or report on (status, event_type, updated_by, updated_at,
+// current_bag_id, barcode) and the encrypted OTP columns rotated by
+// cmd/reencrypt stay on every row.
+var payloadColumns = []string{
+	"name",
+	"father_name",
+	"mother_name",
+	"phone",
+	"delivery_phone",
+	"delivery_phone_applied_verified",
+	"delivery_phone_confirmed_verified",
+	"address",
+	"emergency_contact_name",
+	"emergency_contact_phone",
+	"delivery_branch_code",
+	"delivery_address_id",
+}
+
+// blankPayload is written over the payload of a compacted row; NOT NULL
+// columns get empty values, the rest NULL
+var blankPayload = map[string]interface{}{
+	"name":                              "",
+	"father_name":                       "",
+	"mother_name":                       "",
+	"phone":                             "",
+	"delivery_phone":                    nil,
+	"delivery_phone_applied_verified":   false,
+	"delivery_phone_confirmed_verified": false,
+	"address":                           "",
+	"emergency_contact_name":            nil,
+	"emergency_contact_phone":           nil,
+	"delivery_branch_code":              nil,
+	"delivery_address_id":               nil,
+}
+
+// trackedColumns are compared between the latest event and the booking
+// without replay since compaction never touches them
+var trackedColumns = []string{"status", "current_bag_id", "barcode"}
+
+// Issue is a booking whose current row cannot be reproduced from its events.
+// Only field names are reported so the result never carries applicant PII.
+type Issue struct {
+	BookingID    uint     `json:"booking_id"`
+	AppOrOrderID string   `json:"app_or_order_id"`
+	EventID      uint     `json:"event_id,omitempty"`
+	Problem      string   `json:"problem"`
+	Fields       []string `json:"fields,omitempty"`
+}
+
+// CheckpointEvery keeps every Nth event of a booking as a full snapshot
+// (BOOKING_EVENT_CHECKPOINT_EVERY, default 20)
+func CheckpointEvery() int {
+	return envInt("BOOKING_EVENT_CHECKPOINT_EVERY", defaultCheckpointEvery)
+}
+
+// CompactAfter is how old an event must be before it is compacted
+// (BOOKING_EVENT_COMPACT_AFTER_DAYS, default 30)
+func CompactAfter() time.Duration {
+	return time.Duration(envInt("BOOKING_EVENT_COMPACT_AFTER_DAYS", defaultCompactAfterDays)) * 24 * time.Hour
+}
+
+// IntegrityLimit caps how many bookings one scheduled integrity run checks
+// (BOOKING_EVENT_INTEGRITY_LIMIT, default 5000)
+func IntegrityLimit() int {
+	return envInt("BOOKING_EVENT_INTEGRITY_LIMIT", defaultIntegrityLimit)
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// loadEvents returns a booking's events oldest first as column maps
+func loadEvents(db *gorm.DB, appOrOrderID string) ([]map[string]interface{}, error) {
+	columns := append([]string{"id", "created_at", "compacted", "diff"}, trackedColumns...)
+	columns = append(columns, payloadColumns...)
+
+	var rows []map[string]interface{}
+	err := db.Table("booking_events").Select(columns).
+		Where("app_or_order_id = ?", appOrOrderID).Order("id").Find(&rows).Error
+	return rows, err
+}
+
+// replay rebuilds the payload of every event: full rows stand on their own,
+// compacted rows apply their Diff to the state before them. On failure it
+// returns the ID of the event the chain breaks at.
+func replay(rows []map[string]interface{}) ([]map[string]interface{}, uint, error) {
+	states := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		if !isCompacted(row) {
+			state := make(map[string]interface{}, len(payloadColumns))
+			for _, column := range payloadColumns {
+				state[column] = row[column]
+			}
+			states[i] = state
+			continue
+		}
+
+		if i == 0 {
+			return nil, rowID(row), errors.New("first event is compacted")
+		}
+		diff, err := decodeDiff(row["diff"])
+		if err != nil {
+			return nil, rowID(row), err
+		}
+		states[i] = apply(states[i-1], diff)
+	}
+	return states, 0, nil
+}
+
+func apply(state, diff map[string]interface{}) map[string]interface{} {
+	next := make(map[string]interface{}, len(state))
+	for column, value := range state {
+		next[column] = value
+	}
+	for column, value := range diff {
+		next[column] = value
+	}
+	return next
+}
+
+func decodeDiff(raw interface{}) (map[string]interface{}, error) {
+	var text string
+	switch v := raw.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return nil, errors.New("compacted event has no diff")
+	}
+	diff := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(text), &diff); err != nil {
+		return nil, fmt.Errorf("invalid diff: %w", err)
+	}
+	return diff, nil
+}
+
+// changedColumns lists the payload columns whose values differ between a and b
+func changedColumns(a, b map[string]interface{}, columns []string) []string {
+	var changed []string
+	for _, column := range columns {
+		if normalize(a[column]) != normalize(b[column]) {
+			changed = append(changed, column)
+		}
+	}
+	return changed
+}
+
+// normalize makes values comparable after a JSON round trip, where integers
+// come back as float64
+func normalize(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "\x00"
+	case []byte:
+		return string(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+func isCompacted(row map[string]interface{}) bool {
+	compacted, _ := row["compacted"].(bool)
+	return compacted
+}
+
+func rowID(row map[string]interface{}) uint {
+	switch id := row["id"].(type) {
+	case int64:
+		return uint(id)
+	case int32:
+		return uint(id)
+	case uint:
+		return id
+	}
+	return 0
+}
+
+// Compact replaces the snapshots of a booking's events older than cutoff with
+// diffs against the previous event. The first and latest events and every
+// CheckpointEvery-th event stay full so readers never replay far. A diff is
+// only written after it has been checked to replay to the original snapshot.
+func Compact(db *gorm.DB, appOrOrderID string, cutoff time.Time) (int, error) {
+	every := CheckpointEvery()
+	compacted := 0
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		rows, err := loadEvents(tx, appOrOrderID)
+		if err != nil {
+			return err
+		}
+		if len(rows) < 3 {
+			return nil
+		}
+		states, eventID, err := replay(rows)
+		if err != nil {
+			return fmt.Errorf("event %d: %w", eventID, err)
+		}
+
+		for i := 1; i < len(rows)-1; i++ {
+			row := rows[i]
+			createdAt, _ := row["created_at"].(time.Time)
+			if isCompacted(row) || i%every == 0 || !createdAt.Before(cutoff) {
+				continue
+			}
+
+			diff := map[string]interface{}{}
+			for _, column := range changedColumns(states[i-1], states[i], payloadColumns) {
+				diff[column] = states[i][column]
+			}
+			encoded, err := json.Marshal(diff)
+			if err != nil {
+				return err
+			}
+			decoded, err := decodeDiff(encoded)
+			if err != nil {
+				return err
+			}
+			if lost := changedColumns(apply(states[i-1], decoded), states[i], payloadColumns); len(lost) > 0 {
+				return fmt.Errorf("event %d: diff does not replay %v", rowID(row), lost)
+			}
+
+			updates := map[string]interface{}{"compacted": true, "diff": string(encoded)}
+			for column, blank := range blankPayload {
+				updates[column] = blank
+			}
+			// Table updates leave updated_at alone; on events it is the snapshot time
+			result := tx.Table("booking_events").
+				Where("id = ? AND compacted = ?", rowID(row), false).Updates(updates)
+			if result.Error != nil {
+				return result.Error
+			}
+			compacted += int(result.RowsAffected)
+		}
+		return nil
+	})
+	return compacted, err
+}
+
+// RunCompaction compacts events older than CompactAfter across all bookings and
+// returns how many bookings and events were compacted. A booking that fails is
+// logged and skipped.
+func RunCompaction(db *gorm.DB) (int, int, error) {
+	cutoff := time.Now().Add(-CompactAfter())
+	bookings, events := 0, 0
+	cursor := ""
+
+	for {
+		var ids []string
+		// Bookings whose first event is the only full old row have nothing to compact
+		if err := db.Table("booking_events").
+			Where("compacted = ? AND created_at < ? AND app_or_order_id > ?", false, cutoff, cursor).
+			Group("app_or_order_id").Having("COUNT(*) > 1").
+			Order("app_or_order_id").Limit(maintenanceBatchSize).
+			Pluck("app_or_order_id", &ids).Error; err != nil {
+			return bookings, events, err
+		}
+
+		for _, id := range ids {
+			n, err := Compact(db, id, cutoff)
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to compact events of booking %s", id), err)
+				continue
+			}
+			if n > 0 {
+				bookings++
+				events += n
+			}
+		}
+
+		if len(ids) < maintenanceBatchSize {
+			return bookings, events, nil
+		}
+		cursor = ids[len(ids)-1]
+	}
+}
+
+// checkBooking replays the events of one booking row and compares the result
+// with the row. It returns nil when they agree.
+func checkBooking(db *gorm.DB, booking map[string]interface{}) (*Issue, error) {
+	appOrOrderID, _ := booking["app_or_order_id"].(string)
+	issue := &Issue{BookingID: rowID(booking), AppOrOrderID: appOrOrderID}
+
+	rows, err := loadEvents(db, appOrOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		issue.Problem = ProblemNoEvents
+		return issue, nil
+	}
+	states, eventID, err := replay(rows)
+	if err != nil {
+		issue.Problem = ProblemBrokenChain
+		issue.EventID = eventID
+		return issue, nil
+	}
+
+	latest := rows[len(rows)-1]
+	fields := changedColumns(states[len(states)-1], booking, payloadColumns)
+	fields = append(fields, changedColumns(latest, booking, trackedColumns)...)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	issue.Problem = ProblemStateMismatch
+	issue.EventID = rowID(latest)
+	issue.Fields = fields
+	return issue, nil
+}
+
+func bookingColumns() []string {
+	columns := append([]string{"id", "app_or_order_id"}, trackedColumns...)
+	return append(columns, payloadColumns...)
+}
+
+// CheckBooking verifies that a booking's current state can be replayed from
+// its events. It returns nil when it can.
+func CheckBooking(db *gorm.DB, bookingID uint) (*Issue, error) {
+	var bookings []map[string]interface{}
+	if err := db.Table("bookings").Select(bookingColumns()).
+		Where("id = ?", bookingID).Limit(1).Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	if len(bookings) == 0 {
+		return nil, ErrBookingNotFound
+	}
+	return checkBooking(db, bookings[0])
+}
+
+// CheckIntegrity checks up to limit bookings changed since the given time,
+// skipping anonymized ones, and returns the issues found with the number checked
+func CheckIntegrity(db *gorm.DB, since time.Time, limit int) ([]Issue, int, error) {
+	var issues []Issue
+	checked := 0
+	var cursor int64
+
+	for checked < limit {
+		batch := maintenanceBatchSize
+		if limit-checked < batch {
+			batch = limit - checked
+		}
+		var bookings []map[string]interface{}
+		if err := db.Table("bookings").Select(bookingColumns()).
+			Where("updated_at >= ? AND anonymized_at IS NULL AND id > ?", since, cursor).
+			Order("id").Limit(batch).Find(&bookings).Error; err != nil {
+			return issues, checked, err
+		}
+
+		for _, booking := range bookings {
+			issue, err := checkBooking(db, booking)
+			if err != nil {
+				return issues, checked, err
+			}
+			checked++
+			if issue != nil {
+				issues = append(issues, *issue)
+			}
+		}
+
+		if len(bookings) < batch {
+			break
+		}
+		cursor = int64(rowID(bookings[len(bookings)-1]))
+	}
+	return issues, checked, nil
+}
+
+// StartScheduler compacts old events and then checks bookings changed since the
+// previous run, every BOOKING_EVENT_MAINTENANCE_INTERVAL_HOURS (default 24).
+// The returned function stops the job.
+func StartScheduler(db *gorm.DB) func() {
+	interval := time.Duration(envInt("BOOKING_EVENT_MAINTENANCE_INTERVAL_HOURS", defaultMaintenanceHours)) * time.Hour
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	run := func() {
+		bookings, events, err := RunCompaction(db)
+		if err != nil {
+			logger.Error("Booking event compaction run failed", err)
+		}
+		if events > 0 {
+			logger.Info(fmt.Sprintf("Compacted %d events across %d bookings", events, bookings))
+		}
+
+		issues, checked, err := CheckIntegrity(db, time.Now().Add(-interval), IntegrityLimit())
+		if err != nil {
+			logger.Error("Booking event integrity check failed", err)
+			return
+		}
+		for i, issue := range issues {
+			if i == maxLoggedIntegrityIssues {
+				break
+			}
+			logger.Warning(fmt.Sprintf("Booking %d (event %d) failed the event integrity check: %s %v",
+				issue.BookingID, issue.EventID, issue.Problem, issue.Fields))
+		}
+		if len(issues) > 0 {
+			logger.Warning(fmt.Sprintf("Event integrity check found %d of %d bookings not replayable from their events", len(issues), checked))
+		}
+	}
+
+	go func() {
+		run()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -18,6 +18,7 @@ func SnapshotBookingToEvent(tx *gorm.DB, b *bookingModel.Booking, eventType stri
 		UserID:       b.UserID,
 		User:         b.User, // optional; gorm will set by ID
 		AppOrOrderID: b.AppOrOrderID,
+		Reference:    b.Reference,
 		CurrentBagID: b.CurrentBagID,
 		Barcode:      b.Barcode,
 		Name:         b.Name,
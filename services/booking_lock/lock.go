@@ -0,0 +1,96 @@
+package booking_lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultTTL is how long a lock is held without activity; BOOKING_LOCK_TTL_MINUTES overrides it
+const DefaultTTL = 15 * time.Minute
+
+// LockedError is returned when another user holds an active lock on the booking
+type LockedError struct {
+	BookingID uint
+	HolderID  uint
+	Purpose   bookingModel.LockPurpose
+	ExpiresAt time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("booking %d is locked for %s until %s", e.BookingID, e.Purpose, e.ExpiresAt.Format(time.RFC3339))
+}
+
+// TTL returns the configured lock lifetime
+func TTL() time.Duration {
+	if v := os.Getenv("BOOKING_LOCK_TTL_MINUTES"); v != "" {
+		if minutes, err := strconv.Atoi(v); err == nil && minutes > 0 {
+			return time.Duration(minutes) * time.Minute
+		}
+	}
+	return DefaultTTL
+}
+
+// Acquire takes or refreshes the lock for holderID. It fails with *LockedError when
+// a different user holds an unexpired lock.
+func Acquire(db *gorm.DB, bookingID, holderID uint, purpose bookingModel.LockPurpose) (*bookingModel.BookingLock, error) {
+	now := time.Now()
+	lock := bookingModel.BookingLock{
+		BookingID: bookingID,
+		HolderID:  holderID,
+		Purpose:   purpose,
+		ExpiresAt: now.Add(TTL()),
+	}
+
+	// Take over the row only when it is ours already or has expired
+	result := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "booking_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"holder_id", "purpose", "expires_at", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "booking_locks.holder_id = excluded.holder_id OR booking_locks.expires_at < ?", Vars: []interface{}{now}},
+		}},
+	}).Create(&lock)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if err := Check(db, bookingID, holderID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to acquire lock for booking %d", bookingID)
+	}
+	return &lock, nil
+}
+
+// Check returns *LockedError if someone other than actorID holds an active lock
+func Check(db *gorm.DB, bookingID, actorID uint) error {
+	var lock bookingModel.BookingLock
+	err := db.Where("booking_id = ? AND expires_at > ?", bookingID, time.Now()).First(&lock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if lock.HolderID == actorID {
+		return nil
+	}
+	return &LockedError{
+		BookingID: lock.BookingID,
+		HolderID:  lock.HolderID,
+		Purpose:   lock.Purpose,
+		ExpiresAt: lock.ExpiresAt,
+	}
+}
+
+// Release drops the lock if it is held by holderID
+func Release(db *gorm.DB, bookingID, holderID uint) error {
+	return db.Where("booking_id = ? AND holder_id = ?", bookingID, holderID).
+		Delete(&bookingModel.BookingLock{}).Error
+}
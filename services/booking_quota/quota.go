@@ -0,0 +1,238 @@
+// Package booking_quota caps how many bookings an operator, and the operators of a
+// branch together, create per local day. A compromised account generating bogus
+// bookings runs into its quota long before it floods DMS.
+package booking_quota
+
+import (
+	"fmt"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/localtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultUserDaily   = 100
+	defaultBranchDaily = 0 // no branch quota unless configured
+)
+
+// DefaultLimit is the daily quota of a scope without its own row:
+// BOOKING_QUOTA_USER_DAILY (default 100) or BOOKING_QUOTA_BRANCH_DAILY (default
+// none). Zero means unlimited.
+func DefaultLimit(scope bookingModel.QuotaScope) int {
+	if scope == bookingModel.QuotaScopeBranch {
+		return envInt("BOOKING_QUOTA_BRANCH_DAILY", defaultBranchDaily)
+	}
+	return envInt("BOOKING_QUOTA_USER_DAILY", defaultUserDaily)
+}
+
+// Enforced reports whether a reached quota blocks creation. With
+// BOOKING_QUOTA_ENFORCE=false quotas are only reported and logged.
+func Enforced() bool {
+	enforce, err := strconv.ParseBool(os.Getenv("BOOKING_QUOTA_ENFORCE"))
+	return err != nil || enforce
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// Usage is how much of one quota has been used today
+type Usage struct {
+	Scope      bookingModel.QuotaScope `json:"scope"`
+	Subject    string                  `json:"subject"`
+	Limit      int                     `json:"limit"`
+	Used       int64                   `json:"used"`
+	Remaining  int64                   `json:"remaining"`
+	Overridden bool                    `json:"overridden"`
+	ResetAt    time.Time               `json:"reset_at"`
+}
+
+// Exceeded reports whether no booking is left under the quota. A zero limit is unlimited.
+func (u *Usage) Exceeded() bool {
+	return u.Limit > 0 && u.Remaining <= 0
+}
+
+// ExceededError is returned when a quota leaves no room for another booking
+type ExceededError struct {
+	Usage Usage
+}
+
+func (e *ExceededError) Error() string {
+	if e.Usage.Scope == bookingModel.QuotaScopeBranch {
+		return fmt.Sprintf("branch %s reached its daily booking quota (%d); it resets at %s",
+			e.Usage.Subject, e.Usage.Limit, localtime.Format(e.Usage.ResetAt))
+	}
+	return fmt.Sprintf("daily booking quota reached (%d); it resets at %s",
+		e.Usage.Limit, localtime.Format(e.Usage.ResetAt))
+}
+
+func quotaKey(scope bookingModel.QuotaScope, subject string) string {
+	return string(scope) + ":" + subject
+}
+
+// limitOf returns the limit in effect for a subject and whether an override set it
+func limitOf(quotas map[string]*bookingModel.BookingQuota, scope bookingModel.QuotaScope, subject string, now time.Time) (int, bool) {
+	if q, ok := quotas[quotaKey(scope, subject)]; ok {
+		if q.OverrideActive(now) {
+			return *q.OverrideLimit, true
+		}
+		if q.DailyLimit != nil {
+			return *q.DailyLimit, false
+		}
+	}
+	return DefaultLimit(scope), false
+}
+
+// Check returns today's usage of every quota that applies to a user creating a
+// booking, tightest first.
+func Check(db *gorm.DB, userID uint, branchCodes []string, now time.Time) ([]Usage, error) {
+	return check(db, userID, branchCodes, now, false)
+}
+
+// check counts today's usage of the quotas of a user and their branches. With lock
+// set it first takes a transaction-scoped lock on each limited quota, user first and
+// branches in code order, so concurrent creations under one quota count in turn.
+func check(db *gorm.DB, userID uint, branchCodes []string, now time.Time, lock bool) ([]Usage, error) {
+	start := localtime.StartOfDay(now)
+	reset := start.AddDate(0, 0, 1)
+	userSubject := strconv.FormatUint(uint64(userID), 10)
+
+	var rows []bookingModel.BookingQuota
+	query := db.Where("scope = ? AND subject = ?", bookingModel.QuotaScopeUser, userSubject)
+	if len(branchCodes) > 0 {
+		query = query.Or("scope = ? AND subject IN ?", bookingModel.QuotaScopeBranch, branchCodes)
+	}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load booking quotas: %w", err)
+	}
+	quotas := make(map[string]*bookingModel.BookingQuota, len(rows))
+	for i := range rows {
+		quotas[quotaKey(rows[i].Scope, rows[i].Subject)] = &rows[i]
+	}
+
+	var usages []Usage
+	subjects := append([]subject{{bookingModel.QuotaScopeUser, userSubject}}, branchSubjects(branchCodes)...)
+	for _, sub := range subjects {
+		limit, overridden := limitOf(quotas, sub.scope, sub.code, now)
+		if limit <= 0 {
+			continue
+		}
+		if lock {
+			if err := db.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "booking_quota:"+quotaKey(sub.scope, sub.code)).Error; err != nil {
+				return nil, fmt.Errorf("failed to lock booking quota of %s %s: %w", sub.scope, sub.code, err)
+			}
+		}
+		used, err := countToday(db, sub.scope, sub.code, start)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, newUsage(sub.scope, sub.code, limit, used, overridden, reset))
+	}
+
+	// Tightest first, so the caller can report and enforce usages[0]
+	sort.SliceStable(usages, func(i, j int) bool {
+		return usages[i].Remaining < usages[j].Remaining
+	})
+	return usages, nil
+}
+
+type subject struct {
+	scope bookingModel.QuotaScope
+	code  string
+}
+
+// branchSubjects returns the branch quotas of codes in code order
+func branchSubjects(codes []string) []subject {
+	sorted := append([]string(nil), codes...)
+	sort.Strings(sorted)
+	subjects := make([]subject, 0, len(sorted))
+	for _, code := range sorted {
+		subjects = append(subjects, subject{bookingModel.QuotaScopeBranch, code})
+	}
+	return subjects
+}
+
+// countToday counts the bookings a user, or the users mapped to a branch, created
+// since start. Deleted bookings count too, so deleting bogus bookings frees nothing.
+func countToday(db *gorm.DB, scope bookingModel.QuotaScope, code string, start time.Time) (int64, error) {
+	query := db.Table("bookings").Where("created_at >= ?", start)
+	if scope == bookingModel.QuotaScopeBranch {
+		query = query.Where("user_id IN (?)", db.Table("user_branches").Select("user_id").Where("branch_code = ?", code))
+	} else {
+		userID, err := strconv.ParseUint(code, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid user quota subject %q", code)
+		}
+		query = query.Where("user_id = ?", userID)
+	}
+	var used int64
+	if err := query.Count(&used).Error; err != nil {
+		return 0, fmt.Errorf("failed to count today's bookings of %s %s: %w", scope, code, err)
+	}
+	return used, nil
+}
+
+// UsageOf returns today's usage of one quota, with a zero limit when it is unlimited
+func UsageOf(db *gorm.DB, scope bookingModel.QuotaScope, code string, now time.Time) (*Usage, error) {
+	start := localtime.StartOfDay(now)
+	var rows []bookingModel.BookingQuota
+	if err := db.Where("scope = ? AND subject = ?", scope, code).Limit(1).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load booking quota: %w", err)
+	}
+	quotas := make(map[string]*bookingModel.BookingQuota, len(rows))
+	for i := range rows {
+		quotas[quotaKey(rows[i].Scope, rows[i].Subject)] = &rows[i]
+	}
+	limit, overridden := limitOf(quotas, scope, code, now)
+	used, err := countToday(db, scope, code, start)
+	if err != nil {
+		return nil, err
+	}
+	usage := newUsage(scope, code, limit, used, overridden, start.AddDate(0, 0, 1))
+	return &usage, nil
+}
+
+func newUsage(scope bookingModel.QuotaScope, subject string, limit int, used int64, overridden bool, reset time.Time) Usage {
+	remaining := int64(limit) - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Usage{
+		Scope:      scope,
+		Subject:    subject,
+		Limit:      limit,
+		Used:       used,
+		Remaining:  remaining,
+		Overridden: overridden,
+		ResetAt:    reset,
+	}
+}
+
+// Reserve checks the quotas before a booking is created. It returns the tightest
+// usage, nil when no quota applies, and an *ExceededError when that quota is used
+// up and quotas are enforced.
+//
+// tx must be the transaction that creates the booking: the quotas stay locked until
+// it ends, so a concurrent creation only counts once this booking is committed or
+// rolled back and the quota cannot be overshot.
+func Reserve(tx *gorm.DB, userID uint, branchCodes []string, now time.Time) (*Usage, error) {
+	usages, err := check(tx, userID, branchCodes, now, true)
+	if err != nil || len(usages) == 0 {
+		return nil, err
+	}
+	tightest := usages[0]
+	if tightest.Exceeded() && Enforced() {
+		return &tightest, &ExceededError{Usage: tightest}
+	}
+	return &tightest, nil
+}
@@ -0,0 +1,68 @@
+package booking_reference
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	bookingModel "passport-booking/models/booking"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPrefix = "PBK"
+	defaultWidth  = 6
+)
+
+var prefixPattern = regexp.MustCompile(`^[A-Z0-9]{1,10}$`)
+
+// Prefix is the leading part of every reference; BOOKING_REFERENCE_PREFIX overrides it
+func Prefix() string {
+	if v := strings.ToUpper(strings.TrimSpace(os.Getenv("BOOKING_REFERENCE_PREFIX"))); prefixPattern.MatchString(v) {
+		return v
+	}
+	return defaultPrefix
+}
+
+// Width is the minimum number of digits of the yearly counter; BOOKING_REFERENCE_WIDTH
+// overrides it. Counters past the width simply grow longer.
+func Width() int {
+	if v := os.Getenv("BOOKING_REFERENCE_WIDTH"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 3 && n <= 12 {
+			return n
+		}
+	}
+	return defaultWidth
+}
+
+// Next issues the next reference for the year of t, e.g. PBK-2026-000123. Call it
+// inside the transaction that creates the booking so an aborted booking does not
+// consume a number.
+func Next(tx *gorm.DB, t time.Time) (string, error) {
+	seq := bookingModel.ReferenceSequence{Prefix: Prefix(), Year: t.Year()}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+		return "", err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("prefix = ? AND year = ?", seq.Prefix, seq.Year).First(&seq).Error; err != nil {
+		return "", err
+	}
+
+	seq.LastValue++
+	if err := tx.Model(&seq).Where("prefix = ? AND year = ?", seq.Prefix, seq.Year).
+		Update("last_value", seq.LastValue).Error; err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%d-%0*d", seq.Prefix, seq.Year, Width(), seq.LastValue), nil
+}
+
+// Normalize tidies a reference typed by an applicant or operator for lookup
+func Normalize(reference string) string {
+	return strings.ToUpper(strings.TrimSpace(reference))
+}
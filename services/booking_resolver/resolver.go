@@ -0,0 +1,99 @@
+package booking_resolver
+
+import (
+	"fmt"
+	"strconv"
+
+	"passport-booking/database"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_reference"
+	bookingTypes "passport-booking/types/booking"
+
+	"gorm.io/gorm"
+)
+
+// Find loads the booking matching identifier into dest. The db handle may
+// already carry preloads. A missing or soft-deleted booking is reported as
+// gorm.ErrRecordNotFound.
+func Find(db *gorm.DB, identifier string, idType bookingTypes.IdentifierType, dest *bookingModel.Booking) error {
+	if identifier == "" {
+		return fmt.Errorf("booking identifier is required")
+	}
+
+	switch idType {
+	case bookingTypes.IdentifierTypeID:
+		id, err := strconv.ParseUint(identifier, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid booking id: %s", identifier)
+		}
+		return first(db, "id = ?", uint(id), dest)
+	case bookingTypes.IdentifierTypeBarcode:
+		return first(db, "barcode = ?", identifier, dest)
+	case bookingTypes.IdentifierTypeAppOrOrderID:
+		return first(db, "app_or_order_id = ?", identifier, dest)
+	case bookingTypes.IdentifierTypeReference:
+		return first(db, "reference = ?", booking_reference.Normalize(identifier), dest)
+	case bookingTypes.IdentifierTypeAuto, "":
+		return findAuto(db, identifier, dest)
+	}
+
+	return fmt.Errorf("unsupported identifier type: %s", idType)
+}
+
+// findAuto tries the numeric ID first, then barcode, then AppOrOrderID, then reference
+func findAuto(db *gorm.DB, identifier string, dest *bookingModel.Booking) error {
+	if id, err := strconv.ParseUint(identifier, 10, 32); err == nil {
+		if err := first(db, "id = ?", uint(id), dest); err != gorm.ErrRecordNotFound {
+			return err
+		}
+	}
+
+	if err := first(db, "barcode = ?", identifier, dest); err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	if err := first(db, "app_or_order_id = ?", identifier, dest); err != gorm.ErrRecordNotFound {
+		return err
+	}
+
+	return first(db, "reference = ?", booking_reference.Normalize(identifier), dest)
+}
+
+func first(db *gorm.DB, query string, value interface{}, dest *bookingModel.Booking) error {
+	return db.Session(&gorm.Session{}).Scopes(database.NotDeletedBookings).Where(query, value).First(dest).Error
+}
+
+// FindMany loads every booking matching one of identifiers. Only explicit
+// identifier types are supported; unmatched and soft-deleted identifiers are
+// simply absent.
+func FindMany(db *gorm.DB, identifiers []string, idType bookingTypes.IdentifierType, dest *[]bookingModel.Booking) error {
+	if len(identifiers) == 0 {
+		return nil
+	}
+	db = db.Scopes(database.NotDeletedBookings)
+
+	switch idType {
+	case bookingTypes.IdentifierTypeID:
+		ids := make([]uint, 0, len(identifiers))
+		for _, identifier := range identifiers {
+			id, err := strconv.ParseUint(identifier, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid booking id: %s", identifier)
+			}
+			ids = append(ids, uint(id))
+		}
+		return db.Where("id IN ?", ids).Find(dest).Error
+	case bookingTypes.IdentifierTypeBarcode:
+		return db.Where("barcode IN ?", identifiers).Find(dest).Error
+	case bookingTypes.IdentifierTypeAppOrOrderID:
+		return db.Where("app_or_order_id IN ?", identifiers).Find(dest).Error
+	case bookingTypes.IdentifierTypeReference:
+		references := make([]string, 0, len(identifiers))
+		for _, identifier := range identifiers {
+			references = append(references, booking_reference.Normalize(identifier))
+		}
+		return db.Where("reference IN ?", references).Find(dest).Error
+	}
+
+	return fmt.Errorf("unsupported identifier type for batch lookup: %s", idType)
+}
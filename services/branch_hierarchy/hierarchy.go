@@ -0,0 +1,204 @@
+package branch_hierarchy
+
+import (
+	"errors"
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnitNotFound is returned when no unit has the id or code
+	ErrUnitNotFound = errors.New("org unit not found")
+	// ErrHasChildren is returned when deleting a unit that still has units under it
+	ErrHasChildren = errors.New("org unit still has units under it")
+	// ErrCodeTaken is returned when another unit already uses the code
+	ErrCodeTaken = errors.New("org unit code is already in use")
+)
+
+// ParentError is returned when a unit is placed under a parent of the wrong level
+type ParentError struct {
+	Level bookingModel.OrgLevel
+}
+
+func (e *ParentError) Error() string {
+	if parent := e.Level.ParentLevel(); parent != "" {
+		return fmt.Sprintf("a %s must be placed under a %s", e.Level, parent)
+	}
+	return fmt.Sprintf("a %s cannot have a parent", e.Level)
+}
+
+// Node is a unit with the units under it
+type Node struct {
+	bookingModel.OrgUnit
+	Children []*Node `json:"children,omitempty"`
+}
+
+// Tree is the whole hierarchy loaded in memory; it is small enough (a few thousand
+// branches) that walking it in Go beats recursive queries
+type Tree struct {
+	byID   map[uint]*Node
+	byCode map[string]*Node
+	Roots  []*Node
+}
+
+// Load reads every unit and links them into a tree
+func Load(db *gorm.DB) (*Tree, error) {
+	var units []bookingModel.OrgUnit
+	if err := db.Order("level, code").Find(&units).Error; err != nil {
+		return nil, err
+	}
+
+	t := &Tree{byID: make(map[uint]*Node, len(units)), byCode: make(map[string]*Node, len(units))}
+	for _, u := range units {
+		n := &Node{OrgUnit: u}
+		t.byID[u.ID] = n
+		t.byCode[u.Code] = n
+	}
+	for _, u := range units {
+		n := t.byID[u.ID]
+		if u.ParentID == nil {
+			t.Roots = append(t.Roots, n)
+			continue
+		}
+		if parent, ok := t.byID[*u.ParentID]; ok {
+			parent.Children = append(parent.Children, n)
+		}
+	}
+	return t, nil
+}
+
+// Find returns the unit with code
+func (t *Tree) Find(code string) (*Node, bool) {
+	n, ok := t.byCode[code]
+	return n, ok
+}
+
+// BranchCodes expands codes of any level to the branch codes under them. Codes the
+// tree does not know are kept as branch codes, so mappings made before the tree was
+// filled in keep working.
+func (t *Tree) BranchCodes(codes []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Level == bookingModel.OrgLevelBranch && !seen[n.Code] {
+			seen[n.Code] = true
+			out = append(out, n.Code)
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	for _, code := range codes {
+		if n, ok := t.byCode[code]; ok {
+			walk(n)
+		} else if !seen[code] {
+			seen[code] = true
+			out = append(out, code)
+		}
+	}
+	return out
+}
+
+// AncestorAt returns the unit at level above (or at) the branch with code
+func (t *Tree) AncestorAt(branchCode string, level bookingModel.OrgLevel) (*Node, bool) {
+	n, ok := t.byCode[branchCode]
+	for ok {
+		if n.Level == level {
+			return n, true
+		}
+		if n.ParentID == nil {
+			return nil, false
+		}
+		n, ok = t.byID[*n.ParentID]
+	}
+	return nil, false
+}
+
+// checkParent enforces region → district → branch
+func checkParent(db *gorm.DB, level bookingModel.OrgLevel, parentID *uint) error {
+	want := level.ParentLevel()
+	if parentID == nil {
+		if want != "" {
+			return &ParentError{Level: level}
+		}
+		return nil
+	}
+	if want == "" {
+		return &ParentError{Level: level}
+	}
+	var parent bookingModel.OrgUnit
+	if err := db.First(&parent, *parentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnitNotFound
+		}
+		return err
+	}
+	if parent.Level != want {
+		return &ParentError{Level: level}
+	}
+	return nil
+}
+
+// Create adds a unit under its parent
+func Create(db *gorm.DB, unit *bookingModel.OrgUnit) error {
+	unit.Code = strings.TrimSpace(unit.Code)
+	if err := checkParent(db, unit.Level, unit.ParentID); err != nil {
+		return err
+	}
+	var taken int64
+	if err := db.Model(&bookingModel.OrgUnit{}).Where("code = ?", unit.Code).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrCodeTaken
+	}
+	return db.Create(unit).Error
+}
+
+// Update renames a unit or moves it under another parent of the same level. Code
+// and level are fixed once created.
+func Update(db *gorm.DB, id uint, name string, parentID *uint, updatedByID uint) (*bookingModel.OrgUnit, error) {
+	var unit bookingModel.OrgUnit
+	if err := db.First(&unit, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnitNotFound
+		}
+		return nil, err
+	}
+	if err := checkParent(db, unit.Level, parentID); err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&unit).Updates(map[string]interface{}{
+		"name":          name,
+		"parent_id":     parentID,
+		"updated_by_id": updatedByID,
+	}).Error; err != nil {
+		return nil, err
+	}
+	unit.Name, unit.ParentID, unit.UpdatedByID = name, parentID, updatedByID
+	return &unit, nil
+}
+
+// Delete removes a unit that has nothing under it
+func Delete(db *gorm.DB, id uint) error {
+	var children int64
+	if err := db.Model(&bookingModel.OrgUnit{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+		return err
+	}
+	if children > 0 {
+		return ErrHasChildren
+	}
+	result := db.Delete(&bookingModel.OrgUnit{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUnitNotFound
+	}
+	return nil
+}
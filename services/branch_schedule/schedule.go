@@ -0,0 +1,114 @@
+package branch_schedule
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/localtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultWorkingDays is Sunday to Thursday
+const DefaultWorkingDays = "0,1,2,3,4"
+
+// Location is the zone branch hours are kept in; BRANCH_TIMEZONE overrides the
+// user-facing zone
+func Location() *time.Location {
+	if name := os.Getenv("BRANCH_TIMEZONE"); name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return localtime.Zone()
+}
+
+// ParseClock parses an HH:MM time of day into minutes after midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid HH:MM time", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseWorkingDays parses a comma-separated weekday list, 0 = Sunday
+func ParseWorkingDays(value string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || n > 6 {
+			return nil, fmt.Errorf("working day %q must be a number from 0 (Sunday) to 6", part)
+		}
+		days[time.Weekday(n)] = true
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("at least one working day is required")
+	}
+	return days, nil
+}
+
+// Find returns the schedule of branchCode, or nil when none is configured
+func Find(db *gorm.DB, branchCode string) (*bookingModel.BranchSchedule, error) {
+	var schedule bookingModel.BranchSchedule
+	err := db.Where("branch_code = ?", branchCode).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// isWorkingDay reports whether the branch works on the day of t
+func isWorkingDay(s *bookingModel.BranchSchedule, t time.Time) bool {
+	days, err := ParseWorkingDays(s.WorkingDays)
+	if err != nil {
+		days, _ = ParseWorkingDays(DefaultWorkingDays)
+	}
+	return days[t.Weekday()]
+}
+
+// cutoffOn returns the cutoff instant on the day of t
+func cutoffOn(s *bookingModel.BranchSchedule, t time.Time) time.Time {
+	minutes, err := ParseClock(s.CutoffAt)
+	if err != nil {
+		minutes = 24 * 60
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location()).Add(time.Duration(minutes) * time.Minute)
+}
+
+// AfterCutoff reports whether at is past the branch's cutoff for same-day dispatch,
+// which includes any time on a non-working day
+func AfterCutoff(s *bookingModel.BranchSchedule, at time.Time) bool {
+	at = at.In(Location())
+	return !isWorkingDay(s, at) || at.After(cutoffOn(s, at))
+}
+
+// NextDispatchDate is the working day an item bagged at `at` leaves the branch: the
+// same day before the cutoff, otherwise the next working day
+func NextDispatchDate(s *bookingModel.BranchSchedule, at time.Time) time.Time {
+	at = at.In(Location())
+	y, m, d := at.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, at.Location())
+	if !AfterCutoff(s, at) {
+		return day
+	}
+	for i := 0; i < 7; i++ {
+		day = day.AddDate(0, 0, 1)
+		if isWorkingDay(s, day) {
+			return day
+		}
+	}
+	return day
+}
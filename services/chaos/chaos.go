@@ -0,0 +1,185 @@
+// Package chaos injects faults in staging so the DMS outbox, the circuit breaker
+// and the retry paths can be exercised under controlled failure: random request
+// latency, 5xx answers from DMS and database timeouts. It is off unless
+// CHAOS_ENABLED is set and never runs under the prod profile.
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"passport-booking/config"
+	"passport-booking/logger"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLatencyMinMs   = 200
+	defaultLatencyMaxMs   = 2000
+	defaultDMSErrorStatus = 503
+	defaultDBTimeoutMs    = 1000
+
+	maxDelayMs = 60000
+)
+
+// ErrNotAllowed is returned when fault injection is switched on under the prod profile
+var ErrNotAllowed = errors.New("fault injection is not allowed in production")
+
+// Settings are the faults injected and how often, as percentages of requests or calls
+type Settings struct {
+	Enabled bool `json:"enabled"`
+
+	LatencyPercent float64 `json:"latency_percent"`
+	LatencyMinMs   int     `json:"latency_min_ms"`
+	LatencyMaxMs   int     `json:"latency_max_ms"`
+
+	DMSErrorPercent float64 `json:"dms_error_percent"`
+	DMSErrorStatus  int     `json:"dms_error_status"`
+
+	DBTimeoutPercent float64 `json:"db_timeout_percent"`
+	DBTimeoutMs      int     `json:"db_timeout_ms"` // how long a failing statement hangs first
+}
+
+// Validate checks the percentages, delays and status
+func (s Settings) Validate() error {
+	for name, p := range map[string]float64{
+		"latency_percent":    s.LatencyPercent,
+		"dms_error_percent":  s.DMSErrorPercent,
+		"db_timeout_percent": s.DBTimeoutPercent,
+	} {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", name)
+		}
+	}
+	if s.LatencyMinMs < 0 || s.LatencyMaxMs < s.LatencyMinMs || s.LatencyMaxMs > maxDelayMs {
+		return fmt.Errorf("latency_min_ms and latency_max_ms must satisfy 0 <= min <= max <= %d", maxDelayMs)
+	}
+	if s.DBTimeoutMs < 0 || s.DBTimeoutMs > maxDelayMs {
+		return fmt.Errorf("db_timeout_ms must be between 0 and %d", maxDelayMs)
+	}
+	if s.DMSErrorStatus < 500 || s.DMSErrorStatus > 599 {
+		return fmt.Errorf("dms_error_status must be a 5xx status")
+	}
+	return nil
+}
+
+var (
+	mu       sync.RWMutex
+	current  Settings
+	loadOnce sync.Once
+)
+
+// Allowed reports whether the profile permits fault injection
+func Allowed() bool {
+	return config.CurrentProfile().Profile != config.ProfileProd
+}
+
+// fromEnv reads CHAOS_ENABLED, CHAOS_LATENCY_PERCENT, CHAOS_LATENCY_MIN_MS,
+// CHAOS_LATENCY_MAX_MS, CHAOS_DMS_ERROR_PERCENT, CHAOS_DMS_ERROR_STATUS,
+// CHAOS_DB_TIMEOUT_PERCENT and CHAOS_DB_TIMEOUT_MS
+func fromEnv() Settings {
+	enabled, _ := strconv.ParseBool(os.Getenv("CHAOS_ENABLED"))
+	s := Settings{
+		Enabled:          enabled,
+		LatencyPercent:   envPercent("CHAOS_LATENCY_PERCENT"),
+		LatencyMinMs:     envInt("CHAOS_LATENCY_MIN_MS", defaultLatencyMinMs),
+		LatencyMaxMs:     envInt("CHAOS_LATENCY_MAX_MS", defaultLatencyMaxMs),
+		DMSErrorPercent:  envPercent("CHAOS_DMS_ERROR_PERCENT"),
+		DMSErrorStatus:   envInt("CHAOS_DMS_ERROR_STATUS", defaultDMSErrorStatus),
+		DBTimeoutPercent: envPercent("CHAOS_DB_TIMEOUT_PERCENT"),
+		DBTimeoutMs:      envInt("CHAOS_DB_TIMEOUT_MS", defaultDBTimeoutMs),
+	}
+	if err := s.Validate(); err != nil {
+		logger.Warning(fmt.Sprintf("Fault injection is disabled, invalid settings: %v", err))
+		s.Enabled = false
+	}
+	if s.Enabled && !Allowed() {
+		logger.Warning("CHAOS_ENABLED is ignored under the prod profile")
+		s.Enabled = false
+	}
+	if s.Enabled {
+		logger.Warning(fmt.Sprintf("Fault injection is enabled: latency %.1f%%, DMS errors %.1f%%, DB timeouts %.1f%%",
+			s.LatencyPercent, s.DMSErrorPercent, s.DBTimeoutPercent))
+	}
+	return s
+}
+
+// Current returns the active settings
+func Current() Settings {
+	loadOnce.Do(func() {
+		s := fromEnv()
+		mu.Lock()
+		current = s
+		mu.Unlock()
+	})
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Update replaces the settings until the next restart, which reads the environment
+// again. Enabling is refused under the prod profile.
+func Update(s Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if s.Enabled && !Allowed() {
+		return ErrNotAllowed
+	}
+	Current()
+	mu.Lock()
+	defer mu.Unlock()
+	current = s
+	return nil
+}
+
+// roll reports whether a fault with the given chance, in percent, happens now
+func roll(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+// Latency returns how long to hold the current request, zero when no latency is injected
+func Latency() time.Duration {
+	s := Current()
+	if !s.Enabled || !roll(s.LatencyPercent) {
+		return 0
+	}
+	ms := s.LatencyMinMs
+	if s.LatencyMaxMs > s.LatencyMinMs {
+		ms += rand.Intn(s.LatencyMaxMs - s.LatencyMinMs + 1)
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// DBTimeout reports whether the database statements of the current request should time out
+func DBTimeout() bool {
+	s := Current()
+	return s.Enabled && roll(s.DBTimeoutPercent)
+}
+
+// dmsError returns the status of an injected DMS failure, zero when the call goes through
+func dmsError() int {
+	s := Current()
+	if !s.Enabled || !roll(s.DMSErrorPercent) {
+		return 0
+	}
+	return s.DMSErrorStatus
+}
+
+func envPercent(key string) float64 {
+	f, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return fallback
+}
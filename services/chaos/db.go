@@ -0,0 +1,60 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrDBTimeout is the error an injected database timeout fails a statement with
+var ErrDBTimeout = fmt.Errorf("chaos: injected database timeout: %w", context.DeadlineExceeded)
+
+type dbTimeoutKey struct{}
+
+// WithDBTimeout marks a context so every statement run on it times out
+func WithDBTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dbTimeoutKey{}, true)
+}
+
+func dbTimeoutFrom(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	marked, _ := ctx.Value(dbTimeoutKey{}).(bool)
+	return marked
+}
+
+// RegisterCallbacks fails statements run on a marked context before they reach the
+// database, after hanging for the configured timeout. Only handlers that pass the
+// request context to GORM are affected; background jobs never are.
+func RegisterCallbacks(db *gorm.DB) error {
+	timeout := func(tx *gorm.DB) {
+		if tx.Error != nil || !dbTimeoutFrom(tx.Statement.Context) {
+			return
+		}
+		select {
+		case <-time.After(time.Duration(Current().DBTimeoutMs) * time.Millisecond):
+		case <-tx.Statement.Context.Done():
+		}
+		tx.AddError(ErrDBTimeout)
+	}
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("chaos:query", timeout); err != nil {
+		return err
+	}
+	if err := cb.Create().Before("gorm:create").Register("chaos:create", timeout); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("chaos:update", timeout); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("chaos:delete", timeout); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("chaos:row", timeout); err != nil {
+		return err
+	}
+	return cb.Raw().Before("gorm:raw").Register("chaos:raw", timeout)
+}
@@ -0,0 +1,66 @@
+package consent
+
+import (
+	"errors"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTextVersion is the consent wording version used when PHONE_CONSENT_TEXT_VERSION is unset
+const DefaultTextVersion = "v1"
+
+// ErrConsentRequired is returned when the booking's delivery phone has no recorded consent
+var ErrConsentRequired = errors.New("delivery phone consent is required")
+
+// TextVersion returns the version of the consent wording currently shown to applicants
+func TextVersion() string {
+	if v := os.Getenv("PHONE_CONSENT_TEXT_VERSION"); v != "" {
+		return v
+	}
+	return DefaultTextVersion
+}
+
+// Record stores consent for the booking's current delivery phone
+func Record(db *gorm.DB, booking *bookingModel.Booking, channel bookingModel.ConsentChannel, textVersion, recordedBy, ipAddress, userAgent string) (*bookingModel.PhoneConsent, error) {
+	if booking.DeliveryPhone == nil || *booking.DeliveryPhone == "" {
+		return nil, errors.New("booking has no delivery phone")
+	}
+
+	record := bookingModel.PhoneConsent{
+		BookingID:   booking.ID,
+		Phone:       *booking.DeliveryPhone,
+		Channel:     channel,
+		TextVersion: textVersion,
+		RecordedBy:  recordedBy,
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+		ConsentedAt: time.Now(),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Active returns the consent covering the booking's current delivery phone, or
+// ErrConsentRequired when the phone was never consented to (or has since changed)
+func Active(db *gorm.DB, booking *bookingModel.Booking) (*bookingModel.PhoneConsent, error) {
+	if booking.DeliveryPhone == nil || *booking.DeliveryPhone == "" {
+		return nil, ErrConsentRequired
+	}
+
+	var record bookingModel.PhoneConsent
+	err := db.Where("booking_id = ? AND phone = ?", booking.ID, *booking.DeliveryPhone).
+		Order("consented_at DESC").
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrConsentRequired
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
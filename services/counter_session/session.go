@@ -0,0 +1,142 @@
+package counter_session
+
+import (
+	"errors"
+	"math"
+	"os"
+	parcelModel "passport-booking/models/parcel_booking"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAlreadyOpen is returned when the operator already has an open session
+	ErrAlreadyOpen = errors.New("operator already has an open counter session")
+	// ErrNoOpenSession is returned when the operator has no open session
+	ErrNoOpenSession = errors.New("operator has no open counter session")
+)
+
+// Required reports whether parcel bookings may only be submitted inside an open
+// session, COUNTER_SESSION_REQUIRED=true
+func Required() bool {
+	return strings.EqualFold(os.Getenv("COUNTER_SESSION_REQUIRED"), "true")
+}
+
+// feeStatuses are the parcel statuses whose fee has been taken at the counter
+var feeStatuses = []string{
+	string(parcelModel.ParcelBookingStatusBooked),
+	string(parcelModel.ParcelBookingStatusReceived),
+	string(parcelModel.ParcelBookingStatusDelivered),
+}
+
+// Current returns the operator's open session, or ErrNoOpenSession
+func Current(db *gorm.DB, operatorID uint) (*parcelModel.CounterSession, error) {
+	var session parcelModel.CounterSession
+	err := db.Where("operator_id = ? AND status = ?", operatorID, parcelModel.CounterSessionOpen).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoOpenSession
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Open starts a session for the operator at branchCode with the cash float in the drawer
+func Open(db *gorm.DB, operatorID uint, branchCode string, openingFloat float64) (*parcelModel.CounterSession, error) {
+	if _, err := Current(db, operatorID); err == nil {
+		return nil, ErrAlreadyOpen
+	} else if !errors.Is(err, ErrNoOpenSession) {
+		return nil, err
+	}
+
+	session := parcelModel.CounterSession{
+		OperatorID:   operatorID,
+		BranchCode:   branchCode,
+		Status:       parcelModel.CounterSessionOpen,
+		OpeningFloat: openingFloat,
+		OpenedAt:     time.Now(),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		// The partial unique index catches a concurrent open
+		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "idx_counter_sessions_open_operator") {
+			return nil, ErrAlreadyOpen
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Totals is what the bookings linked to a session collected
+type Totals struct {
+	BookingCount  int
+	CollectedFees float64
+}
+
+// TotalsOf sums the fees of the bookings submitted in the session
+func TotalsOf(db *gorm.DB, sessionID uint) (Totals, error) {
+	var row struct {
+		Count int
+		Fees  float64
+	}
+	err := db.Model(&parcelModel.ParcelBooking{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(total_charge), 0) AS fees").
+		Where("counter_session_id = ? AND current_status IN ?", sessionID, feeStatuses).
+		Scan(&row).Error
+	return Totals{BookingCount: row.Count, CollectedFees: round2(row.Fees)}, err
+}
+
+// Close ends the operator's open session, recording the cash counted in the drawer and
+// how far it is from the float plus collected fees
+func Close(db *gorm.DB, operatorID uint, declaredCash float64, note string) (*parcelModel.CounterSession, error) {
+	var closed *parcelModel.CounterSession
+	err := db.Transaction(func(tx *gorm.DB) error {
+		session, err := Current(tx.Clauses(clause.Locking{Strength: "UPDATE"}), operatorID)
+		if err != nil {
+			return err
+		}
+		totals, err := TotalsOf(tx, session.ID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		expected := round2(session.OpeningFloat + totals.CollectedFees)
+		variance := round2(declaredCash - expected)
+		session.Status = parcelModel.CounterSessionClosed
+		session.ClosedAt = &now
+		session.BookingCount = totals.BookingCount
+		session.CollectedFees = totals.CollectedFees
+		session.ExpectedCash = expected
+		session.DeclaredCash = &declaredCash
+		session.Variance = &variance
+		if note != "" {
+			session.CloseNote = &note
+		}
+		if err := tx.Save(session).Error; err != nil {
+			return err
+		}
+		closed = session
+		return nil
+	})
+	return closed, err
+}
+
+// Attach links a parcel booking to the operator's open session. Without one it
+// returns ErrNoOpenSession, which callers ignore unless sessions are Required.
+func Attach(db *gorm.DB, parcelBookingID, operatorID uint) error {
+	session, err := Current(db, operatorID)
+	if err != nil {
+		return err
+	}
+	return db.Model(&parcelModel.ParcelBooking{}).
+		Where("id = ?", parcelBookingID).
+		Update("counter_session_id", session.ID).Error
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
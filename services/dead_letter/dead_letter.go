@@ -0,0 +1,567 @@
+// Package dead_letter lets administrators browse the items that failed for good in
+// the DMS outbox, the notification log and the DMS callback log, and replay or
+// discard them without editing the tables by hand.
+package dead_letter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	bookingModel "passport-booking/models/booking"
+	notificationModel "passport-booking/models/notification"
+	"passport-booking/services/dms_callback"
+	"passport-booking/services/dms_outbox"
+	"passport-booking/services/notification"
+	integrationTypes "passport-booking/types/integration"
+	"passport-booking/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Queue names a queue whose failures can be browsed
+type Queue string
+
+const (
+	// QueueOutbox holds DMS outbox entries that were rejected or ran out of attempts
+	QueueOutbox Queue = "outbox"
+	// QueueNotification holds SMS and email notifications whose latest attempt failed
+	QueueNotification Queue = "notification"
+	// QueueWebhook holds DMS callbacks that found no status mapping or no booking
+	QueueWebhook Queue = "webhook"
+
+	defaultListLimit = 50
+	maxListLimit     = 200
+	// maxHistory bounds how far back a notification's resend chain is followed
+	maxHistory = 50
+)
+
+var (
+	ErrUnknownQueue     = errors.New("unknown dead-letter queue")
+	ErrNotFound         = errors.New("item not found")
+	ErrNotDeadLettered  = errors.New("item is not dead-lettered")
+	ErrEditNotSupported = errors.New("this field cannot be edited on items of this queue")
+	ErrReasonRequired   = errors.New("a reason is required to discard an item")
+)
+
+// InvalidEditError reports an edited payload or recipient that cannot be replayed
+type InvalidEditError struct {
+	Err error
+}
+
+func (e *InvalidEditError) Error() string { return "invalid edit: " + e.Err.Error() }
+
+func (e *InvalidEditError) Unwrap() error { return e.Err }
+
+// Queues lists the browsable queues
+func Queues() []Queue {
+	return []Queue{QueueOutbox, QueueNotification, QueueWebhook}
+}
+
+// IsValid reports whether q is a known queue
+func (q Queue) IsValid() bool {
+	for _, known := range Queues() {
+		if q == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Item is one dead-lettered entry of a queue
+type Item struct {
+	Queue     Queue     `json:"queue"`
+	ID        uint      `json:"id"`
+	BookingID *uint     `json:"booking_id,omitempty"`
+	Kind      string    `json:"kind"` // outbox operation, notification kind and channel, or DMS status
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// HistoryEntry is one failed attempt of an item
+type HistoryEntry struct {
+	Attempt int       `json:"attempt"`
+	Error   string    `json:"error"`
+	At      time.Time `json:"at"`
+}
+
+// Detail is an item with its payload, error history and earlier administrator actions
+type Detail struct {
+	Item
+	DeadLettered bool                            `json:"dead_lettered"`
+	Payload      json.RawMessage                 `json:"payload,omitempty"`
+	Record       interface{}                     `json:"record"`
+	History      []HistoryEntry                  `json:"history"`
+	Actions      []bookingModel.DeadLetterAction `json:"actions"`
+}
+
+// Edit changes an item before it is replayed. Payload applies to outbox entries
+// and webhooks, Recipient to notifications.
+type Edit struct {
+	Payload   json.RawMessage
+	Recipient string
+}
+
+func (e Edit) empty() bool {
+	return len(e.Payload) == 0 && e.Recipient == ""
+}
+
+// Actor is the administrator acting on an item
+type Actor struct {
+	UUID   string
+	UserID uint
+}
+
+// discarded matches rows of table that an administrator discarded
+func discarded(queue Queue, table string) string {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM dead_letter_actions d WHERE d.queue = '%s' AND d.item_id = %s.id AND d.action = '%s')",
+		queue, table, bookingModel.DeadLetterDiscard)
+}
+
+// superseded matches notification log entries that have a later resend attempt
+const superseded = "EXISTS (SELECT 1 FROM notification_logs r WHERE r.resend_of_id = notification_logs.id)"
+
+var deadCallbackOutcomes = []bookingModel.DMSCallbackOutcome{bookingModel.DMSCallbackUnmapped, bookingModel.DMSCallbackNotFound, bookingModel.DMSCallbackIllegal}
+
+func deadOutbox(db *gorm.DB) *gorm.DB {
+	return db.Model(&bookingModel.DMSOutboxEntry{}).Where("status = ?", bookingModel.DMSOutboxFailed)
+}
+
+func deadNotifications(db *gorm.DB) *gorm.DB {
+	return db.Model(&notificationModel.NotificationLog{}).
+		Where("status = ?", notificationModel.DeliveryStatusFailed).
+		Where("NOT " + superseded).
+		Where("NOT " + discarded(QueueNotification, "notification_logs"))
+}
+
+func deadCallbacks(db *gorm.DB) *gorm.DB {
+	return db.Model(&bookingModel.DMSCallback{}).
+		Where("outcome IN ?", deadCallbackOutcomes).
+		Where("NOT " + discarded(QueueWebhook, "dms_callbacks"))
+}
+
+// Counts returns how many items are dead-lettered in each queue
+func Counts(db *gorm.DB) (map[Queue]int64, error) {
+	counts := map[Queue]int64{}
+	for queue, query := range map[Queue]*gorm.DB{
+		QueueOutbox:       deadOutbox(db),
+		QueueNotification: deadNotifications(db),
+		QueueWebhook:      deadCallbacks(db),
+	} {
+		var n int64
+		if err := query.Count(&n).Error; err != nil {
+			return nil, err
+		}
+		counts[queue] = n
+	}
+	return counts, nil
+}
+
+// List returns the dead-lettered items of queue, newest first. With beforeID only
+// items older than it are returned, for paging.
+func List(db *gorm.DB, queue Queue, beforeID uint, limit int) ([]Item, error) {
+	if limit <= 0 || limit > maxListLimit {
+		limit = defaultListLimit
+	}
+	page := func(query *gorm.DB) *gorm.DB {
+		if beforeID > 0 {
+			query = query.Where("id < ?", beforeID)
+		}
+		return query.Order("id DESC").Limit(limit)
+	}
+
+	var items []Item
+	switch queue {
+	case QueueOutbox:
+		var entries []bookingModel.DMSOutboxEntry
+		if err := page(deadOutbox(db)).Find(&entries).Error; err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			items = append(items, outboxItem(&entries[i]))
+		}
+	case QueueNotification:
+		var logs []notificationModel.NotificationLog
+		if err := page(deadNotifications(db)).Find(&logs).Error; err != nil {
+			return nil, err
+		}
+		for i := range logs {
+			items = append(items, notificationItem(&logs[i]))
+		}
+	case QueueWebhook:
+		var callbacks []bookingModel.DMSCallback
+		if err := page(deadCallbacks(db)).Find(&callbacks).Error; err != nil {
+			return nil, err
+		}
+		for i := range callbacks {
+			items = append(items, webhookItem(&callbacks[i]))
+		}
+	default:
+		return nil, ErrUnknownQueue
+	}
+	return items, nil
+}
+
+func outboxItem(e *bookingModel.DMSOutboxEntry) Item {
+	item := Item{
+		Queue:     QueueOutbox,
+		ID:        e.ID,
+		BookingID: &e.BookingID,
+		Kind:      string(e.Operation),
+		Attempts:  e.Attempts,
+		FailedAt:  e.UpdatedAt,
+	}
+	if e.LastError != nil {
+		item.Error = *e.LastError
+	}
+	return item
+}
+
+func notificationItem(l *notificationModel.NotificationLog) Item {
+	return Item{
+		Queue:     QueueNotification,
+		ID:        l.ID,
+		BookingID: l.BookingID,
+		Kind:      l.Kind + "/" + string(l.Channel),
+		Attempts:  1,
+		Error:     l.Error,
+		FailedAt:  l.CreatedAt,
+	}
+}
+
+func webhookItem(cb *bookingModel.DMSCallback) Item {
+	return Item{
+		Queue:     QueueWebhook,
+		ID:        cb.ID,
+		BookingID: cb.BookingID,
+		Kind:      cb.DMSStatus,
+		Attempts:  1,
+		Error:     callbackError(cb),
+		FailedAt:  cb.CreatedAt,
+	}
+}
+
+// callbackError describes why a callback was not applied
+func callbackError(cb *bookingModel.DMSCallback) string {
+	switch cb.Outcome {
+	case bookingModel.DMSCallbackUnmapped:
+		return fmt.Sprintf("no active mapping for DMS status %s", cb.DMSStatus)
+	case bookingModel.DMSCallbackNotFound:
+		return fmt.Sprintf("no booking with barcode %s", cb.Barcode)
+	case bookingModel.DMSCallbackIllegal:
+		return fmt.Sprintf("DMS status %s maps to a status the booking cannot move to", cb.DMSStatus)
+	}
+	return ""
+}
+
+// Get returns an item of queue with its payload, error history and the actions
+// taken on it. Items that are no longer dead-lettered are returned too, flagged.
+func Get(db *gorm.DB, queue Queue, id uint) (*Detail, error) {
+	if !queue.IsValid() {
+		return nil, ErrUnknownQueue
+	}
+	detail := Detail{}
+	switch queue {
+	case QueueOutbox:
+		var entry bookingModel.DMSOutboxEntry
+		if err := first(db, &entry, id); err != nil {
+			return nil, err
+		}
+		attempts, err := dms_outbox.Attempts(db, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range attempts {
+			detail.History = append(detail.History, HistoryEntry{Attempt: a.Attempt, Error: a.Error, At: a.CreatedAt})
+		}
+		// Entries that failed before attempts were recorded only have their last error
+		if len(detail.History) == 0 && entry.LastError != nil {
+			detail.History = append(detail.History, HistoryEntry{Attempt: entry.Attempts, Error: *entry.LastError, At: entry.UpdatedAt})
+		}
+		detail.Item = outboxItem(&entry)
+		detail.DeadLettered = entry.Status == bookingModel.DMSOutboxFailed
+		detail.Payload = rawJSON(entry.Payload)
+		detail.Record = entry
+	case QueueNotification:
+		var log notificationModel.NotificationLog
+		if err := first(db, &log, id); err != nil {
+			return nil, err
+		}
+		history, err := resendChain(db, &log)
+		if err != nil {
+			return nil, err
+		}
+		dead, err := exists(deadNotifications(db).Where("id = ?", id))
+		if err != nil {
+			return nil, err
+		}
+		detail.Item = notificationItem(&log)
+		detail.Item.Attempts = len(history)
+		detail.History = history
+		detail.DeadLettered = dead
+		detail.Payload = rawJSON(log.Payload)
+		detail.Record = log
+	case QueueWebhook:
+		var callback bookingModel.DMSCallback
+		if err := first(db, &callback, id); err != nil {
+			return nil, err
+		}
+		dead, err := exists(deadCallbacks(db).Where("id = ?", id))
+		if err != nil {
+			return nil, err
+		}
+		detail.Item = webhookItem(&callback)
+		if detail.Item.Error != "" {
+			detail.History = []HistoryEntry{{Attempt: 1, Error: detail.Item.Error, At: callback.CreatedAt}}
+		}
+		detail.DeadLettered = dead
+		detail.Payload = rawJSON(callback.Payload)
+		detail.Record = callback
+	}
+
+	if err := db.Where("queue = ? AND item_id = ?", queue, id).Order("id").Find(&detail.Actions).Error; err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// resendChain returns the failed attempts that led up to log, oldest first
+func resendChain(db *gorm.DB, log *notificationModel.NotificationLog) ([]HistoryEntry, error) {
+	chain := []notificationModel.NotificationLog{*log}
+	for cur := log; cur.ResendOfID != nil && len(chain) < maxHistory; {
+		var prev notificationModel.NotificationLog
+		if err := db.First(&prev, *cur.ResendOfID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, err
+		}
+		chain = append(chain, prev)
+		cur = &chain[len(chain)-1]
+	}
+
+	history := make([]HistoryEntry, 0, len(chain))
+	for i := len(chain) - 1; i >= 0; i-- {
+		history = append(history, HistoryEntry{Attempt: len(history) + 1, Error: chain[i].Error, At: chain[i].CreatedAt})
+	}
+	return history, nil
+}
+
+// Replay sends an item again, after applying edit. Outbox entries go back in the
+// queue for the next drain; notifications and webhooks are replayed at once. The
+// replay is recorded whatever its outcome, and the updated record is returned.
+func Replay(db *gorm.DB, queue Queue, id uint, edit Edit, reason string, actor Actor) (interface{}, error) {
+	if !queue.IsValid() {
+		return nil, ErrUnknownQueue
+	}
+	action := bookingModel.DeadLetterAction{
+		Queue:   string(queue),
+		ItemID:  id,
+		Action:  bookingModel.DeadLetterReplay,
+		Reason:  strings.TrimSpace(reason),
+		Edited:  !edit.empty(),
+		ActorID: actor.UUID,
+	}
+
+	var result interface{}
+	switch queue {
+	case QueueOutbox:
+		if edit.Recipient != "" {
+			return nil, ErrEditNotSupported
+		}
+		payload := ""
+		if len(edit.Payload) > 0 {
+			if err := validObject(edit.Payload); err != nil {
+				return nil, err
+			}
+			payload = string(edit.Payload)
+		}
+		var before bookingModel.DMSOutboxEntry
+		if err := first(db, &before, id); err != nil {
+			return nil, err
+		}
+		entry, err := dms_outbox.RetryWithPayload(db, id, payload)
+		if errors.Is(err, dms_outbox.ErrNotFailed) {
+			return nil, ErrNotDeadLettered
+		}
+		if err != nil {
+			return nil, err
+		}
+		if action.Edited {
+			action.PayloadBefore = before.Payload
+		}
+		result = entry
+	case QueueNotification:
+		if len(edit.Payload) > 0 {
+			return nil, ErrEditNotSupported
+		}
+		var original notificationModel.NotificationLog
+		if err := first(db, &original, id); err != nil {
+			return nil, err
+		}
+		if dead, err := exists(deadNotifications(db).Where("id = ?", id)); err != nil {
+			return nil, err
+		} else if !dead {
+			return nil, ErrNotDeadLettered
+		}
+		recipient := strings.TrimSpace(edit.Recipient)
+		if recipient != "" {
+			if err := validRecipient(original.Channel, recipient); err != nil {
+				return nil, err
+			}
+			action.PayloadBefore = original.Recipient
+		}
+		attempt, err := notification.ResendLogTo(db, id, actor.UserID, recipient)
+		switch {
+		case errors.Is(err, notification.ErrNotFailed), errors.Is(err, notification.ErrAlreadyResent):
+			return nil, ErrNotDeadLettered
+		case attempt == nil:
+			return nil, err
+		}
+		// A provider failure still records the attempt, which becomes the dead letter
+		if attempt.Status == notificationModel.DeliveryStatusFailed {
+			action.Error = attempt.Error
+		}
+		result = attempt
+	case QueueWebhook:
+		if edit.Recipient != "" {
+			return nil, ErrEditNotSupported
+		}
+		var before bookingModel.DMSCallback
+		if err := first(db, &before, id); err != nil {
+			return nil, err
+		}
+		if dead, err := exists(deadCallbacks(db).Where("id = ?", id)); err != nil {
+			return nil, err
+		} else if !dead {
+			return nil, ErrNotDeadLettered
+		}
+		var payload *integrationTypes.DMSCallbackPayload
+		if len(edit.Payload) > 0 {
+			payload = &integrationTypes.DMSCallbackPayload{}
+			if err := json.Unmarshal(edit.Payload, payload); err != nil {
+				return nil, &InvalidEditError{Err: err}
+			}
+			if err := payload.Validate(); err != nil {
+				return nil, &InvalidEditError{Err: err}
+			}
+			action.PayloadBefore = before.Payload
+		}
+		callback, err := dms_callback.Reprocess(db, id, payload)
+		if err != nil {
+			return nil, err
+		}
+		action.Error = callbackError(callback)
+		result = callback
+	}
+
+	if err := db.Create(&action).Error; err != nil {
+		return result, fmt.Errorf("replayed but failed to record the action: %w", err)
+	}
+	return result, nil
+}
+
+// Discard drops a dead-lettered item for good, recording who did it and why
+func Discard(db *gorm.DB, queue Queue, id uint, reason string, actor Actor) (*bookingModel.DeadLetterAction, error) {
+	if !queue.IsValid() {
+		return nil, ErrUnknownQueue
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	switch queue {
+	case QueueOutbox:
+		_, err := dms_outbox.Discard(db, id)
+		switch {
+		case errors.Is(err, dms_outbox.ErrNotFound):
+			return nil, ErrNotFound
+		case errors.Is(err, dms_outbox.ErrNotFailed):
+			return nil, ErrNotDeadLettered
+		case err != nil:
+			return nil, err
+		}
+	case QueueNotification, QueueWebhook:
+		var record interface{} = &notificationModel.NotificationLog{}
+		query := deadNotifications(db)
+		if queue == QueueWebhook {
+			record, query = &bookingModel.DMSCallback{}, deadCallbacks(db)
+		}
+		if err := first(db, record, id); err != nil {
+			return nil, err
+		}
+		if dead, err := exists(query.Where("id = ?", id)); err != nil {
+			return nil, err
+		} else if !dead {
+			return nil, ErrNotDeadLettered
+		}
+	}
+
+	action := bookingModel.DeadLetterAction{
+		Queue:   string(queue),
+		ItemID:  id,
+		Action:  bookingModel.DeadLetterDiscard,
+		Reason:  reason,
+		ActorID: actor.UUID,
+	}
+	if err := db.Create(&action).Error; err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+func first(db *gorm.DB, dest interface{}, id uint) error {
+	if err := db.First(dest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func exists(query *gorm.DB) (bool, error) {
+	var n int64
+	err := query.Limit(1).Count(&n).Error
+	return n > 0, err
+}
+
+// rawJSON returns s as raw JSON, or as a JSON string when it is not valid JSON
+func rawJSON(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	quoted, _ := json.Marshal(s)
+	return quoted
+}
+
+// validObject checks an edited outbox payload is a JSON object
+func validObject(payload json.RawMessage) error {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(payload, &obj); err != nil {
+		return &InvalidEditError{Err: fmt.Errorf("payload must be a JSON object")}
+	}
+	return nil
+}
+
+// validRecipient checks an edited recipient suits the channel of the notification
+func validRecipient(channel notificationModel.Channel, recipient string) error {
+	switch channel {
+	case notificationModel.ChannelSMS:
+		if err := utils.ValidatePhone(recipient); err != nil {
+			return &InvalidEditError{Err: fmt.Errorf("recipient %w", err)}
+		}
+	case notificationModel.ChannelEmail:
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return &InvalidEditError{Err: fmt.Errorf("recipient must be a valid email address")}
+		}
+	}
+	return nil
+}
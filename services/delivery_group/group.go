@@ -0,0 +1,211 @@
+package delivery_group
+
+import (
+	"errors"
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxMembers caps how many bookings one group can hold
+const MaxMembers = 10
+
+// ValidationError explains why bookings cannot be grouped
+type ValidationError struct {
+	BookingID uint
+	Reason    string
+}
+
+func (e *ValidationError) Error() string {
+	if e.BookingID == 0 {
+		return e.Reason
+	}
+	return fmt.Sprintf("booking %d: %s", e.BookingID, e.Reason)
+}
+
+// ErrGroupNotOpen is returned when a delivered or dissolved group is changed
+var ErrGroupNotOpen = errors.New("delivery group is not open")
+
+// Create groups bookings received by postmanID that go to the same recipient and address
+func Create(db *gorm.DB, postmanID uint, bookings []bookingModel.Booking) (*bookingModel.DeliveryGroup, error) {
+	if len(bookings) < 2 {
+		return nil, &ValidationError{Reason: "at least two bookings are required"}
+	}
+	if len(bookings) > MaxMembers {
+		return nil, &ValidationError{Reason: fmt.Sprintf("a group can hold at most %d bookings", MaxMembers)}
+	}
+
+	postmanIDStr := strconv.FormatUint(uint64(postmanID), 10)
+	first := bookings[0]
+	seen := make(map[uint]bool, len(bookings))
+	for _, b := range bookings {
+		if seen[b.ID] {
+			return nil, &ValidationError{BookingID: b.ID, Reason: "listed more than once"}
+		}
+		seen[b.ID] = true
+
+		if b.Status != bookingModel.BookingItemStatusReceivedByPostman || b.UpdatedBy != postmanIDStr {
+			return nil, &ValidationError{BookingID: b.ID, Reason: "must be received by you before grouping"}
+		}
+		if b.DeliveryGroupID != nil {
+			return nil, &ValidationError{BookingID: b.ID, Reason: "already belongs to a delivery group"}
+		}
+		if b.DeliveryPhone == nil || first.DeliveryPhone == nil || *b.DeliveryPhone != *first.DeliveryPhone {
+			return nil, &ValidationError{BookingID: b.ID, Reason: "delivery phone differs from the other bookings"}
+		}
+		if addressKey(b) != addressKey(first) {
+			return nil, &ValidationError{BookingID: b.ID, Reason: "delivery address differs from the other bookings"}
+		}
+	}
+
+	group := bookingModel.DeliveryGroup{
+		PostmanID:     postmanID,
+		DeliveryPhone: *first.DeliveryPhone,
+		Status:        bookingModel.DeliveryGroupStatusOpen,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		ids := make([]uint, 0, len(bookings))
+		for _, b := range bookings {
+			ids = append(ids, b.ID)
+		}
+		// Guard against a concurrent grouping of the same bookings
+		result := tx.Model(&bookingModel.Booking{}).
+			Where("id IN ? AND delivery_group_id IS NULL", ids).
+			Update("delivery_group_id", group.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(ids)) {
+			return &ValidationError{Reason: "some bookings were grouped by another request"}
+		}
+
+		for i := range bookings {
+			bookings[i].DeliveryGroupID = &group.ID
+			if err := booking_event.SnapshotBookingToEvent(tx, &bookings[i], "added_to_delivery_group", postmanIDStr); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// addressKey compares delivery addresses by post office and street, falling back
+// to the address printed on the slip when no delivery address was entered
+func addressKey(b bookingModel.Booking) string {
+	normalize := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return strings.Join(strings.Fields(strings.ToLower(*v)), " ")
+	}
+	if b.DeliveryAddress != nil {
+		return normalize(b.DeliveryAddress.PostOfficeCode) + "|" + normalize(b.DeliveryAddress.StreetAddress)
+	}
+	return "slip|" + normalize(&b.Address)
+}
+
+// Find loads a group with its bookings
+func Find(db *gorm.DB, groupID uint) (*bookingModel.DeliveryGroup, error) {
+	var group bookingModel.DeliveryGroup
+	if err := db.Preload("Bookings", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		First(&group, groupID).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// Propagate copies fields confirmed once for the group from source onto the other open
+// members and records eventType on each of them
+func Propagate(db *gorm.DB, source *bookingModel.Booking, fields map[string]interface{}, eventType, actor string) error {
+	if source.DeliveryGroupID == nil {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var group bookingModel.DeliveryGroup
+		if err := tx.First(&group, *source.DeliveryGroupID).Error; err != nil {
+			return err
+		}
+		if group.Status != bookingModel.DeliveryGroupStatusOpen {
+			return nil
+		}
+
+		var siblings []bookingModel.Booking
+		if err := tx.Where("delivery_group_id = ? AND id <> ? AND status <> ?", group.ID, source.ID, bookingModel.BookingStatusDelivered).
+			Find(&siblings).Error; err != nil {
+			return err
+		}
+
+		for i := range siblings {
+			if err := tx.Model(&siblings[i]).Updates(fields).Error; err != nil {
+				return err
+			}
+			if err := booking_event.SnapshotBookingToEvent(tx, &siblings[i], eventType, actor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RefreshStatus marks the group delivered once all of its bookings are delivered
+func RefreshStatus(db *gorm.DB, groupID uint) error {
+	var pending int64
+	if err := db.Model(&bookingModel.Booking{}).
+		Where("delivery_group_id = ? AND status <> ?", groupID, bookingModel.BookingStatusDelivered).
+		Count(&pending).Error; err != nil {
+		return err
+	}
+	if pending > 0 {
+		return nil
+	}
+
+	now := time.Now()
+	return db.Model(&bookingModel.DeliveryGroup{}).
+		Where("id = ? AND status = ?", groupID, bookingModel.DeliveryGroupStatusOpen).
+		Updates(map[string]interface{}{"status": bookingModel.DeliveryGroupStatusDelivered, "delivered_at": now}).Error
+}
+
+// Dissolve releases the bookings of an open group so they are delivered one by one
+func Dissolve(db *gorm.DB, groupID uint, actor string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var group bookingModel.DeliveryGroup
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&group, groupID).Error; err != nil {
+			return err
+		}
+		if group.Status != bookingModel.DeliveryGroupStatusOpen {
+			return ErrGroupNotOpen
+		}
+
+		var members []bookingModel.Booking
+		if err := tx.Where("delivery_group_id = ?", group.ID).Find(&members).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&bookingModel.Booking{}).Where("delivery_group_id = ? AND status <> ?", group.ID, bookingModel.BookingStatusDelivered).
+			Update("delivery_group_id", nil).Error; err != nil {
+			return err
+		}
+		for i := range members {
+			if members[i].Status == bookingModel.BookingStatusDelivered {
+				continue
+			}
+			if err := booking_event.SnapshotBookingToEvent(tx, &members[i], "removed_from_delivery_group", actor); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&group).Update("status", bookingModel.DeliveryGroupStatusDissolved).Error
+	})
+}
@@ -0,0 +1,176 @@
+package delivery_hold
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/clock"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultMaxDays = 30
+
+// ValidationError explains why a hold cannot be placed
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Reason
+}
+
+// ErrHoldNotActive is returned when a canceled or finished hold is canceled again
+var ErrHoldNotActive = errors.New("delivery hold is no longer active")
+
+// OnHoldError is returned by Check when a hold is in effect for the booking
+type OnHoldError struct {
+	Hold *bookingModel.DeliveryHold
+}
+
+func (e *OnHoldError) Error() string {
+	return fmt.Sprintf("booking is on hold until %s", e.Hold.EndsAt.Format(time.RFC3339))
+}
+
+// Service places, cancels and looks up delivery holds
+type Service struct {
+	DB *gorm.DB
+	// Clock decides whether a hold window has passed; nil means the system clock
+	Clock clock.Clock
+}
+
+// NewDeliveryHoldService creates a new delivery hold service
+func NewDeliveryHoldService(db *gorm.DB) *Service {
+	return &Service{DB: db}
+}
+
+// Now is the time hold windows are judged against
+func (s *Service) Now() time.Time {
+	return clock.OrSystem(s.Clock).Now()
+}
+
+// WithContext returns a copy of the service whose queries run with ctx
+func (s *Service) WithContext(ctx context.Context) *Service {
+	return &Service{DB: s.DB.WithContext(ctx), Clock: s.Clock}
+}
+
+// MaxDays is the longest hold an applicant can ask for; DELIVERY_HOLD_MAX_DAYS overrides it
+func MaxDays() int {
+	if v := os.Getenv("DELIVERY_HOLD_MAX_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultMaxDays
+}
+
+// Create places a hold on booking for [startsAt, endsAt)
+func (s *Service) Create(b *bookingModel.Booking, startsAt, endsAt time.Time, reason *string, actorID uint) (*bookingModel.DeliveryHold, error) {
+	if b.Status.IsCompleted() {
+		return nil, &ValidationError{Reason: fmt.Sprintf("booking is already %s", b.Status)}
+	}
+	if b.Status == bookingModel.BookingItemStatusReceivedByPostman {
+		return nil, &ValidationError{Reason: "booking is already out for delivery"}
+	}
+	if b.AnonymizedAt != nil {
+		return nil, &ValidationError{Reason: "booking has been erased"}
+	}
+	if !endsAt.After(startsAt) {
+		return nil, &ValidationError{Reason: "end date must be after start date"}
+	}
+	if !endsAt.After(s.Now()) {
+		return nil, &ValidationError{Reason: "hold window is already over"}
+	}
+	if endsAt.Sub(startsAt) > time.Duration(MaxDays())*24*time.Hour {
+		return nil, &ValidationError{Reason: fmt.Sprintf("a hold cannot be longer than %d days", MaxDays())}
+	}
+
+	hold := bookingModel.DeliveryHold{
+		BookingID:     b.ID,
+		StartsAt:      startsAt,
+		EndsAt:        endsAt,
+		Reason:        reason,
+		Status:        bookingModel.DeliveryHoldStatusActive,
+		RequestedByID: actorID,
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var overlapping int64
+		if err := tx.Model(&bookingModel.DeliveryHold{}).
+			Where("booking_id = ? AND status = ? AND starts_at < ? AND ends_at > ?", b.ID, bookingModel.DeliveryHoldStatusActive, endsAt, startsAt).
+			Count(&overlapping).Error; err != nil {
+			return err
+		}
+		if overlapping > 0 {
+			return &ValidationError{Reason: "booking already has a hold in this window"}
+		}
+		if err := tx.Create(&hold).Error; err != nil {
+			return err
+		}
+		return booking_event.SnapshotBookingToEvent(tx, b, "delivery_hold_created", strconv.FormatUint(uint64(actorID), 10))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// Cancel withdraws a hold. A hold that has already started ends now so the time it
+// was in effect still counts as held.
+func (s *Service) Cancel(hold *bookingModel.DeliveryHold, b *bookingModel.Booking, actorID uint) error {
+	now := s.Now()
+	if hold.Status != bookingModel.DeliveryHoldStatusActive || !hold.EndsAt.After(now) {
+		return ErrHoldNotActive
+	}
+
+	updates := map[string]interface{}{
+		"canceled_by_id": actorID,
+		"canceled_at":    now,
+	}
+	if hold.StartsAt.After(now) {
+		updates["status"] = bookingModel.DeliveryHoldStatusCanceled
+	} else {
+		updates["ends_at"] = now
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(hold).Updates(updates).Error; err != nil {
+			return err
+		}
+		return booking_event.SnapshotBookingToEvent(tx, b, "delivery_hold_canceled", strconv.FormatUint(uint64(actorID), 10))
+	})
+}
+
+// Check returns an *OnHoldError when a hold is in effect for the booking now. Every
+// step that moves the item towards the applicant calls it, so a hold that starts
+// after the postman picked the item up still stops the delivery.
+func (s *Service) Check(bookingID uint) error {
+	hold, err := s.Active(bookingID)
+	if err != nil {
+		return err
+	}
+	if hold != nil {
+		return &OnHoldError{Hold: hold}
+	}
+	return nil
+}
+
+// Active returns the hold in effect for the booking now, or nil
+func (s *Service) Active(bookingID uint) (*bookingModel.DeliveryHold, error) {
+	t := s.Now()
+	var hold bookingModel.DeliveryHold
+	err := s.DB.Where("booking_id = ? AND status = ? AND starts_at <= ? AND ends_at > ?", bookingID, bookingModel.DeliveryHoldStatusActive, t, t).
+		Order("ends_at DESC").
+		First(&hold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
@@ -0,0 +1,149 @@
+package delivery_repo
+
+import (
+	"context"
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/booking_lock"
+	"passport-booking/services/booking_resolver"
+	"passport-booking/services/delivery_group"
+	"passport-booking/services/delivery_hold"
+	"passport-booking/services/fraud"
+	bookingTypes "passport-booking/types/booking"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// Repository is the booking data the postman delivery handlers read and write
+type Repository interface {
+	// FindBooking loads the booking matching identifier, with its user, into dest. A
+	// missing booking is reported as gorm.ErrRecordNotFound.
+	FindBooking(identifier string, idType bookingTypes.IdentifierType, dest *bookingModel.Booking) error
+	// CheckLock returns a *booking_lock.LockedError when another user holds the booking
+	CheckLock(bookingID, actorID uint) error
+	// CheckHold returns a *delivery_hold.OnHoldError when a hold is in effect
+	CheckHold(bookingID uint) error
+	// EvaluateFraud runs the anti-fraud rules against a delivery attempt
+	EvaluateFraud(signals fraud.Signals) (fraud.Decision, error)
+	// ConfirmDeliveryPhone saves a booking whose delivery phone was just confirmed and
+	// records the confirmation, as eventType, for it and its delivery group
+	ConfirmDeliveryPhone(ctx context.Context, booking *bookingModel.Booking, eventType string, postmanID uint) error
+	// MarkDelivered saves a delivered booking, records its events, releases its lock
+	// and loads its delivery address for the proof of delivery. Only a failure to
+	// save the booking is returned.
+	MarkDelivered(ctx context.Context, booking *bookingModel.Booking, postmanID uint) error
+}
+
+// GormRepository is the Repository backed by the database
+type GormRepository struct {
+	DB    *gorm.DB
+	Holds *delivery_hold.Service
+}
+
+var _ Repository = (*GormRepository)(nil)
+
+// NewDeliveryRepository creates a new delivery repository
+func NewDeliveryRepository(db *gorm.DB) *GormRepository {
+	return &GormRepository{
+		DB:    db,
+		Holds: delivery_hold.NewDeliveryHoldService(db),
+	}
+}
+
+func (r *GormRepository) FindBooking(identifier string, idType bookingTypes.IdentifierType, dest *bookingModel.Booking) error {
+	return booking_resolver.Find(r.DB.Preload("User"), identifier, idType, dest)
+}
+
+func (r *GormRepository) CheckLock(bookingID, actorID uint) error {
+	return booking_lock.Check(r.DB, bookingID, actorID)
+}
+
+func (r *GormRepository) CheckHold(bookingID uint) error {
+	return r.Holds.Check(bookingID)
+}
+
+func (r *GormRepository) EvaluateFraud(signals fraud.Signals) (fraud.Decision, error) {
+	return fraud.Evaluate(r.DB, signals)
+}
+
+func (r *GormRepository) ConfirmDeliveryPhone(ctx context.Context, booking *bookingModel.Booking, eventType string, postmanID uint) error {
+	if err := r.DB.Save(booking).Error; err != nil {
+		return fmt.Errorf("failed to update delivery phone confirmation status: %w", err)
+	}
+
+	postmanIDStr := strconv.FormatUint(uint64(postmanID), 10)
+
+	// Create booking status event
+	bookingStatusEvent := bookingModel.BookingStatusEvent{
+		BookingID: booking.ID,
+		Status:    booking.Status,
+		CreatedBy: postmanIDStr,
+	}
+
+	if err := r.DB.WithContext(ctx).Create(&bookingStatusEvent).Error; err != nil {
+		return fmt.Errorf("failed to create booking status event: %w", err)
+	}
+
+	if err := booking_event.SnapshotBookingToEvent(r.DB.WithContext(ctx), booking, eventType, postmanIDStr); err != nil {
+		logger.Error(fmt.Sprintf("Failed to write booking event (%s)", eventType), err)
+	}
+
+	// Bookings grouped with this one share the recipient, so one OTP confirms all of them
+	if err := delivery_group.Propagate(r.DB.WithContext(ctx), booking, map[string]interface{}{
+		"delivery_phone_confirmed_verified":    true,
+		"delivery_phone_confirm_otp_encrypted": booking.DeliveryPhoneConfirmedOTPEncrypted,
+	}, eventType, postmanIDStr); err != nil {
+		logger.Error("Failed to propagate delivery phone confirmation to delivery group", err)
+	}
+
+	return nil
+}
+
+func (r *GormRepository) MarkDelivered(ctx context.Context, booking *bookingModel.Booking, postmanID uint) error {
+	postmanIDStr := strconv.FormatUint(uint64(postmanID), 10)
+
+	// Save the updated booking
+	if err := r.DB.Save(booking).Error; err != nil {
+		return err
+	}
+
+	// Create booking status event
+	bookingStatusEvent := bookingModel.BookingStatusEvent{
+		BookingID: booking.ID,
+		Status:    booking.Status,
+		CreatedBy: postmanIDStr,
+	}
+
+	if err := r.DB.WithContext(ctx).Create(&bookingStatusEvent).Error; err != nil {
+		logger.Error("Failed to create booking status event for delivery", err)
+		// Don't fail the request for this error
+	}
+
+	// Create booking event for delivery
+	if err := booking_event.SnapshotBookingToEvent(r.DB.WithContext(ctx), booking, "item_delivered", postmanIDStr); err != nil {
+		logger.Error("Failed to write booking event (item_delivered)", err)
+		// Don't fail the request for this error
+	}
+
+	// Delivery is complete, free the booking
+	if err := booking_lock.Release(r.DB, booking.ID, postmanID); err != nil {
+		logger.Error("Failed to release booking lock after delivery", err)
+	}
+
+	if booking.DeliveryGroupID != nil {
+		if err := delivery_group.RefreshStatus(r.DB, *booking.DeliveryGroupID); err != nil {
+			logger.Error("Failed to refresh delivery group status", err)
+		}
+	}
+
+	if booking.DeliveryAddress == nil && booking.DeliveryAddressID != nil {
+		if err := r.DB.Preload("DeliveryAddress").First(booking, booking.ID).Error; err != nil {
+			logger.Error("Failed to load delivery address for proof of delivery", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+// Package device_push sends push notifications to the postman mobile app and keeps
+// the registered device tokens.
+package device_push
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/httpServices/push"
+	"passport-booking/logger"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/preference"
+	"passport-booking/services/workerpool"
+	preferenceTypes "passport-booking/types/preference"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kind identifies the event a push is about. It is sent to the app as data["kind"].
+type Kind string
+
+const (
+	KindItemsAssigned      Kind = "items_assigned"
+	KindDeliveryEscalated  Kind = "delivery_escalated"
+	KindEscalationReviewed Kind = "escalation_reviewed"
+)
+
+// Notification is one push to every device of a user
+type Notification struct {
+	Kind   Kind
+	UserID uint
+	Title  map[string]string // by language; English is used when the user's is missing
+	Body   map[string]string
+	Data   map[string]string
+}
+
+// Register stores token for the user, moving it over if another user registered it
+// on the same device before
+func Register(db *gorm.DB, userID uint, token string, platform userModel.DevicePlatform, appVersion string) (*userModel.DeviceToken, error) {
+	device := userModel.DeviceToken{
+		UserID:     userID,
+		Token:      token,
+		Platform:   platform,
+		AppVersion: appVersion,
+		LastSeenAt: time.Now(),
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "app_version", "last_seen_at", "updated_at"}),
+	}).Create(&device).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("token = ?", token).First(&device).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// List returns the user's registered devices, most recently seen first
+func List(db *gorm.DB, userID uint) ([]userModel.DeviceToken, error) {
+	var devices []userModel.DeviceToken
+	err := db.Where("user_id = ?", userID).Order("last_seen_at DESC").Find(&devices).Error
+	return devices, err
+}
+
+// Remove deletes one of the user's devices. It reports whether a device was removed.
+func Remove(db *gorm.DB, userID, deviceID uint) (bool, error) {
+	result := db.Where("id = ? AND user_id = ?", deviceID, userID).Delete(&userModel.DeviceToken{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// enabled reports whether the user accepts pushes of kind
+func enabled(prefs preferenceTypes.Preferences, kind Kind) bool {
+	switch kind {
+	case KindItemsAssigned:
+		return prefs.PushItemsAssigned
+	case KindDeliveryEscalated, KindEscalationReviewed:
+		return prefs.PushEscalations
+	}
+	return true
+}
+
+// Send pushes n to every device of the user unless their settings turn the kind off.
+// Tokens the provider no longer recognises are removed.
+func Send(db *gorm.DB, sender push.Sender, n Notification) error {
+	prefs, err := preference.Get(db, n.UserID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load preferences of user %d, using defaults", n.UserID), err)
+	}
+	if !enabled(prefs, n.Kind) {
+		return nil
+	}
+
+	devices, err := List(db, n.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to load devices of user %d: %w", n.UserID, err)
+	}
+	if len(devices) == 0 {
+		return nil
+	}
+
+	data := map[string]string{"kind": string(n.Kind)}
+	for k, v := range n.Data {
+		data[k] = v
+	}
+	title, body := localized(n.Title, prefs.Language), localized(n.Body, prefs.Language)
+
+	var errs []string
+	for _, device := range devices {
+		err := sender.Send(push.Message{Token: device.Token, Title: title, Body: body, Data: data})
+		switch {
+		case errors.Is(err, push.ErrInvalidToken):
+			if err := db.Delete(&device).Error; err != nil {
+				logger.Error(fmt.Sprintf("Failed to remove stale device token %d", device.ID), err)
+			}
+		case err != nil:
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("push %s to user %d: %s", n.Kind, n.UserID, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func localized(texts map[string]string, lang string) string {
+	if text, ok := texts[lang]; ok {
+		return text
+	}
+	return texts[preferenceTypes.LanguageEnglish]
+}
+
+// ItemsAssigned tells a postman that items were handed over to them
+func ItemsAssigned(userID uint, count int, bagID string) Notification {
+	return Notification{
+		Kind:   KindItemsAssigned,
+		UserID: userID,
+		Title: map[string]string{
+			preferenceTypes.LanguageEnglish: "New items assigned",
+			preferenceTypes.LanguageBangla:  "নতুন আইটেম বরাদ্দ হয়েছে",
+		},
+		Body: map[string]string{
+			preferenceTypes.LanguageEnglish: fmt.Sprintf("%d passport item(s) from bag %s are assigned to you for delivery.", count, bagID),
+			preferenceTypes.LanguageBangla:  fmt.Sprintf("ব্যাগ %s থেকে %d টি পাসপোর্ট আইটেম আপনাকে বিতরণের জন্য দেওয়া হয়েছে।", bagID, count),
+		},
+		Data: map[string]string{"bag_id": bagID, "count": fmt.Sprintf("%d", count)},
+	}
+}
+
+// DeliveryEscalated tells a postman that a delivery is held for supervisor review
+func DeliveryEscalated(userID uint, barcode string, caseID uint) Notification {
+	return Notification{
+		Kind:   KindDeliveryEscalated,
+		UserID: userID,
+		Title: map[string]string{
+			preferenceTypes.LanguageEnglish: "Delivery escalated",
+			preferenceTypes.LanguageBangla:  "বিতরণ পর্যালোচনায় পাঠানো হয়েছে",
+		},
+		Body: map[string]string{
+			preferenceTypes.LanguageEnglish: fmt.Sprintf("Delivery of %s is held for supervisor review.", barcode),
+			preferenceTypes.LanguageBangla:  fmt.Sprintf("%s এর বিতরণ সুপারভাইজারের পর্যালোচনার জন্য স্থগিত আছে।", barcode),
+		},
+		Data: map[string]string{"barcode": barcode, "fraud_case_id": fmt.Sprintf("%d", caseID)},
+	}
+}
+
+// EscalationReviewed tells a postman the outcome of the review of their held delivery
+func EscalationReviewed(userID uint, caseID uint, released bool) Notification {
+	en, bn := "The supervisor rejected the held delivery. Do not deliver the item.", "সুপারভাইজার স্থগিত বিতরণটি বাতিল করেছেন। আইটেমটি বিতরণ করবেন না।"
+	if released {
+		en, bn = "The supervisor released the held delivery. You can deliver the item now.", "সুপারভাইজার স্থগিত বিতরণটি অনুমোদন করেছেন। এখন আইটেমটি বিতরণ করতে পারেন।"
+	}
+	return Notification{
+		Kind:   KindEscalationReviewed,
+		UserID: userID,
+		Title: map[string]string{
+			preferenceTypes.LanguageEnglish: "Escalation reviewed",
+			preferenceTypes.LanguageBangla:  "পর্যালোচনা সম্পন্ন",
+		},
+		Body: map[string]string{
+			preferenceTypes.LanguageEnglish: en,
+			preferenceTypes.LanguageBangla:  bn,
+		},
+		Data: map[string]string{"fraud_case_id": fmt.Sprintf("%d", caseID), "released": fmt.Sprintf("%t", released)},
+	}
+}
+
+var (
+	defaultDB     *gorm.DB
+	defaultSender push.Sender
+	initOnce      sync.Once
+	sendQueue     = workerpool.NewQueue("device_push", workerpool.PriorityNormal)
+)
+
+// Init sets up the database and provider used by Dispatch
+func Init(db *gorm.DB) {
+	initOnce.Do(func() {
+		defaultDB = db
+		defaultSender = push.NewSender()
+	})
+}
+
+// Dispatch queues n on the worker pool so the request that triggered it is not held
+// up by the provider. Failures are logged.
+func Dispatch(n Notification) {
+	if defaultSender == nil {
+		return
+	}
+	// Failures are logged and counted by the pool
+	sendQueue.Go(func() error {
+		return Send(defaultDB, defaultSender, n)
+	})
+}
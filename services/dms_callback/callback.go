@@ -0,0 +1,283 @@
+// Package dms_callback receives article status updates pushed by DMS and applies them
+// to bookings through a configurable status mapping.
+package dms_callback
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/config"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	integrationTypes "passport-booking/types/integration"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// SystemActor is stored in CreatedBy/UpdatedBy when a callback changes a booking
+	SystemActor = "system:dms-callback"
+
+	defaultToleranceSeconds = 300
+)
+
+var (
+	// ErrNotConfigured is returned while DMS_CALLBACK_SECRET is unset; callbacks are
+	// refused rather than accepted unsigned
+	ErrNotConfigured = errors.New("DMS callback secret is not configured")
+	// ErrBadSignature is returned when the signature or timestamp does not check out
+	ErrBadSignature = errors.New("invalid DMS callback signature")
+)
+
+// Tolerance is how far the callback timestamp may be from now;
+// DMS_CALLBACK_TOLERANCE_SECONDS overrides the default of 5 minutes
+func Tolerance() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("DMS_CALLBACK_TOLERANCE_SECONDS")); err == nil && v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return defaultToleranceSeconds * time.Second
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" under secret, as DMS sends
+// it in X-DMS-Signature
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the X-DMS-Timestamp (unix seconds) and X-DMS-Signature headers of a
+// callback against DMS_CALLBACK_SECRET
+func Verify(body []byte, timestamp, signature string, now time.Time) error {
+	secret := config.Secret("DMS_CALLBACK_SECRET")
+	if secret == "" {
+		return ErrNotConfigured
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrBadSignature
+	}
+	if diff := now.Sub(time.Unix(ts, 0)); diff > Tolerance() || diff < -Tolerance() {
+		return ErrBadSignature
+	}
+
+	expected := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(strings.TrimPrefix(signature, "sha256=")))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// eventID is the idempotency key of a callback: the DMS event id when sent, otherwise
+// derived from the article, status and time of the update, so the same status
+// reported again later gets a key of its own
+func eventID(p integrationTypes.DMSCallbackPayload) string {
+	if p.EventID != "" {
+		return p.EventID
+	}
+	var occurred string
+	if p.OccurredAt != nil {
+		occurred = p.OccurredAt.UTC().Format(time.RFC3339Nano)
+	}
+	sum := sha256.Sum256([]byte(p.ArticleID + "|" + p.Status + "|" + occurred))
+	return "derived:" + hex.EncodeToString(sum[:16])
+}
+
+// Process records the callback and applies it to the booking. It reports duplicate
+// when the same event was processed before, in which case nothing is changed.
+func Process(db *gorm.DB, p integrationTypes.DMSCallbackPayload, raw []byte) (*bookingModel.DMSCallback, bool, error) {
+	callback := bookingModel.DMSCallback{
+		EventID:    eventID(p),
+		Barcode:    p.ArticleID,
+		DMSStatus:  p.Status,
+		OccurredAt: p.OccurredAt,
+		Payload:    string(raw),
+	}
+
+	duplicate := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		outcome, bookingID, err := apply(tx, p)
+		if err != nil {
+			return err
+		}
+		callback.Outcome = outcome
+		callback.BookingID = bookingID
+
+		// The unique event id makes a concurrent or repeated delivery lose here, rolling
+		// back whatever apply did
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&callback)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			duplicate = true
+			return errDuplicate
+		}
+		return nil
+	})
+	if errors.Is(err, errDuplicate) {
+		if err := db.Where("event_id = ?", callback.EventID).First(&callback).Error; err != nil {
+			return nil, true, err
+		}
+		return &callback, true, nil
+	}
+	if err != nil {
+		return nil, duplicate, err
+	}
+	return &callback, false, nil
+}
+
+var errDuplicate = errors.New("duplicate DMS callback")
+
+// ErrCallbackNotFound is returned when reprocessing a callback that was never recorded
+var ErrCallbackNotFound = errors.New("DMS callback not found")
+
+// Reprocess applies a recorded callback again, for one that found no mapping or no
+// booking the first time. With p set the stored payload is replaced by it; the
+// event id stays that of the recorded callback.
+func Reprocess(db *gorm.DB, id uint, p *integrationTypes.DMSCallbackPayload) (*bookingModel.DMSCallback, error) {
+	var callback bookingModel.DMSCallback
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&callback, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCallbackNotFound
+			}
+			return err
+		}
+
+		payload := p
+		if payload == nil {
+			payload = &integrationTypes.DMSCallbackPayload{}
+			if err := json.Unmarshal([]byte(callback.Payload), payload); err != nil {
+				return fmt.Errorf("failed to decode stored callback payload: %w", err)
+			}
+		} else {
+			payload.EventID = callback.EventID
+			raw, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			callback.Payload = string(raw)
+		}
+
+		outcome, bookingID, err := apply(tx, *payload)
+		if err != nil {
+			return err
+		}
+		callback.Outcome = outcome
+		callback.BookingID = bookingID
+		callback.Barcode = payload.ArticleID
+		callback.DMSStatus = payload.Status
+		callback.OccurredAt = payload.OccurredAt
+		return tx.Save(&callback).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &callback, nil
+}
+
+// apply moves the booking of the article to the mapped status, when the booking
+// workflow allows the move. An update that occurred before the last one applied to
+// the booking arrived late and is ignored.
+func apply(tx *gorm.DB, p integrationTypes.DMSCallbackPayload) (bookingModel.DMSCallbackOutcome, *uint, error) {
+	var mapping bookingModel.DMSStatusMapping
+	err := tx.Where("dms_status = ? AND active = ?", p.Status, true).First(&mapping).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return bookingModel.DMSCallbackUnmapped, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	var booking bookingModel.Booking
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("barcode = ?", p.ArticleID).First(&booking).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return bookingModel.DMSCallbackNotFound, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	stale, err := olderThanApplied(tx, booking.ID, p.OccurredAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch {
+	case stale:
+		return bookingModel.DMSCallbackStale, &booking.ID, nil
+	case booking.Status == mapping.BookingStatus:
+		return bookingModel.DMSCallbackUnchanged, &booking.ID, nil
+	case booking.Status == bookingModel.BookingStatusDelivered, booking.Status == bookingModel.BookingStatusReturnedToRPO:
+		return bookingModel.DMSCallbackFinal, &booking.ID, nil
+	case !booking.Status.CanTransitionTo(mapping.BookingStatus):
+		return bookingModel.DMSCallbackIllegal, &booking.ID, nil
+	}
+
+	booking.Status = mapping.BookingStatus
+	booking.UpdatedBy = SystemActor
+	if err := tx.Save(&booking).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to update booking %d: %w", booking.ID, err)
+	}
+	if err := tx.Create(&bookingModel.BookingStatusEvent{
+		BookingID: booking.ID,
+		Status:    booking.Status,
+		CreatedBy: SystemActor,
+	}).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to create booking status event for booking %d: %w", booking.ID, err)
+	}
+	if err := booking_event.SnapshotBookingToEvent(tx, &booking, "dms_status_"+string(booking.Status), SystemActor); err != nil {
+		return "", nil, fmt.Errorf("failed to create booking event for booking %d: %w", booking.ID, err)
+	}
+	return bookingModel.DMSCallbackApplied, &booking.ID, nil
+}
+
+// olderThanApplied reports whether occurredAt is before the latest update already
+// applied to the booking. Callbacks without a time cannot be ordered and never are.
+func olderThanApplied(tx *gorm.DB, bookingID uint, occurredAt *time.Time) (bool, error) {
+	if occurredAt == nil {
+		return false, nil
+	}
+	var latest sql.NullTime
+	err := tx.Model(&bookingModel.DMSCallback{}).
+		Where("booking_id = ? AND outcome IN ?", bookingID,
+			[]bookingModel.DMSCallbackOutcome{bookingModel.DMSCallbackApplied, bookingModel.DMSCallbackUnchanged}).
+		Select("MAX(occurred_at)").Row().Scan(&latest)
+	if err != nil {
+		return false, fmt.Errorf("failed to find the last DMS update of booking %d: %w", bookingID, err)
+	}
+	return latest.Valid && occurredAt.Before(latest.Time), nil
+}
+
+// SaveMapping creates or replaces the mapping of a DMS status
+func SaveMapping(db *gorm.DB, req integrationTypes.SaveMappingRequest, userID uint) (*bookingModel.DMSStatusMapping, error) {
+	mapping := bookingModel.DMSStatusMapping{
+		DMSStatus:     req.DMSStatus,
+		BookingStatus: req.BookingStatus,
+		Active:        req.Active == nil || *req.Active,
+		UpdatedByID:   userID,
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "dms_status"}},
+		DoUpdates: clause.AssignmentColumns([]string{"booking_status", "active", "updated_by_id", "updated_at"}),
+	}).Create(&mapping).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("dms_status = ?", req.DMSStatus).First(&mapping).Error; err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
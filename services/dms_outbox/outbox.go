@@ -0,0 +1,489 @@
+// Package dms_outbox queues intake work while DMS is unavailable and replays it once
+// the circuit breaker lets calls through again.
+package dms_outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/workerpool"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// SystemActor is stored in UpdatedBy when the drain worker changes a booking
+	SystemActor = "system:dms-outbox"
+
+	defaultIntervalSeconds   = 30
+	defaultBackoffSeconds    = 60
+	defaultMaxBackoffSeconds = 3600
+	defaultMaxAttempts       = 10
+	drainBatchSize           = 20
+	// claimTimeout is how long a claimed entry is held before another worker may take it
+	claimTimeout = 5 * time.Minute
+)
+
+var (
+	ErrNotFound  = errors.New("outbox entry not found")
+	ErrNotFailed = errors.New("outbox entry is not in failed status")
+	ErrNoAuth    = errors.New("DMS_SERVICE_TOKEN is not set")
+	// ErrInProgress is returned when an entry of the booking is being replayed right now
+	ErrInProgress = errors.New("a DMS sync of the booking is in progress")
+)
+
+// Handler replays one kind of queued operation against DMS
+type Handler interface {
+	// Replay performs the operation. Returning a Permanent error fails the entry
+	// without further retries.
+	Replay(db *gorm.DB, authHeader string, entry *bookingModel.DMSOutboxEntry) error
+	// Abandon undoes local side effects of the queued operation once it has failed for good
+	Abandon(db *gorm.DB, entry *bookingModel.DMSOutboxEntry, reason error)
+}
+
+// drainQueue runs outbox replays on the shared worker pool
+var drainQueue = workerpool.NewQueue("dms_outbox", workerpool.PriorityNormal)
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[bookingModel.DMSOutboxOperation]Handler{}
+)
+
+// Register sets the handler of an operation
+func Register(op bookingModel.DMSOutboxOperation, h Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[op] = h
+}
+
+func handlerFor(op bookingModel.DMSOutboxOperation) Handler {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	return handlers[op]
+}
+
+// PermanentError is a replay failure retrying will not fix, such as DMS rejecting the
+// request
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent marks err as not worth retrying
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// MaxAttempts is how many replays are tried before an entry fails;
+// DMS_OUTBOX_MAX_ATTEMPTS overrides it
+func MaxAttempts() int {
+	return envInt("DMS_OUTBOX_MAX_ATTEMPTS", defaultMaxAttempts)
+}
+
+// Backoff is the wait before replaying again after the given number of failed
+// attempts. It doubles from DMS_OUTBOX_BACKOFF_SECONDS up to
+// DMS_OUTBOX_MAX_BACKOFF_SECONDS.
+func Backoff(attempts int) time.Duration {
+	base := time.Duration(envInt("DMS_OUTBOX_BACKOFF_SECONDS", defaultBackoffSeconds)) * time.Second
+	ceiling := time.Duration(envInt("DMS_OUTBOX_MAX_BACKOFF_SECONDS", defaultMaxBackoffSeconds)) * time.Second
+	wait := base
+	for i := 1; i < attempts && wait < ceiling; i++ {
+		wait *= 2
+	}
+	if wait > ceiling {
+		wait = ceiling
+	}
+	return wait
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// Enqueue queues an operation for the booking. An operation already waiting for the
+// same booking is returned instead of queueing it twice.
+func Enqueue(db *gorm.DB, op bookingModel.DMSOutboxOperation, bookingID uint, bagID string, payload interface{}, createdBy string) (*bookingModel.DMSOutboxEntry, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+
+	var entry bookingModel.DMSOutboxEntry
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Serialise enqueues per booking so a double submit cannot queue twice
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", fmt.Sprintf("dms_outbox:%d", bookingID)).Error; err != nil {
+			return err
+		}
+		err := tx.Where("operation = ? AND booking_id = ? AND status IN ?", op, bookingID,
+			[]bookingModel.DMSOutboxStatus{bookingModel.DMSOutboxPending, bookingModel.DMSOutboxProcessing}).
+			First(&entry).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		entry = bookingModel.DMSOutboxEntry{
+			Operation:     op,
+			BookingID:     bookingID,
+			BagID:         bagID,
+			Payload:       string(raw),
+			Status:        bookingModel.DMSOutboxPending,
+			NextAttemptAt: time.Now(),
+			CreatedBy:     createdBy,
+		}
+		return tx.Create(&entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// claim takes up to limit due entries, oldest first, so concurrent workers on other
+// instances do not replay the same entry
+func claim(db *gorm.DB, limit int) ([]bookingModel.DMSOutboxEntry, error) {
+	now := time.Now()
+	var entries []bookingModel.DMSOutboxEntry
+	err := db.Raw(`UPDATE dms_outbox_entries SET status = ?, locked_until = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM dms_outbox_entries
+			WHERE (status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)
+			ORDER BY id LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		bookingModel.DMSOutboxProcessing, now.Add(claimTimeout), now,
+		bookingModel.DMSOutboxPending, now, bookingModel.DMSOutboxProcessing, now, limit).
+		Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the subquery order
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// Drain replays due entries while DMS is reachable and returns how many went through
+// and how many failed again
+func Drain(db *gorm.DB, authHeader string) (done, failed int, err error) {
+	var mu sync.Mutex
+	for !dms.Degraded() {
+		entries, err := claim(db, drainBatchSize)
+		if err != nil {
+			return done, failed, err
+		}
+		if len(entries) == 0 {
+			return done, failed, nil
+		}
+
+		// Bags replay side by side on the worker pool; the entries of one bag stay in
+		// the order they were queued
+		unavailable := false
+		group := drainQueue.Group()
+		for _, bagEntries := range byBag(entries) {
+			bagEntries := bagEntries
+			group.Go(func() error {
+				d, f, stopped := replayInOrder(db, authHeader, bagEntries)
+				mu.Lock()
+				done += d
+				failed += f
+				unavailable = unavailable || stopped
+				mu.Unlock()
+				return nil
+			})
+		}
+		if err := group.Wait(); err != nil {
+			logger.Error("DMS outbox replay task failed", err)
+		}
+		if unavailable {
+			return done, failed, nil
+		}
+	}
+	return done, failed, nil
+}
+
+// byBag splits entries by bag, keeping their order within each bag
+func byBag(entries []bookingModel.DMSOutboxEntry) [][]bookingModel.DMSOutboxEntry {
+	index := map[string]int{}
+	var groups [][]bookingModel.DMSOutboxEntry
+	for _, e := range entries {
+		i, ok := index[e.BagID]
+		if !ok {
+			i = len(groups)
+			index[e.BagID] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], e)
+	}
+	return groups
+}
+
+// replayInOrder replays the entries one after another. It stops, handing the rest
+// back untouched, when DMS becomes unavailable.
+func replayInOrder(db *gorm.DB, authHeader string, entries []bookingModel.DMSOutboxEntry) (done, failed int, unavailable bool) {
+	for i := range entries {
+		entry := &entries[i]
+		replayErr := replay(db, authHeader, entry)
+		if errors.Is(replayErr, dms.ErrUnavailable) {
+			release(db, entries[i:])
+			return done, failed, true
+		}
+		if replayErr != nil {
+			failed++
+			recordFailure(db, entry, replayErr)
+			continue
+		}
+		done++
+		now := time.Now()
+		if err := db.Model(entry).Updates(map[string]interface{}{
+			"status":       bookingModel.DMSOutboxDone,
+			"attempts":     entry.Attempts + 1,
+			"last_error":   nil,
+			"locked_until": nil,
+			"completed_at": now,
+		}).Error; err != nil {
+			logger.Error(fmt.Sprintf("Failed to mark DMS outbox entry %d done", entry.ID), err)
+		}
+	}
+	return done, failed, false
+}
+
+func replay(db *gorm.DB, authHeader string, entry *bookingModel.DMSOutboxEntry) error {
+	h := handlerFor(entry.Operation)
+	if h == nil {
+		return Permanent(fmt.Errorf("no handler registered for operation %s", entry.Operation))
+	}
+	return h.Replay(db, authHeader, entry)
+}
+
+// release hands claimed entries back without counting an attempt
+func release(db *gorm.DB, entries []bookingModel.DMSOutboxEntry) {
+	ids := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	if err := db.Model(&bookingModel.DMSOutboxEntry{}).
+		Where("id IN ? AND status = ?", ids, bookingModel.DMSOutboxProcessing).
+		Updates(map[string]interface{}{
+			"status":       bookingModel.DMSOutboxPending,
+			"locked_until": nil,
+		}).Error; err != nil {
+		logger.Error("Failed to release DMS outbox entries", err)
+	}
+}
+
+// recordFailure schedules the next replay, or fails the entry once it is permanent
+// or out of attempts
+func recordFailure(db *gorm.DB, entry *bookingModel.DMSOutboxEntry, replayErr error) {
+	attempts := entry.Attempts + 1
+	msg := replayErr.Error()
+	updates := map[string]interface{}{
+		"attempts":     attempts,
+		"last_error":   msg,
+		"locked_until": nil,
+	}
+
+	var permanent *PermanentError
+	giveUp := errors.As(replayErr, &permanent) || attempts >= MaxAttempts()
+	if giveUp {
+		updates["status"] = bookingModel.DMSOutboxFailed
+	} else {
+		updates["status"] = bookingModel.DMSOutboxPending
+		updates["next_attempt_at"] = time.Now().Add(Backoff(attempts))
+	}
+	if err := db.Model(entry).Updates(updates).Error; err != nil {
+		logger.Error(fmt.Sprintf("Failed to record DMS outbox failure for entry %d", entry.ID), err)
+	}
+	if err := db.Create(&bookingModel.DMSOutboxAttempt{EntryID: entry.ID, Attempt: attempts, Error: msg}).Error; err != nil {
+		logger.Error(fmt.Sprintf("Failed to record DMS outbox attempt for entry %d", entry.ID), err)
+	}
+
+	if giveUp {
+		logger.Warning(fmt.Sprintf("DMS outbox entry %d for booking %d failed: %s", entry.ID, entry.BookingID, msg))
+		if h := handlerFor(entry.Operation); h != nil {
+			h.Abandon(db, entry, replayErr)
+		}
+	}
+}
+
+// Retry puts a failed entry back in the queue for the next drain
+func Retry(db *gorm.DB, id uint) (*bookingModel.DMSOutboxEntry, error) {
+	return RetryWithPayload(db, id, "")
+}
+
+// RetryWithPayload puts a failed entry back in the queue like Retry, replacing its
+// payload first when payload is not empty
+func RetryWithPayload(db *gorm.DB, id uint, payload string) (*bookingModel.DMSOutboxEntry, error) {
+	var entry bookingModel.DMSOutboxEntry
+	if err := db.First(&entry, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"status":          bookingModel.DMSOutboxPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	}
+	if payload != "" {
+		updates["payload"] = payload
+	}
+	result := db.Model(&entry).Where("status = ?", bookingModel.DMSOutboxFailed).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &entry, ErrNotFailed
+	}
+	if err := db.First(&entry, id).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Discard drops a failed entry so it is neither drained nor retried again
+func Discard(db *gorm.DB, id uint) (*bookingModel.DMSOutboxEntry, error) {
+	var entry bookingModel.DMSOutboxEntry
+	if err := db.First(&entry, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	result := db.Model(&entry).Where("status = ?", bookingModel.DMSOutboxFailed).
+		Update("status", bookingModel.DMSOutboxDiscarded)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &entry, ErrNotFailed
+	}
+	entry.Status = bookingModel.DMSOutboxDiscarded
+	return &entry, nil
+}
+
+// Suspend holds back the booking's pending entries so the drain worker skips them, and
+// returns how many it held. It fails with ErrInProgress while an entry is being
+// replayed. Run it in the transaction that deletes the booking.
+func Suspend(tx *gorm.DB, bookingID uint) (int, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", fmt.Sprintf("dms_outbox:%d", bookingID)).Error; err != nil {
+		return 0, err
+	}
+	result := tx.Model(&bookingModel.DMSOutboxEntry{}).
+		Where("booking_id = ? AND status = ?", bookingID, bookingModel.DMSOutboxPending).
+		Update("status", bookingModel.DMSOutboxSuspended)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	var processing int64
+	if err := tx.Model(&bookingModel.DMSOutboxEntry{}).
+		Where("booking_id = ? AND status = ?", bookingID, bookingModel.DMSOutboxProcessing).
+		Count(&processing).Error; err != nil {
+		return 0, err
+	}
+	if processing > 0 {
+		return 0, ErrInProgress
+	}
+	return int(result.RowsAffected), nil
+}
+
+// Resume puts the booking's suspended entries back in the queue for the next drain and
+// returns how many there were
+func Resume(tx *gorm.DB, bookingID uint) (int, error) {
+	result := tx.Model(&bookingModel.DMSOutboxEntry{}).
+		Where("booking_id = ? AND status = ?", bookingID, bookingModel.DMSOutboxSuspended).
+		Updates(map[string]interface{}{
+			"status":          bookingModel.DMSOutboxPending,
+			"next_attempt_at": time.Now(),
+		})
+	return int(result.RowsAffected), result.Error
+}
+
+// Attempts returns the recorded failures of an entry, oldest first
+func Attempts(db *gorm.DB, id uint) ([]bookingModel.DMSOutboxAttempt, error) {
+	var attempts []bookingModel.DMSOutboxAttempt
+	err := db.Where("entry_id = ?", id).Order("id").Find(&attempts).Error
+	return attempts, err
+}
+
+// Counts returns how many entries are in each status
+func Counts(db *gorm.DB) (map[bookingModel.DMSOutboxStatus]int64, error) {
+	var rows []struct {
+		Status bookingModel.DMSOutboxStatus
+		Count  int64
+	}
+	if err := db.Model(&bookingModel.DMSOutboxEntry{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := map[bookingModel.DMSOutboxStatus]int64{}
+	for _, r := range rows {
+		counts[r.Status] = r.Count
+	}
+	return counts, nil
+}
+
+// StartScheduler drains the outbox every DMS_OUTBOX_INTERVAL_SECONDS (default 30)
+// with the DMS service token. Runs are skipped while DMS is degraded. The returned
+// function stops the job.
+func StartScheduler(db *gorm.DB) func() {
+	ticker := time.NewTicker(time.Duration(envInt("DMS_OUTBOX_INTERVAL_SECONDS", defaultIntervalSeconds)) * time.Second)
+	stop := make(chan struct{})
+
+	run := func() {
+		if dms.Degraded() {
+			return
+		}
+		authHeader := dms.ServiceAuthHeader()
+		if authHeader == "" {
+			logger.Warning("DMS outbox draining is disabled: " + ErrNoAuth.Error())
+			return
+		}
+		done, failed, err := Drain(db, authHeader)
+		if err != nil {
+			logger.Error("DMS outbox drain failed", err)
+			return
+		}
+		if done > 0 || failed > 0 {
+			logger.Info(fmt.Sprintf("DMS outbox drained: %d synced, %d failed", done, failed))
+		}
+	}
+
+	go func() {
+		run()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(stop) }
+}
@@ -0,0 +1,425 @@
+// Package evidence_export builds password-protected archives of delivery evidence for
+// the passport office's audit team. Jobs run on the worker pool; the archive is a zip
+// encrypted in the format of `openssl enc -aes-256-cbc -pbkdf2`, so it opens with
+// standard tools and the password handed over out of band.
+package evidence_export
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	evidenceModel "passport-booking/models/evidence"
+	otpModel "passport-booking/models/otp"
+	"passport-booking/services/workerpool"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Dir is where finished archives are written
+const Dir = "./evidence_exports"
+
+const (
+	// PBKDF2Iterations must be passed to openssl as -iter when decrypting
+	PBKDF2Iterations = 100000
+	passwordLength   = 20
+	passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+)
+
+// ErrTooManyBookings is returned when a date range covers more bookings than one
+// archive may hold
+var ErrTooManyBookings = errors.New("too many bookings for one evidence export, narrow the date range")
+
+// exports are bulky and nobody waits on them, so they yield to other work
+var exportQueue = workerpool.NewQueue("evidence_export", workerpool.PriorityLow)
+
+// MaxBookings caps the bookings in one archive; EVIDENCE_EXPORT_MAX_BOOKINGS overrides it
+func MaxBookings() int {
+	if v, err := strconv.Atoi(os.Getenv("EVIDENCE_EXPORT_MAX_BOOKINGS")); err == nil && v > 0 {
+		return v
+	}
+	return 500
+}
+
+// GeneratePassword returns a random archive password without look-alike characters
+func GeneratePassword() (string, error) {
+	b := make([]byte, passwordLength)
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// Enqueue queues the saved job to be built with password. The password only lives in
+// the queued task, so a job interrupted by a restart cannot resume; see FailInterrupted.
+func Enqueue(db *gorm.DB, job *evidenceModel.ExportJob, password string) error {
+	return exportQueue.Submit(func() error {
+		return Run(db, job.ID, password)
+	})
+}
+
+// FailInterrupted marks jobs left queued or running by a previous process as failed
+func FailInterrupted(db *gorm.DB) error {
+	return db.Model(&evidenceModel.ExportJob{}).
+		Where("status IN ?", []evidenceModel.ExportStatus{evidenceModel.ExportStatusQueued, evidenceModel.ExportStatusRunning}).
+		Updates(map[string]interface{}{
+			"status": evidenceModel.ExportStatusFailed,
+			"error":  "interrupted by a restart, request a new export",
+		}).Error
+}
+
+// Run builds the archive of job id and records the outcome on the job
+func Run(db *gorm.DB, id uint, password string) error {
+	var job evidenceModel.ExportJob
+	if err := db.First(&job, id).Error; err != nil {
+		return fmt.Errorf("failed to load evidence export job %d: %w", id, err)
+	}
+
+	now := time.Now()
+	job.Status = evidenceModel.ExportStatusRunning
+	job.StartedAt = &now
+	if err := db.Save(&job).Error; err != nil {
+		return fmt.Errorf("failed to start evidence export job %d: %w", id, err)
+	}
+
+	path, size, count, err := build(db, &job, password)
+	completed := time.Now()
+	job.CompletedAt = &completed
+	if err != nil {
+		job.Status = evidenceModel.ExportStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = evidenceModel.ExportStatusCompleted
+		job.FilePath, job.FileSize, job.BookingCount = path, size, count
+	}
+	if saveErr := db.Save(&job).Error; saveErr != nil {
+		logger.Error(fmt.Sprintf("Failed to record outcome of evidence export job %d", id), saveErr)
+	}
+	if err != nil {
+		return fmt.Errorf("evidence export job %d: %w", id, err)
+	}
+
+	logger.Info(fmt.Sprintf("Evidence export job %d finished with %d bookings (%d bytes)", id, count, size))
+	return nil
+}
+
+// bookingIDs resolves the bookings in scope of job
+func bookingIDs(db *gorm.DB, job *evidenceModel.ExportJob) ([]uint, error) {
+	if job.BookingID != nil {
+		return []uint{*job.BookingID}, nil
+	}
+
+	var ids []uint
+	err := db.Model(&bookingModel.BookingStatusEvent{}).
+		Where("status = ? AND created_at >= ? AND created_at < ?", bookingModel.BookingStatusDelivered, job.From, job.To).
+		Distinct().Order("booking_id").
+		Limit(MaxBookings()+1).
+		Pluck("booking_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > MaxBookings() {
+		return nil, ErrTooManyBookings
+	}
+	return ids, nil
+}
+
+// build writes the encrypted archive and returns its path, size and booking count
+func build(db *gorm.DB, job *evidenceModel.ExportJob, password string) (string, int64, int, error) {
+	ids, err := bookingIDs(db, job)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	var buf bytes.Buffer
+	w := &archive{zip: zip.NewWriter(&buf)}
+	for _, id := range ids {
+		if err := w.addBooking(db, id); err != nil {
+			return "", 0, 0, fmt.Errorf("booking %d: %w", id, err)
+		}
+	}
+	if err := w.addManifest(job, len(ids)); err != nil {
+		return "", 0, 0, err
+	}
+	if err := w.zip.Close(); err != nil {
+		return "", 0, 0, fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	encrypted, err := encrypt(buf.Bytes(), password)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	if err := os.MkdirAll(Dir, 0o700); err != nil {
+		return "", 0, 0, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	path := filepath.Join(Dir, fmt.Sprintf("evidence-export-%d.zip.enc", job.ID))
+	if err := os.WriteFile(path, encrypted, 0o600); err != nil {
+		return "", 0, 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return path, int64(len(encrypted)), len(ids), nil
+}
+
+// FileName is the download name of the archive of job
+func FileName(job *evidenceModel.ExportJob) string {
+	return fmt.Sprintf("evidence-export-%d.zip.enc", job.ID)
+}
+
+// archive is the zip being assembled, with the checksum of every file for the manifest
+type archive struct {
+	zip   *zip.Writer
+	files []manifestFile
+}
+
+type manifestFile struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+func (a *archive) add(name string, data []byte) error {
+	f, err := a.zip.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	a.files = append(a.files, manifestFile{Name: name, Size: len(data), SHA256: hex.EncodeToString(sum[:])})
+	return nil
+}
+
+func (a *archive) addJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return a.add(name, data)
+}
+
+// addFile copies a stored photo into the archive, noting it when it is gone
+func (a *archive) addFile(dir, name string, path *string, missing *[]string) error {
+	if path == nil || *path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(*path)
+	if err != nil {
+		*missing = append(*missing, fmt.Sprintf("%s: %v", name, err))
+		return nil
+	}
+	return a.add(dir+name+strings.ToLower(filepath.Ext(*path)), data)
+}
+
+// otpEvidence is the OTP part of a booking's evidence. The stored codes stay
+// encrypted; revealing one still needs a dual-authorized evidence request.
+type otpEvidence struct {
+	AppliedVerified    bool        `json:"delivery_phone_applied_verified"`
+	AppliedEncrypted   *string     `json:"delivery_phone_applied_otp_encrypted,omitempty"`
+	ConfirmedVerified  bool        `json:"delivery_phone_confirmed_verified"`
+	ConfirmedEncrypted *string     `json:"delivery_phone_confirmed_otp_encrypted,omitempty"`
+	Events             []otpRecord `json:"events"`
+}
+
+// otpRecord is one OTP event without the code
+type otpRecord struct {
+	EventType     string     `json:"event_type"`
+	Phone         string     `json:"phone"`
+	Purpose       string     `json:"purpose"`
+	Channel       string     `json:"channel,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	SendError     string     `json:"send_error,omitempty"`
+	IsUsed        bool       `json:"is_used"`
+	RetryCount    int        `json:"retry_count"`
+	IsBlocked     bool       `json:"is_blocked"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// eventLog is the status history and full event trail of a booking
+type eventLog struct {
+	StatusEvents []statusEvent  `json:"status_events"`
+	Events       []bookingEvent `json:"events"`
+}
+
+type statusEvent struct {
+	Status         string    `json:"status"`
+	CreatedBy      string    `json:"created_by"`
+	CreatedByName  string    `json:"created_by_name,omitempty"`
+	CreatedByRole  string    `json:"created_by_role,omitempty"`
+	ImpersonatedBy *string   `json:"impersonated_by,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type bookingEvent struct {
+	EventType     string    `json:"event_type"`
+	Status        string    `json:"status"`
+	CreatedBy     string    `json:"created_by"`
+	UpdatedBy     string    `json:"updated_by,omitempty"`
+	UpdatedByName string    `json:"updated_by_name,omitempty"`
+	UpdatedByRole string    `json:"updated_by_role,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// addBooking adds the evidence of one booking under a folder named by its barcode
+func (a *archive) addBooking(db *gorm.DB, id uint) error {
+	var booking bookingModel.Booking
+	if err := db.Preload("DeliveryAddress").First(&booking, id).Error; err != nil {
+		return err
+	}
+
+	folder := strconv.FormatUint(uint64(booking.ID), 10)
+	if booking.Barcode != nil && *booking.Barcode != "" {
+		folder = *booking.Barcode
+	}
+	dir := folder + "/"
+
+	if err := a.addJSON(dir+"booking.json", map[string]interface{}{
+		"id":                       booking.ID,
+		"app_or_order_id":          booking.AppOrOrderID,
+		"barcode":                  booking.Barcode,
+		"name":                     booking.Name,
+		"phone":                    booking.Phone,
+		"delivery_phone":           booking.DeliveryPhone,
+		"delivery_branch_code":     booking.DeliveryBranchCode,
+		"delivery_address":         booking.DeliveryAddress,
+		"status":                   booking.Status,
+		"recipient_id_verified":    booking.RecipientIDVerified,
+		"recipient_nid_last4":      booking.RecipientNIDLast4,
+		"recipient_id_verified_at": booking.RecipientIDVerifiedAt,
+		"anonymized_at":            booking.AnonymizedAt,
+	}); err != nil {
+		return err
+	}
+
+	// Photos taken at hand-over; this system captures no recipient signature
+	var missing []string
+	if err := a.addFile(dir, "delivery_photo", booking.UploadPhoto, &missing); err != nil {
+		return err
+	}
+	if err := a.addFile(dir, "recipient_id_photo", booking.RecipientIDPhoto, &missing); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		if err := a.add(dir+"missing_files.txt", []byte(strings.Join(missing, "\n")+"\n")); err != nil {
+			return err
+		}
+	}
+
+	var otpEvents []otpModel.OTPEvent
+	if err := db.Where("booking_id = ?", booking.ID).Order("id").Find(&otpEvents).Error; err != nil {
+		return err
+	}
+	otps := otpEvidence{
+		AppliedVerified:    booking.DeliveryPhoneAppliedVerified,
+		AppliedEncrypted:   booking.DeliveryPhoneAppliedOTPEncrypted,
+		ConfirmedVerified:  booking.DeliveryPhoneConfirmedVerified,
+		ConfirmedEncrypted: booking.DeliveryPhoneConfirmedOTPEncrypted,
+		Events:             make([]otpRecord, 0, len(otpEvents)),
+	}
+	for _, e := range otpEvents {
+		otps.Events = append(otps.Events, otpRecord{
+			EventType: e.EventType, Phone: e.Phone, Purpose: string(e.Purpose),
+			Channel: e.Channel, SentAt: e.SentAt, SendError: e.SendError,
+			IsUsed: e.IsUsed, RetryCount: e.RetryCount, IsBlocked: e.IsBlocked,
+			LastAttemptAt: e.LastAttemptAt, ExpiresAt: e.ExpiresAt, UpdatedAt: e.UpdatedAt,
+		})
+	}
+	if err := a.addJSON(dir+"otp_evidence.json", otps); err != nil {
+		return err
+	}
+
+	var statuses []bookingModel.BookingStatusEvent
+	if err := db.Where("booking_id = ?", booking.ID).Order("created_at, id").Find(&statuses).Error; err != nil {
+		return err
+	}
+	var events []bookingModel.BookingEvent
+	if err := db.Select("event_type", "status", "created_by", "updated_by", "created_at").
+		Where("app_or_order_id = ?", booking.AppOrOrderID).Order("created_at, id").Find(&events).Error; err != nil {
+		return err
+	}
+	log := eventLog{
+		StatusEvents: make([]statusEvent, 0, len(statuses)),
+		Events:       make([]bookingEvent, 0, len(events)),
+	}
+	for _, s := range statuses {
+		log.StatusEvents = append(log.StatusEvents, statusEvent{
+			Status: string(s.Status), CreatedBy: s.CreatedBy, CreatedByName: s.CreatedByName, CreatedByRole: s.CreatedByRole,
+			ImpersonatedBy: s.ImpersonatedBy, CreatedAt: s.CreatedAt,
+		})
+	}
+	for _, e := range events {
+		log.Events = append(log.Events, bookingEvent{
+			EventType: e.EventType, Status: string(e.Status), CreatedBy: e.CreatedBy, UpdatedBy: e.UpdatedBy,
+			UpdatedByName: e.UpdatedByName, UpdatedByRole: e.UpdatedByRole, CreatedAt: e.CreatedAt,
+		})
+	}
+	return a.addJSON(dir+"event_log.json", log)
+}
+
+// addManifest describes the export and lists every file with its checksum
+func (a *archive) addManifest(job *evidenceModel.ExportJob, bookings int) error {
+	return a.addJSON("manifest.json", map[string]interface{}{
+		"export_job_id":   job.ID,
+		"generated_at":    time.Now().UTC(),
+		"requested_by_id": job.RequestedByID,
+		"reason":          job.Reason,
+		"booking_id":      job.BookingID,
+		"from":            job.From,
+		"to":              job.To,
+		"bookings":        bookings,
+		"files":           a.files,
+	})
+}
+
+// encrypt seals data like `openssl enc -aes-256-cbc -pbkdf2 -iter 100000 -md sha256`:
+// "Salted__", an 8 byte salt, then AES-256-CBC with PKCS#7 padding under a key and IV
+// derived from the password with PBKDF2-SHA256
+func encrypt(data []byte, password string) ([]byte, error) {
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	derived, err := pbkdf2.Key(sha256.New, password, salt, PBKDF2Iterations, 32+aes.BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return nil, err
+	}
+
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	plain := make([]byte, len(data)+pad)
+	copy(plain, data)
+	for i := len(data); i < len(plain); i++ {
+		plain[i] = byte(pad)
+	}
+
+	out := make([]byte, 16+len(plain))
+	copy(out, "Salted__")
+	copy(out[8:], salt)
+	cipher.NewCBCEncrypter(block, derived[32:]).CryptBlocks(out[16:], plain)
+	return out, nil
+}
@@ -0,0 +1,238 @@
+package fraud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	fraudModel "passport-booking/models/fraud"
+	devicePush "passport-booking/services/device_push"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Params are a rule's configured thresholds
+type Params map[string]float64
+
+// Get returns the named param or fallback when it is not configured
+func (p Params) Get(name string, fallback float64) float64 {
+	if v, ok := p[name]; ok {
+		return v
+	}
+	return fallback
+}
+
+// Signals is what the rules get to look at for one delivery attempt
+type Signals struct {
+	Booking   *bookingModel.Booking
+	PostmanID uint
+	Latitude  *float64
+	Longitude *float64
+	Now       time.Time
+}
+
+// Hit is a rule that fired, with a human readable reason
+type Hit struct {
+	Code   string                 `json:"code"`
+	Action fraudModel.RuleAction  `json:"action"`
+	Reason string                 `json:"reason"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+// Rule is one pluggable fraud check. Evaluate returns nil when the rule does not fire.
+type Rule interface {
+	Code() string
+	Description() string
+	DefaultAction() fraudModel.RuleAction
+	DefaultParams() Params
+	Evaluate(db *gorm.DB, signals Signals, params Params) (*Hit, error)
+}
+
+var registry = struct {
+	sync.RWMutex
+	rules map[string]Rule
+}{rules: make(map[string]Rule)}
+
+// Register makes a rule available to the engine; its DB row controls whether it runs
+func Register(rule Rule) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.rules[rule.Code()] = rule
+}
+
+// Registered returns the registered rules ordered by code
+func Registered() []Rule {
+	registry.RLock()
+	defer registry.RUnlock()
+	rules := make([]Rule, 0, len(registry.rules))
+	for _, rule := range registry.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Code() < rules[j].Code() })
+	return rules
+}
+
+func lookup(code string) (Rule, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	rule, ok := registry.rules[code]
+	return rule, ok
+}
+
+// Decision is the outcome of evaluating a delivery
+type Decision struct {
+	Action fraudModel.RuleAction // empty when nothing fired
+	Hits   []Hit
+	Case   *fraudModel.FraudCase
+}
+
+// Blocked reports whether the delivery must wait for supervisor review
+func (d Decision) Blocked() bool {
+	return d.Action == fraudModel.RuleActionBlock
+}
+
+// Evaluate runs every enabled rule against the delivery attempt and opens a fraud
+// case when any of them fires. A booking whose earlier block was released by a
+// supervisor is not blocked again, but new hits are still recorded as flags.
+func Evaluate(db *gorm.DB, signals Signals) (Decision, error) {
+	var configs []fraudModel.FraudRule
+	if err := db.Where("enabled = ?", true).Order("code").Find(&configs).Error; err != nil {
+		return Decision{}, fmt.Errorf("failed to load fraud rules: %w", err)
+	}
+
+	var decision Decision
+	for _, config := range configs {
+		rule, ok := lookup(config.Code)
+		if !ok {
+			logger.Warning(fmt.Sprintf("Fraud rule %s is configured but not registered", config.Code))
+			continue
+		}
+
+		params := rule.DefaultParams()
+		if config.Params != "" {
+			if err := json.Unmarshal([]byte(config.Params), &params); err != nil {
+				logger.Error(fmt.Sprintf("Invalid params for fraud rule %s", config.Code), err)
+				continue
+			}
+		}
+
+		hit, err := rule.Evaluate(db, signals, params)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Fraud rule %s failed", config.Code), err)
+			continue
+		}
+		if hit == nil {
+			continue
+		}
+
+		hit.Code = config.Code
+		hit.Action = config.Action
+		decision.Hits = append(decision.Hits, *hit)
+		if hit.Action == fraudModel.RuleActionBlock || decision.Action == "" {
+			decision.Action = hit.Action
+		}
+	}
+
+	if len(decision.Hits) == 0 {
+		return decision, nil
+	}
+
+	if decision.Blocked() {
+		released, err := releasedFor(db, signals.Booking.ID)
+		if err != nil {
+			return decision, err
+		}
+		if released {
+			decision.Action = fraudModel.RuleActionFlag
+		}
+	}
+
+	fraudCase, err := openCase(db, signals, decision)
+	if err != nil {
+		return decision, err
+	}
+	decision.Case = fraudCase
+	return decision, nil
+}
+
+func releasedFor(db *gorm.DB, bookingID uint) (bool, error) {
+	var count int64
+	err := db.Model(&fraudModel.FraudCase{}).
+		Where("booking_id = ? AND action = ? AND status = ?", bookingID, fraudModel.RuleActionBlock, fraudModel.CaseStatusReleased).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// openCase records the hits, reusing the booking's open case of the same action so a
+// postman retrying a held delivery does not pile up duplicates
+func openCase(db *gorm.DB, signals Signals, decision Decision) (*fraudModel.FraudCase, error) {
+	codes := make([]string, 0, len(decision.Hits))
+	for _, hit := range decision.Hits {
+		codes = append(codes, hit.Code)
+	}
+	details, err := json.Marshal(decision.Hits)
+	if err != nil {
+		return nil, err
+	}
+
+	var fraudCase fraudModel.FraudCase
+	err = db.Where("booking_id = ? AND action = ? AND status = ?", signals.Booking.ID, decision.Action, fraudModel.CaseStatusOpen).
+		First(&fraudCase).Error
+	switch {
+	case err == nil:
+		fraudCase.PostmanID = signals.PostmanID
+		fraudCase.RuleCodes = strings.Join(codes, ",")
+		fraudCase.Details = string(details)
+		return &fraudCase, db.Save(&fraudCase).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		fraudCase = fraudModel.FraudCase{
+			BookingID: signals.Booking.ID,
+			PostmanID: signals.PostmanID,
+			Action:    decision.Action,
+			RuleCodes: strings.Join(codes, ","),
+			Details:   string(details),
+			Status:    fraudModel.CaseStatusOpen,
+		}
+		if err := db.Create(&fraudCase).Error; err != nil {
+			return nil, err
+		}
+		if fraudCase.Action == fraudModel.RuleActionBlock {
+			var barcode string
+			if signals.Booking.Barcode != nil {
+				barcode = *signals.Booking.Barcode
+			}
+			devicePush.Dispatch(devicePush.DeliveryEscalated(fraudCase.PostmanID, barcode, fraudCase.ID))
+		}
+		return &fraudCase, nil
+	default:
+		return nil, err
+	}
+}
+
+// SeedRules adds a DB row with the default configuration for every registered rule
+// that is not configured yet. Existing rows are left untouched.
+func SeedRules(db *gorm.DB) error {
+	for _, rule := range Registered() {
+		params, err := json.Marshal(rule.DefaultParams())
+		if err != nil {
+			return err
+		}
+		config := fraudModel.FraudRule{
+			Code:        rule.Code(),
+			Description: rule.Description(),
+			Enabled:     true,
+			Action:      rule.DefaultAction(),
+			Params:      string(params),
+			UpdatedBy:   "system",
+		}
+		if err := db.Where(fraudModel.FraudRule{Code: rule.Code()}).FirstOrCreate(&config).Error; err != nil {
+			return fmt.Errorf("failed to seed fraud rule %s: %w", rule.Code(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package fraud
+
+import (
+	"fmt"
+	"math"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	fraudModel "passport-booking/models/fraud"
+	"passport-booking/models/otp"
+	"passport-booking/services/localtime"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register(farFromAddressRule{})
+	Register(fastOddHourOTPRule{})
+	Register(repeatedHoldsRule{})
+	Register(duplicatePhotoRule{})
+}
+
+// farFromAddressRule fires when the hand-over location is far from where earlier
+// deliveries to the same post office were made. Addresses are not geocoded, so the
+// centroid of past deliveries to the post office stands in for the address.
+type farFromAddressRule struct{}
+
+func (farFromAddressRule) Code() string { return "photo_far_from_address" }
+
+func (farFromAddressRule) Description() string {
+	return "Delivery location is far from earlier deliveries to the same post office"
+}
+
+func (farFromAddressRule) DefaultAction() fraudModel.RuleAction { return fraudModel.RuleActionFlag }
+
+func (farFromAddressRule) DefaultParams() Params {
+	return Params{"max_distance_km": 5, "min_samples": 5}
+}
+
+func (farFromAddressRule) Evaluate(db *gorm.DB, s Signals, p Params) (*Hit, error) {
+	if s.Latitude == nil || s.Longitude == nil || s.Booking.DeliveryAddressID == nil {
+		return nil, nil
+	}
+
+	var centroid struct {
+		Samples   int64
+		Latitude  *float64
+		Longitude *float64
+	}
+	if err := db.Raw(`
+		SELECT COUNT(*) AS samples, AVG(b.delivered_latitude) AS latitude, AVG(b.delivered_longitude) AS longitude
+		FROM bookings b
+		JOIN addresses a ON a.id = b.delivery_address_id
+		WHERE a.post_office_code = (SELECT post_office_code FROM addresses WHERE id = ?)
+			AND b.id <> ? AND b.delivered_latitude IS NOT NULL AND b.delivered_longitude IS NOT NULL`,
+		*s.Booking.DeliveryAddressID, s.Booking.ID,
+	).Scan(&centroid).Error; err != nil {
+		return nil, err
+	}
+	if centroid.Samples < int64(p.Get("min_samples", 5)) || centroid.Latitude == nil {
+		return nil, nil
+	}
+
+	distance := haversineKm(*s.Latitude, *s.Longitude, *centroid.Latitude, *centroid.Longitude)
+	maxDistance := p.Get("max_distance_km", 5)
+	if distance <= maxDistance {
+		return nil, nil
+	}
+	return &Hit{
+		Reason: fmt.Sprintf("delivered %.1f km from the usual delivery area (limit %.1f km)", distance, maxDistance),
+		Data:   map[string]interface{}{"distance_km": math.Round(distance*10) / 10},
+	}, nil
+}
+
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// fastOddHourOTPRule fires when the delivery OTP was verified within seconds of being
+// sent during night hours, a pattern of the postman entering the code on the applicant's behalf
+type fastOddHourOTPRule struct{}
+
+func (fastOddHourOTPRule) Code() string { return "otp_fast_odd_hours" }
+
+func (fastOddHourOTPRule) Description() string {
+	return "Delivery OTP verified seconds after it was sent, at night"
+}
+
+func (fastOddHourOTPRule) DefaultAction() fraudModel.RuleAction { return fraudModel.RuleActionBlock }
+
+func (fastOddHourOTPRule) DefaultParams() Params {
+	return Params{"max_seconds": 10, "night_start_hour": 22, "night_end_hour": 6}
+}
+
+func (fastOddHourOTPRule) Evaluate(db *gorm.DB, s Signals, p Params) (*Hit, error) {
+	// OTP events copy the OTP row, so updated_at is when the OTP was sent or verified
+	var times struct {
+		SentAt     *time.Time
+		VerifiedAt *time.Time
+	}
+	if err := db.Raw(`
+		SELECT MAX(updated_at) FILTER (WHERE event_type IN ('created', 'resent')) AS sent_at,
+			MAX(updated_at) FILTER (WHERE event_type = 'verified_success') AS verified_at
+		FROM otp_events
+		WHERE booking_id = ? AND purpose = ?`,
+		s.Booking.ID, otp.OTPPurposeDeliveryConfirmPhone,
+	).Scan(&times).Error; err != nil {
+		return nil, err
+	}
+	if times.SentAt == nil || times.VerifiedAt == nil || times.VerifiedAt.Before(*times.SentAt) {
+		return nil, nil
+	}
+
+	elapsed := times.VerifiedAt.Sub(*times.SentAt)
+	if elapsed.Seconds() > p.Get("max_seconds", 10) {
+		return nil, nil
+	}
+
+	hour := times.VerifiedAt.In(localZone()).Hour()
+	start, end := int(p.Get("night_start_hour", 22)), int(p.Get("night_end_hour", 6))
+	night := hour >= start || hour < end
+	if start <= end {
+		night = hour >= start && hour < end
+	}
+	if !night {
+		return nil, nil
+	}
+
+	return &Hit{
+		Reason: fmt.Sprintf("OTP verified %.0f seconds after sending at %02d:00", elapsed.Seconds(), hour),
+		Data:   map[string]interface{}{"elapsed_seconds": elapsed.Seconds(), "hour": hour},
+	}, nil
+}
+
+// localZone is the zone night hours are judged in; FRAUD_TIMEZONE overrides the
+// user-facing zone
+func localZone() *time.Location {
+	if name := os.Getenv("FRAUD_TIMEZONE"); name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return localtime.Zone()
+}
+
+// repeatedHoldsRule fires when the postman's deliveries were held or had to be released
+// by a supervisor several times recently
+type repeatedHoldsRule struct{}
+
+func (repeatedHoldsRule) Code() string { return "repeated_overrides" }
+
+func (repeatedHoldsRule) Description() string {
+	return "Postman has had several deliveries held or overridden recently"
+}
+
+func (repeatedHoldsRule) DefaultAction() fraudModel.RuleAction { return fraudModel.RuleActionFlag }
+
+func (repeatedHoldsRule) DefaultParams() Params {
+	return Params{"max_cases": 3, "window_days": 7}
+}
+
+func (repeatedHoldsRule) Evaluate(db *gorm.DB, s Signals, p Params) (*Hit, error) {
+	since := s.Now.AddDate(0, 0, -int(p.Get("window_days", 7)))
+
+	var count int64
+	if err := db.Model(&fraudModel.FraudCase{}).
+		Where("postman_id = ? AND booking_id <> ? AND action = ? AND created_at >= ?",
+			s.PostmanID, s.Booking.ID, fraudModel.RuleActionBlock, since).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	maxCases := p.Get("max_cases", 3)
+	if float64(count) < maxCases {
+		return nil, nil
+	}
+	return &Hit{
+		Reason: fmt.Sprintf("%d held deliveries in the last %.0f days", count, p.Get("window_days", 7)),
+		Data:   map[string]interface{}{"held_deliveries": count},
+	}, nil
+}
+
+// duplicatePhotoRule fires when the delivery photo is byte for byte the photo of
+// another booking, i.e. one hand-over picture reused as proof for several items.
+// Bookings of the same delivery group share their photo by design and are skipped.
+type duplicatePhotoRule struct{}
+
+func (duplicatePhotoRule) Code() string { return "duplicate_delivery_photo" }
+
+func (duplicatePhotoRule) Description() string {
+	return "Delivery photo was already uploaded for a different booking"
+}
+
+func (duplicatePhotoRule) DefaultAction() fraudModel.RuleAction { return fraudModel.RuleActionBlock }
+
+func (duplicatePhotoRule) DefaultParams() Params { return Params{} }
+
+func (duplicatePhotoRule) Evaluate(db *gorm.DB, s Signals, p Params) (*Hit, error) {
+	if s.Booking.UploadPhotoHash == nil || *s.Booking.UploadPhotoHash == "" {
+		return nil, nil
+	}
+
+	query := db.Model(&bookingModel.Booking{}).
+		Where("upload_photo_hash = ? AND id <> ?", *s.Booking.UploadPhotoHash, s.Booking.ID)
+	if s.Booking.DeliveryGroupID != nil {
+		query = query.Where("(delivery_group_id IS NULL OR delivery_group_id <> ?)", *s.Booking.DeliveryGroupID)
+	}
+	var matches []struct {
+		ID      uint
+		Barcode *string
+	}
+	if err := query.Select("id, barcode").Order("id").Limit(10).Scan(&matches).Error; err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, 0, len(matches))
+	barcodes := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.ID)
+		if m.Barcode != nil {
+			barcodes = append(barcodes, *m.Barcode)
+		}
+	}
+	return &Hit{
+		Reason: fmt.Sprintf("the same photo was uploaded for %d other booking(s)", len(matches)),
+		Data:   map[string]interface{}{"booking_ids": ids, "barcodes": barcodes},
+	}, nil
+}
@@ -0,0 +1,75 @@
+package id_verification
+
+import (
+	"fmt"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode controls when the postman must verify the recipient's NID before delivery
+type Mode string
+
+const (
+	ModeOff      Mode = "off"      // step is not offered
+	ModeOptional Mode = "optional" // step is available but not enforced
+	ModeRequired Mode = "required" // delivery is refused until the NID is verified
+)
+
+// CurrentMode reads ID_VERIFICATION_MODE (default off)
+func CurrentMode() Mode {
+	switch Mode(strings.ToLower(os.Getenv("ID_VERIFICATION_MODE"))) {
+	case ModeOptional:
+		return ModeOptional
+	case ModeRequired:
+		return ModeRequired
+	default:
+		return ModeOff
+	}
+}
+
+// Required reports whether booking may only be delivered after ID verification
+func Required(booking *bookingModel.Booking) bool {
+	return CurrentMode() == ModeRequired && !booking.RecipientIDVerified
+}
+
+// NormalizeNID strips spaces and dashes from a typed NID number
+func NormalizeNID(nid string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(nid))
+}
+
+// ValidateNIDFormat checks a normalized Bangladesh NID number: 10 digits (smart card),
+// 13 digits (old laminated card) or 17 digits (13 prefixed with the birth year)
+func ValidateNIDFormat(nid string) error {
+	if nid == "" {
+		return fmt.Errorf("nid_number is required")
+	}
+	for _, r := range nid {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("nid_number must contain digits only")
+		}
+	}
+
+	switch len(nid) {
+	case 10, 13:
+		return nil
+	case 17:
+		year, _ := strconv.Atoi(nid[:4])
+		if year < 1900 || year > time.Now().Year() {
+			return fmt.Errorf("nid_number has an invalid birth year prefix")
+		}
+		return nil
+	default:
+		return fmt.Errorf("nid_number must be 10, 13 or 17 digits")
+	}
+}
+
+// Last4 returns the last four digits shown in place of the full number
+func Last4(nid string) string {
+	if len(nid) <= 4 {
+		return nid
+	}
+	return nid[len(nid)-4:]
+}
@@ -0,0 +1,203 @@
+package impersonation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/constants"
+	userModel "passport-booking/models/user"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// TokenType marks impersonation tokens so they are never mistaken for SSO tokens
+	TokenType = "impersonation"
+
+	// DefaultTTL is used when the request does not ask for a specific lifetime
+	DefaultTTL = 15 * time.Minute
+)
+
+var (
+	ErrSecretNotSet     = errors.New("IMPERSONATION_SECRET is not set")
+	ErrNotImpersonable  = errors.New("target user has no permissions that can be impersonated")
+	ErrSelfImpersonate  = errors.New("cannot impersonate yourself")
+	ErrInvalidTokenType = errors.New("not an impersonation token")
+)
+
+// ImpersonablePermissions are the only permissions an impersonation token can carry.
+// Admin permissions are never handed out even if the target holds them.
+var ImpersonablePermissions = []string{
+	constants.PermOperatorFull,
+	constants.PermParcelOperatorFull,
+	constants.PermPostmanFull,
+	constants.PermPostOfficeFull,
+}
+
+type contextKey struct{}
+
+func secret() ([]byte, error) {
+	s := os.Getenv("IMPERSONATION_SECRET")
+	if s == "" {
+		return nil, ErrSecretNotSet
+	}
+	return []byte(s), nil
+}
+
+// ScopePermissions keeps the target's permissions that can be impersonated
+func ScopePermissions(permissions []string) []string {
+	allowed := make(map[string]bool, len(ImpersonablePermissions))
+	for _, perm := range ImpersonablePermissions {
+		allowed[perm] = true
+	}
+
+	scoped := make([]string, 0, len(permissions))
+	for _, perm := range permissions {
+		if allowed[perm] {
+			scoped = append(scoped, perm)
+		}
+	}
+	return scoped
+}
+
+// Issue signs a scoped token acting as target and records the session for auditing
+func Issue(db *gorm.DB, admin, target *userModel.User, reason string, ttl time.Duration) (string, *userModel.ImpersonationSession, error) {
+	key, err := secret()
+	if err != nil {
+		return "", nil, err
+	}
+	if admin.ID == target.ID {
+		return "", nil, ErrSelfImpersonate
+	}
+
+	permissions := ScopePermissions(target.Permissions)
+	if len(permissions) == 0 {
+		return "", nil, ErrNotImpersonable
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	session := userModel.ImpersonationSession{
+		TokenID:     tokenID,
+		AdminID:     admin.ID,
+		AdminUUID:   admin.Uuid,
+		TargetID:    target.ID,
+		TargetUUID:  target.Uuid,
+		Reason:      reason,
+		Permissions: permissions,
+		ExpiresAt:   now.Add(ttl),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to record impersonation session: %w", err)
+	}
+
+	claimPermissions := make([]interface{}, len(permissions))
+	for i, perm := range permissions {
+		claimPermissions[i] = perm
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"typ":             TokenType,
+		"jti":             tokenID,
+		"uuid":            target.Uuid,
+		"username":        target.Username,
+		"permissions":     claimPermissions,
+		"impersonated_by": admin.Uuid,
+		"iat":             now.Unix(),
+		"exp":             session.ExpiresAt.Unix(),
+	})
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &session, nil
+}
+
+// IsImpersonationToken reports whether the token is HMAC signed, which SSO tokens never are
+func IsImpersonationToken(tokenString string) bool {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return false
+	}
+	_, ok := token.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// Verify validates an impersonation token and returns its claims
+func Verify(tokenString string) (jwt.MapClaims, error) {
+	key, err := secret()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid impersonation token")
+	}
+	if claims["typ"] != TokenType {
+		return nil, ErrInvalidTokenType
+	}
+	return claims, nil
+}
+
+// WithImpersonator returns a context carrying the UUID of the impersonating admin
+func WithImpersonator(ctx context.Context, adminUUID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, adminUUID)
+}
+
+// ImpersonatorFromContext returns the impersonating admin UUID, if any
+func ImpersonatorFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	adminUUID, ok := ctx.Value(contextKey{}).(string)
+	return adminUUID, ok && adminUUID != ""
+}
+
+// RegisterAuditCallback tags every created row that has an ImpersonatedBy field
+// with the impersonating admin found in the statement context
+func RegisterAuditCallback(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:create").Register("impersonation:tag", func(tx *gorm.DB) {
+		if tx.Statement.Schema == nil {
+			return
+		}
+		adminUUID, ok := ImpersonatorFromContext(tx.Statement.Context)
+		if !ok {
+			return
+		}
+		if field := tx.Statement.Schema.LookUpField("ImpersonatedBy"); field != nil {
+			tx.Statement.SetColumn("ImpersonatedBy", &adminUUID)
+		}
+	})
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,72 @@
+package localtime
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Layouts of times shown to people, in SMS, emails and printouts
+const (
+	DateLayout     = "02 Jan 2006"
+	DateTimeLayout = "02 Jan 2006 15:04"
+)
+
+// DefaultZone is the zone applicants, operators and postmen live in
+const DefaultZone = "Asia/Dhaka"
+
+var (
+	zone     *time.Location
+	zoneOnce sync.Once
+)
+
+// Zone is the zone user-facing times and calendar days are in. APP_TIMEZONE
+// overrides the default of Asia/Dhaka. Bangladesh has no daylight saving, so a
+// fixed +06:00 stands in when the host has no zone database.
+func Zone() *time.Location {
+	zoneOnce.Do(func() {
+		name := os.Getenv("APP_TIMEZONE")
+		if name == "" {
+			name = DefaultZone
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			loc = time.FixedZone(DefaultZone, 6*60*60)
+		}
+		zone = loc
+	})
+	return zone
+}
+
+// UseUTC makes UTC the process zone, so times taken from time.Now or read from
+// the database are UTC and encode as RFC3339 with a Z suffix in API responses.
+// Call it once at startup, before anything reads the clock.
+func UseUTC() {
+	time.Local = time.UTC
+}
+
+// In converts t to the user-facing zone
+func In(t time.Time) time.Time {
+	return t.In(Zone())
+}
+
+// Format renders t as a date and time in the user-facing zone, e.g. 16 Oct 2026 14:05
+func Format(t time.Time) string {
+	return In(t).Format(DateTimeLayout)
+}
+
+// FormatDate renders the calendar day of t in the user-facing zone
+func FormatDate(t time.Time) string {
+	return In(t).Format(DateLayout)
+}
+
+// StartOfDay is midnight of t's calendar day in the user-facing zone
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := In(t).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, Zone())
+}
+
+// ParseDate parses a layout-formatted date or time as user-facing local time
+func ParseDate(layout, value string) (time.Time, error) {
+	return time.ParseInLocation(layout, value, Zone())
+}
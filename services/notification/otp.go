@@ -0,0 +1,121 @@
+package notification
+
+import (
+	"fmt"
+	"math"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	otpModel "passport-booking/models/otp"
+	"passport-booking/services/preference"
+	preferenceTypes "passport-booking/types/preference"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// otpKinds maps each OTP purpose to the kind whose template words its SMS
+var otpKinds = map[otpModel.OTPPurpose]Kind{
+	otpModel.OTPPurposeDeliveryApplyPhone:   KindOTPDeliveryApply,
+	otpModel.OTPPurposeDeliveryConfirmPhone: KindOTPDeliveryConfirm,
+	otpModel.OTPPurposeAddressChange:        KindOTPAddressChange,
+	otpModel.OTPPurposeParcelDelivery:       KindOTPParcelDelivery,
+}
+
+// RenderOTP words the SMS carrying the code of o, valid until its expiry counted from
+// now. Codes tied to a booking carry its barcode suffix and branch name and use the
+// language of the booking's applicant; others go out in the default language.
+func RenderOTP(db *gorm.DB, o *otpModel.OTP, now time.Time) (string, error) {
+	kind, ok := otpKinds[o.Purpose]
+	if !ok {
+		return "", fmt.Errorf("no template for OTP purpose %s", o.Purpose)
+	}
+
+	data := Data{}
+	language := preference.Defaults().Language
+	if o.BookingID != 0 {
+		var err error
+		if data, language, err = codeData(db, o.BookingID); err != nil {
+			return "", err
+		}
+	}
+	if language == "" {
+		language = preferenceTypes.LanguageEnglish
+	}
+	data.OTPCode = o.OTPCode
+	data.ExpiresIn = int(math.Ceil(o.ExpiresAt.Sub(now).Minutes()))
+
+	rendered, err := Render(kind, language, data)
+	if err != nil {
+		return "", err
+	}
+	return rendered.SMS, nil
+}
+
+// RenderOfflineDeliveryCode words the SMS carrying an offline delivery code issued
+// in advance for a booking, in the language of its applicant
+func RenderOfflineDeliveryCode(db *gorm.DB, bookingID uint, code string, validUntil time.Time) (string, error) {
+	data, language, err := codeData(db, bookingID)
+	if err != nil {
+		return "", err
+	}
+	if language == "" {
+		language = preferenceTypes.LanguageEnglish
+	}
+	data.OTPCode = code
+	data.ValidUntil = &validUntil
+
+	rendered, err := Render(KindOfflineDeliveryCode, language, data)
+	if err != nil {
+		return "", err
+	}
+	return rendered.SMS, nil
+}
+
+// codeData fills the booking details a code message refers to and returns the
+// language of the booking's applicant
+func codeData(db *gorm.DB, bookingID uint) (Data, string, error) {
+	var booking bookingModel.Booking
+	if err := db.Select("id", "user_id", "name", "app_or_order_id", "barcode", "delivery_branch_code").
+		First(&booking, bookingID).Error; err != nil {
+		return Data{}, "", fmt.Errorf("failed to load booking %d: %w", bookingID, err)
+	}
+
+	data := Data{Name: booking.Name, AppOrOrderID: booking.AppOrOrderID}
+	if booking.Barcode != nil {
+		data.Barcode = *booking.Barcode
+		data.BarcodeSuffix = barcodeSuffix(*booking.Barcode)
+	}
+	if booking.DeliveryBranchCode != nil && *booking.DeliveryBranchCode != "" {
+		data.DeliveryBranch = *booking.DeliveryBranchCode
+		data.BranchName = branchName(db, *booking.DeliveryBranchCode)
+	}
+
+	prefs, err := preference.Get(db, booking.UserID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load preferences of user %d, using defaults", booking.UserID), err)
+	}
+	return data, prefs.Language, nil
+}
+
+// barcodeSuffix keeps the last four digits of a barcode such as EP123456789BD
+func barcodeSuffix(barcode string) string {
+	digits := make([]rune, 0, len(barcode))
+	for _, r := range barcode {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) > 4 {
+		digits = digits[len(digits)-4:]
+	}
+	return string(digits)
+}
+
+// branchName looks the branch up in the postal hierarchy, falling back to its code
+func branchName(db *gorm.DB, code string) string {
+	var unit bookingModel.OrgUnit
+	if err := db.Select("name").Where("code = ?", code).First(&unit).Error; err != nil || unit.Name == "" {
+		return code
+	}
+	return unit.Name
+}
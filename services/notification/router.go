@@ -0,0 +1,335 @@
+// Package notification sends applicant notifications over the channel they prefer.
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"passport-booking/httpServices/email"
+	"passport-booking/httpServices/sms"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	notificationModel "passport-booking/models/notification"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/localtime"
+	"passport-booking/services/preference"
+	"passport-booking/services/transliterate"
+	preferenceTypes "passport-booking/types/preference"
+	"passport-booking/services/workerpool"
+	"passport-booking/utils"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Notification is one message to an applicant
+type Notification struct {
+	Kind      Kind
+	UserID    uint
+	BookingID uint   // recorded in the delivery log; 0 when not about a booking
+	Phone     string // SMS destination; the user's phone when empty
+	Data      Data
+}
+
+// Router resolves the applicant's channel and sends through it
+type Router struct {
+	DB    *gorm.DB
+	SMS   sms.Sender
+	Email email.Sender
+}
+
+// NewRouter creates a router with the configured SMS and email providers
+func NewRouter(db *gorm.DB) *Router {
+	return &Router{DB: db, SMS: sms.NewSMSService(), Email: email.NewSender()}
+}
+
+// Send delivers n. Email goes out whenever the applicant has an address on file,
+// carrying attachments such as the proof of delivery; SMS goes out when it is their
+// chosen channel or there is no email to use. A "none" preference sends nothing.
+func (r *Router) Send(n Notification) error {
+	var user userModel.User
+	if err := r.DB.First(&user, n.UserID).Error; err != nil {
+		return fmt.Errorf("failed to load user %d: %w", n.UserID, err)
+	}
+
+	prefs, err := preference.Get(r.DB, n.UserID)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to load preferences of user %d, using defaults", n.UserID), err)
+	}
+	if prefs.NotificationChannel == preferenceTypes.ChannelNone {
+		return nil
+	}
+
+	if n.Data.Name == "" {
+		n.Data.Name = user.LegalName
+	}
+	rendered, err := Render(n.Kind, prefs.Language, n.Data)
+	if err != nil {
+		return err
+	}
+
+	entry := notificationModel.NotificationLog{
+		UserID:          n.UserID,
+		Kind:            string(n.Kind),
+		Language:        prefs.Language,
+		TemplateVersion: rendered.TemplateVersion,
+	}
+	if n.BookingID != 0 {
+		entry.BookingID = &n.BookingID
+	}
+	if payload, err := json.Marshal(n.Data); err == nil {
+		entry.Payload = string(payload)
+	}
+
+	hasEmail := user.Email != nil && strings.TrimSpace(*user.Email) != ""
+	var errs []string
+	if hasEmail {
+		e := entry
+		e.Channel, e.Recipient, e.Subject, e.Body = notificationModel.ChannelEmail, *user.Email, rendered.Subject, rendered.Email
+		if err := r.deliver(&e, n.Data); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if prefs.NotificationChannel == preferenceTypes.ChannelSMS || !hasEmail {
+		phone := n.Phone
+		if phone == "" {
+			phone = user.Phone
+		}
+		if phone != "" {
+			e := entry
+			e.Channel, e.Recipient, e.Body = notificationModel.ChannelSMS, phone, rendered.SMS
+			if err := r.deliver(&e, n.Data); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("notification %s to user %d: %s", n.Kind, n.UserID, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// deliver hands the rendered entry to the provider of its channel and records the
+// outcome in the delivery log
+func (r *Router) deliver(entry *notificationModel.NotificationLog, data Data) error {
+	var sendErr error
+	switch entry.Channel {
+	case notificationModel.ChannelEmail:
+		msg := email.Message{To: entry.Recipient, Subject: entry.Subject, Text: entry.Body}
+		if Kind(entry.Kind) == KindProofOfDelivery {
+			msg.Attachments = append(msg.Attachments, proofOfDeliveryPDF(data))
+		}
+		sendErr = r.Email.Send(msg)
+		if sendErr == nil {
+			entry.ProviderResponse = "accepted"
+		}
+	case notificationModel.ChannelSMS:
+		var resp *sms.SMSResponse
+		resp, sendErr = r.SMS.SendSMS(entry.Recipient, entry.Body)
+		if resp != nil {
+			if encoded, err := json.Marshal(resp); err == nil {
+				entry.ProviderResponse = string(encoded)
+			}
+		}
+	default:
+		sendErr = fmt.Errorf("unknown notification channel %s", entry.Channel)
+	}
+
+	entry.Status = notificationModel.DeliveryStatusSent
+	if sendErr != nil {
+		entry.Status = notificationModel.DeliveryStatusFailed
+		entry.Error = sendErr.Error()
+	}
+	if err := r.DB.Create(entry).Error; err != nil {
+		logger.Error(fmt.Sprintf("Failed to record %s notification %s to user %d", entry.Channel, entry.Kind, entry.UserID), err)
+	}
+	return sendErr
+}
+
+// Resend sends a logged notification again on the same channel to the same
+// recipient, recording the new attempt linked to the original
+func (r *Router) Resend(original *notificationModel.NotificationLog, resentByID uint) (*notificationModel.NotificationLog, error) {
+	var data Data
+	if original.Payload != "" {
+		if err := json.Unmarshal([]byte(original.Payload), &data); err != nil {
+			return nil, fmt.Errorf("failed to decode notification payload: %w", err)
+		}
+	}
+
+	entry := notificationModel.NotificationLog{
+		BookingID:       original.BookingID,
+		UserID:          original.UserID,
+		Kind:            original.Kind,
+		Channel:         original.Channel,
+		Recipient:       original.Recipient,
+		Language:        original.Language,
+		TemplateVersion: original.TemplateVersion,
+		Subject:         original.Subject,
+		Body:            original.Body,
+		Payload:         original.Payload,
+		ResendOfID:      &original.ID,
+		ResentByID:      &resentByID,
+	}
+	err := r.deliver(&entry, data)
+	return &entry, err
+}
+
+// proofOfDeliveryPDF renders the delivery record attached to the proof-of-delivery email
+func proofOfDeliveryPDF(d Data) email.Attachment {
+	lines := []string{
+		"Bangladesh Post Office",
+		"Proof of Delivery",
+		strings.Repeat("-", 60),
+		"Application: " + d.AppOrOrderID,
+		"Barcode:     " + d.Barcode,
+	}
+	if d.DeliveredAt != nil {
+		lines = append(lines, "Delivered:   "+localtime.Format(*d.DeliveredAt))
+	}
+	if d.DeliveredTo != "" {
+		lines = append(lines, "Received by: "+d.DeliveredTo)
+	}
+	if d.PostmanName != "" {
+		lines = append(lines, "Postman:     "+d.PostmanName)
+	}
+	if d.DeliveryBranch != "" {
+		lines = append(lines, "Branch:      "+d.DeliveryBranch)
+	}
+	for i, line := range d.AddressLines {
+		label := "             "
+		if i == 0 {
+			label = "Address:     "
+		}
+		lines = append(lines, label+line)
+	}
+	lines = append(lines, "", "Verify at "+d.TrackingURL)
+
+	return email.Attachment{
+		Filename:    fmt.Sprintf("proof-of-delivery-%s.pdf", d.Barcode),
+		ContentType: "application/pdf",
+		Data:        utils.TextPDF("Proof of delivery "+d.Barcode, lines),
+	}
+}
+
+// ForBooking fills the booking fields of the template data
+func ForBooking(b *bookingModel.Booking) Data {
+	d := Data{
+		Name:         b.Name,
+		AppOrOrderID: b.AppOrOrderID,
+		ExpectedDate: b.ExpectedDispatchDate,
+	}
+	if b.Barcode != nil {
+		d.Barcode = *b.Barcode
+		d.TrackingURL = utils.TrackingURL(*b.Barcode)
+	}
+	if b.DeliveryBranchCode != nil {
+		d.DeliveryBranch = *b.DeliveryBranchCode
+	}
+	d.AddressLines = transliterate.Lines(b.DeliveryAddress, transliterate.ScriptLatin)
+	return d
+}
+
+var (
+	defaultRouter *Router
+	initOnce      sync.Once
+	// Applicants are waiting on these, so they go ahead of other background work
+	sendQueue = workerpool.NewQueue("notification", workerpool.PriorityHigh)
+)
+
+// Init sets up the router used by Dispatch
+func Init(db *gorm.DB) {
+	initOnce.Do(func() {
+		defaultRouter = NewRouter(db)
+	})
+}
+
+// Dispatch queues n on the worker pool so the request that triggered it is not held
+// up by the providers. Failures are logged.
+func Dispatch(n Notification) {
+	if defaultRouter == nil {
+		return
+	}
+	// Failures are logged and counted by the pool
+	sendQueue.Go(func() error {
+		return defaultRouter.Send(n)
+	})
+}
+
+var (
+	// ErrLogNotFound is returned when a delivery log entry does not exist
+	ErrLogNotFound = errors.New("notification log not found")
+	// ErrNotFailed is returned when resending a notification that went out
+	ErrNotFailed = errors.New("notification did not fail")
+	// ErrAlreadyResent is returned when a failed notification already has a later attempt
+	ErrAlreadyResent = errors.New("notification was already resent")
+)
+
+// superseded matches log entries that have a later resend attempt
+const superseded = "EXISTS (SELECT 1 FROM notification_logs r WHERE r.resend_of_id = notification_logs.id)"
+
+// ResendLog resends the failed delivery log entry id
+func ResendLog(db *gorm.DB, id, resentByID uint) (*notificationModel.NotificationLog, error) {
+	return ResendLogTo(db, id, resentByID, "")
+}
+
+// ResendLogTo resends the failed delivery log entry id like ResendLog, to recipient
+// instead of the logged one when it is not empty
+func ResendLogTo(db *gorm.DB, id, resentByID uint, recipient string) (*notificationModel.NotificationLog, error) {
+	var original notificationModel.NotificationLog
+	if err := db.First(&original, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLogNotFound
+		}
+		return nil, err
+	}
+	if original.Status != notificationModel.DeliveryStatusFailed {
+		return nil, ErrNotFailed
+	}
+	var count int64
+	if err := db.Model(&notificationModel.NotificationLog{}).Where("resend_of_id = ?", original.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAlreadyResent
+	}
+	if recipient != "" {
+		original.Recipient = recipient
+	}
+
+	return routerFor(db).Resend(&original, resentByID)
+}
+
+// ResendFailedForBooking resends every notification of the booking whose latest
+// attempt failed. It returns the new attempts; failures among them are logged.
+func ResendFailedForBooking(db *gorm.DB, bookingID, resentByID uint) ([]notificationModel.NotificationLog, error) {
+	var failed []notificationModel.NotificationLog
+	if err := db.Where("booking_id = ? AND status = ?", bookingID, notificationModel.DeliveryStatusFailed).
+		Where("NOT " + superseded).
+		Order("id").Find(&failed).Error; err != nil {
+		return nil, err
+	}
+
+	router := routerFor(db)
+	attempts := make([]notificationModel.NotificationLog, 0, len(failed))
+	for i := range failed {
+		attempt, err := router.Resend(&failed[i], resentByID)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Resend of notification %d failed", failed[i].ID), err)
+		}
+		if attempt != nil {
+			attempts = append(attempts, *attempt)
+		}
+	}
+	return attempts, nil
+}
+
+// routerFor returns the shared router, or one bound to db before Init has run
+func routerFor(db *gorm.DB) *Router {
+	if defaultRouter != nil {
+		return defaultRouter
+	}
+	return NewRouter(db)
+}
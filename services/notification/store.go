@@ -0,0 +1,297 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/logger"
+	notificationModel "passport-booking/models/notification"
+	preferenceTypes "passport-booking/types/preference"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultReloadSeconds = 60
+
+var (
+	// ErrUnknownKind is returned for a kind that has no built-in template
+	ErrUnknownKind = errors.New("unknown notification kind")
+	// ErrTemplateNotFound is returned when a template version does not exist
+	ErrTemplateNotFound = errors.New("message template not found")
+)
+
+// InvalidTemplateError reports a template that does not parse or render
+type InvalidTemplateError struct {
+	Part string
+	Err  error
+}
+
+func (e *InvalidTemplateError) Error() string {
+	return fmt.Sprintf("invalid %s template: %v", e.Part, e.Err)
+}
+
+var (
+	overrides   = map[Kind]map[string]Content{}
+	overridesMu sync.RWMutex
+)
+
+// lookup returns the text of kind in language: the active managed template first,
+// then the built-in one, then the same in English
+func lookup(kind Kind, language string) (Content, bool) {
+	overridesMu.RLock()
+	defer overridesMu.RUnlock()
+
+	for _, lang := range []string{language, preferenceTypes.LanguageEnglish} {
+		if c, ok := overrides[kind][lang]; ok {
+			return c, true
+		}
+		if c, ok := templates[kind][lang]; ok {
+			return c, true
+		}
+	}
+	return Content{}, false
+}
+
+// Reload replaces the managed templates in memory with the active versions in the DB
+func Reload(db *gorm.DB) error {
+	var rows []notificationModel.MessageTemplate
+	if err := db.Where("active = ?", true).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	loaded := map[Kind]map[string]Content{}
+	for _, row := range rows {
+		kind := Kind(row.Kind)
+		if loaded[kind] == nil {
+			loaded[kind] = map[string]Content{}
+		}
+		loaded[kind][row.Language] = Content{Subject: row.Subject, Email: row.Email, SMS: row.SMS, Version: row.Version}
+	}
+
+	overridesMu.Lock()
+	overrides = loaded
+	overridesMu.Unlock()
+	return nil
+}
+
+// StartTemplateReloader reloads the managed templates every
+// NOTIFICATION_TEMPLATE_RELOAD_SECONDS (default 60) so edits made on another instance
+// are picked up. The returned function stops the job.
+func StartTemplateReloader(db *gorm.DB) func() {
+	seconds := defaultReloadSeconds
+	if v, err := strconv.Atoi(os.Getenv("NOTIFICATION_TEMPLATE_RELOAD_SECONDS")); err == nil && v > 0 {
+		seconds = v
+	}
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	done := make(chan struct{})
+
+	run := func() {
+		if err := Reload(db); err != nil {
+			logger.Error("Failed to reload notification templates", err)
+		}
+	}
+
+	go func() {
+		run()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// Kinds lists the notification kinds that can be templated
+func Kinds() []Kind {
+	return []Kind{KindBookingConfirmation, KindDeliverySchedule, KindProofOfDelivery, KindAccountApproved, KindAccountRejected,
+		KindOTPDeliveryApply, KindOTPDeliveryConfirm, KindOTPAddressChange, KindOTPParcelDelivery, KindOfflineDeliveryCode,
+		KindPostmasterDigest}
+}
+
+// Known reports whether kind has a built-in template
+func Known(kind Kind) bool {
+	_, ok := templates[kind]
+	return ok
+}
+
+// Builtin returns the built-in text of kind in language
+func Builtin(kind Kind, language string) (Content, bool) {
+	c, ok := templates[kind][language]
+	return c, ok
+}
+
+// Variables lists the fields templates can refer to as {{.Name}}
+func Variables() []string {
+	t := reflect.TypeOf(Data{})
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		names = append(names, t.Field(i).Name)
+	}
+	return names
+}
+
+// sampleData exercises every variable so unknown fields or bad calls surface on save
+func sampleData() Data {
+	now := time.Now()
+	return Data{
+		Name:           "Rahim Uddin",
+		AppOrOrderID:   "OID1234567890",
+		Barcode:        "EP123456789BD",
+		TrackingURL:    "https://ekdak.com/track/EP123456789BD",
+		ExpectedDate:   &now,
+		PostmanName:    "Karim Mia",
+		PostmanPhone:   "01700000000",
+		DeliveredAt:    &now,
+		DeliveredTo:    "Rahim Uddin",
+		DeliveryBranch: "1000",
+		Reason:         "Verified with the branch roster",
+		OTPCode:        "123456",
+		ExpiresIn:      5,
+		BarcodeSuffix:  "6789",
+		BranchName:     "Dhaka GPO",
+		ValidUntil:     &now,
+		DigestDate:     &now,
+		PendingBags:    3,
+		AgedItems:      2,
+		AgedDays:       7,
+		Escalations:    1,
+		OTPBlocks:      4,
+		DigestLines:    []string{"EP123456789BD  received_by_postman  9 days"},
+	}
+}
+
+// Preview renders the given text with sample data, reporting the first part that
+// does not parse or render
+func Preview(kind Kind, subject, email, sms string) (Rendered, error) {
+	return execute(kind, Content{Subject: subject, Email: email, SMS: sms}, sampleData())
+}
+
+// SaveVersion stores the text as the next version of kind in language. With activate
+// the new version replaces the active one immediately.
+func SaveVersion(db *gorm.DB, kind Kind, language, subject, email, sms, note string, userID uint, activate bool) (*notificationModel.MessageTemplate, error) {
+	if !Known(kind) {
+		return nil, ErrUnknownKind
+	}
+	if _, err := Preview(kind, subject, email, sms); err != nil {
+		return nil, err
+	}
+
+	tmpl := notificationModel.MessageTemplate{
+		Kind:        string(kind),
+		Language:    language,
+		Subject:     subject,
+		Email:       email,
+		SMS:         sms,
+		Note:        note,
+		CreatedByID: userID,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Serializes concurrent saves of the same kind and language on the version number
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "message_template:"+string(kind)+":"+language).Error; err != nil {
+			return err
+		}
+		var latest int
+		if err := tx.Model(&notificationModel.MessageTemplate{}).
+			Where("kind = ? AND language = ?", kind, language).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		tmpl.Version = latest + 1
+
+		if activate {
+			if err := deactivate(tx, string(kind), language); err != nil {
+				return err
+			}
+			tmpl.Active = true
+		}
+		return tx.Create(&tmpl).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if activate {
+		reloadNow(db)
+	}
+	return &tmpl, nil
+}
+
+// Activate makes the template version id the one in use for its kind and language
+func Activate(db *gorm.DB, id uint) (*notificationModel.MessageTemplate, error) {
+	var tmpl notificationModel.MessageTemplate
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tmpl, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTemplateNotFound
+			}
+			return err
+		}
+		if err := deactivate(tx, tmpl.Kind, tmpl.Language); err != nil {
+			return err
+		}
+		tmpl.Active = true
+		return tx.Save(&tmpl).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reloadNow(db)
+	return &tmpl, nil
+}
+
+// Reset deactivates the managed versions of kind in language so the built-in text is
+// used again. Versions are kept for history.
+func Reset(db *gorm.DB, kind Kind, language string) error {
+	if err := deactivate(db, string(kind), language); err != nil {
+		return err
+	}
+	reloadNow(db)
+	return nil
+}
+
+// Versions returns every stored version of kind in language, newest first
+func Versions(db *gorm.DB, kind Kind, language string) ([]notificationModel.MessageTemplate, error) {
+	var rows []notificationModel.MessageTemplate
+	err := db.Where("kind = ? AND language = ?", kind, language).Order("version DESC").Find(&rows).Error
+	return rows, err
+}
+
+// Active returns the text currently used for kind in language and the managed
+// version it comes from, nil when the built-in text is in use
+func Active(db *gorm.DB, kind Kind, language string) (Content, *notificationModel.MessageTemplate, error) {
+	var row notificationModel.MessageTemplate
+	err := db.Where("kind = ? AND language = ? AND active = ?", kind, language, true).First(&row).Error
+	if err == nil {
+		return Content{Subject: row.Subject, Email: row.Email, SMS: row.SMS, Version: row.Version}, &row, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return Content{}, nil, err
+	}
+	c, _ := Builtin(kind, language)
+	return c, nil, nil
+}
+
+func deactivate(db *gorm.DB, kind, language string) error {
+	return db.Model(&notificationModel.MessageTemplate{}).
+		Where("kind = ? AND language = ? AND active = ?", kind, language, true).
+		Update("active", false).Error
+}
+
+// reloadNow applies a change on this instance without waiting for the reloader
+func reloadNow(db *gorm.DB) {
+	if err := Reload(db); err != nil {
+		logger.Error("Failed to reload notification templates", err)
+	}
+}
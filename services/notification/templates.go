@@ -0,0 +1,346 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"passport-booking/services/localtime"
+	preferenceTypes "passport-booking/types/preference"
+	"text/template"
+	"time"
+)
+
+// Kind names a notification an applicant or staff member can receive
+type Kind string
+
+const (
+	KindBookingConfirmation Kind = "booking_confirmation"
+	KindDeliverySchedule    Kind = "delivery_schedule"
+	KindProofOfDelivery     Kind = "proof_of_delivery"
+	KindAccountApproved     Kind = "account_approved"
+	KindAccountRejected     Kind = "account_rejected"
+
+	// One-time codes, one kind per OTP purpose
+	KindOTPDeliveryApply   Kind = "otp_delivery_phone_apply"
+	KindOTPDeliveryConfirm Kind = "otp_delivery_phone_confirm"
+	KindOTPAddressChange   Kind = "otp_address_change"
+	KindOTPParcelDelivery  Kind = "otp_parcel_delivery"
+
+	// Code issued in advance for delivery to an address without coverage
+	KindOfflineDeliveryCode Kind = "offline_delivery_code"
+
+	// Morning summary of a postmaster's branches
+	KindPostmasterDigest Kind = "postmaster_digest"
+)
+
+// Data is what the templates can refer to
+type Data struct {
+	Name           string
+	AppOrOrderID   string
+	Barcode        string
+	TrackingURL    string
+	ExpectedDate   *time.Time
+	PostmanName    string
+	PostmanPhone   string
+	DeliveredAt    *time.Time
+	DeliveredTo    string
+	DeliveryBranch string
+	AddressLines   []string   // delivery address, romanized for the text-only PDF
+	Reason         string     // reviewer's note on a registration decision
+	OTPCode        string     // one-time code of an OTP message
+	ExpiresIn      int        // minutes the one-time code stays valid
+	BarcodeSuffix  string     // last digits of the barcode, enough to tell parcels apart
+	BranchName     string     // name of the delivery branch, its code when unnamed
+	ValidUntil     *time.Time // last day a code issued in advance can be used
+	DigestDate     *time.Time // day a postmaster digest was put together
+	PendingBags    int        // bags dispatched to the postmaster's branches, not yet received
+	AgedItems      int        // items at the branches without progress for AgedDays
+	AgedDays       int
+	Escalations    int      // deliveries held for supervisor review
+	OTPBlocks      int      // OTPs blocked after too many wrong codes in the last day
+	DigestLines    []string // oldest aged items, one per line
+}
+
+// Content is the text of one kind in one language
+type Content struct {
+	Subject string `json:"subject"`
+	Email   string `json:"email"`
+	SMS     string `json:"sms"`
+	Version int    `json:"-"` // managed template version, 0 for the built-in text
+}
+
+var templates = map[Kind]map[string]Content{
+	KindBookingConfirmation: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Passport delivery booked - {{.Barcode}}",
+			Email: `Dear {{.Name}},
+
+Your passport delivery for application {{.AppOrOrderID}} has been booked with Bangladesh Post Office.
+
+Barcode: {{.Barcode}}
+{{if .ExpectedDate}}Expected dispatch: {{date .ExpectedDate}}
+{{end}}Track it at {{.TrackingURL}}
+
+Bangladesh Post Office`,
+			SMS: "Passport delivery booked. Barcode {{.Barcode}}. Track: {{.TrackingURL}}",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "পাসপোর্ট ডেলিভারি বুকিং সম্পন্ন - {{.Barcode}}",
+			Email: `প্রিয় {{.Name}},
+
+আবেদন {{.AppOrOrderID}} এর পাসপোর্ট ডেলিভারি বাংলাদেশ ডাক বিভাগে বুক করা হয়েছে।
+
+বারকোড: {{.Barcode}}
+{{if .ExpectedDate}}সম্ভাব্য প্রেরণ: {{date .ExpectedDate}}
+{{end}}ট্র্যাক করুন: {{.TrackingURL}}
+
+বাংলাদেশ ডাক বিভাগ`,
+			SMS: "পাসপোর্ট ডেলিভারি বুক হয়েছে। বারকোড {{.Barcode}}। ট্র্যাক: {{.TrackingURL}}",
+		},
+	},
+	KindDeliverySchedule: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Your passport is out for delivery - {{.Barcode}}",
+			Email: `Dear {{.Name}},
+
+Your passport ({{.Barcode}}) is with postman {{.PostmanName}}{{if .PostmanPhone}} ({{.PostmanPhone}}){{end}} and will be delivered soon.
+Please keep your phone available; you will need the one-time code sent to it to receive the passport.
+
+Track it at {{.TrackingURL}}
+
+Bangladesh Post Office`,
+			SMS: "Your passport {{.Barcode}} is out for delivery with postman {{.PostmanName}}{{if .PostmanPhone}} ({{.PostmanPhone}}){{end}}.",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "আপনার পাসপোর্ট ডেলিভারির পথে - {{.Barcode}}",
+			Email: `প্রিয় {{.Name}},
+
+আপনার পাসপোর্ট ({{.Barcode}}) পোস্টম্যান {{.PostmanName}}{{if .PostmanPhone}} ({{.PostmanPhone}}){{end}} এর কাছে আছে এবং শীঘ্রই পৌঁছে দেওয়া হবে।
+পাসপোর্ট গ্রহণের সময় আপনার ফোনে পাঠানো ওটিপি প্রয়োজন হবে।
+
+ট্র্যাক করুন: {{.TrackingURL}}
+
+বাংলাদেশ ডাক বিভাগ`,
+			SMS: "আপনার পাসপোর্ট {{.Barcode}} পোস্টম্যান {{.PostmanName}} এর মাধ্যমে ডেলিভারির পথে।",
+		},
+	},
+	KindProofOfDelivery: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Passport delivered - {{.Barcode}}",
+			Email: `Dear {{.Name}},
+
+Your passport ({{.Barcode}}) was delivered{{if .DeliveredAt}} on {{datetime .DeliveredAt}}{{end}}{{if .PostmanName}} by postman {{.PostmanName}}{{end}}.
+The proof of delivery is attached. If you did not receive it, contact your post office with this email.
+
+Bangladesh Post Office`,
+			SMS: "Your passport {{.Barcode}} has been delivered. Proof: {{.TrackingURL}}",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "পাসপোর্ট ডেলিভারি সম্পন্ন - {{.Barcode}}",
+			Email: `প্রিয় {{.Name}},
+
+আপনার পাসপোর্ট ({{.Barcode}}){{if .DeliveredAt}} {{datetime .DeliveredAt}} তারিখে{{end}} ডেলিভারি করা হয়েছে।
+ডেলিভারির প্রমাণপত্র সংযুক্ত করা হলো। পাসপোর্ট না পেয়ে থাকলে এই ইমেইলসহ আপনার ডাকঘরে যোগাযোগ করুন।
+
+বাংলাদেশ ডাক বিভাগ`,
+			SMS: "আপনার পাসপোর্ট {{.Barcode}} ডেলিভারি করা হয়েছে। প্রমাণ: {{.TrackingURL}}",
+		},
+	},
+	KindAccountApproved: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Your operator account is approved",
+			Email: `Dear {{.Name}},
+
+Your registration for the passport booking service has been approved{{if .DeliveryBranch}} for branch {{.DeliveryBranch}}{{end}}. You can now sign in.
+{{if .Reason}}
+Note from the reviewer: {{.Reason}}
+{{end}}
+Bangladesh Post Office`,
+			SMS: "Your passport booking operator account is approved{{if .DeliveryBranch}} for branch {{.DeliveryBranch}}{{end}}. You can now sign in.",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "আপনার অপারেটর অ্যাকাউন্ট অনুমোদিত হয়েছে",
+			Email: `প্রিয় {{.Name}},
+
+পাসপোর্ট বুকিং সেবায় আপনার নিবন্ধন{{if .DeliveryBranch}} শাখা {{.DeliveryBranch}} এর জন্য{{end}} অনুমোদিত হয়েছে। এখন আপনি লগইন করতে পারবেন।
+{{if .Reason}}
+পর্যালোচকের মন্তব্য: {{.Reason}}
+{{end}}
+বাংলাদেশ ডাক বিভাগ`,
+			SMS: "আপনার পাসপোর্ট বুকিং অপারেটর অ্যাকাউন্ট অনুমোদিত হয়েছে। এখন লগইন করতে পারবেন।",
+		},
+	},
+	KindAccountRejected: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Your operator registration was not approved",
+			Email: `Dear {{.Name}},
+
+Your registration for the passport booking service was not approved.
+{{if .Reason}}Reason: {{.Reason}}
+{{end}}
+Please contact your postmaster if you think this is a mistake.
+
+Bangladesh Post Office`,
+			SMS: "Your passport booking operator registration was not approved.{{if .Reason}} Reason: {{.Reason}}{{end}}",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "আপনার অপারেটর নিবন্ধন অনুমোদিত হয়নি",
+			Email: `প্রিয় {{.Name}},
+
+পাসপোর্ট বুকিং সেবায় আপনার নিবন্ধন অনুমোদিত হয়নি।
+{{if .Reason}}কারণ: {{.Reason}}
+{{end}}
+ভুল মনে হলে আপনার পোস্টমাস্টারের সাথে যোগাযোগ করুন।
+
+বাংলাদেশ ডাক বিভাগ`,
+			SMS: "আপনার পাসপোর্ট বুকিং অপারেটর নিবন্ধন অনুমোদিত হয়নি।{{if .Reason}} কারণ: {{.Reason}}{{end}}",
+		},
+	},
+	KindOTPDeliveryApply: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Your verification code",
+			Email:   "Your code to verify the delivery phone of passport {{.AppOrOrderID}} is {{.OTPCode}}. It expires in {{.ExpiresIn}} minutes. Do not share it with anyone.",
+			SMS:     "{{.OTPCode}} is your code to verify the delivery phone for passport delivery{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}}. Valid {{.ExpiresIn}} min. Do not share it.",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "আপনার যাচাইকরণ কোড",
+			Email:   "পাসপোর্ট {{.AppOrOrderID}} এর ডেলিভারি ফোন যাচাইয়ের কোড {{.OTPCode}}। কোডটি {{.ExpiresIn}} মিনিট বৈধ। কারো সাথে শেয়ার করবেন না।",
+			SMS:     "পাসপোর্ট ডেলিভারি{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}} এর ফোন যাচাইয়ের কোড {{.OTPCode}}। {{.ExpiresIn}} মিনিট বৈধ। কাউকে জানাবেন না।",
+		},
+	},
+	KindOTPDeliveryConfirm: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Your passport delivery code",
+			Email:   "Give code {{.OTPCode}} to the postman{{if .BranchName}} from {{.BranchName}}{{end}} only when you receive passport {{.AppOrOrderID}}. It expires in {{.ExpiresIn}} minutes.",
+			SMS:     "{{.OTPCode}} is your code to receive passport{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}}{{if .BranchName}} from {{.BranchName}}{{end}}. Give it to the postman only on delivery. Valid {{.ExpiresIn}} min.",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "আপনার পাসপোর্ট ডেলিভারি কোড",
+			Email:   "পাসপোর্ট {{.AppOrOrderID}} হাতে পাওয়ার পরই{{if .BranchName}} {{.BranchName}} এর{{end}} পোস্টম্যানকে কোড {{.OTPCode}} দিন। কোডটি {{.ExpiresIn}} মিনিট বৈধ।",
+			SMS:     "পাসপোর্ট{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}}{{if .BranchName}} ({{.BranchName}}){{end}} গ্রহণের কোড {{.OTPCode}}। হাতে পেলে তবেই পোস্টম্যানকে দিন। {{.ExpiresIn}} মিনিট বৈধ।",
+		},
+	},
+	KindOTPAddressChange: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Confirm your delivery address change",
+			Email:   "Your code to change the delivery address of passport {{.AppOrOrderID}} is {{.OTPCode}}. It expires in {{.ExpiresIn}} minutes. Ignore this if you did not ask for a change.",
+			SMS:     "{{.OTPCode}} is your code to change the delivery address of passport{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}}. Valid {{.ExpiresIn}} min. Ignore if you did not ask for it.",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "ডেলিভারি ঠিকানা পরিবর্তন নিশ্চিত করুন",
+			Email:   "পাসপোর্ট {{.AppOrOrderID}} এর ডেলিভারি ঠিকানা পরিবর্তনের কোড {{.OTPCode}}। কোডটি {{.ExpiresIn}} মিনিট বৈধ। আপনি অনুরোধ না করে থাকলে উপেক্ষা করুন।",
+			SMS:     "পাসপোর্ট{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}} এর ঠিকানা পরিবর্তনের কোড {{.OTPCode}}। {{.ExpiresIn}} মিনিট বৈধ। অনুরোধ না করলে উপেক্ষা করুন।",
+		},
+	},
+	KindOTPParcelDelivery: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Your parcel delivery code",
+			Email:   "Give code {{.OTPCode}} to the postman only when you receive your parcel. It expires in {{.ExpiresIn}} minutes.",
+			SMS:     "{{.OTPCode}} is your code to receive parcel{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}}. Give it to the postman only on delivery. Valid {{.ExpiresIn}} min.",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "আপনার পার্সেল ডেলিভারি কোড",
+			Email:   "পার্সেল হাতে পাওয়ার পরই পোস্টম্যানকে কোড {{.OTPCode}} দিন। কোডটি {{.ExpiresIn}} মিনিট বৈধ।",
+			SMS:     "পার্সেল{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}} গ্রহণের কোড {{.OTPCode}}। হাতে পেলে তবেই পোস্টম্যানকে দিন। {{.ExpiresIn}} মিনিট বৈধ।",
+		},
+	},
+	KindOfflineDeliveryCode: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Your passport delivery code",
+			Email:   "Your address has no mobile coverage, so keep code {{.OTPCode}} for your passport{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}}. Give it to the postman only when you receive it.{{if .ValidUntil}} Valid until {{date .ValidUntil}}.{{end}}",
+			SMS:     "Keep code {{.OTPCode}} to receive passport{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}} where there is no network. Give it to the postman only on delivery.{{if .ValidUntil}} Valid until {{date .ValidUntil}}.{{end}}",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "আপনার পাসপোর্ট ডেলিভারি কোড",
+			Email:   "আপনার ঠিকানায় মোবাইল নেটওয়ার্ক নেই, তাই পাসপোর্ট{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}} গ্রহণের জন্য কোড {{.OTPCode}} সংরক্ষণ করুন। হাতে পেলে তবেই পোস্টম্যানকে দিন।{{if .ValidUntil}} {{date .ValidUntil}} পর্যন্ত বৈধ।{{end}}",
+			SMS:     "নেটওয়ার্কহীন এলাকায় পাসপোর্ট{{if .BarcodeSuffix}} ...{{.BarcodeSuffix}}{{end}} গ্রহণের কোড {{.OTPCode}} রেখে দিন। হাতে পেলে তবেই পোস্টম্যানকে দিন।{{if .ValidUntil}} {{date .ValidUntil}} পর্যন্ত বৈধ।{{end}}",
+		},
+	},
+	KindPostmasterDigest: {
+		preferenceTypes.LanguageEnglish: {
+			Subject: "Branch digest {{date .DigestDate}} - {{.BranchName}}",
+			Email: `Dear {{.Name}},
+
+Summary for {{.BranchName}} on {{date .DigestDate}}:
+
+Bags dispatched to you, not yet received: {{.PendingBags}}
+Items at the branch with no progress for {{.AgedDays}}+ days: {{.AgedItems}}
+Deliveries held for supervisor review: {{.Escalations}}
+OTPs blocked after wrong codes in the last 24 hours: {{.OTPBlocks}}
+{{if .DigestLines}}
+Oldest items:
+{{range .DigestLines}}  {{.}}
+{{end}}{{end}}
+You can turn this digest off in your notification preferences.
+
+Bangladesh Post Office`,
+			SMS: "{{.BranchName}} {{date .DigestDate}}: {{.PendingBags}} bags pending, {{.AgedItems}} items {{.AgedDays}}+ days old, {{.Escalations}} escalations, {{.OTPBlocks}} OTP blocks.",
+		},
+		preferenceTypes.LanguageBangla: {
+			Subject: "শাখা সারসংক্ষেপ {{date .DigestDate}} - {{.BranchName}}",
+			Email: `প্রিয় {{.Name}},
+
+{{date .DigestDate}} তারিখে {{.BranchName}} এর সারসংক্ষেপ:
+
+প্রেরিত কিন্তু এখনও গৃহীত হয়নি এমন ব্যাগ: {{.PendingBags}}
+{{.AgedDays}}+ দিন ধরে অগ্রগতি নেই এমন আইটেম: {{.AgedItems}}
+সুপারভাইজারের পর্যালোচনায় আটকে থাকা ডেলিভারি: {{.Escalations}}
+গত ২৪ ঘণ্টায় ভুল কোডের কারণে ব্লক হওয়া ওটিপি: {{.OTPBlocks}}
+{{if .DigestLines}}
+সবচেয়ে পুরনো আইটেম:
+{{range .DigestLines}}  {{.}}
+{{end}}{{end}}
+নোটিফিকেশন সেটিংস থেকে এই সারসংক্ষেপ বন্ধ করা যাবে।
+
+বাংলাদেশ ডাক বিভাগ`,
+			SMS: "{{.BranchName}} {{date .DigestDate}}: অপেক্ষমাণ ব্যাগ {{.PendingBags}}, {{.AgedDays}}+ দিনের আইটেম {{.AgedItems}}, এসকেলেশন {{.Escalations}}, ওটিপি ব্লক {{.OTPBlocks}}।",
+		},
+	},
+}
+
+var funcs = template.FuncMap{
+	"date":     func(t *time.Time) string { return localtime.FormatDate(*t) },
+	"datetime": func(t *time.Time) string { return localtime.Format(*t) },
+}
+
+// Rendered is the text of a notification ready to send
+type Rendered struct {
+	Subject         string
+	Email           string
+	SMS             string
+	TemplateVersion int
+}
+
+// Render fills the templates of kind in language, falling back to English. Templates
+// managed through the API take precedence over the built-in text.
+func Render(kind Kind, language string, data Data) (Rendered, error) {
+	c, ok := lookup(kind, language)
+	if !ok {
+		return Rendered{}, fmt.Errorf("no template for notification %s", kind)
+	}
+	return execute(kind, c, data)
+}
+
+// execute fills the parts of c with data
+func execute(kind Kind, c Content, data Data) (Rendered, error) {
+	out := Rendered{TemplateVersion: c.Version}
+	for _, part := range []struct {
+		name string
+		src  string
+		dst  *string
+	}{
+		{"subject", c.Subject, &out.Subject},
+		{"email", c.Email, &out.Email},
+		{"sms", c.SMS, &out.SMS},
+	} {
+		tmpl, err := template.New(string(kind) + "." + part.name).Funcs(funcs).Parse(part.src)
+		if err != nil {
+			return Rendered{}, &InvalidTemplateError{Part: part.name, Err: err}
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return Rendered{}, &InvalidTemplateError{Part: part.name, Err: err}
+		}
+		*part.dst = buf.String()
+	}
+	return out, nil
+}
@@ -0,0 +1,32 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	bookingModel "passport-booking/models/booking"
+)
+
+// BenchmarkBookingConfirmationRender renders the message sent when an item is booked
+func BenchmarkBookingConfirmationRender(b *testing.B) {
+	barcode := "EB123456789BD"
+	branch := "LT-OFFICE"
+	dispatch := time.Now().AddDate(0, 0, 1)
+	data := ForBooking(&bookingModel.Booking{
+		ID:                   42,
+		AppOrOrderID:         "OID1234567890",
+		Barcode:              &barcode,
+		Name:                 "Load Test Applicant",
+		DeliveryBranchCode:   &branch,
+		Status:               bookingModel.BookingStatusBooked,
+		ExpectedDispatchDate: &dispatch,
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Render(KindBookingConfirmation, "bn", data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
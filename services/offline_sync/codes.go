@@ -0,0 +1,196 @@
+package offline_sync
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"passport-booking/config"
+	"passport-booking/models/address"
+	bookingModel "passport-booking/models/booking"
+	deliveryTypes "passport-booking/types/delivery"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	codeDigits             = 8
+	defaultCodeTTLDays     = 7
+	defaultCodeMaxAttempts = 5
+)
+
+var (
+	// ErrNotNoNetwork is returned for bookings whose address is not flagged as without coverage
+	ErrNotNoNetwork = errors.New("the booking's delivery address is not flagged as no-network")
+	// ErrNoCode is returned when the booking has no usable offline code
+	ErrNoCode = errors.New("no offline delivery code was issued for this booking")
+	// ErrCodeExpired is returned for a code entered after it expired
+	ErrCodeExpired = errors.New("offline delivery code had expired when it was entered")
+	// ErrCodeLocked is returned once a code has seen too many wrong entries
+	ErrCodeLocked = errors.New("offline delivery code is locked after too many wrong entries")
+	// ErrCodeInvalid is returned for a wrong code
+	ErrCodeInvalid = errors.New("invalid offline delivery code")
+	// ErrCodeSelfIssued is returned when the user who was handed a printed code
+	// enters it, since the applicant never took part
+	ErrCodeSelfIssued = errors.New("offline delivery code was printed by this user and must be entered by someone else")
+)
+
+// CodeTTL is how long an offline delivery code stays valid
+// (OFFLINE_DELIVERY_CODE_TTL_DAYS, default 7)
+func CodeTTL() time.Duration {
+	return time.Duration(envInt("OFFLINE_DELIVERY_CODE_TTL_DAYS", defaultCodeTTLDays)) * 24 * time.Hour
+}
+
+// CodeMaxAttempts is how many wrong entries lock a code
+// (OFFLINE_DELIVERY_CODE_MAX_ATTEMPTS, default 5)
+func CodeMaxAttempts() int {
+	return envInt("OFFLINE_DELIVERY_CODE_MAX_ATTEMPTS", defaultCodeMaxAttempts)
+}
+
+// NoNetwork reports whether the booking's delivery address is flagged as without coverage
+func NoNetwork(db *gorm.DB, booking *bookingModel.Booking) (bool, error) {
+	if booking.DeliveryAddressID == nil {
+		return false, nil
+	}
+	var addr address.Address
+	if err := db.Select("no_network").First(&addr, *booking.DeliveryAddressID).Error; err != nil {
+		return false, err
+	}
+	return addr.NoNetwork, nil
+}
+
+// SetNoNetwork flags or clears the booking's delivery address as without coverage
+func SetNoNetwork(db *gorm.DB, booking *bookingModel.Booking, noNetwork bool) error {
+	if booking.DeliveryAddressID == nil {
+		return errors.New("booking has no delivery address")
+	}
+	return db.Model(&address.Address{}).Where("id = ?", *booking.DeliveryAddressID).
+		Update("no_network", noNetwork).Error
+}
+
+// hashCode keys the hash with OFFLINE_SYNC_SECRET and the booking, so a leaked
+// table cannot be brute-forced offline or a code replayed on another booking
+func hashCode(bookingID uint, code string) (string, error) {
+	secret := config.Secret("OFFLINE_SYNC_SECRET")
+	if secret == "" {
+		return "", ErrDisabled
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("offline-code:" + strconv.FormatUint(uint64(bookingID), 10) + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func generateCode() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(codeDigits), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", codeDigits, n), nil
+}
+
+// IssueCode creates a fresh offline delivery code for a booking whose address is
+// flagged no-network, revoking any earlier one. The plain code is returned once
+// for sending or printing and never stored.
+func IssueCode(db *gorm.DB, booking *bookingModel.Booking, channel string, issuedByID uint, now time.Time) (string, *bookingModel.OfflineDeliveryCode, error) {
+	noNetwork, err := NoNetwork(db, booking)
+	if err != nil {
+		return "", nil, err
+	}
+	if !noNetwork {
+		return "", nil, ErrNotNoNetwork
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate code: %w", err)
+	}
+	hash, err := hashCode(booking.ID, code)
+	if err != nil {
+		return "", nil, err
+	}
+
+	record := bookingModel.OfflineDeliveryCode{
+		BookingID:  booking.ID,
+		CodeHash:   hash,
+		Channel:    channel,
+		IssuedByID: issuedByID,
+		ExpiresAt:  now.Add(CodeTTL()),
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&bookingModel.OfflineDeliveryCode{}).
+			Where("booking_id = ? AND used_at IS NULL AND revoked_at IS NULL", booking.ID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return code, &record, nil
+}
+
+// RedeemCode checks a code the postman entered at enteredAt against the booking's
+// current code and uses it up on a match. Wrong entries count towards
+// CodeMaxAttempts. A printed code cannot be redeemed by the user who issued it,
+// as the plain code was returned to them.
+func RedeemCode(db *gorm.DB, bookingID uint, code string, postmanID uint, enteredAt time.Time) error {
+	var record bookingModel.OfflineDeliveryCode
+	err := db.Where("booking_id = ? AND used_at IS NULL AND revoked_at IS NULL", bookingID).
+		Order("created_at DESC").First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNoCode
+	}
+	if err != nil {
+		return err
+	}
+	if enteredAt.Before(record.CreatedAt) {
+		return ErrNoCode
+	}
+	if record.Channel == deliveryTypes.OfflineCodeChannelPrint && record.IssuedByID == postmanID {
+		return ErrCodeSelfIssued
+	}
+	if !enteredAt.Before(record.ExpiresAt) {
+		return ErrCodeExpired
+	}
+	if record.Attempts >= CodeMaxAttempts() {
+		return ErrCodeLocked
+	}
+
+	hash, err := hashCode(bookingID, code)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(record.CodeHash)) != 1 {
+		if err := db.Model(&record).Update("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+			return err
+		}
+		return ErrCodeInvalid
+	}
+
+	// Guarded so two syncs entering the same code cannot both use it
+	result := db.Model(&record).Where("used_at IS NULL AND revoked_at IS NULL").
+		Updates(map[string]interface{}{"used_at": time.Now(), "used_by_id": postmanID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoCode
+	}
+	return nil
+}
+
+// IsCodeError reports whether err is a problem with the entered code rather than
+// a failure to check it
+func IsCodeError(err error) bool {
+	return errors.Is(err, ErrNoCode) || errors.Is(err, ErrCodeExpired) ||
+		errors.Is(err, ErrCodeLocked) || errors.Is(err, ErrCodeInvalid) ||
+		errors.Is(err, ErrCodeSelfIssued)
+}
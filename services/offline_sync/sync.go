@@ -0,0 +1,146 @@
+// Package offline_sync authenticates bundles of delivery actions postmen performed
+// without connectivity and keeps one result per action, so a bundle can be sent
+// again safely after a dropped connection.
+package offline_sync
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/config"
+	bookingModel "passport-booking/models/booking"
+	deliveryTypes "passport-booking/types/delivery"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// ResultPending marks an action whose sync is in progress
+	ResultPending = "pending"
+
+	defaultMaxActions  = 200
+	defaultMaxAgeHours = 72
+	// clockSkew is how far in the future a device clock may run
+	clockSkew = 5 * time.Minute
+	// pendingTimeout releases claims of syncs that never finished
+	pendingTimeout = 10 * time.Minute
+)
+
+var (
+	// ErrDisabled is returned when OFFLINE_SYNC_SECRET is not configured
+	ErrDisabled = errors.New("offline sync is not configured")
+	// ErrInvalidSignature is returned for a bundle not signed with the postman's key
+	ErrInvalidSignature = errors.New("invalid bundle signature")
+)
+
+// MaxActions caps the actions in one bundle (OFFLINE_SYNC_MAX_ACTIONS, default 200)
+func MaxActions() int {
+	return envInt("OFFLINE_SYNC_MAX_ACTIONS", defaultMaxActions)
+}
+
+// MaxAge is how long after it was performed an action can still be synced
+// (OFFLINE_SYNC_MAX_AGE_HOURS, default 72)
+func MaxAge() time.Duration {
+	return time.Duration(envInt("OFFLINE_SYNC_MAX_AGE_HOURS", defaultMaxAgeHours)) * time.Hour
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// DeviceKey is the key a postman's app signs offline bundles with. It is derived
+// from OFFLINE_SYNC_SECRET, so it is never stored and rotating the secret
+// revokes every key.
+func DeviceKey(postmanID uint) (string, error) {
+	secret := config.Secret("OFFLINE_SYNC_SECRET")
+	if secret == "" {
+		return "", ErrDisabled
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("offline-sync:" + strconv.FormatUint(uint64(postmanID), 10)))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Verify checks that signature is the hex HMAC-SHA256 of the bundle, exactly as
+// sent, under the postman's device key
+func Verify(postmanID uint, bundle, signature string) error {
+	key, err := DeviceKey(postmanID)
+	if err != nil {
+		return err
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(bundle))
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// CheckPerformedAt rejects actions older than MaxAge or from the future
+func CheckPerformedAt(performedAt, now time.Time) error {
+	if performedAt.After(now.Add(clockSkew)) {
+		return fmt.Errorf("performed_at is in the future")
+	}
+	if now.Sub(performedAt) > MaxAge() {
+		return fmt.Errorf("action is older than %s and can no longer be synced", MaxAge())
+	}
+	return nil
+}
+
+// Recorded returns the stored result of an action claimed before
+func Recorded(db *gorm.DB, postmanID uint, actionID string) (*bookingModel.OfflineSyncAction, error) {
+	var action bookingModel.OfflineSyncAction
+	if err := db.Where("postman_id = ? AND action_id = ?", postmanID, actionID).First(&action).Error; err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+// Claim records that an action is being applied. It returns false when the action
+// was claimed before, by an earlier sync or a parallel one, so it is never applied
+// twice. Claims left pending longer than pendingTimeout by a crashed sync are
+// released.
+func Claim(db *gorm.DB, action *bookingModel.OfflineSyncAction) (bool, error) {
+	if err := db.Where("postman_id = ? AND action_id = ? AND result = ? AND synced_at < ?",
+		action.PostmanID, action.ActionID, ResultPending, time.Now().Add(-pendingTimeout)).
+		Delete(&bookingModel.OfflineSyncAction{}).Error; err != nil {
+		return false, err
+	}
+
+	action.Result = ResultPending
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(action)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Finish stores the result of a claimed action. An action to be retried is
+// released so the next sync applies it again.
+func Finish(db *gorm.DB, action *bookingModel.OfflineSyncAction, result, message string) error {
+	if result == deliveryTypes.OfflineResultRetry {
+		return db.Delete(action).Error
+	}
+	action.Result = result
+	action.Message = message
+	return db.Model(action).Updates(map[string]interface{}{
+		"result":     result,
+		"message":    message,
+		"booking_id": action.BookingID,
+	}).Error
+}
@@ -0,0 +1,125 @@
+package otp
+
+import (
+	"fmt"
+	"os"
+	"passport-booking/config"
+	"passport-booking/logger"
+	"passport-booking/models/otp"
+	"passport-booking/services/notification"
+	"passport-booking/services/otp_event"
+	"strings"
+	"time"
+)
+
+const (
+	channelSMS   = "sms"
+	channelVoice = "voice"
+)
+
+// VoiceFallbackAfter is how many OTP SMS in a row must fail for a phone before its
+// codes are delivered by voice call
+func VoiceFallbackAfter() int {
+	return envInt("OTP_VOICE_FALLBACK_AFTER", 3)
+}
+
+// voiceFallbackWindow bounds how far back SMS failures count, so a phone goes back
+// to SMS once the provider has had time to recover
+func voiceFallbackWindow() time.Duration {
+	return time.Duration(envInt("OTP_VOICE_FALLBACK_WINDOW_MINUTES", 60)) * time.Minute
+}
+
+// VoiceEnabledFor reports whether codes for purpose may fall back to a voice call.
+// OTP_VOICE_PURPOSES lists the purposes separated by commas, or "all".
+func VoiceEnabledFor(purpose otp.OTPPurpose) bool {
+	for _, p := range strings.Split(os.Getenv("OTP_VOICE_PURPOSES"), ",") {
+		p = strings.TrimSpace(p)
+		if strings.EqualFold(p, "all") || p == string(purpose) {
+			return true
+		}
+	}
+	return false
+}
+
+// smsFailing reports whether the last VoiceFallbackAfter OTP SMS to phone within the
+// fallback window all failed
+func (s *Service) smsFailing(phone string) bool {
+	threshold := VoiceFallbackAfter()
+	var types []string
+	err := s.DB.Model(&otp.OTPEvent{}).
+		Where("phone = ? AND channel = ? AND sent_at > ?", phone, channelSMS, s.Now().Add(-voiceFallbackWindow())).
+		Order("sent_at DESC, id DESC").
+		Limit(threshold).
+		Pluck("event_type", &types).Error
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to count OTP SMS failures for %s", phone), err)
+		return false
+	}
+	if len(types) < threshold {
+		return false
+	}
+	for _, t := range types {
+		if t != channelSMS+"_failed" {
+			return false
+		}
+	}
+	return true
+}
+
+// deliver sends the code of o by SMS, or by voice call when the purpose allows it and
+// SMS to the phone keeps failing. Every attempt is recorded in OTPEvent. Delivery
+// failures are logged and do not fail the request, as the code stays valid.
+func (s *Service) deliver(o *otp.OTP) {
+	voiceAllowed := s.Voice != nil && VoiceEnabledFor(o.Purpose)
+	if voiceAllowed && s.smsFailing(o.Phone) {
+		// Call first and text only if the call fails too
+		if s.call(o) != nil {
+			s.text(o)
+		}
+		return
+	}
+	if s.text(o) != nil && voiceAllowed && s.smsFailing(o.Phone) {
+		// This failure reached the threshold, so call now rather than on the next request
+		s.call(o)
+	}
+}
+
+// text sends the code of o by SMS, worded by the template of its purpose, and records
+// the attempt. The generic OTP text is used when the template cannot be rendered.
+func (s *Service) text(o *otp.OTP) error {
+	sentAt := s.Now()
+	var err error
+	if body, renderErr := notification.RenderOTP(s.DB, o, sentAt); renderErr == nil {
+		_, err = s.SMSService.SendSMS(o.Phone, body)
+	} else {
+		logger.Error(fmt.Sprintf("Failed to render OTP message for %s, sending the generic text", o.Phone), renderErr)
+		err = s.SMSService.SendOTP(o.Phone, o.OTPCode)
+	}
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to send OTP SMS to %s", o.Phone), err)
+		config.Debugf("OTP for %s: %s (Purpose: %s) - SMS delivery failed, showing for testing", o.Phone, o.OTPCode, o.Purpose)
+	} else {
+		logger.Info(fmt.Sprintf("OTP sent via SMS to %s (Purpose: %s)", o.Phone, o.Purpose))
+	}
+	s.recordDelivery(o, channelSMS, sentAt, err)
+	return err
+}
+
+// call reads the code of o out in a voice call and records the attempt
+func (s *Service) call(o *otp.OTP) error {
+	sentAt := s.Now()
+	err := s.Voice.CallOTP(o.Phone, o.OTPCode)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to place voice OTP call to %s", o.Phone), err)
+	} else {
+		logger.Info(fmt.Sprintf("OTP sent via voice call to %s (Purpose: %s)", o.Phone, o.Purpose))
+	}
+	s.recordDelivery(o, channelVoice, sentAt, err)
+	return err
+}
+
+func (s *Service) recordDelivery(o *otp.OTP, channel string, sentAt time.Time, sendErr error) {
+	if err := otp_event.RecordDelivery(s.DB, o, channel, sentAt, sendErr); err != nil {
+		logger.Error(fmt.Sprintf("Failed to store OTP %s delivery event for %s", channel, o.Phone), err)
+	}
+}
@@ -2,20 +2,55 @@ package otp
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math/big"
 	"passport-booking/httpServices/sms"
+	"passport-booking/httpServices/voice"
+	"passport-booking/logger"
 	"passport-booking/models/otp"
+	"passport-booking/services/clock"
 	"passport-booking/services/otp_event"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// OTPService describes the OTP operations used by the controllers
+type OTPService interface {
+	SendOTPWithBookingID(phone string, purpose otp.OTPPurpose, bookingID *uint) (*otp.OTP, error)
+	ResendOTPWithBookingID(phone string, purpose otp.OTPPurpose, bookingID *uint) (*otp.OTP, error)
+	VerifyOTP(phone, otpCode string, purpose otp.OTPPurpose) (bool, error)
+	VerifyOTPWithDetails(phone, otpCode string, purpose otp.OTPPurpose, bookingID uint) (bool, *otp.OTP, error)
+	GetOTPStatus(phone string, purpose otp.OTPPurpose, bookingID uint) (*otp.OTP, error)
+	GetOTPRetryInfo(phone string, purpose otp.OTPPurpose, bookingID uint) (*OTPRetryInfo, error)
+	GetLatestOTPsForBookings(bookingIDs []uint, purpose otp.OTPPurpose) (map[uint]*otp.OTP, error)
+	BindSession(otpRecord *otp.OTP, bookingID, issuedToID uint) (string, error)
+	Now() time.Time
+	VerifyOTPWithSession(phone, otpCode string, purpose otp.OTPPurpose, bookingID uint, sessionToken string, actorID uint) (bool, *otp.OTP, error)
+}
+
+// Expiry is how long a sent OTP can be used
+const Expiry = 5 * time.Minute
+
+// activeOTPIndex is the partial unique index on (phone, purpose, booking_id) of unused OTPs
+const activeOTPIndex = "uniq_otps_active_phone_purpose_booking"
+
 // Service handles OTP operations
 type Service struct {
 	DB         *gorm.DB
-	SMSService *sms.SMSService
+	SMSService sms.Sender
+	// Voice calls out codes when SMS keeps failing; nil disables the fallback
+	Voice voice.Caller
+	// Clock drives expiry and block windows; nil means the system clock
+	Clock clock.Clock
+}
+
+// Now is the time expiry and block windows are judged against
+func (s *Service) Now() time.Time {
+	return clock.OrSystem(s.Clock).Now()
 }
 
 // NewOTPService creates a new OTP service
@@ -23,6 +58,15 @@ func NewOTPService(db *gorm.DB) *Service {
 	return &Service{
 		DB:         db,
 		SMSService: sms.NewSMSService(),
+		Voice:      voice.NewCaller(),
+	}
+}
+
+// NewOTPServiceWithSender creates an OTP service that delivers codes through the given sender
+func NewOTPServiceWithSender(db *gorm.DB, sender sms.Sender) *Service {
+	return &Service{
+		DB:         db,
+		SMSService: sender,
 	}
 }
 
@@ -60,39 +104,48 @@ func (s *Service) SendOTPWithBookingID(phone string, purpose otp.OTPPurpose, boo
 		return nil, fmt.Errorf("booking ID is required for OTP generation")
 	}
 
-	// Check if there's an existing active OTP for this phone and purpose
-	existingOTP, err := s.GetOTPStatus(phone, purpose)
+	// Check if there's an existing active OTP for this phone, purpose and booking. An
+	// OTP issued for another booking on the same phone is never reused.
+	existingOTP, err := s.GetOTPStatus(phone, purpose, *bookingID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check existing OTP: %w", err)
 	}
 
-	// If there's an existing OTP that hasn't expired yet, don't send new OTP
-	if existingOTP != nil && !existingOTP.IsExpired() && !existingOTP.IsUsed {
-		return nil, fmt.Errorf("an OTP for this phone number is still active and hasn't expired yet. Please wait until it expires or use the existing OTP")
+	// If there's an existing OTP that hasn't expired yet, send the same code again
+	// (bounded by the resend cooldown and counter) rather than issuing a new one
+	if existingOTP != nil && !existingOTP.IsExpiredAt(s.Now()) && !existingOTP.IsUsed {
+		if existingOTP.IsBlockedAt(s.Now()) {
+			blockTime := "permanently"
+			if existingOTP.BlockedUntil != nil {
+				blockTime = fmt.Sprintf("until %s", existingOTP.BlockedUntil.Format("15:04:05"))
+			}
+			return nil, fmt.Errorf("OTP requests are blocked %s due to too many failed attempts", blockTime)
+		}
+		return s.resendActive(existingOTP, *bookingID)
 	}
 
 	// If there's an expired OTP, mark it as used to clean up
-	if existingOTP != nil && existingOTP.IsExpired() && !existingOTP.IsUsed {
+	if existingOTP != nil && existingOTP.IsExpiredAt(s.Now()) && !existingOTP.IsUsed {
 		existingOTP.IsUsed = true
 		if err := s.DB.Save(existingOTP).Error; err != nil {
 			// Log error but continue
-			fmt.Printf("Failed to mark expired OTP as used: %v\n", err)
+			logger.Error("Failed to mark expired OTP as used", err)
 		} else {
 			// Store OTP expired cleanup event
 			if err := otp_event.SnapshotOTPToEvent(s.DB, existingOTP, "expired_cleanup"); err != nil {
 				// Log error but continue
-				fmt.Printf("Failed to store OTP expired cleanup event: %v\n", err)
+				logger.Error("Failed to store OTP expired cleanup event", err)
 			}
 		}
 	}
 
 	// If there's a valid existing OTP, return it (don't generate a new one)
-	if existingOTP != nil && existingOTP.IsValid() {
+	if existingOTP != nil && existingOTP.IsValidAt(s.Now()) {
 		return existingOTP, nil
 	}
 
 	// Check if user is blocked due to too many attempts
-	if existingOTP != nil && existingOTP.IsCurrentlyBlocked() {
+	if existingOTP != nil && existingOTP.IsBlockedAt(s.Now()) {
 		blockTime := "permanently"
 		if existingOTP.BlockedUntil != nil {
 			blockTime = fmt.Sprintf("until %s", existingOTP.BlockedUntil.Format("15:04:05"))
@@ -106,15 +159,18 @@ func (s *Service) SendOTPWithBookingID(phone string, purpose otp.OTPPurpose, boo
 		return nil, fmt.Errorf("failed to generate OTP: %w", err)
 	}
 
-	// Invalidate any existing unused OTPs for this phone and purpose
+	// Invalidate expired unused OTPs for this phone, purpose and booking. A live one
+	// is left alone: it can only be there if a concurrent request just issued it,
+	// and the insert below then returns it instead.
 	err = s.DB.Model(&otp.OTP{}).
-		Where("phone = ? AND purpose = ? AND is_used = false", phone, purpose).
+		Where("phone = ? AND purpose = ? AND booking_id = ? AND is_used = false AND expires_at <= ?", phone, purpose, *bookingID, s.Now()).
 		Update("is_used", true).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to invalidate existing OTPs: %w", err)
 	}
 
 	// Create new OTP record with retry settings
+	now := s.Now()
 	newOTP := &otp.OTP{
 		BookingID:  *bookingID,
 		Phone:      phone,
@@ -124,108 +180,49 @@ func (s *Service) SendOTPWithBookingID(phone string, purpose otp.OTPPurpose, boo
 		RetryCount: 0,
 		MaxRetries: 3, // Default max retries
 		IsBlocked:  false,
-		ExpiresAt:  time.Now().Add(5 * time.Minute), // 5 minutes expiry
+		ExpiresAt:  now.Add(Expiry),
+		LastSentAt: &now,
 	}
 
 	if err := s.DB.Create(newOTP).Error; err != nil {
+		// The partial unique index allows one unused OTP per phone, purpose and booking,
+		// so a concurrent send for the same booking that got there first wins and its
+		// OTP is returned
+		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), activeOTPIndex) {
+			var active otp.OTP
+			if findErr := s.DB.Where("phone = ? AND purpose = ? AND booking_id = ? AND is_used = false", phone, purpose, *bookingID).
+				Order("created_at DESC").First(&active).Error; findErr == nil {
+				return &active, nil
+			}
+		}
 		return nil, fmt.Errorf("failed to create OTP record: %w", err)
 	}
 
 	// Store OTP creation event
 	if err := otp_event.SnapshotOTPToEvent(s.DB, newOTP, "created"); err != nil {
 		// Log error but don't fail the OTP creation
-		fmt.Printf("Failed to store OTP creation event for %s: %v\n", phone, err)
+		logger.Error(fmt.Sprintf("Failed to store OTP creation event for %s", phone), err)
 	}
 
-	// Send OTP via SMS
-	if err := s.SMSService.SendOTP(phone, otpCode); err != nil {
-		// Log the error but don't fail the OTP creation
-		// The OTP is still valid and can be used for testing/fallback
-		fmt.Printf("Failed to send OTP SMS to %s: %v\n", phone, err)
-		fmt.Printf("OTP for %s: %s (Purpose: %s) - SMS delivery failed, showing for testing\n", phone, otpCode, purpose)
-	} else {
-		fmt.Printf("OTP sent via SMS to %s (Purpose: %s)\n", phone, purpose)
-	}
+	// Send OTP via SMS, or voice when SMS keeps failing
+	s.deliver(newOTP)
 
 	return newOTP, nil
 }
 
-// VerifyOTP verifies the provided OTP code for the given phone number and purpose with retry handling
+// VerifyOTP verifies the provided OTP code for the given phone number and purpose with retry handling (for non-booking purposes)
 func (s *Service) VerifyOTP(phone, otpCode string, purpose otp.OTPPurpose) (bool, error) {
-	var otpRecord otp.OTP
-
-	err := s.DB.Where("phone = ? AND purpose = ? AND is_used = false",
-		phone, purpose).
-		Order("created_at DESC").
-		First(&otpRecord).Error
-
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return false, nil // No OTP found
-		}
-		return false, fmt.Errorf("failed to find OTP record: %w", err)
-	}
-
-	// Check if OTP is blocked
-	if otpRecord.IsCurrentlyBlocked() {
-		blockTime := "permanently"
-		if otpRecord.BlockedUntil != nil {
-			blockTime = fmt.Sprintf("until %s", otpRecord.BlockedUntil.Format("15:04:05"))
-		}
-		return false, fmt.Errorf("OTP verification is blocked %s due to too many failed attempts", blockTime)
-	}
-
-	// Check if OTP has expired
-	if otpRecord.IsExpired() {
-		return false, fmt.Errorf("OTP has expired")
-	}
-
-	// Check if the OTP code matches
-	if otpRecord.OTPCode != otpCode {
-		// Increment retry count for failed attempt
-		otpRecord.IncrementRetry()
-		if err := s.DB.Save(&otpRecord).Error; err != nil {
-			return false, fmt.Errorf("failed to update retry count: %w", err)
-		}
-
-		// Store OTP failed verification event
-		eventType := "verification_failed"
-		if otpRecord.IsCurrentlyBlocked() {
-			eventType = "blocked_max_retries"
-		}
-		if err := otp_event.SnapshotOTPToEvent(s.DB, &otpRecord, eventType); err != nil {
-			// Log error but don't fail the verification
-			fmt.Printf("Failed to store OTP verification failed event for %s: %v\n", phone, err)
-		}
-
-		remainingAttempts := otpRecord.MaxRetries - otpRecord.RetryCount
-		if remainingAttempts <= 0 {
-			return false, fmt.Errorf("invalid OTP. Maximum attempts exceeded. OTP is now blocked")
-		}
-		return false, fmt.Errorf("invalid OTP. %d attempts remaining", remainingAttempts)
-	}
-
-	// OTP is valid, mark as used
-	otpRecord.IsUsed = true
-	if err := s.DB.Save(&otpRecord).Error; err != nil {
-		return false, fmt.Errorf("failed to mark OTP as used: %w", err)
-	}
-
-	// Store OTP successful verification event
-	if err := otp_event.SnapshotOTPToEvent(s.DB, &otpRecord, "verified_success"); err != nil {
-		// Log error but don't fail the verification
-		fmt.Printf("Failed to store OTP verification success event for %s: %v\n", phone, err)
-	}
-
-	return true, nil
+	isValid, _, err := s.VerifyOTPWithDetails(phone, otpCode, purpose, 0)
+	return isValid, err
 }
 
-// VerifyOTPWithDetails verifies the provided OTP code and returns the OTP record details with retry handling
-func (s *Service) VerifyOTPWithDetails(phone, otpCode string, purpose otp.OTPPurpose) (bool, *otp.OTP, error) {
+// VerifyOTPWithDetails verifies the provided OTP code against the OTP issued for the
+// booking and returns the OTP record details with retry handling
+func (s *Service) VerifyOTPWithDetails(phone, otpCode string, purpose otp.OTPPurpose, bookingID uint) (bool, *otp.OTP, error) {
 	var otpRecord otp.OTP
 
-	err := s.DB.Where("phone = ? AND purpose = ? AND is_used = false",
-		phone, purpose).
+	err := s.DB.Where("phone = ? AND purpose = ? AND booking_id = ? AND is_used = false",
+		phone, purpose, bookingID).
 		Order("created_at DESC").
 		First(&otpRecord).Error
 
@@ -237,7 +234,7 @@ func (s *Service) VerifyOTPWithDetails(phone, otpCode string, purpose otp.OTPPur
 	}
 
 	// Check if OTP is blocked
-	if otpRecord.IsCurrentlyBlocked() {
+	if otpRecord.IsBlockedAt(s.Now()) {
 		blockTime := "permanently"
 		if otpRecord.BlockedUntil != nil {
 			blockTime = fmt.Sprintf("until %s", otpRecord.BlockedUntil.Format("15:04:05"))
@@ -246,26 +243,25 @@ func (s *Service) VerifyOTPWithDetails(phone, otpCode string, purpose otp.OTPPur
 	}
 
 	// Check if OTP has expired
-	if otpRecord.IsExpired() {
+	if otpRecord.IsExpiredAt(s.Now()) {
 		return false, &otpRecord, fmt.Errorf("OTP has expired")
 	}
 
 	// Check if the OTP code matches
 	if otpRecord.OTPCode != otpCode {
 		// Increment retry count for failed attempt
-		otpRecord.IncrementRetry()
-		if err := s.DB.Save(&otpRecord).Error; err != nil {
-			return false, &otpRecord, fmt.Errorf("failed to update retry count: %w", err)
+		if err := s.recordFailedAttempt(&otpRecord); err != nil {
+			return false, &otpRecord, err
 		}
 
 		// Store OTP failed verification event
 		eventType := "verification_failed"
-		if otpRecord.IsCurrentlyBlocked() {
+		if otpRecord.IsBlockedAt(s.Now()) {
 			eventType = "blocked_max_retries"
 		}
 		if err := otp_event.SnapshotOTPToEvent(s.DB, &otpRecord, eventType); err != nil {
 			// Log error but don't fail the verification
-			fmt.Printf("Failed to store OTP verification failed event for %s: %v\n", phone, err)
+			logger.Error(fmt.Sprintf("Failed to store OTP verification failed event for %s", phone), err)
 		}
 
 		remainingAttempts := otpRecord.MaxRetries - otpRecord.RetryCount
@@ -276,25 +272,75 @@ func (s *Service) VerifyOTPWithDetails(phone, otpCode string, purpose otp.OTPPur
 	}
 
 	// OTP is valid, mark as used
-	otpRecord.IsUsed = true
-	if err := s.DB.Save(&otpRecord).Error; err != nil {
-		return false, &otpRecord, fmt.Errorf("failed to mark OTP as used: %w", err)
+	if err := s.consume(&otpRecord); err != nil {
+		return false, &otpRecord, err
 	}
 
 	// Store OTP successful verification event
 	if err := otp_event.SnapshotOTPToEvent(s.DB, &otpRecord, "verified_success"); err != nil {
 		// Log error but don't fail the verification
-		fmt.Printf("Failed to store OTP verification success event for %s: %v\n", phone, err)
+		logger.Error(fmt.Sprintf("Failed to store OTP verification success event for %s", phone), err)
 	}
 
 	return true, &otpRecord, nil
 }
 
+// usableCondition matches OTPs that are unused and not inside a block window
+const usableCondition = "is_used = false AND (is_blocked = false OR (blocked_until IS NOT NULL AND blocked_until <= ?))"
+
+// recordFailedAttempt counts a wrong code in a single UPDATE ... RETURNING, so
+// concurrent wrong guesses each see the count left by the previous one and the
+// OTP blocks at MaxRetries however many arrive at once. The record is refreshed
+// from the database.
+func (s *Service) recordFailedAttempt(o *otp.OTP) error {
+	now := s.Now()
+	result := s.DB.Model(o).Clauses(clause.Returning{}).
+		Where(usableCondition, now).
+		Updates(map[string]interface{}{
+			"retry_count":     gorm.Expr("retry_count + 1"),
+			"last_attempt_at": now,
+			"is_blocked":      gorm.Expr("retry_count + 1 >= max_retries"),
+			"blocked_until":   gorm.Expr("CASE WHEN retry_count + 1 >= max_retries THEN ?::timestamptz ELSE NULL END", now.Add(otp.BlockDuration)),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update retry count: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return s.unusableError(o)
+	}
+	return nil
+}
+
+// consume marks the OTP used unless a concurrent request used or blocked it first
+func (s *Service) consume(o *otp.OTP) error {
+	result := s.DB.Model(o).Where(usableCondition, s.Now()).Update("is_used", true)
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark OTP as used: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return s.unusableError(o)
+	}
+	o.IsUsed = true
+	return nil
+}
+
+// unusableError reloads an OTP another request changed underneath us and explains
+// why it can no longer be verified
+func (s *Service) unusableError(o *otp.OTP) error {
+	if err := s.DB.First(o, o.ID).Error; err != nil {
+		return fmt.Errorf("failed to reload OTP record: %w", err)
+	}
+	if o.IsBlockedAt(s.Now()) {
+		return fmt.Errorf("invalid OTP. Maximum attempts exceeded. OTP is now blocked")
+	}
+	return fmt.Errorf("OTP has already been used")
+}
+
 // CleanupExpiredOTPs removes expired OTP records from the database
 func (s *Service) CleanupExpiredOTPs() error {
 	// First, get all expired OTPs to store events before deletion
 	var expiredOTPs []otp.OTP
-	err := s.DB.Where("expires_at < ?", time.Now()).Find(&expiredOTPs).Error
+	err := s.DB.Where("expires_at < ?", s.Now()).Find(&expiredOTPs).Error
 	if err != nil {
 		return err
 	}
@@ -303,20 +349,20 @@ func (s *Service) CleanupExpiredOTPs() error {
 	for _, expiredOTP := range expiredOTPs {
 		if err := otp_event.SnapshotOTPToEvent(s.DB, &expiredOTP, "expired"); err != nil {
 			// Log error but continue with cleanup
-			fmt.Printf("Failed to store OTP expiration event for OTP ID %d: %v\n", expiredOTP.ID, err)
+			logger.Error(fmt.Sprintf("Failed to store OTP expiration event for OTP ID %d", expiredOTP.ID), err)
 		}
 	}
 
 	// Now delete the expired OTPs
-	return s.DB.Where("expires_at < ?", time.Now()).Delete(&otp.OTP{}).Error
+	return s.DB.Where("expires_at < ?", s.Now()).Delete(&otp.OTP{}).Error
 }
 
-// GetOTPStatus checks if there's a valid OTP for the given phone and purpose
-func (s *Service) GetOTPStatus(phone string, purpose otp.OTPPurpose) (*otp.OTP, error) {
+// GetOTPStatus checks if there's a valid OTP for the given phone, purpose and booking
+func (s *Service) GetOTPStatus(phone string, purpose otp.OTPPurpose, bookingID uint) (*otp.OTP, error) {
 	var otpRecord otp.OTP
 
-	err := s.DB.Where("phone = ? AND purpose = ? AND is_used = false AND expires_at > ?",
-		phone, purpose, time.Now()).
+	err := s.DB.Where("phone = ? AND purpose = ? AND booking_id = ? AND is_used = false AND expires_at > ?",
+		phone, purpose, bookingID, s.Now()).
 		Order("created_at DESC").
 		First(&otpRecord).Error
 
@@ -330,11 +376,36 @@ func (s *Service) GetOTPStatus(phone string, purpose otp.OTPPurpose) (*otp.OTP,
 	return &otpRecord, nil
 }
 
-// GetOTPRetryInfo returns retry information for a phone number and purpose
-func (s *Service) GetOTPRetryInfo(phone string, purpose otp.OTPPurpose) (*OTPRetryInfo, error) {
+// GetLatestOTPsForBookings returns the most recent OTP per booking for the given purpose
+func (s *Service) GetLatestOTPsForBookings(bookingIDs []uint, purpose otp.OTPPurpose) (map[uint]*otp.OTP, error) {
+	latest := make(map[uint]*otp.OTP, len(bookingIDs))
+	if len(bookingIDs) == 0 {
+		return latest, nil
+	}
+
+	var records []otp.OTP
+	err := s.DB.Where("booking_id IN ? AND purpose = ?", bookingIDs, purpose).
+		Order("created_at DESC").
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find OTP records: %w", err)
+	}
+
+	// Records are newest first, so keep the first one seen per booking
+	for i := range records {
+		if _, exists := latest[records[i].BookingID]; !exists {
+			latest[records[i].BookingID] = &records[i]
+		}
+	}
+
+	return latest, nil
+}
+
+// GetOTPRetryInfo returns retry information for a phone number, purpose and booking
+func (s *Service) GetOTPRetryInfo(phone string, purpose otp.OTPPurpose, bookingID uint) (*OTPRetryInfo, error) {
 	var otpRecord otp.OTP
 
-	err := s.DB.Where("phone = ? AND purpose = ?", phone, purpose).
+	err := s.DB.Where("phone = ? AND purpose = ? AND booking_id = ?", phone, purpose, bookingID).
 		Order("created_at DESC").
 		First(&otpRecord).Error
 
@@ -353,10 +424,11 @@ func (s *Service) GetOTPRetryInfo(phone string, purpose otp.OTPPurpose) (*OTPRet
 		return nil, fmt.Errorf("failed to find OTP record: %w", err)
 	}
 
+	now := s.Now()
 	info := &OTPRetryInfo{
-		CanRequestNewOTP: !otpRecord.IsCurrentlyBlocked() && (otpRecord.IsUsed || otpRecord.IsExpired()),
-		CanRetryOTP:      otpRecord.CanRetry(),
-		IsBlocked:        otpRecord.IsCurrentlyBlocked(),
+		CanRequestNewOTP: !otpRecord.IsBlockedAt(now) && (otpRecord.IsUsed || otpRecord.IsExpiredAt(now)),
+		CanRetryOTP:      otpRecord.CanRetryAt(now),
+		IsBlocked:        otpRecord.IsBlockedAt(now),
 		RemainingRetries: otpRecord.MaxRetries - otpRecord.RetryCount,
 		BlockedUntil:     otpRecord.BlockedUntil,
 	}
@@ -404,7 +476,7 @@ func (s *Service) UnblockOTP(phone string, purpose otp.OTPPurpose) error {
 	// Store OTP unblock event
 	if err := otp_event.SnapshotOTPToEvent(s.DB, &otpRecord, "manually_unblocked"); err != nil {
 		// Log error but don't fail the unblock operation
-		fmt.Printf("Failed to store OTP unblock event for %s: %v\n", phone, err)
+		logger.Error(fmt.Sprintf("Failed to store OTP unblock event for %s", phone), err)
 	}
 
 	return nil
@@ -412,7 +484,7 @@ func (s *Service) UnblockOTP(phone string, purpose otp.OTPPurpose) error {
 
 // CleanupExpiredBlocks removes expired blocks and resets retry counts
 func (s *Service) CleanupExpiredBlocks() error {
-	now := time.Now()
+	now := s.Now()
 
 	// Find all OTPs that are blocked but the block period has expired
 	var expiredBlocks []otp.OTP
@@ -428,21 +500,22 @@ func (s *Service) CleanupExpiredBlocks() error {
 		otpRecord.Reset()
 		if err := s.DB.Save(&otpRecord).Error; err != nil {
 			// Log error but continue with other records
-			fmt.Printf("Failed to reset expired block for OTP ID %d: %v\n", otpRecord.ID, err)
+			logger.Error(fmt.Sprintf("Failed to reset expired block for OTP ID %d", otpRecord.ID), err)
 			continue
 		}
 
 		// Store OTP auto-unblock event
 		if err := otp_event.SnapshotOTPToEvent(s.DB, &otpRecord, "auto_unblocked"); err != nil {
 			// Log error but continue with other records
-			fmt.Printf("Failed to store OTP auto-unblock event for OTP ID %d: %v\n", otpRecord.ID, err)
+			logger.Error(fmt.Sprintf("Failed to store OTP auto-unblock event for OTP ID %d", otpRecord.ID), err)
 		}
 	}
 
 	return nil
 }
 
-// ResendOTPWithBookingID resends OTP by updating existing unused OTP record or creating new one
+// ResendOTPWithBookingID resends the active code, refreshes an expired unused OTP
+// record with a new code, or creates a new one
 func (s *Service) ResendOTPWithBookingID(phone string, purpose otp.OTPPurpose, bookingID *uint) (*otp.OTP, error) {
 	// Ensure we have a valid booking ID
 	if bookingID == nil {
@@ -463,7 +536,7 @@ func (s *Service) ResendOTPWithBookingID(phone string, purpose otp.OTPPurpose, b
 	// If we found an existing unused OTP, update it instead of creating a new one
 	if err != gorm.ErrRecordNotFound {
 		// Check if user is blocked due to too many attempts
-		if existingOTP.IsCurrentlyBlocked() {
+		if existingOTP.IsBlockedAt(s.Now()) {
 			blockTime := "permanently"
 			if existingOTP.BlockedUntil != nil {
 				blockTime = fmt.Sprintf("until %s", existingOTP.BlockedUntil.Format("15:04:05"))
@@ -471,6 +544,11 @@ func (s *Service) ResendOTPWithBookingID(phone string, purpose otp.OTPPurpose, b
 			return nil, fmt.Errorf("OTP requests are blocked %s due to too many failed attempts", blockTime)
 		}
 
+		// A code that is still valid is sent again unchanged
+		if !existingOTP.IsExpiredAt(s.Now()) {
+			return s.resendActive(&existingOTP, *bookingID)
+		}
+
 		// Generate new OTP code
 		otpCode, err := s.GenerateOTP()
 		if err != nil {
@@ -479,8 +557,11 @@ func (s *Service) ResendOTPWithBookingID(phone string, purpose otp.OTPPurpose, b
 
 		// Update existing OTP with new code and expiration time
 		existingOTP.OTPCode = otpCode
-		existingOTP.ExpiresAt = time.Now().Add(5 * time.Minute) // 5 minutes expiry
-		existingOTP.UpdatedAt = time.Now()
+		now := s.Now()
+		existingOTP.ExpiresAt = now.Add(Expiry)
+		existingOTP.UpdatedAt = now
+		existingOTP.ResendCount = 0
+		existingOTP.LastSentAt = &now
 
 		if err := s.DB.Save(&existingOTP).Error; err != nil {
 			return nil, fmt.Errorf("failed to update existing OTP record: %w", err)
@@ -489,17 +570,11 @@ func (s *Service) ResendOTPWithBookingID(phone string, purpose otp.OTPPurpose, b
 		// Store OTP resend event
 		if err := otp_event.SnapshotOTPToEvent(s.DB, &existingOTP, "resent"); err != nil {
 			// Log error but don't fail the OTP resend
-			fmt.Printf("Failed to store OTP resend event for %s: %v\n", phone, err)
+			logger.Error(fmt.Sprintf("Failed to store OTP resend event for %s", phone), err)
 		}
 
-		// Send OTP via SMS
-		if err := s.SMSService.SendOTP(phone, otpCode); err != nil {
-			// Log the error but don't fail the OTP resend
-			fmt.Printf("Failed to send OTP SMS to %s: %v\n", phone, err)
-			fmt.Printf("Resent OTP for %s: %s (Purpose: %s) - SMS delivery failed, showing for testing\n", phone, otpCode, purpose)
-		} else {
-			fmt.Printf("Resent OTP via SMS to %s (Purpose: %s)\n", phone, purpose)
-		}
+		// Send OTP via SMS, or voice when SMS keeps failing
+		s.deliver(&existingOTP)
 
 		return &existingOTP, nil
 	}
@@ -0,0 +1,310 @@
+package otp
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"passport-booking/httpServices/sms"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/models/otp"
+	"passport-booking/services/clock"
+	"passport-booking/testutil/factory"
+	"passport-booking/testutil/testdb"
+)
+
+func TestVerifyOTPExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		advance time.Duration
+		wantOK  bool
+		wantErr string
+	}{
+		{"fresh", 0, true, ""},
+		{"just before expiry", Expiry - time.Second, true, ""},
+		{"at expiry", Expiry, true, ""},
+		{"just after expiry", Expiry + time.Second, false, "OTP has expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := testdb.Tx(t)
+			fake := clock.NewFake(time.Now().Truncate(time.Microsecond))
+			o := factory.CreateOTP(t, tx, func(o *otp.OTP) {
+				o.ExpiresAt = fake.Now().Add(Expiry)
+			})
+			s := &Service{DB: tx, Clock: fake}
+
+			fake.Advance(tt.advance)
+			ok, _, err := s.VerifyOTPWithDetails(o.Phone, o.OTPCode, o.Purpose, o.BookingID)
+			if ok != tt.wantOK {
+				t.Errorf("ok = %v, want %v (err %v)", ok, tt.wantOK, err)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyOTPBlockWindow(t *testing.T) {
+	tx := testdb.Tx(t)
+	fake := clock.NewFake(time.Now().Truncate(time.Microsecond))
+	o := factory.CreateOTP(t, tx, func(o *otp.OTP) {
+		// Outlives the block window so only the block decides the outcome
+		o.ExpiresAt = fake.Now().Add(time.Hour)
+	})
+	s := &Service{DB: tx, Clock: fake}
+
+	for i := 1; i <= o.MaxRetries; i++ {
+		ok, _, err := s.VerifyOTPWithDetails(o.Phone, "000000", o.Purpose, o.BookingID)
+		if ok || err == nil {
+			t.Fatalf("wrong code %d: ok = %v, err = %v", i, ok, err)
+		}
+	}
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		wantOK  bool
+	}{
+		{"right after blocking", 0, false},
+		{"just before the window ends", otp.BlockDuration - time.Second, false},
+		{"after the window", 2 * time.Second, true},
+	}
+	for _, step := range steps {
+		fake.Advance(step.advance)
+		ok, record, err := s.VerifyOTPWithDetails(o.Phone, o.OTPCode, o.Purpose, o.BookingID)
+		if ok != step.wantOK {
+			t.Fatalf("%s: ok = %v, want %v (err %v)", step.name, ok, step.wantOK, err)
+		}
+		if !step.wantOK && (err == nil || !strings.Contains(err.Error(), "blocked")) {
+			t.Fatalf("%s: err = %v, want a block error", step.name, err)
+		}
+		if step.wantOK && !record.IsUsed {
+			t.Fatalf("%s: OTP not marked used", step.name)
+		}
+	}
+}
+
+// verifyConcurrently runs VerifyOTPWithDetails for code from n goroutines at once
+// and returns how many of them succeeded
+func verifyConcurrently(s *Service, o *otp.OTP, code string, n int) int {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		verified int
+	)
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			ok, _, _ := s.VerifyOTPWithDetails(o.Phone, code, o.Purpose, o.BookingID)
+			if ok {
+				mu.Lock()
+				verified++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return verified
+}
+
+// The concurrent tests commit their rows, since requests on separate connections
+// cannot see a test transaction; the unique phones keep them apart from other tests.
+// Run them with -race.
+
+func TestVerifyOTPWithDetailsConcurrentWrongCodes(t *testing.T) {
+	db := testdb.Open(t)
+	o := factory.CreateOTP(t, db)
+	s := &Service{DB: db}
+
+	if verified := verifyConcurrently(s, o, "000000", 20); verified != 0 {
+		t.Fatalf("%d wrong codes verified", verified)
+	}
+
+	var got otp.OTP
+	if err := db.First(&got, o.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.RetryCount != got.MaxRetries {
+		t.Errorf("retry_count = %d after 20 concurrent wrong codes, want it capped at %d", got.RetryCount, got.MaxRetries)
+	}
+	if !got.IsBlocked || got.BlockedUntil == nil {
+		t.Errorf("OTP not blocked after its retries ran out: is_blocked = %v, blocked_until = %v", got.IsBlocked, got.BlockedUntil)
+	}
+
+	// The right code no longer helps once the OTP is blocked
+	if ok, _, _ := s.VerifyOTPWithDetails(o.Phone, o.OTPCode, o.Purpose, o.BookingID); ok {
+		t.Error("blocked OTP verified")
+	}
+}
+
+func TestVerifyOTPWithDetailsConcurrentRightCode(t *testing.T) {
+	db := testdb.Open(t)
+	o := factory.CreateOTP(t, db)
+	s := &Service{DB: db}
+
+	if verified := verifyConcurrently(s, o, o.OTPCode, 20); verified != 1 {
+		t.Fatalf("OTP verified %d times by concurrent requests, want once", verified)
+	}
+}
+
+// recordingSender counts the texts it is asked to send instead of sending them
+type recordingSender struct {
+	mu   sync.Mutex
+	sent int
+}
+
+func (r *recordingSender) SendSMS(phoneNumber, message string) (*sms.SMSResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent++
+	return &sms.SMSResponse{Success: true}, nil
+}
+
+func (r *recordingSender) SendOTP(phoneNumber, otpCode string) error {
+	_, err := r.SendSMS(phoneNumber, otpCode)
+	return err
+}
+
+func (r *recordingSender) SendDeliveryNotification(phoneNumber, bookingID string) error {
+	_, err := r.SendSMS(phoneNumber, bookingID)
+	return err
+}
+
+// TestSendOTPConcurrentIssuesOne checks that concurrent sends for one phone and
+// purpose leave a single active OTP: the partial unique index rejects the losers,
+// which then return the winner's OTP or hit the resend cooldown.
+func TestSendOTPConcurrentIssuesOne(t *testing.T) {
+	// Keeps every loser inside the cooldown, so none of them sends the code again
+	t.Setenv("OTP_RESEND_COOLDOWN_SECONDS", "60")
+	db := testdb.Open(t)
+	booking := factory.CreateBooking(t, db)
+	sender := &recordingSender{}
+	s := NewOTPServiceWithSender(db, sender)
+	purpose := otp.OTPPurposeDeliveryConfirmPhone
+
+	const n = 20
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		ids  = make(map[uint]bool)
+		errs []error
+	)
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			o, err := s.SendOTPWithBookingID(booking.Phone, purpose, &booking.ID)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			ids[o.ID] = true
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range errs {
+		if !errors.Is(err, ErrResendCooldown) {
+			t.Errorf("unexpected error from a concurrent send: %v", err)
+		}
+	}
+	if len(ids) != 1 {
+		t.Errorf("concurrent sends returned %d different OTPs, want 1", len(ids))
+	}
+
+	var active int64
+	if err := db.Model(&otp.OTP{}).Where("phone = ? AND purpose = ? AND is_used = false", booking.Phone, purpose).
+		Count(&active).Error; err != nil {
+		t.Fatal(err)
+	}
+	if active != 1 {
+		t.Errorf("%d active OTPs after %d concurrent sends, want 1", active, n)
+	}
+	if sender.sent != 1 {
+		t.Errorf("%d texts sent, want 1", sender.sent)
+	}
+}
+
+// TestSendOTPSharedPhone checks that bookings sharing a delivery phone each get their
+// own OTP, and that one booking's code does not verify the other
+func TestSendOTPSharedPhone(t *testing.T) {
+	tx := testdb.Tx(t)
+	first := factory.CreateBooking(t, tx)
+	second := factory.CreateBooking(t, tx, func(b *bookingModel.Booking) {
+		b.Phone = first.Phone
+	})
+	sender := &recordingSender{}
+	s := NewOTPServiceWithSender(tx, sender)
+	purpose := otp.OTPPurposeDeliveryConfirmPhone
+
+	a, err := s.SendOTPWithBookingID(first.Phone, purpose, &first.ID)
+	if err != nil {
+		t.Fatalf("send for the first booking: %v", err)
+	}
+	b, err := s.SendOTPWithBookingID(second.Phone, purpose, &second.ID)
+	if err != nil {
+		t.Fatalf("send for the second booking: %v", err)
+	}
+	if a.ID == b.ID {
+		t.Fatalf("both bookings got OTP %d, want one each", a.ID)
+	}
+	if b.BookingID != second.ID {
+		t.Errorf("second OTP belongs to booking %d, want %d", b.BookingID, second.ID)
+	}
+	if sender.sent != 2 {
+		t.Errorf("%d texts sent, want 2", sender.sent)
+	}
+
+	if a.OTPCode != b.OTPCode {
+		if ok, _, _ := s.VerifyOTPWithDetails(second.Phone, a.OTPCode, purpose, second.ID); ok {
+			t.Error("the first booking's code verified the second booking")
+		}
+	}
+	ok, record, err := s.VerifyOTPWithDetails(second.Phone, b.OTPCode, purpose, second.ID)
+	if !ok || err != nil {
+		t.Fatalf("second booking's own code: ok = %v, err = %v", ok, err)
+	}
+	if record.ID != b.ID {
+		t.Errorf("verified OTP %d, want %d", record.ID, b.ID)
+	}
+}
+
+// TestSessionScopedToBooking checks that an OTP cannot be bound or verified through a
+// session for another booking sharing the phone
+func TestSessionScopedToBooking(t *testing.T) {
+	tx := testdb.Tx(t)
+	o := factory.CreateOTP(t, tx)
+	s := &Service{DB: tx}
+	other := o.BookingID + 1
+
+	if _, err := s.BindSession(o, other, 1); !errors.Is(err, ErrOTPSessionMismatch) {
+		t.Errorf("binding for another booking: err = %v, want ErrOTPSessionMismatch", err)
+	}
+	token, err := s.BindSession(o, o.BookingID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, record, _ := s.VerifyOTPWithSession(o.Phone, o.OTPCode, o.Purpose, other, token, 1); ok || record != nil {
+		t.Errorf("verified through another booking: ok = %v, record = %v", ok, record)
+	}
+	ok, record, err := s.VerifyOTPWithSession(o.Phone, o.OTPCode, o.Purpose, o.BookingID, token, 1)
+	if !ok || err != nil || record.ID != o.ID {
+		t.Errorf("own booking: ok = %v, err = %v", ok, err)
+	}
+}
@@ -0,0 +1,94 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/logger"
+	"passport-booking/models/otp"
+	"passport-booking/services/otp_event"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrResendCooldown is returned when the active code was sent too recently to send it again
+var ErrResendCooldown = errors.New("OTP was sent recently, please wait before requesting it again")
+
+// ErrResendLimit is returned when the active code has already been resent the maximum number of times
+var ErrResendLimit = errors.New("OTP resend limit reached, please wait until it expires")
+
+// ResendCooldown is the minimum gap between two sends of the same code
+func ResendCooldown() time.Duration {
+	return time.Duration(envInt("OTP_RESEND_COOLDOWN_SECONDS", 60)) * time.Second
+}
+
+// MaxResends is how many times one code may be sent again before it expires
+func MaxResends() int {
+	return envInt("OTP_MAX_RESENDS", 3)
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// resendActive sends the still valid code of o again without changing it or its
+// expiry, provided o was issued for bookingID. The cooldown and resend counter are
+// checked and bumped in one UPDATE so concurrent requests cannot send the code more
+// often than allowed.
+func (s *Service) resendActive(o *otp.OTP, bookingID uint) (*otp.OTP, error) {
+	if o.BookingID != bookingID {
+		return nil, fmt.Errorf("OTP was issued for another booking")
+	}
+	now := s.Now()
+	cooldown := ResendCooldown()
+	result := s.DB.Model(o).
+		Where("booking_id = ? AND is_used = false AND expires_at > ? AND resend_count < ? AND COALESCE(last_sent_at, created_at) <= ?",
+			bookingID, now, MaxResends(), now.Add(-cooldown)).
+		Updates(map[string]interface{}{
+			"resend_count": gorm.Expr("resend_count + 1"),
+			"last_sent_at": now,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to record OTP resend: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if err := s.DB.First(o, o.ID).Error; err != nil {
+			return nil, fmt.Errorf("failed to reload OTP record: %w", err)
+		}
+		if o.IsUsed || o.IsExpiredAt(now) {
+			return nil, fmt.Errorf("OTP is no longer active, please request a new one")
+		}
+		if o.ResendCount >= MaxResends() {
+			return nil, ErrResendLimit
+		}
+		lastSent := o.CreatedAt
+		if o.LastSentAt != nil {
+			lastSent = *o.LastSentAt
+		}
+		wait := lastSent.Add(cooldown).Sub(now).Round(time.Second)
+		return nil, fmt.Errorf("%w (retry in %s)", ErrResendCooldown, wait)
+	}
+	o.ResendCount++
+	o.LastSentAt = &now
+
+	if err := otp_event.SnapshotOTPToEvent(s.DB, o, "resent_same_code"); err != nil {
+		// Log error but don't fail the OTP resend
+		logger.Error(fmt.Sprintf("Failed to store OTP resend event for %s", o.Phone), err)
+	}
+
+	s.deliver(o)
+
+	return o, nil
+}
+
+// IsRateLimited reports whether err means the applicant has to wait before another send
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrResendCooldown) || errors.Is(err, ErrResendLimit)
+}
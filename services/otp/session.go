@@ -0,0 +1,103 @@
+package otp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"passport-booking/models/otp"
+
+	"gorm.io/gorm"
+)
+
+// ErrOTPSessionMismatch is returned when an OTP is verified with a session token
+// that was not issued together with it, e.g. from a second device
+var ErrOTPSessionMismatch = errors.New("OTP session is invalid for this request")
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// BindSession ties an unused OTP of the booking to the user who requested it and
+// returns a one-shot session token that must accompany the verification. Only the
+// token hash is stored. An OTP issued for another booking is never rebound.
+func (s *Service) BindSession(otpRecord *otp.OTP, bookingID, issuedToID uint) (string, error) {
+	if otpRecord.BookingID != bookingID {
+		return "", ErrOTPSessionMismatch
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate OTP session: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	hash := hashSessionToken(token)
+
+	result := s.DB.Model(otpRecord).
+		Where("booking_id = ? AND is_used = false", bookingID).
+		Updates(map[string]interface{}{
+			"session_token_hash": hash,
+			"issued_to_id":       issuedToID,
+		})
+	if result.Error != nil {
+		return "", fmt.Errorf("failed to bind OTP session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrOTPSessionMismatch
+	}
+
+	otpRecord.SessionTokenHash = &hash
+	otpRecord.IssuedToID = &issuedToID
+	return token, nil
+}
+
+// VerifyOTPWithSession checks the session binding of the booking's pending OTP before
+// verifying the code, so a mismatched session neither verifies nor consumes the
+// holder's retry attempts
+func (s *Service) VerifyOTPWithSession(phone, otpCode string, purpose otp.OTPPurpose, bookingID uint, sessionToken string, actorID uint) (bool, *otp.OTP, error) {
+	var pending otp.OTP
+	err := s.DB.Where("phone = ? AND purpose = ? AND booking_id = ? AND is_used = false", phone, purpose, bookingID).
+		Order("created_at DESC").
+		First(&pending).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil, nil
+		}
+		return false, nil, fmt.Errorf("failed to find OTP record: %w", err)
+	}
+
+	if !sessionMatches(&pending, sessionToken, actorID) {
+		return false, nil, ErrOTPSessionMismatch
+	}
+
+	isValid, otpRecord, err := s.VerifyOTPWithDetails(phone, otpCode, purpose, bookingID)
+	if err != nil || !isValid {
+		return isValid, otpRecord, err
+	}
+
+	// A newer OTP may have been issued between the two lookups
+	if otpRecord.ID != pending.ID {
+		return false, nil, ErrOTPSessionMismatch
+	}
+
+	// One-shot: the session cannot be presented again
+	if err := s.DB.Model(otpRecord).Update("session_token_hash", nil).Error; err != nil {
+		return false, otpRecord, fmt.Errorf("failed to close OTP session: %w", err)
+	}
+	otpRecord.SessionTokenHash = nil
+
+	return true, otpRecord, nil
+}
+
+func sessionMatches(otpRecord *otp.OTP, sessionToken string, actorID uint) bool {
+	if otpRecord.SessionTokenHash == nil || otpRecord.IssuedToID == nil || sessionToken == "" {
+		return false
+	}
+	if *otpRecord.IssuedToID != actorID {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(*otpRecord.SessionTokenHash), []byte(hashSessionToken(sessionToken))) == 1
+}
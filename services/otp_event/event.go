@@ -2,19 +2,48 @@ package otp_event
 
 import (
 	"passport-booking/models/otp"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 // SnapshotOTPToEvent writes a full snapshot of an OTP row into OTPEvent with the given event type.
 func SnapshotOTPToEvent(tx *gorm.DB, o *otp.OTP, eventType string) error {
+	ev, err := snapshot(tx, o, eventType)
+	if err != nil {
+		return err
+	}
+	return tx.Create(ev).Error
+}
+
+// RecordDelivery writes a snapshot for one attempt to deliver the code over channel
+// (sms or voice). The event type is <channel>_sent, or <channel>_failed with the
+// provider error when sendErr is set.
+func RecordDelivery(tx *gorm.DB, o *otp.OTP, channel string, sentAt time.Time, sendErr error) error {
+	eventType := channel + "_sent"
+	if sendErr != nil {
+		eventType = channel + "_failed"
+	}
+	ev, err := snapshot(tx, o, eventType)
+	if err != nil {
+		return err
+	}
+	ev.Channel = channel
+	ev.SentAt = &sentAt
+	if sendErr != nil {
+		ev.SendError = sendErr.Error()
+	}
+	return tx.Create(ev).Error
+}
+
+func snapshot(tx *gorm.DB, o *otp.OTP, eventType string) (*otp.OTPEvent, error) {
 	// Make sure related booking is present for event row
 	// If caller already preloaded, this will be filled; else we fetch minimal required ids.
 	if err := tx.Preload("Booking").First(o, o.ID).Error; err != nil {
-		return err
+		return nil, err
 	}
 
-	ev := otp.OTPEvent{
+	ev := &otp.OTPEvent{
 		BookingID:     o.BookingID,
 		Booking:       o.Booking, // optional; gorm will set by ID
 		Phone:         o.Phone,
@@ -31,6 +60,5 @@ func SnapshotOTPToEvent(tx *gorm.DB, o *otp.OTP, eventType string) error {
 		UpdatedAt:     o.UpdatedAt,
 		EventType:     eventType,
 	}
-
-	return tx.Create(&ev).Error
+	return ev, nil
 }
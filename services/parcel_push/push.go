@@ -0,0 +1,362 @@
+package parcel_push
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"passport-booking/httpServices/dms"
+	"passport-booking/logger"
+	"passport-booking/models/parcel_booking"
+	barcodeService "passport-booking/services/barcode"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// EventPushFailed is recorded in the status history when a DMS submission fails
+	EventPushFailed = "push_failed"
+
+	// SystemActor is stored in UpdatedBy when the retry worker changes a booking
+	SystemActor = "system:dms-push"
+
+	defaultBackoffMinutes    = 5
+	defaultMaxBackoffMinutes = 360
+	defaultMaxAttempts       = 8
+	defaultIntervalMinutes   = 2
+	retryBatchSize           = 50
+)
+
+var (
+	ErrNotFound   = errors.New("parcel booking not found")
+	ErrNotPending = errors.New("parcel booking is not in pending status")
+	ErrNoAuth     = errors.New("DMS_SERVICE_TOKEN is not set")
+)
+
+// PushError is a failed DMS submission. Body and StatusCode are set when DMS answered.
+type PushError struct {
+	StatusCode int
+	Body       []byte
+	Err        error
+}
+
+func (e *PushError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("DMS API returned status %d", e.StatusCode)
+}
+
+func (e *PushError) Unwrap() error { return e.Err }
+
+// MaxAttempts is how many submissions are tried before the worker gives up;
+// PARCEL_PUSH_MAX_ATTEMPTS overrides it
+func MaxAttempts() int {
+	return envInt("PARCEL_PUSH_MAX_ATTEMPTS", defaultMaxAttempts)
+}
+
+// Backoff is the wait before retrying after the given number of failed attempts. It
+// doubles from PARCEL_PUSH_BACKOFF_MINUTES up to PARCEL_PUSH_MAX_BACKOFF_MINUTES.
+func Backoff(attempts int) time.Duration {
+	base := time.Duration(envInt("PARCEL_PUSH_BACKOFF_MINUTES", defaultBackoffMinutes)) * time.Minute
+	ceiling := time.Duration(envInt("PARCEL_PUSH_MAX_BACKOFF_MINUTES", defaultMaxBackoffMinutes)) * time.Minute
+	wait := base
+	for i := 1; i < attempts && wait < ceiling; i++ {
+		wait *= 2
+	}
+	if wait > ceiling {
+		wait = ceiling
+	}
+	return wait
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// Pusher submits parcel bookings to DMS and tracks their push_status
+type Pusher struct {
+	DB       *gorm.DB
+	DMS      dms.Client
+	Barcodes *barcodeService.Service
+}
+
+// NewPusher creates a new parcel booking pusher
+func NewPusher(db *gorm.DB, dmsClient dms.Client) *Pusher {
+	return &Pusher{
+		DB:       db,
+		DMS:      dmsClient,
+		Barcodes: barcodeService.NewBarcodeService(db, dmsClient),
+	}
+}
+
+// Push books a pending parcel with DMS. On success the parcel moves to booked; on
+// failure the attempt is recorded, a retry is scheduled and a *PushError returned.
+// actorID is recorded on the status event and updatedBy on the booking.
+func (p *Pusher) Push(authHeader string, parcelBookingID, actorID uint, updatedBy string) (*parcel_booking.ParcelBooking, error) {
+	var parcel parcel_booking.ParcelBooking
+	if err := p.DB.Preload("User").First(&parcel, parcelBookingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if parcel.CurrentStatus != string(parcel_booking.ParcelBookingStatusPending) {
+		return &parcel, ErrNotPending
+	}
+	if parcel.User.Uuid == "" {
+		return &parcel, fmt.Errorf("user information not found for parcel booking")
+	}
+
+	// Swap a provisional barcode for a DMS one before pushing the booking
+	if barcodeService.IsProvisional(parcel.Barcode) {
+		reconciled, err := p.Barcodes.Reconcile(authHeader, parcel.Barcode)
+		if err != nil {
+			pushErr := &PushError{Err: fmt.Errorf("provisional barcode could not be reconciled: %w", err)}
+			return p.recordFailure(&parcel, actorID, pushErr)
+		}
+		parcel.Barcode = reconciled
+	}
+
+	resp, err := p.DMS.BookParcelArticle(authHeader, bookArticlePayload(&parcel))
+	if err != nil {
+		return p.recordFailure(&parcel, actorID, &PushError{Err: fmt.Errorf("failed to call booking API: %w", err)})
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return p.recordFailure(&parcel, actorID, &PushError{StatusCode: resp.StatusCode, Body: resp.Body})
+	}
+
+	logger.Info(fmt.Sprintf("DMS booking successful for barcode %s. Status: %d", parcel.Barcode, resp.StatusCode))
+
+	now := time.Now()
+	err = p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&parcel).Updates(map[string]interface{}{
+			"current_status":  string(parcel_booking.ParcelBookingStatusBooked),
+			"booking_date":    now,
+			"updated_by":      updatedBy,
+			"push_status":     parcel_booking.PushStatusSuccess,
+			"push_attempts":   parcel.PushAttempts + 1,
+			"push_last_error": nil,
+			"last_push_at":    now,
+			"next_push_at":    nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&parcel_booking.ParcelBookingStatusEvent{
+			ParcelBookingID: parcel.ID,
+			Status:          string(parcel_booking.ParcelBookingStatusBooked),
+			CreatedBy:       actorID,
+		}).Error
+	})
+	if err != nil {
+		return &parcel, fmt.Errorf("DMS accepted the booking but it could not be saved: %w", err)
+	}
+
+	p.DB.Preload("User").First(&parcel, parcel.ID)
+	return &parcel, nil
+}
+
+// recordFailure stores a failed attempt and schedules the next one, or none once
+// MaxAttempts is reached
+func (p *Pusher) recordFailure(parcel *parcel_booking.ParcelBooking, actorID uint, pushErr *PushError) (*parcel_booking.ParcelBooking, error) {
+	now := time.Now()
+	attempts := parcel.PushAttempts + 1
+	msg := pushErr.Error()
+	if len(pushErr.Body) > 0 {
+		msg = fmt.Sprintf("%s: %s", msg, string(pushErr.Body))
+	}
+
+	var next *time.Time
+	if attempts < MaxAttempts() {
+		at := now.Add(Backoff(attempts))
+		next = &at
+	}
+
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(parcel).Updates(map[string]interface{}{
+			"push_status":     parcel_booking.PushStatusFailed,
+			"push_attempts":   attempts,
+			"push_last_error": msg,
+			"last_push_at":    now,
+			"next_push_at":    next,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&parcel_booking.ParcelBookingStatusEvent{
+			ParcelBookingID: parcel.ID,
+			Status:          EventPushFailed,
+			CreatedBy:       actorID,
+		}).Error
+	})
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to record DMS push failure for parcel_booking_id: %d", parcel.ID), err)
+	}
+
+	parcel.PushStatus = parcel_booking.PushStatusFailed
+	parcel.PushAttempts = attempts
+	parcel.PushLastError = &msg
+	parcel.LastPushAt = &now
+	parcel.NextPushAt = next
+	return parcel, pushErr
+}
+
+// RetryDue pushes failed parcels whose next attempt is due, using the DMS service
+// token. It returns how many were booked and how many failed again.
+func (p *Pusher) RetryDue() (booked, failed int, err error) {
+	authHeader := dms.ServiceAuthHeader()
+	if authHeader == "" {
+		return 0, 0, ErrNoAuth
+	}
+	// Retrying into an open circuit would only burn attempts
+	if dms.Degraded() {
+		return 0, 0, nil
+	}
+
+	var due []parcel_booking.ParcelBooking
+	if err := p.DB.Select("id", "user_id", "next_push_at").
+		Where("push_status = ? AND current_status = ? AND next_push_at <= ?",
+			parcel_booking.PushStatusFailed, string(parcel_booking.ParcelBookingStatusPending), time.Now()).
+		Order("next_push_at").
+		Limit(retryBatchSize).
+		Find(&due).Error; err != nil {
+		return 0, 0, err
+	}
+
+	for _, parcel := range due {
+		// Claim the retry so a concurrent worker or manual submit does not push it twice
+		claim := p.DB.Model(&parcel_booking.ParcelBooking{}).
+			Where("id = ? AND push_status = ? AND next_push_at = ?", parcel.ID, parcel_booking.PushStatusFailed, parcel.NextPushAt).
+			Update("next_push_at", nil)
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+
+		_, err := p.Push(authHeader, parcel.ID, parcel.UserID, SystemActor)
+		var pushErr *PushError
+		switch {
+		case err == nil:
+			booked++
+		case errors.As(err, &pushErr):
+			failed++
+		default:
+			logger.Error(fmt.Sprintf("DMS push retry failed for parcel_booking_id: %d", parcel.ID), err)
+			failed++
+		}
+	}
+	return booked, failed, nil
+}
+
+// StartScheduler runs RetryDue every PARCEL_PUSH_INTERVAL_MINUTES (default 2).
+// The returned function stops the job.
+func StartScheduler(db *gorm.DB) func() {
+	pusher := NewPusher(db, dms.NewDMSService())
+	ticker := time.NewTicker(time.Duration(envInt("PARCEL_PUSH_INTERVAL_MINUTES", defaultIntervalMinutes)) * time.Minute)
+	done := make(chan struct{})
+
+	run := func() {
+		booked, failed, err := pusher.RetryDue()
+		if err != nil {
+			if errors.Is(err, ErrNoAuth) {
+				logger.Warning("Parcel DMS push retries are disabled: " + err.Error())
+			} else {
+				logger.Error("Parcel DMS push retry run failed", err)
+			}
+			return
+		}
+		if booked > 0 || failed > 0 {
+			logger.Info(fmt.Sprintf("Parcel DMS push retries: %d booked, %d failed", booked, failed))
+		}
+	}
+
+	go func() {
+		run()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// Stuck returns failed pushes that have given up or have been pending longer than
+// olderThan, oldest first
+func Stuck(db *gorm.DB, olderThan time.Duration, limit int) ([]parcel_booking.ParcelBooking, error) {
+	var parcels []parcel_booking.ParcelBooking
+	err := db.Where("push_status = ? AND current_status = ?",
+		parcel_booking.PushStatusFailed, string(parcel_booking.ParcelBookingStatusPending)).
+		Where("(next_push_at IS NULL OR COALESCE(pending_date, created_at) <= ?)", time.Now().Add(-olderThan)).
+		Order("COALESCE(pending_date, created_at)").
+		Limit(limit).
+		Find(&parcels).Error
+	return parcels, err
+}
+
+// bookArticlePayload builds the DMS book article request for a parcel booking
+func bookArticlePayload(parcel *parcel_booking.ParcelBooking) dms.ParcelBookArticleRequest {
+	return dms.ParcelBookArticleRequest{
+		AdPodID:         "1",
+		ArticleDesc:     "Passport Delivery",
+		ArticlePrice:    100,
+		Barcode:         parcel.Barcode,
+		CityPostStatus:  "No",
+		DeliveryBranch:  "100000",
+		EmtsBranchCode:  "100000",
+		Height:          10,
+		HndDevice:       "web",
+		ImagePod:        "0",
+		ImageSrc:        "No",
+		InsurancePrice:  "0",
+		IsBulkMail:      "No",
+		IsCharge:        "Yes",
+		IsCityPost:      "No",
+		IsInternational: false,
+		IsStation:       "No",
+		Length:          10,
+		Receiver: dms.Address{
+			AddressType:   "home",
+			Country:       "Bangladesh",
+			District:      parcel.RpoName, // Using RpoName as district
+			Division:      "",             // Can be enhanced if needed
+			PhoneNumber:   parcel.Phone,
+			PoliceStation: "",
+			PostOffice:    parcel.PostCode,
+			StreetAddress: parcel.RpoAddress,
+			UserUUID:      parcel.User.Uuid,
+			Username:      parcel.User.Username,
+			Zone:          "Zone 1",
+		},
+		Sender: dms.Address{
+			AddressType:   "office",
+			Country:       "Bangladesh",
+			District:      "Dhaka",
+			Division:      "Dhaka",
+			PhoneNumber:   "018XXXXXXXX",
+			PoliceStation: "Gulshan",
+			PostOffice:    "Gulshan",
+			StreetAddress: "456, Gulshan, Dhaka",
+			UserUUID:      parcel.User.Uuid,
+			Username:      "passport-office",
+			Zone:          "Zone 2",
+		},
+		ServiceName: "letter",
+		SetAd:       "No",
+		VasType:     "Registry",
+		VpAmount:    "0",
+		VpService:   "No",
+		Weight:      100,
+		Width:       10,
+	}
+}
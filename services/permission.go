@@ -56,8 +56,11 @@ func (ps *PermissionService) RequireAnyPermission(c *fiber.Ctx, permissions ...s
 
 // GetUserInfo returns user information from JWT claims
 func (ps *PermissionService) GetUserInfo(c *fiber.Ctx) (jwt.MapClaims, bool) {
-	userClaims, ok := c.Locals("user").(jwt.MapClaims)
-	return userClaims, ok
+	current, _ := middleware.GetCurrentUser(c)
+	if current == nil {
+		return nil, false
+	}
+	return current.Claims, true
 }
 
 // GetUserID returns user ID from JWT claims
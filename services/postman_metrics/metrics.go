@@ -0,0 +1,258 @@
+package postman_metrics
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	reportModel "passport-booking/models/report"
+	"passport-booking/services/clock"
+	"passport-booking/services/localtime"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// PeriodLayout is the format of PostmanMetric.Period
+	PeriodLayout = "2006-01"
+
+	defaultOnTimeHours     = 48
+	defaultDailyTarget     = 20
+	defaultIntervalMinutes = 60
+	onTimeWeight           = 0.5
+	firstAttemptWeight     = 0.3
+	throughputWeight       = 0.2
+	sendOtpEventType       = "delivery_confirmation_send_otp"
+)
+
+// Service computes the monthly postman metrics
+type Service struct {
+	DB *gorm.DB
+	// Clock stamps computed metrics and picks the periods the scheduler recomputes;
+	// nil means the system clock
+	Clock clock.Clock
+}
+
+// NewPostmanMetricsService creates a new postman metrics service
+func NewPostmanMetricsService(db *gorm.DB) *Service {
+	return &Service{DB: db}
+}
+
+func (s *Service) now() time.Time {
+	return clock.OrSystem(s.Clock).Now()
+}
+
+// OnTimeWindow is how long after receiving an item a delivery still counts as on time;
+// POSTMAN_ON_TIME_HOURS overrides it
+func OnTimeWindow() time.Duration {
+	return time.Duration(envInt("POSTMAN_ON_TIME_HOURS", defaultOnTimeHours)) * time.Hour
+}
+
+// DailyTarget is the items/day at which the throughput part of the score is maxed out;
+// POSTMAN_DAILY_TARGET overrides it
+func DailyTarget() int {
+	return envInt("POSTMAN_DAILY_TARGET", defaultDailyTarget)
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// PeriodBounds returns the [start, end) range of a YYYY-MM period
+func PeriodBounds(period string) (time.Time, time.Time, error) {
+	start, err := localtime.ParseDate(PeriodLayout, period)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid period format. Use 'YYYY-MM'")
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// deliveryRow is one booking delivered by a postman within the period
+type deliveryRow struct {
+	PostmanID   uint
+	BookingID   uint
+	BranchCode  *string
+	ReceivedAt  *time.Time
+	DeliveredAt time.Time
+	OtpAttempts int64
+}
+
+// Compute recalculates and stores the metrics of every postman active in period
+func (s *Service) Compute(period string) error {
+	db := s.DB
+	start, end, err := PeriodBounds(period)
+	if err != nil {
+		return err
+	}
+
+	// Items handed to each postman in the period
+	var received []struct {
+		PostmanID uint
+		Total     int64
+	}
+	if err := db.Table("booking_status_events").
+		Select("CAST(created_by AS bigint) AS postman_id, COUNT(DISTINCT booking_id) AS total").
+		Where("status = ? AND created_at >= ? AND created_at < ?", bookingModel.BookingItemStatusReceivedByPostman, start, end).
+		Where("created_by ~ '^[0-9]+$'").
+		Group("created_by").
+		Scan(&received).Error; err != nil {
+		return fmt.Errorf("failed to count received items: %w", err)
+	}
+
+	// Deliveries in the period with the matching receive time and number of OTP sends
+	var deliveries []deliveryRow
+	if err := db.Raw(`
+		SELECT CAST(d.created_by AS bigint) AS postman_id,
+			d.booking_id,
+			b.delivery_branch_code AS branch_code,
+			(SELECT MAX(r.created_at) FROM booking_status_events r
+				WHERE r.booking_id = d.booking_id AND r.status = ? AND r.created_at <= d.created_at) AS received_at,
+			d.created_at AS delivered_at,
+			(SELECT COUNT(*) FROM booking_events e
+				WHERE e.app_or_order_id = b.app_or_order_id AND e.event_type = ? AND e.created_at <= d.created_at) AS otp_attempts
+		FROM booking_status_events d
+		JOIN bookings b ON b.id = d.booking_id
+		WHERE d.status = ? AND d.created_at >= ? AND d.created_at < ?
+			AND d.created_by ~ '^[0-9]+$'`,
+		bookingModel.BookingItemStatusReceivedByPostman,
+		sendOtpEventType,
+		bookingModel.BookingStatusDelivered, start, end,
+	).Scan(&deliveries).Error; err != nil {
+		return fmt.Errorf("failed to load deliveries: %w", err)
+	}
+
+	now := s.now()
+	metrics := make(map[uint]*reportModel.PostmanMetric)
+	metricFor := func(postmanID uint) *reportModel.PostmanMetric {
+		m, ok := metrics[postmanID]
+		if !ok {
+			m = &reportModel.PostmanMetric{PostmanID: postmanID, Period: period, ComputedAt: now}
+			metrics[postmanID] = m
+		}
+		return m
+	}
+
+	for _, r := range received {
+		metricFor(r.PostmanID).ItemsReceived = r.Total
+	}
+
+	onTime := OnTimeWindow()
+	activeDays := make(map[uint]map[string]bool)
+	branches := make(map[uint]map[string]int)
+	for _, d := range deliveries {
+		m := metricFor(d.PostmanID)
+		m.Delivered++
+		if d.ReceivedAt != nil && d.DeliveredAt.Sub(*d.ReceivedAt) <= onTime {
+			m.OnTimeDelivered++
+		}
+		if d.OtpAttempts <= 1 {
+			m.FirstAttemptDelivered++
+		}
+
+		if activeDays[d.PostmanID] == nil {
+			activeDays[d.PostmanID] = make(map[string]bool)
+			branches[d.PostmanID] = make(map[string]int)
+		}
+		activeDays[d.PostmanID][localtime.In(d.DeliveredAt).Format("2006-01-02")] = true
+		if d.BranchCode != nil {
+			branches[d.PostmanID][*d.BranchCode]++
+		}
+	}
+
+	rows := make([]reportModel.PostmanMetric, 0, len(metrics))
+	for postmanID, m := range metrics {
+		m.ActiveDays = int64(len(activeDays[postmanID]))
+		m.BranchCode = topBranch(branches[postmanID])
+		score(m)
+		rows = append(rows, *m)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Drop postmen that no longer have activity in the period (e.g. corrected events)
+		if err := tx.Where("period = ?", period).Delete(&reportModel.PostmanMetric{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "postman_id"}, {Name: "period"}},
+			UpdateAll: true,
+		}).CreateInBatches(rows, 200).Error
+	})
+}
+
+// score fills the rates and the weighted 0-100 score of m
+func score(m *reportModel.PostmanMetric) {
+	if m.Delivered > 0 {
+		m.OnTimeRate = round(float64(m.OnTimeDelivered) / float64(m.Delivered))
+		m.FirstAttemptRate = round(float64(m.FirstAttemptDelivered) / float64(m.Delivered))
+	}
+	if m.ActiveDays > 0 {
+		m.AvgItemsPerDay = round(float64(m.Delivered) / float64(m.ActiveDays))
+	}
+
+	throughput := math.Min(m.AvgItemsPerDay/float64(DailyTarget()), 1)
+	m.Score = round(100 * (onTimeWeight*m.OnTimeRate + firstAttemptWeight*m.FirstAttemptRate + throughputWeight*throughput))
+}
+
+func topBranch(counts map[string]int) *string {
+	var best string
+	bestCount := 0
+	for code, count := range counts {
+		if count > bestCount || (count == bestCount && code < best) {
+			best, bestCount = code, count
+		}
+	}
+	if bestCount == 0 {
+		return nil
+	}
+	return &best
+}
+
+func round(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// StartScheduler recomputes the current month (and the previous one, so late events
+// are picked up after the month closes) every POSTMAN_METRICS_INTERVAL_MINUTES
+// (default 60). The returned function stops the job.
+func (s *Service) StartScheduler() func() {
+	interval := time.Duration(envInt("POSTMAN_METRICS_INTERVAL_MINUTES", defaultIntervalMinutes)) * time.Minute
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	run := func() {
+		now := localtime.In(s.now())
+		current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		for _, period := range []string{current.AddDate(0, -1, 0).Format(PeriodLayout), current.Format(PeriodLayout)} {
+			if err := s.Compute(period); err != nil {
+				logger.Error(fmt.Sprintf("Failed to compute postman metrics for %s", period), err)
+			}
+		}
+	}
+
+	go func() {
+		run()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
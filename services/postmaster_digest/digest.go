@@ -0,0 +1,293 @@
+// Package postmaster_digest sends each postmaster a morning summary of their
+// branches: bags on the way that were not received, items stuck at the branch,
+// deliveries held for review and OTP blocks. It goes through the notification
+// router, so the postmaster's channel and language apply, and can be turned off with
+// the postmaster_digest preference.
+package postmaster_digest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"passport-booking/constants"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	fraudModel "passport-booking/models/fraud"
+	notificationModel "passport-booking/models/notification"
+	userModel "passport-booking/models/user"
+	"passport-booking/services/localtime"
+	"passport-booking/services/notification"
+	"passport-booking/services/preference"
+	"passport-booking/services/user_admin"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultHour     = 8
+	defaultAgedDays = 7
+	maxDigestLines  = 10
+	maxBranchNames  = 3
+)
+
+// agedStatuses are the statuses of items that reached the branch but were not delivered
+var agedStatuses = []bookingModel.BookingStatus{
+	bookingModel.BookingStatusReceivedByPostMaster,
+	bookingModel.BookingStatusReceivedByPostman,
+	bookingModel.BookingItemStatusReceivedByPostman,
+}
+
+// Digest is the summary of one postmaster's branches
+type Digest struct {
+	BranchCodes []string  `json:"branch_codes"`
+	PendingBags int64     `json:"pending_bags"`
+	AgedItems   int64     `json:"aged_items"`
+	AgedDays    int       `json:"aged_days"`
+	Escalations int64     `json:"escalations"`
+	OTPBlocks   int64     `json:"otp_blocks"`
+	Oldest      []AgedRow `json:"oldest"`
+}
+
+// AgedRow is an item at the branch without progress
+type AgedRow struct {
+	Barcode   *string                    `json:"barcode"`
+	Reference *string                    `json:"reference"`
+	Status    bookingModel.BookingStatus `json:"status"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+}
+
+// Empty reports whether there is nothing to tell the postmaster
+func (d *Digest) Empty() bool {
+	return d.PendingBags == 0 && d.AgedItems == 0 && d.Escalations == 0 && d.OTPBlocks == 0
+}
+
+// AgedDays is how long an item may sit at a branch before the digest lists it,
+// POSTMASTER_DIGEST_AGED_DAYS (default 7)
+func AgedDays() int {
+	return envInt("POSTMASTER_DIGEST_AGED_DAYS", defaultAgedDays)
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// Build summarizes the branches as of now
+func Build(db *gorm.DB, branchCodes []string, now time.Time) (*Digest, error) {
+	d := &Digest{BranchCodes: branchCodes, AgedDays: AgedDays(), Oldest: []AgedRow{}}
+	if len(branchCodes) == 0 {
+		return d, nil
+	}
+
+	if err := db.Model(&bookingModel.Bag{}).
+		Where("dest_office_code IN ? AND status = ?", branchCodes, bookingModel.BagStatusClosed).
+		Count(&d.PendingBags).Error; err != nil {
+		return nil, fmt.Errorf("failed to count pending bags: %w", err)
+	}
+
+	aged := db.Model(&bookingModel.Booking{}).
+		Where("delivery_branch_code IN ? AND status IN ? AND deleted_at IS NULL", branchCodes, agedStatuses).
+		Where("updated_at < ?", now.AddDate(0, 0, -d.AgedDays))
+	if err := aged.Count(&d.AgedItems).Error; err != nil {
+		return nil, fmt.Errorf("failed to count aged items: %w", err)
+	}
+	if d.AgedItems > 0 {
+		if err := aged.Select("barcode, reference, status, updated_at").
+			Order("updated_at").Limit(maxDigestLines).
+			Scan(&d.Oldest).Error; err != nil {
+			return nil, fmt.Errorf("failed to list aged items: %w", err)
+		}
+	}
+
+	if err := db.Model(&fraudModel.FraudCase{}).
+		Joins("JOIN bookings b ON b.id = fraud_cases.booking_id").
+		Where("fraud_cases.status = ? AND b.delivery_branch_code IN ?", fraudModel.CaseStatusOpen, branchCodes).
+		Count(&d.Escalations).Error; err != nil {
+		return nil, fmt.Errorf("failed to count escalations: %w", err)
+	}
+
+	if err := db.Table("otps o").
+		Joins("JOIN bookings b ON b.id = o.booking_id").
+		Where("o.is_blocked AND o.last_attempt_at >= ? AND b.delivery_branch_code IN ?", now.Add(-24*time.Hour), branchCodes).
+		Count(&d.OTPBlocks).Error; err != nil {
+		return nil, fmt.Errorf("failed to count OTP blocks: %w", err)
+	}
+	return d, nil
+}
+
+// data turns the digest into template data for the notification
+func (d *Digest) data(now time.Time) notification.Data {
+	names := d.BranchCodes
+	if len(names) > maxBranchNames {
+		names = append(append([]string{}, names[:maxBranchNames]...), fmt.Sprintf("+%d more", len(d.BranchCodes)-maxBranchNames))
+	}
+	lines := make([]string, 0, len(d.Oldest))
+	for _, row := range d.Oldest {
+		id := "-"
+		if row.Barcode != nil {
+			id = *row.Barcode
+		} else if row.Reference != nil {
+			id = *row.Reference
+		}
+		days := int(now.Sub(row.UpdatedAt).Hours() / 24)
+		lines = append(lines, fmt.Sprintf("%s  %s  %d days", id, row.Status, days))
+	}
+	return notification.Data{
+		BranchName:  strings.Join(names, ", "),
+		DigestDate:  &now,
+		PendingBags: int(d.PendingBags),
+		AgedItems:   int(d.AgedItems),
+		AgedDays:    d.AgedDays,
+		Escalations: int(d.Escalations),
+		OTPBlocks:   int(d.OTPBlocks),
+		DigestLines: lines,
+	}
+}
+
+// Result counts what one run did
+type Result struct {
+	Postmasters int `json:"postmasters"`
+	Sent        int `json:"sent"`
+	OptedOut    int `json:"opted_out"`
+	AlreadySent int `json:"already_sent"`
+	NoBranches  int `json:"no_branches"`
+	Empty       int `json:"empty"`
+	Failed      int `json:"failed"`
+}
+
+// postmasters returns the active users holding the postmaster permission
+func postmasters(db *gorm.DB) ([]userModel.User, error) {
+	encoded, _ := json.Marshal([]string{constants.PermPostOfficeFull})
+	var users []userModel.User
+	err := db.Where("is_active AND deleted_at IS NULL AND approval_status = ?", userModel.ApprovalStatusApproved).
+		Where("permissions::jsonb @> ?", string(encoded)).
+		Order("id").Find(&users).Error
+	return users, err
+}
+
+// sentToday reports whether the user already got today's digest, so a restart after
+// the digest hour does not send it twice
+func sentToday(db *gorm.DB, userID uint, now time.Time) (bool, error) {
+	var count int64
+	err := db.Model(&notificationModel.NotificationLog{}).
+		Where("user_id = ? AND kind = ? AND created_at >= ?", userID, notification.KindPostmasterDigest, localtime.StartOfDay(now)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Run sends today's digest to every postmaster who has not had it and has not opted
+// out. Digests with nothing to report are skipped.
+func Run(db *gorm.DB, now time.Time) (*Result, error) {
+	users, err := postmasters(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load postmasters: %w", err)
+	}
+
+	result := &Result{Postmasters: len(users)}
+	for _, user := range users {
+		prefs, err := preference.Get(db, user.ID)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to load preferences of postmaster %d, using defaults", user.ID), err)
+		}
+		if !prefs.PostmasterDigest {
+			result.OptedOut++
+			continue
+		}
+		if sent, err := sentToday(db, user.ID, now); err != nil {
+			logger.Error(fmt.Sprintf("Failed to check the digest log of postmaster %d", user.ID), err)
+			result.Failed++
+			continue
+		} else if sent {
+			result.AlreadySent++
+			continue
+		}
+
+		codes, err := user_admin.BranchScope(db, user.ID)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to resolve branches of postmaster %d", user.ID), err)
+			result.Failed++
+			continue
+		}
+		if len(codes) == 0 {
+			result.NoBranches++
+			continue
+		}
+		digest, err := Build(db, codes, now)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to build the digest of postmaster %d", user.ID), err)
+			result.Failed++
+			continue
+		}
+		if digest.Empty() {
+			result.Empty++
+			continue
+		}
+
+		if err := notification.NewRouter(db).Send(notification.Notification{
+			Kind:   notification.KindPostmasterDigest,
+			UserID: user.ID,
+			Data:   digest.data(now),
+		}); err != nil {
+			logger.Error(fmt.Sprintf("Failed to send the digest of postmaster %d", user.ID), err)
+			result.Failed++
+			continue
+		}
+		result.Sent++
+	}
+	return result, nil
+}
+
+// nextRun returns the next time the digest hour, POSTMASTER_DIGEST_HOUR (default 8)
+// local time, comes around after now
+func nextRun(now time.Time) time.Time {
+	hour := envInt("POSTMASTER_DIGEST_HOUR", defaultHour) % 24
+	next := localtime.StartOfDay(now).Add(time.Duration(hour) * time.Hour)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// StartScheduler sends the digests every day at the digest hour. A process started
+// after the hour catches up on today's digests straight away.
+func StartScheduler(db *gorm.DB) func() {
+	done := make(chan struct{})
+
+	run := func() {
+		result, err := Run(db, time.Now())
+		if err != nil {
+			logger.Error("Failed to send postmaster digests", err)
+			return
+		}
+		if result.Sent > 0 || result.Failed > 0 {
+			logger.Info(fmt.Sprintf("Postmaster digests: %d sent, %d failed, %d opted out, %d empty",
+				result.Sent, result.Failed, result.OptedOut, result.Empty))
+		}
+	}
+
+	go func() {
+		now := time.Now()
+		if localtime.In(now).Hour() >= envInt("POSTMASTER_DIGEST_HOUR", defaultHour)%24 {
+			run()
+		}
+		for {
+			timer := time.NewTimer(time.Until(nextRun(time.Now())))
+			select {
+			case <-timer.C:
+				run()
+			case <-done:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,118 @@
+package preference
+
+import (
+	"os"
+	userModel "passport-booking/models/user"
+	preferenceTypes "passport-booking/types/preference"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Defaults returns the preferences used when a user has not stored a value.
+// DEFAULT_LANGUAGE and DEFAULT_NOTIFICATION_CHANNEL override the built-in defaults.
+func Defaults() preferenceTypes.Preferences {
+	defaults := preferenceTypes.Preferences{
+		Language:            preferenceTypes.LanguageEnglish,
+		NotificationChannel: preferenceTypes.ChannelSMS,
+		PushItemsAssigned:   true,
+		PushEscalations:     true,
+		PostmasterDigest:    true,
+	}
+	if lang := os.Getenv("DEFAULT_LANGUAGE"); lang != "" {
+		defaults.Language = lang
+	}
+	if channel := os.Getenv("DEFAULT_NOTIFICATION_CHANNEL"); channel != "" {
+		defaults.NotificationChannel = channel
+	}
+	return defaults
+}
+
+// Get returns the user's preferences with defaults filled in for missing keys
+func Get(db *gorm.DB, userID uint) (preferenceTypes.Preferences, error) {
+	prefs := Defaults()
+
+	var rows []userModel.UserPreference
+	if err := db.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return prefs, err
+	}
+
+	for _, row := range rows {
+		switch row.Key {
+		case userModel.PreferenceLanguage:
+			prefs.Language = row.Value
+		case userModel.PreferenceNotificationChannel:
+			prefs.NotificationChannel = row.Value
+		case userModel.PreferenceDefaultBranch:
+			prefs.DefaultBranch = row.Value
+		case userModel.PreferencePushItemsAssigned:
+			prefs.PushItemsAssigned = row.Value == "true"
+		case userModel.PreferencePushEscalations:
+			prefs.PushEscalations = row.Value == "true"
+		case userModel.PreferencePostmasterDigest:
+			prefs.PostmasterDigest = row.Value == "true"
+		}
+	}
+	return prefs, nil
+}
+
+// Update stores the preferences present in the request and returns the resolved set
+func Update(db *gorm.DB, userID uint, req preferenceTypes.UpdatePreferencesRequest) (preferenceTypes.Preferences, error) {
+	values := map[userModel.PreferenceKey]*string{
+		userModel.PreferenceLanguage:            req.Language,
+		userModel.PreferenceNotificationChannel: req.NotificationChannel,
+		userModel.PreferenceDefaultBranch:       req.DefaultBranch,
+		userModel.PreferencePushItemsAssigned:   boolValue(req.PushItemsAssigned),
+		userModel.PreferencePushEscalations:     boolValue(req.PushEscalations),
+		userModel.PreferencePostmasterDigest:    boolValue(req.PostmasterDigest),
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for key, value := range values {
+			if value == nil {
+				continue
+			}
+			row := userModel.UserPreference{UserID: userID, Key: key, Value: *value}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+			}).Create(&row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return preferenceTypes.Preferences{}, err
+	}
+
+	return Get(db, userID)
+}
+
+// boolValue stores a boolean preference as "true" or "false"
+func boolValue(b *bool) *string {
+	if b == nil {
+		return nil
+	}
+	value := strconv.FormatBool(*b)
+	return &value
+}
+
+// Language returns the user's preferred language, falling back to the default
+func Language(db *gorm.DB, userID uint) string {
+	prefs, err := Get(db, userID)
+	if err != nil {
+		return Defaults().Language
+	}
+	return prefs.Language
+}
+
+// NotificationChannel returns the channel notifications should be routed through
+func NotificationChannel(db *gorm.DB, userID uint) string {
+	prefs, err := Get(db, userID)
+	if err != nil {
+		return Defaults().NotificationChannel
+	}
+	return prefs.NotificationChannel
+}
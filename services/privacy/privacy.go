@@ -0,0 +1,298 @@
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"passport-booking/services/storage"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// ErasedValue replaces free-text personal data
+	ErasedValue = "[erased]"
+
+	// SystemActor is recorded as the actor of scheduled anonymization
+	SystemActor = "system"
+
+	defaultRetentionMonths = 24
+	defaultIntervalHours   = 24
+	retentionBatchSize     = 200
+)
+
+var (
+	ErrLegalHold         = errors.New("booking is under legal hold")
+	ErrAlreadyAnonymized = errors.New("booking personal data is already erased")
+	ErrBookingNotFound   = errors.New("booking not found")
+)
+
+// erasedAddress clears the street of an address in both languages. The English
+// division, district, police station and post office stay for branch reports; the
+// Bangla ones are free text typed alongside the street and go with it.
+var erasedAddress = map[string]interface{}{
+	"street_address":    nil,
+	"street_address_bn": nil,
+	"division_bn":       nil,
+	"district_bn":       nil,
+	"police_station_bn": nil,
+	"post_office_bn":    nil,
+}
+
+// erasedNotification clears who a message went to and what it said, which carry the
+// applicant's name, phone and codes. Kind, channel and status stay for delivery reports.
+var erasedNotification = map[string]interface{}{
+	"recipient": "",
+	"body":      ErasedValue,
+	"payload":   "",
+}
+
+// RetentionMonths is how long applicant PII is kept after delivery. PII_RETENTION_MONTHS
+// overrides the default of 24; 0 turns scheduled anonymization off.
+func RetentionMonths() int {
+	if v := os.Getenv("PII_RETENTION_MONTHS"); v != "" {
+		if months, err := strconv.Atoi(v); err == nil && months >= 0 {
+			return months
+		}
+	}
+	return defaultRetentionMonths
+}
+
+// Anonymize erases the applicant's personal data from a booking and everything that
+// mirrors it (event snapshots, OTP and consent records, notifications sent about it,
+// street address, delivery and ID photos). Status, branch, type and timestamps are kept so reports stay correct.
+func Anonymize(db *gorm.DB, photos storage.FileStorage, bookingID uint, actor string) error {
+	var files []*string
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var booking bookingModel.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, bookingID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookingNotFound
+			}
+			return err
+		}
+		if booking.LegalHold {
+			return ErrLegalHold
+		}
+		if booking.AnonymizedAt != nil {
+			return ErrAlreadyAnonymized
+		}
+
+		now := time.Now()
+		files = []*string{booking.UploadPhoto, booking.RecipientIDPhoto}
+		if err := tx.Model(&booking).Updates(map[string]interface{}{
+			"name":                                 ErasedValue,
+			"father_name":                          ErasedValue,
+			"mother_name":                          ErasedValue,
+			"phone":                                "",
+			"delivery_phone":                       nil,
+			"delivery_phone_apply_otp_encrypted":   nil,
+			"delivery_phone_confirm_otp_encrypted": nil,
+			"address":                              ErasedValue,
+			"emergency_contact_name":               nil,
+			"emergency_contact_phone":              nil,
+			"delivered_latitude":                   nil,
+			"delivered_longitude":                  nil,
+			"upload_photo":                         nil,
+			"upload_photo_hash":                    nil,
+			"recipient_nid_encrypted":              nil,
+			"recipient_id_photo":                   nil,
+			"anonymized_at":                        now,
+			"updated_by":                           actor,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to anonymize booking: %w", err)
+		}
+
+		if err := tx.Table("booking_events").Where("app_or_order_id = ?", booking.AppOrOrderID).Updates(map[string]interface{}{
+			"name":                                   ErasedValue,
+			"father_name":                            ErasedValue,
+			"mother_name":                            ErasedValue,
+			"phone":                                  "",
+			"delivery_phone":                         nil,
+			"delivery_phone_applied_otp_encrypted":   nil,
+			"delivery_phone_confirmed_otp_encrypted": nil,
+			"address":                                ErasedValue,
+			"emergency_contact_name":                 nil,
+			"emergency_contact_phone":                nil,
+			// Compacted events carry payload changes in diff; an empty diff replays the erased values
+			"diff": gorm.Expr("CASE WHEN compacted THEN '{}' END"),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to anonymize booking events: %w", err)
+		}
+
+		for _, table := range []string{"otps", "otp_events"} {
+			if err := tx.Table(table).Where("booking_id = ?", booking.ID).Update("phone", "").Error; err != nil {
+				return fmt.Errorf("failed to anonymize %s: %w", table, err)
+			}
+		}
+
+		if err := tx.Table("booking_phone_consents").Where("booking_id = ?", booking.ID).Updates(map[string]interface{}{
+			"phone":      "",
+			"ip_address": "",
+			"user_agent": "",
+		}).Error; err != nil {
+			return fmt.Errorf("failed to anonymize phone consents: %w", err)
+		}
+
+		if err := tx.Table("notification_logs").Where("booking_id = ?", booking.ID).
+			Updates(erasedNotification).Error; err != nil {
+			return fmt.Errorf("failed to anonymize notifications: %w", err)
+		}
+
+		if booking.DeliveryAddressID != nil {
+			if err := tx.Table("addresses").Where("id = ?", *booking.DeliveryAddressID).
+				Updates(erasedAddress).Error; err != nil {
+				return fmt.Errorf("failed to anonymize delivery address: %w", err)
+			}
+		}
+
+		// Addresses left behind by delivery address changes
+		if err := tx.Table("addresses").
+			Where("id IN (?) OR id IN (?)",
+				tx.Table("booking_address_changes").Select("old_address_id").Where("booking_id = ?", booking.ID),
+				tx.Table("booking_address_changes").Select("new_address_id").Where("booking_id = ?", booking.ID)).
+			Updates(erasedAddress).Error; err != nil {
+			return fmt.Errorf("failed to anonymize changed addresses: %w", err)
+		}
+
+		return booking_event.SnapshotBookingToEvent(tx, &booking, "pii_anonymized", actor)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Files are removed only once the database no longer points at them. Bookings
+	// delivered as a group share one delivery photo.
+	for _, photo := range files {
+		if photo == nil || *photo == "" {
+			continue
+		}
+		var refs int64
+		if err := db.Model(&bookingModel.Booking{}).
+			Where("upload_photo = ? OR recipient_id_photo = ?", *photo, *photo).
+			Count(&refs).Error; err != nil || refs > 0 {
+			continue
+		}
+		if photos.Exists(*photo) {
+			if err := photos.Remove(*photo); err != nil {
+				logger.Error(fmt.Sprintf("Failed to remove photo of anonymized booking %d", bookingID), err)
+			}
+		}
+	}
+	return nil
+}
+
+// RunRetention anonymizes delivered bookings whose delivery is older than the retention
+// period and returns how many were erased. Notifications older than the period are
+// erased too, as many are about bookings that were never delivered or about no
+// booking at all.
+func RunRetention(db *gorm.DB, photos storage.FileStorage) (int, error) {
+	months := RetentionMonths()
+	if months == 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, -months, 0)
+
+	if err := eraseOldNotifications(db, cutoff); err != nil {
+		return 0, err
+	}
+
+	erased := 0
+	for {
+		var ids []uint
+		if err := db.Table("bookings AS b").
+			Select("b.id").
+			Where("b.status = ? AND b.anonymized_at IS NULL AND b.legal_hold = ?", bookingModel.BookingStatusDelivered, false).
+			Where(`COALESCE(
+				(SELECT MAX(e.created_at) FROM booking_status_events e WHERE e.booking_id = b.id AND e.status = ?),
+				b.updated_at) < ?`, bookingModel.BookingStatusDelivered, cutoff).
+			Order("b.id").
+			Limit(retentionBatchSize).
+			Pluck("b.id", &ids).Error; err != nil {
+			return erased, fmt.Errorf("failed to find bookings past retention: %w", err)
+		}
+		if len(ids) == 0 {
+			return erased, nil
+		}
+
+		processed := 0
+		for _, id := range ids {
+			err := Anonymize(db, photos, id, SystemActor)
+			switch {
+			case err == nil:
+				erased++
+				processed++
+			case errors.Is(err, ErrLegalHold), errors.Is(err, ErrAlreadyAnonymized):
+				// Changed since the batch was selected
+				processed++
+			default:
+				logger.Error(fmt.Sprintf("Failed to anonymize booking %d", id), err)
+			}
+		}
+		// Stop instead of looping over the same failing rows
+		if processed == 0 {
+			return erased, nil
+		}
+	}
+}
+
+// eraseOldNotifications erases notifications sent before cutoff, except those about a
+// booking under legal hold
+func eraseOldNotifications(db *gorm.DB, cutoff time.Time) error {
+	result := db.Table("notification_logs").
+		Where("created_at < ? AND recipient <> ''", cutoff).
+		Where("booking_id IS NULL OR booking_id NOT IN (?)", db.Table("bookings").Select("id").Where("legal_hold = ?", true)).
+		Updates(erasedNotification)
+	if result.Error != nil {
+		return fmt.Errorf("failed to erase notifications past retention: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		logger.Info(fmt.Sprintf("PII retention erased %d notifications", result.RowsAffected))
+	}
+	return nil
+}
+
+// StartScheduler runs RunRetention every PII_RETENTION_INTERVAL_HOURS (default 24).
+// The returned function stops the job.
+func StartScheduler(db *gorm.DB, photos storage.FileStorage) func() {
+	interval := defaultIntervalHours * time.Hour
+	if v := os.Getenv("PII_RETENTION_INTERVAL_HOURS"); v != "" {
+		if hours, err := strconv.Atoi(v); err == nil && hours > 0 {
+			interval = time.Duration(hours) * time.Hour
+		}
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	run := func() {
+		erased, err := RunRetention(db, photos)
+		if err != nil {
+			logger.Error("PII retention run failed", err)
+		}
+		if erased > 0 {
+			logger.Info(fmt.Sprintf("PII retention anonymized %d bookings", erased))
+		}
+	}
+
+	go func() {
+		run()
+		for {
+			select {
+			case <-ticker.C:
+				run()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,64 @@
+// Package query_count counts the SQL statements a request runs, so list endpoints can
+// be held to a fixed query budget instead of growing a query per row.
+package query_count
+
+import (
+	"context"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+type contextKey struct{}
+
+// Counter holds the number of statements run on a context
+type Counter struct {
+	n atomic.Int64
+}
+
+// Count returns the statements counted so far
+func (c *Counter) Count() int64 {
+	return c.n.Load()
+}
+
+// WithCounter returns a context that counts the statements run on it
+func WithCounter(ctx context.Context) (context.Context, *Counter) {
+	counter := &Counter{}
+	return context.WithValue(ctx, contextKey{}, counter), counter
+}
+
+// FromContext returns the counter of the context, if any
+func FromContext(ctx context.Context) (*Counter, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	counter, ok := ctx.Value(contextKey{}).(*Counter)
+	return counter, ok
+}
+
+// RegisterCallbacks counts every statement whose context carries a counter. Preloads
+// run on the context of the query that asked for them, so each preload counts once.
+func RegisterCallbacks(db *gorm.DB) error {
+	count := func(tx *gorm.DB) {
+		if counter, ok := FromContext(tx.Statement.Context); ok {
+			counter.n.Add(1)
+		}
+	}
+	cb := db.Callback()
+	if err := cb.Query().After("gorm:query").Register("query_count:query", count); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("query_count:create", count); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("query_count:update", count); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("query_count:delete", count); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("query_count:row", count); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("query_count:raw", count)
+}
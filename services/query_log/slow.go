@@ -0,0 +1,263 @@
+// Package query_log is the GORM logger of the service. Statements slower than
+// DB_SLOW_QUERY_MS are logged and counted with their parameters masked, so the log can
+// show which query scanned a table without copying applicant data into it.
+package query_log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/config"
+	"passport-booking/logger"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+const (
+	defaultSlowQueryMs = 200
+	// recentSize is how many of the latest slow queries Stats keeps
+	recentSize = 50
+)
+
+// SlowQuery is one statement that went over the threshold
+type SlowQuery struct {
+	SQL        string    `json:"sql"`
+	DurationMs int64     `json:"duration_ms"`
+	Rows       int64     `json:"rows"`
+	At         time.Time `json:"at"`
+}
+
+// Stats is the slow_queries metric since the process started
+type Stats struct {
+	ThresholdMs int64       `json:"threshold_ms"`
+	SlowQueries int64       `json:"slow_queries"`
+	TotalMs     int64       `json:"total_ms"`
+	MaxMs       int64       `json:"max_ms"`
+	Recent      []SlowQuery `json:"recent"`
+}
+
+// Logger logs failed and slow statements. Parameters never reach the log as they
+// are: strings and bytes are masked, numbers, booleans and times are kept.
+type Logger struct {
+	level     gormLogger.LogLevel
+	threshold time.Duration
+
+	mu      sync.Mutex
+	count   int64
+	total   time.Duration
+	max     time.Duration
+	recent  []SlowQuery
+	nextPos int
+}
+
+var (
+	std     *Logger
+	stdOnce sync.Once
+)
+
+// Default is the logger installed on the database connection
+func Default() *Logger {
+	stdOnce.Do(func() {
+		std = New()
+	})
+	return std
+}
+
+// New creates a logger with the threshold from DB_SLOW_QUERY_MS (default 200). Every
+// statement is logged when the profile enables verbose SQL.
+func New() *Logger {
+	level := gormLogger.Warn
+	if config.CurrentProfile().VerboseSQL {
+		level = gormLogger.Info
+	}
+	return &Logger{
+		level:     level,
+		threshold: time.Duration(envInt("DB_SLOW_QUERY_MS", defaultSlowQueryMs)) * time.Millisecond,
+	}
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+// LogMode returns a copy of the logger at the given level sharing the same metric
+func (l *Logger) LogMode(level gormLogger.LogLevel) gormLogger.Interface {
+	return &leveled{Logger: l, level: level}
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.info(l.level, msg, args...)
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.warn(l.level, msg, args...)
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.error(l.level, msg, args...)
+}
+
+func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.trace(l.level, begin, fc, err)
+}
+
+// ParamsFilter masks the statement parameters before GORM renders them into the SQL
+// handed to Trace
+func (l *Logger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	masked := make([]interface{}, len(params))
+	for i, p := range params {
+		masked[i] = mask(p)
+	}
+	return sql, masked
+}
+
+// mask keeps values that cannot identify anyone. Named string types are the model
+// enums, such as booking statuses, and stay readable; plain strings and bytes are
+// replaced by their length.
+func mask(p interface{}) interface{} {
+	if p == nil {
+		return nil
+	}
+	if t, ok := p.(time.Time); ok {
+		return t
+	}
+	v := reflect.ValueOf(p)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return v.Interface()
+	case reflect.String:
+		if v.Type() != reflect.TypeOf("") {
+			return v.String()
+		}
+		return fmt.Sprintf("<%d chars>", v.Len())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return fmt.Sprintf("<%d bytes>", v.Len())
+		}
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return t
+		}
+	}
+	return "<masked>"
+}
+
+func (l *Logger) info(level gormLogger.LogLevel, msg string, args ...interface{}) {
+	if level >= gormLogger.Info {
+		logger.Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *Logger) warn(level gormLogger.LogLevel, msg string, args ...interface{}) {
+	if level >= gormLogger.Warn {
+		logger.Warning(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *Logger) error(level gormLogger.LogLevel, msg string, args ...interface{}) {
+	if level >= gormLogger.Error {
+		logger.Error(fmt.Sprintf(msg, args...), nil)
+	}
+}
+
+func (l *Logger) trace(level gormLogger.LogLevel, begin time.Time, fc func() (string, int64), err error) {
+	if level <= gormLogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && level >= gormLogger.Error:
+		sql, rows := fc()
+		logger.Error(fmt.Sprintf("Query failed duration_ms=%d rows=%d sql=%q", elapsed.Milliseconds(), rows, sql), err)
+	case elapsed >= l.threshold:
+		sql, rows := fc()
+		l.record(SlowQuery{SQL: sql, DurationMs: elapsed.Milliseconds(), Rows: rows, At: begin})
+		if level >= gormLogger.Warn {
+			logger.Warning(fmt.Sprintf("Slow query duration_ms=%d threshold_ms=%d rows=%d sql=%q",
+				elapsed.Milliseconds(), l.threshold.Milliseconds(), rows, sql))
+		}
+	case level >= gormLogger.Info:
+		sql, rows := fc()
+		logger.Info(fmt.Sprintf("Query duration_ms=%d rows=%d sql=%q", elapsed.Milliseconds(), rows, sql))
+	}
+}
+
+func (l *Logger) record(q SlowQuery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d := time.Duration(q.DurationMs) * time.Millisecond
+	l.count++
+	l.total += d
+	if d > l.max {
+		l.max = d
+	}
+	if len(l.recent) < recentSize {
+		l.recent = append(l.recent, q)
+		return
+	}
+	l.recent[l.nextPos] = q
+	l.nextPos = (l.nextPos + 1) % recentSize
+}
+
+// Stats returns the slow query counters and the latest slow queries, newest first
+func (l *Logger) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := Stats{
+		ThresholdMs: l.threshold.Milliseconds(),
+		SlowQueries: l.count,
+		TotalMs:     l.total.Milliseconds(),
+		MaxMs:       l.max.Milliseconds(),
+		Recent:      make([]SlowQuery, 0, len(l.recent)),
+	}
+	for i := len(l.recent) - 1; i >= 0; i-- {
+		stats.Recent = append(stats.Recent, l.recent[(l.nextPos+i)%len(l.recent)])
+	}
+	return stats
+}
+
+// leveled is the logger at another level, as returned by LogMode for Debug sessions
+type leveled struct {
+	*Logger
+	level gormLogger.LogLevel
+}
+
+func (l *leveled) LogMode(level gormLogger.LogLevel) gormLogger.Interface {
+	return &leveled{Logger: l.Logger, level: level}
+}
+
+func (l *leveled) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.info(l.level, msg, args...)
+}
+
+func (l *leveled) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.warn(l.level, msg, args...)
+}
+
+func (l *leveled) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.error(l.level, msg, args...)
+}
+
+func (l *leveled) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.trace(l.level, begin, fc, err)
+}
@@ -0,0 +1,246 @@
+// Package research_dataset builds the anonymized delivery dataset shared with the
+// ministry's statistics division. Records carry no names, phones, addresses or IDs:
+// only the month, division and district, booking type, outcome and a lead-time band.
+// Every published cell covers at least K bookings; smaller cells are generalized
+// from district to division to the whole country and suppressed if still too small.
+package research_dataset
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/localtime"
+	"passport-booking/services/transliterate"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Any marks a field generalized away to meet the k-anonymity threshold
+const Any = "*"
+
+// MinK is the smallest group size that may be published; ANALYTICS_K_ANONYMITY
+// raises or lowers the default of 10, but never below 2
+func MinK() int {
+	if v, err := strconv.Atoi(os.Getenv("ANALYTICS_K_ANONYMITY")); err == nil && v >= 2 {
+		return v
+	}
+	return 10
+}
+
+// Row is one published cell of the dataset
+type Row struct {
+	Month          string  `json:"month"` // YYYY-MM in local time
+	Division       string  `json:"division"`
+	District       string  `json:"district"`
+	BookingType    string  `json:"booking_type"`
+	Outcome        string  `json:"outcome"`   // delivered or returned
+	LeadTime       string  `json:"lead_time"` // days from booking to outcome, banded
+	Bookings       int     `json:"bookings"`
+	AvgDaysToClose float64 `json:"avg_days_to_close"`
+}
+
+// Dataset is the anonymized dataset of one date range
+type Dataset struct {
+	From        string    `json:"from_date"`
+	To          string    `json:"to_date"`
+	K           int       `json:"k"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Rows        []Row     `json:"rows"`
+	Total       int       `json:"total_bookings"`
+	Generalized int       `json:"generalized_bookings"` // published with a coarser geography
+	Suppressed  int       `json:"suppressed_bookings"`  // in cells too small even nationwide
+}
+
+// record is one booking outcome before aggregation
+type record struct {
+	ClosedAt    time.Time
+	CreatedAt   time.Time
+	Division    *string
+	District    *string
+	BookingType *string
+	Status      string
+}
+
+// cell aggregates the bookings sharing the same quasi-identifiers
+type cell struct {
+	key     Row
+	count   int
+	sumDays float64
+}
+
+// Build aggregates the bookings delivered or returned in [from, to) into cells of at
+// least k bookings
+func Build(db *gorm.DB, from, to time.Time, k int) (*Dataset, error) {
+	var records []record
+	// The outcome is the first delivered or return status of each booking in the range
+	err := db.Raw(`
+		SELECT DISTINCT ON (e.booking_id)
+			e.created_at AS closed_at, b.created_at AS created_at,
+			a.division AS division, a.district AS district,
+			b.booking_type AS booking_type, e.status AS status
+		FROM booking_status_events e
+		JOIN bookings b ON b.id = e.booking_id
+		LEFT JOIN addresses a ON a.id = b.delivery_address_id
+		WHERE e.status IN ? AND e.created_at >= ? AND e.created_at < ? AND b.deleted_at IS NULL
+		ORDER BY e.booking_id, e.created_at`,
+		[]bookingModel.BookingStatus{bookingModel.BookingStatusDelivered, bookingModel.BookingStatusReturn},
+		from, to,
+	).Scan(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load booking outcomes: %w", err)
+	}
+
+	cells := map[Row]*cell{}
+	for _, r := range records {
+		days := r.ClosedAt.Sub(r.CreatedAt).Hours() / 24
+		key := Row{
+			Month:       localtime.In(r.ClosedAt).Format("2006-01"),
+			Division:    place(r.Division),
+			District:    place(r.District),
+			BookingType: orUnknown(r.BookingType),
+			Outcome:     outcome(r.Status),
+			LeadTime:    leadTimeBand(days),
+		}
+		addTo(cells, key, 1, days)
+	}
+
+	ds := &Dataset{
+		From:        localtime.FormatDate(from),
+		To:          localtime.FormatDate(to.AddDate(0, 0, -1)),
+		K:           k,
+		GeneratedAt: time.Now().UTC(),
+		Total:       len(records),
+	}
+
+	// Publish what meets k, generalize the rest one level and try again
+	generalize := []func(*Row){
+		func(r *Row) { r.District = Any },
+		func(r *Row) { r.Division = Any },
+	}
+	for level := 0; ; level++ {
+		small := map[Row]*cell{}
+		for key, c := range cells {
+			if c.count >= k {
+				ds.Rows = append(ds.Rows, c.row())
+				if level > 0 {
+					ds.Generalized += c.count
+				}
+				continue
+			}
+			if level == len(generalize) {
+				ds.Suppressed += c.count
+				continue
+			}
+			coarser := key
+			generalize[level](&coarser)
+			addTo(small, coarser, c.count, c.sumDays)
+		}
+		if level == len(generalize) || len(small) == 0 {
+			break
+		}
+		cells = small
+	}
+
+	sort.Slice(ds.Rows, func(i, j int) bool {
+		a, b := ds.Rows[i], ds.Rows[j]
+		for _, pair := range [][2]string{
+			{a.Month, b.Month}, {a.Division, b.Division}, {a.District, b.District},
+			{a.BookingType, b.BookingType}, {a.Outcome, b.Outcome}, {a.LeadTime, b.LeadTime},
+		} {
+			if pair[0] != pair[1] {
+				return pair[0] < pair[1]
+			}
+		}
+		return false
+	})
+	if ds.Rows == nil {
+		ds.Rows = []Row{}
+	}
+	return ds, nil
+}
+
+func addTo(cells map[Row]*cell, key Row, count int, days float64) {
+	c, ok := cells[key]
+	if !ok {
+		c = &cell{key: key}
+		cells[key] = c
+	}
+	c.count += count
+	c.sumDays += days
+}
+
+func (c *cell) row() Row {
+	r := c.key
+	r.Bookings = c.count
+	r.AvgDaysToClose = float64(int(c.sumDays/float64(c.count)*10+0.5)) / 10
+	return r
+}
+
+// place normalizes a division or district to its English title-cased name
+func place(v *string) string {
+	if v == nil || strings.TrimSpace(*v) == "" {
+		return "Unknown"
+	}
+	name := strings.TrimSpace(*v)
+	if transliterate.HasBangla(name) {
+		name = transliterate.ToLatin(name)
+	}
+	words := strings.Fields(strings.ToLower(name))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+func orUnknown(v *string) string {
+	if v == nil || *v == "" {
+		return "unknown"
+	}
+	return *v
+}
+
+func outcome(status string) string {
+	if status == string(bookingModel.BookingStatusDelivered) {
+		return "delivered"
+	}
+	return "returned"
+}
+
+// leadTimeBand puts a duration in days into a coarse band
+func leadTimeBand(days float64) string {
+	switch {
+	case days < 4:
+		return "0-3d"
+	case days < 8:
+		return "4-7d"
+	case days < 15:
+		return "8-14d"
+	case days < 31:
+		return "15-30d"
+	}
+	return "31d+"
+}
+
+// WriteCSV writes the rows of ds with a header line
+func WriteCSV(w io.Writer, ds *Dataset) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"month", "division", "district", "booking_type", "outcome", "lead_time", "bookings", "avg_days_to_close"}); err != nil {
+		return err
+	}
+	for _, r := range ds.Rows {
+		if err := cw.Write([]string{
+			r.Month, r.Division, r.District, r.BookingType, r.Outcome, r.LeadTime,
+			strconv.Itoa(r.Bookings), strconv.FormatFloat(r.AvgDaysToClose, 'f', 1, 64),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,247 @@
+package return_manifest
+
+import (
+	"errors"
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	rpoModel "passport-booking/models/regional_passport_office"
+	"passport-booking/services/booking_event"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ValidationError explains why bookings cannot be put on a manifest
+type ValidationError struct {
+	BookingID uint
+	Reason    string
+}
+
+func (e *ValidationError) Error() string {
+	if e.BookingID == 0 {
+		return e.Reason
+	}
+	return fmt.Sprintf("booking %d: %s", e.BookingID, e.Reason)
+}
+
+// ErrWrongStatus is returned when a manifest is dispatched or acknowledged out of order
+var ErrWrongStatus = errors.New("return manifest is not in the required state")
+
+// ErrRPONotFound is returned when the destination regional passport office does not exist
+var ErrRPONotFound = errors.New("regional passport office not found")
+
+// Create bags returned bookings for the regional passport office rpoID
+func Create(db *gorm.DB, rpoID uint, bookings []bookingModel.Booking, actorID uint) (*bookingModel.ReturnManifest, error) {
+	if len(bookings) == 0 {
+		return nil, &ValidationError{Reason: "at least one booking is required"}
+	}
+
+	var rpo rpoModel.RegionalPassportOffice
+	if err := db.First(&rpo, rpoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRPONotFound
+		}
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(bookings))
+	for _, b := range bookings {
+		if b.Status != bookingModel.BookingStatusReturn {
+			return nil, &ValidationError{BookingID: b.ID, Reason: "only returned items can be sent back to the RPO"}
+		}
+		ids = append(ids, b.ID)
+	}
+
+	// Branch of the manifest when every item comes from the same one
+	var branchCode *string
+	for i, b := range bookings {
+		if i == 0 {
+			branchCode = b.DeliveryBranchCode
+			continue
+		}
+		if branchCode == nil || b.DeliveryBranchCode == nil || *branchCode != *b.DeliveryBranchCode {
+			branchCode = nil
+			break
+		}
+	}
+
+	manifest := bookingModel.ReturnManifest{
+		RPOID:       rpo.ID,
+		BranchCode:  branchCode,
+		Status:      bookingModel.ReturnManifestStatusOpen,
+		CreatedByID: actorID,
+	}
+	actor := strconv.FormatUint(uint64(actorID), 10)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var bagged []uint
+		if err := tx.Model(&bookingModel.ReturnManifestItem{}).
+			Where("booking_id IN ? AND status = ?", ids, bookingModel.ReturnItemStatusBagged).
+			Pluck("booking_id", &bagged).Error; err != nil {
+			return err
+		}
+		if len(bagged) > 0 {
+			return &ValidationError{BookingID: bagged[0], Reason: "already on another return manifest"}
+		}
+
+		if err := tx.Create(&manifest).Error; err != nil {
+			return err
+		}
+		manifest.ManifestNo = fmt.Sprintf("RTN-%s-%06d", manifest.CreatedAt.Format("20060102"), manifest.ID)
+		if err := tx.Model(&manifest).Update("manifest_no", manifest.ManifestNo).Error; err != nil {
+			return err
+		}
+
+		for i := range bookings {
+			item := bookingModel.ReturnManifestItem{
+				ManifestID: manifest.ID,
+				BookingID:  bookings[i].ID,
+				Status:     bookingModel.ReturnItemStatusBagged,
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			if err := booking_event.SnapshotBookingToEvent(tx, &bookings[i], "return_bagged_for_rpo", actor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// Dispatch marks an open manifest as sent and its bookings as in transit to the RPO
+func Dispatch(db *gorm.DB, manifestID, actorID uint) error {
+	actor := strconv.FormatUint(uint64(actorID), 10)
+	return db.Transaction(func(tx *gorm.DB) error {
+		manifest, err := lockManifest(tx, manifestID, bookingModel.ReturnManifestStatusOpen)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(manifest).Updates(map[string]interface{}{
+			"status":           bookingModel.ReturnManifestStatusDispatched,
+			"dispatched_by_id": actorID,
+			"dispatched_at":    now,
+		}).Error; err != nil {
+			return err
+		}
+
+		for _, item := range manifest.Items {
+			if err := setStatus(tx, item.BookingID, bookingModel.BookingStatusReturnInTransit, "return_dispatched_to_rpo", actor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Acknowledge records what the RPO found in a dispatched bag. receivedIDs lists the
+// bookings that arrived; nil means all of them. Bookings not listed are marked missing
+// and stay in transit.
+func Acknowledge(db *gorm.DB, manifestID uint, receivedIDs []uint, note *string, actorID uint) error {
+	actor := strconv.FormatUint(uint64(actorID), 10)
+	return db.Transaction(func(tx *gorm.DB) error {
+		manifest, err := lockManifest(tx, manifestID, bookingModel.ReturnManifestStatusDispatched)
+		if err != nil {
+			return err
+		}
+
+		var received map[uint]bool
+		if receivedIDs != nil {
+			received = make(map[uint]bool, len(receivedIDs))
+			onManifest := make(map[uint]bool, len(manifest.Items))
+			for _, item := range manifest.Items {
+				onManifest[item.BookingID] = true
+			}
+			for _, id := range receivedIDs {
+				if !onManifest[id] {
+					return &ValidationError{BookingID: id, Reason: "is not on this manifest"}
+				}
+				received[id] = true
+			}
+		}
+
+		for _, item := range manifest.Items {
+			if received == nil || received[item.BookingID] {
+				if err := tx.Model(&item).Update("status", bookingModel.ReturnItemStatusReceived).Error; err != nil {
+					return err
+				}
+				if err := setStatus(tx, item.BookingID, bookingModel.BookingStatusReturnedToRPO, "return_acknowledged_by_rpo", actor); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := tx.Model(&item).Update("status", bookingModel.ReturnItemStatusMissing).Error; err != nil {
+				return err
+			}
+			var b bookingModel.Booking
+			if err := tx.First(&b, item.BookingID).Error; err != nil {
+				return err
+			}
+			if err := booking_event.SnapshotBookingToEvent(tx, &b, "return_missing_at_rpo", actor); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(manifest).Updates(map[string]interface{}{
+			"status":             bookingModel.ReturnManifestStatusAcknowledged,
+			"acknowledged_by_id": actorID,
+			"acknowledged_at":    time.Now(),
+			"acknowledge_note":   note,
+		}).Error
+	})
+}
+
+// Find loads a manifest with its items and their bookings
+func Find(db *gorm.DB, manifestID uint) (*bookingModel.ReturnManifest, error) {
+	var manifest bookingModel.ReturnManifest
+	if err := db.Preload("Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		Preload("Items.Booking").
+		First(&manifest, manifestID).Error; err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func lockManifest(tx *gorm.DB, manifestID uint, want bookingModel.ReturnManifestStatus) (*bookingModel.ReturnManifest, error) {
+	var manifest bookingModel.ReturnManifest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&manifest, manifestID).Error; err != nil {
+		return nil, err
+	}
+	if manifest.Status != want {
+		return nil, ErrWrongStatus
+	}
+	if err := tx.Where("manifest_id = ?", manifest.ID).Order("id").Find(&manifest.Items).Error; err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// setStatus moves a booking to status and records the status and booking events
+func setStatus(tx *gorm.DB, bookingID uint, status bookingModel.BookingStatus, eventType, actor string) error {
+	var b bookingModel.Booking
+	if err := tx.First(&b, bookingID).Error; err != nil {
+		return err
+	}
+	if err := b.Status.CheckTransition(status); err != nil {
+		return err
+	}
+	if err := tx.Model(&b).Updates(map[string]interface{}{"status": status, "updated_by": actor}).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&bookingModel.BookingStatusEvent{
+		BookingID: b.ID,
+		Status:    status,
+		CreatedBy: actor,
+	}).Error; err != nil {
+		return err
+	}
+	return booking_event.SnapshotBookingToEvent(tx, &b, eventType, actor)
+}
@@ -0,0 +1,251 @@
+// Package shipment keeps the shared shipments table in step with bookings and parcel
+// bookings.
+//
+// Booking and ParcelBooking grew separately and duplicate barcode, status and owner
+// handling with diverging status enums. They are being unified in three steps:
+//
+//  1. Every Booking and ParcelBooking is mirrored as a Shipment. The adapters below map
+//     each record and its status onto the shared Stage; a GORM callback resyncs a row
+//     whenever it is written, and cmd/migrate-shipments backfills existing data.
+//  2. Cross-cutting features (tracking, reports, holds and the like) read and key on
+//     shipments instead of branching on the two models.
+//  3. The duplicated columns are dropped from bookings and parcel_bookings once nothing
+//     reads them there, leaving only kind-specific fields on each.
+package shipment
+
+import (
+	"errors"
+	"fmt"
+	"passport-booking/logger"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/models/parcel_booking"
+	shipmentModel "passport-booking/models/shipment"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// adapter maps one source table onto shipments
+type adapter struct {
+	kind shipmentModel.Kind
+	load func(db *gorm.DB, id uint) (*shipmentModel.Shipment, error)
+}
+
+var adapters = map[string]adapter{
+	"bookings":        {kind: shipmentModel.KindBooking, load: loadBooking},
+	"parcel_bookings": {kind: shipmentModel.KindParcelBooking, load: loadParcelBooking},
+}
+
+// BookingStage maps a booking status onto the shared lifecycle
+func BookingStage(status bookingModel.BookingStatus) shipmentModel.Stage {
+	switch status {
+	case bookingModel.BookingStatusBooked:
+		return shipmentModel.StageBooked
+	case bookingModel.BookingStatusReceivedByPostman, bookingModel.BookingStatusReceivedByPostMaster:
+		return shipmentModel.StageAtBranch
+	case bookingModel.BookingItemStatusReceivedByPostman:
+		return shipmentModel.StageOutForDelivery
+	case bookingModel.BookingStatusDelivered:
+		return shipmentModel.StageDelivered
+	case bookingModel.BookingStatusReturn, bookingModel.BookingStatusReturnInTransit:
+		return shipmentModel.StageReturning
+	case bookingModel.BookingStatusReturnedToRPO:
+		return shipmentModel.StageReturned
+	default:
+		return shipmentModel.StageCreated
+	}
+}
+
+// ParcelBookingStage maps a parcel booking status onto the shared lifecycle
+func ParcelBookingStage(status string) shipmentModel.Stage {
+	switch parcel_booking.ParcelBookingStatus(status) {
+	case parcel_booking.ParcelBookingStatusBooked:
+		return shipmentModel.StageBooked
+	case parcel_booking.ParcelBookingStatusReceived:
+		return shipmentModel.StageAtBranch
+	case parcel_booking.ParcelBookingStatusDelivered:
+		return shipmentModel.StageDelivered
+	case parcel_booking.ParcelBookingStatusReturn:
+		return shipmentModel.StageReturning
+	default:
+		return shipmentModel.StageCreated
+	}
+}
+
+// FromBooking builds the shipment view of a booking. bookedAt and deliveredAt come from
+// its status history since the booking itself does not store them.
+func FromBooking(b *bookingModel.Booking, bookedAt, deliveredAt *time.Time) shipmentModel.Shipment {
+	return shipmentModel.Shipment{
+		Kind:         shipmentModel.KindBooking,
+		SourceID:     b.ID,
+		UserID:       b.UserID,
+		Barcode:      b.Barcode,
+		Phone:        b.Phone,
+		Stage:        BookingStage(b.Status),
+		SourceStatus: string(b.Status),
+		BookedAt:     bookedAt,
+		DeliveredAt:  deliveredAt,
+	}
+}
+
+// FromParcelBooking builds the shipment view of a parcel booking
+func FromParcelBooking(p *parcel_booking.ParcelBooking) shipmentModel.Shipment {
+	var barcode *string
+	if p.Barcode != "" {
+		barcode = &p.Barcode
+	}
+	return shipmentModel.Shipment{
+		Kind:         shipmentModel.KindParcelBooking,
+		SourceID:     p.ID,
+		UserID:       p.UserID,
+		Barcode:      barcode,
+		Phone:        p.Phone,
+		Stage:        ParcelBookingStage(p.CurrentStatus),
+		SourceStatus: p.CurrentStatus,
+		BookedAt:     p.BookingDate,
+		DeliveredAt:  p.DeliveredDate,
+	}
+}
+
+func loadBooking(db *gorm.DB, id uint) (*shipmentModel.Shipment, error) {
+	var b bookingModel.Booking
+	if err := db.First(&b, id).Error; err != nil {
+		return nil, err
+	}
+
+	var times struct {
+		BookedAt    *time.Time
+		DeliveredAt *time.Time
+	}
+	if err := db.Model(&bookingModel.BookingStatusEvent{}).
+		Select(`MIN(created_at) FILTER (WHERE status = ?) AS booked_at,
+			MAX(created_at) FILTER (WHERE status = ?) AS delivered_at`,
+			bookingModel.BookingStatusBooked, bookingModel.BookingStatusDelivered).
+		Where("booking_id = ?", id).
+		Scan(&times).Error; err != nil {
+		return nil, err
+	}
+
+	s := FromBooking(&b, times.BookedAt, times.DeliveredAt)
+	return &s, nil
+}
+
+func loadParcelBooking(db *gorm.DB, id uint) (*shipmentModel.Shipment, error) {
+	var p parcel_booking.ParcelBooking
+	if err := db.First(&p, id).Error; err != nil {
+		return nil, err
+	}
+	s := FromParcelBooking(&p)
+	return &s, nil
+}
+
+// Sync writes the shipment mirroring the given row of bookings or parcel_bookings,
+// removing it when the row no longer exists
+func Sync(db *gorm.DB, table string, id uint) error {
+	a, ok := adapters[table]
+	if !ok {
+		return fmt.Errorf("no shipment adapter for table %s", table)
+	}
+
+	s, err := a.load(db, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return db.Where("kind = ? AND source_id = ?", a.kind, id).Delete(&shipmentModel.Shipment{}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "kind"}, {Name: "source_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"user_id", "barcode", "phone", "stage", "source_status", "booked_at", "delivered_at", "updated_at",
+		}),
+	}).Create(s).Error
+}
+
+// Backfill syncs every row of table, batchSize at a time, and returns how many rows it
+// visited. With dryRun nothing is written.
+func Backfill(db *gorm.DB, table string, batchSize int, dryRun bool) (int, error) {
+	if _, ok := adapters[table]; !ok {
+		return 0, fmt.Errorf("no shipment adapter for table %s", table)
+	}
+
+	visited := 0
+	var lastID uint
+	for {
+		var ids []uint
+		if err := db.Table(table).Where("id > ?", lastID).Order("id").Limit(batchSize).Pluck("id", &ids).Error; err != nil {
+			return visited, err
+		}
+		if len(ids) == 0 {
+			return visited, nil
+		}
+
+		for _, id := range ids {
+			lastID = id
+			visited++
+			if dryRun {
+				continue
+			}
+			if err := Sync(db, table, id); err != nil {
+				return visited, fmt.Errorf("%s %d: %w", table, id, err)
+			}
+		}
+	}
+}
+
+// Tables lists the source tables mirrored into shipments
+func Tables() []string {
+	return []string{"bookings", "parcel_bookings"}
+}
+
+// RegisterCallbacks resyncs the shipment of every booking or parcel booking saved
+// through a model value. Bulk updates without a loaded model are picked up by the next
+// cmd/migrate-shipments run.
+func RegisterCallbacks(db *gorm.DB) error {
+	sync := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.Schema == nil {
+			return
+		}
+		if _, ok := adapters[tx.Statement.Schema.Table]; !ok {
+			return
+		}
+		pk := tx.Statement.Schema.PrioritizedPrimaryField
+		if pk == nil {
+			return
+		}
+
+		var ids []uint
+		collect := func(rv reflect.Value) {
+			if v, zero := pk.ValueOf(tx.Statement.Context, rv); !zero {
+				if id, ok := v.(uint); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+		rv := reflect.Indirect(tx.Statement.ReflectValue)
+		switch rv.Kind() {
+		case reflect.Struct:
+			collect(rv)
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				collect(reflect.Indirect(rv.Index(i)))
+			}
+		}
+
+		// Runs on the statement's connection so it joins any open transaction
+		session := tx.Session(&gorm.Session{NewDB: true})
+		for _, id := range ids {
+			if err := Sync(session, tx.Statement.Schema.Table, id); err != nil {
+				logger.Error(fmt.Sprintf("Failed to sync shipment for %s %d", tx.Statement.Schema.Table, id), err)
+			}
+		}
+	}
+
+	if err := db.Callback().Create().After("gorm:create").Register("shipment:sync", sync); err != nil {
+		return err
+	}
+	return db.Callback().Update().After("gorm:update").Register("shipment:sync", sync)
+}
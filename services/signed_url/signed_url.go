@@ -0,0 +1,109 @@
+// Package signed_url issues short-lived links to stored proof files such as delivery
+// photos. A link names the booking by barcode, never the storage path, and carries
+// an expiry and an HMAC over both, so files cannot be guessed or kept open forever.
+package signed_url
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"passport-booking/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTTLSeconds = 900
+	defaultBasePath   = "/api/public/proof"
+)
+
+// Kind is the type of file a link opens
+type Kind string
+
+const (
+	// KindDeliveryPhoto is the hand-over photo of a delivered booking
+	KindDeliveryPhoto Kind = "delivery-photo"
+)
+
+// IsValid reports whether k is a known kind
+func (k Kind) IsValid() bool {
+	return k == KindDeliveryPhoto
+}
+
+var (
+	// ErrDisabled is returned when SIGNED_URL_SECRET is not configured
+	ErrDisabled = errors.New("signed URLs are not configured")
+	// ErrExpired is returned for a link past its expiry
+	ErrExpired = errors.New("link has expired")
+	// ErrInvalidSignature is returned for a tampered or foreign link
+	ErrInvalidSignature = errors.New("invalid link signature")
+)
+
+func secret() string {
+	return config.Secret("SIGNED_URL_SECRET")
+}
+
+// TTL is how long links stay valid; SIGNED_URL_TTL_SECONDS overrides the default of
+// 15 minutes
+func TTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("SIGNED_URL_TTL_SECONDS")); err == nil && v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return defaultTTLSeconds * time.Second
+}
+
+// expiry rounds the expiry up to the next TTL boundary so the same link is handed out
+// for a while, which keeps cached tracking responses consistent
+func expiry(now time.Time) time.Time {
+	ttl := TTL()
+	return now.Add(ttl).Truncate(ttl).Add(ttl)
+}
+
+func sign(key string, kind Kind, ref string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s|%s|%d", kind, ref, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns a signed link to the file of kind for ref and when it expires.
+// PUBLIC_PROOF_BASE_URL sets the link prefix, defaulting to the API path.
+func URL(kind Kind, ref string, now time.Time) (string, time.Time, error) {
+	key := secret()
+	if key == "" {
+		return "", time.Time{}, ErrDisabled
+	}
+	expiresAt := expiry(now)
+	base := os.Getenv("PUBLIC_PROOF_BASE_URL")
+	if base == "" {
+		base = defaultBasePath
+	}
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	q.Set("sig", sign(key, kind, ref, expiresAt.Unix()))
+	link := fmt.Sprintf("%s/%s/%s?%s", strings.TrimRight(base, "/"), kind, url.PathEscape(ref), q.Encode())
+	return link, expiresAt, nil
+}
+
+// Verify checks a link's signature and expiry
+func Verify(kind Kind, ref, expires, signature string, now time.Time) error {
+	key := secret()
+	if key == "" {
+		return ErrDisabled
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sign(key, kind, ref, exp)), []byte(strings.ToLower(signature))) {
+		return ErrInvalidSignature
+	}
+	if now.Unix() > exp {
+		return ErrExpired
+	}
+	return nil
+}
@@ -11,11 +11,15 @@ import (
 
 	"passport-booking/logger"
 	"passport-booking/models/slip_parser"
+	"passport-booking/services/workerpool"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
+// asyncQueue runs the bookkeeping done after a parse, which nobody waits on
+var asyncQueue = workerpool.NewQueue("slip_parser", workerpool.PriorityLow)
+
 // SlipParserService handles slip parser operations
 type SlipParserService struct {
 	DB        *gorm.DB
@@ -77,13 +81,14 @@ func (s *SlipParserService) CreateInitialRequest(c *fiber.Ctx, requestID, origin
 
 // SaveFileAsync saves the uploaded file asynchronously
 func (s *SlipParserService) SaveFileAsync(requestID string, fileBytes []byte, originalFileName, mimeType string) {
-	go func() {
+	asyncQueue.Go(func() error {
 		if err := s.saveFile(requestID, fileBytes, originalFileName, mimeType); err != nil {
-			logger.Error(fmt.Sprintf("Failed to save file for request %s", requestID), err)
 			// Update request with error
 			s.updateRequestWithFileError(requestID, err.Error())
+			return fmt.Errorf("failed to save file for request %s: %w", requestID, err)
 		}
-	}()
+		return nil
+	})
 }
 
 // saveFile saves the file to disk and updates the database record
@@ -126,11 +131,12 @@ func (s *SlipParserService) saveFile(requestID string, fileBytes []byte, origina
 
 // SaveSuccessResultAsync saves the parsing result asynchronously
 func (s *SlipParserService) SaveSuccessResultAsync(requestID string, result *slip_parser.SlipParserResponse) {
-	go func() {
+	asyncQueue.Go(func() error {
 		if err := s.saveSuccessResult(requestID, result); err != nil {
-			logger.Error(fmt.Sprintf("Failed to save success result for request %s", requestID), err)
+			return fmt.Errorf("failed to save success result for request %s: %w", requestID, err)
 		}
-	}()
+		return nil
+	})
 }
 
 // saveSuccessResult saves the successful parsing result
@@ -150,11 +156,12 @@ func (s *SlipParserService) saveSuccessResult(requestID string, result *slip_par
 
 // SaveFailureResultAsync saves the failure result asynchronously
 func (s *SlipParserService) SaveFailureResultAsync(requestID string, errorMsg string, processingTime int64) {
-	go func() {
+	asyncQueue.Go(func() error {
 		if err := s.saveFailureResult(requestID, errorMsg, processingTime); err != nil {
-			logger.Error(fmt.Sprintf("Failed to save failure result for request %s", requestID), err)
+			return fmt.Errorf("failed to save failure result for request %s: %w", requestID, err)
 		}
-	}()
+		return nil
+	})
 }
 
 // saveFailureResult saves the failure result
@@ -0,0 +1,187 @@
+package slo
+
+import (
+	"fmt"
+	"os"
+	"passport-booking/logger"
+	reportModel "passport-booking/models/report"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultFlushSeconds = 60
+
+// LatencyBounds are the histogram bounds request latencies are counted under. A
+// latency objective's threshold is rounded down to one of them.
+var LatencyBounds = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1000 * time.Millisecond,
+	2500 * time.Millisecond,
+	5000 * time.Millisecond,
+}
+
+// boundColumns are the request_metrics columns of LatencyBounds, in order
+var boundColumns = []string{"le_100ms", "le_250ms", "le_500ms", "le_1000ms", "le_2500ms", "le_5000ms"}
+
+type metricKey struct {
+	method string
+	route  string
+	hour   time.Time
+}
+
+type counts struct {
+	requests int64
+	errors   int64
+	within   [6]int64
+}
+
+// Recorder aggregates requests in memory and flushes them to request_metrics, so
+// serving a request costs a map update rather than a write
+type Recorder struct {
+	mu      sync.Mutex
+	pending map[metricKey]*counts
+}
+
+// NewRecorder returns an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{pending: make(map[metricKey]*counts)}
+}
+
+var defaultRecorder = NewRecorder()
+
+// Default returns the process-wide recorder fed by the request metrics middleware
+func Default() *Recorder {
+	return defaultRecorder
+}
+
+// Observe counts one request to a route pattern. Only 5xx responses count as errors:
+// a 4xx is the caller's mistake and does not spend the error budget.
+func (r *Recorder) Observe(method, route string, status int, elapsed time.Duration, at time.Time) {
+	key := metricKey{method: method, route: route, hour: at.UTC().Truncate(time.Hour)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.pending[key]
+	if !ok {
+		c = &counts{}
+		r.pending[key] = c
+	}
+	c.requests++
+	if status >= 500 {
+		c.errors++
+	}
+	for i, bound := range LatencyBounds {
+		if elapsed <= bound {
+			c.within[i]++
+		}
+	}
+}
+
+// Flush adds the pending counts to request_metrics. Counts that fail to write are
+// kept for the next flush.
+func (r *Recorder) Flush(db *gorm.DB) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[metricKey]*counts)
+	r.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	rows := make([]reportModel.RequestMetric, 0, len(pending))
+	for key, c := range pending {
+		rows = append(rows, reportModel.RequestMetric{
+			Method:   key.method,
+			Route:    key.route,
+			Hour:     key.hour,
+			Requests: c.requests,
+			Errors:   c.errors,
+			Le100ms:  c.within[0],
+			Le250ms:  c.within[1],
+			Le500ms:  c.within[2],
+			Le1000ms: c.within[3],
+			Le2500ms: c.within[4],
+			Le5000ms: c.within[5],
+		})
+	}
+
+	add := map[string]interface{}{"updated_at": gorm.Expr("excluded.updated_at")}
+	for _, column := range append([]string{"requests", "errors"}, boundColumns...) {
+		add[column] = gorm.Expr(fmt.Sprintf("request_metrics.%s + excluded.%s", column, column))
+	}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "method"}, {Name: "route"}, {Name: "hour"}},
+		DoUpdates: clause.Assignments(add),
+	}).CreateInBatches(&rows, 200).Error
+	if err != nil {
+		r.requeue(pending)
+	}
+	return err
+}
+
+// requeue merges counts that could not be written back into the pending set
+func (r *Recorder) requeue(failed map[metricKey]*counts) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, f := range failed {
+		c, ok := r.pending[key]
+		if !ok {
+			r.pending[key] = f
+			continue
+		}
+		c.requests += f.requests
+		c.errors += f.errors
+		for i := range c.within {
+			c.within[i] += f.within[i]
+		}
+	}
+}
+
+// StartScheduler flushes the default recorder every SLO_FLUSH_INTERVAL_SECONDS
+// (default 60) and once more when stopped
+func StartScheduler(db *gorm.DB) func() {
+	interval := time.Duration(envInt("SLO_FLUSH_INTERVAL_SECONDS", defaultFlushSeconds)) * time.Second
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	flush := func() {
+		if err := Default().Flush(db); err != nil {
+			logger.Error("Failed to flush request metrics", err)
+		}
+	}
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				flush()
+			case <-done:
+				ticker.Stop()
+				flush()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
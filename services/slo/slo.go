@@ -0,0 +1,267 @@
+// Package slo tracks the service-level objectives of the booking and delivery APIs.
+// The request metrics middleware counts requests per route and hour; the monthly
+// report turns those counts into availability and latency SLIs and the share of the
+// error budget each objective has spent.
+package slo
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"passport-booking/services/localtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kind is what an objective measures
+type Kind string
+
+const (
+	KindAvailability Kind = "availability" // share of requests not answered with a 5xx
+	KindLatency      Kind = "latency"      // share of requests answered within a threshold
+)
+
+// Status summarizes how an objective is doing in the month
+type Status string
+
+const (
+	StatusMet      Status = "met"
+	StatusAtRisk   Status = "at_risk"  // still met, but most of the budget is spent
+	StatusBreached Status = "breached" // the budget is exhausted
+	StatusNoData   Status = "no_data"
+)
+
+// atRiskConsumed is the share of the budget, in percent, past which an objective is at risk
+const atRiskConsumed = 75.0
+
+// Service is a group of endpoints sharing objectives, selected by route prefix
+type Service struct {
+	Name    string
+	Prefix  string
+	Envs    string // prefix of the environment variables overriding the targets
+	Targets []Objective
+}
+
+// Objective is a target for one SLI of a service
+type Objective struct {
+	Kind        Kind
+	Target      float64 // percent of requests that must be good
+	ThresholdMs int64   // latency objectives only
+}
+
+// Services returns the tracked services with their objectives. Targets can be
+// overridden with SLO_<SERVICE>_AVAILABILITY_TARGET, SLO_<SERVICE>_LATENCY_TARGET
+// and SLO_<SERVICE>_LATENCY_MS.
+func Services() []Service {
+	services := []Service{
+		{Name: "booking", Prefix: "/api/booking", Envs: "SLO_BOOKING", Targets: []Objective{
+			{Kind: KindAvailability, Target: 99.5},
+			{Kind: KindLatency, Target: 95, ThresholdMs: 1000},
+		}},
+		{Name: "delivery", Prefix: "/api/delivered", Envs: "SLO_DELIVERY", Targets: []Objective{
+			{Kind: KindAvailability, Target: 99.5},
+			{Kind: KindLatency, Target: 95, ThresholdMs: 2500},
+		}},
+	}
+	for i := range services {
+		s := &services[i]
+		for j := range s.Targets {
+			o := &s.Targets[j]
+			switch o.Kind {
+			case KindAvailability:
+				o.Target = envPercent(s.Envs+"_AVAILABILITY_TARGET", o.Target)
+			case KindLatency:
+				o.Target = envPercent(s.Envs+"_LATENCY_TARGET", o.Target)
+				o.ThresholdMs = int64(envInt(s.Envs+"_LATENCY_MS", int(o.ThresholdMs)))
+			}
+		}
+	}
+	return services
+}
+
+// bucket returns the latency column counting requests within ms, rounding down to
+// the nearest bound so the objective is never looser than configured
+func bucket(ms int64) (string, int64) {
+	column, bound := boundColumns[0], LatencyBounds[0].Milliseconds()
+	for i, b := range LatencyBounds {
+		if b.Milliseconds() <= ms {
+			column, bound = boundColumns[i], b.Milliseconds()
+		}
+	}
+	return column, bound
+}
+
+// Day is an objective's SLI on one day of the month
+type Day struct {
+	Date  string   `json:"date"`
+	Total int64    `json:"total"`
+	Good  int64    `json:"good"`
+	SLI   *float64 `json:"sli"` // null on days without traffic
+}
+
+// Result is how one objective did in the month
+type Result struct {
+	Service         string  `json:"service"`
+	Kind            Kind    `json:"kind"`
+	Target          float64 `json:"target"`
+	ThresholdMs     int64   `json:"threshold_ms,omitempty"`
+	Total           int64   `json:"total"`
+	Good            int64   `json:"good"`
+	Bad             int64   `json:"bad"`
+	SLI             float64 `json:"sli"`
+	ErrorBudget     float64 `json:"error_budget"`     // bad requests the target allows
+	BudgetConsumed  float64 `json:"budget_consumed"`  // percent of the budget spent
+	BudgetRemaining float64 `json:"budget_remaining"` // percent of the budget left
+	Status          Status  `json:"status"`
+	Daily           []Day   `json:"daily"`
+}
+
+// MonthlyReport is the error budget report of one calendar month
+type MonthlyReport struct {
+	Month          string    `json:"month"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	ElapsedPercent float64   `json:"elapsed_percent"` // share of the month already past
+	Objectives     []Result  `json:"objectives"`
+}
+
+type hourRow struct {
+	Hour     time.Time
+	Requests int64
+	Errors   int64
+	Within   int64
+}
+
+// Report computes every objective for the month starting at month, a time in the
+// local zone. Requests still held by the recorder are not included.
+func Report(db *gorm.DB, month time.Time, now time.Time) (*MonthlyReport, error) {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, localtime.Zone())
+	to := from.AddDate(0, 1, 0)
+
+	report := &MonthlyReport{
+		Month:      from.Format("2006-01"),
+		From:       from,
+		To:         to,
+		Objectives: []Result{},
+	}
+	switch {
+	case !now.After(from):
+		report.ElapsedPercent = 0
+	case now.Before(to):
+		report.ElapsedPercent = round(100 * now.Sub(from).Hours() / to.Sub(from).Hours())
+	default:
+		report.ElapsedPercent = 100
+	}
+
+	for _, service := range Services() {
+		for _, objective := range service.Targets {
+			result, err := evaluate(db, service, objective, from, to)
+			if err != nil {
+				return nil, err
+			}
+			report.Objectives = append(report.Objectives, *result)
+		}
+	}
+	return report, nil
+}
+
+func evaluate(db *gorm.DB, service Service, objective Objective, from, to time.Time) (*Result, error) {
+	result := &Result{
+		Service: service.Name,
+		Kind:    objective.Kind,
+		Target:  objective.Target,
+		Daily:   []Day{},
+	}
+	within := "0"
+	if objective.Kind == KindLatency {
+		column, bound := bucket(objective.ThresholdMs)
+		within = "COALESCE(SUM(" + column + "), 0)"
+		result.ThresholdMs = bound
+	}
+
+	var rows []hourRow
+	if err := db.Table("request_metrics").
+		Select("hour, COALESCE(SUM(requests), 0) AS requests, COALESCE(SUM(errors), 0) AS errors, "+within+" AS within").
+		Where("route = ? OR route LIKE ?", service.Prefix, strings.TrimSuffix(service.Prefix, "/")+"/%").
+		Where("hour >= ? AND hour < ?", from.UTC(), to.UTC()).
+		Group("hour").
+		Order("hour").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read %s request metrics: %w", service.Name, err)
+	}
+
+	days := make(map[string]*Day)
+	var order []string
+	for _, row := range rows {
+		good := row.Requests - row.Errors
+		if objective.Kind == KindLatency {
+			good = row.Within
+		}
+		date := localtime.FormatDate(row.Hour)
+		day, ok := days[date]
+		if !ok {
+			day = &Day{Date: date}
+			days[date] = day
+			order = append(order, date)
+		}
+		day.Total += row.Requests
+		day.Good += good
+		result.Total += row.Requests
+		result.Good += good
+	}
+	for _, date := range order {
+		day := days[date]
+		if day.Total > 0 {
+			sli := round(100 * float64(day.Good) / float64(day.Total))
+			day.SLI = &sli
+		}
+		result.Daily = append(result.Daily, *day)
+	}
+
+	result.Bad = result.Total - result.Good
+	if result.Total == 0 {
+		result.SLI = 100
+		result.BudgetRemaining = 100
+		result.Status = StatusNoData
+		return result, nil
+	}
+	result.SLI = round(100 * float64(result.Good) / float64(result.Total))
+
+	budget := float64(result.Total) * (100 - objective.Target) / 100
+	result.ErrorBudget = round(budget)
+	consumed := 100.0
+	if budget > 0 {
+		consumed = 100 * float64(result.Bad) / budget
+	} else if result.Bad == 0 {
+		consumed = 0
+	}
+	result.BudgetConsumed = round(consumed)
+	result.BudgetRemaining = round(math.Max(0, 100-consumed))
+
+	switch {
+	case consumed > 100:
+		result.Status = StatusBreached
+	case consumed >= atRiskConsumed:
+		result.Status = StatusAtRisk
+	default:
+		result.Status = StatusMet
+	}
+	return result, nil
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// envPercent reads a percentage in (0, 100) from the environment
+func envPercent(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && f < 100 {
+			return f
+		}
+	}
+	return fallback
+}
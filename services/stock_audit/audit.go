@@ -0,0 +1,236 @@
+package stock_audit
+
+import (
+	"errors"
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_event"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CustodyStatuses are the statuses in which an item is physically at its delivery
+// branch: received there and not yet out with a postman, delivered or bagged back to
+// the RPO
+var CustodyStatuses = []bookingModel.BookingStatus{
+	bookingModel.BookingStatusReceivedByPostman,
+	bookingModel.BookingStatusReceivedByPostMaster,
+	bookingModel.BookingStatusReturn,
+}
+
+// ErrIncidentClosed is returned when resolving an incident that is no longer open
+var ErrIncidentClosed = errors.New("stock incident is already resolved")
+
+// Result is the outcome of a stock audit
+type Result struct {
+	Audit      bookingModel.StockAudit
+	Missing    []bookingModel.StockIncident // open missing incidents for this audit, new or carried over
+	Unexpected []bookingModel.StockIncident // open unexpected incidents for this audit, new or carried over
+	Recovered  []string                     // barcodes whose earlier missing incident this audit closed
+}
+
+type expectedItem struct {
+	ID      uint
+	Barcode string
+	Status  bookingModel.BookingStatus
+}
+
+// Run reconciles the barcodes scanned at branchCode against the items in custody
+// there, records the audit and opens an incident for every discrepancy. scanned must
+// be normalized and free of duplicates.
+func Run(db *gorm.DB, branchCode string, scanned []string, note *string, actorID uint) (*Result, error) {
+	actor := strconv.FormatUint(uint64(actorID), 10)
+	result := &Result{
+		Missing:    []bookingModel.StockIncident{},
+		Unexpected: []bookingModel.StockIncident{},
+		Recovered:  []string{},
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Items without a barcode cannot be scanned, so they are left out of the audit
+		var expected []expectedItem
+		if err := tx.Model(&bookingModel.Booking{}).
+			Select("id, barcode, status").
+			Where("delivery_branch_code = ? AND status IN ? AND barcode IS NOT NULL AND barcode <> ''", branchCode, CustodyStatuses).
+			Scan(&expected).Error; err != nil {
+			return err
+		}
+
+		expectedByBarcode := make(map[string]expectedItem, len(expected))
+		for _, e := range expected {
+			expectedByBarcode[e.Barcode] = e
+		}
+		scannedSet := make(map[string]bool, len(scanned))
+		for _, b := range scanned {
+			scannedSet[b] = true
+		}
+
+		audit := bookingModel.StockAudit{
+			BranchCode:    branchCode,
+			ExpectedCount: len(expected),
+			ScannedCount:  len(scanned),
+			Note:          note,
+			CreatedByID:   actorID,
+		}
+		if err := tx.Create(&audit).Error; err != nil {
+			return err
+		}
+
+		var open []bookingModel.StockIncident
+		if err := tx.Where("branch_code = ? AND status = ?", branchCode, bookingModel.StockIncidentStatusOpen).
+			Find(&open).Error; err != nil {
+			return err
+		}
+		openByKey := make(map[string]bookingModel.StockIncident, len(open))
+		for _, inc := range open {
+			openByKey[string(inc.Kind)+":"+inc.Barcode] = inc
+		}
+
+		now := time.Now()
+		resolve := func(inc bookingModel.StockIncident, reason string) error {
+			return tx.Model(&inc).Updates(map[string]interface{}{
+				"status":          bookingModel.StockIncidentStatusResolved,
+				"resolved_by_id":  actorID,
+				"resolved_at":     now,
+				"resolution_note": reason,
+			}).Error
+		}
+
+		raise := func(kind bookingModel.StockIncidentKind, barcode string, bookingID *uint, status *bookingModel.BookingStatus) (bookingModel.StockIncident, bool, error) {
+			if inc, ok := openByKey[string(kind)+":"+barcode]; ok {
+				return inc, false, nil
+			}
+			inc := bookingModel.StockIncident{
+				AuditID:       audit.ID,
+				BranchCode:    branchCode,
+				Kind:          kind,
+				Barcode:       barcode,
+				BookingID:     bookingID,
+				BookingStatus: status,
+				Status:        bookingModel.StockIncidentStatusOpen,
+			}
+			return inc, true, tx.Create(&inc).Error
+		}
+
+		// Expected items that were not on the shelf
+		for _, e := range expected {
+			if scannedSet[e.Barcode] {
+				audit.MatchedCount++
+				continue
+			}
+			id, status := e.ID, e.Status
+			inc, created, err := raise(bookingModel.StockIncidentMissing, e.Barcode, &id, &status)
+			if err != nil {
+				return err
+			}
+			if created {
+				if err := snapshot(tx, e.ID, "stock_audit_missing", actor); err != nil {
+					return err
+				}
+			}
+			result.Missing = append(result.Missing, inc)
+		}
+
+		// Scanned items the branch should not be holding
+		var unknown []string
+		for _, b := range scanned {
+			if _, ok := expectedByBarcode[b]; !ok {
+				unknown = append(unknown, b)
+			}
+		}
+		found := map[string]bookingModel.Booking{}
+		if len(unknown) > 0 {
+			var bookings []bookingModel.Booking
+			if err := tx.Where("barcode IN ?", unknown).Find(&bookings).Error; err != nil {
+				return err
+			}
+			for _, b := range bookings {
+				found[*b.Barcode] = b
+			}
+		}
+		for _, barcode := range unknown {
+			var bookingID *uint
+			var status *bookingModel.BookingStatus
+			if b, ok := found[barcode]; ok {
+				id, s := b.ID, b.Status
+				bookingID, status = &id, &s
+			}
+			inc, created, err := raise(bookingModel.StockIncidentUnexpected, barcode, bookingID, status)
+			if err != nil {
+				return err
+			}
+			if created && bookingID != nil {
+				if err := snapshot(tx, *bookingID, "stock_audit_unexpected", actor); err != nil {
+					return err
+				}
+			}
+			result.Unexpected = append(result.Unexpected, inc)
+		}
+
+		// Earlier incidents this audit shows are no longer true
+		for _, inc := range open {
+			switch {
+			case inc.Kind == bookingModel.StockIncidentMissing && scannedSet[inc.Barcode]:
+				if err := resolve(inc, fmt.Sprintf("found on the shelf by stock audit %d", audit.ID)); err != nil {
+					return err
+				}
+				result.Recovered = append(result.Recovered, inc.Barcode)
+			case inc.Kind == bookingModel.StockIncidentMissing:
+				if _, stillExpected := expectedByBarcode[inc.Barcode]; !stillExpected {
+					if err := resolve(inc, fmt.Sprintf("no longer expected at the branch as of stock audit %d", audit.ID)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		audit.MissingCount = len(result.Missing)
+		audit.UnexpectedCount = len(result.Unexpected)
+		if err := tx.Model(&audit).Updates(map[string]interface{}{
+			"matched_count":    audit.MatchedCount,
+			"missing_count":    audit.MissingCount,
+			"unexpected_count": audit.UnexpectedCount,
+		}).Error; err != nil {
+			return err
+		}
+		result.Audit = audit
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Find loads an audit with the incidents it opened
+func Find(db *gorm.DB, auditID uint) (*bookingModel.StockAudit, error) {
+	var audit bookingModel.StockAudit
+	if err := db.Preload("Incidents", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		First(&audit, auditID).Error; err != nil {
+		return nil, err
+	}
+	return &audit, nil
+}
+
+// Resolve closes an open incident after it has been investigated
+func Resolve(db *gorm.DB, inc *bookingModel.StockIncident, note string, actorID uint) error {
+	if inc.Status != bookingModel.StockIncidentStatusOpen {
+		return ErrIncidentClosed
+	}
+	now := time.Now()
+	inc.Status = bookingModel.StockIncidentStatusResolved
+	inc.ResolvedByID = &actorID
+	inc.ResolvedAt = &now
+	inc.ResolutionNote = &note
+	return db.Save(inc).Error
+}
+
+func snapshot(tx *gorm.DB, bookingID uint, eventType, actor string) error {
+	var b bookingModel.Booking
+	if err := tx.First(&b, bookingID).Error; err != nil {
+		return err
+	}
+	return booking_event.SnapshotBookingToEvent(tx, &b, eventType, actor)
+}
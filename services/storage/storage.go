@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+)
+
+// FileStorage stores uploaded files and reports whether they still exist
+type FileStorage interface {
+	Save(file *multipart.FileHeader, filename string) (string, error)
+	Exists(path string) bool
+	Remove(path string) error
+}
+
+// DeliveryPhotoDir is where delivery hand-over photos are stored
+const DeliveryPhotoDir = "./upload_photos"
+
+// RecipientIDPhotoDir is where photos of recipients' ID documents are stored
+const RecipientIDPhotoDir = "./upload_id_photos"
+
+// LocalStorage keeps files in a directory on the local filesystem
+type LocalStorage struct {
+	BaseDir string
+}
+
+// NewLocalStorage creates a local storage rooted at baseDir
+func NewLocalStorage(baseDir string) *LocalStorage {
+	return &LocalStorage{BaseDir: baseDir}
+}
+
+// Save copies the uploaded file into the base directory and returns its path
+func (s *LocalStorage) Save(file *multipart.FileHeader, filename string) (string, error) {
+	if err := os.MkdirAll(s.BaseDir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	filePath := fmt.Sprintf("%s/%s", s.BaseDir, filepath.Base(filename))
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		os.Remove(filePath)
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return filePath, nil
+}
+
+// Exists reports whether a file exists at path
+func (s *LocalStorage) Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Remove deletes the file at path
+func (s *LocalStorage) Remove(path string) error {
+	return os.Remove(path)
+}
@@ -0,0 +1,136 @@
+package transliterate
+
+import (
+	"strings"
+
+	addressModel "passport-booking/models/address"
+)
+
+// Script is the writing system an address is rendered in
+type Script string
+
+const (
+	ScriptBangla Script = "bn"
+	ScriptLatin  Script = "en"
+)
+
+// ParseScript validates a script given by a client
+func ParseScript(s string) (Script, bool) {
+	switch Script(strings.ToLower(strings.TrimSpace(s))) {
+	case ScriptBangla:
+		return ScriptBangla, true
+	case ScriptLatin:
+		return ScriptLatin, true
+	}
+	return "", false
+}
+
+// ScriptFor picks the script for a destination country. Domestic mail is sorted
+// and delivered by postmen who read Bangla; anything leaving the country is
+// addressed in English.
+func ScriptFor(country string) Script {
+	switch strings.ToLower(strings.TrimSpace(country)) {
+	case "", "bangladesh", "bd":
+		return ScriptBangla
+	}
+	return ScriptLatin
+}
+
+// pairs lists the English and Bangla renderings of each localized address field
+func pairs(a *addressModel.Address) [][2]**string {
+	return [][2]**string{
+		{&a.Division, &a.DivisionBn},
+		{&a.District, &a.DistrictBn},
+		{&a.PoliceStation, &a.PoliceStationBn},
+		{&a.PostOffice, &a.PostOfficeBn},
+		{&a.StreetAddress, &a.StreetAddressBn},
+	}
+}
+
+// Localize fills in whichever rendering of each address field is missing. Text
+// typed in Bangla into an English field is moved to the Bangla one and
+// romanized, since DMS only takes English.
+func Localize(a *addressModel.Address) {
+	if a == nil {
+		return
+	}
+	for _, p := range pairs(a) {
+		en, bn := p[0], p[1]
+		if v := value(*en); v != "" && HasBangla(v) {
+			if value(*bn) == "" {
+				*bn = ptr(normalizeBn(v))
+			}
+			*en = ptr(ToLatin(v))
+		}
+		switch {
+		case value(*bn) == "" && value(*en) != "":
+			*bn = ptr(ToBangla(value(*en)))
+		case value(*en) == "" && value(*bn) != "":
+			*en = ptr(ToLatin(value(*bn)))
+		}
+	}
+}
+
+// Lines renders an address for a label or proof of delivery, most specific line
+// first. Bangla falls back to transliterating the English fields of addresses
+// saved before both renderings were stored.
+func Lines(a *addressModel.Address, script Script) []string {
+	if a == nil {
+		return nil
+	}
+	field := func(en, bn *string) string {
+		if script == ScriptBangla {
+			if v := value(bn); v != "" {
+				return v
+			}
+			return ToBangla(value(en))
+		}
+		if v := value(en); v != "" {
+			return ToLatin(v)
+		}
+		return ToLatin(value(bn))
+	}
+
+	postOffice := field(a.PostOffice, a.PostOfficeBn)
+	if code := value(a.PostOfficeCode); code != "" {
+		if script == ScriptBangla {
+			code = ToBangla(code)
+		}
+		postOffice = strings.TrimSpace(postOffice + " - " + code)
+	}
+	candidates := []string{
+		field(a.StreetAddress, a.StreetAddressBn),
+		postOffice,
+		field(a.PoliceStation, a.PoliceStationBn),
+		joinNonEmpty(", ", field(a.District, a.DistrictBn), field(a.Division, a.DivisionBn)),
+	}
+
+	lines := make([]string, 0, len(candidates))
+	for _, line := range candidates {
+		if line = strings.Trim(line, " -,"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	kept := parts[:0]
+	for _, p := range parts {
+		if p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return strings.TrimSpace(*s)
+}
+
+func ptr(s string) *string {
+	return &s
+}
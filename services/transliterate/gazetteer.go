@@ -0,0 +1,136 @@
+package transliterate
+
+import "strings"
+
+// places maps the official English spelling of divisions, districts and common
+// address words to their Bangla names. Rule based transliteration gets these
+// wrong often enough ("Dhaka" is ঢাকা, not ধাকা) that they are looked up first.
+var places = map[string]string{
+	// Divisions
+	"dhaka":      "ঢাকা",
+	"chattogram": "চট্টগ্রাম",
+	"rajshahi":   "রাজশাহী",
+	"khulna":     "খুলনা",
+	"barishal":   "বরিশাল",
+	"sylhet":     "সিলেট",
+	"rangpur":    "রংপুর",
+	"mymensingh": "ময়মনসিংহ",
+
+	// Districts
+	"faridpur":         "ফরিদপুর",
+	"gazipur":          "গাজীপুর",
+	"gopalganj":        "গোপালগঞ্জ",
+	"kishoreganj":      "কিশোরগঞ্জ",
+	"madaripur":        "মাদারীপুর",
+	"manikganj":        "মানিকগঞ্জ",
+	"munshiganj":       "মুন্সীগঞ্জ",
+	"narayanganj":      "নারায়ণগঞ্জ",
+	"narsingdi":        "নরসিংদী",
+	"rajbari":          "রাজবাড়ী",
+	"shariatpur":       "শরীয়তপুর",
+	"tangail":          "টাঙ্গাইল",
+	"bandarban":        "বান্দরবান",
+	"brahmanbaria":     "ব্রাহ্মণবাড়িয়া",
+	"chandpur":         "চাঁদপুর",
+	"cumilla":          "কুমিল্লা",
+	"cox's bazar":      "কক্সবাজার",
+	"feni":             "ফেনী",
+	"khagrachhari":     "খাগড়াছড়ি",
+	"lakshmipur":       "লক্ষ্মীপুর",
+	"noakhali":         "নোয়াখালী",
+	"rangamati":        "রাঙ্গামাটি",
+	"bogura":           "বগুড়া",
+	"joypurhat":        "জয়পুরহাট",
+	"naogaon":          "নওগাঁ",
+	"natore":           "নাটোর",
+	"chapai nawabganj": "চাঁপাইনবাবগঞ্জ",
+	"pabna":            "পাবনা",
+	"sirajganj":        "সিরাজগঞ্জ",
+	"bagerhat":         "বাগেরহাট",
+	"chuadanga":        "চুয়াডাঙ্গা",
+	"jashore":          "যশোর",
+	"jhenaidah":        "ঝিনাইদহ",
+	"kushtia":          "কুষ্টিয়া",
+	"magura":           "মাগুরা",
+	"meherpur":         "মেহেরপুর",
+	"narail":           "নড়াইল",
+	"satkhira":         "সাতক্ষীরা",
+	"barguna":          "বরগুনা",
+	"bhola":            "ভোলা",
+	"jhalokati":        "ঝালকাঠি",
+	"patuakhali":       "পটুয়াখালী",
+	"pirojpur":         "পিরোজপুর",
+	"habiganj":         "হবিগঞ্জ",
+	"moulvibazar":      "মৌলভীবাজার",
+	"sunamganj":        "সুনামগঞ্জ",
+	"dinajpur":         "দিনাজপুর",
+	"gaibandha":        "গাইবান্ধা",
+	"kurigram":         "কুড়িগ্রাম",
+	"lalmonirhat":      "লালমনিরহাট",
+	"nilphamari":       "নীলফামারী",
+	"panchagarh":       "পঞ্চগড়",
+	"thakurgaon":       "ঠাকুরগাঁও",
+	"jamalpur":         "জামালপুর",
+	"netrokona":        "নেত্রকোণা",
+	"sherpur":          "শেরপুর",
+
+	// Address words
+	"road":    "রোড",
+	"lane":    "লেন",
+	"house":   "বাড়ি",
+	"flat":    "ফ্ল্যাট",
+	"block":   "ব্লক",
+	"sector":  "সেক্টর",
+	"village": "গ্রাম",
+	"para":    "পাড়া",
+	"bazar":   "বাজার",
+	"sadar":   "সদর",
+	"thana":   "থানা",
+	"upazila": "উপজেলা",
+	"union":   "ইউনিয়ন",
+	"ward":    "ওয়ার্ড",
+	"no":      "নং",
+}
+
+// aliases are older spellings still common on slips and in DMS data
+var aliases = map[string]string{
+	"chittagong":  "chattogram",
+	"barisal":     "barishal",
+	"comilla":     "cumilla",
+	"coxs bazar":  "cox's bazar",
+	"bogra":       "bogura",
+	"jessore":     "jashore",
+	"nawabganj":   "chapai nawabganj",
+	"jhalakati":   "jhalokati",
+	"netrakona":   "netrokona",
+	"maulvibazar": "moulvibazar",
+	"laxmipur":    "lakshmipur",
+}
+
+// placesBn is the reverse of places, keyed by normalized Bangla
+var placesBn = func() map[string]string {
+	m := make(map[string]string, len(places))
+	for en, bn := range places {
+		m[normalizeBn(bn)] = en
+	}
+	return m
+}()
+
+// lookupBn finds the Bangla name of an English place or address word
+func lookupBn(en string) (string, bool) {
+	key := strings.ToLower(strings.TrimSpace(en))
+	if canonical, ok := aliases[key]; ok {
+		key = canonical
+	}
+	bn, ok := places[key]
+	return normalizeBn(bn), ok
+}
+
+// lookupEn finds the official English spelling of a Bangla place or address word
+func lookupEn(bn string) (string, bool) {
+	en, ok := placesBn[normalizeBn(strings.TrimSpace(bn))]
+	if !ok {
+		return "", false
+	}
+	return titleCase(en), true
+}
@@ -0,0 +1,247 @@
+package transliterate
+
+import (
+	"strings"
+	"unicode"
+)
+
+const hasanta = '্'
+
+// Bangla consonants and their Latin spelling. Each takes the inherent vowel "o"
+// unless followed by a vowel sign or hasanta, or it ends the word.
+var consonantsBn = map[rune]string{
+	'ক': "k", 'খ': "kh", 'গ': "g", 'ঘ': "gh", 'ঙ': "ng",
+	'চ': "ch", 'ছ': "chh", 'জ': "j", 'ঝ': "jh", 'ঞ': "n",
+	'ট': "t", 'ঠ': "th", 'ড': "d", 'ঢ': "dh", 'ণ': "n",
+	'ত': "t", 'থ': "th", 'দ': "d", 'ধ': "dh", 'ন': "n",
+	'প': "p", 'ফ': "f", 'ব': "b", 'ভ': "bh", 'ম': "m",
+	'য': "j", 'র': "r", 'ল': "l", 'শ': "sh", 'ষ': "sh",
+	'স': "s", 'হ': "h", 'ড়': "r", 'ঢ়': "rh", 'য়': "y",
+}
+
+// Vowel signs, independent vowels, marks and digits
+var othersBn = map[rune]string{
+	'া': "a", 'ি': "i", 'ী': "i", 'ু': "u", 'ূ': "u", 'ৃ': "ri",
+	'ে': "e", 'ৈ': "oi", 'ো': "o", 'ৌ': "ou",
+	'অ': "o", 'আ': "a", 'ই': "i", 'ঈ': "i", 'উ': "u", 'ঊ': "u",
+	'ঋ': "ri", 'এ': "e", 'ঐ': "oi", 'ও': "o", 'ঔ': "ou",
+	'ং': "ng", 'ঃ': "h", 'ঁ': "n", 'ৎ': "t", '।': ".",
+	'০': "0", '১': "1", '২': "2", '৩': "3", '৪': "4",
+	'৫': "5", '৬': "6", '৭': "7", '৮': "8", '৯': "9",
+}
+
+// Latin spellings of Bangla consonants, longest first
+var consonantsEn = []struct{ en, bn string }{
+	{"chh", "ছ"}, {"kh", "খ"}, {"gh", "ঘ"}, {"ch", "চ"}, {"jh", "ঝ"},
+	{"th", "থ"}, {"dh", "ধ"}, {"ph", "ফ"}, {"bh", "ভ"}, {"sh", "শ"},
+	{"ck", "ক"}, {"b", "ব"}, {"c", "ক"}, {"d", "দ"}, {"f", "ফ"},
+	{"g", "গ"}, {"h", "হ"}, {"j", "জ"}, {"k", "ক"}, {"l", "ল"},
+	{"m", "ম"}, {"n", "ন"}, {"p", "প"}, {"q", "ক"}, {"r", "র"},
+	{"s", "স"}, {"t", "ত"}, {"v", "ভ"}, {"w", "ব"}, {"x", "ক্স"},
+	{"y", "য়"}, {"z", "জ"},
+}
+
+// Latin vowels with their sign after a consonant and independent form, longest first
+var vowelsEn = []struct{ en, sign, independent string }{
+	{"aa", "া", "আ"}, {"ee", "ী", "ঈ"}, {"oo", "ু", "উ"}, {"oi", "ৈ", "ঐ"},
+	{"ou", "ৌ", "ঔ"}, {"a", "া", "আ"}, {"i", "ি", "ই"}, {"u", "ু", "উ"},
+	{"e", "ে", "এ"}, {"o", "", "ও"},
+}
+
+var bnNormalizer = strings.NewReplacer(
+	"\u09a1\u09bc", "\u09dc", // ড + nukta
+	"\u09a2\u09bc", "\u09dd", // ঢ + nukta
+	"\u09af\u09bc", "\u09df", // য + nukta
+	"\u200c", "", // zero width non-joiner
+	"\u200d", "", // zero width joiner
+)
+
+// normalizeBn folds the decomposed nukta letters some keyboards produce into their
+// precomposed form and drops joiners, so lookups do not depend on the input method
+func normalizeBn(s string) string {
+	return bnNormalizer.Replace(s)
+}
+
+// HasBangla reports whether s contains any Bangla script
+func HasBangla(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Bengali, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToLatin romanizes Bangla text. Known places get their official English spelling;
+// everything else is romanized letter by letter, which is readable rather than exact.
+// The result is plain ASCII, so it also suits the text-only PDFs.
+func ToLatin(s string) string {
+	s = normalizeBn(s)
+	if en, ok := lookupEn(s); ok {
+		return en
+	}
+	return mapRuns(s, func(r rune) bool { return unicode.Is(unicode.Bengali, r) }, func(word string) string {
+		if en, ok := lookupEn(word); ok {
+			return en
+		}
+		return titleCase(romanize(word))
+	})
+}
+
+// ToBangla renders English text in Bangla script. Known places and address words
+// come from the gazetteer; the rest is spelled out phonetically and digits become
+// Bangla digits.
+func ToBangla(s string) string {
+	if bn, ok := lookupBn(s); ok {
+		return bn
+	}
+	return mapRuns(s, isLatinWordRune, func(word string) string {
+		if bn, ok := lookupBn(word); ok {
+			return bn
+		}
+		return phonetic(strings.ToLower(word))
+	})
+}
+
+func isLatinWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// mapRuns applies fn to each maximal run of runes matching inWord and copies the
+// rest (spaces, punctuation) through unchanged
+func mapRuns(s string, inWord func(rune) bool, fn func(string) string) string {
+	var b strings.Builder
+	start := -1
+	for i, r := range s {
+		if inWord(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			b.WriteString(fn(s[start:i]))
+			start = -1
+		}
+		b.WriteRune(r)
+	}
+	if start >= 0 {
+		b.WriteString(fn(s[start:]))
+	}
+	return b.String()
+}
+
+func romanize(word string) string {
+	runes := []rune(word)
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		latin, isConsonant := consonantsBn[r]
+		if !isConsonant {
+			if latin, ok := othersBn[r]; ok {
+				b.WriteString(latin)
+			}
+			continue
+		}
+		// য as the second half of a conjunct is the "ya" glide
+		if r == 'য' && i > 0 && runes[i-1] == hasanta {
+			latin = "y"
+		}
+		b.WriteString(latin)
+
+		if i+1 >= len(runes) {
+			break
+		}
+		next := runes[i+1]
+		switch {
+		case next == hasanta:
+			i++
+		case takesInherentVowel(next):
+			b.WriteString("o")
+		}
+	}
+	return b.String()
+}
+
+// takesInherentVowel reports whether a consonant followed by next keeps its
+// inherent vowel
+func takesInherentVowel(next rune) bool {
+	_, isConsonant := consonantsBn[next]
+	return isConsonant || next == 'ং' || next == 'ঃ'
+}
+
+func phonetic(word string) string {
+	digits := []rune("০১২৩৪৫৬৭৮৯")
+	var b strings.Builder
+	prev := ""
+	afterConsonant, seenVowel := false, false
+	for i := 0; i < len(word); {
+		rest := word[i:]
+		if c := rest[0]; c >= '0' && c <= '9' {
+			b.WriteRune(digits[c-'0'])
+			i++
+			afterConsonant = false
+			continue
+		}
+		if strings.HasPrefix(rest, "ng") && seenVowel {
+			b.WriteString("ং")
+			i += 2
+			afterConsonant, prev = false, ""
+			continue
+		}
+		if bn, n, ok := matchConsonant(rest); ok {
+			switch {
+			case afterConsonant && bn == "য়":
+				bn = "্য"
+			case afterConsonant && (!seenVowel || bn == prev || bn == "র" || bn == "ল"):
+				b.WriteRune(hasanta)
+			}
+			b.WriteString(bn)
+			i += n
+			afterConsonant, prev = true, bn
+			continue
+		}
+		if v, n, ok := matchVowel(rest); ok {
+			switch {
+			case !afterConsonant:
+				b.WriteString(v.independent)
+			case v.en == "o" && i+n == len(word):
+				b.WriteString("ো")
+			default:
+				b.WriteString(v.sign)
+			}
+			i += n
+			afterConsonant, seenVowel, prev = false, true, ""
+			continue
+		}
+		i++
+	}
+	return b.String()
+}
+
+func matchConsonant(s string) (string, int, bool) {
+	for _, c := range consonantsEn {
+		if strings.HasPrefix(s, c.en) {
+			return c.bn, len(c.en), true
+		}
+	}
+	return "", 0, false
+}
+
+func matchVowel(s string) (struct{ en, sign, independent string }, int, bool) {
+	for _, v := range vowelsEn {
+		if strings.HasPrefix(s, v.en) {
+			return v, len(v.en), true
+		}
+	}
+	return struct{ en, sign, independent string }{}, 0, false
+}
+
+// titleCase upper-cases the first letter of each word
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
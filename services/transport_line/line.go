@@ -0,0 +1,199 @@
+package transport_line
+
+import (
+	"errors"
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/branch_schedule"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrLineNotFound is returned when line_id is not a registered transport line
+	ErrLineNotFound = errors.New("transport line not found")
+	// ErrLineInactive is returned when the line has been retired
+	ErrLineInactive = errors.New("transport line is not active")
+	// ErrCapacityReached is returned when the line has carried its daily capacity
+	ErrCapacityReached = errors.New("transport line has reached its daily capacity")
+)
+
+// OfficeNotServedError is returned when the line does not go to a destination office
+type OfficeNotServedError struct {
+	LineCode   string
+	OfficeCode string
+}
+
+func (e *OfficeNotServedError) Error() string {
+	return fmt.Sprintf("transport line %s does not serve office %s", e.LineCode, e.OfficeCode)
+}
+
+// Find loads a line with its offices and schedules, returning ErrLineNotFound when
+// code is not registered
+func Find(db *gorm.DB, code string) (*bookingModel.TransportLine, error) {
+	var line bookingModel.TransportLine
+	err := db.Preload("Offices").Preload("Schedules", func(db *gorm.DB) *gorm.DB {
+		return db.Order("weekday, departs_at")
+	}).Where("code = ?", code).First(&line).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrLineNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+// Serves reports whether the line goes to officeCode
+func Serves(line *bookingModel.TransportLine, officeCode string) bool {
+	for _, o := range line.Offices {
+		if o.OfficeCode == officeCode {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadedOn counts the bags received onto the line on the day of at
+func LoadedOn(db *gorm.DB, lineID uint, at time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&bookingModel.TransportLineLoad{}).
+		Where("line_id = ? AND load_date = ?", lineID, loadDate(at)).
+		Count(&count).Error
+	return count, err
+}
+
+// CheckReceive validates that bagID can be received onto the line with the given code
+// for the destination offices: the line must exist, be active, serve every office and
+// have room left today. A bag already loaded onto the line does not count twice.
+func CheckReceive(db *gorm.DB, code, bagID string, offices []string, at time.Time) (*bookingModel.TransportLine, error) {
+	line, err := Find(db, code)
+	if err != nil {
+		return nil, err
+	}
+	if !line.Active {
+		return nil, ErrLineInactive
+	}
+	for _, office := range offices {
+		if !Serves(line, office) {
+			return nil, &OfficeNotServedError{LineCode: line.Code, OfficeCode: office}
+		}
+	}
+
+	if line.DailyCapacity > 0 {
+		var already int64
+		if err := db.Model(&bookingModel.TransportLineLoad{}).
+			Where("line_id = ? AND bag_id = ?", line.ID, bagID).
+			Count(&already).Error; err != nil {
+			return nil, err
+		}
+		if already == 0 {
+			loaded, err := LoadedOn(db, line.ID, at)
+			if err != nil {
+				return nil, err
+			}
+			if loaded >= int64(line.DailyCapacity) {
+				return nil, ErrCapacityReached
+			}
+		}
+	}
+	return line, nil
+}
+
+// RecordLoad counts bagID against the line's capacity for the day of at
+func RecordLoad(db *gorm.DB, line *bookingModel.TransportLine, bagID, officeCode string, userID uint, at time.Time) error {
+	load := bookingModel.TransportLineLoad{
+		LineID:      line.ID,
+		BagID:       bagID,
+		OfficeCode:  officeCode,
+		LoadDate:    loadDate(at),
+		CreatedByID: userID,
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&load).Error
+}
+
+// Save creates or updates the line by code, replacing its offices and schedules
+func Save(db *gorm.DB, line *bookingModel.TransportLine) error {
+	offices, schedules := line.Offices, line.Schedules
+	line.Offices, line.Schedules = nil, nil
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "code"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "origin_office", "daily_capacity", "active", "updated_by_id", "updated_at"}),
+		}).Create(line).Error; err != nil {
+			return err
+		}
+		// The upsert does not report the id of an existing row
+		if err := tx.Where("code = ?", line.Code).First(line).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("line_id = ?", line.ID).Delete(&bookingModel.TransportLineOffice{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("line_id = ?", line.ID).Delete(&bookingModel.TransportLineSchedule{}).Error; err != nil {
+			return err
+		}
+		for i := range offices {
+			offices[i].ID = 0
+			offices[i].LineID = line.ID
+		}
+		for i := range schedules {
+			schedules[i].ID = 0
+			schedules[i].LineID = line.ID
+		}
+		if len(offices) > 0 {
+			if err := tx.Create(&offices).Error; err != nil {
+				return err
+			}
+		}
+		if len(schedules) > 0 {
+			if err := tx.Create(&schedules).Error; err != nil {
+				return err
+			}
+		}
+		line.Offices, line.Schedules = offices, schedules
+		return nil
+	})
+}
+
+// NextDeparture returns the first scheduled departure of the line after at, or nil when
+// it has no schedule
+func NextDeparture(line *bookingModel.TransportLine, at time.Time) *time.Time {
+	at = at.In(branch_schedule.Location())
+	var departures []time.Time
+	for _, s := range line.Schedules {
+		minutes, err := branch_schedule.ParseClock(s.DepartsAt)
+		if err != nil {
+			continue
+		}
+		for offset := 0; offset <= 7; offset++ {
+			day := at.AddDate(0, 0, offset)
+			if int(day.Weekday()) != s.Weekday {
+				continue
+			}
+			y, m, d := day.Date()
+			t := time.Date(y, m, d, 0, 0, 0, 0, at.Location()).Add(time.Duration(minutes) * time.Minute)
+			if t.After(at) {
+				departures = append(departures, t)
+				break
+			}
+		}
+	}
+	if len(departures) == 0 {
+		return nil
+	}
+	sort.Slice(departures, func(i, j int) bool { return departures[i].Before(departures[j]) })
+	return &departures[0]
+}
+
+// loadDate is the branch-local calendar day of at
+func loadDate(at time.Time) time.Time {
+	at = at.In(branch_schedule.Location())
+	y, m, d := at.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, at.Location())
+}
@@ -0,0 +1,183 @@
+// Package user_activity lists what one user did across bookings, bags and parcel
+// bookings, for HR reviews and incident investigations. It reads the records the
+// actions already leave behind instead of keeping a separate activity log.
+package user_activity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Category groups activity by what it was done to
+type Category string
+
+const (
+	CategoryBooking Category = "booking"
+	CategoryBag     Category = "bag"
+	CategoryParcel  Category = "parcel"
+)
+
+// Categories lists every category in display order
+func Categories() []Category {
+	return []Category{CategoryBooking, CategoryBag, CategoryParcel}
+}
+
+// IsValid reports whether c is a known category
+func (c Category) IsValid() bool {
+	for _, known := range Categories() {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Actions recorded outside the booking event log
+const (
+	ActionBagOpened = "bag_opened"
+	ActionBagLoaded = "bag_loaded_on_line"
+	// parcel status events are reported as "parcel_<status>"
+	parcelActionPrefix = "parcel_"
+)
+
+// Filter selects the activity returned
+type Filter struct {
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	Categories []Category
+	Action     string
+	Limit      int
+	Offset     int
+}
+
+// Entry is one action the user performed
+type Entry struct {
+	OccurredAt time.Time `json:"occurred_at"`
+	Category   Category  `json:"category"`
+	Action     string    `json:"action"`
+	Reference  *string   `json:"reference,omitempty"` // application or order ID
+	Barcode    *string   `json:"barcode,omitempty"`
+	BagID      *string   `json:"bag_id,omitempty"`
+	Status     *string   `json:"status,omitempty"`
+}
+
+// ActionCount is how often the user performed an action in the range
+type ActionCount struct {
+	Category Category `json:"category"`
+	Action   string   `json:"action"`
+	Count    int64    `json:"count"`
+}
+
+// Feed is a page of a user's activity with totals for the whole range
+type Feed struct {
+	Entries []Entry       `json:"entries"`
+	Summary []ActionCount `json:"summary"`
+	Total   int64         `json:"total"`
+}
+
+// source is one table the feed reads, projected onto the Entry columns
+type source struct {
+	category Category
+	query    string
+	args     func(userID uint, from, to time.Time) []interface{}
+}
+
+// sources read each table through an index on its actor column. Booking events
+// copy the booking's created_at, so their updated_at is the time of the action.
+var sources = []source{
+	{
+		category: CategoryBooking,
+		query: `SELECT e.updated_at AS occurred_at, 'booking' AS category, e.event_type AS action,
+			e.app_or_order_id AS reference, e.barcode AS barcode, CAST(NULL AS TEXT) AS bag_id, CAST(e.status AS TEXT) AS status
+			FROM booking_events e
+			WHERE e.updated_by = ? AND e.updated_at >= ? AND e.updated_at < ?`,
+		args: func(userID uint, from, to time.Time) []interface{} {
+			return []interface{}{strconv.FormatUint(uint64(userID), 10), from, to}
+		},
+	},
+	{
+		category: CategoryBag,
+		query: `SELECT b.created_at AS occurred_at, 'bag' AS category, '` + ActionBagOpened + `' AS action,
+			CAST(NULL AS TEXT) AS reference, CAST(NULL AS TEXT) AS barcode, b.bag_id AS bag_id, CAST(b.status AS TEXT) AS status
+			FROM bags b
+			WHERE b.created_by_id = ? AND b.created_at >= ? AND b.created_at < ?`,
+		args: actorArgs,
+	},
+	{
+		category: CategoryBag,
+		query: `SELECT l.created_at AS occurred_at, 'bag' AS category, '` + ActionBagLoaded + `' AS action,
+			CAST(NULL AS TEXT) AS reference, CAST(NULL AS TEXT) AS barcode, l.bag_id AS bag_id, CAST(NULL AS TEXT) AS status
+			FROM transport_line_loads l
+			WHERE l.created_by_id = ? AND l.created_at >= ? AND l.created_at < ?`,
+		args: actorArgs,
+	},
+	{
+		category: CategoryParcel,
+		query: `SELECT s.created_at AS occurred_at, 'parcel' AS category, '` + parcelActionPrefix + `' || LOWER(s.status) AS action,
+			CAST(NULL AS TEXT) AS reference, p.barcode AS barcode, CAST(NULL AS TEXT) AS bag_id, CAST(s.status AS TEXT) AS status
+			FROM parcel_booking_status_events s
+			JOIN parcel_bookings p ON p.id = s.parcel_booking_id
+			WHERE s.created_by = ? AND s.created_at >= ? AND s.created_at < ?`,
+		args: actorArgs,
+	},
+}
+
+func actorArgs(userID uint, from, to time.Time) []interface{} {
+	return []interface{}{userID, from, to}
+}
+
+// union combines the sources of the requested categories
+func union(userID uint, filter Filter) (string, []interface{}) {
+	wanted := make(map[Category]bool, len(filter.Categories))
+	for _, c := range filter.Categories {
+		wanted[c] = true
+	}
+
+	var parts []string
+	var args []interface{}
+	for _, s := range sources {
+		if len(wanted) > 0 && !wanted[s.category] {
+			continue
+		}
+		parts = append(parts, s.query)
+		args = append(args, s.args(userID, filter.From, filter.To)...)
+	}
+	return strings.Join(parts, "\nUNION ALL\n"), args
+}
+
+// List returns a page of the user's activity, newest first, with the number of
+// times each action was performed in the range
+func List(db *gorm.DB, userID uint, filter Filter) (*Feed, error) {
+	feed := &Feed{Entries: []Entry{}, Summary: []ActionCount{}}
+	activity, args := union(userID, filter)
+	if activity == "" {
+		return feed, nil
+	}
+
+	if err := db.Raw(`SELECT category, action, COUNT(*) AS count FROM (`+activity+`) a
+		GROUP BY category, action ORDER BY category, count DESC, action`, args...).
+		Scan(&feed.Summary).Error; err != nil {
+		return nil, fmt.Errorf("failed to summarize activity: %w", err)
+	}
+	for _, s := range feed.Summary {
+		if filter.Action == "" || s.Action == filter.Action {
+			feed.Total += s.Count
+		}
+	}
+
+	page := `SELECT * FROM (` + activity + `) a`
+	if filter.Action != "" {
+		page += ` WHERE action = ?`
+		args = append(args, filter.Action)
+	}
+	page += ` ORDER BY occurred_at DESC, category, action LIMIT ? OFFSET ?`
+	args = append(args, filter.Limit, filter.Offset)
+	if err := db.Raw(page, args...).Scan(&feed.Entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list activity: %w", err)
+	}
+	return feed, nil
+}
@@ -0,0 +1,180 @@
+package user_admin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/logger"
+	userModel "passport-booking/models/user"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How long the active delegations are trusted before they are reloaded. Expiry is
+// checked against the clock on every request, so a grant never outlives ExpiresAt.
+const delegationRefresh = 30 * time.Second
+
+var (
+	// ErrDelegateSelf is returned when a user delegates to themselves
+	ErrDelegateSelf = errors.New("you cannot delegate to yourself")
+	// ErrDelegationNotFound is returned when no active delegation has the id
+	ErrDelegationNotFound = errors.New("delegation not found")
+)
+
+// NotHeldError is returned when the grantor tries to hand out permissions they do
+// not hold themselves
+type NotHeldError struct {
+	Permissions []string
+}
+
+func (e *NotHeldError) Error() string {
+	return fmt.Sprintf("you can only delegate permissions you hold: %v", e.Permissions)
+}
+
+// MaxDelegation is the longest a grant may run, from DELEGATION_MAX_DAYS (30)
+func MaxDelegation() time.Duration {
+	days := 30
+	if v := os.Getenv("DELEGATION_MAX_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			days = n
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// grant is the cached part of a delegation needed on every request
+type grant struct {
+	permissions []string
+	startsAt    time.Time
+	expiresAt   time.Time
+}
+
+var delegations = struct {
+	sync.Mutex
+	byUUID   map[string][]grant
+	loadedAt time.Time
+}{}
+
+// DelegatedPermissions returns the permissions delegated to the user behind uuid
+// that are in force at now
+func DelegatedPermissions(db *gorm.DB, uuid string, now time.Time) []string {
+	delegations.Lock()
+	defer delegations.Unlock()
+
+	if time.Since(delegations.loadedAt) >= delegationRefresh {
+		var active []userModel.Delegation
+		if err := db.Where("revoked_at IS NULL AND expires_at > ?", now).Find(&active).Error; err != nil {
+			logger.Error("Failed to load delegations", err)
+		} else {
+			delegations.byUUID = make(map[string][]grant)
+			for _, d := range active {
+				delegations.byUUID[d.GranteeUUID] = append(delegations.byUUID[d.GranteeUUID], grant{
+					permissions: d.Permissions,
+					startsAt:    d.StartsAt,
+					expiresAt:   d.ExpiresAt,
+				})
+			}
+		}
+		delegations.loadedAt = time.Now()
+	}
+
+	var permissions []string
+	for _, g := range delegations.byUUID[uuid] {
+		if !now.Before(g.startsAt) && now.Before(g.expiresAt) {
+			permissions = append(permissions, g.permissions...)
+		}
+	}
+	return permissions
+}
+
+// forgetDelegations makes the next DelegatedPermissions call reload
+func forgetDelegations() {
+	delegations.Lock()
+	delegations.loadedAt = time.Time{}
+	delegations.Unlock()
+}
+
+// Delegate grants permissions from grantor to grantee between startsAt and
+// expiresAt. held is what the grantor holds in their own right; delegated
+// permissions cannot be passed on.
+func Delegate(db *gorm.DB, grantor, grantee *userModel.User, held map[string]bool, permissions []string, startsAt, expiresAt time.Time, reason string) (*userModel.Delegation, error) {
+	if grantor.ID == grantee.ID {
+		return nil, ErrDelegateSelf
+	}
+	var missing []string
+	for _, p := range permissions {
+		if !held[p] {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &NotHeldError{Permissions: missing}
+	}
+
+	delegation := userModel.Delegation{
+		GrantorID:   grantor.ID,
+		GranteeID:   grantee.ID,
+		GranteeUUID: grantee.Uuid,
+		Permissions: permissions,
+		Reason:      reason,
+		StartsAt:    startsAt,
+		ExpiresAt:   expiresAt,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&delegation).Error; err != nil {
+			return err
+		}
+		return audit(tx, grantee, grantor, userModel.AdminActionDelegate, map[string]interface{}{
+			"delegation_id": delegation.ID,
+			"permissions":   permissions,
+			"starts_at":     startsAt,
+			"expires_at":    expiresAt,
+		}, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	forgetDelegations()
+	return &delegation, nil
+}
+
+// Revoke ends a delegation early. Only its grantor or a super admin (anyone is
+// true) may revoke it.
+func Revoke(db *gorm.DB, id uint, actor *userModel.User, anyone bool, reason string) (*userModel.Delegation, error) {
+	var delegation userModel.Delegation
+	query := db.Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now())
+	if !anyone {
+		query = query.Where("grantor_id = ?", actor.ID)
+	}
+	if err := query.First(&delegation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDelegationNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&delegation).Updates(map[string]interface{}{
+			"revoked_at":    now,
+			"revoked_by_id": actor.ID,
+		}).Error; err != nil {
+			return err
+		}
+		return audit(tx, &userModel.User{ID: delegation.GranteeID}, actor, userModel.AdminActionRevoke, map[string]interface{}{
+			"delegation_id": delegation.ID,
+		}, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delegation.RevokedAt = &now
+	delegation.RevokedByID = &actor.ID
+	forgetDelegations()
+	return &delegation, nil
+}
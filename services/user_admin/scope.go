@@ -0,0 +1,26 @@
+package user_admin
+
+import (
+	userModel "passport-booking/models/user"
+	"passport-booking/services/branch_hierarchy"
+
+	"gorm.io/gorm"
+)
+
+// BranchScope returns the branch codes a user is mapped to. A mapping to a region
+// or district covers every branch under it in the hierarchy.
+func BranchScope(db *gorm.DB, userID uint) ([]string, error) {
+	var codes []string
+	if err := db.Model(&userModel.UserBranch{}).Where("user_id = ?", userID).Pluck("branch_code", &codes).Error; err != nil {
+		return nil, err
+	}
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	tree, err := branch_hierarchy.Load(db)
+	if err != nil {
+		return nil, err
+	}
+	return tree.BranchCodes(codes), nil
+}
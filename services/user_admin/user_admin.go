@@ -0,0 +1,220 @@
+package user_admin
+
+import (
+	"encoding/json"
+	"errors"
+	"passport-booking/logger"
+	userModel "passport-booking/models/user"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// How long the set of deactivated users is trusted before it is reloaded
+const inactiveRefresh = 30 * time.Second
+
+// ErrSelf is returned when administrators try to deactivate themselves
+var ErrSelf = errors.New("you cannot deactivate your own account")
+
+// ErrNotPending is returned when reviewing a registration that was already reviewed
+var ErrNotPending = errors.New("registration is not pending approval")
+
+// inactive caches the UUIDs of deactivated and unapproved users so the auth
+// middleware does not query the database on every request
+var inactive = struct {
+	sync.Mutex
+	uuids    map[string]bool
+	loadedAt time.Time
+}{}
+
+// IsInactive reports whether the user behind uuid has been deactivated or is not
+// approved yet
+func IsInactive(db *gorm.DB, uuid string) bool {
+	inactive.Lock()
+	defer inactive.Unlock()
+
+	if time.Since(inactive.loadedAt) >= inactiveRefresh {
+		var uuids []string
+		if err := db.Model(&userModel.User{}).Where("is_active = ? OR approval_status <> ?", false, userModel.ApprovalStatusApproved).Pluck("uuid", &uuids).Error; err != nil {
+			// Keep the previous set rather than locking everyone in or out
+			logger.Error("Failed to load deactivated users", err)
+		} else {
+			inactive.uuids = make(map[string]bool, len(uuids))
+			for _, uuid := range uuids {
+				inactive.uuids[uuid] = true
+			}
+		}
+		inactive.loadedAt = time.Now()
+	}
+	return inactive.uuids[uuid]
+}
+
+// forget makes the next IsInactive call reload the deactivated users
+func forget() {
+	inactive.Lock()
+	inactive.loadedAt = time.Time{}
+	inactive.Unlock()
+}
+
+// audit records an admin change inside the transaction that made it
+func audit(tx *gorm.DB, target, actor *userModel.User, action userModel.AdminAction, details interface{}, reason string) error {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&userModel.UserAdminAudit{
+		UserID:  target.ID,
+		ActorID: actor.ID,
+		Action:  action,
+		Details: string(encoded),
+		Reason:  reason,
+	}).Error
+}
+
+// SetActive switches a user on or off. Deactivated users are refused by the auth
+// middleware and at login.
+func SetActive(db *gorm.DB, target, actor *userModel.User, active bool, reason string) error {
+	if !active && target.ID == actor.ID {
+		return ErrSelf
+	}
+	if target.IsActive == active {
+		return nil
+	}
+
+	action := userModel.AdminActionDeactivate
+	if active {
+		action = userModel.AdminActionActivate
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(target).Update("is_active", active).Error; err != nil {
+			return err
+		}
+		return audit(tx, target, actor, action, map[string]bool{"is_active": active}, reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	forget()
+	return nil
+}
+
+// MapBranch maps the user to a branch, updating the relationship of an existing
+// mapping
+func MapBranch(db *gorm.DB, target, actor *userModel.User, branchCode, relationship, reason string) (*userModel.UserBranch, error) {
+	var mapping *userModel.UserBranch
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		mapping, err = mapBranch(tx, target, actor, branchCode, relationship, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// mapBranch upserts the mapping and audits it inside tx
+func mapBranch(tx *gorm.DB, target, actor *userModel.User, branchCode, relationship, reason string) (*userModel.UserBranch, error) {
+	mapping := userModel.UserBranch{
+		UserID:       target.ID,
+		BranchCode:   branchCode,
+		Relationship: relationship,
+		MappedByID:   actor.ID,
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "branch_code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"relationship", "mapped_by_id"}),
+	}).Create(&mapping).Error; err != nil {
+		return nil, err
+	}
+	err := audit(tx, target, actor, userModel.AdminActionMapBranch, map[string]string{
+		"branch_code":  branchCode,
+		"relationship": relationship,
+	}, reason)
+	return &mapping, err
+}
+
+// UnmapBranch removes the user's mapping to a branch. It returns
+// gorm.ErrRecordNotFound when the user is not mapped to it.
+func UnmapBranch(db *gorm.DB, target, actor *userModel.User, branchCode, reason string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND branch_code = ?", target.ID, branchCode).Delete(&userModel.UserBranch{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return audit(tx, target, actor, userModel.AdminActionUnmapBranch, map[string]string{
+			"branch_code": branchCode,
+		}, reason)
+	})
+}
+
+// Approve lets a pending registration in and maps the user to the branch they asked
+// to join
+func Approve(db *gorm.DB, target, actor *userModel.User, note string) error {
+	return review(db, target, actor, userModel.ApprovalStatusApproved, note)
+}
+
+// Reject turns a pending registration away; the user stays locked out
+func Reject(db *gorm.DB, target, actor *userModel.User, note string) error {
+	return review(db, target, actor, userModel.ApprovalStatusRejected, note)
+}
+
+// review records the decision on a pending registration. The pending check is part
+// of the update so two reviewers cannot both decide.
+func review(db *gorm.DB, target, actor *userModel.User, status userModel.ApprovalStatus, note string) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"approval_status": status,
+		"reviewed_at":     now,
+		"review_note":     nil,
+	}
+	if note != "" {
+		updates["review_note"] = note
+	}
+	action := userModel.AdminActionReject
+	if status == userModel.ApprovalStatusApproved {
+		updates["approved_by_id"] = actor.ID
+		action = userModel.AdminActionApprove
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&userModel.User{}).
+			Where("id = ? AND approval_status = ?", target.ID, userModel.ApprovalStatusPending).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotPending
+		}
+		if err := audit(tx, target, actor, action, map[string]interface{}{"approval_status": status}, note); err != nil {
+			return err
+		}
+		if status == userModel.ApprovalStatusApproved && target.RequestedBranchCode != nil && *target.RequestedBranchCode != "" {
+			if _, err := mapBranch(tx, target, actor, *target.RequestedBranchCode, "operator", "approved registration"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	target.ApprovalStatus = status
+	target.ReviewedAt = &now
+	if note != "" {
+		target.ReviewNote = &note
+	}
+	if status == userModel.ApprovalStatusApproved {
+		target.ApprovedByID = &actor.ID
+	}
+	forget()
+	return nil
+}
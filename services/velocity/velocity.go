@@ -0,0 +1,74 @@
+package velocity
+
+import (
+	"sync"
+	"time"
+)
+
+// Window counts hits per key over a sliding time window, in memory. Counts are per
+// instance, which is enough to notice one client hammering an endpoint.
+type Window struct {
+	size time.Duration
+
+	mu    sync.Mutex
+	hits  map[string][]time.Time
+	swept time.Time
+}
+
+// NewWindow creates a window of the given size
+func NewWindow(size time.Duration) *Window {
+	return &Window{size: size, hits: make(map[string][]time.Time)}
+}
+
+// Hit records a hit for key and returns how many hits the key has in the window,
+// including this one
+func (w *Window) Hit(key string, now time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.sweep(now)
+	recent := w.trim(w.hits[key], now)
+	recent = append(recent, now)
+	w.hits[key] = recent
+	return len(recent)
+}
+
+// Count returns the hits key has in the window without recording one
+func (w *Window) Count(key string, now time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.trim(w.hits[key], now))
+}
+
+// Reset forgets the hits of key
+func (w *Window) Reset(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.hits, key)
+}
+
+// trim drops the hits that fell out of the window
+func (w *Window) trim(hits []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-w.size)
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	return hits[i:]
+}
+
+// sweep drops idle keys once per window so the map does not grow with every client
+// ever seen
+func (w *Window) sweep(now time.Time) {
+	if now.Sub(w.swept) < w.size {
+		return
+	}
+	w.swept = now
+	for key, hits := range w.hits {
+		if len(w.trim(hits, now)) == 0 {
+			delete(w.hits, key)
+		}
+	}
+}
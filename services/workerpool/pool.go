@@ -0,0 +1,381 @@
+// Package workerpool runs background work on a bounded set of workers shared by the
+// dispatchers, instead of each one starting its own goroutines. Work is submitted to
+// named queues; idle workers take from the highest priority queue first, a panicking
+// task is recovered and counted, and Shutdown lets queued work finish before exit.
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/logger"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWorkers       = 8
+	defaultQueueCapacity = 1000
+	defaultDrainSeconds  = 30
+)
+
+var (
+	// ErrClosed is returned when submitting after Shutdown started
+	ErrClosed = errors.New("worker pool is shutting down")
+	// ErrQueueFull is returned when a queue already holds its capacity of tasks
+	ErrQueueFull = errors.New("worker pool queue is full")
+)
+
+// Priority orders queues; workers always serve a higher priority queue first
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityNormal:
+		return "normal"
+	}
+	return "low"
+}
+
+// Task is one unit of work. A returned error is logged and counted as failed.
+type Task func() error
+
+// QueueStats are the counters of one queue
+type QueueStats struct {
+	Name      string `json:"name"`
+	Priority  string `json:"priority"`
+	Capacity  int    `json:"capacity"`
+	Queued    int    `json:"queued"`
+	Running   int    `json:"running"`
+	Submitted int64  `json:"submitted"`
+	Completed int64  `json:"completed"`
+	Failed    int64  `json:"failed"`
+	Panicked  int64  `json:"panicked"`
+	Rejected  int64  `json:"rejected"`
+	// AvgWaitMs is the mean time tasks spent queued before a worker took them
+	AvgWaitMs float64 `json:"avg_wait_ms"`
+}
+
+// Stats is a snapshot of the pool
+type Stats struct {
+	Workers int          `json:"workers"`
+	Busy    int          `json:"busy"`
+	Closed  bool         `json:"closed"`
+	Queues  []QueueStats `json:"queues"`
+}
+
+type job struct {
+	task     Task
+	queuedAt time.Time
+}
+
+type queue struct {
+	name     string
+	priority Priority
+	capacity int
+	jobs     []job
+
+	running   int
+	submitted int64
+	completed int64
+	failed    int64
+	panicked  int64
+	rejected  int64
+	waited    time.Duration
+	started   int64
+}
+
+// Pool is a fixed set of workers serving prioritised queues
+type Pool struct {
+	workers int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queues map[string]*queue
+	order  []*queue // by priority, highest first
+	busy   int
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New starts a pool of workers
+func New(workers int) *Pool {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	p := &Pool{workers: workers, queues: map[string]*queue{}}
+	p.cond = sync.NewCond(&p.mu)
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) queue(name string, priority Priority, capacity int) *queue {
+	q, ok := p.queues[name]
+	if ok {
+		return q
+	}
+	q = &queue{name: name, priority: priority, capacity: capacity}
+	p.queues[name] = q
+	p.order = append(p.order, q)
+	sort.SliceStable(p.order, func(i, j int) bool { return p.order[i].priority > p.order[j].priority })
+	return q
+}
+
+func (p *Pool) submit(name string, priority Priority, capacity int, task Task) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	q := p.queue(name, priority, capacity)
+	if p.closed {
+		q.rejected++
+		return ErrClosed
+	}
+	if len(q.jobs) >= q.capacity {
+		q.rejected++
+		return ErrQueueFull
+	}
+	q.jobs = append(q.jobs, job{task: task, queuedAt: time.Now()})
+	q.submitted++
+	p.cond.Signal()
+	return nil
+}
+
+// next blocks until there is a job, taking from the highest priority queue. It returns
+// nil once the pool is closed and every queue is empty.
+func (p *Pool) next() (*queue, *job) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		for _, q := range p.order {
+			if len(q.jobs) == 0 {
+				continue
+			}
+			j := q.jobs[0]
+			q.jobs[0] = job{}
+			q.jobs = q.jobs[1:]
+			q.running++
+			q.started++
+			q.waited += time.Since(j.queuedAt)
+			p.busy++
+			return q, &j
+		}
+		if p.closed {
+			return nil, nil
+		}
+		p.cond.Wait()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for {
+		q, j := p.next()
+		if j == nil {
+			return
+		}
+		panicked, err := run(j.task)
+
+		p.mu.Lock()
+		q.running--
+		p.busy--
+		switch {
+		case panicked:
+			q.panicked++
+		case err != nil:
+			q.failed++
+		default:
+			q.completed++
+		}
+		p.mu.Unlock()
+
+		if err != nil {
+			logger.Error(fmt.Sprintf("Worker pool task on queue %s failed", q.name), err)
+		}
+	}
+}
+
+// run executes the task, turning a panic into an error so one bad task cannot take
+// the worker down
+func run(task Task) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+			panicked = true
+		}
+	}()
+	return false, task()
+}
+
+// Shutdown stops accepting work and waits for queued and running tasks to finish, or
+// for ctx to end
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("worker pool did not drain: %w", ctx.Err())
+	}
+}
+
+// Stats returns the pool counters
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := Stats{Workers: p.workers, Busy: p.busy, Closed: p.closed}
+	for _, q := range p.order {
+		s := QueueStats{
+			Name:      q.name,
+			Priority:  q.priority.String(),
+			Capacity:  q.capacity,
+			Queued:    len(q.jobs),
+			Running:   q.running,
+			Submitted: q.submitted,
+			Completed: q.completed,
+			Failed:    q.failed,
+			Panicked:  q.panicked,
+			Rejected:  q.rejected,
+		}
+		if q.started > 0 {
+			s.AvgWaitMs = float64(q.waited.Milliseconds()) / float64(q.started)
+		}
+		stats.Queues = append(stats.Queues, s)
+	}
+	return stats
+}
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return fallback
+}
+
+var (
+	defaultPool *Pool
+	defaultOnce sync.Once
+)
+
+// Default is the process-wide pool, started on first use with WORKER_POOL_SIZE
+// (default 8) workers
+func Default() *Pool {
+	defaultOnce.Do(func() {
+		defaultPool = New(envInt("WORKER_POOL_SIZE", defaultWorkers))
+	})
+	return defaultPool
+}
+
+// DrainTimeout is how long shutdown waits for queued work; WORKER_POOL_DRAIN_SECONDS
+// overrides the default of 30 seconds
+func DrainTimeout() time.Duration {
+	return time.Duration(envInt("WORKER_POOL_DRAIN_SECONDS", defaultDrainSeconds)) * time.Second
+}
+
+// Shutdown drains the default pool
+func Shutdown(ctx context.Context) error {
+	return Default().Shutdown(ctx)
+}
+
+// Queue is a named queue on the default pool. Declare one per dispatcher as a package
+// variable; the pool is only started once something is submitted.
+type Queue struct {
+	Name     string
+	Priority Priority
+	// Capacity bounds the tasks waiting in the queue; WORKER_POOL_QUEUE_CAPACITY
+	// overrides the default of 1000 when zero
+	Capacity int
+}
+
+// NewQueue declares a queue on the default pool
+func NewQueue(name string, priority Priority) *Queue {
+	return &Queue{Name: name, Priority: priority}
+}
+
+func (q *Queue) capacity() int {
+	if q.Capacity > 0 {
+		return q.Capacity
+	}
+	return envInt("WORKER_POOL_QUEUE_CAPACITY", defaultQueueCapacity)
+}
+
+// Submit queues task, returning ErrQueueFull or ErrClosed when it was not accepted
+func (q *Queue) Submit(task Task) error {
+	return Default().submit(q.Name, q.Priority, q.capacity(), task)
+}
+
+// Go queues task and logs when it is rejected, for fire-and-forget dispatch
+func (q *Queue) Go(task Task) {
+	if err := q.Submit(task); err != nil {
+		logger.Error(fmt.Sprintf("Dropped task on worker pool queue %s", q.Name), err)
+	}
+}
+
+// Group runs related tasks on the queue and waits for all of them, like a scoped
+// set of goroutines. Tasks the queue rejects run on the caller instead so Wait always
+// covers every task. Wait must not be called from a pool task, which could leave every
+// worker waiting on work none of them is free to run.
+type Group struct {
+	queue *Queue
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	errs  []error
+}
+
+// Group starts a task group on the queue
+func (q *Queue) Group() *Group {
+	return &Group{queue: q}
+}
+
+// Go adds task to the group
+func (g *Group) Go(task Task) {
+	g.wg.Add(1)
+	wrapped := func() error {
+		defer g.wg.Done()
+		_, err := run(task)
+		if err != nil {
+			g.mu.Lock()
+			g.errs = append(g.errs, err)
+			g.mu.Unlock()
+		}
+		// Counted on the group; returning it would log it a second time
+		return nil
+	}
+	if err := g.queue.Submit(wrapped); err != nil {
+		wrapped()
+	}
+}
+
+// Wait blocks until every task of the group has finished and returns their errors
+// joined
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return errors.Join(g.errs...)
+}
@@ -0,0 +1,196 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPoolConcurrency(t *testing.T) {
+	p := New(4)
+	release := make(chan struct{})
+	var running, peak atomic.Int32
+	for i := 0; i < 20; i++ {
+		err := p.submit("test", PriorityNormal, 100, func() error {
+			n := running.Add(1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, "every worker to be busy", func() bool { return p.Stats().Busy == 4 })
+	if queued := p.Stats().Queues[0].Queued; queued != 16 {
+		t.Errorf("%d tasks queued, want 16", queued)
+	}
+	close(release)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if peak.Load() != 4 {
+		t.Errorf("%d tasks ran at once, want 4", peak.Load())
+	}
+	if completed := p.Stats().Queues[0].Completed; completed != 20 {
+		t.Errorf("%d tasks completed, want 20", completed)
+	}
+}
+
+func TestPoolPriority(t *testing.T) {
+	p := New(1)
+	release := make(chan struct{})
+	p.submit("blocker", PriorityNormal, 10, func() error { <-release; return nil })
+	waitFor(t, "the worker to be busy", func() bool { return p.Stats().Busy == 1 })
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) Task {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+	p.submit("low", PriorityLow, 10, record("low"))
+	p.submit("high", PriorityHigh, 10, record("high"))
+	close(release)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(order) != 2 || order[0] != "high" {
+		t.Errorf("ran %v, want high first", order)
+	}
+}
+
+func TestPoolCounts(t *testing.T) {
+	p := New(2)
+	p.submit("test", PriorityNormal, 10, func() error { return nil })
+	p.submit("test", PriorityNormal, 10, func() error { return errors.New("failed") })
+	p.submit("test", PriorityNormal, 10, func() error { panic("boom") })
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	q := p.Stats().Queues[0]
+	if q.Submitted != 3 || q.Completed != 1 || q.Failed != 1 || q.Panicked != 1 {
+		t.Errorf("stats %+v, want one completed, failed and panicked", q)
+	}
+}
+
+func TestPoolQueueFull(t *testing.T) {
+	p := New(1)
+	release := make(chan struct{})
+	p.submit("test", PriorityNormal, 1, func() error { <-release; return nil })
+	waitFor(t, "the worker to be busy", func() bool { return p.Stats().Busy == 1 })
+
+	if err := p.submit("test", PriorityNormal, 1, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.submit("test", PriorityNormal, 1, func() error { return nil }); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("error %v, want ErrQueueFull", err)
+	}
+	close(release)
+	p.Shutdown(context.Background())
+}
+
+func TestPoolShutdown(t *testing.T) {
+	p := New(1)
+	var ran atomic.Int32
+	for i := 0; i < 5; i++ {
+		p.submit("test", PriorityNormal, 10, func() error {
+			time.Sleep(time.Millisecond)
+			ran.Add(1)
+			return nil
+		})
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if ran.Load() != 5 {
+		t.Errorf("%d queued tasks ran before shutdown returned, want 5", ran.Load())
+	}
+
+	if err := p.submit("test", PriorityNormal, 10, func() error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("error %v, want ErrClosed", err)
+	}
+	if stats := p.Stats(); !stats.Closed || stats.Queues[0].Rejected != 1 {
+		t.Errorf("stats %+v, want closed with one rejection", stats)
+	}
+}
+
+func TestPoolShutdownTimeout(t *testing.T) {
+	p := New(1)
+	release := make(chan struct{})
+	defer close(release)
+	p.submit("test", PriorityNormal, 10, func() error { <-release; return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want the deadline", err)
+	}
+}
+
+func TestGroupWait(t *testing.T) {
+	queue := &Queue{Name: "test-group", Priority: PriorityNormal, Capacity: 1}
+	group := queue.Group()
+	var ran atomic.Int32
+	for i := 0; i < 10; i++ {
+		group.Go(func() error {
+			ran.Add(1)
+			if i%5 == 0 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+	}
+	err := group.Wait()
+	if ran.Load() != 10 {
+		t.Errorf("%d tasks ran, want 10, including those the full queue rejected", ran.Load())
+	}
+	if err == nil || len(err.(interface{ Unwrap() []error }).Unwrap()) != 2 {
+		t.Errorf("error %v, want both failures joined", err)
+	}
+}
+
+// BenchmarkWorkerPoolGroup100 fans 100 no-op tasks out on the worker pool and waits
+// for them, the overhead an outbox drain pays per batch
+func BenchmarkWorkerPoolGroup100(b *testing.B) {
+	queue := NewQueue("bench", PriorityNormal)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		group := queue.Group()
+		for j := 0; j < 100; j++ {
+			group.Go(func() error { return nil })
+		}
+		if err := group.Wait(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
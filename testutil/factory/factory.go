@@ -0,0 +1,219 @@
+// Package factory builds valid models for tests. Every builder fills the required
+// columns with unique values and applies the overrides in order, e.g.
+//
+//	b := factory.CreateBooking(t, tx, func(b *bookingModel.Booking) {
+//		b.Status = bookingModel.BookingStatusBooked
+//	})
+//
+// Build* returns an unsaved value; Create* saves it, creating the rows it depends
+// on when their IDs are left zero, and fails the test on error.
+package factory
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	addressModel "passport-booking/models/address"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/models/otp"
+	"passport-booking/models/parcel_booking"
+	"passport-booking/models/user"
+
+	"gorm.io/gorm"
+)
+
+var seq atomic.Int64
+
+// next returns a number unique within the test binary, for unique columns
+func next() int64 {
+	return seq.Add(1)
+}
+
+// phone returns a unique, well-formed Bangladeshi mobile number
+func phone(n int64) string {
+	return fmt.Sprintf("+88017%08d", n%100000000)
+}
+
+func apply[T any](v *T, overrides []func(*T)) {
+	for _, o := range overrides {
+		o(v)
+	}
+}
+
+func create(t testing.TB, db *gorm.DB, v interface{}) {
+	t.Helper()
+	if err := db.Create(v).Error; err != nil {
+		t.Fatalf("factory: failed to create %T: %v", v, err)
+	}
+}
+
+// BuildUser returns an active, approved user with the given overrides
+func BuildUser(overrides ...func(*user.User)) user.User {
+	n := next()
+	u := user.User{
+		Uuid:           fmt.Sprintf("00000000-0000-4000-8000-%012d", n),
+		Username:       fmt.Sprintf("user%d", n),
+		LegalName:      fmt.Sprintf("Test User %d", n),
+		Phone:          phone(n),
+		PhoneVerified:  true,
+		Permissions:    user.StringSlice{},
+		IsActive:       true,
+		ApprovalStatus: user.ApprovalStatusApproved,
+	}
+	apply(&u, overrides)
+	return u
+}
+
+// CreateUser saves a built user
+func CreateUser(t testing.TB, db *gorm.DB, overrides ...func(*user.User)) *user.User {
+	t.Helper()
+	u := BuildUser(overrides...)
+	create(t, db, &u)
+	return &u
+}
+
+// WithPermissions sets a user's permissions
+func WithPermissions(permissions ...string) func(*user.User) {
+	return func(u *user.User) {
+		u.Permissions = user.StringSlice(permissions)
+	}
+}
+
+// BuildAddress returns a complete Dhaka delivery address
+func BuildAddress(overrides ...func(*addressModel.Address)) addressModel.Address {
+	s := func(v string) *string { return &v }
+	a := addressModel.Address{
+		Division:       s("Dhaka"),
+		District:       s("Dhaka"),
+		PoliceStation:  s("Mirpur"),
+		PostOffice:     s("Mirpur"),
+		PostOfficeCode: s("1216"),
+		StreetAddress:  s("House 12, Road 5"),
+	}
+	apply(&a, overrides)
+	return a
+}
+
+// BuildBooking returns an initial customer booking with a delivery address. UserID
+// is left zero unless overridden.
+func BuildBooking(overrides ...func(*bookingModel.Booking)) bookingModel.Booking {
+	n := next()
+	address := BuildAddress()
+	b := bookingModel.Booking{
+		AppOrOrderID:       fmt.Sprintf("OID%010d", n),
+		Name:               fmt.Sprintf("Applicant %d", n),
+		FatherName:         "Father",
+		MotherName:         "Mother",
+		Phone:              phone(n),
+		Address:            *address.StreetAddress,
+		DeliveryAddress:    &address,
+		DeliveryBranchCode: address.PostOfficeCode,
+		Status:             bookingModel.BookingStatusInitial,
+		BookingType:        bookingModel.BookingTypeCustomer,
+		BookingDate:        time.Now(),
+		CreatedBy:          "factory",
+	}
+	apply(&b, overrides)
+	return b
+}
+
+// CreateBooking saves a built booking, creating its applicant when UserID is zero
+func CreateBooking(t testing.TB, db *gorm.DB, overrides ...func(*bookingModel.Booking)) *bookingModel.Booking {
+	t.Helper()
+	b := BuildBooking(overrides...)
+	if b.UserID == 0 {
+		b.User = *CreateUser(t, db)
+		b.UserID = b.User.ID
+	}
+	create(t, db, &b)
+	return &b
+}
+
+// BuildOTP returns an unused OTP for a booking, expiring in five minutes. BookingID
+// is left zero unless overridden.
+func BuildOTP(overrides ...func(*otp.OTP)) otp.OTP {
+	n := next()
+	o := otp.OTP{
+		Phone:      phone(n),
+		OTPCode:    fmt.Sprintf("%06d", 100000+n%900000),
+		Purpose:    otp.OTPPurposeDeliveryConfirmPhone,
+		MaxRetries: 3,
+		ExpiresAt:  time.Now().Add(5 * time.Minute),
+	}
+	apply(&o, overrides)
+	return o
+}
+
+// CreateOTP saves a built OTP, creating its booking when BookingID is zero
+func CreateOTP(t testing.TB, db *gorm.DB, overrides ...func(*otp.OTP)) *otp.OTP {
+	t.Helper()
+	o := BuildOTP(overrides...)
+	if o.BookingID == 0 {
+		b := CreateBooking(t, db)
+		o.BookingID = b.ID
+	}
+	// Saved without the association so the booking is not written twice
+	if err := db.Omit("Booking").Create(&o).Error; err != nil {
+		t.Fatalf("factory: failed to create %T: %v", &o, err)
+	}
+	return &o
+}
+
+// BuildBag returns an open, empty bag
+func BuildBag(overrides ...func(*bookingModel.Bag)) bookingModel.Bag {
+	n := next()
+	bag := bookingModel.Bag{
+		BagID:          fmt.Sprintf("BAG%08d", n),
+		BagType:        "passport",
+		DestOfficeCode: "1216",
+		Status:         bookingModel.BagStatusOpen,
+	}
+	apply(&bag, overrides)
+	return bag
+}
+
+// CreateBag saves a built bag, creating the operator who opened it when
+// CreatedByID is zero
+func CreateBag(t testing.TB, db *gorm.DB, overrides ...func(*bookingModel.Bag)) *bookingModel.Bag {
+	t.Helper()
+	bag := BuildBag(overrides...)
+	if bag.CreatedByID == 0 {
+		bag.CreatedByID = CreateUser(t, db).ID
+	}
+	create(t, db, &bag)
+	return &bag
+}
+
+// BuildParcelBooking returns a pending parcel booking. UserID is left zero unless
+// overridden.
+func BuildParcelBooking(overrides ...func(*parcel_booking.ParcelBooking)) parcel_booking.ParcelBooking {
+	n := next()
+	p := parcel_booking.ParcelBooking{
+		RpoAddress:    "Regional Passport Office, Agargaon, Dhaka",
+		Phone:         phone(n),
+		PostCode:      "1207",
+		RpoName:       "Agargaon",
+		Barcode:       fmt.Sprintf("EP%09dBD", n),
+		ServiceType:   "parcel",
+		Price:         100,
+		TotalCharge:   100,
+		CurrentStatus: string(parcel_booking.ParcelBookingStatusPending),
+	}
+	apply(&p, overrides)
+	return p
+}
+
+// CreateParcelBooking saves a built parcel booking, creating its operator when
+// UserID is zero
+func CreateParcelBooking(t testing.TB, db *gorm.DB, overrides ...func(*parcel_booking.ParcelBooking)) *parcel_booking.ParcelBooking {
+	t.Helper()
+	p := BuildParcelBooking(overrides...)
+	if p.UserID == 0 {
+		p.User = *CreateUser(t, db)
+		p.UserID = p.User.ID
+	}
+	create(t, db, &p)
+	return &p
+}
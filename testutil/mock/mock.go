@@ -0,0 +1,270 @@
+// Package mock provides stand-ins for the OTP service, the DMS client and the delivery
+// booking repository, so handler tests control their answers and can check what the
+// handler asked for. Methods a test did not set up return ErrNotMocked.
+package mock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"passport-booking/httpServices/dms"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/models/otp"
+	"passport-booking/services/clock"
+	"passport-booking/services/delivery_repo"
+	"passport-booking/services/fraud"
+	otpService "passport-booking/services/otp"
+	bookingTypes "passport-booking/types/booking"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotMocked is returned by calls the test did not expect
+var ErrNotMocked = errors.New("mock: call not set up")
+
+// Verification is the answer of a mocked OTP verification
+type Verification struct {
+	OK     bool
+	Record *otp.OTP
+	Err    error
+}
+
+// VerifyCall records one OTP verification
+type VerifyCall struct {
+	Phone     string
+	Code      string
+	Purpose   otp.OTPPurpose
+	BookingID uint
+	Session   string
+	ActorID   uint
+}
+
+// OTPService answers every verification with Verify and records the calls
+type OTPService struct {
+	Verify Verification
+	// Clock is what Now reports; nil means the system clock
+	Clock clock.Clock
+
+	mu    sync.Mutex
+	calls []VerifyCall
+}
+
+var _ otpService.OTPService = (*OTPService)(nil)
+
+// Calls returns the verifications made so far
+func (m *OTPService) Calls() []VerifyCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]VerifyCall(nil), m.calls...)
+}
+
+func (m *OTPService) verify(call VerifyCall) (bool, *otp.OTP, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, call)
+	return m.Verify.OK, m.Verify.Record, m.Verify.Err
+}
+
+func (m *OTPService) VerifyOTP(phone, otpCode string, purpose otp.OTPPurpose) (bool, error) {
+	ok, _, err := m.verify(VerifyCall{Phone: phone, Code: otpCode, Purpose: purpose})
+	return ok, err
+}
+
+func (m *OTPService) VerifyOTPWithDetails(phone, otpCode string, purpose otp.OTPPurpose, bookingID uint) (bool, *otp.OTP, error) {
+	return m.verify(VerifyCall{Phone: phone, Code: otpCode, Purpose: purpose, BookingID: bookingID})
+}
+
+func (m *OTPService) VerifyOTPWithSession(phone, otpCode string, purpose otp.OTPPurpose, bookingID uint, sessionToken string, actorID uint) (bool, *otp.OTP, error) {
+	return m.verify(VerifyCall{Phone: phone, Code: otpCode, Purpose: purpose, BookingID: bookingID, Session: sessionToken, ActorID: actorID})
+}
+
+func (m *OTPService) SendOTPWithBookingID(phone string, purpose otp.OTPPurpose, bookingID *uint) (*otp.OTP, error) {
+	return nil, ErrNotMocked
+}
+
+func (m *OTPService) ResendOTPWithBookingID(phone string, purpose otp.OTPPurpose, bookingID *uint) (*otp.OTP, error) {
+	return nil, ErrNotMocked
+}
+
+func (m *OTPService) GetOTPStatus(phone string, purpose otp.OTPPurpose, bookingID uint) (*otp.OTP, error) {
+	return nil, ErrNotMocked
+}
+
+func (m *OTPService) GetOTPRetryInfo(phone string, purpose otp.OTPPurpose, bookingID uint) (*otpService.OTPRetryInfo, error) {
+	return nil, ErrNotMocked
+}
+
+func (m *OTPService) GetLatestOTPsForBookings(bookingIDs []uint, purpose otp.OTPPurpose) (map[uint]*otp.OTP, error) {
+	return nil, ErrNotMocked
+}
+
+func (m *OTPService) Now() time.Time {
+	return clock.OrSystem(m.Clock).Now()
+}
+
+func (m *OTPService) BindSession(otpRecord *otp.OTP, bookingID, issuedToID uint) (string, error) {
+	return "", ErrNotMocked
+}
+
+// DMS answers DeliverArticle with Deliver and DeliverErr and records the requests
+type DMS struct {
+	Deliver    *dms.Response
+	DeliverErr error
+
+	mu        sync.Mutex
+	delivered []dms.DeliverArticleRequest
+}
+
+var _ dms.Client = (*DMS)(nil)
+
+// Delivered returns the deliveries reported so far
+func (m *DMS) Delivered() []dms.DeliverArticleRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]dms.DeliverArticleRequest(nil), m.delivered...)
+}
+
+func (m *DMS) DeliverArticle(authHeader string, req dms.DeliverArticleRequest) (*dms.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delivered = append(m.delivered, req)
+	return m.Deliver, m.DeliverErr
+}
+
+func (m *DMS) ReceiveBagItem(authHeader string, req dms.ReceiveBagItemRequest) (*dms.Response, error) {
+	return nil, ErrNotMocked
+}
+
+func (m *DMS) RerouteArticle(authHeader string, req dms.RerouteArticleRequest) (*dms.Response, error) {
+	return nil, ErrNotMocked
+}
+
+func (m *DMS) GetBarcode(authHeader string, req dms.GetBarcodeRequest) (string, error) {
+	return "", ErrNotMocked
+}
+
+func (m *DMS) BookParcelArticle(authHeader string, req dms.ParcelBookArticleRequest) (*dms.Response, error) {
+	return nil, ErrNotMocked
+}
+
+// Bookings keeps bookings in memory for the delivery handlers. LockErr and HoldErr
+// answer every lock and hold check and Fraud every fraud evaluation.
+type Bookings struct {
+	LockErr error
+	HoldErr error
+	Fraud   fraud.Decision
+
+	mu        sync.Mutex
+	bookings  []bookingModel.Booking
+	confirmed []uint
+	delivered []uint
+}
+
+var _ delivery_repo.Repository = (*Bookings)(nil)
+
+// Add stores a copy of b, numbering it when its ID is zero, and returns its ID
+func (m *Bookings) Add(b bookingModel.Booking) uint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b.ID == 0 {
+		b.ID = uint(len(m.bookings) + 1)
+	}
+	m.bookings = append(m.bookings, b)
+	return b.ID
+}
+
+// Get returns the stored copy of the booking with the given ID
+func (m *Bookings) Get(id uint) (bookingModel.Booking, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, b := range m.bookings {
+		if b.ID == id {
+			return b, true
+		}
+	}
+	return bookingModel.Booking{}, false
+}
+
+// Confirmed returns the IDs of the bookings whose delivery phone was confirmed
+func (m *Bookings) Confirmed() []uint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]uint(nil), m.confirmed...)
+}
+
+// Delivered returns the IDs of the bookings marked delivered
+func (m *Bookings) Delivered() []uint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]uint(nil), m.delivered...)
+}
+
+// save replaces the stored copy of b
+func (m *Bookings) save(b *bookingModel.Booking) {
+	for i := range m.bookings {
+		if m.bookings[i].ID == b.ID {
+			m.bookings[i] = *b
+			return
+		}
+	}
+	m.bookings = append(m.bookings, *b)
+}
+
+// FindBooking matches identifier against the ID, barcode and AppOrOrderID of the
+// stored bookings
+func (m *Bookings) FindBooking(identifier string, idType bookingTypes.IdentifierType, dest *bookingModel.Booking) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, b := range m.bookings {
+		byID := strconv.FormatUint(uint64(b.ID), 10) == identifier
+		byBarcode := b.Barcode != nil && *b.Barcode == identifier
+		byOrder := b.AppOrOrderID == identifier
+		var match bool
+		switch idType {
+		case bookingTypes.IdentifierTypeID:
+			match = byID
+		case bookingTypes.IdentifierTypeBarcode:
+			match = byBarcode
+		case bookingTypes.IdentifierTypeAppOrOrderID:
+			match = byOrder
+		default:
+			match = byID || byBarcode || byOrder
+		}
+		if match {
+			*dest = b
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (m *Bookings) CheckLock(bookingID, actorID uint) error {
+	return m.LockErr
+}
+
+func (m *Bookings) CheckHold(bookingID uint) error {
+	return m.HoldErr
+}
+
+func (m *Bookings) EvaluateFraud(signals fraud.Signals) (fraud.Decision, error) {
+	return m.Fraud, nil
+}
+
+func (m *Bookings) ConfirmDeliveryPhone(ctx context.Context, booking *bookingModel.Booking, eventType string, postmanID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.save(booking)
+	m.confirmed = append(m.confirmed, booking.ID)
+	return nil
+}
+
+func (m *Bookings) MarkDelivered(ctx context.Context, booking *bookingModel.Booking, postmanID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.save(booking)
+	m.delivered = append(m.delivered, booking.ID)
+	return nil
+}
@@ -0,0 +1,59 @@
+// Package testdb connects tests to a throwaway Postgres database and isolates
+// each test in a transaction that is rolled back when it finishes.
+package testdb
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"passport-booking/database"
+	"passport-booking/services/query_count"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	db      *gorm.DB
+	openErr error
+	once    sync.Once
+)
+
+// Open connects to TEST_DATABASE_DSN, migrates the schema and registers the query
+// counter once per test binary. Tests are skipped when the variable is unset, so go test ./... still
+// passes without a database.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	once.Do(func() {
+		db, openErr = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if openErr == nil {
+			openErr = database.Migrate(db)
+		}
+		if openErr == nil {
+			openErr = query_count.RegisterCallbacks(db)
+		}
+	})
+	if openErr != nil {
+		t.Fatalf("testdb: %v", openErr)
+	}
+	return db
+}
+
+// Tx opens the test database and starts a transaction that is rolled back when
+// the test ends. Code under test that starts its own transaction gets a
+// savepoint inside it.
+func Tx(t testing.TB) *gorm.DB {
+	t.Helper()
+	tx := Open(t).Begin()
+	if tx.Error != nil {
+		t.Fatalf("testdb: failed to begin transaction: %v", tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
@@ -1,5 +1,13 @@
 package bag
 
+import (
+	"fmt"
+	"passport-booking/httpServices/dms"
+	bookingModel "passport-booking/models/booking"
+	"strings"
+	"time"
+)
+
 type BranchMappingRequest struct {
 	Username     string `json:"username"`
 	BranchCode   string `json:"branch_code"`
@@ -20,52 +28,17 @@ type AddItemRequest struct {
 	ItemID  string `json:"item_id"`
 	BagType string `json:"bag_type"`
 	Index   int    `json:"index"`
+	// Item weight counted against the bag limit; DMS booking weight when omitted
+	WeightGrams int `json:"weight_grams,omitempty"`
+	// Branch making up the bag; its cutoff decides the expected dispatch date
+	BranchCode string `json:"branch_code,omitempty"`
 }
 
-type BookingRequest struct {
-	FromNumber      string  `json:"form_number"`
-	AdPodID         string  `json:"ad_pod_id"`
-	ArticleDesc     string  `json:"article_desc"`
-	ArticlePrice    int     `json:"article_price"`
-	Barcode         string  `json:"barcode"`
-	CityPostStatus  string  `json:"city_post_status"`
-	DeliveryBranch  string  `json:"delivery_branch"`
-	EmtsBranchCode  string  `json:"emts_branch_code"`
-	Height          int     `json:"height"`
-	HndDevice       string  `json:"hnd_device"`
-	ImagePod        string  `json:"image_pod"`
-	ImageSrc        string  `json:"image_src"`
-	InsurancePrice  string  `json:"insurance_price"`
-	IsBulkMail      string  `json:"is_bulk_mail"`
-	IsCharge        string  `json:"is_charge"`
-	IsCityPost      string  `json:"is_city_post"`
-	IsInternational bool    `json:"is_international"`
-	IsStation       string  `json:"is_station"`
-	Length          int     `json:"length"`
-	ServiceName     string  `json:"service_name"`
-	SetAd           string  `json:"set_ad"`
-	VasType         string  `json:"vas_type"`
-	VpAmount        string  `json:"vp_amount"`
-	VpService       string  `json:"vp_service"`
-	Weight          int     `json:"weight"`
-	Width           int     `json:"width"`
-	Receiver        Address `json:"receiver"`
-	Sender          Address `json:"sender"`
-}
+// BookingRequest is the DMS book article payload
+type BookingRequest = dms.BookArticleRequest
 
-type Address struct {
-	AddressType   string `json:"address_type"`
-	Country       string `json:"country"`
-	District      string `json:"district"`
-	Division      string `json:"division"`
-	PhoneNumber   string `json:"phone_number"`
-	PoliceStation string `json:"police_station"`
-	PostOffice    string `json:"post_office"`
-	StreetAddress string `json:"street_address"`
-	UserUUID      string `json:"user_uuid"`
-	Username      string `json:"username"`
-	Zone          string `json:"zone"`
-}
+// Address is the sender/receiver block of a DMS book article payload
+type Address = dms.Address
 
 type Booking struct {
 	ID           uint   `gorm:"primaryKey"`
@@ -75,12 +48,94 @@ type Booking struct {
 
 type CloseBagRequest struct {
 	BagID string `json:"bag_id"`
+	// Branch closing the bag; when it has a schedule, closing after its cutoff needs Override
+	BranchCode     string `json:"branch_code,omitempty"`
+	Override       bool   `json:"override,omitempty"`
+	OverrideReason string `json:"override_reason,omitempty"`
 }
 
 type ReceiveBagRequest struct {
+	BagID           string `json:"bag_id"`
+	RecvInstruction string `json:"recv_instruction"`
+	// Code of a registered transport line that serves the bag's destination
+	LineID       string `json:"line_id"`
+	ReceiveItems string `json:"receive_items"`
+	// Destination office; taken from the bagged bookings' delivery branch when empty
+	DestOfficeCode string `json:"dest_office_code,omitempty"`
+}
+
+// TransportLineRequest creates or updates a transport line
+type TransportLineRequest struct {
+	Code          string                       `json:"code"`
+	Name          string                       `json:"name"`
+	OriginOffice  string                       `json:"origin_office"`
+	DailyCapacity int                          `json:"daily_capacity"`
+	Active        *bool                        `json:"active,omitempty"`
+	Offices       []string                     `json:"offices"`
+	Schedules     []TransportLineScheduleInput `json:"schedules"`
+}
+
+// TransportLineScheduleInput is one weekly departure of a line
+type TransportLineScheduleInput struct {
+	Weekday   int    `json:"weekday"`
+	DepartsAt string `json:"departs_at"`
+}
 
-	BagID           string   `json:"bag_id"`
-	RecvInstruction string   `json:"recv_instruction"`
-	LineID          string   `json:"line_id"`
-	ReceiveItems    string   `json:"receive_items"`
-}
\ No newline at end of file
+// Validate validates the TransportLineRequest fields, trimming and de-duplicating offices
+func (r *TransportLineRequest) Validate() error {
+	r.Code = strings.ToUpper(strings.TrimSpace(r.Code))
+	r.Name = strings.TrimSpace(r.Name)
+	r.OriginOffice = strings.TrimSpace(r.OriginOffice)
+	if r.Code == "" || len(r.Code) > 50 {
+		return fmt.Errorf("code is required and must be at most 50 characters")
+	}
+	if r.Name == "" || len(r.Name) > 150 {
+		return fmt.Errorf("name is required and must be at most 150 characters")
+	}
+	if r.OriginOffice == "" {
+		return fmt.Errorf("origin_office is required")
+	}
+	if r.DailyCapacity < 0 {
+		return fmt.Errorf("daily_capacity cannot be negative")
+	}
+
+	seen := make(map[string]bool, len(r.Offices))
+	offices := make([]string, 0, len(r.Offices))
+	for _, o := range r.Offices {
+		o = strings.TrimSpace(o)
+		if o == "" || seen[o] {
+			continue
+		}
+		seen[o] = true
+		offices = append(offices, o)
+	}
+	if len(offices) == 0 {
+		return fmt.Errorf("at least one destination office is required")
+	}
+	r.Offices = offices
+
+	for i, s := range r.Schedules {
+		if s.Weekday < 0 || s.Weekday > 6 {
+			return fmt.Errorf("schedules[%d].weekday must be from 0 (Sunday) to 6", i)
+		}
+		t, err := time.Parse("15:04", strings.TrimSpace(s.DepartsAt))
+		if err != nil {
+			return fmt.Errorf("schedules[%d].departs_at must be an HH:MM time", i)
+		}
+		r.Schedules[i].DepartsAt = t.Format("15:04")
+	}
+	return nil
+}
+
+// TransportLineListRequest filters the transport line list
+type TransportLineListRequest struct {
+	Office string `query:"office"`
+	Active *bool  `query:"active"`
+}
+
+// TransportLineResponse is a line with its load for today and next departure
+type TransportLineResponse struct {
+	bookingModel.TransportLine
+	LoadedToday   int64      `json:"loaded_today"`
+	NextDeparture *time.Time `json:"next_departure,omitempty"`
+}
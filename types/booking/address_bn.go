@@ -0,0 +1,77 @@
+package booking
+
+import (
+	"fmt"
+	"strings"
+
+	addressModel "passport-booking/models/address"
+	"passport-booking/utils"
+)
+
+// AddressBn carries the optional Bangla rendering of a delivery address. Fields
+// left empty are transliterated from the English ones when the address is saved.
+type AddressBn struct {
+	DivisionBn      string `json:"division_bn,omitempty" validate:"max=255"`
+	DistrictBn      string `json:"district_bn,omitempty" validate:"max=255"`
+	PoliceStationBn string `json:"police_station_bn,omitempty" validate:"max=255"`
+	PostOfficeBn    string `json:"post_office_bn,omitempty" validate:"max=255"`
+	StreetAddressBn string `json:"street_address_bn,omitempty" validate:"max=255"`
+}
+
+// Validate trims the Bangla fields and checks their length
+func (a *AddressBn) Validate() error {
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"division_bn", &a.DivisionBn},
+		{"district_bn", &a.DistrictBn},
+		{"police_station_bn", &a.PoliceStationBn},
+		{"post_office_bn", &a.PostOfficeBn},
+		{"street_address_bn", &a.StreetAddressBn},
+	}
+	for _, f := range fields {
+		*f.value = strings.TrimSpace(*f.value)
+		if len(*f.value) > 255 {
+			return fmt.Errorf("%s must be at most 255 bytes", f.name)
+		}
+	}
+	return nil
+}
+
+// ApplyTo sets the given Bangla fields on addr and clears the others, so a stale
+// rendering of a changed field is not kept
+func (a AddressBn) ApplyTo(addr *addressModel.Address) {
+	set := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+	addr.DivisionBn = set(a.DivisionBn)
+	addr.DistrictBn = set(a.DistrictBn)
+	addr.PoliceStationBn = set(a.PoliceStationBn)
+	addr.PostOfficeBn = set(a.PostOfficeBn)
+	addr.StreetAddressBn = set(a.StreetAddressBn)
+}
+
+// DomesticCountry is the destination of every passport booking
+const DomesticCountry = "Bangladesh"
+
+// AddressLabel is a delivery address rendered for printing on a label
+type AddressLabel struct {
+	BookingID uint     `json:"booking_id"`
+	Barcode   *string  `json:"barcode,omitempty"`
+	Name      string   `json:"name"`
+	Phone     string   `json:"phone"`
+	Script    string   `json:"script"`
+	Lines     []string `json:"lines"`
+}
+
+// MaskPII masks the phone number and the street line of the label
+func (l *AddressLabel) MaskPII() {
+	l.Phone = utils.MaskPhone(l.Phone)
+	if len(l.Lines) > 0 {
+		l.Lines[0] = utils.MaskAddress(l.Lines[0])
+	}
+}
@@ -0,0 +1,84 @@
+package booking
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AddressChangeRequest asks to deliver a booking to another address
+type AddressChangeRequest struct {
+	BookingID          uint   `json:"booking_id" validate:"required"`
+	DeliveryBranchCode string `json:"delivery_branch_code" validate:"required,min=1,max=100"`
+	Division           string `json:"division" validate:"required,min=1,max=255"`
+	District           string `json:"district" validate:"required,min=1,max=255"`
+	PoliceStation      string `json:"police_station" validate:"required,min=1,max=255"`
+	PostOffice         string `json:"post_office" validate:"required,min=1,max=255"`
+	StreetAddress      string `json:"street_address" validate:"required,min=1,max=255"`
+	AddressBn
+	Reason string `json:"reason,omitempty"`
+}
+
+// Validate validates the AddressChangeRequest fields
+func (r *AddressChangeRequest) Validate() error {
+	if r.BookingID == 0 {
+		return fmt.Errorf("booking_id is required")
+	}
+	fields := []struct {
+		name  string
+		value *string
+		max   int
+	}{
+		{"delivery_branch_code", &r.DeliveryBranchCode, 100},
+		{"division", &r.Division, 255},
+		{"district", &r.District, 255},
+		{"police_station", &r.PoliceStation, 255},
+		{"post_office", &r.PostOffice, 255},
+		{"street_address", &r.StreetAddress, 255},
+	}
+	for _, f := range fields {
+		*f.value = strings.TrimSpace(*f.value)
+		if *f.value == "" {
+			return fmt.Errorf("%s is required", f.name)
+		}
+		if len(*f.value) > f.max {
+			return fmt.Errorf("%s must be at most %d characters", f.name, f.max)
+		}
+	}
+	r.Reason = strings.TrimSpace(r.Reason)
+	return r.AddressBn.Validate()
+}
+
+// OperatorAddressChangeRequest is an address change made by an operator on the
+// applicant's behalf; a reason is mandatory since no OTP is asked
+type OperatorAddressChangeRequest struct {
+	AddressChangeRequest
+}
+
+// Validate validates the OperatorAddressChangeRequest fields
+func (r *OperatorAddressChangeRequest) Validate() error {
+	if err := r.AddressChangeRequest.Validate(); err != nil {
+		return err
+	}
+	if r.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	return nil
+}
+
+// VerifyAddressChangeRequest confirms an applicant's address change with the OTP sent
+// to the delivery phone
+type VerifyAddressChangeRequest struct {
+	ChangeID uint   `json:"change_id" validate:"required"`
+	OTPCode  string `json:"otp_code" validate:"required"`
+}
+
+// Validate validates the VerifyAddressChangeRequest fields
+func (r *VerifyAddressChangeRequest) Validate() error {
+	if r.ChangeID == 0 {
+		return fmt.Errorf("change_id is required")
+	}
+	if r.OTPCode == "" {
+		return fmt.Errorf("otp_code is required")
+	}
+	return nil
+}
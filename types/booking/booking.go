@@ -2,6 +2,7 @@ package booking
 
 import (
 	"fmt"
+	"passport-booking/services/localtime"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +16,9 @@ type BookingCreateRequest struct {
 	PoliceStation      string `json:"police_station" validate:"required,min=1,max=255"`
 	PostOffice         string `json:"post_office" validate:"required,min=1,max=255"`
 	StreetAddress      string `json:"street_address" validate:"required,min=1,max=255"`
+	AddressBn
+	// Passport system that issued the application/order ID; detected from the ID when empty
+	SourceSystem string `json:"source_system,omitempty"`
 }
 
 // BookingCreateRequest represents the request payload for creating a booking
@@ -27,10 +31,11 @@ type BookingStoreUpdateRequest struct {
 	PoliceStation      string `json:"police_station" validate:"required,min=1,max=255"`
 	PostOffice         string `json:"post_office" validate:"required,min=1,max=255"`
 	StreetAddress      string `json:"street_address" validate:"required,min=1,max=255"`
+	AddressBn
 }
 
 // use first step validation
-func (b BookingCreateRequest) Validate() error {
+func (b *BookingCreateRequest) Validate() error {
 	if b.RequestID == "" {
 		return fmt.Errorf("RequestID is required")
 	}
@@ -52,11 +57,11 @@ func (b BookingCreateRequest) Validate() error {
 	if b.StreetAddress == "" {
 		return fmt.Errorf("streetAddress is required")
 	}
-	return nil
+	return b.AddressBn.Validate()
 }
 
 // use second step validation
-func (b BookingStoreUpdateRequest) Validate() error {
+func (b *BookingStoreUpdateRequest) Validate() error {
 	if b.DeliveryBranchCode == "" {
 		return fmt.Errorf("deliveryBranchCode is required")
 	}
@@ -75,16 +80,17 @@ func (b BookingStoreUpdateRequest) Validate() error {
 	if b.StreetAddress == "" {
 		return fmt.Errorf("streetAddress is required")
 	}
-	return nil
+	return b.AddressBn.Validate()
 }
 
 // BookingIndexRequest represents the request for listing bookings with pagination and filters
 type BookingIndexRequest struct {
-	Page     int    `json:"page" query:"page"`
-	PerPage  int    `json:"per_page" query:"per_page"`
-	FromDate string `json:"from_date" query:"from_date"` // Format: "26:8:2026 11:39:23" or "2026-08-26 11:39:23"
-	ToDate   string `json:"to_date" query:"to_date"`     // Format: "26:8:2026 11:39:23" or "2026-08-26 11:39:23"
-	Status   string `json:"status" query:"status"`       // booking status filter
+	Page      int    `json:"page" query:"page"`
+	PerPage   int    `json:"per_page" query:"per_page"`
+	FromDate  string `json:"from_date" query:"from_date"` // Format: "26:8:2026 11:39:23" or "2026-08-26 11:39:23"
+	ToDate    string `json:"to_date" query:"to_date"`     // Format: "26:8:2026 11:39:23" or "2026-08-26 11:39:23"
+	Status    string `json:"status" query:"status"`       // booking status filter
+	Reference string `json:"reference" query:"reference"` // e.g. PBK-2026-000123
 }
 
 // BookingIndexResponse represents the response for listing bookings with pagination
@@ -188,22 +194,26 @@ func parseDateTime(dateStr string) (time.Time, error) {
 				if err1 == nil && err2 == nil && err3 == nil {
 					// Convert to standard format and parse
 					standardFormat := fmt.Sprintf("%04d-%02d-%02d %s", year, month, day, timePart)
-					return time.Parse("2006-01-02 15:04:05", standardFormat)
+					return localtime.ParseDate("2006-01-02 15:04:05", standardFormat)
 				}
 			}
 		}
 	}
 
-	// Try standard formats
+	// RFC3339 carries its own offset
+	if t, err := time.Parse(time.RFC3339, dateStr); err == nil {
+		return t, nil
+	}
+
+	// Try standard formats, read as local time
 	formats := []string{
 		"2006-01-02 15:04:05",
 		"2006-01-02T15:04:05",
-		"2006-01-02T15:04:05Z",
 		"2006-01-02",
 	}
 
 	for _, format := range formats {
-		if t, err := time.Parse(format, dateStr); err == nil {
+		if t, err := localtime.ParseDate(format, dateStr); err == nil {
 			return t, nil
 		}
 	}
@@ -220,3 +230,19 @@ func (b *BookingIndexRequest) GetOffset() int {
 func (b *BookingIndexRequest) GetLimit() int {
 	return b.PerPage
 }
+
+// AppIDReviewRequest confirms a flagged application/order ID after an operator checked it
+type AppIDReviewRequest struct {
+	SourceSystem string `json:"source_system,omitempty"` // set when the operator identified the issuing system
+	Note         string `json:"note,omitempty"`
+}
+
+// Validate validates the AppIDReviewRequest fields
+func (r *AppIDReviewRequest) Validate() error {
+	r.SourceSystem = strings.TrimSpace(r.SourceSystem)
+	r.Note = strings.TrimSpace(r.Note)
+	if len(r.Note) > 1000 {
+		return fmt.Errorf("note must be at most 1000 characters")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package booking
+
+import (
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	"time"
+)
+
+// DeliveryPhoneConsentRequest records the applicant's consent for their delivery phone
+type DeliveryPhoneConsentRequest struct {
+	BookingID   uint                        `json:"booking_id" validate:"required"`
+	Consent     bool                        `json:"consent"`
+	Channel     bookingModel.ConsentChannel `json:"channel" validate:"required"`
+	TextVersion string                      `json:"text_version" validate:"required"`
+}
+
+// Validate validates the DeliveryPhoneConsentRequest fields
+func (r *DeliveryPhoneConsentRequest) Validate() error {
+	if r.BookingID == 0 {
+		return fmt.Errorf("booking_id is required")
+	}
+	if !r.Consent {
+		return fmt.Errorf("consent must be explicitly given")
+	}
+	switch r.Channel {
+	case bookingModel.ConsentChannelWeb, bookingModel.ConsentChannelMobileApp, bookingModel.ConsentChannelAgentAssisted:
+	case "":
+		return fmt.Errorf("channel is required")
+	default:
+		return fmt.Errorf("channel must be one of 'web', 'mobile_app' or 'agent_assisted'")
+	}
+	if r.TextVersion == "" {
+		return fmt.Errorf("text_version is required")
+	}
+	return nil
+}
+
+// PhoneConsentResponse is the consent shown on the booking detail
+type PhoneConsentResponse struct {
+	Phone       string                      `json:"phone"`
+	Channel     bookingModel.ConsentChannel `json:"channel"`
+	TextVersion string                      `json:"text_version"`
+	ConsentedAt time.Time                   `json:"consented_at"`
+}
+
+// NewPhoneConsentResponse maps a consent record to its response DTO
+func NewPhoneConsentResponse(c *bookingModel.PhoneConsent) *PhoneConsentResponse {
+	if c == nil {
+		return nil
+	}
+	return &PhoneConsentResponse{
+		Phone:       c.Phone,
+		Channel:     c.Channel,
+		TextVersion: c.TextVersion,
+		ConsentedAt: c.ConsentedAt,
+	}
+}
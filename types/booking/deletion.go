@@ -0,0 +1,33 @@
+package booking
+
+import (
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	"strings"
+	"time"
+)
+
+// BookingDeletionRequest gives the reason for deleting or restoring a booking
+type BookingDeletionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Validate validates the BookingDeletionRequest fields
+func (r *BookingDeletionRequest) Validate() error {
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if len(r.Reason) > 1000 {
+		return fmt.Errorf("reason must be at most 1000 characters")
+	}
+	return nil
+}
+
+// BookingDeletionResponse is a booking after it was deleted or restored
+type BookingDeletionResponse struct {
+	Booking  BookingResponse               `json:"booking"`
+	Deletion *bookingModel.BookingDeletion `json:"deletion,omitempty"`
+	// Until when a deleted booking can be restored
+	RestorableUntil *time.Time `json:"restorable_until,omitempty"`
+}
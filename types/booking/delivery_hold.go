@@ -0,0 +1,41 @@
+package booking
+
+import (
+	"fmt"
+	"passport-booking/services/localtime"
+	"strings"
+	"time"
+)
+
+// DeliveryHoldRequest asks to keep the item at the branch for a date range.
+// Both dates are inclusive.
+type DeliveryHoldRequest struct {
+	BookingID uint   `json:"booking_id" validate:"required"`
+	StartDate string `json:"start_date" validate:"required"` // YYYY-MM-DD
+	EndDate   string `json:"end_date" validate:"required"`   // YYYY-MM-DD
+	Reason    string `json:"reason,omitempty"`
+
+	StartsAt time.Time `json:"-"`
+	EndsAt   time.Time `json:"-"`
+}
+
+// Validate validates the DeliveryHoldRequest fields and resolves the hold window
+func (r *DeliveryHoldRequest) Validate() error {
+	if r.BookingID == 0 {
+		return fmt.Errorf("booking_id is required")
+	}
+	start, err := localtime.ParseDate("2006-01-02", r.StartDate)
+	if err != nil {
+		return fmt.Errorf("invalid start_date format. Use 'YYYY-MM-DD'")
+	}
+	end, err := localtime.ParseDate("2006-01-02", r.EndDate)
+	if err != nil {
+		return fmt.Errorf("invalid end_date format. Use 'YYYY-MM-DD'")
+	}
+	if end.Before(start) {
+		return fmt.Errorf("start_date cannot be after end_date")
+	}
+	r.StartsAt, r.EndsAt = start, end.AddDate(0, 0, 1)
+	r.Reason = strings.TrimSpace(r.Reason)
+	return nil
+}
@@ -0,0 +1,36 @@
+package booking
+
+import "fmt"
+
+// IdentifierType tags which booking column an identifier refers to
+type IdentifierType string
+
+const (
+	IdentifierTypeAuto         IdentifierType = "auto"
+	IdentifierTypeID           IdentifierType = "id"
+	IdentifierTypeBarcode      IdentifierType = "barcode"
+	IdentifierTypeAppOrOrderID IdentifierType = "app_or_order_id"
+	IdentifierTypeReference    IdentifierType = "reference"
+)
+
+// IsValid checks if the identifier type is one of the supported values
+func (t IdentifierType) IsValid() bool {
+	switch t {
+	case IdentifierTypeAuto, IdentifierTypeID, IdentifierTypeBarcode, IdentifierTypeAppOrOrderID, IdentifierTypeReference:
+		return true
+	}
+	return false
+}
+
+// NormalizeIdentifierType applies the endpoint default when no type was sent
+// and rejects unknown values
+func NormalizeIdentifierType(t *IdentifierType, defaultType IdentifierType) error {
+	if *t == "" {
+		*t = defaultType
+		return nil
+	}
+	if !t.IsValid() {
+		return fmt.Errorf("identifier_type must be one of 'auto', 'id', 'barcode', 'app_or_order_id' or 'reference'")
+	}
+	return nil
+}
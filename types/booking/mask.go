@@ -0,0 +1,48 @@
+package booking
+
+import (
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/utils"
+)
+
+// MaskPII partially masks the phone numbers and addresses of the response for roles
+// that do not need them in full
+func (r *BookingResponse) MaskPII() {
+	r.Phone = utils.MaskPhone(r.Phone)
+	r.DeliveryPhone = utils.MaskPhonePtr(r.DeliveryPhone)
+	r.EmergencyContactPhone = utils.MaskPhonePtr(r.EmergencyContactPhone)
+	r.Address = utils.MaskAddress(r.Address)
+	if r.User != nil {
+		r.User.Phone = utils.MaskPhone(r.User.Phone)
+	}
+	if r.DeliveryAddress != nil {
+		r.DeliveryAddress.StreetAddress = utils.MaskAddressPtr(r.DeliveryAddress.StreetAddress)
+		r.DeliveryAddress.StreetAddressBn = utils.MaskAddressPtr(r.DeliveryAddress.StreetAddressBn)
+	}
+}
+
+// NewBookingResponsesMasked maps bookings to response DTOs, masking PII when maskPII
+// is set
+func NewBookingResponsesMasked(bookings []bookingModel.Booking, maskPII bool) []BookingResponse {
+	resp := NewBookingResponses(bookings)
+	if maskPII {
+		for i := range resp {
+			resp[i].MaskPII()
+		}
+	}
+	return resp
+}
+
+// MaskBookingPII masks the phone numbers and addresses of a booking model returned
+// as is by older list endpoints. Only use it on a copy that is not saved afterwards.
+func MaskBookingPII(b *bookingModel.Booking) {
+	b.Phone = utils.MaskPhone(b.Phone)
+	b.DeliveryPhone = utils.MaskPhonePtr(b.DeliveryPhone)
+	b.EmergencyContactPhone = utils.MaskPhonePtr(b.EmergencyContactPhone)
+	b.Address = utils.MaskAddress(b.Address)
+	b.User.Phone = utils.MaskPhone(b.User.Phone)
+	if b.DeliveryAddress != nil {
+		b.DeliveryAddress.StreetAddress = utils.MaskAddressPtr(b.DeliveryAddress.StreetAddress)
+		b.DeliveryAddress.StreetAddressBn = utils.MaskAddressPtr(b.DeliveryAddress.StreetAddressBn)
+	}
+}
@@ -0,0 +1,67 @@
+package booking
+
+import (
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/booking_quota"
+	"passport-booking/services/localtime"
+	"strings"
+	"time"
+)
+
+const maxQuotaOverrideDays = 90
+
+// SetBookingQuotaRequest replaces the quota of a user or branch. A null daily_limit
+// falls back to the default; an override_limit needs override_until and a reason.
+type SetBookingQuotaRequest struct {
+	DailyLimit    *int   `json:"daily_limit"`
+	OverrideLimit *int   `json:"override_limit"`
+	OverrideUntil string `json:"override_until,omitempty"` // YYYY-MM-DD, inclusive
+	Reason        string `json:"reason,omitempty"`
+
+	OverrideEndsAt *time.Time `json:"-"`
+}
+
+// Validate validates the SetBookingQuotaRequest fields and resolves the override window
+func (r *SetBookingQuotaRequest) Validate(now time.Time) error {
+	if r.DailyLimit != nil && *r.DailyLimit < 0 {
+		return fmt.Errorf("daily_limit must not be negative; use 0 for unlimited")
+	}
+	r.Reason = strings.TrimSpace(r.Reason)
+	r.OverrideUntil = strings.TrimSpace(r.OverrideUntil)
+	if r.OverrideLimit == nil {
+		if r.OverrideUntil != "" {
+			return fmt.Errorf("override_until needs an override_limit")
+		}
+		return nil
+	}
+
+	if *r.OverrideLimit < 0 {
+		return fmt.Errorf("override_limit must not be negative; use 0 for unlimited")
+	}
+	if r.Reason == "" {
+		return fmt.Errorf("reason is required with an override")
+	}
+	until, err := localtime.ParseDate("2006-01-02", r.OverrideUntil)
+	if err != nil {
+		return fmt.Errorf("invalid override_until format. Use 'YYYY-MM-DD'")
+	}
+	today := localtime.StartOfDay(now)
+	if until.Before(today) {
+		return fmt.Errorf("override_until cannot be in the past")
+	}
+	if until.After(today.AddDate(0, 0, maxQuotaOverrideDays)) {
+		return fmt.Errorf("an override can last at most %d days", maxQuotaOverrideDays)
+	}
+	ends := until.AddDate(0, 0, 1)
+	r.OverrideEndsAt = &ends
+	return nil
+}
+
+// BookingQuotaResponse is a quota with its usage today
+type BookingQuotaResponse struct {
+	Quota        *bookingModel.BookingQuota `json:"quota"` // nil when the defaults apply
+	DefaultLimit int                        `json:"default_limit"`
+	Usage        *booking_quota.Usage       `json:"usage"`
+	Enforced     bool                       `json:"enforced"`
+}
@@ -0,0 +1,181 @@
+package booking
+
+import (
+	bookingModel "passport-booking/models/booking"
+	"time"
+)
+
+// BookingResponse is the public shape of a booking. Encrypted OTP columns and
+// other internal fields are deliberately left out.
+type BookingResponse struct {
+	ID                             uint                       `json:"id"`
+	UserID                         uint                       `json:"user_id"`
+	User                           *BookingUserResponse       `json:"user,omitempty"`
+	AppOrOrderID                   string                     `json:"app_or_order_id"`
+	Reference                      *string                    `json:"reference,omitempty"`
+	CurrentBagID                   *string                    `json:"current_bag_id,omitempty"`
+	ExpectedDispatchDate           *time.Time                 `json:"expected_dispatch_date,omitempty"`
+	BaggedAfterCutoff              bool                       `json:"bagged_after_cutoff"`
+	Barcode                        *string                    `json:"barcode,omitempty"`
+	Name                           string                     `json:"name"`
+	FatherName                     string                     `json:"father_name"`
+	MotherName                     string                     `json:"mother_name"`
+	Phone                          string                     `json:"phone"`
+	DeliveryPhone                  *string                    `json:"delivery_phone"`
+	DeliveryPhoneAppliedVerified   bool                       `json:"delivery_phone_applied_verified"`
+	DeliveryPhoneConfirmedVerified bool                       `json:"delivery_phone_confirmed_verified"`
+	DeliveryApplicationIDVerified  bool                       `json:"delivery_application_id_verified"`
+	Address                        string                     `json:"address"`
+	EmergencyContactName           *string                    `json:"emergency_contact_name,omitempty"`
+	EmergencyContactPhone          *string                    `json:"emergency_contact_phone,omitempty"`
+	DeliveryBranchCode             *string                    `json:"delivery_branch_code,omitempty"`
+	DeliveryAddressID              *uint                      `json:"delivery_address_id,omitempty"`
+	DeliveryAddress                *BookingAddressResponse    `json:"delivery_address,omitempty"`
+	Status                         bookingModel.BookingStatus `json:"status"`
+	BookingType                    bookingModel.BookingType   `json:"booking_type"`
+	BookingDate                    time.Time                  `json:"booking_date"`
+	CreatedBy                      string                     `json:"created_by"`
+	CreatedAt                      time.Time                  `json:"created_at"`
+	UpdatedBy                      string                     `json:"updated_by,omitempty"`
+	UpdatedAt                      time.Time                  `json:"updated_at"`
+	DeletedAt                      *time.Time                 `json:"deleted_at,omitempty"`
+	UploadPhoto                    *string                    `json:"upload_photo"`
+	RecipientIDVerified            bool                       `json:"recipient_id_verified"`
+	RecipientNIDLast4              *string                    `json:"recipient_nid_last4,omitempty"`
+	RecipientIDVerifiedAt          *time.Time                 `json:"recipient_id_verified_at,omitempty"`
+	ReturnReason                   *string                    `json:"return_reason,omitempty"`
+	SourceSystem                   *string                    `json:"source_system,omitempty"`
+	AppIDNeedsReview               bool                       `json:"app_id_needs_review"`
+	AppIDReviewReason              *string                    `json:"app_id_review_reason,omitempty"`
+	PhoneConsent                   *PhoneConsentResponse      `json:"phone_consent,omitempty"`
+}
+
+// BookingUserResponse is the subset of user data exposed alongside a booking
+type BookingUserResponse struct {
+	ID        uint   `json:"id"`
+	Uuid      string `json:"uuid"`
+	Username  string `json:"username"`
+	LegalName string `json:"legal_name"`
+	Phone     string `json:"phone"`
+}
+
+// BookingAddressResponse is the delivery address exposed alongside a booking
+type BookingAddressResponse struct {
+	ID             uint    `json:"id"`
+	Division       *string `json:"division,omitempty"`
+	District       *string `json:"district,omitempty"`
+	PoliceStation  *string `json:"police_station,omitempty"`
+	PostOffice     *string `json:"post_office,omitempty"`
+	PostOfficeCode *string `json:"post_office_code,omitempty"`
+	StreetAddress  *string `json:"street_address,omitempty"`
+
+	DivisionBn      *string `json:"division_bn,omitempty"`
+	DistrictBn      *string `json:"district_bn,omitempty"`
+	PoliceStationBn *string `json:"police_station_bn,omitempty"`
+	PostOfficeBn    *string `json:"post_office_bn,omitempty"`
+	StreetAddressBn *string `json:"street_address_bn,omitempty"`
+}
+
+// NewBookingResponse maps a booking model to its response DTO
+func NewBookingResponse(b *bookingModel.Booking) BookingResponse {
+	resp := BookingResponse{
+		ID:                             b.ID,
+		UserID:                         b.UserID,
+		AppOrOrderID:                   b.AppOrOrderID,
+		Reference:                      b.Reference,
+		CurrentBagID:                   b.CurrentBagID,
+		Barcode:                        b.Barcode,
+		Name:                           b.Name,
+		FatherName:                     b.FatherName,
+		MotherName:                     b.MotherName,
+		Phone:                          b.Phone,
+		DeliveryPhone:                  b.DeliveryPhone,
+		DeliveryPhoneAppliedVerified:   b.DeliveryPhoneAppliedVerified,
+		DeliveryPhoneConfirmedVerified: b.DeliveryPhoneConfirmedVerified,
+		DeliveryApplicationIDVerified:  b.DeliveryApplicationIDVerified,
+		Address:                        b.Address,
+		EmergencyContactName:           b.EmergencyContactName,
+		EmergencyContactPhone:          b.EmergencyContactPhone,
+		DeliveryBranchCode:             b.DeliveryBranchCode,
+		DeliveryAddressID:              b.DeliveryAddressID,
+		Status:                         b.Status,
+		BookingType:                    b.BookingType,
+		BookingDate:                    b.BookingDate,
+		CreatedBy:                      b.CreatedBy,
+		CreatedAt:                      b.CreatedAt,
+		UpdatedBy:                      b.UpdatedBy,
+		UpdatedAt:                      b.UpdatedAt,
+		DeletedAt:                      b.DeletedAt,
+		UploadPhoto:                    b.UploadPhoto,
+		RecipientIDVerified:            b.RecipientIDVerified,
+		RecipientNIDLast4:              b.RecipientNIDLast4,
+		RecipientIDVerifiedAt:          b.RecipientIDVerifiedAt,
+		ReturnReason:                   b.ReturnReason,
+		ExpectedDispatchDate:           b.ExpectedDispatchDate,
+		BaggedAfterCutoff:              b.BaggedAfterCutoff,
+		SourceSystem:                   b.SourceSystem,
+		AppIDNeedsReview:               b.AppIDNeedsReview,
+		AppIDReviewReason:              b.AppIDReviewReason,
+	}
+
+	// Only include the user when the relation was preloaded
+	if b.User.ID != 0 {
+		resp.User = &BookingUserResponse{
+			ID:        b.User.ID,
+			Uuid:      b.User.Uuid,
+			Username:  b.User.Username,
+			LegalName: b.User.LegalName,
+			Phone:     b.User.Phone,
+		}
+	}
+
+	if b.DeliveryAddress != nil {
+		resp.DeliveryAddress = &BookingAddressResponse{
+			ID:             b.DeliveryAddress.ID,
+			Division:       b.DeliveryAddress.Division,
+			District:       b.DeliveryAddress.District,
+			PoliceStation:  b.DeliveryAddress.PoliceStation,
+			PostOffice:     b.DeliveryAddress.PostOffice,
+			PostOfficeCode: b.DeliveryAddress.PostOfficeCode,
+			StreetAddress:  b.DeliveryAddress.StreetAddress,
+
+			DivisionBn:      b.DeliveryAddress.DivisionBn,
+			DistrictBn:      b.DeliveryAddress.DistrictBn,
+			PoliceStationBn: b.DeliveryAddress.PoliceStationBn,
+			PostOfficeBn:    b.DeliveryAddress.PostOfficeBn,
+			StreetAddressBn: b.DeliveryAddress.StreetAddressBn,
+		}
+	}
+
+	return resp
+}
+
+// NewBookingResponses maps a list of booking models to response DTOs
+func NewBookingResponses(bookings []bookingModel.Booking) []BookingResponse {
+	resp := make([]BookingResponse, 0, len(bookings))
+	for i := range bookings {
+		resp = append(resp, NewBookingResponse(&bookings[i]))
+	}
+	return resp
+}
+
+// TrackingResponse is the tracking view of a booking looked up by barcode
+type TrackingResponse struct {
+	Barcode      string                     `json:"barcode"`
+	AppOrOrderID string                     `json:"app_or_order_id"`
+	Status       bookingModel.BookingStatus `json:"status"`
+	UpdatedAt    time.Time                  `json:"updated_at"`
+	// Expected dispatch from the booking branch, pushed to the next working day after cutoff
+	ExpectedDispatchDate *time.Time              `json:"expected_dispatch_date,omitempty"`
+	BaggedAfterCutoff    bool                    `json:"bagged_after_cutoff"`
+	Events               []TrackingEventResponse `json:"events"`
+	// Signed link to the delivery photo, only for the booking's owner
+	DeliveryPhotoURL          *string    `json:"delivery_photo_url,omitempty"`
+	DeliveryPhotoURLExpiresAt *time.Time `json:"delivery_photo_url_expires_at,omitempty"`
+}
+
+// TrackingEventResponse is a single status change in the tracking history
+type TrackingEventResponse struct {
+	Status    bookingModel.BookingStatus `json:"status"`
+	CreatedAt time.Time                  `json:"created_at"`
+}
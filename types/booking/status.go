@@ -0,0 +1,34 @@
+package booking
+
+// StatusWorkflow lists the statuses of one kind of booking and the moves between them
+type StatusWorkflow struct {
+	Statuses    []string            `json:"statuses"`
+	Initial     string              `json:"initial"`
+	Final       []string            `json:"final"`
+	Transitions map[string][]string `json:"transitions"`
+}
+
+// NewStatusWorkflow builds the workflow of an enum from its registry, in the order
+// statuses lists them
+func NewStatusWorkflow[S ~string](statuses []S, transitions map[S][]S) StatusWorkflow {
+	w := StatusWorkflow{
+		Statuses:    make([]string, 0, len(statuses)),
+		Final:       []string{},
+		Transitions: make(map[string][]string, len(statuses)),
+	}
+	for _, s := range statuses {
+		w.Statuses = append(w.Statuses, string(s))
+		next := make([]string, 0, len(transitions[s]))
+		for _, n := range transitions[s] {
+			next = append(next, string(n))
+		}
+		w.Transitions[string(s)] = next
+		if len(next) == 0 {
+			w.Final = append(w.Final, string(s))
+		}
+	}
+	if len(statuses) > 0 {
+		w.Initial = string(statuses[0])
+	}
+	return w
+}
@@ -0,0 +1,196 @@
+package branch
+
+import (
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StockAuditRequest is the list of barcodes scanned on the branch shelf during a stock-take
+type StockAuditRequest struct {
+	Barcodes []string `json:"barcodes"`
+	Note     string   `json:"note,omitempty"`
+}
+
+// Validate validates the StockAuditRequest fields, normalizing and de-duplicating the barcodes
+func (r *StockAuditRequest) Validate() error {
+	if len(r.Barcodes) > 5000 {
+		return fmt.Errorf("a stock audit can hold at most 5000 barcodes")
+	}
+	r.Note = strings.TrimSpace(r.Note)
+	if len(r.Note) > 1000 {
+		return fmt.Errorf("note must be at most 1000 characters")
+	}
+
+	seen := make(map[string]bool, len(r.Barcodes))
+	barcodes := make([]string, 0, len(r.Barcodes))
+	for _, b := range r.Barcodes {
+		b = utils.NormalizeBarcode(b)
+		if err := utils.ValidateBarcode(b); err != nil {
+			return fmt.Errorf("%s: %w", b, err)
+		}
+		if seen[b] {
+			continue
+		}
+		seen[b] = true
+		barcodes = append(barcodes, b)
+	}
+	r.Barcodes = barcodes
+	return nil
+}
+
+// StockAuditResponse is the reconciliation of a stock audit
+type StockAuditResponse struct {
+	Audit      bookingModel.StockAudit      `json:"audit"`
+	Missing    []bookingModel.StockIncident `json:"missing"`
+	Unexpected []bookingModel.StockIncident `json:"unexpected"`
+	Recovered  []string                     `json:"recovered"`
+}
+
+// IncidentListRequest filters the stock incidents of a branch
+type IncidentListRequest struct {
+	Status bookingModel.StockIncidentStatus `query:"status"`
+	Kind   bookingModel.StockIncidentKind   `query:"kind"`
+}
+
+// Validate validates the IncidentListRequest fields
+func (r *IncidentListRequest) Validate() error {
+	switch r.Status {
+	case "", bookingModel.StockIncidentStatusOpen, bookingModel.StockIncidentStatusResolved:
+	default:
+		return fmt.Errorf("status must be one of 'open' or 'resolved'")
+	}
+	switch r.Kind {
+	case "", bookingModel.StockIncidentMissing, bookingModel.StockIncidentUnexpected:
+	default:
+		return fmt.Errorf("kind must be one of 'missing' or 'unexpected'")
+	}
+	return nil
+}
+
+// ResolveIncidentRequest closes a stock incident with the outcome of the investigation
+type ResolveIncidentRequest struct {
+	Note string `json:"note"`
+}
+
+// Validate validates the ResolveIncidentRequest fields
+func (r *ResolveIncidentRequest) Validate() error {
+	r.Note = strings.TrimSpace(r.Note)
+	if r.Note == "" {
+		return fmt.Errorf("note is required")
+	}
+	if len(r.Note) > 1000 {
+		return fmt.Errorf("note must be at most 1000 characters")
+	}
+	return nil
+}
+
+// ScheduleRequest sets a branch's operating hours and same-day dispatch cutoff
+type ScheduleRequest struct {
+	OpensAt     string `json:"opens_at"`
+	ClosesAt    string `json:"closes_at"`
+	CutoffAt    string `json:"cutoff_at"`
+	WorkingDays string `json:"working_days"`
+}
+
+// Validate validates the ScheduleRequest fields, defaulting the working days to Sunday–Thursday
+func (r *ScheduleRequest) Validate() error {
+	clock := func(name, value string) (time.Time, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(value))
+		if err != nil {
+			return t, fmt.Errorf("%s must be an HH:MM time", name)
+		}
+		return t, nil
+	}
+	opens, err := clock("opens_at", r.OpensAt)
+	if err != nil {
+		return err
+	}
+	closes, err := clock("closes_at", r.ClosesAt)
+	if err != nil {
+		return err
+	}
+	cutoff, err := clock("cutoff_at", r.CutoffAt)
+	if err != nil {
+		return err
+	}
+	if !opens.Before(closes) {
+		return fmt.Errorf("opens_at must be before closes_at")
+	}
+	if cutoff.Before(opens) || cutoff.After(closes) {
+		return fmt.Errorf("cutoff_at must fall between opens_at and closes_at")
+	}
+	r.OpensAt, r.ClosesAt, r.CutoffAt = opens.Format("15:04"), closes.Format("15:04"), cutoff.Format("15:04")
+
+	if strings.TrimSpace(r.WorkingDays) == "" {
+		r.WorkingDays = "0,1,2,3,4"
+	}
+	seen := make(map[int]bool)
+	days := make([]string, 0, 7)
+	for _, part := range strings.Split(r.WorkingDays, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 || n > 6 {
+			return fmt.Errorf("working_days must be comma-separated numbers from 0 (Sunday) to 6")
+		}
+		if !seen[n] {
+			seen[n] = true
+			days = append(days, strconv.Itoa(n))
+		}
+	}
+	r.WorkingDays = strings.Join(days, ",")
+	return nil
+}
+
+// RefillPoolRequest fetches barcodes from DMS into a branch pool. Without a count the
+// pool is topped up to its configured size.
+type RefillPoolRequest struct {
+	Count int `json:"count,omitempty"`
+}
+
+// Validate validates the RefillPoolRequest fields
+func (r *RefillPoolRequest) Validate() error {
+	if r.Count < 0 || r.Count > 1000 {
+		return fmt.Errorf("count must be between 1 and 1000")
+	}
+	return nil
+}
+
+// OrgUnitRequest creates or updates a unit of the branch hierarchy. Code and level
+// are only read on create.
+type OrgUnitRequest struct {
+	Code     string                `json:"code"`
+	Name     string                `json:"name"`
+	Level    bookingModel.OrgLevel `json:"level"`
+	ParentID *uint                 `json:"parent_id"`
+}
+
+// Validate validates the OrgUnitRequest fields; create also requires code and level
+func (r *OrgUnitRequest) Validate(create bool) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(r.Name) > 255 {
+		return fmt.Errorf("name must be at most 255 characters")
+	}
+	if !create {
+		return nil
+	}
+
+	r.Code = strings.TrimSpace(r.Code)
+	if r.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	if len(r.Code) > 100 {
+		return fmt.Errorf("code must be at most 100 characters")
+	}
+	switch r.Level {
+	case bookingModel.OrgLevelRegion, bookingModel.OrgLevelDistrict, bookingModel.OrgLevelBranch:
+	default:
+		return fmt.Errorf("level must be one of 'region', 'district' or 'branch'")
+	}
+	return nil
+}
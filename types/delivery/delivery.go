@@ -3,11 +3,15 @@ package delivery
 import (
 	"fmt"
 	"passport-booking/models/otp"
+	bookingTypes "passport-booking/types/booking"
+	"passport-booking/utils"
+	"strings"
 )
 
 type DeliveryPhoneSendOtpRequest struct {
-	BookingID string         `json:"booking_id" validate:"required"`
-	Purpose   otp.OTPPurpose `json:"purpose" validate:"required"`
+	BookingID      string                      `json:"booking_id" validate:"required"`
+	Purpose        otp.OTPPurpose              `json:"purpose" validate:"required"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
 }
 
 // Validate validates the DeliveryPhoneSendOtpRequest fields
@@ -17,6 +21,10 @@ func (r *DeliveryPhoneSendOtpRequest) Validate() error {
 		return fmt.Errorf("booking_id is required")
 	}
 
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+
 	if r.Purpose == "" {
 		return fmt.Errorf("purpose is required")
 	}
@@ -29,9 +37,11 @@ func (r *DeliveryPhoneSendOtpRequest) Validate() error {
 }
 
 type VerifyDeliveryPhoneRequest struct {
-	BookingID string         `json:"booking_id" validate:"required"`
-	OTPCode   string         `json:"otp_code" validate:"required"`
-	Purpose   otp.OTPPurpose `json:"purpose" validate:"required"`
+	BookingID      string                      `json:"booking_id" validate:"required"`
+	OTPCode        string                      `json:"otp_code" validate:"required"`
+	Purpose        otp.OTPPurpose              `json:"purpose" validate:"required"`
+	OTPSession     string                      `json:"otp_session" validate:"required"` // returned by send-otp
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
 }
 
 // Validate validates the VerifyDeliveryPhoneRequest fields
@@ -40,10 +50,18 @@ func (r *VerifyDeliveryPhoneRequest) Validate() error {
 		return fmt.Errorf("booking_id is required")
 	}
 
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+
 	if r.OTPCode == "" {
 		return fmt.Errorf("otp_code is required")
 	}
 
+	if r.OTPSession == "" {
+		return fmt.Errorf("otp_session is required")
+	}
+
 	if r.Purpose == "" {
 		return fmt.Errorf("purpose is required")
 	}
@@ -57,8 +75,9 @@ func (r *VerifyDeliveryPhoneRequest) Validate() error {
 }
 
 type VerifyApplicationIDRequest struct {
-	BookingID     string `json:"booking_id" validate:"required"`
-	ApplicationID string `json:"application_id" validate:"required"`
+	BookingID      string                      `json:"booking_id" validate:"required"`
+	ApplicationID  string                      `json:"application_id" validate:"required"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
 }
 
 // Validate validates the VerifyApplicationIDRequest fields
@@ -67,6 +86,10 @@ func (r *VerifyApplicationIDRequest) Validate() error {
 		return fmt.Errorf("booking_id is required")
 	}
 
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+
 	if r.ApplicationID == "" {
 		return fmt.Errorf("application_id is required")
 	}
@@ -83,11 +106,19 @@ func (r *ReceiveItemRequest) Validate() error {
 	if r.ItemID == "" {
 		return fmt.Errorf("booking_id is required")
 	}
+	r.ItemID = utils.NormalizeBarcode(r.ItemID)
+	if err := utils.ValidateBarcode(r.ItemID); err != nil {
+		return err
+	}
 	return nil
 }
 
 type ItemDeliveryRequest struct {
-	BookingID string `json:"booking_id" validate:"required"`
+	BookingID      string                      `json:"booking_id" validate:"required"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
+	// Optional device location at hand-over
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
 }
 
 // Validate validates the ItemDeliveryRequest fields
@@ -95,5 +126,53 @@ func (r *ItemDeliveryRequest) Validate() error {
 	if r.BookingID == "" {
 		return fmt.Errorf("booking_id is required")
 	}
+
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+
+	if r.IdentifierType == bookingTypes.IdentifierTypeBarcode {
+		r.BookingID = utils.NormalizeBarcode(r.BookingID)
+		if err := utils.ValidateBarcode(r.BookingID); err != nil {
+			return err
+		}
+	}
+
+	if (r.Latitude == nil) != (r.Longitude == nil) {
+		return fmt.Errorf("latitude and longitude must be provided together")
+	}
+	if r.Latitude != nil && (*r.Latitude < -90 || *r.Latitude > 90 || *r.Longitude < -180 || *r.Longitude > 180) {
+		return fmt.Errorf("latitude or longitude is out of range")
+	}
+	return nil
+}
+
+type MarkReturnRequest struct {
+	BookingID      string                      `json:"booking_id" validate:"required"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
+	Reason         string                      `json:"reason" validate:"required"`
+}
+
+// Validate validates the MarkReturnRequest fields
+func (r *MarkReturnRequest) Validate() error {
+	if r.BookingID == "" {
+		return fmt.Errorf("booking_id is required")
+	}
+
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+
+	if r.IdentifierType == bookingTypes.IdentifierTypeBarcode {
+		r.BookingID = utils.NormalizeBarcode(r.BookingID)
+		if err := utils.ValidateBarcode(r.BookingID); err != nil {
+			return err
+		}
+	}
+
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
 	return nil
 }
@@ -0,0 +1,89 @@
+package delivery
+
+import (
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	bookingTypes "passport-booking/types/booking"
+	"passport-booking/utils"
+	"time"
+)
+
+type CreateDeliveryGroupRequest struct {
+	BookingIDs     []string                    `json:"booking_ids" validate:"required"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
+}
+
+// Validate validates the CreateDeliveryGroupRequest fields
+func (r *CreateDeliveryGroupRequest) Validate() error {
+	if len(r.BookingIDs) < 2 {
+		return fmt.Errorf("booking_ids must list at least two bookings")
+	}
+
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+	if r.IdentifierType == bookingTypes.IdentifierTypeAuto {
+		return fmt.Errorf("identifier_type 'auto' is not supported for delivery groups")
+	}
+
+	for i, id := range r.BookingIDs {
+		if id == "" {
+			return fmt.Errorf("booking_ids must not contain empty values")
+		}
+		if r.IdentifierType == bookingTypes.IdentifierTypeBarcode {
+			r.BookingIDs[i] = utils.NormalizeBarcode(id)
+			if err := utils.ValidateBarcode(r.BookingIDs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type DeliverGroupRequest struct {
+	// Optional device location at hand-over
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// Validate validates the DeliverGroupRequest fields
+func (r *DeliverGroupRequest) Validate() error {
+	if (r.Latitude == nil) != (r.Longitude == nil) {
+		return fmt.Errorf("latitude and longitude must be provided together")
+	}
+	if r.Latitude != nil && (*r.Latitude < -90 || *r.Latitude > 90 || *r.Longitude < -180 || *r.Longitude > 180) {
+		return fmt.Errorf("latitude or longitude is out of range")
+	}
+	return nil
+}
+
+type DeliveryGroupResponse struct {
+	ID            uint                             `json:"id"`
+	PostmanID     uint                             `json:"postman_id"`
+	DeliveryPhone string                           `json:"delivery_phone"`
+	Status        bookingModel.DeliveryGroupStatus `json:"status"`
+	DeliveredAt   *time.Time                       `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time                        `json:"created_at"`
+	Bookings      []bookingTypes.BookingResponse   `json:"bookings"`
+}
+
+// NewDeliveryGroupResponse converts a group with its preloaded bookings
+func NewDeliveryGroupResponse(g *bookingModel.DeliveryGroup) DeliveryGroupResponse {
+	return DeliveryGroupResponse{
+		ID:            g.ID,
+		PostmanID:     g.PostmanID,
+		DeliveryPhone: g.DeliveryPhone,
+		Status:        g.Status,
+		DeliveredAt:   g.DeliveredAt,
+		CreatedAt:     g.CreatedAt,
+		Bookings:      bookingTypes.NewBookingResponses(g.Bookings),
+	}
+}
+
+// GroupDeliveryResult reports the outcome for one booking of a group delivery
+type GroupDeliveryResult struct {
+	BookingID uint   `json:"booking_id"`
+	Barcode   string `json:"barcode"`
+	Delivered bool   `json:"delivered"`
+	Error     string `json:"error,omitempty"`
+}
@@ -0,0 +1,161 @@
+package delivery
+
+import (
+	"fmt"
+	bookingTypes "passport-booking/types/booking"
+	"strings"
+	"time"
+)
+
+// Offline action types a postman can sync
+const (
+	OfflineActionReceive   = "receive"
+	OfflineActionVerifyOTP = "verify_otp"
+	OfflineActionPhoto     = "photo"
+	// A code issued in advance for a no-network address, in place of the live OTP
+	OfflineActionDeliveryCode = "delivery_code"
+)
+
+// Per-action sync results
+const (
+	OfflineResultApplied  = "applied"  // the action changed the booking
+	OfflineResultConflict = "conflict" // the booking moved on while the postman was offline; server state kept
+	OfflineResultRejected = "rejected" // the action is invalid and will never apply
+	OfflineResultRetry    = "retry"    // a dependency failed; keep the action and sync it again
+)
+
+// OfflineBundle is the signed "bundle" form field of an offline sync request
+type OfflineBundle struct {
+	BundleID string          `json:"bundle_id"`
+	Actions  []OfflineAction `json:"actions"`
+}
+
+// OfflineAction is one action performed without connectivity. Photos travel as
+// multipart files named by PhotoFile and are checked against PhotoSHA256, which
+// the bundle signature covers.
+type OfflineAction struct {
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	Barcode      string    `json:"barcode"`
+	PerformedAt  time.Time `json:"performed_at"`
+	OTPCode      string    `json:"otp_code,omitempty"`
+	DeliveryCode string    `json:"delivery_code,omitempty"`
+	PhotoFile    string    `json:"photo_file,omitempty"`
+	PhotoSHA256  string    `json:"photo_sha256,omitempty"`
+}
+
+// OfflineActionResult reports what the server did with one action
+type OfflineActionResult struct {
+	ActionID  string `json:"action_id"`
+	Type      string `json:"type"`
+	Barcode   string `json:"barcode"`
+	BookingID *uint  `json:"booking_id,omitempty"`
+	Result    string `json:"result"`
+	Message   string `json:"message,omitempty"`
+	// Set when the action was synced before and this is the stored result
+	Replayed bool `json:"replayed,omitempty"`
+}
+
+// Validate validates the OfflineBundle fields
+func (b *OfflineBundle) Validate(maxActions int) error {
+	b.BundleID = strings.TrimSpace(b.BundleID)
+	if b.BundleID == "" || len(b.BundleID) > 64 {
+		return fmt.Errorf("bundle_id is required and must be at most 64 characters")
+	}
+	if len(b.Actions) == 0 {
+		return fmt.Errorf("actions must not be empty")
+	}
+	if len(b.Actions) > maxActions {
+		return fmt.Errorf("a bundle may carry at most %d actions", maxActions)
+	}
+
+	seen := make(map[string]bool, len(b.Actions))
+	for i := range b.Actions {
+		a := &b.Actions[i]
+		a.ID = strings.TrimSpace(a.ID)
+		a.Barcode = strings.TrimSpace(a.Barcode)
+		if a.ID == "" || len(a.ID) > 64 {
+			return fmt.Errorf("actions[%d].id is required and must be at most 64 characters", i)
+		}
+		if seen[a.ID] {
+			return fmt.Errorf("actions[%d].id %q is repeated", i, a.ID)
+		}
+		seen[a.ID] = true
+		if a.Barcode == "" {
+			return fmt.Errorf("actions[%d].barcode is required", i)
+		}
+		if a.PerformedAt.IsZero() {
+			return fmt.Errorf("actions[%d].performed_at is required", i)
+		}
+		switch a.Type {
+		case OfflineActionReceive:
+		case OfflineActionVerifyOTP:
+			if strings.TrimSpace(a.OTPCode) == "" {
+				return fmt.Errorf("actions[%d].otp_code is required", i)
+			}
+		case OfflineActionPhoto:
+			if a.PhotoFile == "" || a.PhotoSHA256 == "" {
+				return fmt.Errorf("actions[%d].photo_file and photo_sha256 are required", i)
+			}
+		case OfflineActionDeliveryCode:
+			if strings.TrimSpace(a.DeliveryCode) == "" {
+				return fmt.Errorf("actions[%d].delivery_code is required", i)
+			}
+		default:
+			return fmt.Errorf("actions[%d].type must be one of '%s', '%s', '%s' or '%s'", i,
+				OfflineActionReceive, OfflineActionVerifyOTP, OfflineActionPhoto, OfflineActionDeliveryCode)
+		}
+	}
+	return nil
+}
+
+// Channels an offline delivery code can be issued through
+const (
+	OfflineCodeChannelSMS   = "sms"
+	OfflineCodeChannelPrint = "print"
+)
+
+// NoNetworkRequest flags or clears a booking's delivery address as without coverage
+type NoNetworkRequest struct {
+	BookingID      string                      `json:"booking_id" validate:"required"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
+	NoNetwork      *bool                       `json:"no_network"`
+}
+
+// Validate validates the NoNetworkRequest fields
+func (r *NoNetworkRequest) Validate() error {
+	if r.BookingID == "" {
+		return fmt.Errorf("booking_id is required")
+	}
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+	if r.NoNetwork == nil {
+		return fmt.Errorf("no_network is required")
+	}
+	return nil
+}
+
+// IssueOfflineCodeRequest asks for an offline delivery code for a booking
+type IssueOfflineCodeRequest struct {
+	BookingID      string                      `json:"booking_id" validate:"required"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
+	Channel        string                      `json:"channel"`
+}
+
+// Validate validates the IssueOfflineCodeRequest fields
+func (r *IssueOfflineCodeRequest) Validate() error {
+	if r.BookingID == "" {
+		return fmt.Errorf("booking_id is required")
+	}
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+	if r.Channel == "" {
+		r.Channel = OfflineCodeChannelSMS
+	}
+	if r.Channel != OfflineCodeChannelSMS && r.Channel != OfflineCodeChannelPrint {
+		return fmt.Errorf("channel must be either '%s' or '%s'", OfflineCodeChannelSMS, OfflineCodeChannelPrint)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package device
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegisterDeviceRequest registers the push token of the mobile app
+type RegisterDeviceRequest struct {
+	Token      string `json:"token"`
+	Platform   string `json:"platform"`
+	AppVersion string `json:"app_version,omitempty"`
+}
+
+func (r *RegisterDeviceRequest) Validate() error {
+	r.Token = strings.TrimSpace(r.Token)
+	if r.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	if len(r.Token) > 512 {
+		return fmt.Errorf("token must not exceed 512 characters")
+	}
+	r.Platform = strings.ToLower(strings.TrimSpace(r.Platform))
+	if r.Platform != "android" && r.Platform != "ios" {
+		return fmt.Errorf("platform must be one of: android, ios")
+	}
+	r.AppVersion = strings.TrimSpace(r.AppVersion)
+	if len(r.AppVersion) > 50 {
+		return fmt.Errorf("app_version must not exceed 50 characters")
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package evidence
+
+import (
+	"fmt"
+	"strings"
+
+	bookingTypes "passport-booking/types/booking"
+)
+
+// CreateEvidenceRequest opens a dual-authorized request to reveal OTP evidence
+type CreateEvidenceRequest struct {
+	BookingID      string                      `json:"booking_id" validate:"required"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
+	Reason         string                      `json:"reason" validate:"required"`
+}
+
+// Validate validates the CreateEvidenceRequest fields
+func (r *CreateEvidenceRequest) Validate() error {
+	r.BookingID = strings.TrimSpace(r.BookingID)
+	r.Reason = strings.TrimSpace(r.Reason)
+
+	if r.BookingID == "" {
+		return fmt.Errorf("booking_id is required")
+	}
+
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+
+	if len(r.Reason) < 10 {
+		return fmt.Errorf("reason must be at least 10 characters")
+	}
+
+	return nil
+}
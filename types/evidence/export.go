@@ -0,0 +1,67 @@
+package evidence
+
+import (
+	"fmt"
+	"passport-booking/services/localtime"
+	"strings"
+	"time"
+
+	bookingTypes "passport-booking/types/booking"
+)
+
+const (
+	exportDateLayout = "2006-01-02"
+	maxExportDays    = 31
+)
+
+// CreateExportRequest asks for an encrypted evidence archive of one booking or of the
+// bookings delivered between from_date and to_date
+type CreateExportRequest struct {
+	BookingID      string                      `json:"booking_id,omitempty"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
+	FromDate       string                      `json:"from_date,omitempty"` // YYYY-MM-DD
+	ToDate         string                      `json:"to_date,omitempty"`   // YYYY-MM-DD inclusive
+	Reason         string                      `json:"reason" validate:"required"`
+
+	From time.Time `json:"-"`
+	To   time.Time `json:"-"` // exclusive upper bound
+}
+
+// Validate validates the CreateExportRequest fields
+func (r *CreateExportRequest) Validate() error {
+	r.BookingID = strings.TrimSpace(r.BookingID)
+	r.FromDate = strings.TrimSpace(r.FromDate)
+	r.ToDate = strings.TrimSpace(r.ToDate)
+	r.Reason = strings.TrimSpace(r.Reason)
+
+	if len(r.Reason) < 10 {
+		return fmt.Errorf("reason must be at least 10 characters")
+	}
+
+	if r.BookingID != "" {
+		if r.FromDate != "" || r.ToDate != "" {
+			return fmt.Errorf("give either booking_id or from_date and to_date, not both")
+		}
+		return bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode)
+	}
+
+	if r.FromDate == "" || r.ToDate == "" {
+		return fmt.Errorf("booking_id or both from_date and to_date are required")
+	}
+	from, err := localtime.ParseDate(exportDateLayout, r.FromDate)
+	if err != nil {
+		return fmt.Errorf("from_date must be in YYYY-MM-DD format")
+	}
+	to, err := localtime.ParseDate(exportDateLayout, r.ToDate)
+	if err != nil {
+		return fmt.Errorf("to_date must be in YYYY-MM-DD format")
+	}
+	if to.Before(from) {
+		return fmt.Errorf("to_date must not be before from_date")
+	}
+	r.From, r.To = from, to.AddDate(0, 0, 1)
+	if r.To.Sub(r.From) > maxExportDays*24*time.Hour {
+		return fmt.Errorf("date range must not exceed %d days", maxExportDays)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package fraud
+
+import (
+	"encoding/json"
+	"fmt"
+	fraudModel "passport-booking/models/fraud"
+	"strings"
+)
+
+// UpdateRuleRequest changes the configuration of a fraud rule
+type UpdateRuleRequest struct {
+	Enabled *bool                 `json:"enabled"`
+	Action  fraudModel.RuleAction `json:"action"`
+	Params  map[string]float64    `json:"params"`
+}
+
+// Validate validates the UpdateRuleRequest fields
+func (r *UpdateRuleRequest) Validate() error {
+	if r.Enabled == nil && r.Action == "" && r.Params == nil {
+		return fmt.Errorf("at least one of enabled, action or params is required")
+	}
+	if r.Action != "" && r.Action != fraudModel.RuleActionFlag && r.Action != fraudModel.RuleActionBlock {
+		return fmt.Errorf("action must be either 'flag' or 'block'")
+	}
+	for name, value := range r.Params {
+		if value < 0 {
+			return fmt.Errorf("param %s cannot be negative", name)
+		}
+	}
+	return nil
+}
+
+// EncodedParams returns the params as stored on the rule
+func (r *UpdateRuleRequest) EncodedParams() (string, error) {
+	encoded, err := json.Marshal(r.Params)
+	return string(encoded), err
+}
+
+// CaseListRequest holds the query parameters of GET /fraud/cases
+type CaseListRequest struct {
+	Status    fraudModel.CaseStatus `query:"status"`
+	PostmanID uint                  `query:"postman_id"`
+	Page      int                   `query:"page"`
+	PerPage   int                   `query:"per_page"`
+}
+
+// Validate fills defaults for the case list query
+func (r *CaseListRequest) Validate() error {
+	switch r.Status {
+	case "", fraudModel.CaseStatusOpen, fraudModel.CaseStatusReleased, fraudModel.CaseStatusRejected:
+	default:
+		return fmt.Errorf("status must be one of 'open', 'released' or 'rejected'")
+	}
+	if r.Page <= 0 {
+		r.Page = 1
+	}
+	if r.PerPage <= 0 || r.PerPage > 100 {
+		r.PerPage = 20
+	}
+	return nil
+}
+
+// ReviewCaseRequest is a supervisor's decision on a fraud case
+type ReviewCaseRequest struct {
+	Decision fraudModel.CaseStatus `json:"decision"` // released or rejected
+	Note     string                `json:"note"`
+}
+
+// Validate validates the ReviewCaseRequest fields
+func (r *ReviewCaseRequest) Validate() error {
+	if r.Decision != fraudModel.CaseStatusReleased && r.Decision != fraudModel.CaseStatusRejected {
+		return fmt.Errorf("decision must be either 'released' or 'rejected'")
+	}
+	r.Note = strings.TrimSpace(r.Note)
+	if r.Note == "" {
+		return fmt.Errorf("note is required")
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package types
+
+import "strings"
+
+// ImpersonateRequest asks for a short-lived token acting as another user
+type ImpersonateRequest struct {
+	TargetUUID string `json:"target_uuid"`
+	Reason     string `json:"reason"`
+	TTLMinutes int    `json:"ttl_minutes,omitempty"`
+}
+
+// custom error message
+func (r *ImpersonateRequest) Validate() string {
+	r.TargetUUID = strings.TrimSpace(r.TargetUUID)
+	r.Reason = strings.TrimSpace(r.Reason)
+
+	if r.TargetUUID == "" {
+		return "target_uuid is required"
+	}
+	if len(r.Reason) < 10 {
+		return "reason must be at least 10 characters"
+	}
+	if r.TTLMinutes < 0 || r.TTLMinutes > 60 {
+		return "ttl_minutes must be between 1 and 60"
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package integration
+
+import (
+	"fmt"
+	"passport-booking/httpServices/dms"
+	bookingModel "passport-booking/models/booking"
+	"strings"
+	"time"
+)
+
+// DMSCallbackPayload is the article status update DMS posts to the callback endpoint
+type DMSCallbackPayload struct {
+	EventID    string     `json:"event_id"`
+	ArticleID  string     `json:"article_id"` // the booking barcode
+	Status     string     `json:"status"`
+	OfficeCode string     `json:"office_code,omitempty"`
+	OccurredAt *time.Time `json:"occurred_at,omitempty"`
+}
+
+// Validate validates the DMSCallbackPayload fields
+func (p *DMSCallbackPayload) Validate() error {
+	p.ArticleID = strings.TrimSpace(p.ArticleID)
+	p.Status = strings.TrimSpace(p.Status)
+	p.EventID = strings.TrimSpace(p.EventID)
+	if p.ArticleID == "" {
+		return fmt.Errorf("article_id is required")
+	}
+	if p.Status == "" {
+		return fmt.Errorf("status is required")
+	}
+	// Needed to order updates and to tell a repeated status from a redelivery
+	if p.OccurredAt == nil || p.OccurredAt.IsZero() {
+		return fmt.Errorf("occurred_at is required")
+	}
+	if len(p.EventID) > 128 {
+		return fmt.Errorf("event_id must not exceed 128 characters")
+	}
+	return nil
+}
+
+// mappableStatuses are the booking statuses a DMS status can move a booking to
+var mappableStatuses = map[bookingModel.BookingStatus]bool{
+	bookingModel.BookingStatusBooked:                true,
+	bookingModel.BookingItemStatusReceivedByPostman: true,
+	bookingModel.BookingStatusReceivedByPostman:     true,
+	bookingModel.BookingStatusReceivedByPostMaster:  true,
+	bookingModel.BookingStatusReturn:                true,
+	bookingModel.BookingStatusReturnInTransit:       true,
+	bookingModel.BookingStatusReturnedToRPO:         true,
+	bookingModel.BookingStatusDelivered:             true,
+}
+
+// SaveMappingRequest creates or changes the mapping of one DMS status
+type SaveMappingRequest struct {
+	DMSStatus     string                     `json:"dms_status"`
+	BookingStatus bookingModel.BookingStatus `json:"booking_status"`
+	Active        *bool                      `json:"active,omitempty"`
+}
+
+// Validate validates the SaveMappingRequest fields
+func (r *SaveMappingRequest) Validate() error {
+	r.DMSStatus = strings.TrimSpace(r.DMSStatus)
+	if r.DMSStatus == "" {
+		return fmt.Errorf("dms_status is required")
+	}
+	if len(r.DMSStatus) > 100 {
+		return fmt.Errorf("dms_status must not exceed 100 characters")
+	}
+	if !mappableStatuses[r.BookingStatus] {
+		return fmt.Errorf("booking_status %q cannot be the target of a DMS status", r.BookingStatus)
+	}
+	return nil
+}
+
+// SetDMSModeRequest switches intake between auto, degraded and normal operation
+type SetDMSModeRequest struct {
+	Mode string `json:"mode"`
+}
+
+// Validate validates the SetDMSModeRequest fields
+func (r *SetDMSModeRequest) Validate() error {
+	mode, err := dms.ParseMode(r.Mode)
+	if err != nil {
+		return err
+	}
+	r.Mode = string(mode)
+	return nil
+}
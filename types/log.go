@@ -12,5 +12,6 @@ type LogEntry struct {
 	RequestHeaders  string
 	ResponseHeaders string
 	StatusCode      int
+	ImpersonatedBy  *string
 	CreatedAt       time.Time
 }
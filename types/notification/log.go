@@ -0,0 +1,38 @@
+package notification
+
+import (
+	"fmt"
+	notificationModel "passport-booking/models/notification"
+)
+
+// LogListRequest holds the query parameters of GET /notifications/log
+type LogListRequest struct {
+	BookingID uint                             `query:"booking_id"`
+	UserID    uint                             `query:"user_id"`
+	Status    notificationModel.DeliveryStatus `query:"status"`
+	Channel   notificationModel.Channel        `query:"channel"`
+	Kind      string                           `query:"kind"`
+	Page      int                              `query:"page"`
+	PerPage   int                              `query:"per_page"`
+}
+
+// Validate fills defaults for the delivery log query
+func (r *LogListRequest) Validate() error {
+	switch r.Status {
+	case "", notificationModel.DeliveryStatusSent, notificationModel.DeliveryStatusFailed:
+	default:
+		return fmt.Errorf("status must be one of 'sent' or 'failed'")
+	}
+	switch r.Channel {
+	case "", notificationModel.ChannelSMS, notificationModel.ChannelEmail:
+	default:
+		return fmt.Errorf("channel must be one of 'sms' or 'email'")
+	}
+	if r.Page <= 0 {
+		r.Page = 1
+	}
+	if r.PerPage <= 0 || r.PerPage > 100 {
+		r.PerPage = 20
+	}
+	return nil
+}
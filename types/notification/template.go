@@ -0,0 +1,65 @@
+package notification
+
+import (
+	"fmt"
+	preferenceTypes "passport-booking/types/preference"
+	"strings"
+)
+
+// SaveTemplateRequest adds a new version of the wording of a notification kind
+type SaveTemplateRequest struct {
+	Kind     string `json:"kind"`
+	Language string `json:"language"`
+	Subject  string `json:"subject"`
+	Email    string `json:"email"`
+	SMS      string `json:"sms"`
+	Note     string `json:"note,omitempty"`
+	Activate bool   `json:"activate"` // make the new version the one in use right away
+}
+
+// Validate validates the SaveTemplateRequest fields
+func (r *SaveTemplateRequest) Validate() error {
+	r.Kind = strings.TrimSpace(r.Kind)
+	if r.Kind == "" {
+		return fmt.Errorf("kind is required")
+	}
+	r.Language = strings.ToLower(strings.TrimSpace(r.Language))
+	if r.Language != preferenceTypes.LanguageEnglish && r.Language != preferenceTypes.LanguageBangla {
+		return fmt.Errorf("language must be one of: en, bn")
+	}
+	if strings.TrimSpace(r.Subject) == "" || strings.TrimSpace(r.Email) == "" || strings.TrimSpace(r.SMS) == "" {
+		return fmt.Errorf("subject, email and sms are required")
+	}
+	if len(r.Subject) > 255 {
+		return fmt.Errorf("subject must not exceed 255 characters")
+	}
+	return nil
+}
+
+// PreviewTemplateRequest renders unsaved wording with sample data
+type PreviewTemplateRequest struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+	Email   string `json:"email"`
+	SMS     string `json:"sms"`
+}
+
+// Validate validates the PreviewTemplateRequest fields
+func (r *PreviewTemplateRequest) Validate() error {
+	r.Kind = strings.TrimSpace(r.Kind)
+	if r.Kind == "" {
+		return fmt.Errorf("kind is required")
+	}
+	return nil
+}
+
+// TemplateSummary is the wording in use for one kind and language
+type TemplateSummary struct {
+	Kind          string `json:"kind"`
+	Language      string `json:"language"`
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	SMS           string `json:"sms"`
+	Builtin       bool   `json:"builtin"`                  // no managed version is active
+	ActiveVersion int    `json:"active_version,omitempty"` // managed version in use
+}
@@ -2,7 +2,10 @@ package otp
 
 import (
 	"fmt"
+	otpModel "passport-booking/models/otp"
+	bookingTypes "passport-booking/types/booking"
 	"passport-booking/utils"
+	"time"
 )
 
 // SendOTPRequest represents the request payload for sending OTP
@@ -67,3 +70,52 @@ type OTPResponse struct {
 	IsBlocked         *bool  `json:"is_blocked,omitempty"`
 	NewOTPSent        bool   `json:"new_otp_sent,omitempty"`
 }
+
+// BatchOTPStatusRequest represents the request for looking up OTP status of many bookings
+type BatchOTPStatusRequest struct {
+	BookingIDs     []string                    `json:"booking_ids" validate:"required"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
+	Purpose        otpModel.OTPPurpose         `json:"purpose,omitempty"`
+}
+
+// MaxBatchOTPStatusSize caps how many bookings can be looked up in one call
+const MaxBatchOTPStatusSize = 100
+
+// Validate validates the BatchOTPStatusRequest fields
+func (r *BatchOTPStatusRequest) Validate() error {
+	if len(r.BookingIDs) == 0 {
+		return fmt.Errorf("booking_ids is required")
+	}
+	if len(r.BookingIDs) > MaxBatchOTPStatusSize {
+		return fmt.Errorf("booking_ids cannot contain more than %d entries", MaxBatchOTPStatusSize)
+	}
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+	if r.IdentifierType == bookingTypes.IdentifierTypeAuto {
+		return fmt.Errorf("identifier_type 'auto' is not supported for batch lookups")
+	}
+	if r.Purpose == "" {
+		r.Purpose = otpModel.OTPPurposeDeliveryConfirmPhone
+	}
+	if r.Purpose != otpModel.OTPPurposeDeliveryApplyPhone && r.Purpose != otpModel.OTPPurposeDeliveryConfirmPhone {
+		return fmt.Errorf("purpose must be either 'delivery_phone_apply_verification' or 'delivery_phone_confirm_verification'")
+	}
+	return nil
+}
+
+// BookingOTPStatus is the per-booking entry of a batch OTP status response
+type BookingOTPStatus struct {
+	BookingID                      string     `json:"booking_id"`
+	Found                          bool       `json:"found"`
+	DeliveryPhoneAppliedVerified   bool       `json:"delivery_phone_applied_verified"`
+	DeliveryPhoneConfirmedVerified bool       `json:"delivery_phone_confirmed_verified"`
+	DeliveryApplicationIDVerified  bool       `json:"delivery_application_id_verified"`
+	OTPSent                        bool       `json:"otp_sent"`
+	OTPActive                      bool       `json:"otp_active"`
+	OTPUsed                        bool       `json:"otp_used"`
+	OTPBlocked                     bool       `json:"otp_blocked"`
+	RemainingRetries               int        `json:"remaining_retries"`
+	BlockedUntil                   *time.Time `json:"blocked_until,omitempty"`
+	ExpiresAt                      *time.Time `json:"expires_at,omitempty"`
+}
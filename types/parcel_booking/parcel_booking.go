@@ -1,5 +1,13 @@
 package parcel_booking
 
+import (
+	"fmt"
+	parcelModel "passport-booking/models/parcel_booking"
+	"passport-booking/utils"
+	"strings"
+	"time"
+)
+
 // StoreParcelBookingRequest represents the request structure for storing parcel booking
 type StoreParcelBookingRequest struct {
 	RpoAddress string `json:"rpo_address" validate:"required"`
@@ -16,4 +24,235 @@ type StorePendingBookingRequest struct {
 // StoreSubmitRequest represents the request structure for submitting parcel booking
 type StoreSubmitRequest struct {
 	Barcode string `json:"barcode" validate:"required"`
-}
\ No newline at end of file
+}
+
+// ReceiveParcelRequest represents the request structure for receiving a booked parcel at the RPO
+type ReceiveParcelRequest struct {
+	Barcode string `json:"barcode" validate:"required"`
+}
+
+// Validate validates the ReceiveParcelRequest fields
+func (r *ReceiveParcelRequest) Validate() error {
+	return normalizeBarcode(&r.Barcode)
+}
+
+// ParcelDeliverySendOtpRequest represents the request structure for sending the hand-over OTP
+type ParcelDeliverySendOtpRequest struct {
+	Barcode string `json:"barcode" validate:"required"`
+}
+
+// Validate validates the ParcelDeliverySendOtpRequest fields
+func (r *ParcelDeliverySendOtpRequest) Validate() error {
+	return normalizeBarcode(&r.Barcode)
+}
+
+// ParcelDeliveryVerifyOtpRequest represents the request structure for verifying the hand-over OTP
+type ParcelDeliveryVerifyOtpRequest struct {
+	Barcode    string `json:"barcode" validate:"required"`
+	OTPCode    string `json:"otp_code" validate:"required"`
+	OTPSession string `json:"otp_session" validate:"required"` // returned by send-otp
+}
+
+// Validate validates the ParcelDeliveryVerifyOtpRequest fields
+func (r *ParcelDeliveryVerifyOtpRequest) Validate() error {
+	if err := normalizeBarcode(&r.Barcode); err != nil {
+		return err
+	}
+	if r.OTPCode == "" {
+		return fmt.Errorf("otp_code is required")
+	}
+	if r.OTPSession == "" {
+		return fmt.Errorf("otp_session is required")
+	}
+	return nil
+}
+
+// ParcelDeliverRequest represents the request structure for handing a parcel to the applicant
+type ParcelDeliverRequest struct {
+	Barcode string `json:"barcode" validate:"required"`
+	// Optional device location at hand-over
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// Validate validates the ParcelDeliverRequest fields
+func (r *ParcelDeliverRequest) Validate() error {
+	if err := normalizeBarcode(&r.Barcode); err != nil {
+		return err
+	}
+	if (r.Latitude == nil) != (r.Longitude == nil) {
+		return fmt.Errorf("latitude and longitude must be provided together")
+	}
+	if r.Latitude != nil && (*r.Latitude < -90 || *r.Latitude > 90 || *r.Longitude < -180 || *r.Longitude > 180) {
+		return fmt.Errorf("latitude or longitude is out of range")
+	}
+	return nil
+}
+
+func normalizeBarcode(barcode *string) error {
+	*barcode = utils.NormalizeBarcode(*barcode)
+	if *barcode == "" {
+		return fmt.Errorf("barcode is required")
+	}
+	return nil
+}
+
+const (
+	defaultStuckOlderThanMinutes = 60
+	defaultStuckLimit            = 100
+	maxStuckLimit                = 500
+)
+
+// StuckPushRequest holds the query parameters of GET /parcelbooking/stuck-pushes
+type StuckPushRequest struct {
+	OlderThanMinutes int `query:"older_than_minutes"` // minutes since the parcel became pending, defaults to 60
+	Limit            int `query:"limit"`
+}
+
+// Validate fills defaults for the stuck pushes query
+func (r *StuckPushRequest) Validate() error {
+	if r.OlderThanMinutes == 0 {
+		r.OlderThanMinutes = defaultStuckOlderThanMinutes
+	}
+	if r.OlderThanMinutes < 0 {
+		return fmt.Errorf("older_than_minutes must be positive")
+	}
+	if r.Limit == 0 {
+		r.Limit = defaultStuckLimit
+	}
+	if r.Limit < 0 || r.Limit > maxStuckLimit {
+		return fmt.Errorf("limit must be between 1 and %d", maxStuckLimit)
+	}
+	return nil
+}
+
+// StuckPush is a parcel booking whose DMS submission has not gone through
+type StuckPush struct {
+	ID           uint       `json:"id"`
+	Barcode      string     `json:"barcode"`
+	RpoName      string     `json:"rpo_name"`
+	PostCode     string     `json:"post_code"`
+	PushAttempts int        `json:"push_attempts"`
+	LastError    *string    `json:"last_error"`
+	LastPushAt   *time.Time `json:"last_push_at"`
+	NextPushAt   *time.Time `json:"next_push_at"` // null once retries have given up
+	GaveUp       bool       `json:"gave_up"`
+	AgeMinutes   int64      `json:"age_minutes"`
+}
+
+// Receipt is the customer receipt of a completed counter booking
+type Receipt struct {
+	ReceiptNo           string    `json:"receipt_no"`
+	Barcode             string    `json:"barcode"`
+	BookedAt            time.Time `json:"booked_at"`
+	Operator            string    `json:"operator"`
+	RpoName             string    `json:"rpo_name"`
+	RpoAddress          string    `json:"rpo_address"`
+	PostCode            string    `json:"post_code"`
+	Phone               string    `json:"phone"`
+	ServiceType         string    `json:"service_type"`
+	VasType             string    `json:"vas_type,omitempty"`
+	Insured             bool      `json:"insured"`
+	Price               float64   `json:"price"`
+	TotalCharge         float64   `json:"total_charge"`
+	TrackingURL         string    `json:"tracking_url"`
+	TrackingInstruction string    `json:"tracking_instruction"`
+}
+
+// ReceiptRequest selects the receipt format
+type ReceiptRequest struct {
+	Format string `query:"format"` // json (default), escpos or pdf
+}
+
+// Validate defaults and checks the receipt format
+func (r *ReceiptRequest) Validate() error {
+	if r.Format == "" {
+		r.Format = "json"
+	}
+	switch r.Format {
+	case "json", "escpos", "pdf":
+		return nil
+	}
+	return fmt.Errorf("format must be one of 'json', 'escpos' or 'pdf'")
+}
+
+// OpenCounterSessionRequest starts an operator's counter session
+type OpenCounterSessionRequest struct {
+	BranchCode   string  `json:"branch_code"`
+	OpeningFloat float64 `json:"opening_float"`
+}
+
+// Validate validates the OpenCounterSessionRequest fields
+func (r *OpenCounterSessionRequest) Validate() error {
+	r.BranchCode = strings.TrimSpace(r.BranchCode)
+	if r.BranchCode == "" {
+		return fmt.Errorf("branch_code is required")
+	}
+	if r.OpeningFloat < 0 {
+		return fmt.Errorf("opening_float cannot be negative")
+	}
+	return nil
+}
+
+// CloseCounterSessionRequest ends the operator's counter session with the cash counted
+type CloseCounterSessionRequest struct {
+	DeclaredCash *float64 `json:"declared_cash"`
+	Note         string   `json:"note,omitempty"`
+}
+
+// Validate validates the CloseCounterSessionRequest fields
+func (r *CloseCounterSessionRequest) Validate() error {
+	if r.DeclaredCash == nil {
+		return fmt.Errorf("declared_cash is required")
+	}
+	if *r.DeclaredCash < 0 {
+		return fmt.Errorf("declared_cash cannot be negative")
+	}
+	r.Note = strings.TrimSpace(r.Note)
+	if len(r.Note) > 1000 {
+		return fmt.Errorf("note must be at most 1000 characters")
+	}
+	return nil
+}
+
+// CounterSessionListRequest filters the counter session report
+type CounterSessionListRequest struct {
+	BranchCode string `query:"branch_code"`
+	OperatorID uint   `query:"operator_id"`
+	Status     string `query:"status"`
+	From       string `query:"from"` // YYYY-MM-DD, on opened_at
+	To         string `query:"to"`   // YYYY-MM-DD, inclusive
+}
+
+// Validate validates the CounterSessionListRequest fields
+func (r *CounterSessionListRequest) Validate() error {
+	switch r.Status {
+	case "", "open", "closed":
+	default:
+		return fmt.Errorf("status must be one of 'open' or 'closed'")
+	}
+	for name, value := range map[string]string{"from": r.From, "to": r.To} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return fmt.Errorf("%s must be a date in YYYY-MM-DD format", name)
+		}
+	}
+	return nil
+}
+
+// CounterSessionReport is a session with the bookings whose fees it collected
+type CounterSessionReport struct {
+	Session  parcelModel.CounterSession `json:"session"`
+	Bookings []CounterSessionBooking    `json:"bookings"`
+}
+
+// CounterSessionBooking is one booking in a session report
+type CounterSessionBooking struct {
+	ID            uint       `json:"id"`
+	Barcode       string     `json:"barcode"`
+	CurrentStatus string     `json:"current_status"`
+	TotalCharge   float64    `json:"total_charge"`
+	BookingDate   *time.Time `json:"booking_date"`
+}
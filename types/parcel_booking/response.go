@@ -0,0 +1,117 @@
+package parcel_booking
+
+import (
+	parcelModel "passport-booking/models/parcel_booking"
+	"passport-booking/utils"
+	"time"
+)
+
+// ParcelBookingResponse is the public shape of a parcel booking. DMS push state,
+// the counter session and the hand-over GPS fix are deliberately left out.
+type ParcelBookingResponse struct {
+	ID                    uint                       `json:"id"`
+	UserID                uint                       `json:"user_id"`
+	User                  *ParcelBookingUserResponse `json:"user,omitempty"`
+	InsuranceID           *uint                      `json:"insurance_id"`
+	RpoAddress            string                     `json:"rpo_address"`
+	Phone                 string                     `json:"phone"`
+	PostCode              string                     `json:"post_code"`
+	RpoName               string                     `json:"rpo_name"`
+	Barcode               string                     `json:"barcode"`
+	TotalCharge           float64                    `json:"total_charge"`
+	ServiceType           string                     `json:"service_type"`
+	VasType               string                     `json:"vas_type"`
+	Price                 float64                    `json:"price"`
+	Insured               bool                       `json:"insured"`
+	CurrentStatus         string                     `json:"current_status"`
+	UpdatedBy             string                     `json:"updated_by,omitempty"`
+	DeliveryPhoneVerified bool                       `json:"delivery_phone_verified"`
+	UploadPhoto           *string                    `json:"upload_photo,omitempty"`
+	CreatedAt             time.Time                  `json:"created_at"`
+	PendingDate           *time.Time                 `json:"pending_date"`
+	BookingDate           *time.Time                 `json:"booking_date"`
+	ReceivedDate          *time.Time                 `json:"received_date"`
+	DeliveredDate         *time.Time                 `json:"delivered_date"`
+	UpdatedAt             time.Time                  `json:"updated_at"`
+}
+
+// ParcelBookingUserResponse is the subset of user data exposed alongside a parcel booking
+type ParcelBookingUserResponse struct {
+	ID        uint   `json:"id"`
+	Uuid      string `json:"uuid"`
+	Username  string `json:"username"`
+	LegalName string `json:"legal_name"`
+	Phone     string `json:"phone"`
+}
+
+// NewParcelBookingResponse maps a parcel booking model to its response DTO
+func NewParcelBookingResponse(p *parcelModel.ParcelBooking) ParcelBookingResponse {
+	resp := ParcelBookingResponse{
+		ID:                    p.ID,
+		UserID:                p.UserID,
+		InsuranceID:           p.InsuranceID,
+		RpoAddress:            p.RpoAddress,
+		Phone:                 p.Phone,
+		PostCode:              p.PostCode,
+		RpoName:               p.RpoName,
+		Barcode:               p.Barcode,
+		TotalCharge:           p.TotalCharge,
+		ServiceType:           p.ServiceType,
+		VasType:               p.VasType,
+		Price:                 p.Price,
+		Insured:               p.Insured,
+		CurrentStatus:         p.CurrentStatus,
+		UpdatedBy:             p.UpdatedBy,
+		DeliveryPhoneVerified: p.DeliveryPhoneVerified,
+		UploadPhoto:           p.UploadPhoto,
+		CreatedAt:             p.CreatedAt,
+		PendingDate:           p.PendingDate,
+		BookingDate:           p.BookingDate,
+		ReceivedDate:          p.ReceivedDate,
+		DeliveredDate:         p.DeliveredDate,
+		UpdatedAt:             p.UpdatedAt,
+	}
+
+	// Only include the user when the relation was preloaded
+	if p.User.ID != 0 {
+		resp.User = &ParcelBookingUserResponse{
+			ID:        p.User.ID,
+			Uuid:      p.User.Uuid,
+			Username:  p.User.Username,
+			LegalName: p.User.LegalName,
+			Phone:     p.User.Phone,
+		}
+	}
+
+	return resp
+}
+
+// NewParcelBookingResponses maps a list of parcel booking models to response DTOs
+func NewParcelBookingResponses(parcels []parcelModel.ParcelBooking) []ParcelBookingResponse {
+	resp := make([]ParcelBookingResponse, 0, len(parcels))
+	for i := range parcels {
+		resp = append(resp, NewParcelBookingResponse(&parcels[i]))
+	}
+	return resp
+}
+
+// MaskPII partially masks the phone numbers of the response for roles that do not
+// need them in full
+func (r *ParcelBookingResponse) MaskPII() {
+	r.Phone = utils.MaskPhone(r.Phone)
+	if r.User != nil {
+		r.User.Phone = utils.MaskPhone(r.User.Phone)
+	}
+}
+
+// NewParcelBookingResponsesMasked maps parcel bookings to response DTOs, masking PII
+// when maskPII is set
+func NewParcelBookingResponsesMasked(parcels []parcelModel.ParcelBooking, maskPII bool) []ParcelBookingResponse {
+	resp := NewParcelBookingResponses(parcels)
+	if maskPII {
+		for i := range resp {
+			resp[i].MaskPII()
+		}
+	}
+	return resp
+}
@@ -0,0 +1,70 @@
+package preference
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	LanguageEnglish = "en"
+	LanguageBangla  = "bn"
+
+	ChannelSMS   = "sms"
+	ChannelEmail = "email"
+	ChannelNone  = "none"
+)
+
+var (
+	supportedLanguages = map[string]bool{LanguageEnglish: true, LanguageBangla: true}
+	supportedChannels  = map[string]bool{ChannelSMS: true, ChannelEmail: true, ChannelNone: true}
+)
+
+// Preferences is the resolved preference set of a user with defaults applied
+type Preferences struct {
+	Language            string `json:"language"`
+	NotificationChannel string `json:"notification_channel"`
+	DefaultBranch       string `json:"default_branch"`
+	PushItemsAssigned   bool   `json:"push_items_assigned"`
+	PushEscalations     bool   `json:"push_escalations"`
+	PostmasterDigest    bool   `json:"postmaster_digest"` // daily branch summary for postmasters
+}
+
+// UpdatePreferencesRequest updates any subset of the user's preferences.
+// An empty default_branch clears the stored branch.
+type UpdatePreferencesRequest struct {
+	Language            *string `json:"language,omitempty"`
+	NotificationChannel *string `json:"notification_channel,omitempty"`
+	DefaultBranch       *string `json:"default_branch,omitempty"`
+	PushItemsAssigned   *bool   `json:"push_items_assigned,omitempty"`
+	PushEscalations     *bool   `json:"push_escalations,omitempty"`
+	PostmasterDigest    *bool   `json:"postmaster_digest,omitempty"`
+}
+
+func (r *UpdatePreferencesRequest) Validate() error {
+	if r.Language == nil && r.NotificationChannel == nil && r.DefaultBranch == nil &&
+		r.PushItemsAssigned == nil && r.PushEscalations == nil && r.PostmasterDigest == nil {
+		return fmt.Errorf("at least one preference is required")
+	}
+	if r.Language != nil {
+		lang := strings.ToLower(strings.TrimSpace(*r.Language))
+		if !supportedLanguages[lang] {
+			return fmt.Errorf("language must be one of: en, bn")
+		}
+		r.Language = &lang
+	}
+	if r.NotificationChannel != nil {
+		channel := strings.ToLower(strings.TrimSpace(*r.NotificationChannel))
+		if !supportedChannels[channel] {
+			return fmt.Errorf("notification_channel must be one of: sms, email, none")
+		}
+		r.NotificationChannel = &channel
+	}
+	if r.DefaultBranch != nil {
+		branch := strings.TrimSpace(*r.DefaultBranch)
+		if len(branch) > 50 {
+			return fmt.Errorf("default_branch must not exceed 50 characters")
+		}
+		r.DefaultBranch = &branch
+	}
+	return nil
+}
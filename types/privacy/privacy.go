@@ -0,0 +1,38 @@
+package privacy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EraseRequest is the body of POST /privacy/bookings/:id/erase
+type EraseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Validate validates the EraseRequest fields
+func (r *EraseRequest) Validate() error {
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	return nil
+}
+
+// LegalHoldRequest is the body of PUT /privacy/bookings/:id/legal-hold
+type LegalHoldRequest struct {
+	LegalHold *bool  `json:"legal_hold"`
+	Reason    string `json:"reason"`
+}
+
+// Validate validates the LegalHoldRequest fields
+func (r *LegalHoldRequest) Validate() error {
+	if r.LegalHold == nil {
+		return fmt.Errorf("legal_hold is required")
+	}
+	r.Reason = strings.TrimSpace(r.Reason)
+	if *r.LegalHold && r.Reason == "" {
+		return fmt.Errorf("reason is required when placing a legal hold")
+	}
+	return nil
+}
@@ -9,7 +9,8 @@ type RegisterUserRequest struct {
 	Token       string `json:"token"`        // Redirect URL after login
 	Password    string `json:"password"`
 	Username    string `json:"username"`
-	Access      string `json:"access"` // Access token for the user
+	Access      string `json:"access"`                // Access token for the user
+	BranchCode  string `json:"branch_code,omitempty"` // Branch the operator asks to join; reviewed with the registration
 }
 type RegisterUserResponse struct {
 	Status  string         `json:"status"`
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// AnonymizedDatasetRequest holds the query parameters of GET /reports/research/dataset
+type AnonymizedDatasetRequest struct {
+	FromDate string `query:"from_date"` // YYYY-MM-DD, defaults to 30 days before to_date
+	ToDate   string `query:"to_date"`   // YYYY-MM-DD inclusive, defaults to today
+	Format   string `query:"format"`    // json (default) or csv
+	K        int    `query:"k"`         // smallest published group, at least the configured minimum
+
+	From time.Time `query:"-"`
+	To   time.Time `query:"-"` // exclusive upper bound
+}
+
+// Validate fills defaults and checks the range and threshold against minK
+func (r *AnonymizedDatasetRequest) Validate(minK int) error {
+	r.Format = strings.ToLower(strings.TrimSpace(r.Format))
+	if r.Format == "" {
+		r.Format = FormatJSON
+	}
+	if r.Format != FormatJSON && r.Format != FormatCSV {
+		return fmt.Errorf("format must be either 'json' or 'csv'")
+	}
+
+	if r.K == 0 {
+		r.K = minK
+	}
+	if r.K < minK {
+		return fmt.Errorf("k must be at least %d", minK)
+	}
+
+	from, to, err := parseDateRange(r.FromDate, r.ToDate)
+	if err != nil {
+		return err
+	}
+	r.From, r.To = from, to
+	return nil
+}
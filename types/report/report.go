@@ -0,0 +1,421 @@
+package report
+
+import (
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	"passport-booking/services/localtime"
+	"strings"
+	"time"
+)
+
+const (
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+
+	dateLayout = "2006-01-02"
+
+	defaultRangeDays = 30
+	maxRangeDays     = 366
+)
+
+// TimeSeriesRequest holds the query parameters of GET /reports/timeseries
+type TimeSeriesRequest struct {
+	Interval      string `query:"interval"`        // day (default) or week
+	FromDate      string `query:"from_date"`       // YYYY-MM-DD, defaults to 30 days before to_date
+	ToDate        string `query:"to_date"`         // YYYY-MM-DD inclusive, defaults to today
+	BranchCode    string `query:"branch_code"`     // restrict to one delivery branch
+	GroupByBranch bool   `query:"group_by_branch"` // split every period per delivery branch
+
+	From time.Time `query:"-"`
+	To   time.Time `query:"-"` // exclusive upper bound
+}
+
+// Validate fills defaults and checks the requested range
+func (r *TimeSeriesRequest) Validate() error {
+	r.Interval = strings.ToLower(strings.TrimSpace(r.Interval))
+	if r.Interval == "" {
+		r.Interval = IntervalDay
+	}
+	if r.Interval != IntervalDay && r.Interval != IntervalWeek {
+		return fmt.Errorf("interval must be either 'day' or 'week'")
+	}
+
+	from, to, err := parseDateRange(r.FromDate, r.ToDate)
+	if err != nil {
+		return err
+	}
+	r.From, r.To = from, to
+	return nil
+}
+
+// parseDateRange turns optional YYYY-MM-DD bounds into a [from, to) range,
+// defaulting to the last 30 days
+func parseDateRange(fromDate, toDate string) (time.Time, time.Time, error) {
+	today := localtime.StartOfDay(time.Now())
+
+	to := today
+	if toDate != "" {
+		parsed, err := localtime.ParseDate(dateLayout, toDate)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to_date format. Use 'YYYY-MM-DD'")
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -defaultRangeDays+1)
+	if fromDate != "" {
+		parsed, err := localtime.ParseDate(dateLayout, fromDate)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from_date format. Use 'YYYY-MM-DD'")
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from_date cannot be after to_date")
+	}
+	if to.Sub(from) > maxRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("date range cannot exceed %d days", maxRangeDays)
+	}
+
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// TimeSeriesPoint holds the counts for one period (and branch when grouped)
+type TimeSeriesPoint struct {
+	Period     time.Time `json:"period"`
+	BranchCode *string   `json:"branch_code,omitempty"`
+	Created    int64     `json:"created"`
+	Booked     int64     `json:"booked"`
+	Delivered  int64     `json:"delivered"`
+	Failed     int64     `json:"failed"`
+}
+
+// TimeSeriesResponse is the payload of GET /reports/timeseries
+type TimeSeriesResponse struct {
+	Interval string            `json:"interval"`
+	From     string            `json:"from_date"`
+	To       string            `json:"to_date"`
+	Points   []TimeSeriesPoint `json:"points"`
+}
+
+const (
+	defaultLeaderboardLimit = 20
+	maxLeaderboardLimit     = 100
+	defaultScorecardMonths  = 6
+	maxScorecardMonths      = 24
+)
+
+// LeaderboardRequest holds the query parameters of GET /reports/postmen/leaderboard
+type LeaderboardRequest struct {
+	Period     string `query:"period"`      // YYYY-MM, defaults to the current month
+	BranchCode string `query:"branch_code"` // only postmen whose main delivery branch matches
+	Limit      int    `query:"limit"`
+}
+
+// Validate fills defaults for the leaderboard query
+func (r *LeaderboardRequest) Validate() error {
+	if err := validatePeriod(&r.Period); err != nil {
+		return err
+	}
+	if r.Limit <= 0 {
+		r.Limit = defaultLeaderboardLimit
+	}
+	if r.Limit > maxLeaderboardLimit {
+		r.Limit = maxLeaderboardLimit
+	}
+	return nil
+}
+
+// ScorecardRequest holds the query parameters of GET /reports/postmen/:id/scorecard
+type ScorecardRequest struct {
+	Period string `query:"period"` // YYYY-MM, defaults to the current month
+	Months int    `query:"months"` // history length including period
+}
+
+// Validate fills defaults for the scorecard query
+func (r *ScorecardRequest) Validate() error {
+	if err := validatePeriod(&r.Period); err != nil {
+		return err
+	}
+	if r.Months <= 0 {
+		r.Months = defaultScorecardMonths
+	}
+	if r.Months > maxScorecardMonths {
+		r.Months = maxScorecardMonths
+	}
+	return nil
+}
+
+func validatePeriod(period *string) error {
+	*period = strings.TrimSpace(*period)
+	if *period == "" {
+		*period = localtime.In(time.Now()).Format("2006-01")
+		return nil
+	}
+	if _, err := time.Parse("2006-01", *period); err != nil {
+		return fmt.Errorf("invalid period format. Use 'YYYY-MM'")
+	}
+	return nil
+}
+
+// PostmanMetricEntry is a postman metric row with the postman's identity and rank
+type PostmanMetricEntry struct {
+	Rank             int     `json:"rank"`
+	PostmanID        uint    `json:"postman_id"`
+	Username         string  `json:"username"`
+	LegalName        string  `json:"legal_name"`
+	Period           string  `json:"period"`
+	BranchCode       *string `json:"branch_code,omitempty"`
+	ItemsReceived    int64   `json:"items_received"`
+	Delivered        int64   `json:"delivered"`
+	OnTimeRate       float64 `json:"on_time_rate"`
+	FirstAttemptRate float64 `json:"first_attempt_rate"`
+	AvgItemsPerDay   float64 `json:"avg_items_per_day"`
+	Score            float64 `json:"score"`
+}
+
+// ScorecardResponse is the payload of GET /reports/postmen/:id/scorecard
+type ScorecardResponse struct {
+	Current *PostmanMetricEntry  `json:"current"`
+	History []PostmanMetricEntry `json:"history"`
+}
+
+const (
+	defaultCapacityDays = 7
+	maxCapacityDays     = 90
+)
+
+// CapacityRequest holds the query parameters of GET /reports/branches/capacity
+type CapacityRequest struct {
+	Days       int    `query:"days"`        // inbound window in days, defaults to 7
+	BranchCode string `query:"branch_code"` // restrict to one delivery branch
+}
+
+// Validate fills defaults for the capacity query
+func (r *CapacityRequest) Validate() error {
+	if r.Days == 0 {
+		r.Days = defaultCapacityDays
+	}
+	if r.Days < 0 || r.Days > maxCapacityDays {
+		return fmt.Errorf("days must be between 1 and %d", maxCapacityDays)
+	}
+	r.BranchCode = strings.TrimSpace(r.BranchCode)
+	return nil
+}
+
+// BacklogAge counts open bookings by time since their last status change
+type BacklogAge struct {
+	UnderOneDay    int64 `json:"under_1d"`
+	OneToThreeDays int64 `json:"1d_to_3d"`
+	ThreeToSeven   int64 `json:"3d_to_7d"`
+	OverSevenDays  int64 `json:"over_7d"`
+}
+
+// BranchCapacity is the workload of one delivery branch
+type BranchCapacity struct {
+	BranchCode       string     `json:"branch_code"`
+	Inbound          int64      `json:"inbound"`
+	InboundPerDay    float64    `json:"inbound_per_day"`
+	Postmen          int64      `json:"postmen"`
+	InboundPerPerson *float64   `json:"inbound_per_postman"` // null when no postman was active
+	Backlog          int64      `json:"backlog"`
+	BacklogAge       BacklogAge `json:"backlog_age"`
+	OldestBacklogHrs float64    `json:"oldest_backlog_hours"`
+}
+
+const (
+	HeatmapGroupCell     = "cell"
+	HeatmapGroupPostCode = "post_code"
+
+	defaultHeatmapPrecision = 2
+	maxHeatmapPrecision     = 3
+	defaultHeatmapMinCount  = 3
+)
+
+// HeatmapRequest holds the query parameters of GET /reports/deliveries/heatmap
+type HeatmapRequest struct {
+	FromDate   string `query:"from_date"`
+	ToDate     string `query:"to_date"`
+	BranchCode string `query:"branch_code"`
+	GroupBy    string `query:"group_by"`  // cell (default) or post_code
+	Precision  int    `query:"precision"` // decimal places kept of the coordinates, 0-3
+	MinCount   int    `query:"min_count"` // groups with fewer deliveries are left out
+
+	From time.Time `query:"-"`
+	To   time.Time `query:"-"` // exclusive upper bound
+}
+
+// Validate fills defaults for the heatmap query. Precision is capped at three decimal
+// places (~110m) and groups below the default minimum count are never exposed so a
+// single delivery cannot be traced back to a home address.
+func (r *HeatmapRequest) Validate() error {
+	r.GroupBy = strings.ToLower(strings.TrimSpace(r.GroupBy))
+	if r.GroupBy == "" {
+		r.GroupBy = HeatmapGroupCell
+	}
+	if r.GroupBy != HeatmapGroupCell && r.GroupBy != HeatmapGroupPostCode {
+		return fmt.Errorf("group_by must be either 'cell' or 'post_code'")
+	}
+
+	if r.Precision == 0 {
+		r.Precision = defaultHeatmapPrecision
+	}
+	if r.Precision < 0 || r.Precision > maxHeatmapPrecision {
+		return fmt.Errorf("precision must be between 0 and %d", maxHeatmapPrecision)
+	}
+	if r.MinCount < defaultHeatmapMinCount {
+		r.MinCount = defaultHeatmapMinCount
+	}
+
+	from, to, err := parseDateRange(r.FromDate, r.ToDate)
+	if err != nil {
+		return err
+	}
+	r.From, r.To = from, to
+	r.BranchCode = strings.TrimSpace(r.BranchCode)
+	return nil
+}
+
+// GeoJSONFeatureCollection is a minimal GeoJSON FeatureCollection
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature is a GeoJSON Feature; Geometry is null for post-code groups
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *GeoJSONPoint          `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONPoint holds [longitude, latitude] as required by GeoJSON
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// InventoryItem is one booking physically held at a branch
+type InventoryItem struct {
+	BookingID    uint      `json:"booking_id"`
+	Barcode      *string   `json:"barcode,omitempty"`
+	AppOrOrderID string    `json:"app_or_order_id"`
+	Name         string    `json:"name"`
+	Status       string    `json:"status"`
+	InCustodyAt  time.Time `json:"in_custody_since"`
+	AgeHours     float64   `json:"age_hours"`
+	OnHold       bool      `json:"on_hold"` // applicant asked the branch to keep it for now
+}
+
+// InventoryBucket groups held items of similar age
+type InventoryBucket struct {
+	Bucket string          `json:"bucket"`
+	Count  int             `json:"count"`
+	Items  []InventoryItem `json:"items"`
+}
+
+// BranchInventory is the stock list of one branch for physical verification
+type BranchInventory struct {
+	BranchCode  string            `json:"branch_code"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Total       int               `json:"total"`
+	Buckets     []InventoryBucket `json:"buckets"`
+}
+
+// CustodyEntry is one leg of a booking's chain of custody
+type CustodyEntry struct {
+	Status          string     `json:"status"`
+	Custodian       string     `json:"custodian"`          // regional_passport_office, branch, postman, in_transit or recipient
+	Location        *string    `json:"location,omitempty"` // branch code while the item is at a branch
+	RecordedByID    *uint      `json:"recorded_by_id,omitempty"`
+	RecordedByName  string     `json:"recorded_by_name"`
+	ImpersonatedBy  *string    `json:"impersonated_by,omitempty"`
+	From            time.Time  `json:"from"`
+	Until           *time.Time `json:"until,omitempty"` // nil for the current leg
+	DurationSeconds int64      `json:"duration_seconds"`
+}
+
+// CustodyChain is who held a booking's item when, for legal requests
+type CustodyChain struct {
+	BookingID     uint           `json:"booking_id"`
+	AppOrOrderID  string         `json:"app_or_order_id"`
+	Barcode       *string        `json:"barcode,omitempty"`
+	CurrentStatus string         `json:"current_status"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Entries       []CustodyEntry `json:"entries"`
+}
+
+// RollupRequest holds the query parameters of GET /reports/rollup
+type RollupRequest struct {
+	Level    bookingModel.OrgLevel `query:"level"`     // region (default), district or branch
+	Root     string                `query:"root"`      // only units under this region or district
+	FromDate string                `query:"from_date"` // YYYY-MM-DD, defaults to 30 days before to_date
+	ToDate   string                `query:"to_date"`   // YYYY-MM-DD inclusive, defaults to today
+
+	From time.Time `query:"-"`
+	To   time.Time `query:"-"` // exclusive upper bound
+}
+
+// Validate fills defaults and checks the requested level and range
+func (r *RollupRequest) Validate() error {
+	switch r.Level {
+	case "":
+		r.Level = bookingModel.OrgLevelRegion
+	case bookingModel.OrgLevelRegion, bookingModel.OrgLevelDistrict, bookingModel.OrgLevelBranch:
+	default:
+		return fmt.Errorf("level must be one of 'region', 'district' or 'branch'")
+	}
+	r.Root = strings.TrimSpace(r.Root)
+
+	from, to, err := parseDateRange(r.FromDate, r.ToDate)
+	if err != nil {
+		return err
+	}
+	r.From, r.To = from, to
+	return nil
+}
+
+// RollupKPIs are the delivery KPIs summed over the branches of a unit
+type RollupKPIs struct {
+	Booked          int64    `json:"booked"`
+	Delivered       int64    `json:"delivered"`
+	Returned        int64    `json:"returned"`
+	Backlog         int64    `json:"backlog"`
+	DeliveryRate    *float64 `json:"delivery_rate"` // delivered / (delivered + returned), nil without either
+	AvgDeliveryDays *float64 `json:"avg_delivery_days"`
+}
+
+// RollupEntry is one unit of the requested level with its KPIs
+type RollupEntry struct {
+	Code     string                `json:"code"`
+	Name     string                `json:"name"`
+	Level    bookingModel.OrgLevel `json:"level"`
+	Branches int                   `json:"branches"`
+	RollupKPIs
+}
+
+// SLORequest holds the query parameters of GET /reports/slo
+type SLORequest struct {
+	Month string `query:"month"` // YYYY-MM, defaults to the current month
+
+	Start time.Time `query:"-"` // first day of the month, local zone
+}
+
+// Validate parses the month, rejecting months that have not started
+func (r *SLORequest) Validate(now time.Time) error {
+	current := localtime.In(now)
+	r.Start = time.Date(current.Year(), current.Month(), 1, 0, 0, 0, 0, localtime.Zone())
+	if r.Month = strings.TrimSpace(r.Month); r.Month == "" {
+		return nil
+	}
+	month, err := localtime.ParseDate("2006-01", r.Month)
+	if err != nil {
+		return fmt.Errorf("month must be in YYYY-MM format")
+	}
+	if month.After(r.Start) {
+		return fmt.Errorf("month cannot be in the future")
+	}
+	r.Start = month
+	return nil
+}
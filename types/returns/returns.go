@@ -0,0 +1,143 @@
+package returns
+
+import (
+	"fmt"
+	bookingModel "passport-booking/models/booking"
+	bookingTypes "passport-booking/types/booking"
+	"passport-booking/utils"
+	"strings"
+	"time"
+)
+
+// CreateManifestRequest bags returned bookings for a regional passport office
+type CreateManifestRequest struct {
+	RPOID          uint                        `json:"rpo_id" validate:"required"`
+	BookingIDs     []string                    `json:"booking_ids" validate:"required"`
+	IdentifierType bookingTypes.IdentifierType `json:"identifier_type,omitempty"`
+}
+
+// Validate validates the CreateManifestRequest fields
+func (r *CreateManifestRequest) Validate() error {
+	if r.RPOID == 0 {
+		return fmt.Errorf("rpo_id is required")
+	}
+	if len(r.BookingIDs) == 0 {
+		return fmt.Errorf("booking_ids is required")
+	}
+	if len(r.BookingIDs) > 500 {
+		return fmt.Errorf("a manifest can hold at most 500 bookings")
+	}
+
+	if err := bookingTypes.NormalizeIdentifierType(&r.IdentifierType, bookingTypes.IdentifierTypeBarcode); err != nil {
+		return err
+	}
+	if r.IdentifierType == bookingTypes.IdentifierTypeAuto {
+		return fmt.Errorf("identifier_type 'auto' is not supported for return manifests")
+	}
+
+	for i, id := range r.BookingIDs {
+		if id == "" {
+			return fmt.Errorf("booking_ids must not contain empty values")
+		}
+		if r.IdentifierType == bookingTypes.IdentifierTypeBarcode {
+			r.BookingIDs[i] = utils.NormalizeBarcode(id)
+			if err := utils.ValidateBarcode(r.BookingIDs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AcknowledgeRequest is the RPO's confirmation of a received return bag. When
+// ReceivedBookingIDs is omitted every item on the manifest counts as received.
+type AcknowledgeRequest struct {
+	ReceivedBookingIDs []uint `json:"received_booking_ids,omitempty"`
+	Note               string `json:"note,omitempty"`
+}
+
+// Validate validates the AcknowledgeRequest fields
+func (r *AcknowledgeRequest) Validate() error {
+	r.Note = strings.TrimSpace(r.Note)
+	if len(r.Note) > 1000 {
+		return fmt.Errorf("note must be at most 1000 characters")
+	}
+	return nil
+}
+
+// ManifestListRequest filters the manifest list
+type ManifestListRequest struct {
+	Status  bookingModel.ReturnManifestStatus `query:"status"`
+	RPOID   uint                              `query:"rpo_id"`
+	Page    int                               `query:"page"`
+	PerPage int                               `query:"per_page"`
+}
+
+// Validate validates the ManifestListRequest fields and applies paging defaults
+func (r *ManifestListRequest) Validate() error {
+	switch r.Status {
+	case "", bookingModel.ReturnManifestStatusOpen, bookingModel.ReturnManifestStatusDispatched, bookingModel.ReturnManifestStatusAcknowledged:
+	default:
+		return fmt.Errorf("status must be one of 'open', 'dispatched' or 'acknowledged'")
+	}
+	if r.Page < 1 {
+		r.Page = 1
+	}
+	if r.PerPage < 1 || r.PerPage > 100 {
+		r.PerPage = 20
+	}
+	return nil
+}
+
+// ManifestItemResponse is one booking on a manifest
+type ManifestItemResponse struct {
+	ID        uint                          `json:"id"`
+	Status    bookingModel.ReturnItemStatus `json:"status"`
+	BookingID uint                          `json:"booking_id"`
+	Booking   *bookingTypes.BookingResponse `json:"booking,omitempty"`
+}
+
+// ManifestResponse is a return manifest with its items
+type ManifestResponse struct {
+	ID               uint                              `json:"id"`
+	ManifestNo       string                            `json:"manifest_no"`
+	RPOID            uint                              `json:"rpo_id"`
+	BranchCode       *string                           `json:"branch_code,omitempty"`
+	Status           bookingModel.ReturnManifestStatus `json:"status"`
+	CreatedByID      uint                              `json:"created_by_id"`
+	CreatedAt        time.Time                         `json:"created_at"`
+	DispatchedByID   *uint                             `json:"dispatched_by_id,omitempty"`
+	DispatchedAt     *time.Time                        `json:"dispatched_at,omitempty"`
+	AcknowledgedByID *uint                             `json:"acknowledged_by_id,omitempty"`
+	AcknowledgedAt   *time.Time                        `json:"acknowledged_at,omitempty"`
+	AcknowledgeNote  *string                           `json:"acknowledge_note,omitempty"`
+	Items            []ManifestItemResponse            `json:"items"`
+}
+
+// NewManifestResponse maps a manifest with its preloaded items to its response DTO
+func NewManifestResponse(m *bookingModel.ReturnManifest) ManifestResponse {
+	resp := ManifestResponse{
+		ID:               m.ID,
+		ManifestNo:       m.ManifestNo,
+		RPOID:            m.RPOID,
+		BranchCode:       m.BranchCode,
+		Status:           m.Status,
+		CreatedByID:      m.CreatedByID,
+		CreatedAt:        m.CreatedAt,
+		DispatchedByID:   m.DispatchedByID,
+		DispatchedAt:     m.DispatchedAt,
+		AcknowledgedByID: m.AcknowledgedByID,
+		AcknowledgedAt:   m.AcknowledgedAt,
+		AcknowledgeNote:  m.AcknowledgeNote,
+		Items:            make([]ManifestItemResponse, 0, len(m.Items)),
+	}
+	for _, item := range m.Items {
+		entry := ManifestItemResponse{ID: item.ID, Status: item.Status, BookingID: item.BookingID}
+		if item.Booking != nil {
+			booking := bookingTypes.NewBookingResponse(item.Booking)
+			entry.Booking = &booking
+		}
+		resp.Items = append(resp.Items, entry)
+	}
+	return resp
+}
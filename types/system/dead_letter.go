@@ -0,0 +1,51 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const maxDeadLetterReasonLength = 1000
+
+// ReplayDeadLetterRequest replays a dead-lettered item, optionally edited first.
+// Payload replaces an outbox entry's or webhook's payload; Recipient replaces the
+// phone number or email address of a notification.
+type ReplayDeadLetterRequest struct {
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Recipient string          `json:"recipient,omitempty"`
+	Reason    string          `json:"reason,omitempty"`
+}
+
+// Validate validates the ReplayDeadLetterRequest fields
+func (r *ReplayDeadLetterRequest) Validate() error {
+	r.Recipient = strings.TrimSpace(r.Recipient)
+	r.Reason = strings.TrimSpace(r.Reason)
+	if string(r.Payload) == "null" {
+		r.Payload = nil
+	}
+	if len(r.Payload) > 0 && r.Recipient != "" {
+		return fmt.Errorf("give either payload or recipient, not both")
+	}
+	if len(r.Reason) > maxDeadLetterReasonLength {
+		return fmt.Errorf("reason must be at most %d characters", maxDeadLetterReasonLength)
+	}
+	return nil
+}
+
+// DiscardDeadLetterRequest drops a dead-lettered item for good
+type DiscardDeadLetterRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Validate validates the DiscardDeadLetterRequest fields
+func (r *DiscardDeadLetterRequest) Validate() error {
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if len(r.Reason) > maxDeadLetterReasonLength {
+		return fmt.Errorf("reason must be at most %d characters", maxDeadLetterReasonLength)
+	}
+	return nil
+}
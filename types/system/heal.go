@@ -0,0 +1,31 @@
+package system
+
+import "fmt"
+
+const maxHealBookingIDs = 500
+
+// HealRequest runs one auto-heal command over some bookings or all of them
+type HealRequest struct {
+	BookingIDs []uint `json:"booking_ids,omitempty"`
+	All        bool   `json:"all"`
+	Apply      bool   `json:"apply"` // false reports what would change without writing
+	Force      bool   `json:"force"` // recompute-status: also move delivered or returned bookings and make moves the workflow does not allow
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// Validate validates the HealRequest fields
+func (r *HealRequest) Validate() error {
+	if len(r.BookingIDs) == 0 && !r.All {
+		return fmt.Errorf("booking_ids or all is required")
+	}
+	if len(r.BookingIDs) > 0 && r.All {
+		return fmt.Errorf("give either booking_ids or all, not both")
+	}
+	if len(r.BookingIDs) > maxHealBookingIDs {
+		return fmt.Errorf("at most %d booking_ids can be healed at once", maxHealBookingIDs)
+	}
+	if r.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package account
+
+import (
+	"fmt"
+	"passport-booking/services/localtime"
+	"passport-booking/services/user_activity"
+	bookingTypes "passport-booking/types/booking"
+	"strings"
+	"time"
+)
+
+const (
+	defaultActivityDays = 30
+	maxActivityDays     = 366
+)
+
+// ActivityRequest holds the query parameters of GET /admin/users/:id/activity
+type ActivityRequest struct {
+	From     string `query:"from"`     // YYYY-MM-DD, defaults to 30 days before to
+	To       string `query:"to"`       // YYYY-MM-DD inclusive, defaults to today
+	Category string `query:"category"` // comma-separated booking, bag or parcel
+	Action   string `query:"action"`
+	Page     int    `query:"page"`
+	PerPage  int    `query:"per_page"`
+}
+
+// Filter validates the request and converts it into an activity filter
+func (r *ActivityRequest) Filter(now time.Time) (user_activity.Filter, error) {
+	filter := user_activity.Filter{}
+
+	end := localtime.StartOfDay(now).AddDate(0, 0, 1)
+	if r.To = strings.TrimSpace(r.To); r.To != "" {
+		to, err := localtime.ParseDate("2006-01-02", r.To)
+		if err != nil {
+			return filter, fmt.Errorf("to must be a date in YYYY-MM-DD format")
+		}
+		end = to.AddDate(0, 0, 1)
+	}
+	start := end.AddDate(0, 0, -defaultActivityDays)
+	if r.From = strings.TrimSpace(r.From); r.From != "" {
+		from, err := localtime.ParseDate("2006-01-02", r.From)
+		if err != nil {
+			return filter, fmt.Errorf("from must be a date in YYYY-MM-DD format")
+		}
+		start = from
+	}
+	if !start.Before(end) {
+		return filter, fmt.Errorf("from cannot be after to")
+	}
+	if end.Sub(start) > maxActivityDays*24*time.Hour {
+		return filter, fmt.Errorf("the date range can cover at most %d days", maxActivityDays)
+	}
+	filter.From, filter.To = start, end
+
+	for _, part := range strings.Split(r.Category, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		category := user_activity.Category(part)
+		if !category.IsValid() {
+			return filter, fmt.Errorf("category must be one of 'booking', 'bag' or 'parcel'")
+		}
+		filter.Categories = append(filter.Categories, category)
+	}
+	filter.Action = strings.TrimSpace(r.Action)
+
+	if r.Page <= 0 {
+		r.Page = 1
+	}
+	if r.PerPage <= 0 || r.PerPage > 100 {
+		r.PerPage = 50
+	}
+	filter.Limit = r.PerPage
+	filter.Offset = (r.Page - 1) * r.PerPage
+	return filter, nil
+}
+
+// ActivityResponse is a page of a user's activity
+type ActivityResponse struct {
+	UserID     uint                            `json:"user_id"`
+	Username   string                          `json:"username"`
+	LegalName  string                          `json:"legal_name"`
+	From       time.Time                       `json:"from"`
+	To         time.Time                       `json:"to"`
+	Summary    []user_activity.ActionCount     `json:"summary"`
+	Entries    []user_activity.Entry           `json:"entries"`
+	Pagination bookingTypes.PaginationResponse `json:"pagination"`
+}
@@ -0,0 +1,190 @@
+package account
+
+import (
+	"fmt"
+	"passport-booking/constants"
+	userModel "passport-booking/models/user"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UserListRequest holds the query parameters of GET /admin/users
+type UserListRequest struct {
+	Search     string `query:"search"` // matches username, legal name, phone or email
+	Permission string `query:"permission"`
+	BranchCode string `query:"branch_code"`
+	Active     *bool  `query:"active"`
+	Page       int    `query:"page"`
+	PerPage    int    `query:"per_page"`
+}
+
+// Validate fills defaults for the user list query
+func (r *UserListRequest) Validate() error {
+	r.Search = strings.TrimSpace(r.Search)
+	if len(r.Search) > 100 {
+		return fmt.Errorf("search must be at most 100 characters")
+	}
+	if r.Page <= 0 {
+		r.Page = 1
+	}
+	if r.PerPage <= 0 || r.PerPage > 100 {
+		r.PerPage = 20
+	}
+	return nil
+}
+
+// SetActiveRequest is the body of PUT /admin/users/:id/active
+type SetActiveRequest struct {
+	Active *bool  `json:"active"`
+	Reason string `json:"reason"`
+}
+
+// Validate checks the active toggle request
+func (r *SetActiveRequest) Validate() error {
+	if r.Active == nil {
+		return fmt.Errorf("active is required")
+	}
+	r.Reason = strings.TrimSpace(r.Reason)
+	if !*r.Active && r.Reason == "" {
+		return fmt.Errorf("reason is required to deactivate a user")
+	}
+	return nil
+}
+
+// BranchMappingRequest is the body of POST /admin/users/:id/branches
+type BranchMappingRequest struct {
+	BranchCode   string `json:"branch_code"`
+	Relationship string `json:"relationship"`
+	Reason       string `json:"reason"`
+}
+
+// Validate checks the branch mapping request
+func (r *BranchMappingRequest) Validate() error {
+	r.BranchCode = strings.TrimSpace(r.BranchCode)
+	if r.BranchCode == "" {
+		return fmt.Errorf("branch_code is required")
+	}
+	if len(r.BranchCode) > 50 {
+		return fmt.Errorf("branch_code must be at most 50 characters")
+	}
+	r.Relationship = strings.TrimSpace(r.Relationship)
+	if len(r.Relationship) > 50 {
+		return fmt.Errorf("relationship must be at most 50 characters")
+	}
+	r.Reason = strings.TrimSpace(r.Reason)
+	return nil
+}
+
+// RegistrationListRequest holds the query parameters of GET /admin/registrations
+type RegistrationListRequest struct {
+	Status     userModel.ApprovalStatus `query:"status"`
+	BranchCode string                   `query:"branch_code"`
+	Page       int                      `query:"page"`
+	PerPage    int                      `query:"per_page"`
+}
+
+// Validate fills defaults for the registration queue query
+func (r *RegistrationListRequest) Validate() error {
+	switch r.Status {
+	case "":
+		r.Status = userModel.ApprovalStatusPending
+	case userModel.ApprovalStatusPending, userModel.ApprovalStatusApproved, userModel.ApprovalStatusRejected:
+	default:
+		return fmt.Errorf("status must be one of 'pending', 'approved' or 'rejected'")
+	}
+	r.BranchCode = strings.TrimSpace(r.BranchCode)
+	if r.Page <= 0 {
+		r.Page = 1
+	}
+	if r.PerPage <= 0 || r.PerPage > 100 {
+		r.PerPage = 20
+	}
+	return nil
+}
+
+// ReviewRegistrationRequest is the body of the approve and reject endpoints
+type ReviewRegistrationRequest struct {
+	Note string `json:"note"`
+}
+
+// Validate requires a note when rejecting so the operator learns why
+func (r *ReviewRegistrationRequest) Validate(approve bool) error {
+	r.Note = strings.TrimSpace(r.Note)
+	if !approve && r.Note == "" {
+		return fmt.Errorf("note is required to reject a registration")
+	}
+	if len(r.Note) > 500 {
+		return fmt.Errorf("note must be at most 500 characters")
+	}
+	return nil
+}
+
+// DelegationRequest is the body of POST /delegations
+type DelegationRequest struct {
+	GranteeID   uint       `json:"grantee_id"`
+	Permissions []string   `json:"permissions"`
+	StartsAt    *time.Time `json:"starts_at"` // now when omitted
+	ExpiresAt   time.Time  `json:"expires_at"`
+	Reason      string     `json:"reason"`
+}
+
+// Validate checks the grant against the known permissions and the longest allowed
+// duration, filling StartsAt
+func (r *DelegationRequest) Validate(now time.Time, maxDuration time.Duration) error {
+	if r.GranteeID == 0 {
+		return fmt.Errorf("grantee_id is required")
+	}
+	if len(r.Permissions) == 0 {
+		return fmt.Errorf("permissions are required")
+	}
+	seen := make(map[string]bool, len(r.Permissions))
+	permissions := make([]string, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		p = strings.TrimSpace(p)
+		if p == constants.PermSuperAdminFull {
+			return fmt.Errorf("%s cannot be delegated", p)
+		}
+		if !slices.Contains(constants.KnownPermissions, p) {
+			return fmt.Errorf("unknown permission %q", p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			permissions = append(permissions, p)
+		}
+	}
+	r.Permissions = permissions
+
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if r.StartsAt == nil || r.StartsAt.Before(now) {
+		r.StartsAt = &now
+	}
+	if !r.ExpiresAt.After(*r.StartsAt) {
+		return fmt.Errorf("expires_at must be after starts_at")
+	}
+	if r.ExpiresAt.Sub(*r.StartsAt) > maxDuration {
+		return fmt.Errorf("a delegation can run for at most %d days", int(maxDuration.Hours()/24))
+	}
+	return nil
+}
+
+// DelegationListRequest holds the query parameters of GET /delegations
+type DelegationListRequest struct {
+	Scope      string `query:"scope"` // granted, received or all (super admins)
+	ActiveOnly bool   `query:"active"`
+}
+
+// Validate fills the default scope
+func (r *DelegationListRequest) Validate() error {
+	switch r.Scope {
+	case "":
+		r.Scope = "granted"
+	case "granted", "received", "all":
+	default:
+		return fmt.Errorf("scope must be one of 'granted', 'received' or 'all'")
+	}
+	return nil
+}
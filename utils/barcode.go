@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultProvisionalBarcodePrefix marks barcodes issued locally while DMS was down
+const defaultProvisionalBarcodePrefix = "PRV"
+
+// maxProvisionalSequence is the last sequence a branch range can hold, as the
+// sequence is zero padded to 7 digits
+const maxProvisionalSequence = 9999999
+
+var (
+	// s10BarcodePattern matches UPU S10 article identifiers, e.g. EE123456785BD
+	s10BarcodePattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{9}[A-Z]{2}$`)
+	// basicBarcodePattern is the loosest shape any DMS barcode takes
+	basicBarcodePattern = regexp.MustCompile(`^[A-Z0-9]{6,30}$`)
+	// provisionalBranchCodePattern matches the branch code of a provisional barcode,
+	// a post code or the 000000 default
+	provisionalBranchCodePattern = regexp.MustCompile(`^[0-9]{4,6}$`)
+	// provisionalSuffixPattern matches what follows the provisional prefix: the
+	// branch code and the zero-padded 7 digit sequence
+	provisionalSuffixPattern = regexp.MustCompile(`^[0-9]{4,6}[0-9]{7}$`)
+	// s10Weights are the UPU S10 check digit weights for the 8 serial digits
+	s10Weights = []int{8, 6, 4, 2, 3, 5, 9, 7}
+)
+
+// ProvisionalBarcodePrefix returns the prefix used for locally issued barcodes
+func ProvisionalBarcodePrefix() string {
+	if prefix := os.Getenv("PROVISIONAL_BARCODE_PREFIX"); prefix != "" {
+		return prefix
+	}
+	return defaultProvisionalBarcodePrefix
+}
+
+// IsProvisionalBarcode reports whether a barcode was issued from the local range
+func IsProvisionalBarcode(barcode string) bool {
+	return strings.HasPrefix(barcode, ProvisionalBarcodePrefix())
+}
+
+// FormatProvisionalBarcode builds the provisional barcode for a sequence in a branch
+// range: the prefix, the branch code and the sequence zero padded to 7 digits
+func FormatProvisionalBarcode(branchCode string, sequence int64) (string, error) {
+	if !provisionalBranchCodePattern.MatchString(branchCode) {
+		return "", fmt.Errorf("branch code %s cannot carry provisional barcodes", branchCode)
+	}
+	if sequence < 1 || sequence > maxProvisionalSequence {
+		return "", fmt.Errorf("provisional barcode range of branch %s is exhausted", branchCode)
+	}
+	return fmt.Sprintf("%s%s%07d", ProvisionalBarcodePrefix(), branchCode, sequence), nil
+}
+
+// validateProvisionalBarcode checks that a barcode carrying the provisional prefix
+// has the shape FormatProvisionalBarcode gives it
+func validateProvisionalBarcode(barcode string) error {
+	suffix := strings.TrimPrefix(barcode, ProvisionalBarcodePrefix())
+	if !provisionalSuffixPattern.MatchString(suffix) || strings.Trim(suffix[len(suffix)-7:], "0") == "" {
+		return fmt.Errorf("provisional barcode %s has an invalid format", barcode)
+	}
+	return nil
+}
+
+// NormalizeBarcode trims whitespace and upper-cases a scanned barcode. A
+// scanned tracking QR code URL is reduced to the barcode it points at.
+func NormalizeBarcode(barcode string) string {
+	return strings.ToUpper(strings.TrimSpace(barcodeFromScan(strings.TrimSpace(barcode))))
+}
+
+// ValidateBarcode checks the format of a scanned barcode. Provisional barcodes
+// must hold a branch code and sequence, S10 barcodes have their check digit
+// verified, and when BARCODE_SERVICE_PREFIXES is set
+// (comma separated) the barcode must start with one of those prefixes.
+func ValidateBarcode(barcode string) error {
+	barcode = NormalizeBarcode(barcode)
+	if barcode == "" {
+		return fmt.Errorf("barcode is required")
+	}
+
+	if IsProvisionalBarcode(barcode) {
+		return validateProvisionalBarcode(barcode)
+	}
+
+	if !basicBarcodePattern.MatchString(barcode) {
+		return fmt.Errorf("barcode %s has an invalid format", barcode)
+	}
+
+	if prefixes := os.Getenv("BARCODE_SERVICE_PREFIXES"); prefixes != "" {
+		allowed := false
+		for _, prefix := range strings.Split(prefixes, ",") {
+			if prefix = strings.TrimSpace(prefix); prefix != "" && strings.HasPrefix(barcode, strings.ToUpper(prefix)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("barcode %s does not belong to the passport delivery service", barcode)
+		}
+	}
+
+	if s10BarcodePattern.MatchString(barcode) {
+		expected := S10CheckDigit(barcode[2:10])
+		if int(barcode[10]-'0') != expected {
+			return fmt.Errorf("barcode %s has an invalid check digit", barcode)
+		}
+	}
+
+	return nil
+}
+
+// S10CheckDigit computes the UPU S10 check digit for an 8 digit serial number
+func S10CheckDigit(serial string) int {
+	sum := 0
+	for i, weight := range s10Weights {
+		sum += int(serial[i]-'0') * weight
+	}
+	check := 11 - (sum % 11)
+	switch check {
+	case 10:
+		return 0
+	case 11:
+		return 5
+	}
+	return check
+}
@@ -0,0 +1,52 @@
+package utils
+
+import "testing"
+
+func TestValidateBarcodeProvisional(t *testing.T) {
+	t.Setenv("PROVISIONAL_BARCODE_PREFIX", "")
+	tests := []struct {
+		name    string
+		barcode string
+		wantErr bool
+	}{
+		{"default branch", "PRV0000000000001", false},
+		{"post code branch", "PRV12160000042", false},
+		{"scanned in lower case", " prv12160000042 ", false},
+		{"prefix only", "PRV", true},
+		{"no branch code", "PRV0000042", true},
+		{"unpadded sequence", "PRV121642", true},
+		{"sequence zero", "PRV12160000000", true},
+		{"letters after the prefix", "PRVDHAKA0000042", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBarcode(tt.barcode)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateBarcode(%q) = %v, want error %v", tt.barcode, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatProvisionalBarcode(t *testing.T) {
+	t.Setenv("PROVISIONAL_BARCODE_PREFIX", "")
+	barcode, err := FormatProvisionalBarcode("1216", 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if barcode != "PRV12160000042" {
+		t.Errorf("barcode %s, want PRV12160000042", barcode)
+	}
+	if err := ValidateBarcode(barcode); err != nil {
+		t.Errorf("issued barcode rejected: %v", err)
+	}
+
+	for _, branchCode := range []string{"", "DHAKA", "1234567"} {
+		if _, err := FormatProvisionalBarcode(branchCode, 1); err == nil {
+			t.Errorf("branch code %q accepted", branchCode)
+		}
+	}
+	if _, err := FormatProvisionalBarcode("1216", maxProvisionalSequence+1); err == nil {
+		t.Error("sequence past the range accepted")
+	}
+}
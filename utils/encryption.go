@@ -7,50 +7,28 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
-	"os"
+	"strings"
 )
 
-// getEncryptionKey retrieves the encryption key from environment variables
-func getEncryptionKey() ([]byte, error) {
-	key := os.Getenv("ENCRYPTION_KEY")
-	if key == "" {
-		return nil, fmt.Errorf("ENCRYPTION_KEY environment variable is not set")
-	}
-
-	// If the key is base64 encoded, decode it
-	keyBytes, err := base64.StdEncoding.DecodeString(key)
-	if err != nil {
-		// If decoding fails, use the key as is (assuming it's already bytes)
-		return []byte(key), nil
-	}
-
-	// Ensure the key is 32 bytes for AES-256
-	if len(keyBytes) != 32 {
-		return nil, fmt.Errorf("encryption key must be 32 bytes for AES-256, got %d bytes", len(keyBytes))
-	}
+// ciphertextVersion prefixes ciphertexts that carry the ID of the key used to seal them:
+// "v1:<key id>:<base64 nonce+ciphertext>". Values without the prefix predate the
+// keyring and are opened with the legacy ENCRYPTION_KEY.
+const ciphertextVersion = "v1"
 
-	return keyBytes, nil
-}
-
-// EncryptData encrypts the given data using AES-256-GCM
+// EncryptData encrypts the given data using AES-256-GCM with the keyring's active key
 func EncryptData(data string) (string, error) {
 	if data == "" {
 		return "", nil
 	}
 
-	key, err := getEncryptionKey()
+	keyID, key, err := DefaultKeyring().ActiveKey()
 	if err != nil {
 		return "", fmt.Errorf("failed to get encryption key: %w", err)
 	}
 
-	block, err := aes.NewCipher(key)
+	gcm, err := newGCM(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher block: %w", err)
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return "", err
 	}
 
 	nonce := make([]byte, gcm.NonceSize())
@@ -59,7 +37,7 @@ func EncryptData(data string) (string, error) {
 	}
 
 	ciphertext := gcm.Seal(nonce, nonce, []byte(data), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return fmt.Sprintf("%s:%s:%s", ciphertextVersion, keyID, base64.StdEncoding.EncodeToString(ciphertext)), nil
 }
 
 // DecryptData decrypts the given encrypted data using AES-256-GCM
@@ -68,24 +46,20 @@ func DecryptData(encryptedData string) (string, error) {
 		return "", nil
 	}
 
-	key, err := getEncryptionKey()
+	keyID, payload := splitCiphertext(encryptedData)
+	key, err := DefaultKeyring().Key(keyID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get encryption key: %w", err)
 	}
 
-	ciphertext, err := base64.StdEncoding.DecodeString(encryptedData)
+	ciphertext, err := base64.StdEncoding.DecodeString(payload)
 	if err != nil {
 		return "", fmt.Errorf("failed to decode base64: %w", err)
 	}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cipher block: %w", err)
-	}
-
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := newGCM(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return "", err
 	}
 
 	nonceSize := gcm.NonceSize()
@@ -101,3 +75,51 @@ func DecryptData(encryptedData string) (string, error) {
 
 	return string(plaintext), nil
 }
+
+// CiphertextKeyID returns the ID of the key that sealed the value
+func CiphertextKeyID(encryptedData string) string {
+	keyID, _ := splitCiphertext(encryptedData)
+	return keyID
+}
+
+// NeedsReencrypt reports whether the value was sealed with a key other than the active one
+func NeedsReencrypt(encryptedData string) (bool, error) {
+	if encryptedData == "" {
+		return false, nil
+	}
+	activeID, _, err := DefaultKeyring().ActiveKey()
+	if err != nil {
+		return false, err
+	}
+	return CiphertextKeyID(encryptedData) != activeID, nil
+}
+
+// ReencryptData opens the value with whichever key sealed it and seals it again with the active key
+func ReencryptData(encryptedData string) (string, error) {
+	plaintext, err := DecryptData(encryptedData)
+	if err != nil {
+		return "", err
+	}
+	return EncryptData(plaintext)
+}
+
+func splitCiphertext(encryptedData string) (keyID, payload string) {
+	parts := strings.SplitN(encryptedData, ":", 3)
+	if len(parts) == 3 && parts[0] == ciphertextVersion {
+		return parts[1], parts[2]
+	}
+	return LegacyKeyID, encryptedData
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher block: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
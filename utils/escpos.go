@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"bytes"
+)
+
+// escposLineChars is the width of an 80mm thermal roll in Font A
+const escposLineChars = 48
+
+// ESCPOS builds a receipt as ESC/POS commands for counter thermal printers
+type ESCPOS struct {
+	buf bytes.Buffer
+}
+
+// NewESCPOS starts a receipt with the printer reset to its defaults
+func NewESCPOS() *ESCPOS {
+	p := &ESCPOS{}
+	p.buf.Write([]byte{0x1b, '@'})
+	return p
+}
+
+// Center switches between centred and left-aligned text
+func (p *ESCPOS) Center(on bool) *ESCPOS {
+	n := byte(0)
+	if on {
+		n = 1
+	}
+	p.buf.Write([]byte{0x1b, 'a', n})
+	return p
+}
+
+// Bold toggles emphasized text
+func (p *ESCPOS) Bold(on bool) *ESCPOS {
+	n := byte(0)
+	if on {
+		n = 1
+	}
+	p.buf.Write([]byte{0x1b, 'E', n})
+	return p
+}
+
+// Line prints one line of text, wrapping it to the roll width. Characters outside
+// printable ASCII are replaced with '?' since printers default to code page 437.
+func (p *ESCPOS) Line(text string) *ESCPOS {
+	text = pdfSanitize(text)
+	for len(text) > escposLineChars {
+		p.buf.WriteString(text[:escposLineChars])
+		p.buf.WriteByte('\n')
+		text = text[escposLineChars:]
+	}
+	p.buf.WriteString(text)
+	p.buf.WriteByte('\n')
+	return p
+}
+
+// Pair prints a label on the left and its value right-aligned on the same line
+func (p *ESCPOS) Pair(label, value string) *ESCPOS {
+	gap := escposLineChars - len(label) - len(value)
+	if gap < 1 {
+		return p.Line(label).Line("  " + value)
+	}
+	return p.Line(label + string(bytes.Repeat([]byte{' '}, gap)) + value)
+}
+
+// Rule prints a dashed separator across the roll
+func (p *ESCPOS) Rule() *ESCPOS {
+	return p.Line(string(bytes.Repeat([]byte{'-'}, escposLineChars)))
+}
+
+// Barcode prints data as a CODE128 barcode with its text underneath
+func (p *ESCPOS) Barcode(data string) *ESCPOS {
+	data = pdfSanitize(data)
+	if data == "" || len(data) > 253 {
+		return p
+	}
+	p.buf.Write([]byte{0x1d, 'h', 80}) // height in dots
+	p.buf.Write([]byte{0x1d, 'w', 2})  // module width
+	p.buf.Write([]byte{0x1d, 'H', 2})  // text below
+	p.buf.Write([]byte{0x1d, 'k', 73, byte(len(data) + 2), '{', 'B'})
+	p.buf.WriteString(data)
+	p.buf.WriteByte('\n')
+	return p
+}
+
+// Cut feeds the paper past the tear bar and cuts it
+func (p *ESCPOS) Cut() *ESCPOS {
+	p.buf.Write([]byte{0x1d, 'V', 66, 3})
+	return p
+}
+
+// Bytes returns the encoded receipt
+func (p *ESCPOS) Bytes() []byte {
+	return p.buf.Bytes()
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GenerateETag builds a weak ETag from a resource key and its last update time
+func GenerateETag(key string, updatedAt time.Time, extra ...string) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s|%d", key, updatedAt.UnixNano())
+	for _, e := range extra {
+		fmt.Fprintf(h, "|%s", e)
+	}
+	return fmt.Sprintf(`W/"%s"`, hex.EncodeToString(h.Sum(nil))[:20])
+}
+
+// CheckETag sets the ETag header and reports whether the client's
+// If-None-Match already matches, in which case a 304 should be returned
+func CheckETag(c *fiber.Ctx, etag string) bool {
+	c.Set(fiber.HeaderETag, etag)
+
+	ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch)
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ParseFields splits a ?fields= value into a clean list of field names
+func ParseFields(raw string) []string {
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// SelectFields reduces data (a struct, map or slice of them) to the requested
+// top-level JSON fields. When no fields are requested data is returned as is.
+func SelectFields(data interface{}, rawFields string) (interface{}, error) {
+	fields := ParseFields(rawFields)
+	if len(fields) == 0 {
+		return data, nil
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode data for field selection: %w", err)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode data for field selection: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		wanted[f] = true
+	}
+
+	switch v := decoded.(type) {
+	case []interface{}:
+		for i, item := range v {
+			v[i] = pickFields(item, wanted)
+		}
+		return v, nil
+	default:
+		return pickFields(v, wanted), nil
+	}
+}
+
+func pickFields(item interface{}, wanted map[string]bool) interface{} {
+	obj, ok := item.(map[string]interface{})
+	if !ok {
+		return item
+	}
+	picked := make(map[string]interface{}, len(wanted))
+	for key, value := range obj {
+		if wanted[key] {
+			picked[key] = value
+		}
+	}
+	return picked
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"passport-booking/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LegacyKeyID identifies the single static ENCRYPTION_KEY used before the keyring
+const LegacyKeyID = "legacy"
+
+// keyringCacheTTL bounds how long keys fetched from the provider are reused
+const keyringCacheTTL = 5 * time.Minute
+
+// KeySet is a snapshot of all known data keys and the one new data is sealed with
+type KeySet struct {
+	ActiveID string
+	Keys     map[string][]byte
+}
+
+// KeyProvider loads data encryption keys from a secret store
+type KeyProvider interface {
+	LoadKeys() (*KeySet, error)
+}
+
+// Keyring caches the keys returned by a provider
+type Keyring struct {
+	provider KeyProvider
+
+	mu        sync.Mutex
+	keys      *KeySet
+	fetchedAt time.Time
+}
+
+// NewKeyring creates a keyring backed by the given provider
+func NewKeyring(provider KeyProvider) *Keyring {
+	return &Keyring{provider: provider}
+}
+
+var (
+	defaultKeyring     *Keyring
+	defaultKeyringOnce sync.Once
+)
+
+// DefaultKeyring returns the process-wide keyring selected by KEYRING_PROVIDER
+// ("env" by default, or "vault")
+func DefaultKeyring() *Keyring {
+	defaultKeyringOnce.Do(func() {
+		switch strings.ToLower(os.Getenv("KEYRING_PROVIDER")) {
+		case "vault":
+			defaultKeyring = NewKeyring(NewVaultKeyProvider())
+		default:
+			defaultKeyring = NewKeyring(EnvKeyProvider{})
+		}
+	})
+	return defaultKeyring
+}
+
+func (k *Keyring) load() (*KeySet, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.keys != nil && time.Since(k.fetchedAt) < keyringCacheTTL {
+		return k.keys, nil
+	}
+
+	keys, err := k.provider.LoadKeys()
+	if err != nil {
+		// Keep serving the last known keys if the secret store is briefly unreachable
+		if k.keys != nil {
+			return k.keys, nil
+		}
+		return nil, err
+	}
+	if _, ok := keys.Keys[keys.ActiveID]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", keys.ActiveID)
+	}
+
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	return keys, nil
+}
+
+// ActiveKey returns the key new data is encrypted with
+func (k *Keyring) ActiveKey() (string, []byte, error) {
+	keys, err := k.load()
+	if err != nil {
+		return "", nil, err
+	}
+	return keys.ActiveID, keys.Keys[keys.ActiveID], nil
+}
+
+// Key returns the key with the given ID
+func (k *Keyring) Key(id string) ([]byte, error) {
+	keys, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %q not found in keyring", id)
+	}
+	return key, nil
+}
+
+// Invalidate drops cached keys so the next call reloads them from the provider
+func (k *Keyring) Invalidate() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = nil
+}
+
+// parseKey accepts a base64 encoded 32 byte key, or a raw key as the legacy env var did
+func parseKey(value string) ([]byte, error) {
+	keyBytes, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		// If decoding fails, use the key as is (assuming it's already bytes)
+		return []byte(value), nil
+	}
+	if len(keyBytes) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes for AES-256, got %d bytes", len(keyBytes))
+	}
+	return keyBytes, nil
+}
+
+// EnvKeyProvider reads keys from ENCRYPTION_KEYS ("id:key,id:key") and the active
+// key ID from ENCRYPTION_ACTIVE_KEY_ID. ENCRYPTION_KEY is kept as the "legacy" key.
+type EnvKeyProvider struct{}
+
+// LoadKeys implements KeyProvider
+func (EnvKeyProvider) LoadKeys() (*KeySet, error) {
+	keys := &KeySet{Keys: make(map[string][]byte)}
+
+	if legacy := os.Getenv("ENCRYPTION_KEY"); legacy != "" {
+		key, err := parseKey(legacy)
+		if err != nil {
+			return nil, fmt.Errorf("ENCRYPTION_KEY: %w", err)
+		}
+		keys.Keys[LegacyKeyID] = key
+		keys.ActiveID = LegacyKeyID
+	}
+
+	for _, entry := range strings.Split(os.Getenv("ENCRYPTION_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || value == "" {
+			return nil, fmt.Errorf("ENCRYPTION_KEYS entries must look like id:key")
+		}
+		key, err := parseKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("ENCRYPTION_KEYS %s: %w", id, err)
+		}
+		keys.Keys[id] = key
+		keys.ActiveID = id
+	}
+
+	if active := os.Getenv("ENCRYPTION_ACTIVE_KEY_ID"); active != "" {
+		keys.ActiveID = active
+	}
+	if len(keys.Keys) == 0 {
+		return nil, errors.New("ENCRYPTION_KEY environment variable is not set")
+	}
+	return keys, nil
+}
+
+// VaultKeyProvider reads the keyring from a Vault KV v2 secret shaped like
+// {"active": "<id>", "keys": {"<id>": "<base64 key>"}}
+type VaultKeyProvider struct {
+	Addr  string
+	Token string
+	Path  string
+}
+
+// NewVaultKeyProvider configures the provider from VAULT_ADDR, VAULT_TOKEN and VAULT_KEYRING_PATH
+func NewVaultKeyProvider() *VaultKeyProvider {
+	return &VaultKeyProvider{
+		Addr:  os.Getenv("VAULT_ADDR"),
+		Token: os.Getenv("VAULT_TOKEN"),
+		Path:  os.Getenv("VAULT_KEYRING_PATH"),
+	}
+}
+
+// LoadKeys implements KeyProvider
+func (v *VaultKeyProvider) LoadKeys() (*KeySet, error) {
+	data, err := config.ReadVaultKV(v.Addr, v.Token, v.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	active, _ := data["active"].(string)
+	rawKeys, _ := data["keys"].(map[string]interface{})
+
+	keys := &KeySet{ActiveID: active, Keys: make(map[string][]byte)}
+	for id, raw := range rawKeys {
+		value, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("vault key %s must be a string", id)
+		}
+		if strings.Contains(id, ":") {
+			return nil, fmt.Errorf("vault key id %q must not contain ':'", id)
+		}
+		key, err := parseKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("vault key %s: %w", id, err)
+		}
+		keys.Keys[id] = key
+	}
+	return keys, nil
+}
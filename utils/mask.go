@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maskedPhonePattern finds mobile numbers inside free text such as message bodies
+var maskedPhonePattern = regexp.MustCompile(`(?:\+88|\b88|\b)01[3-9]\d{8}\b`)
+
+// MaskPhone keeps the operator prefix and the last three digits of a phone number,
+// e.g. 01712345123 becomes 017*****123
+func MaskPhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return ""
+	}
+	national := strings.TrimPrefix(strings.TrimPrefix(phone, "+"), "88")
+	if len(national) < 7 {
+		return strings.Repeat("*", len(phone))
+	}
+	return national[:3] + strings.Repeat("*", len(national)-6) + national[len(national)-3:]
+}
+
+// MaskPhonePtr masks a nullable phone number
+func MaskPhonePtr(phone *string) *string {
+	if phone == nil {
+		return nil
+	}
+	masked := MaskPhone(*phone)
+	return &masked
+}
+
+// MaskPhonesIn masks every mobile number inside text
+func MaskPhonesIn(text string) string {
+	return maskedPhonePattern.ReplaceAllStringFunc(text, MaskPhone)
+}
+
+// MaskAddress keeps only the last part of a comma separated address, usually the
+// area or district, e.g. "House 12, Road 5, Dhanmondi" becomes "****, Dhanmondi"
+func MaskAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return ""
+	}
+	parts := strings.Split(address, ",")
+	if len(parts) > 1 {
+		return "****, " + strings.TrimSpace(parts[len(parts)-1])
+	}
+	return "****"
+}
+
+// MaskAddressPtr masks a nullable address
+func MaskAddressPtr(address *string) *string {
+	if address == nil {
+		return nil
+	}
+	masked := MaskAddress(*address)
+	return &masked
+}
+
+// MaskRecipient masks a notification recipient, which is a phone number or an email
+func MaskRecipient(recipient string) string {
+	if at := strings.Index(recipient, "@"); at > 0 {
+		return recipient[:1] + "***" + recipient[at:]
+	}
+	return MaskPhone(recipient)
+}
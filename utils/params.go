@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// maxIdentifierLength bounds free-form identifiers such as application IDs and
+// booking references
+const maxIdentifierLength = 64
+
+// ValidateNumericID checks that id is a positive integer that fits a database ID
+func ValidateNumericID(id string) error {
+	n, err := strconv.ParseUint(id, 10, 32)
+	if err != nil || n == 0 {
+		return fmt.Errorf("must be a positive number")
+	}
+	return nil
+}
+
+// ValidatePhone checks a Bangladeshi mobile number, with or without +88
+func ValidatePhone(phone string) error {
+	if !ValidatePhoneNumber(phone) {
+		return fmt.Errorf("must be a valid mobile number, e.g. 01XXXXXXXXX")
+	}
+	return nil
+}
+
+// ValidateIdentifier checks a free-form identifier for length and printable ASCII
+func ValidateIdentifier(id string) error {
+	if len(id) > maxIdentifierLength {
+		return fmt.Errorf("must be at most %d characters", maxIdentifierLength)
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return fmt.Errorf("contains invalid characters")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// defaultTrackingPath is used when PUBLIC_TRACKING_BASE_URL is not configured
+const defaultTrackingPath = "/api/booking/track/"
+
+// TrackingURL returns the public tracking URL encoded into QR codes
+func TrackingURL(barcode string) string {
+	base := os.Getenv("PUBLIC_TRACKING_BASE_URL")
+	if base == "" {
+		base = defaultTrackingPath
+	}
+	return strings.TrimRight(base, "/") + "/" + url.PathEscape(barcode)
+}
+
+// GenerateQRCodePNG renders content as a PNG QR code of the given pixel size
+func GenerateQRCodePNG(content string, size int) ([]byte, error) {
+	if size <= 0 {
+		size = 256
+	}
+	png, err := qrcode.Encode(content, qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+	return png, nil
+}
+
+// GenerateQRCodeBase64 renders content as a base64 data URI PNG QR code
+func GenerateQRCodeBase64(content string, size int) (string, error) {
+	png, err := GenerateQRCodePNG(content, size)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png), nil
+}
+
+// barcodeFromScan extracts the barcode from a scanned tracking QR URL,
+// returning the input unchanged when it is not a tracking URL
+func barcodeFromScan(scan string) string {
+	idx := strings.LastIndex(scan, "/track/")
+	if idx == -1 {
+		return scan
+	}
+	barcode := scan[idx+len("/track/"):]
+	if cut := strings.IndexAny(barcode, "/?#"); cut != -1 {
+		barcode = barcode[:cut]
+	}
+	if unescaped, err := url.PathUnescape(barcode); err == nil {
+		barcode = unescaped
+	}
+	return barcode
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"passport-booking/database"
+	"passport-booking/httpServices/dms"
 	"passport-booking/models/user"
 	"passport-booking/types"
 	"regexp"
@@ -160,7 +161,7 @@ func GenerateBarcode(serviceName, authHeader string) (string, error) {
 		auth = "Bearer " + auth
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := dms.NewHTTPClient(30 * time.Second)
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return "", fmt.Errorf("create request: %w", err)
@@ -228,7 +229,7 @@ func GetServiceCost(serviceName string, weight int, additionalService string, tr
 		auth = "Bearer " + auth
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := dms.NewHTTPClient(30 * time.Second)
 	req, err := http.NewRequest(http.MethodGet, url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return 0, fmt.Errorf("create request: %w", err)
@@ -352,14 +353,75 @@ func isLikelyBase64(content string) bool {
 	return float64(base64Chars)/float64(len(content)) > 0.8
 }
 
+// redactedLogFields are JSON fields whose values are bearer secrets and never
+// written to the API log, at any depth of the request or response body
+var redactedLogFields = map[string]bool{
+	"otp_session": true,
+}
+
+// RedactJSONFields replaces the values of redactedLogFields in a JSON body.
+// Bodies that are not JSON are returned unchanged.
+func RedactJSONFields(body string) string {
+	mentioned := false
+	for field := range redactedLogFields {
+		if strings.Contains(body, field) {
+			mentioned = true
+			break
+		}
+	}
+	if !mentioned {
+		return body
+	}
+	// UseNumber keeps large IDs exact when the body is re-encoded
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return body
+	}
+	if !redactValue(decoded) {
+		return body
+	}
+	redacted, err := json.Marshal(decoded)
+	if err != nil {
+		return body
+	}
+	return string(redacted)
+}
+
+// redactValue redacts v in place and reports whether anything was redacted
+func redactValue(v interface{}) bool {
+	changed := false
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if redactedLogFields[key] {
+				value[key] = "[REDACTED]"
+				changed = true
+				continue
+			}
+			if redactValue(field) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if redactValue(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
 // CreateSanitizedLogEntry creates a deep copied and sanitized log entry for logging
 // This function handles file uploads, large content, and creates safe copies of all data
 func CreateSanitizedLogEntry(c *fiber.Ctx) types.LogEntry {
 	// Create deep copies of all data to prevent memory reference issues
 	method := string([]byte(c.Method()))
 	url := string([]byte(c.OriginalURL()))
-	requestBody := sanitizeRequestBody(c) // Use sanitized request body
-	responseBody := string(append([]byte(nil), c.Response().Body()...))
+	requestBody := RedactJSONFields(sanitizeRequestBody(c)) // Use sanitized request body
+	responseBody := RedactJSONFields(string(c.Response().Body()))
 
 	// Deep copy headers
 	requestHeaders := make([]byte, len(c.Request().Header.Header()))
@@ -376,18 +438,28 @@ func CreateSanitizedLogEntry(c *fiber.Ctx) types.LogEntry {
 		RequestHeaders:  string(requestHeaders),
 		ResponseHeaders: string(responseHeaders),
 		StatusCode:      c.Response().StatusCode(),
+		ImpersonatedBy:  impersonatedBy(c),
 		CreatedAt:       time.Now(),
 	}
 }
 
+// impersonatedBy returns the impersonating admin set by the auth middleware, if any
+func impersonatedBy(c *fiber.Ctx) *string {
+	adminUUID, ok := c.Locals("impersonated_by").(string)
+	if !ok || adminUUID == "" {
+		return nil
+	}
+	return &adminUUID
+}
+
 // CreateSanitizedLogEntryWithCustomBody creates a sanitized log entry with custom request and response bodies
 // Useful for cases where you want to provide pre-processed body content
 func CreateSanitizedLogEntryWithCustomBody(c *fiber.Ctx, requestBody, responseBody string) types.LogEntry {
 	// Create deep copies of all data to prevent memory reference issues
 	method := string([]byte(c.Method()))
 	url := string([]byte(c.OriginalURL()))
-	requestBodyCopy := string(append([]byte(nil), []byte(requestBody)...))
-	responseBodyCopy := string(append([]byte(nil), []byte(responseBody)...))
+	requestBodyCopy := RedactJSONFields(string(append([]byte(nil), []byte(requestBody)...)))
+	responseBodyCopy := RedactJSONFields(string(append([]byte(nil), []byte(responseBody)...)))
 
 	// Deep copy headers
 	requestHeaders := make([]byte, len(c.Request().Header.Header()))
@@ -404,6 +476,7 @@ func CreateSanitizedLogEntryWithCustomBody(c *fiber.Ctx, requestBody, responseBo
 		RequestHeaders:  string(requestHeaders),
 		ResponseHeaders: string(responseHeaders),
 		StatusCode:      c.Response().StatusCode(),
+		ImpersonatedBy:  impersonatedBy(c),
 		CreatedAt:       time.Now(),
 	}
 }